@@ -0,0 +1,241 @@
+package kvfile_afero
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	kvfile_fs "github.com/aperturerobotics/go-kvfile/fs"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+)
+
+// ErrReadOnly is returned by the methods which modify the filesystem.
+//
+// The error is wrapped in an *fs.PathError or *os.LinkError.
+var ErrReadOnly = errors.New("read-only file system")
+
+// Fs is a read-only afero.Fs backed by a kvfile.
+//
+// The paths are derived from the keys with the same rules as the kvfile_fs
+// package. Names can be absolute or relative to the root and are cleaned
+// before the lookup, so "/a/b.txt", "a/b.txt", and "a/./b.txt" are the same
+// file. Methods which modify the filesystem return ErrReadOnly.
+type Fs struct {
+	fsys *kvfile_fs.FS
+}
+
+// NewFs builds a read-only Fs with a kvfile reader.
+//
+// modTime is the modification time of the files and directories, can be zero.
+func NewFs(rd *kvfile.Reader, modTime time.Time) *Fs {
+	return &Fs{fsys: kvfile_fs.NewFS(rd, modTime)}
+}
+
+// Name returns the name of the filesystem.
+func (f *Fs) Name() string {
+	return "kvfile"
+}
+
+// fsName converts the afero name to a fs.FS path.
+func fsName(name string) string {
+	name = path.Clean("/" + filepath.ToSlash(name))
+	if name == "/" {
+		return "."
+	}
+	return strings.TrimPrefix(name, "/")
+}
+
+// Open opens the file or directory with the name.
+func (f *Fs) Open(name string) (afero.File, error) {
+	fsFile, err := f.fsys.Open(fsName(name))
+	if err != nil {
+		return nil, err
+	}
+	return &file{name: name, f: fsFile}, nil
+}
+
+// OpenFile opens the file or directory with the name.
+//
+// Returns ErrReadOnly if the flags include any write flags.
+func (f *Fs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, readOnlyError("open", name)
+	}
+	return f.Open(name)
+}
+
+// Stat returns the info for the file or directory with the name.
+func (f *Fs) Stat(name string) (os.FileInfo, error) {
+	return f.fsys.Stat(fsName(name))
+}
+
+// ReadDir reads the named directory and returns the entries sorted by name.
+func (f *Fs) ReadDir(name string) ([]fs.DirEntry, error) {
+	return f.fsys.ReadDir(fsName(name))
+}
+
+// Create returns ErrReadOnly.
+func (f *Fs) Create(name string) (afero.File, error) {
+	return nil, readOnlyError("open", name)
+}
+
+// Mkdir returns ErrReadOnly.
+func (f *Fs) Mkdir(name string, perm os.FileMode) error {
+	return readOnlyError("mkdir", name)
+}
+
+// MkdirAll returns ErrReadOnly.
+func (f *Fs) MkdirAll(path string, perm os.FileMode) error {
+	return readOnlyError("mkdir", path)
+}
+
+// Remove returns ErrReadOnly.
+func (f *Fs) Remove(name string) error {
+	return readOnlyError("remove", name)
+}
+
+// RemoveAll returns ErrReadOnly.
+func (f *Fs) RemoveAll(path string) error {
+	return readOnlyError("unlinkat", path)
+}
+
+// Rename returns ErrReadOnly.
+func (f *Fs) Rename(oldname, newname string) error {
+	return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: ErrReadOnly}
+}
+
+// Chmod returns ErrReadOnly.
+func (f *Fs) Chmod(name string, mode os.FileMode) error {
+	return readOnlyError("chmod", name)
+}
+
+// Chown returns ErrReadOnly.
+func (f *Fs) Chown(name string, uid, gid int) error {
+	return readOnlyError("chown", name)
+}
+
+// Chtimes returns ErrReadOnly.
+func (f *Fs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return readOnlyError("chtimes", name)
+}
+
+// readOnlyError builds the error for a write operation.
+func readOnlyError(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: ErrReadOnly}
+}
+
+// file is an open file or directory.
+type file struct {
+	name string
+	f    fs.File
+}
+
+// Name returns the name the file was opened with.
+func (f *file) Name() string {
+	return f.name
+}
+
+// Stat returns the file info.
+func (f *file) Stat() (os.FileInfo, error) {
+	return f.f.Stat()
+}
+
+// Read reads from the value.
+func (f *file) Read(p []byte) (int, error) {
+	return f.f.Read(p)
+}
+
+// ReadAt reads from the value at the offset.
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	rd, ok := f.f.(io.ReaderAt)
+	if !ok {
+		return 0, f.dirError("read")
+	}
+	return rd.ReadAt(p, off)
+}
+
+// Seek sets the offset of the next Read.
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := f.f.(io.Seeker)
+	if !ok {
+		return 0, f.dirError("seek")
+	}
+	return seeker.Seek(offset, whence)
+}
+
+// Readdir returns the info for the next count entries of the directory, or all
+// remaining entries if count <= 0.
+func (f *file) Readdir(count int) ([]os.FileInfo, error) {
+	dir, ok := f.f.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: errors.New("not a directory")}
+	}
+	entries, err := dir.ReadDir(count)
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, ierr := entry.Info()
+		if ierr != nil {
+			return infos, ierr
+		}
+		infos = append(infos, info)
+	}
+	return infos, err
+}
+
+// Readdirnames returns the names of the next n entries of the directory, or
+// all remaining entries if n <= 0.
+func (f *file) Readdirnames(n int) ([]string, error) {
+	infos, err := f.Readdir(n)
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return names, err
+}
+
+// Close closes the file.
+func (f *file) Close() error {
+	return f.f.Close()
+}
+
+// Sync does nothing.
+func (f *file) Sync() error {
+	return nil
+}
+
+// Write returns ErrReadOnly.
+func (f *file) Write(p []byte) (int, error) {
+	return 0, readOnlyError("write", f.name)
+}
+
+// WriteAt returns ErrReadOnly.
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	return 0, readOnlyError("write", f.name)
+}
+
+// WriteString returns ErrReadOnly.
+func (f *file) WriteString(s string) (int, error) {
+	return 0, readOnlyError("write", f.name)
+}
+
+// Truncate returns ErrReadOnly.
+func (f *file) Truncate(size int64) error {
+	return readOnlyError("truncate", f.name)
+}
+
+// dirError returns the error for reading a directory.
+func (f *file) dirError(op string) error {
+	return &fs.PathError{Op: op, Path: f.name, Err: errors.New("is a directory")}
+}
+
+// _ is a type assertion
+var (
+	_ afero.Fs   = ((*Fs)(nil))
+	_ afero.File = ((*file)(nil))
+)
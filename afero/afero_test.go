@@ -0,0 +1,167 @@
+package kvfile_afero
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+)
+
+// buildTestFs writes a kvfile with the keys and builds a Fs.
+//
+// The value of each key is the key.
+func buildTestFs(t testing.TB, keys []string) *Fs {
+	var buf bytes.Buffer
+	wr := kvfile.NewWriter(&buf)
+	for _, key := range keys {
+		if err := wr.WriteValue([]byte(key), bytes.NewReader([]byte(key))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	rd, err := kvfile.BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return NewFs(rd, time.Unix(1700000000, 0))
+}
+
+var testKeys = []string{
+	"README.md",
+	"a/b/c.txt",
+	"a/b.txt",
+	"docs/guide.md",
+	// invalid path elements are not listed
+	"bad//key",
+}
+
+func TestWalk(t *testing.T) {
+	fsys := buildTestFs(t, testKeys)
+	var paths []string
+	err := afero.Walk(fsys, "/", func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			p += "/"
+		}
+		paths = append(paths, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []string{
+		"//",
+		"/README.md",
+		"/a/",
+		"/a/b/",
+		"/a/b/c.txt",
+		"/a/b.txt",
+		"/bad/",
+		"/docs/",
+		"/docs/guide.md",
+	}
+	if !slices.Equal(paths, expected) {
+		t.Fatalf("unexpected paths:\n%q\nexpected:\n%q", paths, expected)
+	}
+}
+
+func TestFs(t *testing.T) {
+	fsys := buildTestFs(t, testKeys)
+	for _, name := range []string{"a/b/c.txt", "/a/b/c.txt", "a/./b/../b/c.txt"} {
+		data, err := afero.ReadFile(fsys, name)
+		if err != nil || string(data) != "a/b/c.txt" {
+			t.Fatalf("unexpected contents of %s: %q %v", name, data, err)
+		}
+	}
+
+	infos, err := afero.ReadDir(fsys, "/a")
+	if err != nil || len(infos) != 2 || infos[0].Name() != "b" || !infos[0].IsDir() || infos[1].Name() != "b.txt" {
+		t.Fatalf("unexpected dir entries: %v %v", infos, err)
+	}
+	if ok, err := afero.DirExists(fsys, "docs"); err != nil || !ok {
+		t.Fatalf("expected docs to be a directory: %v", err)
+	}
+	if ok, err := afero.Exists(fsys, "missing"); err != nil || ok {
+		t.Fatalf("expected missing to not exist: %v", err)
+	}
+	if _, err := fsys.Stat("a/missing"); !os.IsNotExist(err) {
+		t.Fatalf("expected not exist: %v", err)
+	}
+
+	f, err := fsys.Open("/docs/guide.md")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer f.Close()
+	if f.Name() != "/docs/guide.md" {
+		t.Fatalf("unexpected name: %s", f.Name())
+	}
+	buf := make([]byte, 5)
+	if _, err := f.ReadAt(buf, 5); err != nil || string(buf) != "guide" {
+		t.Fatalf("unexpected ReadAt: %q %v", buf, err)
+	}
+	if _, err := f.Seek(-2, io.SeekEnd); err != nil {
+		t.Fatal(err.Error())
+	}
+	if rest, err := io.ReadAll(f); err != nil || string(rest) != "md" {
+		t.Fatalf("unexpected contents after seek: %q %v", rest, err)
+	}
+	if _, err := f.Readdir(-1); err == nil {
+		t.Fatal("expected an error listing a file")
+	}
+
+	dir, err := fsys.Open("a")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer dir.Close()
+	names, err := dir.Readdirnames(1)
+	if err != nil || !slices.Equal(names, []string{"b"}) {
+		t.Fatalf("unexpected names: %v %v", names, err)
+	}
+	if _, err := dir.Read(buf); err == nil {
+		t.Fatal("expected an error reading a directory")
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	fsys := buildTestFs(t, testKeys)
+	errs := []error{
+		fsys.Mkdir("x", 0o755),
+		fsys.MkdirAll("x/y", 0o755),
+		fsys.Remove("README.md"),
+		fsys.RemoveAll("a"),
+		fsys.Rename("README.md", "x"),
+		fsys.Chmod("README.md", 0o644),
+		fsys.Chown("README.md", 0, 0),
+		fsys.Chtimes("README.md", time.Now(), time.Now()),
+		afero.WriteFile(fsys, "new.txt", []byte("x"), 0o644),
+	}
+	_, err := fsys.Create("new.txt")
+	errs = append(errs, err)
+	_, err = fsys.OpenFile("README.md", os.O_RDWR, 0)
+	errs = append(errs, err)
+
+	f, err := fsys.OpenFile("README.md", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = f.Write([]byte("x"))
+	errs = append(errs, err, f.Truncate(0))
+
+	for i, err := range errs {
+		if !errors.Is(err, ErrReadOnly) {
+			t.Fatalf("expected read-only error for %d: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package kvfile
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Amend writes the entries of the reader with changes applied to a new kvfile.
+//
+// The values in set add or overwrite entries and the keys in remove are
+// skipped: removing a key which does not exist is not an error. The other
+// entries are copied with their metadata, expiry, and tombstones and their
+// values are streamed from the reader.
+func Amend(writer io.Writer, r *Reader, set map[string][]byte, remove [][]byte) error {
+	removeKeys := make(map[string]struct{}, len(remove))
+	for _, key := range remove {
+		if _, ok := set[string(key)]; ok {
+			return errors.Errorf("key %q cannot be both set and removed", key)
+		}
+		removeKeys[string(key)] = struct{}{}
+	}
+	setKeys := make([]string, 0, len(set))
+	for key := range set {
+		if len(key) == 0 {
+			return errors.New("key cannot be empty")
+		}
+		setKeys = append(setKeys, key)
+	}
+	slices.Sort(setKeys)
+
+	wr := NewWriter(writer)
+	writeSet := func(key string) error {
+		value := set[key]
+		return wr.writeEntry(&IndexEntry{Key: []byte(key)}, bytes.NewReader(value), int64(len(value)))
+	}
+
+	size := r.Size()
+	for i := uint64(0); i < size; i++ {
+		indexEntry, err := r.ReadIndexEntry(i)
+		if err != nil {
+			return err
+		}
+		key := indexEntry.GetKey()
+
+		// write the set entries before this key
+		for len(setKeys) != 0 && setKeys[0] < string(key) {
+			if err := writeSet(setKeys[0]); err != nil {
+				return err
+			}
+			setKeys = setKeys[1:]
+		}
+		if len(setKeys) != 0 && setKeys[0] == string(key) {
+			if err := writeSet(setKeys[0]); err != nil {
+				return err
+			}
+			setKeys = setKeys[1:]
+			continue
+		}
+		if _, ok := removeKeys[string(key)]; ok {
+			continue
+		}
+
+		if err := copyEntry(wr, r, indexEntry, int(i), key); err != nil {
+			return err
+		}
+	}
+	for _, key := range setKeys {
+		if err := writeSet(key); err != nil {
+			return err
+		}
+	}
+	return wr.Close()
+}
+
+// KeyCollisionError is returned by Rewrite if two keys are transformed to the
+// same key.
+type KeyCollisionError struct {
+	// Key is the transformed key.
+	Key []byte
+	// First is the first original key.
+	First []byte
+	// Second is the second original key.
+	Second []byte
+}
+
+// Error returns the error string.
+func (e *KeyCollisionError) Error() string {
+	return "keys " + strconv.Quote(string(e.First)) + " and " + strconv.Quote(string(e.Second)) + " both map to " + strconv.Quote(string(e.Key))
+}
+
+// Rewrite writes the entries of the reader with transformed keys to a new
+// kvfile.
+//
+// transform returns the new key for a key, or false to skip the entry. The
+// entries are copied with their metadata, expiry, and tombstones and their
+// values are streamed from the reader. Returns a *KeyCollisionError if two keys
+// are transformed to the same key.
+//
+// Returns the number of entries written.
+func Rewrite(writer io.Writer, r *Reader, transform func(key []byte) ([]byte, bool, error)) (uint64, error) {
+	wr := NewWriter(writer)
+	// written maps each new key to the original key
+	written := make(map[string][]byte)
+	size := r.Size()
+	for i := uint64(0); i < size; i++ {
+		indexEntry, err := r.ReadIndexEntry(i)
+		if err != nil {
+			return 0, err
+		}
+		key := indexEntry.GetKey()
+		newKey, ok, err := transform(key)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		if len(newKey) == 0 {
+			return 0, errors.Errorf("key %q transformed to an empty key", key)
+		}
+		if first, ok := written[string(newKey)]; ok {
+			return 0, &KeyCollisionError{Key: newKey, First: first, Second: key}
+		}
+		written[string(newKey)] = key
+		if err := copyEntry(wr, r, indexEntry, int(i), newKey); err != nil {
+			return 0, err
+		}
+	}
+	if err := wr.Close(); err != nil {
+		return 0, err
+	}
+	return uint64(len(written)), nil
+}
+
+// CopyEntry writes the entry from the reader to the writer.
+//
+// Copies the key, metadata, expiry, and tombstone and streams the value from
+// the reader. indexEntryIdx is the index of the entry in the reader.
+// The writer is closed if an error is returned while writing the value.
+func (w *Writer) CopyEntry(r *Reader, indexEntry *IndexEntry, indexEntryIdx int) error {
+	return copyEntry(w, r, indexEntry, indexEntryIdx, indexEntry.GetKey())
+}
+
+// copyEntry writes the entry from the reader with the key to the writer.
+//
+// Copies the metadata, expiry, and tombstone and streams the value.
+func copyEntry(wr *Writer, r *Reader, indexEntry *IndexEntry, indexEntryIdx int, key []byte) error {
+	valueRdr, err := r.GetValueReaderWithEntry(indexEntry, indexEntryIdx)
+	if err != nil {
+		return err
+	}
+	return wr.writeEntry(&IndexEntry{
+		Key:           key,
+		ExpiresUnixMs: indexEntry.GetExpiresUnixMs(),
+		Meta:          indexEntry.GetMeta(),
+		Tombstone:     indexEntry.GetTombstone(),
+	}, valueRdr, valueRdr.Size())
+}
+
+// WriteExcludingPrefix writes the entries of the reader without the keys with
+// any of the prefixes to a new kvfile.
+//
+// The other entries are copied with their metadata, expiry, and tombstones and
+// their values are streamed from the reader.
+//
+// Returns the number of entries and value bytes removed.
+func WriteExcludingPrefix(writer io.Writer, r *Reader, prefixes [][]byte) (removed, removedBytes uint64, err error) {
+	wr := NewWriter(writer)
+	size := r.Size()
+	for i := uint64(0); i < size; i++ {
+		indexEntry, err := r.ReadIndexEntry(i)
+		if err != nil {
+			return 0, 0, err
+		}
+		key := indexEntry.GetKey()
+		if slices.ContainsFunc(prefixes, func(prefix []byte) bool { return bytes.HasPrefix(key, prefix) }) {
+			_, length, err := r.GetValuePositionWithEntry(indexEntry, int(i))
+			if err != nil {
+				return 0, 0, err
+			}
+			removed++
+			removedBytes += uint64(length)
+			continue
+		}
+		if err := copyEntry(wr, r, indexEntry, int(i), key); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := wr.Close(); err != nil {
+		return 0, 0, err
+	}
+	return removed, removedBytes, nil
+}
@@ -0,0 +1,181 @@
+package kvfile
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestAmend(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	if err := wr.WriteValueBytesMeta([]byte("b"), []byte("val-b"), []byte("meta-b")); err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, key := range []string{"d", "f"} {
+		if err := wr.WriteValue([]byte(key), bytes.NewReader([]byte("val-"+key))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.WriteTombstone([]byte("g")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var out bytes.Buffer
+	set := map[string][]byte{"a": []byte("new-a"), "d": []byte("new-d"), "z": []byte("new-z")}
+	remove := [][]byte{[]byte("f"), []byte("missing")}
+	if err := Amend(&out, rdr, set, remove); err != nil {
+		t.Fatal(err.Error())
+	}
+	amended, err := BuildReaderWithOptions(bytes.NewReader(out.Bytes()), uint64(out.Len()), ReaderOptions{ExposeTombstones: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := amended.Verify(true); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var keys, values []string
+	err = amended.ScanPrefix(nil, func(key, value []byte) error {
+		keys, values = append(keys, string(key)), append(values, string(value))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if strings.Join(keys, ",") != "a,b,d,g,z" {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+	expected := []string{"new-a", "val-b", "new-d", "", "new-z"}
+	for i, value := range values {
+		if value != expected[i] {
+			t.Fatalf("unexpected value for %s: %q", keys[i], value)
+		}
+	}
+
+	// metadata and tombstones are copied
+	entry, _, err := amended.SearchIndexEntryWithKey([]byte("b"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(entry.GetMeta()) != "meta-b" {
+		t.Fatalf("expected metadata to be copied: %q", entry.GetMeta())
+	}
+	entry, _, err = amended.SearchIndexEntryWithKey([]byte("g"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !entry.GetTombstone() {
+		t.Fatal("expected tombstone to be copied")
+	}
+
+	if err := Amend(&out, rdr, map[string][]byte{"b": nil}, [][]byte{[]byte("b")}); err == nil {
+		t.Fatal("expected error setting and removing the same key")
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	if err := wr.WriteValueBytesMeta([]byte("old/a"), []byte("val-a"), []byte("meta-a")); err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, key := range []string{"old/b", "other/c"} {
+		if err := wr.WriteValue([]byte(key), bytes.NewReader([]byte("val-"+key))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// move old/ to z/ which reverses the key order
+	var out bytes.Buffer
+	n, err := Rewrite(&out, rdr, func(key []byte) ([]byte, bool, error) {
+		if !bytes.HasPrefix(key, []byte("old/")) {
+			return nil, false, nil
+		}
+		return append([]byte("z/"), key[4:]...), true, nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 entries written: %v", n)
+	}
+	rewritten, err := BuildReader(bytes.NewReader(out.Bytes()), uint64(out.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := rewritten.Verify(true); err != nil {
+		t.Fatal(err.Error())
+	}
+	entry, _, err := rewritten.SearchIndexEntryWithKey([]byte("z/a"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if entry == nil || string(entry.GetMeta()) != "meta-a" {
+		t.Fatalf("expected z/a with metadata: %v", entry)
+	}
+	if val, found, err := rewritten.Get([]byte("z/b")); err != nil || !found || string(val) != "val-old/b" {
+		t.Fatalf("unexpected value for z/b: %q %v %v", val, found, err)
+	}
+
+	_, err = Rewrite(&out, rdr, func(key []byte) ([]byte, bool, error) {
+		return []byte("same"), true, nil
+	})
+	var collisionErr *KeyCollisionError
+	if !errors.As(err, &collisionErr) || string(collisionErr.First) != "old/a" || string(collisionErr.Second) != "old/b" {
+		t.Fatalf("expected collision error: %v", err)
+	}
+}
+
+func TestWriteExcludingPrefix(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	for _, key := range []string{"a/1", "a/2", "ab/3", "b/4", "c/5"} {
+		if err := wr.WriteValue([]byte(key), bytes.NewReader([]byte("val-"+key))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the overlapping prefixes a and a/ remove each entry once
+	var out bytes.Buffer
+	removed, removedBytes, err := WriteExcludingPrefix(&out, rdr, [][]byte{[]byte("a/"), []byte("a"), []byte("c/")})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if removed != 4 || removedBytes != uint64(len("val-a/1val-a/2val-ab/3val-c/5")) {
+		t.Fatalf("unexpected removed count: %v %v", removed, removedBytes)
+	}
+	pruned, err := BuildReader(bytes.NewReader(out.Bytes()), uint64(out.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if pruned.Size() != 1 {
+		t.Fatalf("expected 1 entry: %v", pruned.Size())
+	}
+	if val, found, err := pruned.Get([]byte("b/4")); err != nil || !found || string(val) != "val-b/4" {
+		t.Fatalf("unexpected value for b/4: %q %v %v", val, found, err)
+	}
+}
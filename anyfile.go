@@ -0,0 +1,168 @@
+package kvfile
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// FileStrategy is the strategy used to read a fs.File.
+type FileStrategy int
+
+const (
+	// FileStrategyAuto uses ReadAt if available, otherwise reads the file to
+	// memory if it is at most MaxMemorySize, otherwise seeks.
+	FileStrategyAuto FileStrategy = iota
+	// FileStrategyReaderAt reads with the io.ReaderAt of the file.
+	FileStrategyReaderAt
+	// FileStrategyMemory reads the entire file to memory.
+	FileStrategyMemory
+	// FileStrategySeeker reads with Seek and Read guarded by a mutex.
+	FileStrategySeeker
+)
+
+// String returns the name of the strategy.
+func (s FileStrategy) String() string {
+	switch s {
+	case FileStrategyAuto:
+		return "auto"
+	case FileStrategyReaderAt:
+		return "reader-at"
+	case FileStrategyMemory:
+		return "memory"
+	case FileStrategySeeker:
+		return "seeker"
+	default:
+		return "unknown"
+	}
+}
+
+// DefaultMaxMemorySize is the default FileOptions.MaxMemorySize.
+const DefaultMaxMemorySize = 16 * 1024 * 1024
+
+// FileOptions are options for BuildReaderWithAnyFileOptions.
+type FileOptions struct {
+	// Strategy is the strategy to use to read the file.
+	// Defaults to FileStrategyAuto.
+	Strategy FileStrategy
+	// MaxMemorySize is the largest file FileStrategyAuto reads to memory.
+	// If zero, uses DefaultMaxMemorySize.
+	MaxMemorySize int64
+	// ReaderOptions are the options for the Reader.
+	ReaderOptions ReaderOptions
+}
+
+// BuildReaderWithAnyFile constructs a new Reader with a fs.File.
+//
+// Unlike BuildReaderWithFile, the file does not need to implement io.ReaderAt.
+// See FileStrategyAuto. The file must not be closed while using the Reader.
+func BuildReaderWithAnyFile(f fs.File) (*Reader, error) {
+	rd, _, err := BuildReaderWithAnyFileOptions(f, FileOptions{})
+	return rd, err
+}
+
+// BuildReaderFromFS opens the file in the filesystem and constructs a new Reader.
+//
+// Returns the opened file which must be closed after using the Reader.
+func BuildReaderFromFS(fsys fs.FS, name string) (*Reader, fs.File, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	rd, err := BuildReaderWithAnyFile(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, err
+	}
+	return rd, f, nil
+}
+
+// BuildReaderWithAnyFileOptions constructs a new Reader with a fs.File.
+//
+// Returns the strategy used to read the file. The file must not be closed
+// while using the Reader unless the strategy is FileStrategyMemory.
+func BuildReaderWithAnyFileOptions(f fs.File, opts FileOptions) (*Reader, FileStrategy, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, FileStrategyAuto, err
+	}
+	size := fi.Size()
+	if size < 0 {
+		return nil, FileStrategyAuto, errors.Errorf("invalid file size: %v", size)
+	}
+
+	strategy := opts.Strategy
+	if strategy == FileStrategyAuto {
+		maxMemorySize := opts.MaxMemorySize
+		if maxMemorySize == 0 {
+			maxMemorySize = DefaultMaxMemorySize
+		}
+		_, isReaderAt := f.(io.ReaderAt)
+		_, isSeeker := f.(io.Seeker)
+		switch {
+		case isReaderAt:
+			strategy = FileStrategyReaderAt
+		case size <= maxMemorySize:
+			strategy = FileStrategyMemory
+		case isSeeker:
+			strategy = FileStrategySeeker
+		default:
+			return nil, FileStrategyAuto, errors.Errorf("file does not implement ReadAt or Seek and is larger than %v bytes", maxMemorySize)
+		}
+	}
+
+	var rdAt io.ReaderAt
+	switch strategy {
+	case FileStrategyReaderAt:
+		var ok bool
+		rdAt, ok = f.(io.ReaderAt)
+		if !ok {
+			return nil, strategy, errors.New("file does not implement ReadAt")
+		}
+	case FileStrategyMemory:
+		data := make([]byte, size)
+		if _, err := io.ReadFull(f, data); err != nil {
+			return nil, strategy, err
+		}
+		rdAt = bytes.NewReader(data)
+	case FileStrategySeeker:
+		seeker, ok := f.(io.ReadSeeker)
+		if !ok {
+			return nil, strategy, errors.New("file does not implement Seek")
+		}
+		rdAt = &seekerReaderAt{rd: seeker}
+	default:
+		return nil, strategy, errors.Errorf("unknown file strategy: %v", int(strategy))
+	}
+
+	rd, err := BuildReaderWithOptions(rdAt, uint64(size), opts.ReaderOptions)
+	if err != nil {
+		return nil, strategy, err
+	}
+	return rd, strategy, nil
+}
+
+// seekerReaderAt implements io.ReaderAt with Seek and Read.
+//
+// Concurrency safe: reads are serialized with a mutex.
+type seekerReaderAt struct {
+	mtx sync.Mutex
+	rd  io.ReadSeeker
+}
+
+// ReadAt reads len(p) bytes starting at off.
+func (s *seekerReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, err := s.rd.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(s.rd, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,107 @@
+package kvfile
+
+import (
+	"bytes"
+	"embed"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+//go:embed testdata/embed.kvf
+var testEmbedFS embed.FS
+
+// readOnlyFile hides the ReadAt and Seek methods of a fs.File.
+type readOnlyFile struct {
+	fs.File
+}
+
+// readSeekFile hides the ReadAt method of a fs.File.
+type readSeekFile struct {
+	fs.File
+	io.Seeker
+}
+
+// checkEmbedReader checks the contents of testdata/embed.kvf.
+func checkEmbedReader(t *testing.T, rdr *Reader) {
+	for key, expected := range map[string]string{"embed/a": "1", "embed/b": "2", "hello": "world"} {
+		val, found, err := rdr.Get([]byte(key))
+		if err != nil || !found || string(val) != expected {
+			t.Fatalf("unexpected value for %s: %q %v %v", key, val, found, err)
+		}
+	}
+}
+
+func TestBuildReaderFromFS(t *testing.T) {
+	data, err := testEmbedFS.ReadFile("testdata/embed.kvf")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	mapFS := fstest.MapFS{"dir/test.kvf": &fstest.MapFile{Data: data}}
+	for name, fsys := range map[string]fs.FS{"testdata/embed.kvf": testEmbedFS, "dir/test.kvf": mapFS} {
+		rdr, f, err := BuildReaderFromFS(fsys, name)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		checkEmbedReader(t, rdr)
+		if err := f.Close(); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if _, _, err := BuildReaderFromFS(mapFS, "missing.kvf"); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+
+	open := func() fs.File {
+		f, err := testEmbedFS.Open("testdata/embed.kvf")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return f
+	}
+	tests := []struct {
+		wrap     func(f fs.File) fs.File
+		opts     FileOptions
+		expected FileStrategy
+	}{
+		{func(f fs.File) fs.File { return f }, FileOptions{}, FileStrategyReaderAt},
+		{func(f fs.File) fs.File { return readOnlyFile{f} }, FileOptions{}, FileStrategyMemory},
+		{func(f fs.File) fs.File { return readSeekFile{f, f.(io.Seeker)} }, FileOptions{MaxMemorySize: 8}, FileStrategySeeker},
+		{func(f fs.File) fs.File { return readSeekFile{f, f.(io.Seeker)} }, FileOptions{Strategy: FileStrategyMemory}, FileStrategyMemory},
+		{func(f fs.File) fs.File { return f }, FileOptions{Strategy: FileStrategySeeker}, FileStrategySeeker},
+	}
+	for _, tc := range tests {
+		f := open()
+		rdr, strategy, err := BuildReaderWithAnyFileOptions(tc.wrap(f), tc.opts)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if strategy != tc.expected {
+			t.Fatalf("expected strategy %v: %v", tc.expected, strategy)
+		}
+		checkEmbedReader(t, rdr)
+		_ = f.Close()
+	}
+
+	// cannot seek and too large for memory
+	f := open()
+	defer f.Close()
+	if _, _, err := BuildReaderWithAnyFileOptions(readOnlyFile{f}, FileOptions{MaxMemorySize: 8}); err == nil {
+		t.Fatal("expected an error for a large file without ReadAt or Seek")
+	}
+	if _, _, err := BuildReaderWithAnyFileOptions(readOnlyFile{f}, FileOptions{Strategy: FileStrategyReaderAt}); err == nil {
+		t.Fatal("expected an error forcing ReadAt on a file without ReadAt")
+	}
+}
+
+func TestSeekerReaderAt(t *testing.T) {
+	rd := &seekerReaderAt{rd: bytes.NewReader([]byte("hello world"))}
+	p := make([]byte, 5)
+	if n, err := rd.ReadAt(p, 6); err != nil || string(p[:n]) != "world" {
+		t.Fatalf("unexpected read: %q %v", p[:n], err)
+	}
+	if n, err := rd.ReadAt(p, 8); err != io.EOF || string(p[:n]) != "rld" {
+		t.Fatalf("expected a short read at the end: %q %v", p[:n], err)
+	}
+}
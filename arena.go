@@ -0,0 +1,80 @@
+package kvfile
+
+import (
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrScanTooLarge is returned by ScanPrefixArena if the keys and values would
+// exceed the maximum size.
+var ErrScanTooLarge = errors.New("scan results too large")
+
+// ScanPrefixArena reads the key/value pairs with a prefix into a single buffer.
+//
+// Returns the keys and values in key order and the arena containing them: each
+// key and value is a subslice of the arena, so the arena is retained while any
+// key or value is in use. The subslices have their capacity limited to their
+// length, so appending to one reallocates it instead of overwriting the next.
+// Avoids an allocation per value when reading many small values.
+//
+// maxBytes limits the total size of the keys and values, zero for no limit.
+// The limit is checked with the value sizes in the index before reading the
+// values: returns an error wrapping ErrScanTooLarge without reading any value
+// if exceeded. The values are read with ReadAtBatch. Entries hidden by the
+// reader options are skipped.
+func (r *Reader) ScanPrefixArena(prefix []byte, maxBytes uint64) (keys, values [][]byte, arena []byte, err error) {
+	// find the values to read and the size of the arena
+	count, err := r.CountPrefix(prefix)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	keys = make([][]byte, 0, count)
+	ranges := make([]ReadRange, 0, count)
+	valueLens := make([]int64, 0, count)
+	var arenaSize, valueBytes uint64
+	if onScan := r.opts.Hooks.getOnScan(); onScan != nil {
+		start := time.Now()
+		defer func() {
+			onScan(len(values), int64(valueBytes), time.Since(start))
+		}()
+	}
+	err = r.scanPrefixEntries(prefix, func(indexEntry *IndexEntry, indexEntryIdx int) error {
+		valueIdx, valueLen, err := r.GetValuePositionWithEntry(indexEntry, indexEntryIdx)
+		if err != nil {
+			return err
+		}
+		valueBytes += uint64(valueLen)
+		arenaSize += uint64(len(indexEntry.GetKey())) + uint64(valueLen)
+		if maxBytes != 0 && arenaSize > maxBytes {
+			return errors.Wrapf(ErrScanTooLarge, "%v > %v bytes", arenaSize, maxBytes)
+		}
+		if arenaSize > math.MaxInt {
+			return errors.Wrapf(ErrScanTooLarge, "%v bytes", arenaSize)
+		}
+		keys = append(keys, indexEntry.GetKey())
+		ranges = append(ranges, ReadRange{Off: valueIdx})
+		valueLens = append(valueLens, valueLen)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// copy the keys to the arena and read the values into it
+	arena = make([]byte, arenaSize)
+	values = make([][]byte, len(keys))
+	var pos int
+	for i, key := range keys {
+		keyEnd := pos + copy(arena[pos:], key)
+		keys[i] = arena[pos:keyEnd:keyEnd]
+		pos = keyEnd + int(valueLens[i])
+		values[i] = arena[keyEnd:pos:pos]
+		ranges[i].Buf = values[i]
+	}
+	if err := ReadAtBatch(r.valueRd, ranges); err != nil {
+		return nil, nil, nil, err
+	}
+	return keys, values, arena, nil
+}
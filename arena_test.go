@@ -0,0 +1,108 @@
+package kvfile
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestScanPrefixArena(t *testing.T) {
+	n := 40
+	rdr, err := NewReaderFromBytes(writeScanReuseTestFile(t, n))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	keys, values, arena, err := rdr.ScanPrefixArena([]byte("key-"), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(keys) != n || len(values) != n {
+		t.Fatalf("expected %v entries but got %v keys and %v values", n, len(keys), len(values))
+	}
+	var arenaSize int
+	for i := range keys {
+		expected := bytes.Repeat([]byte{byte(i)}, scanReuseValueSize(i))
+		if string(keys[i]) != "key-"+strconv.Itoa(100000+i) || !bytes.Equal(values[i], expected) {
+			t.Fatalf("entry %v: unexpected key %q or value of %v bytes", i, keys[i], len(values[i]))
+		}
+		arenaSize += len(keys[i]) + len(values[i])
+	}
+	if len(arena) != arenaSize {
+		t.Fatalf("expected an arena of %v bytes but got %v", arenaSize, len(arena))
+	}
+
+	// appending to a key does not overwrite the value after it
+	_ = append(keys[2], 'x')
+	if !bytes.Equal(values[2], []byte{2, 2, 2}) {
+		t.Fatal("expected append to not overwrite the arena")
+	}
+
+	// the limit is checked before reading the values
+	if _, _, _, err := rdr.ScanPrefixArena([]byte("key-"), uint64(arenaSize-1)); !errors.Is(err, ErrScanTooLarge) {
+		t.Fatalf("expected ErrScanTooLarge but got %v", err)
+	}
+	keys, values, _, err = rdr.ScanPrefixArena([]byte("key-"), uint64(arenaSize))
+	if err != nil || len(keys) != n || len(values) != n {
+		t.Fatalf("expected the scan at the limit to succeed: %v", err)
+	}
+
+	keys, values, arena, err = rdr.ScanPrefixArena([]byte("missing"), 0)
+	if err != nil || len(keys) != 0 || len(values) != 0 || len(arena) != 0 {
+		t.Fatalf("expected no results for a missing prefix: %v", err)
+	}
+}
+
+// benchmarkScanCollect scans many small values and keeps the results.
+func benchmarkScanCollect(b *testing.B, arena bool) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	n := 100000
+	for i := 0; i < n; i++ {
+		key := []byte("key-" + strconv.Itoa(1000000+i))
+		if err := wr.WriteValue(key, bytes.NewReader([]byte("value-"+strconv.Itoa(i)))); err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		b.Fatal(err.Error())
+	}
+	rdr, err := NewReaderFromBytes(buf.Bytes())
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var values [][]byte
+		if arena {
+			_, values, _, err = rdr.ScanPrefixArena([]byte("key-"), 0)
+		} else {
+			err = rdr.ScanPrefix([]byte("key-"), func(key, value []byte) error {
+				values = append(values, value)
+				return nil
+			})
+		}
+		if err != nil || len(values) != n {
+			b.Fatalf("scan failed: %v %v", len(values), err)
+		}
+	}
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/float64(b.N), "gc-pause-ns/op")
+	b.ReportMetric(float64(after.NumGC-before.NumGC)/float64(b.N), "gcs/op")
+}
+
+func BenchmarkScanCollect(b *testing.B) {
+	benchmarkScanCollect(b, false)
+}
+
+func BenchmarkScanCollectArena(b *testing.B) {
+	benchmarkScanCollect(b, true)
+}
@@ -0,0 +1,118 @@
+package kvfile
+
+import (
+	"io"
+	"os"
+	"slices"
+	"time"
+)
+
+// ReadRange is a range of a ReaderAt to read into Buf.
+type ReadRange struct {
+	// Off is the offset to read from.
+	Off int64
+	// Buf is filled with the len(Buf) bytes at Off.
+	Buf []byte
+}
+
+// BatchReaderAt is a ReaderAt which can read many ranges in one call.
+type BatchReaderAt interface {
+	io.ReaderAt
+	// ReadAtBatch fills the buffer of each range.
+	//
+	// The ranges can be in any order and can overlap. Returns an error if any
+	// range cannot be read completely.
+	ReadAtBatch(ranges []ReadRange) error
+}
+
+// ReadAtBatch fills the buffer of each range from the ReaderAt.
+//
+// Uses ReadAtBatch if rd implements BatchReaderAt. On Linux, ranges read from
+// an *os.File are gathered into fewer preadv calls, reading the small gaps
+// between nearby ranges to a scratch buffer. Otherwise calls ReadAt for each
+// range. Returns io.ErrUnexpectedEOF if a range is past the end of the file.
+func ReadAtBatch(rd io.ReaderAt, ranges []ReadRange) error {
+	switch rd := rd.(type) {
+	case BatchReaderAt:
+		return rd.ReadAtBatch(ranges)
+	case *os.File:
+		return readFileBatch(rd, ranges)
+	default:
+		return readAtLoop(rd, ranges)
+	}
+}
+
+// readAtLoop calls ReadAt for each range.
+func readAtLoop(rd io.ReaderAt, ranges []ReadRange) error {
+	for _, rng := range ranges {
+		if len(rng.Buf) == 0 {
+			continue
+		}
+		n, err := rd.ReadAt(rng.Buf, rng.Off)
+		if n == len(rng.Buf) {
+			continue
+		}
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
+// sortReadRanges returns the non-empty ranges sorted by offset.
+func sortReadRanges(ranges []ReadRange) []ReadRange {
+	sorted := make([]ReadRange, 0, len(ranges))
+	for _, rng := range ranges {
+		if len(rng.Buf) != 0 {
+			sorted = append(sorted, rng)
+		}
+	}
+	slices.SortFunc(sorted, func(a, b ReadRange) int {
+		switch {
+		case a.Off < b.Off:
+			return -1
+		case a.Off > b.Off:
+			return 1
+		default:
+			return 0
+		}
+	})
+	return sorted
+}
+
+// GetMany looks up the values for the keys.
+//
+// Looks up the position of each value in the index, then reads the values
+// with ReadAtBatch. Returns the values and if each key was found. The value of
+// a key which was not found is nil. Keys can be repeated.
+//
+// The OnGet hook is called for each key with the duration of the batch.
+func (r *Reader) GetMany(keys [][]byte) ([][]byte, []bool, error) {
+	start := time.Now()
+	values := make([][]byte, len(keys))
+	found := make([]bool, len(keys))
+	ranges := make([]ReadRange, 0, len(keys))
+	for i, key := range keys {
+		valueIdx, valueLen, _, _, err := r.GetValuePosition(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if valueLen < 0 || valueIdx < 0 {
+			continue
+		}
+		found[i] = true
+		values[i] = make([]byte, valueLen)
+		ranges = append(ranges, ReadRange{Off: valueIdx, Buf: values[i]})
+	}
+	if err := ReadAtBatch(r.valueRd, ranges); err != nil {
+		return nil, nil, err
+	}
+	if onGet := r.opts.Hooks.getOnGet(); onGet != nil {
+		dur := time.Since(start)
+		for i, key := range keys {
+			onGet(key, found[i], int64(len(values[i])), dur)
+		}
+	}
+	return values, found, nil
+}
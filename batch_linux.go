@@ -0,0 +1,103 @@
+package kvfile
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxBatchGap is the maximum gap between two ranges read in one preadv call.
+const maxBatchGap = 4096
+
+// maxBatchIovecs is the maximum number of buffers in one preadv call.
+// Linux limits the number of buffers to IOV_MAX = 1024.
+const maxBatchIovecs = 1024
+
+// preadv is the preadv system call, replaced in tests.
+var preadv = unix.Preadv
+
+// readFileBatch reads the ranges from the file with preadv.
+//
+// The ranges are sorted by offset and nearby ranges are read in one call with
+// the gaps between them read to a scratch buffer. Overlapping ranges start a
+// new call.
+func readFileBatch(f *os.File, ranges []ReadRange) error {
+	sorted := sortReadRanges(ranges)
+	if len(sorted) == 0 {
+		return nil
+	}
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return readAtLoop(f, ranges)
+	}
+	var readErr error
+	err = rc.Read(func(fd uintptr) bool {
+		readErr = preadvRanges(int(fd), sorted)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return readErr
+}
+
+// preadvRanges reads the sorted ranges from the file descriptor.
+func preadvRanges(fd int, ranges []ReadRange) error {
+	var gap []byte
+	iovs := make([][]byte, 0, min(len(ranges)*2, maxBatchIovecs))
+	for len(ranges) != 0 {
+		off := ranges[0].Off
+		end := off
+		iovs = iovs[:0]
+		var i int
+		for ; i < len(ranges) && len(iovs)+2 <= maxBatchIovecs; i++ {
+			rng := ranges[i]
+			if rng.Off < end || rng.Off-end > maxBatchGap {
+				break
+			}
+			if gapLen := rng.Off - end; gapLen != 0 {
+				if gap == nil {
+					// the gaps can share the buffer as the contents are discarded
+					gap = make([]byte, maxBatchGap)
+				}
+				iovs = append(iovs, gap[:gapLen])
+			}
+			iovs = append(iovs, rng.Buf)
+			end = rng.Off + int64(len(rng.Buf))
+		}
+		if err := preadvFull(fd, iovs, off); err != nil {
+			return err
+		}
+		ranges = ranges[i:]
+	}
+	return nil
+}
+
+// preadvFull calls preadv until the buffers are filled.
+//
+// Modifies iovs to skip the data read by partial reads.
+func preadvFull(fd int, iovs [][]byte, off int64) error {
+	for len(iovs) != 0 {
+		n, err := preadv(fd, iovs, off)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return os.NewSyscallError("preadv", err)
+		}
+		if n == 0 {
+			return io.ErrUnexpectedEOF
+		}
+		off += int64(n)
+		for n != 0 {
+			if n < len(iovs[0]) {
+				iovs[0] = iovs[0][n:]
+				break
+			}
+			n -= len(iovs[0])
+			iovs = iovs[1:]
+		}
+	}
+	return nil
+}
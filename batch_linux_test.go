@@ -0,0 +1,150 @@
+package kvfile
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// countPreadv replaces preadv with a function counting the calls and limiting
+// each call to maxRead bytes if not zero.
+func countPreadv(t testing.TB, maxRead int) *int {
+	var calls int
+	orig := preadv
+	preadv = func(fd int, iovs [][]byte, off int64) (int, error) {
+		calls++
+		if maxRead != 0 {
+			// partial read: truncate the buffers to maxRead bytes
+			var limited [][]byte
+			remaining := maxRead
+			for _, iov := range iovs {
+				if remaining == 0 {
+					break
+				}
+				iov = iov[:min(len(iov), remaining)]
+				remaining -= len(iov)
+				limited = append(limited, iov)
+			}
+			iovs = limited
+		}
+		return orig(fd, iovs, off)
+	}
+	t.Cleanup(func() {
+		preadv = orig
+	})
+	return &calls
+}
+
+func TestGetManyPreadv(t *testing.T) {
+	path, keys := writeBatchTestFile(t, 10000)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer f.Close()
+	rdr, err := BuildReaderWithFile(f)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	calls := countPreadv(t, 0)
+	values, found, err := rdr.GetMany(keys)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for i := range keys {
+		if !found[i] || !bytes.Equal(values[i], bytes.Repeat([]byte{byte(i)}, i%17)) {
+			t.Fatalf("unexpected value for %s: %v %v", keys[i], found[i], values[i])
+		}
+	}
+	// the values are contiguous: each call reads up to 1024 buffers
+	if *calls == 0 || *calls > len(keys)/500 {
+		t.Fatalf("expected fewer preadv calls for %d values: %d", len(keys), *calls)
+	}
+
+	// partial reads and interleaved offsets
+	countPreadv(t, 7)
+	checkGetMany(t, rdr, keys)
+}
+
+func TestPreadvRanges(t *testing.T) {
+	data := make([]byte, maxBatchGap*4)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	path := t.TempDir() + "/data"
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer f.Close()
+
+	// a small gap, a gap larger than maxBatchGap, and overlapping ranges
+	ranges := []ReadRange{
+		{Off: 10, Buf: make([]byte, 5)},
+		{Off: 100, Buf: make([]byte, 5)},
+		{Off: 102, Buf: make([]byte, 5)},
+		{Off: maxBatchGap * 3, Buf: make([]byte, maxBatchGap)},
+		{Off: 0, Buf: make([]byte, 1)},
+	}
+	calls := countPreadv(t, 0)
+	if err := ReadAtBatch(f, ranges); err != nil {
+		t.Fatal(err.Error())
+	}
+	for i, rng := range ranges {
+		if !bytes.Equal(rng.Buf, data[rng.Off:rng.Off+int64(len(rng.Buf))]) {
+			t.Fatalf("unexpected data for range %d", i)
+		}
+	}
+	if *calls != 3 {
+		t.Fatalf("expected 3 preadv calls: %d", *calls)
+	}
+
+	// past the end of the file
+	err = ReadAtBatch(f, []ReadRange{{Off: int64(len(data)) - 1, Buf: make([]byte, 2)}})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected an error reading past the end: %v", err)
+	}
+}
+
+func BenchmarkGetMany(b *testing.B) {
+	const count = 10000
+	path, keys := writeBatchTestFile(b, count)
+	f, err := os.Open(path)
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+	defer f.Close()
+	rdr, err := BuildReaderWithFile(f)
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+
+	b.Run("Get", func(b *testing.B) {
+		for range b.N {
+			for _, key := range keys {
+				if _, _, err := rdr.Get(key); err != nil {
+					b.Fatal(err.Error())
+				}
+			}
+		}
+		// one pread for each value
+		b.ReportMetric(count, "value-syscalls/op")
+	})
+
+	b.Run("GetMany", func(b *testing.B) {
+		calls := countPreadv(b, 0)
+		for range b.N {
+			if _, _, err := rdr.GetMany(keys); err != nil {
+				b.Fatal(err.Error())
+			}
+		}
+		b.ReportMetric(float64(*calls)/float64(b.N), "value-syscalls/op")
+	})
+}
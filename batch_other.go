@@ -0,0 +1,10 @@
+//go:build !linux
+
+package kvfile
+
+import "os"
+
+// readFileBatch calls ReadAt for each range.
+func readFileBatch(f *os.File, ranges []ReadRange) error {
+	return readAtLoop(f, ranges)
+}
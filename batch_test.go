@@ -0,0 +1,131 @@
+package kvfile
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// writeBatchTestFile writes count keys with small values to a file.
+func writeBatchTestFile(t testing.TB, count int) (string, [][]byte) {
+	keys := make([][]byte, count)
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	for i := range keys {
+		keys[i] = []byte("key-" + strconv.Itoa(100000+i))
+		value := bytes.Repeat([]byte{byte(i)}, i%17)
+		if err := wr.WriteValue(keys[i], bytes.NewReader(value)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	path := filepath.Join(t.TempDir(), "batch.kvf")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	return path, keys
+}
+
+// checkGetMany checks GetMany returns the values from writeBatchTestFile.
+func checkGetMany(t *testing.T, rdr *Reader, keys [][]byte) {
+	t.Helper()
+	// interleaved offsets, repeated keys, and missing keys
+	query := [][]byte{[]byte("missing")}
+	for i := len(keys) - 1; i >= 0; i -= 3 {
+		query = append(query, keys[i], keys[len(keys)-1-i])
+	}
+	query = append(query, keys[0], []byte("zzz"))
+	values, found, err := rdr.GetMany(query)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for i, key := range query {
+		expected, expectedFound, err := rdr.Get(key)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if found[i] != expectedFound || !bytes.Equal(values[i], expected) {
+			t.Fatalf("unexpected value for %s: %v %q", key, found[i], values[i])
+		}
+		if expectedFound && values[i] == nil {
+			t.Fatalf("expected an empty value for %s", key)
+		}
+	}
+}
+
+func TestGetMany(t *testing.T) {
+	path, keys := writeBatchTestFile(t, 500)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := BuildReader(bytes.NewReader(data), uint64(len(data)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	checkGetMany(t, rdr, keys)
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer f.Close()
+	fileRdr, err := BuildReaderWithFile(f)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	checkGetMany(t, fileRdr, keys)
+}
+
+// batchReaderAt counts the calls to ReadAtBatch.
+type batchReaderAt struct {
+	io.ReaderAt
+	calls int
+}
+
+func (b *batchReaderAt) ReadAtBatch(ranges []ReadRange) error {
+	b.calls++
+	return readAtLoop(b.ReaderAt, ranges)
+}
+
+func TestReadAtBatch(t *testing.T) {
+	data := []byte("0123456789")
+	ranges := []ReadRange{
+		{Off: 8, Buf: make([]byte, 2)},
+		{Off: 0, Buf: make([]byte, 3)},
+		{Off: 1, Buf: make([]byte, 3)},
+		{Off: 10, Buf: nil},
+	}
+	batch := &batchReaderAt{ReaderAt: bytes.NewReader(data)}
+	if err := ReadAtBatch(batch, ranges); err != nil || batch.calls != 1 {
+		t.Fatalf("expected one batch read: %d %v", batch.calls, err)
+	}
+	for i, expected := range []string{"89", "012", "123", ""} {
+		if string(ranges[i].Buf) != expected {
+			t.Fatalf("unexpected range %d: %q", i, ranges[i].Buf)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer f.Close()
+	for _, rd := range []io.ReaderAt{bytes.NewReader(data), f} {
+		err := ReadAtBatch(rd, []ReadRange{{Off: 0, Buf: make([]byte, 2)}, {Off: 9, Buf: make([]byte, 2)}})
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected unexpected EOF reading past the end: %v", err)
+		}
+	}
+}
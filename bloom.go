@@ -0,0 +1,113 @@
+package kvfile
+
+import (
+	"hash/maphash"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// maxBloomBitsPerKey is the maximum bits per key for BuildBloomFilter.
+const maxBloomBitsPerKey = 64
+
+// bloomFilter is an in-memory bloom filter of the keys in a file.
+//
+// Immutable after it is built.
+type bloomFilter struct {
+	// seed is the seed for the key hash
+	seed maphash.Seed
+	// bits contains the filter bits
+	bits []uint64
+	// hashes is the number of bits set for each key
+	hashes uint32
+}
+
+// newBloomFilter builds an empty bloom filter sized for the number of keys.
+func newBloomFilter(keys uint64, bitsPerKey int) *bloomFilter {
+	// the optimal number of hashes is bitsPerKey * ln(2)
+	hashes := uint32(math.Round(float64(bitsPerKey) * math.Ln2))
+	hashes = max(hashes, 1)
+	words := max((keys*uint64(bitsPerKey)+63)/64, 1)
+	return &bloomFilter{seed: maphash.MakeSeed(), bits: make([]uint64, words), hashes: hashes}
+}
+
+// add adds the key to the filter.
+func (f *bloomFilter) add(key []byte) {
+	h1, h2, nbits := f.hash(key)
+	for i := uint32(0); i < f.hashes; i++ {
+		bit := h1 % nbits
+		f.bits[bit/64] |= 1 << (bit % 64)
+		h1 += h2
+	}
+}
+
+// mayContain checks if the key may be in the filter.
+//
+// Returns false only if the key was not added.
+func (f *bloomFilter) mayContain(key []byte) bool {
+	h1, h2, nbits := f.hash(key)
+	for i := uint32(0); i < f.hashes; i++ {
+		bit := h1 % nbits
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+		h1 += h2
+	}
+	return true
+}
+
+// hash returns the two hashes for double hashing and the number of bits.
+func (f *bloomFilter) hash(key []byte) (h1, h2, nbits uint64) {
+	h := maphash.Bytes(f.seed, key)
+	// the second hash is odd so the probes do not repeat early
+	return h, (h>>32 | h<<32) | 1, uint64(len(f.bits)) * 64
+}
+
+// BuildBloomFilter reads the keys in the file and builds an in-memory bloom
+// filter checked by lookups to skip searching the index for missing keys.
+//
+// bitsPerKey is the number of filter bits per key, between 1 and 64: 10 bits
+// per key has a false positive rate of about 1%. The filter has no false
+// negatives. Replaces the existing filter if any. Lookups during the build do
+// not use the filter. The filter is not stored in the file: building a new
+// Reader for the file has no filter.
+func (r *Reader) BuildBloomFilter(bitsPerKey int) error {
+	if bitsPerKey < 1 || bitsPerKey > maxBloomBitsPerKey {
+		return errors.Errorf("invalid bloom filter bits per key: %v", bitsPerKey)
+	}
+	filter := newBloomFilter(r.indexEntryCount, bitsPerKey)
+	// hidden entries are included: the LayeredReader looks up tombstones
+	for i := uint64(0); i < r.indexEntryCount; i++ {
+		indexEntry, err := r.ReadIndexEntry(i)
+		if err != nil {
+			return err
+		}
+		filter.add(indexEntry.GetKey())
+	}
+	r.bloom.Store(filter)
+	return nil
+}
+
+// ClearBloomFilter removes the bloom filter built by BuildBloomFilter.
+func (r *Reader) ClearBloomFilter() {
+	r.bloom.Store(nil)
+}
+
+// BloomFilterSize returns the memory used by the bloom filter in bytes.
+//
+// Returns 0 if there is no bloom filter.
+func (r *Reader) BloomFilterSize() int {
+	filter := r.bloom.Load()
+	if filter == nil {
+		return 0
+	}
+	return len(filter.bits) * 8
+}
+
+// bloomMayContain checks the bloom filter for the key.
+//
+// Returns true if there is no bloom filter.
+func (r *Reader) bloomMayContain(key []byte) bool {
+	filter := r.bloom.Load()
+	return filter == nil || filter.mayContain(key)
+}
@@ -0,0 +1,143 @@
+package kvfile
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestBloomFilter(t *testing.T) {
+	n := 1000
+	data := writeHashIndexTestFile(t, n, false)
+	counter := &countingReaderAt{rd: bytes.NewReader(data)}
+	rdr, err := BuildReader(counter, uint64(len(data)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := rdr.BuildBloomFilter(0); err == nil {
+		t.Fatal("expected an error with zero bits per key")
+	}
+	if size := rdr.BloomFilterSize(); size != 0 {
+		t.Fatalf("expected no bloom filter but got %v bytes", size)
+	}
+	if err := rdr.BuildBloomFilter(10); err != nil {
+		t.Fatal(err.Error())
+	}
+	if size := rdr.BloomFilterSize(); size != (n*10+63)/64*8 {
+		t.Fatalf("unexpected bloom filter size: %v", size)
+	}
+
+	// no false negatives
+	getAllKeys(t, rdr, n)
+
+	// most misses do not read the file
+	counter.reads.Store(0)
+	var falsePositives int
+	for i := 0; i < n; i++ {
+		key := []byte("miss-" + strconv.Itoa(i))
+		if rdr.bloom.Load().mayContain(key) {
+			falsePositives++
+		}
+		found, err := rdr.Exists(key)
+		if err != nil || found {
+			t.Fatalf("unexpected result for a missing key: %v %v", found, err)
+		}
+	}
+	if falsePositives > n/20 {
+		t.Fatalf("too many false positives: %v of %v", falsePositives, n)
+	}
+	// only the false positives search the index
+	if reads := counter.reads.Load(); reads > int64(falsePositives)*64 {
+		t.Fatalf("expected few reads for %v false positives but got %v", falsePositives, reads)
+	}
+
+	rdr.ClearBloomFilter()
+	if size := rdr.BloomFilterSize(); size != 0 {
+		t.Fatalf("expected the bloom filter to be cleared: %v bytes", size)
+	}
+	getAllKeys(t, rdr, n)
+}
+
+func TestBloomFilterConcurrent(t *testing.T) {
+	n := 200
+	data := writeHashIndexTestFile(t, n, false)
+	rdr, err := NewReaderFromBytes(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				if found, err := rdr.Exists([]byte("key-" + strconv.Itoa(i))); err != nil || !found {
+					t.Errorf("expected key %v to exist: %v %v", i, found, err)
+					return
+				}
+			}
+		}()
+	}
+	if err := rdr.BuildBloomFilter(8); err != nil {
+		t.Fatal(err.Error())
+	}
+	wg.Wait()
+}
+
+func TestBloomFilterTombstones(t *testing.T) {
+	base := buildLayer(t, map[string]string{"test-1": "base-1", "test-2": "base-2"}, nil, ReaderOptions{})
+	top := buildLayer(t, map[string]string{"test-3": "top-3"}, []string{"test-1"}, ReaderOptions{})
+	for _, layer := range []*Reader{base, top} {
+		if err := layer.BuildBloomFilter(10); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	// the tombstone in the top layer hides the key in the base
+	rdr := NewLayeredReader(base, top)
+	if found, err := rdr.Exists([]byte("test-1")); err != nil || found {
+		t.Fatalf("expected the deleted key to not exist: %v %v", found, err)
+	}
+	for _, key := range []string{"test-2", "test-3"} {
+		if found, err := rdr.Exists([]byte(key)); err != nil || !found {
+			t.Fatalf("expected %v to exist: %v %v", key, found, err)
+		}
+	}
+}
+
+func benchmarkGetMiss(b *testing.B, bitsPerKey int) {
+	n := 100000
+	data := writeHashIndexTestFile(b, n, false)
+	counter := &countingReaderAt{rd: bytes.NewReader(data)}
+	rdr, err := BuildReader(counter, uint64(len(data)))
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+	if bitsPerKey != 0 {
+		if err := rdr.BuildBloomFilter(bitsPerKey); err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = []byte("miss-" + strconv.Itoa(i))
+	}
+	counter.reads.Store(0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, found, err := rdr.Get(keys[i%n])
+		if err != nil || found {
+			b.Fatalf("get failed: %v %v", found, err)
+		}
+	}
+	b.ReportMetric(float64(counter.reads.Load())/float64(b.N), "readat/op")
+}
+
+func BenchmarkGetMiss(b *testing.B) {
+	benchmarkGetMiss(b, 0)
+}
+
+func BenchmarkGetMissBloomFilter(b *testing.B) {
+	benchmarkGetMiss(b, 10)
+}
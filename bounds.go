@@ -0,0 +1,82 @@
+package kvfile
+
+import (
+	"bytes"
+
+	protobuf_go_lite "github.com/aperturerobotics/protobuf-go-lite"
+	"github.com/pkg/errors"
+)
+
+// Bounds returns the smallest and largest keys in the file.
+//
+// If the file has the bounds footer block (see WriterOptions.WriteBounds)
+// returns the keys without reading the index. Otherwise reads the first and
+// last index entries. Entries hidden by the reader options are not excluded.
+//
+// Returns nil, nil, false, nil if the file is empty.
+func (r *Reader) Bounds() (minKey, maxKey []byte, ok bool, err error) {
+	if block, found := r.findFooterBlock(FooterBlockBounds); found {
+		data, err := r.readFooterBlockData(block)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		minKey, maxKey, err = parseBoundsBlock(data, r.indexEntrySizeLimit())
+		if err != nil {
+			return nil, nil, false, err
+		}
+		return minKey, maxKey, true, nil
+	}
+
+	size := r.Size()
+	if size == 0 {
+		return nil, nil, false, nil
+	}
+	first, err := r.ReadIndexEntry(0)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	last, err := r.ReadIndexEntry(size - 1)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return first.GetKey(), last.GetKey(), true, nil
+}
+
+// buildBoundsBlock builds the bounds footer block for the index.
+//
+// The block contains the length-prefixed min and max keys.
+func buildBoundsBlock(index []*IndexEntry) *footerBlockData {
+	var minKey, maxKey []byte
+	for i, indexEntry := range index {
+		key := indexEntry.GetKey()
+		if i == 0 || bytes.Compare(key, minKey) < 0 {
+			minKey = key
+		}
+		if i == 0 || bytes.Compare(key, maxKey) > 0 {
+			maxKey = key
+		}
+	}
+	data := make([]byte, 0, len(minKey)+len(maxKey)+4)
+	data = protobuf_go_lite.AppendVarint(data, uint64(len(minKey)))
+	data = append(data, minKey...)
+	data = protobuf_go_lite.AppendVarint(data, uint64(len(maxKey)))
+	data = append(data, maxKey...)
+	return &footerBlockData{typ: FooterBlockBounds, data: data}
+}
+
+// parseBoundsBlock parses the bounds footer block.
+//
+// Keys longer than maxKeySize are rejected.
+func parseBoundsBlock(data []byte, maxKeySize uint64) (minKey, maxKey []byte, err error) {
+	var keys [2][]byte
+	for i := range keys {
+		keyLen, n := protobuf_go_lite.ConsumeVarint(data)
+		if n < 0 || keyLen > uint64(len(data)-n) || keyLen > maxKeySize {
+			return nil, nil, errors.New("invalid bounds footer block")
+		}
+		data = data[n:]
+		keys[i] = data[:keyLen]
+		data = data[keyLen:]
+	}
+	return keys[0], keys[1], nil
+}
@@ -0,0 +1,117 @@
+package kvfile
+
+import (
+	"bytes"
+	"io"
+	"sync/atomic"
+	"testing"
+)
+
+// countingReaderAt counts the calls to ReadAt.
+type countingReaderAt struct {
+	rd    io.ReaderAt
+	reads atomic.Int64
+	bytes atomic.Int64
+}
+
+// ReadAt reads from the underlying reader and counts the call.
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.reads.Add(1)
+	n, err := c.rd.ReadAt(p, off)
+	c.bytes.Add(int64(n))
+	return n, err
+}
+
+func TestBounds(t *testing.T) {
+	longKey := append([]byte("z-"), bytes.Repeat([]byte("k"), 2000)...)
+	keys := [][]byte{
+		[]byte("test-2"),
+		longKey,
+		[]byte("test-1"),
+		[]byte("a-test"),
+	}
+
+	for _, writeBounds := range []bool{true, false} {
+		var buf bytes.Buffer
+		wr, err := NewWriterWithOptions(&buf, WriterOptions{WriteBounds: writeBounds})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		for _, key := range keys {
+			if err := wr.WriteValue(key, bytes.NewReader([]byte("value"))); err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+		if err := wr.Close(); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		counter := &countingReaderAt{rd: bytes.NewReader(buf.Bytes())}
+		rdr, err := BuildReader(counter, uint64(buf.Len()))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		counter.reads.Store(0)
+		minKey, maxKey, ok, err := rdr.Bounds()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !ok || string(minKey) != "a-test" || !bytes.Equal(maxKey, longKey) {
+			t.Fatalf("unexpected bounds: %v %s %s", ok, string(minKey), string(maxKey))
+		}
+		if reads := counter.reads.Load(); writeBounds && reads != 1 {
+			t.Fatalf("expected bounds to be read from the footer with one read: %v", reads)
+		} else if !writeBounds && reads < 2 {
+			t.Fatalf("expected bounds to be read from the index: %v", reads)
+		}
+
+		// the rest of the file is unaffected
+		val, found, err := rdr.Get(longKey)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !found || string(val) != "value" {
+			t.Fatalf("unexpected value: %v %s", found, string(val))
+		}
+	}
+}
+
+func TestBoundsEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	wr, err := NewWriterWithOptions(&buf, WriterOptions{WriteBounds: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	minKey, maxKey, ok, err := rdr.Bounds()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if ok || minKey != nil || maxKey != nil {
+		t.Fatalf("expected no bounds for empty file: %v", ok)
+	}
+}
+
+func TestParseBoundsBlockLimit(t *testing.T) {
+	minKey := []byte("a")
+	maxKey := bytes.Repeat([]byte("z"), 100)
+	block := buildBoundsBlock([]*IndexEntry{{Key: minKey}, {Key: maxKey}})
+
+	if _, _, err := parseBoundsBlock(block.data, 99); err == nil {
+		t.Fatal("expected key size limit error")
+	}
+	gotMin, gotMax, err := parseBoundsBlock(block.data, 100)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(gotMin, minKey) || !bytes.Equal(gotMax, maxKey) {
+		t.Fatalf("unexpected bounds: %s %s", string(gotMin), string(gotMax))
+	}
+}
@@ -0,0 +1,190 @@
+package kvfile
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// CachingReaderAt is an io.ReaderAt caching block-aligned reads from an
+// underlying ReaderAt with LRU eviction.
+//
+// Wrap a high-latency ReaderAt (HTTP, S3, FUSE) before BuildReader to avoid
+// repeated reads of the index. A read spanning multiple blocks reads the
+// missing blocks with at most one read from the underlying ReaderAt. The
+// underlying data must not change. Concurrency safe.
+type CachingReaderAt struct {
+	// rd is the underlying reader
+	rd io.ReaderAt
+	// blockSize is the size of each block
+	blockSize int64
+	// maxBlocks is the maximum number of cached blocks
+	maxBlocks int
+
+	// hits is the number of blocks read from the cache
+	hits atomic.Uint64
+	// misses is the number of blocks read from the underlying reader
+	misses atomic.Uint64
+
+	mtx sync.Mutex
+	// blocks maps the block index to the element in lru
+	blocks map[int64]*list.Element
+	// lru contains the cached blocks, most recently used first
+	lru *list.List
+}
+
+// cachedBlock is a block in the CachingReaderAt.
+type cachedBlock struct {
+	idx  int64
+	data []byte
+}
+
+// CacheStats are the counters of a CachingReaderAt.
+type CacheStats struct {
+	// Hits is the number of blocks read from the cache.
+	Hits uint64
+	// Misses is the number of blocks read from the underlying ReaderAt.
+	Misses uint64
+	// Blocks is the number of cached blocks.
+	Blocks int
+}
+
+// NewCachingReaderAt constructs a new CachingReaderAt.
+//
+// The cache holds up to maxBlocks blocks of blockSize bytes. If blockSize is
+// zero or less uses 4096. If maxBlocks is zero or less uses 1024.
+func NewCachingReaderAt(rd io.ReaderAt, blockSize int, maxBlocks int) *CachingReaderAt {
+	if blockSize <= 0 {
+		blockSize = 4096
+	}
+	if maxBlocks <= 0 {
+		maxBlocks = 1024
+	}
+	return &CachingReaderAt{
+		rd:        rd,
+		blockSize: int64(blockSize),
+		maxBlocks: maxBlocks,
+		blocks:    make(map[int64]*list.Element),
+		lru:       list.New(),
+	}
+}
+
+// Stats returns the cache counters.
+func (c *CachingReaderAt) Stats() CacheStats {
+	c.mtx.Lock()
+	blocks := c.lru.Len()
+	c.mtx.Unlock()
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load(), Blocks: blocks}
+}
+
+// ReadAt reads len(p) bytes starting at off.
+func (c *CachingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.Errorf("negative offset: %v", off)
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	first := off / c.blockSize
+	last := (off + int64(len(p)) - 1) / c.blockSize
+	blocks := make([][]byte, last-first+1)
+	missFirst, missLast := int64(-1), int64(-1)
+	var hits, misses uint64
+
+	c.mtx.Lock()
+	for idx := first; idx <= last; idx++ {
+		elem, ok := c.blocks[idx]
+		if !ok {
+			if missFirst < 0 {
+				missFirst = idx
+			}
+			missLast = idx
+			misses++
+			continue
+		}
+		c.lru.MoveToFront(elem)
+		blocks[idx-first] = elem.Value.(*cachedBlock).data
+		hits++
+	}
+	c.mtx.Unlock()
+	c.hits.Add(hits)
+	c.misses.Add(misses)
+
+	if missFirst >= 0 {
+		if err := c.readBlocks(missFirst, missLast, blocks[missFirst-first:missLast-first+1]); err != nil {
+			return 0, err
+		}
+	}
+
+	// copy from the blocks
+	var nr int
+	for i, data := range blocks {
+		blockOff := int64(0)
+		if i == 0 {
+			blockOff = off - first*c.blockSize
+		}
+		if blockOff >= int64(len(data)) {
+			break
+		}
+		nr += copy(p[nr:], data[blockOff:])
+		if int64(len(data)) < c.blockSize {
+			// end of file
+			break
+		}
+	}
+	if nr < len(p) {
+		return nr, io.EOF
+	}
+	return nr, nil
+}
+
+// readBlocks reads the blocks from first to last with a single read, filling
+// in the blocks which were not cached and caching them.
+//
+// Each block is copied so evicting a block releases its memory.
+func (c *CachingReaderAt) readBlocks(first, last int64, blocks [][]byte) error {
+	buf := make([]byte, (last-first+1)*c.blockSize)
+	n, err := c.rd.ReadAt(buf, first*c.blockSize)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	buf = buf[:n]
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for i := range blocks {
+		start := int64(i) * c.blockSize
+		if start >= int64(len(buf)) {
+			break
+		}
+		end := min(start+c.blockSize, int64(len(buf)))
+		if blocks[i] != nil {
+			// already cached: keep the cached block
+			continue
+		}
+		data := bytes.Clone(buf[start:end])
+		blocks[i] = data
+		c.addBlockLocked(first+int64(i), data)
+	}
+	return nil
+}
+
+// addBlockLocked adds a block to the cache, evicting the least recently used.
+func (c *CachingReaderAt) addBlockLocked(idx int64, data []byte) {
+	if _, ok := c.blocks[idx]; ok {
+		return
+	}
+	c.blocks[idx] = c.lru.PushFront(&cachedBlock{idx: idx, data: data})
+	for c.lru.Len() > c.maxBlocks {
+		evicted := c.lru.Remove(c.lru.Back()).(*cachedBlock)
+		delete(c.blocks, evicted.idx)
+	}
+}
+
+// _ is a type assertion
+var _ io.ReaderAt = ((*CachingReaderAt)(nil))
@@ -0,0 +1,117 @@
+package kvfile
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCachingReaderAtGets(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	var keys []string
+	for i := range 100 {
+		key := "key-" + strconv.Itoa(1000+i)
+		keys = append(keys, key)
+		if err := wr.WriteValue([]byte(key), bytes.NewReader([]byte("val-"+key))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	counting := &countingReaderAt{rd: bytes.NewReader(buf.Bytes())}
+	cache := NewCachingReaderAt(counting, 512, 1024)
+	rdr, err := BuildReader(cache, uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	getAll := func() {
+		for _, key := range keys {
+			val, found, err := rdr.Get([]byte(key))
+			if err != nil || !found || string(val) != "val-"+key {
+				t.Fatalf("unexpected value for %s: %q %v %v", key, val, found, err)
+			}
+		}
+	}
+	getAll()
+	reads := counting.reads.Load()
+	if maxReads := int64(buf.Len()/512 + 1); reads > maxReads {
+		t.Fatalf("expected at most one read per block: %d > %d", reads, maxReads)
+	}
+	getAll()
+	if counting.reads.Load() != reads {
+		t.Fatalf("expected repeated gets to be cached: %d != %d", counting.reads.Load(), reads)
+	}
+	if stats := cache.Stats(); stats.Hits == 0 || stats.Misses < uint64(reads) {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestCachingReaderAt(t *testing.T) {
+	data := make([]byte, 10000)
+	rnd := rand.New(rand.NewPCG(1, 2))
+	for i := range data {
+		data[i] = byte(rnd.IntN(256))
+	}
+	src := bytes.NewReader(data)
+
+	// reads match the underlying reader including at the end of the file
+	for _, blockSize := range []int{1, 7, 64, 4096, 20000} {
+		cache := NewCachingReaderAt(src, blockSize, 16)
+		for range 200 {
+			off := rnd.Int64N(int64(len(data)) + 10)
+			p := make([]byte, rnd.IntN(300))
+			expected := make([]byte, len(p))
+			en, eerr := src.ReadAt(expected, off)
+			n, err := cache.ReadAt(p, off)
+			if n != en || (err == nil) != (eerr == nil) || !bytes.Equal(p[:n], expected[:en]) {
+				t.Fatalf("block size %d: read %d at %d: %d %v != %d %v", blockSize, len(p), off, n, err, en, eerr)
+			}
+		}
+		if stats := cache.Stats(); stats.Blocks > 16 {
+			t.Fatalf("expected at most 16 cached blocks: %d", stats.Blocks)
+		}
+	}
+
+	// a read spanning cached and missing blocks reads the missing span once
+	counting := &countingReaderAt{rd: src}
+	cache := NewCachingReaderAt(counting, 100, 100)
+	p := make([]byte, 100)
+	if _, err := cache.ReadAt(p, 200); err != nil {
+		t.Fatal(err.Error())
+	}
+	p = make([]byte, 500)
+	if _, err := cache.ReadAt(p, 50); err != nil {
+		t.Fatal(err.Error())
+	}
+	if reads := counting.reads.Load(); reads != 2 || !bytes.Equal(p, data[50:550]) {
+		t.Fatalf("expected a single read for the missing span: %d", reads)
+	}
+	if _, err := cache.ReadAt(p, 50); err != nil || counting.reads.Load() != 2 {
+		t.Fatalf("expected the span to be cached: %v %d", err, counting.reads.Load())
+	}
+
+	// concurrent reads
+	cache = NewCachingReaderAt(src, 64, 8)
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rnd := rand.New(rand.NewPCG(uint64(i), 0))
+			p := make([]byte, 200)
+			for range 200 {
+				off := rnd.Int64N(int64(len(data) - len(p)))
+				if n, err := cache.ReadAt(p, off); err != nil || !bytes.Equal(p[:n], data[off:off+int64(n)]) {
+					t.Errorf("unexpected concurrent read at %d: %v", off, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
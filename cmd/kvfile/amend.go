@@ -0,0 +1,171 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aperturerobotics/go-kvfile"
+	kvfile_compress "github.com/aperturerobotics/go-kvfile/compress"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// setCommand builds the set command.
+func (k *kvfileCli) setCommand() *cli.Command {
+	var keyIn keyInput
+	var value, valuePath, outputPath string
+	var inPlace bool
+	return &cli.Command{
+		Name:  "set",
+		Usage: "Rewrite a kvfile with the value for a key added or replaced.",
+		Flags: append(keyIn.flags("the key to set"),
+			&cli.StringFlag{
+				Name:        "value",
+				Usage:       "the value to set",
+				Destination: &value,
+			},
+			&cli.StringFlag{
+				Name:        "value-file",
+				Usage:       "path to a file with the value to set",
+				Destination: &valuePath,
+			},
+			&cli.StringFlag{
+				Name:        "output",
+				Usage:       "path to write the new kvfile to",
+				Destination: &outputPath,
+			},
+			&cli.BoolFlag{
+				Name:        "in-place",
+				Usage:       "replace the kvfile",
+				Destination: &inPlace,
+			},
+		),
+		Action: func(c *cli.Context) error {
+			key, err := k.parseKeyInput(&keyIn)
+			if err != nil {
+				return err
+			}
+			if c.IsSet("value") == (valuePath != "") {
+				return errors.New("please provide one of --value or --value-file")
+			}
+			valueData := []byte(value)
+			if valuePath != "" {
+				valueData, err = os.ReadFile(valuePath)
+				if err != nil {
+					return errors.Wrap(err, "read value")
+				}
+			}
+			return k.amendFile(outputPath, inPlace, map[string][]byte{string(key): valueData}, nil, false)
+		},
+	}
+}
+
+// deleteCommand builds the delete command.
+func (k *kvfileCli) deleteCommand() *cli.Command {
+	var keyIn keyInput
+	var outputPath string
+	var inPlace, strict bool
+	return &cli.Command{
+		Name:  "delete",
+		Usage: "Rewrite a kvfile without a key.",
+		Flags: append(keyIn.flags("the key to delete"),
+			&cli.StringFlag{
+				Name:        "output",
+				Usage:       "path to write the new kvfile to",
+				Destination: &outputPath,
+			},
+			&cli.BoolFlag{
+				Name:        "in-place",
+				Usage:       "replace the kvfile",
+				Destination: &inPlace,
+			},
+			&cli.BoolFlag{
+				Name:        "strict",
+				Usage:       "return an error if the key does not exist",
+				Destination: &strict,
+			},
+		),
+		Action: func(c *cli.Context) error {
+			key, err := k.parseKeyInput(&keyIn)
+			if err != nil {
+				return err
+			}
+			return k.amendFile(outputPath, inPlace, nil, [][]byte{key}, strict)
+		},
+	}
+}
+
+// amendFile writes the kvfile with the changes applied to the output path or
+// replaces the kvfile if inPlace is set.
+//
+// The new kvfile is compressed if the kvfile is compressed. If strict is set,
+// returns an error if a key to remove does not exist.
+func (k *kvfileCli) amendFile(outputPath string, inPlace bool, set map[string][]byte, remove [][]byte, strict bool) error {
+	return k.rewriteFile(outputPath, inPlace, func(reader *kvfile.Reader) error {
+		if !strict {
+			return nil
+		}
+		for _, key := range remove {
+			found, err := reader.Exists(key)
+			if err != nil {
+				return err
+			}
+			if !found {
+				return errors.Errorf("Key %q not found.", formatData(key, k.keyEncoding))
+			}
+		}
+		return nil
+	}, func(out io.Writer, reader *kvfile.Reader) error {
+		return kvfile.Amend(out, reader, set, remove)
+	})
+}
+
+// rewriteFile writes a new kvfile from the kvfile at the file path to the
+// output path, or replaces the file if inPlace is set.
+//
+// check is called with the reader before the output file is created. The
+// output is compressed if the input is compressed.
+func (k *kvfileCli) rewriteFile(outputPath string, inPlace bool, check func(reader *kvfile.Reader) error, write func(out io.Writer, reader *kvfile.Reader) error) error {
+	if k.filePath == "" {
+		return errors.New("please provide a file path")
+	}
+	if inPlace == (outputPath != "") {
+		return errors.New("please provide one of --output or --in-place")
+	}
+	writeOutputFile := createOutputFile
+	if inPlace {
+		outputPath, writeOutputFile = k.filePath, replaceOutputFile
+	} else {
+		inPath, err := filepath.Abs(k.filePath)
+		if err != nil {
+			return err
+		}
+		outPath, err := filepath.Abs(outputPath)
+		if err != nil {
+			return err
+		}
+		if inPath == outPath {
+			return errors.New("output file is the input file: use --in-place to replace it")
+		}
+	}
+
+	reader, rel, compressed, err := k.openKVFilePath(k.filePath, k.compressed)
+	if err != nil {
+		return err
+	}
+	defer rel()
+	if err := check(reader); err != nil {
+		return err
+	}
+
+	var compressOpts *kvfile_compress.CompressOptions
+	if compressed {
+		compressOpts = &kvfile_compress.CompressOptions{}
+	}
+	return writeOutputFile(outputPath, func(file io.Writer) error {
+		return k.writeOutput(file, compressOpts, func(out io.Writer) error {
+			return write(out, reader)
+		})
+	})
+}
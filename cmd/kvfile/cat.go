@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+
+	"github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// catCommand builds the cat command.
+func (k *kvfileCli) catCommand() *cli.Command {
+	var prefix, separator, keysFrom string
+	return &cli.Command{
+		Name:  "cat",
+		Usage: "Write the values of the keys with a prefix or in --keys-from to stdout in order.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "prefix",
+				Usage:       "only write the values of the keys with the prefix",
+				Destination: &prefix,
+			},
+			&cli.StringFlag{
+				Name:        "separator",
+				Usage:       "string to write between values",
+				Destination: &separator,
+			},
+			&cli.StringFlag{
+				Name:        "keys-from",
+				Usage:       "path to a file with one key per line to write the values of in order, - for stdin",
+				Destination: &keysFrom,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if keysFrom != "" && prefix != "" {
+				return errors.New("cannot use --prefix with --keys-from")
+			}
+			prefixKey, err := k.parseKey("prefix", prefix)
+			if err != nil {
+				return err
+			}
+			var keys [][]byte
+			if keysFrom != "" {
+				keys, err = k.readKeysFile(keysFrom)
+				if err != nil {
+					return err
+				}
+			}
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
+
+			var written bool
+			writeSeparator := func() error {
+				if written && separator != "" {
+					if _, err := io.WriteString(k.stdout, separator); err != nil {
+						return err
+					}
+				}
+				written = true
+				return nil
+			}
+
+			if keysFrom == "" {
+				return reader.ScanPrefixEntries(prefixKey, func(indexEntry *kvfile.IndexEntry, indexEntryIdx int) error {
+					if err := writeSeparator(); err != nil {
+						return err
+					}
+					if _, err := reader.ReadToWithEntry(indexEntry, indexEntryIdx, k.stdout); err != nil {
+						return errors.Wrapf(err, "read %q", formatData(indexEntry.GetKey(), k.keyEncoding))
+					}
+					return nil
+				})
+			}
+
+			// check all keys exist before writing anything
+			for _, key := range keys {
+				found, err := reader.Exists(key)
+				if err != nil {
+					return err
+				}
+				if !found {
+					return errors.Errorf("Key %q not found.", formatData(key, k.keyEncoding))
+				}
+			}
+			for _, key := range keys {
+				if err := writeSeparator(); err != nil {
+					return err
+				}
+				if _, _, err := reader.ReadTo(key, k.stdout); err != nil {
+					return errors.Wrapf(err, "read %q", formatData(key, k.keyEncoding))
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// readKeysFile reads the keys from a file with one key per line, skipping
+// empty lines.
+//
+// Reads stdin if the path is -.
+func (k *kvfileCli) readKeysFile(filePath string) ([][]byte, error) {
+	in := k.stdin
+	if filePath != "-" {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	var keys [][]byte
+	scanner := bufio.NewScanner(in)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		key, err := parseData("key", scanner.Text(), k.keyEncoding)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s: line %d", filePath, line)
+		}
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, filePath)
+	}
+	return keys, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aperturerobotics/go-kvfile"
+	kvfile_compress "github.com/aperturerobotics/go-kvfile/compress"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// Formats for the convert command.
+const (
+	formatCompressed = "compressed"
+	formatPlain      = "plain"
+)
+
+// convertCommand builds the convert command.
+func (k *kvfileCli) convertCommand() *cli.Command {
+	var outputPath, to string
+	var force, progress bool
+	return &cli.Command{
+		Name:  "convert",
+		Usage: "Rewrite a kvfile as a compressed or plain kvfile.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output",
+				Usage:       "path to write the converted kvfile to",
+				Destination: &outputPath,
+			},
+			&cli.StringFlag{
+				Name:        "to",
+				Usage:       "format to convert to: compressed or plain",
+				Destination: &to,
+			},
+			&cli.BoolFlag{
+				Name:        "force",
+				Usage:       "allow replacing the input file",
+				Destination: &force,
+			},
+			k.progressFlag(&progress),
+		},
+		Action: func(c *cli.Context) error {
+			if outputPath == "" {
+				return errors.New("please provide an output path")
+			}
+			if to != formatCompressed && to != formatPlain {
+				return errors.Errorf("invalid --to: %q: expected compressed or plain", to)
+			}
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
+
+			inPath, err := filepath.Abs(k.filePath)
+			if err != nil {
+				return err
+			}
+			outPath, err := filepath.Abs(outputPath)
+			if err != nil {
+				return err
+			}
+			if inPath == outPath && !force {
+				return errors.New("output file is the input file: use --force to replace it")
+			}
+			inInfo, err := os.Stat(k.filePath)
+			if err != nil {
+				return err
+			}
+
+			writeOutputFile := createOutputFile
+			if inPath == outPath {
+				writeOutputFile = replaceOutputFile
+			}
+			var compressOpts *kvfile_compress.CompressOptions
+			if to == formatCompressed {
+				compressOpts = &kvfile_compress.CompressOptions{}
+			}
+			reporter := k.newProgressReporter(progress)
+			err = writeOutputFile(outputPath, func(file io.Writer) error {
+				return k.writeOutput(file, compressOpts, func(out io.Writer) error {
+					wr, err := reporter.newWriter(out)
+					if err != nil {
+						return err
+					}
+					return copyEntries(wr, reader)
+				})
+			})
+			reporter.finish()
+			if err != nil {
+				return err
+			}
+
+			outInfo, err := os.Stat(outputPath)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(k.stdout, "%d -> %d bytes\n", inInfo.Size(), outInfo.Size())
+			return nil
+		},
+	}
+}
+
+// copyEntries writes the entries from the reader to the writer and closes the
+// writer.
+//
+// All entries are copied with their metadata, expiry, and tombstones, including
+// the entries hidden by the reader. Values are streamed from the reader.
+func copyEntries(wr *kvfile.Writer, reader *kvfile.Reader) error {
+	size := reader.Size()
+	for i := uint64(0); i < size; i++ {
+		indexEntry, err := reader.ReadIndexEntry(i)
+		if err != nil {
+			return err
+		}
+		if err := wr.CopyEntry(reader, indexEntry, int(i)); err != nil {
+			return err
+		}
+	}
+	return wr.Close()
+}
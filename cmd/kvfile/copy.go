@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// copyCommand builds the copy command.
+func (k *kvfileCli) copyCommand() *cli.Command {
+	var outputPath, stripPrefix, addPrefix, onlyPrefix string
+	var strict bool
+	return &cli.Command{
+		Name:  "copy",
+		Usage: "Copy the entries of a kvfile to a new kvfile, remapping the key prefixes.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output",
+				Usage:       "path to write the new kvfile to",
+				Destination: &outputPath,
+			},
+			&cli.StringFlag{
+				Name:        "strip-prefix",
+				Usage:       "prefix to remove from the keys",
+				Destination: &stripPrefix,
+			},
+			&cli.StringFlag{
+				Name:        "add-prefix",
+				Usage:       "prefix to add to the keys after --strip-prefix",
+				Destination: &addPrefix,
+			},
+			&cli.StringFlag{
+				Name:        "only-prefix",
+				Usage:       "only copy the entries with keys with the prefix",
+				Destination: &onlyPrefix,
+			},
+			&cli.BoolFlag{
+				Name:        "strict",
+				Usage:       "return an error if a copied key does not have the --strip-prefix",
+				Destination: &strict,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if outputPath == "" {
+				return errors.New("please provide an output path")
+			}
+			prefixes := make([][]byte, 3)
+			for i, prefix := range []struct{ name, value string }{
+				{"strip-prefix", stripPrefix},
+				{"add-prefix", addPrefix},
+				{"only-prefix", onlyPrefix},
+			} {
+				var err error
+				prefixes[i], err = k.parseKey(prefix.name, prefix.value)
+				if err != nil {
+					return err
+				}
+			}
+			strip, add, only := prefixes[0], prefixes[1], prefixes[2]
+			if strict && len(strip) == 0 {
+				return errors.New("--strict requires --strip-prefix")
+			}
+			compressOpts, err := k.buildCompressOptions("")
+			if err != nil {
+				return err
+			}
+
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
+			inPath, err := filepath.Abs(k.filePath)
+			if err != nil {
+				return err
+			}
+			outPath, err := filepath.Abs(outputPath)
+			if err != nil {
+				return err
+			}
+			if inPath == outPath {
+				return errors.New("output file cannot be the input file")
+			}
+
+			var copied uint64
+			err = createOutputFile(outputPath, func(file io.Writer) error {
+				return k.writeOutput(file, compressOpts, func(out io.Writer) error {
+					var err error
+					copied, err = kvfile.Rewrite(out, reader, func(key []byte) ([]byte, bool, error) {
+						if !bytes.HasPrefix(key, only) {
+							return nil, false, nil
+						}
+						newKey, found := bytes.CutPrefix(key, strip)
+						if !found && strict {
+							return nil, false, errors.Errorf("key %q does not have the prefix %q", key, strip)
+						}
+						return append(bytes.Clone(add), newKey...), true, nil
+					})
+					return err
+				})
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(k.stdout, "copied %d entries\n", copied)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// importCSVCommand builds the import-csv command.
+func (k *kvfileCli) importCSVCommand() *cli.Command {
+	var inputPath, delimiter string
+	var keyColumn, valueColumn int
+	var tsv, header, progress bool
+	return &cli.Command{
+		Name:  "import-csv",
+		Usage: "Write a new kvfile from CSV or TSV rows read from stdin or --input.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "input",
+				Usage:       "path to the CSV to read (default: stdin)",
+				Destination: &inputPath,
+			},
+			&cli.IntFlag{
+				Name:        "key-column",
+				Usage:       "index of the key column (encoded with --key-encoding)",
+				Value:       0,
+				Destination: &keyColumn,
+			},
+			&cli.IntFlag{
+				Name:        "value-column",
+				Usage:       "index of the value column",
+				Value:       1,
+				Destination: &valueColumn,
+			},
+			&cli.StringFlag{
+				Name:        "delimiter",
+				Usage:       "the field delimiter",
+				Value:       ",",
+				Destination: &delimiter,
+			},
+			&cli.BoolFlag{
+				Name:        "tsv",
+				Usage:       "read tab-separated values, same as --delimiter '\\t'",
+				Destination: &tsv,
+			},
+			&cli.BoolFlag{
+				Name:        "header",
+				Usage:       "skip the first row",
+				Destination: &header,
+			},
+			k.progressFlag(&progress),
+		},
+		Action: func(c *cli.Context) error {
+			if k.filePath == "" {
+				return errors.New("please provide a file path")
+			}
+			if keyColumn < 0 || valueColumn < 0 || keyColumn == valueColumn {
+				return errors.Errorf("invalid --key-column and --value-column: %v and %v", keyColumn, valueColumn)
+			}
+			if tsv {
+				if c.IsSet("delimiter") {
+					return errors.New("--tsv and --delimiter cannot be used together")
+				}
+				delimiter = "\t"
+			}
+			comma, size := utf8.DecodeRuneInString(delimiter)
+			if size == 0 || size != len(delimiter) {
+				return errors.Errorf("invalid --delimiter: %q: expected a single character", delimiter)
+			}
+			compressOpts, err := k.buildCompressOptions("")
+			if err != nil {
+				return err
+			}
+
+			in := k.stdin
+			if inputPath != "" {
+				file, err := os.Open(inputPath)
+				if err != nil {
+					return errors.Wrap(err, "read input")
+				}
+				defer file.Close()
+				in = file
+			}
+
+			cr := csv.NewReader(in)
+			cr.Comma = comma
+			cr.FieldsPerRecord = -1
+			cr.ReuseRecord = true
+			reporter := k.newProgressReporter(progress)
+			defer reporter.finish()
+			return createOutputFile(k.filePath, func(file io.Writer) error {
+				return k.writeOutput(file, compressOpts, func(out io.Writer) error {
+					wr, err := reporter.newWriter(out)
+					if err != nil {
+						return err
+					}
+					return k.importCSV(wr, cr, keyColumn, valueColumn, header)
+				})
+			})
+		},
+	}
+}
+
+// importCSV writes the rows read from cr to the writer and closes the writer.
+//
+// The rows are streamed to the writer.
+func (k *kvfileCli) importCSV(wr *kvfile.Writer, cr *csv.Reader, keyColumn, valueColumn int, header bool) error {
+	// seen maps each key to the line number it was read on
+	seen := make(map[string]int)
+	for {
+		record, err := cr.Read()
+		if err == nil && header {
+			header = false
+			record, err = cr.Read()
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "read CSV")
+		}
+		line, _ := cr.FieldPos(0)
+		if len(record) <= keyColumn || len(record) <= valueColumn {
+			return errors.Errorf("line %d: expected at least %d columns: found %d", line, max(keyColumn, valueColumn)+1, len(record))
+		}
+		key, err := parseData(fmt.Sprintf("key on line %d", line), record[keyColumn], k.keyEncoding)
+		if err != nil {
+			return err
+		}
+		if len(key) == 0 {
+			return errors.Errorf("line %d: key cannot be empty", line)
+		}
+		if prevLine, ok := seen[string(key)]; ok {
+			return errors.Errorf("duplicate key %q on line %d: first seen on line %d", key, line, prevLine)
+		}
+		seen[string(key)] = line
+		if err := wr.WriteValue(key, strings.NewReader(record[valueColumn])); err != nil {
+			return err
+		}
+	}
+	return wr.Close()
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/aperturerobotics/go-kvfile"
+	"github.com/urfave/cli/v2"
+)
+
+// Change types for the diff command.
+const (
+	changeAdded   = "added"
+	changeRemoved = "removed"
+	changeChanged = "changed"
+)
+
+// diffEntry is a difference in the diff --json output.
+//
+// Keys which are valid UTF-8 are stored as strings, otherwise they are stored
+// base64 encoded in key_base64.
+type diffEntry struct {
+	Change    string `json:"change"`
+	Key       string `json:"key,omitempty"`
+	KeyBase64 []byte `json:"key_base64,omitempty"`
+	OldSize   *int64 `json:"old_size,omitempty"`
+	NewSize   *int64 `json:"new_size,omitempty"`
+}
+
+// diffCommand builds the diff command.
+//
+// Exits with 0 if the files are equal, 1 if not, and 2 on errors.
+func (k *kvfileCli) diffCommand() *cli.Command {
+	var prefix string
+	var values, jsonOut bool
+	return &cli.Command{
+		Name:      "diff",
+		Usage:     "List the added, removed, and changed keys: exits 0 if equal, 1 if different, 2 on errors.",
+		ArgsUsage: "<old.kv> <new.kv>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "prefix",
+				Usage:       "only compare the keys with the prefix",
+				Destination: &prefix,
+			},
+			&cli.BoolFlag{
+				Name:        "values",
+				Usage:       "print the value sizes",
+				Destination: &values,
+			},
+			&cli.BoolFlag{
+				Name:        "json",
+				Usage:       "print the differences as JSON Lines",
+				Destination: &jsonOut,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() != 2 {
+				return cli.Exit("please provide the old and new files", 2)
+			}
+			prefixKey, err := k.parseKey("prefix", prefix)
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+			oldPath, newPath := c.Args().Get(0), c.Args().Get(1)
+			oldReader, oldRel, _, err := k.openKVFilePath(oldPath, false)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("open %s: %v", oldPath, err), 2)
+			}
+			defer oldRel()
+			newReader, newRel, _, err := k.openKVFilePath(newPath, false)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("open %s: %v", newPath, err), 2)
+			}
+			defer newRel()
+
+			bw := bufio.NewWriter(k.stdout)
+			enc := json.NewEncoder(bw)
+			enc.SetEscapeHTML(false)
+			differ, err := diffReaders(oldReader, newReader, prefixKey, func(entry *diffEntry, key []byte) error {
+				if jsonOut {
+					return enc.Encode(entry)
+				}
+				return k.printDiffEntry(bw, entry, key, values)
+			})
+			if ferr := bw.Flush(); err == nil {
+				err = ferr
+			}
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+			if differ {
+				return cli.Exit("", 1)
+			}
+			return nil
+		},
+	}
+}
+
+// printDiffEntry prints a difference as a line starting with +, -, or ~.
+func (k *kvfileCli) printDiffEntry(out io.Writer, entry *diffEntry, key []byte, values bool) error {
+	keyStr := formatData(key, k.keyEncoding)
+	var err error
+	switch entry.Change {
+	case changeAdded:
+		if values {
+			_, err = fmt.Fprintf(out, "+ %s (%d bytes)\n", keyStr, *entry.NewSize)
+		} else {
+			_, err = fmt.Fprintf(out, "+ %s\n", keyStr)
+		}
+	case changeRemoved:
+		if values {
+			_, err = fmt.Fprintf(out, "- %s (%d bytes)\n", keyStr, *entry.OldSize)
+		} else {
+			_, err = fmt.Fprintf(out, "- %s\n", keyStr)
+		}
+	default:
+		if values {
+			_, err = fmt.Fprintf(out, "~ %s (%d -> %d bytes)\n", keyStr, *entry.OldSize, *entry.NewSize)
+		} else {
+			_, err = fmt.Fprintf(out, "~ %s\n", keyStr)
+		}
+	}
+	return err
+}
+
+// diffReaders calls the callback with each difference between the entries
+// with the prefix in the readers, returning if any were found.
+//
+// The indexes are read in lockstep and the values are streamed.
+func diffReaders(oldReader, newReader *kvfile.Reader, prefix []byte, cb func(entry *diffEntry, key []byte) error) (bool, error) {
+	oldCursor, err := newMergeCursor(oldReader, prefix)
+	if err != nil {
+		return false, err
+	}
+	newCursor, err := newMergeCursor(newReader, prefix)
+	if err != nil {
+		return false, err
+	}
+
+	bufOld, bufNew := make([]byte, 32*1024), make([]byte, 32*1024)
+	var differ bool
+	for oldCursor.entry != nil || newCursor.entry != nil {
+		var cmp int
+		switch {
+		case oldCursor.entry == nil:
+			cmp = 1
+		case newCursor.entry == nil:
+			cmp = -1
+		default:
+			cmp = bytes.Compare(oldCursor.entry.GetKey(), newCursor.entry.GetKey())
+		}
+
+		var key []byte
+		entry := &diffEntry{}
+		if cmp <= 0 {
+			key = oldCursor.entry.GetKey()
+			rdr, err := oldCursor.valueReader()
+			if err != nil {
+				return differ, err
+			}
+			oldSize := rdr.Size()
+			entry.OldSize = &oldSize
+		}
+		if cmp >= 0 {
+			key = newCursor.entry.GetKey()
+			rdr, err := newCursor.valueReader()
+			if err != nil {
+				return differ, err
+			}
+			newSize := rdr.Size()
+			entry.NewSize = &newSize
+		}
+
+		switch {
+		case cmp < 0:
+			entry.Change = changeRemoved
+		case cmp > 0:
+			entry.Change = changeAdded
+		case *entry.OldSize != *entry.NewSize:
+			entry.Change = changeChanged
+		default:
+			oldRdr, err := oldCursor.valueReader()
+			if err != nil {
+				return differ, err
+			}
+			newRdr, err := newCursor.valueReader()
+			if err != nil {
+				return differ, err
+			}
+			equal, err := valuesEqual(oldRdr, newRdr, bufOld, bufNew)
+			if err != nil {
+				return differ, err
+			}
+			if !equal {
+				entry.Change = changeChanged
+			}
+		}
+
+		if entry.Change != "" {
+			differ = true
+			if utf8.Valid(key) {
+				entry.Key = string(key)
+			} else {
+				entry.KeyBase64 = key
+			}
+			if err := cb(entry, key); err != nil {
+				return differ, err
+			}
+		}
+
+		if cmp <= 0 {
+			if err := oldCursor.next(); err != nil {
+				return differ, err
+			}
+		}
+		if cmp >= 0 {
+			if err := newCursor.next(); err != nil {
+				return differ, err
+			}
+		}
+	}
+	return differ, nil
+}
+
+// valuesEqual compares two values of the same size using the buffers.
+func valuesEqual(a, b io.Reader, bufA, bufB []byte) (bool, error) {
+	for {
+		na, err := io.ReadFull(a, bufA)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return false, err
+		}
+		nb, err := io.ReadFull(b, bufB)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return false, err
+		}
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		if na < len(bufA) {
+			return true, nil
+		}
+	}
+}
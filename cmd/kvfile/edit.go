@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// editCommand builds the edit command.
+func (k *kvfileCli) editCommand() *cli.Command {
+	var keyIn keyInput
+	var force bool
+	return &cli.Command{
+		Name:  "edit",
+		Usage: "Edit the value for a key with $KVFILE_EDITOR or $EDITOR and replace the kvfile if changed.",
+		Flags: append(keyIn.flags("the key to edit"),
+			&cli.BoolFlag{
+				Name:        "force",
+				Usage:       "edit values which are not valid UTF-8 text",
+				Destination: &force,
+			},
+		),
+		Action: func(c *cli.Context) error {
+			key, err := k.parseKeyInput(&keyIn)
+			if err != nil {
+				return err
+			}
+			value, err := k.readValue(key)
+			if err != nil {
+				return err
+			}
+			if !force && (!utf8.Valid(value) || bytes.IndexByte(value, 0) >= 0) {
+				return errors.New("value is binary: use --force to edit it anyway")
+			}
+
+			edited, err := k.editValue(key, value)
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(edited, value) {
+				fmt.Fprintln(k.stderr, "Edit cancelled, no changes made.")
+				return nil
+			}
+			return k.amendFile("", true, map[string][]byte{string(key): edited}, nil, false)
+		},
+	}
+}
+
+// readValue reads the value for the key from the kvfile at the file path and
+// closes the kvfile.
+func (k *kvfileCli) readValue(key []byte) ([]byte, error) {
+	reader, rel, err := k.openKVFile()
+	if rel != nil {
+		defer rel()
+	}
+	if err != nil {
+		return nil, err
+	}
+	value, found, err := reader.Get(key)
+	if err == nil && !found {
+		err = errors.Errorf("Key %q not found.", formatData(key, k.keyEncoding))
+	}
+	return value, err
+}
+
+// editValue writes the value to a temporary file, opens it in the editor, and
+// returns the edited contents.
+//
+// The temporary file has the extension of the key, if any, and is removed.
+func (k *kvfileCli) editValue(key, value []byte) ([]byte, error) {
+	editor := os.Getenv("KVFILE_EDITOR")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	editorArgs := strings.Fields(editor)
+	if len(editorArgs) == 0 {
+		return nil, errors.New("the editor command is empty")
+	}
+
+	var ext string
+	if utf8.Valid(key) {
+		ext = filepath.Ext(string(key))
+		if strings.ContainsAny(ext, `/\`) {
+			ext = ""
+		}
+	}
+	file, err := os.CreateTemp("", "kvfile-edit-*"+ext)
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := file.Name()
+	defer os.Remove(tmpPath)
+	_, err = file.Write(value)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(editorArgs[0], append(editorArgs[1:], tmpPath)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = k.stdin, k.stdout, k.stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrap(err, "run editor")
+	}
+	return os.ReadFile(tmpPath)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+
+	b58 "github.com/mr-tron/base58/base58"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// Encodings for keys and values.
+const (
+	encodingRaw    = "raw"
+	encodingHex    = "hex"
+	encodingBase64 = "base64"
+	encodingBase58 = "base58"
+)
+
+// checkEncoding checks that the encoding is known, naming the source of the
+// encoding in errors.
+func checkEncoding(name, encoding string) error {
+	switch encoding {
+	case encodingRaw, encodingHex, encodingBase64, encodingBase58:
+		return nil
+	default:
+		return errors.Errorf("invalid %s: %q: expected raw, hex, base64, or base58", name, encoding)
+	}
+}
+
+// formatData encodes the data for printing.
+func formatData(data []byte, encoding string) string {
+	switch encoding {
+	case encodingHex:
+		return hex.EncodeToString(data)
+	case encodingBase64:
+		return base64.StdEncoding.EncodeToString(data)
+	case encodingBase58:
+		return b58.Encode(data)
+	default:
+		return string(data)
+	}
+}
+
+// parseData decodes the data, naming the source of the data in errors.
+//
+// Returns nil if data is empty.
+func parseData(name, data, encoding string) ([]byte, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var out []byte
+	var err error
+	switch encoding {
+	case encodingHex:
+		out, err = hex.DecodeString(data)
+	case encodingBase64:
+		out, err = base64.StdEncoding.DecodeString(data)
+	case encodingBase58:
+		out, err = b58.Decode(data)
+	default:
+		out = []byte(data)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "decode %s as %s", name, encoding)
+	}
+	return out, nil
+}
+
+// parseKey decodes a key read from the flag with the key encoding.
+func (k *kvfileCli) parseKey(flagName, key string) ([]byte, error) {
+	return parseData("--"+flagName, key, k.keyEncoding)
+}
+
+// keyInput contains the flags to supply a key.
+type keyInput struct {
+	key, keyHex, keyBase64, keyBase58 string
+	keyFile                           string
+	stripNewline                      bool
+}
+
+// flags returns the flags to supply the key with the usage.
+func (i *keyInput) flags(usage string) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "key",
+			Usage:       usage + " (encoded with --key-encoding)",
+			Destination: &i.key,
+		},
+		&cli.StringFlag{
+			Name:        "key-hex",
+			Usage:       usage + " as hex",
+			Destination: &i.keyHex,
+		},
+		&cli.StringFlag{
+			Name:        "key-base64",
+			Usage:       usage + " as base64",
+			Destination: &i.keyBase64,
+		},
+		&cli.StringFlag{
+			Name:        "key-base58",
+			Usage:       usage + " as base58",
+			Destination: &i.keyBase58,
+		},
+		&cli.StringFlag{
+			Name:        "key-file",
+			Usage:       "path to a file containing " + usage + " as raw bytes",
+			Destination: &i.keyFile,
+		},
+		&cli.BoolFlag{
+			Name:        "strip-newline",
+			Usage:       "remove a trailing newline from the --key-file contents",
+			Destination: &i.stripNewline,
+		},
+	}
+}
+
+// parseKeyInput decodes the key from the key flags.
+//
+// Exactly one of the key flags must be set. The --key-file contents are used
+// verbatim unless --strip-newline is set.
+func (k *kvfileCli) parseKeyInput(i *keyInput) ([]byte, error) {
+	var key []byte
+	var err error
+	var count int
+	if i.key != "" {
+		count++
+		key, err = k.parseKey("key", i.key)
+	}
+	for _, input := range []struct{ name, data, encoding string }{
+		{"--key-hex", i.keyHex, encodingHex},
+		{"--key-base64", i.keyBase64, encodingBase64},
+		{"--key-base58", i.keyBase58, encodingBase58},
+	} {
+		if input.data != "" {
+			count++
+			key, err = parseData(input.name, input.data, input.encoding)
+		}
+	}
+	if i.stripNewline && i.keyFile == "" {
+		return nil, errors.New("--strip-newline requires --key-file")
+	}
+	if i.keyFile != "" {
+		count++
+		key, err = os.ReadFile(i.keyFile)
+		if trimmed, ok := bytes.CutSuffix(key, []byte("\n")); ok && i.stripNewline {
+			key = bytes.TrimSuffix(trimmed, []byte("\r"))
+		}
+	}
+	switch {
+	case count == 0:
+		return nil, errors.New("please provide a key")
+	case count > 1:
+		return nil, errors.New("only one of --key, --key-hex, --key-base64, --key-base58, and --key-file can be set")
+	case err != nil:
+		return nil, err
+	case len(key) == 0:
+		return nil, errors.New("please provide a key")
+	}
+	return key, nil
+}
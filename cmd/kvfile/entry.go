@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// entryInfo is the get --meta and entry output.
+type entryInfo struct {
+	Key           string `json:"key"`
+	Index         int    `json:"index"`
+	Offset        int64  `json:"offset"`
+	Size          int64  `json:"size"`
+	ExpiresUnixMs uint64 `json:"expires_unix_ms,omitempty"`
+	Meta          []byte `json:"meta,omitempty"`
+	Tombstone     bool   `json:"tombstone,omitempty"`
+	Encrypted     bool   `json:"encrypted,omitempty"`
+	External      bool   `json:"external,omitempty"`
+}
+
+// entryCommand builds the entry command.
+func (k *kvfileCli) entryCommand() *cli.Command {
+	var keyIn keyInput
+	return &cli.Command{
+		Name:  "entry",
+		Usage: "Print the index entry for a key as JSON without reading the value.",
+		Flags: keyIn.flags("the key to look up"),
+		Action: func(c *cli.Context) error {
+			key, err := k.parseKeyInput(&keyIn)
+			if err != nil {
+				return err
+			}
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
+			return k.printEntryInfo(reader, key)
+		},
+	}
+}
+
+// printEntryInfo prints the index entry for the key as JSON.
+//
+// Does not read the value.
+func (k *kvfileCli) printEntryInfo(reader *kvfile.Reader, key []byte) error {
+	offset, size, indexEntry, indexEntryIdx, err := reader.GetValuePosition(key)
+	if err != nil {
+		return err
+	}
+	if indexEntry == nil {
+		return errors.Errorf("Key %q not found.", formatData(key, k.keyEncoding))
+	}
+	data, err := json.Marshal(&entryInfo{
+		Key:           formatData(key, k.keyEncoding),
+		Index:         indexEntryIdx,
+		Offset:        offset,
+		Size:          size,
+		ExpiresUnixMs: indexEntry.GetExpiresUnixMs(),
+		Meta:          indexEntry.GetMeta(),
+		Tombstone:     indexEntry.GetTombstone(),
+		Encrypted:     reader.EncryptedValues(),
+		External:      reader.ExternalValues(),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(k.stdout, "%s\n", data)
+	return nil
+}
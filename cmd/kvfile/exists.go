@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// existsCommand builds the exists command.
+//
+// Exits with 0 if the key exists, 1 if not, and 2 on errors.
+func (k *kvfileCli) existsCommand() *cli.Command {
+	var keyIn keyInput
+	var verbose bool
+	return &cli.Command{
+		Name:  "exists",
+		Usage: "Check if a key exists: exits 0 if found, 1 if not found, 2 on errors.",
+		Flags: append(keyIn.flags("the key to look up"),
+			&cli.BoolFlag{
+				Name:        "verbose",
+				Usage:       "print the key and if it was found",
+				Destination: &verbose,
+			},
+		),
+		Action: func(c *cli.Context) error {
+			key, err := k.parseKeyInput(&keyIn)
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+
+			found, err := reader.Exists(key)
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+			if verbose {
+				status := "found"
+				if !found {
+					status = "not found"
+				}
+				fmt.Fprintf(k.stdout, "%s: %s\n", formatData(key, k.keyEncoding), status)
+			}
+			if !found {
+				return cli.Exit("", 1)
+			}
+			return nil
+		},
+	}
+}
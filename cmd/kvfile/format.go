@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// Output formats for entries.
+const (
+	formatText  = "text"
+	formatJSON  = "json"
+	formatTSV   = "tsv"
+	formatTable = "table"
+)
+
+// tsvEscaper escapes the fields of tab-separated output.
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// outputFormat contains the output format flags of a command.
+type outputFormat struct {
+	format   string
+	print0   bool
+	maxWidth int
+	noColor  bool
+}
+
+// flags returns the flags for the output format.
+func (o *outputFormat) flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "format",
+			Usage:       "output format: text, json (one object per line with base64 values), tsv (with escaping), or table (aligned columns printed at the end)",
+			Value:       formatText,
+			Destination: &o.format,
+		},
+		&cli.BoolFlag{
+			Name:        "print0",
+			Usage:       "terminate keys and values with NUL instead of newline in the text format",
+			Destination: &o.print0,
+		},
+		&cli.IntFlag{
+			Name:        "max-width",
+			Usage:       "width to truncate the table format to (default: $COLUMNS or 80)",
+			Destination: &o.maxWidth,
+		},
+		&cli.BoolFlag{
+			Name:        "no-color",
+			Usage:       "print the table format without ANSI escapes (default: true if $NO_COLOR is set or stdout is not a terminal)",
+			Destination: &o.noColor,
+		},
+	}
+}
+
+// entryPrinter prints entries in an output format.
+type entryPrinter struct {
+	out           io.Writer
+	format        string
+	print0        bool
+	keyEncoding   string
+	valueEncoding string
+	enc           *json.Encoder
+	table         *tableWriter
+}
+
+// newEntryPrinter builds a printer for the output format writing to stdout.
+func (k *kvfileCli) newEntryPrinter(o *outputFormat) (*entryPrinter, error) {
+	switch o.format {
+	case formatText:
+	case formatJSON, formatTSV, formatTable:
+		if o.print0 {
+			return nil, errors.New("--print0 requires --format text")
+		}
+	default:
+		return nil, errors.Errorf("invalid --format: %q: expected text, json, tsv, or table", o.format)
+	}
+	if o.format != formatTable && (o.maxWidth != 0 || o.noColor) {
+		return nil, errors.New("--max-width and --no-color require --format table")
+	}
+	if o.maxWidth < 0 {
+		return nil, errors.Errorf("invalid --max-width: %v", o.maxWidth)
+	}
+	p := &entryPrinter{
+		out:           k.stdout,
+		format:        o.format,
+		print0:        o.print0,
+		keyEncoding:   k.keyEncoding,
+		valueEncoding: k.valueEncoding,
+	}
+	if o.format == formatJSON {
+		p.enc = json.NewEncoder(k.stdout)
+		p.enc.SetEscapeHTML(false)
+	}
+	if o.format == formatTable {
+		p.table = &tableWriter{
+			out:   k.stdout,
+			width: o.maxWidth,
+			color: !o.noColor && os.Getenv("NO_COLOR") == "" && isTerminal(k.stdout),
+		}
+		if p.table.width == 0 {
+			p.table.width = terminalWidth()
+		}
+	}
+	return p, nil
+}
+
+// printKey prints a key.
+func (p *entryPrinter) printKey(key []byte) error {
+	return p.printEntry(key, nil, false)
+}
+
+// printEntry prints a key and value if withValue is set.
+//
+// The json format ignores the encodings: keys which are valid UTF-8 are
+// strings, otherwise they are base64 in key_base64, and values are base64 in
+// value_base64.
+func (p *entryPrinter) printEntry(key, value []byte, withValue bool) error {
+	var err error
+	switch p.format {
+	case formatJSON:
+		entry := &kvfile.JSONLEntry{}
+		if utf8.Valid(key) {
+			entry.Key = string(key)
+		} else {
+			entry.KeyBase64 = key
+		}
+		if withValue {
+			entry.ValueBase64 = value
+		}
+		err = p.enc.Encode(entry)
+	case formatTable:
+		var valueStr string
+		if withValue {
+			valueStr = formatData(value, p.valueEncoding)
+		}
+		p.table.addRow(formatData(key, p.keyEncoding), valueStr, len(value), withValue)
+	case formatTSV:
+		line := tsvEscaper.Replace(formatData(key, p.keyEncoding))
+		if withValue {
+			line += "\t" + tsvEscaper.Replace(formatData(value, p.valueEncoding))
+		}
+		_, err = io.WriteString(p.out, line+"\n")
+	default:
+		term := "\n"
+		if p.print0 {
+			term = "\x00"
+		}
+		out := formatData(key, p.keyEncoding) + term
+		if withValue {
+			out += formatData(value, p.valueEncoding) + term
+		}
+		_, err = io.WriteString(p.out, out)
+	}
+	return err
+}
+
+// flush prints the entries buffered by the table format.
+func (p *entryPrinter) flush() error {
+	if p.table == nil {
+		return nil
+	}
+	return p.table.flush()
+}
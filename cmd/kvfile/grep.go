@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/aperturerobotics/go-kvfile"
+	"github.com/urfave/cli/v2"
+)
+
+// grepSnippetContext is the number of bytes around a match in snippets.
+const grepSnippetContext = 20
+
+// grepCommand builds the grep command.
+//
+// Exits with 0 if a value matched, 1 if not, and 2 on errors.
+func (k *kvfileCli) grepCommand() *cli.Command {
+	var pattern, prefix string
+	var useRegex, showValue bool
+	return &cli.Command{
+		Name:  "grep",
+		Usage: "Print the keys with values containing a pattern: exits 0 if found, 1 if not found, 2 on errors.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "pattern",
+				Usage:       "the substring to search for in the values",
+				Destination: &pattern,
+			},
+			&cli.BoolFlag{
+				Name:        "regex",
+				Usage:       "interpret the pattern as a regular expression",
+				Destination: &useRegex,
+			},
+			&cli.StringFlag{
+				Name:        "prefix",
+				Usage:       "only search the values of the keys with the prefix",
+				Destination: &prefix,
+			},
+			&cli.BoolFlag{
+				Name:        "show-value",
+				Usage:       "print the offset of the first match and a snippet of the value",
+				Destination: &showValue,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if pattern == "" {
+				return cli.Exit("please provide a pattern", 2)
+			}
+			prefixKey, err := k.parseKey("prefix", prefix)
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+			// match returns the start and end of the first match or nil
+			var match func(value []byte) []int
+			if useRegex {
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("invalid --pattern: %v", err), 2)
+				}
+				match = re.FindIndex
+			} else {
+				literal := []byte(pattern)
+				match = func(value []byte) []int {
+					if idx := bytes.Index(value, literal); idx >= 0 {
+						return []int{idx, idx + len(literal)}
+					}
+					return nil
+				}
+			}
+
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+
+			var matched bool
+			err = reader.ScanPrefixEntries(prefixKey, func(indexEntry *kvfile.IndexEntry, indexEntryIdx int) error {
+				// read one value at a time
+				value, err := reader.GetWithEntry(indexEntry, indexEntryIdx)
+				if err != nil {
+					return err
+				}
+				loc := match(value)
+				if loc == nil {
+					return nil
+				}
+				matched = true
+				key := formatData(indexEntry.GetKey(), k.keyEncoding)
+				if !showValue {
+					_, err = fmt.Fprintln(k.stdout, key)
+					return err
+				}
+				start := max(loc[0]-grepSnippetContext, 0)
+				end := min(loc[1]+grepSnippetContext, len(value))
+				_, err = fmt.Fprintf(k.stdout, "%s: offset %d: %q\n", key, loc[0], value[start:end])
+				return err
+			})
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+			if !matched {
+				return cli.Exit("", 1)
+			}
+			return nil
+		},
+	}
+}
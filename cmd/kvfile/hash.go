@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+
+	"github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// hashAlgos are the hash functions for the hash command.
+var hashAlgos = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// hashCommand builds the hash command.
+func (k *kvfileCli) hashCommand() *cli.Command {
+	algo := "sha256"
+	return &cli.Command{
+		Name:  "hash",
+		Usage: "Print the hex content digest of a k/v file, which does not depend on the physical layout or compression.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "algo",
+				Usage:       "hash function: sha256 or sha512",
+				Value:       algo,
+				Destination: &algo,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			newHash, ok := hashAlgos[algo]
+			if !ok {
+				return errors.Errorf("invalid --algo: %q", algo)
+			}
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
+
+			digest, err := kvfile.ContentDigest(reader, newHash)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(k.stdout, hex.EncodeToString(digest))
+			return nil
+		},
+	}
+}
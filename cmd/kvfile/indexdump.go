@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// indexDumpLayout is the first index-dump --json record.
+type indexDumpLayout struct {
+	ValuesEnd            uint64 `json:"values_end"`
+	IndexEntryListPos    uint64 `json:"index_entry_list_pos"`
+	IndexEntryIndexesPos uint64 `json:"index_entry_indexes_pos"`
+	EntryCount           uint64 `json:"entry_count"`
+	FixedKeyWidth        uint64 `json:"fixed_key_width,omitempty"`
+}
+
+// indexDumpEntry is an index-dump --json record for an index entry.
+//
+// Error is set if the entry is corrupt.
+type indexDumpEntry struct {
+	Index       uint64 `json:"index"`
+	Offset      uint64 `json:"offset"`
+	Key         string `json:"key,omitempty"`
+	ValueOffset uint64 `json:"value_offset,omitempty"`
+	ValueSize   uint64 `json:"value_size,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// indexDumpCommand builds the index-dump command.
+func (k *kvfileCli) indexDumpCommand() *cli.Command {
+	var start, limit uint64
+	var jsonOut bool
+	return &cli.Command{
+		Name:  "index-dump",
+		Usage: "Print the layout and index entries of a k/v file, flagging corrupt entries.",
+		Flags: []cli.Flag{
+			&cli.Uint64Flag{
+				Name:        "start",
+				Usage:       "index of the first entry to print",
+				Destination: &start,
+			},
+			&cli.Uint64Flag{
+				Name:        "limit",
+				Usage:       "maximum number of entries to print (default: no limit)",
+				Destination: &limit,
+			},
+			&cli.BoolFlag{
+				Name:        "json",
+				Usage:       "print the layout and entries as JSON Lines",
+				Destination: &jsonOut,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
+
+			bw := bufio.NewWriter(k.stdout)
+			corrupt, err := k.dumpIndex(bw, reader, start, limit, jsonOut)
+			if ferr := bw.Flush(); err == nil {
+				err = ferr
+			}
+			if err != nil {
+				return err
+			}
+			if corrupt != 0 {
+				return errors.Errorf("found %d corrupt entries", corrupt)
+			}
+			return nil
+		},
+	}
+}
+
+// dumpIndex writes the layout and the index entries in [start, start+limit) to
+// the writer.
+//
+// Continues past corrupt entries and returns the number found.
+func (k *kvfileCli) dumpIndex(out io.Writer, reader *kvfile.Reader, start, limit uint64, jsonOut bool) (int, error) {
+	layout := reader.Layout()
+	enc := json.NewEncoder(out)
+	if jsonOut {
+		if err := enc.Encode(&indexDumpLayout{
+			ValuesEnd:            layout.ValuesEnd,
+			IndexEntryListPos:    layout.IndexEntryListPos,
+			IndexEntryIndexesPos: layout.IndexEntryIndexesPos,
+			EntryCount:           layout.EntryCount,
+			FixedKeyWidth:        layout.FixedKeyWidth,
+		}); err != nil {
+			return 0, err
+		}
+	} else {
+		fmt.Fprintf(out, "values: 0-%d\n", layout.ValuesEnd)
+		fmt.Fprintf(out, "index entries: %d\n", layout.IndexEntryListPos)
+		fmt.Fprintf(out, "index entry positions: %d\n", layout.IndexEntryIndexesPos)
+		if layout.FixedKeyWidth != 0 {
+			fmt.Fprintf(out, "fixed key width: %d\n", layout.FixedKeyWidth)
+		}
+		fmt.Fprintf(out, "entry count: %d\n", layout.EntryCount)
+	}
+
+	end := layout.EntryCount
+	if limit != 0 && start < end && end-start > limit {
+		end = start + limit
+	}
+	var corrupt int
+	for i := start; i < end; i++ {
+		entry := &indexDumpEntry{Index: i, Offset: reader.IndexEntryOffset(i)}
+		indexEntry, err := reader.ReadIndexEntry(i)
+		if err == nil {
+			entry.Key = formatData(indexEntry.GetKey(), k.keyEncoding)
+			entry.ValueOffset, entry.ValueSize = indexEntry.GetOffset(), indexEntry.GetSize()
+			_, _, err = reader.GetValuePositionWithEntry(indexEntry, int(i))
+		}
+		if err != nil {
+			entry.Error = err.Error()
+			corrupt++
+		}
+
+		if jsonOut {
+			if err := enc.Encode(entry); err != nil {
+				return corrupt, err
+			}
+			continue
+		}
+		if indexEntry == nil {
+			fmt.Fprintf(out, "%d\toffset %d\tCORRUPT: %s\n", entry.Index, entry.Offset, entry.Error)
+			continue
+		}
+		fmt.Fprintf(out, "%d\toffset %d\tkey %q\tvalue %d+%d", entry.Index, entry.Offset, entry.Key, entry.ValueOffset, entry.ValueSize)
+		if entry.Error != "" {
+			fmt.Fprintf(out, "\tCORRUPT: %s", entry.Error)
+		}
+		fmt.Fprintln(out)
+	}
+	return corrupt, nil
+}
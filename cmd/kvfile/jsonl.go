@@ -0,0 +1,128 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// exportCommand builds the export command.
+func (k *kvfileCli) exportCommand() *cli.Command {
+	var outputPath string
+	return &cli.Command{
+		Name:  "export",
+		Usage: "Export the key-value pairs as JSON Lines.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output",
+				Usage:       "path to write the JSON Lines to (default: stdout)",
+				Destination: &outputPath,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
+
+			out := k.stdout
+			if outputPath != "" {
+				file, err := os.Create(outputPath)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				out = file
+			}
+
+			return kvfile.ExportJSONL(out, reader)
+		},
+	}
+}
+
+// importCommand builds the import command.
+func (k *kvfileCli) importCommand() *cli.Command {
+	var inputPath string
+	var progress bool
+	return &cli.Command{
+		Name:  "import",
+		Usage: "Write a new kvfile from JSON Lines read from stdin or --input.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "input",
+				Usage:       "path to the JSON Lines to read (default: stdin)",
+				Destination: &inputPath,
+			},
+			k.progressFlag(&progress),
+		},
+		Action: func(c *cli.Context) error {
+			if k.filePath == "" {
+				return errors.New("please provide a file path")
+			}
+			compressOpts, err := k.buildCompressOptions("")
+			if err != nil {
+				return err
+			}
+
+			in := k.stdin
+			if inputPath != "" {
+				file, err := os.Open(inputPath)
+				if err != nil {
+					return errors.Wrap(err, "read input")
+				}
+				defer file.Close()
+				in = file
+			}
+
+			reporter := k.newProgressReporter(progress)
+			defer reporter.finish()
+			return createOutputFile(k.filePath, func(file io.Writer) error {
+				return k.writeOutput(file, compressOpts, func(out io.Writer) error {
+					wr, err := reporter.newWriter(out)
+					if err != nil {
+						return err
+					}
+					return kvfile.ImportJSONLToWriter(wr, in)
+				})
+			})
+		},
+	}
+}
+
+// createOutputFile creates the file and calls the callback to write it.
+//
+// The file is removed if the callback returns an error.
+func createOutputFile(path string, cb func(file io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = cb(file)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+	}
+	return err
+}
+
+// replaceOutputFile writes a temporary file with the callback and renames it
+// over the file at path.
+func replaceOutputFile(path string, cb func(file io.Writer) error) error {
+	tmpPath := path + ".tmp"
+	if err := createOutputFile(tmpPath, cb); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
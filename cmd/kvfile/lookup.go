@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// lookupEntry is a line of the json output of the lookup command.
+type lookupEntry struct {
+	*kvfile.JSONLEntry
+	Found bool `json:"found"`
+}
+
+// lookupCommand builds the lookup command.
+//
+// Exits with 2 on errors and 1 if --fail-on-miss is set and a key was not found.
+func (k *kvfileCli) lookupCommand() *cli.Command {
+	format := formatText
+	var missMarker string
+	var failOnMiss bool
+	return &cli.Command{
+		Name:  "lookup",
+		Usage: "Read keys from stdin one per line and print the value for each line in order.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "format",
+				Usage:       "output format: text (one value per line), or json (one object per line with key, found, and value)",
+				Value:       format,
+				Destination: &format,
+			},
+			&cli.StringFlag{
+				Name:        "miss",
+				Usage:       "line to print in the text format for keys which are not found",
+				Destination: &missMarker,
+			},
+			&cli.BoolFlag{
+				Name:        "fail-on-miss",
+				Usage:       "exit 1 if a key is not found",
+				Destination: &failOnMiss,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if format != formatText && format != formatJSON {
+				return cli.Exit(fmt.Sprintf("invalid --format: %q: expected text or json", format), 2)
+			}
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+
+			misses, err := k.lookupKeys(reader, format, missMarker)
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+			if failOnMiss && misses != 0 {
+				return cli.Exit("", 1)
+			}
+			return nil
+		},
+	}
+}
+
+// lookupKeys reads keys from stdin one per line and prints the value for each
+// line, flushing stdout after each line.
+//
+// The line and value buffers are reused between lookups. Returns the number of
+// keys which were not found.
+func (k *kvfileCli) lookupKeys(reader *kvfile.Reader, format, missMarker string) (int, error) {
+	br := bufio.NewReader(k.stdin)
+	bw := bufio.NewWriter(k.stdout)
+	var enc *json.Encoder
+	if format == formatJSON {
+		enc = json.NewEncoder(bw)
+		enc.SetEscapeHTML(false)
+	}
+
+	var misses int
+	var line, keyBuf []byte
+	var value bytes.Buffer
+	for lineNum := 1; ; lineNum++ {
+		var err error
+		line, err = readLine(br, line[:0])
+		if err == io.EOF && len(line) == 0 {
+			return misses, nil
+		}
+		if err != nil && err != io.EOF {
+			return misses, errors.Wrap(err, "read input")
+		}
+
+		var key []byte
+		key, keyBuf, err = k.decodeKey(line, keyBuf)
+		if err != nil {
+			return misses, errors.Wrapf(err, "invalid key on line %d", lineNum)
+		}
+		value.Reset()
+		found, err := lookupValue(reader, key, &value)
+		if err != nil {
+			return misses, err
+		}
+		if !found {
+			misses++
+		}
+
+		switch {
+		case enc != nil:
+			entry := &lookupEntry{JSONLEntry: kvfile.NewJSONLEntry(key, value.Bytes(), kvfile.JSONLValueAuto), Found: found}
+			if !found {
+				entry.Value, entry.ValueBase64 = nil, nil
+			}
+			err = enc.Encode(entry)
+		case !found:
+			_, err = bw.WriteString(missMarker + "\n")
+		case k.valueEncoding == encodingRaw:
+			if _, err = bw.Write(value.Bytes()); err == nil {
+				err = bw.WriteByte('\n')
+			}
+		default:
+			_, err = bw.WriteString(formatData(value.Bytes(), k.valueEncoding) + "\n")
+		}
+		if err == nil {
+			err = bw.Flush()
+		}
+		if err != nil {
+			return misses, err
+		}
+	}
+}
+
+// readLine appends the next line from the reader to buf without the line
+// ending.
+func readLine(br *bufio.Reader, buf []byte) ([]byte, error) {
+	for {
+		chunk, err := br.ReadSlice('\n')
+		buf = append(buf, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if n := len(buf); n != 0 && buf[n-1] == '\n' {
+			buf = buf[:n-1]
+			if n := len(buf); n != 0 && buf[n-1] == '\r' {
+				buf = buf[:n-1]
+			}
+		}
+		return buf, err
+	}
+}
+
+// decodeKey decodes a key with the key encoding, reusing buf if possible.
+//
+// Returns the key and the buffer to reuse for the next key.
+func (k *kvfileCli) decodeKey(data, buf []byte) ([]byte, []byte, error) {
+	var n int
+	var err error
+	switch k.keyEncoding {
+	case encodingHex:
+		buf = growBuf(buf, hex.DecodedLen(len(data)))
+		n, err = hex.Decode(buf, data)
+	case encodingBase64:
+		buf = growBuf(buf, base64.StdEncoding.DecodedLen(len(data)))
+		n, err = base64.StdEncoding.Decode(buf, data)
+	case encodingBase58:
+		key, err := parseData("key", string(data), k.keyEncoding)
+		return key, buf, err
+	default:
+		return data, buf, nil
+	}
+	if err != nil {
+		return nil, buf, err
+	}
+	return buf[:n], buf, nil
+}
+
+// growBuf returns buf with length n, allocating if the capacity is too small.
+func growBuf(buf []byte, n int) []byte {
+	if cap(buf) < n {
+		return make([]byte, n)
+	}
+	return buf[:n]
+}
+
+// lookupValue reads the value for the key to the buffer.
+//
+// Returns false if the key was not found.
+func lookupValue(reader *kvfile.Reader, key []byte, value *bytes.Buffer) (bool, error) {
+	if len(key) == 0 {
+		return false, nil
+	}
+	_, found, err := reader.ReadTo(key, value)
+	return found, err
+}
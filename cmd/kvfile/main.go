@@ -1,267 +1,492 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 
 	"github.com/aperturerobotics/go-kvfile"
 	kvfile_compress "github.com/aperturerobotics/go-kvfile/compress"
-	b58 "github.com/mr-tron/base58/base58"
 	"github.com/pkg/errors"
 	"github.com/urfave/cli/v2"
 )
 
-var (
-	filePath       string
-	binKeys        bool
-	binValues      bool = true
-	readCompressed bool
-	valueStr       string
-	keyStr         string
-)
+// kvfileCli contains the global flags and the standard streams of the CLI.
+type kvfileCli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	filePath      string
+	binKeys       bool
+	binValues     bool
+	keyEncoding   string
+	valueEncoding string
+	compressed    bool
+	maxValueSize  byteSize
+	maxKeySize    byteSize
+}
 
 func main() {
-	app := &cli.App{
+	os.Exit(run(os.Args, os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the CLI with the arguments and streams and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	k := &kvfileCli{stdin: stdin, stdout: stdout, stderr: stderr, binValues: true}
+	err := k.newApp().Run(args)
+	if err == nil {
+		return 0
+	}
+	if msg := err.Error(); msg != "" {
+		io.WriteString(stderr, msg+"\n")
+	}
+	var exitErr cli.ExitCoder
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// newApp builds the CLI app.
+func (k *kvfileCli) newApp() *cli.App {
+	return &cli.App{
 		Name:  "kvfile",
 		Usage: "A CLI tool for working with key-value files",
 		Authors: []*cli.Author{
 			{Name: "Christian Stewart", Email: "christian@aperture.us"},
 		},
+		Reader:    k.stdin,
+		Writer:    k.stdout,
+		ErrWriter: k.stderr,
+		// errors are printed by run
+		ExitErrHandler: func(c *cli.Context, err error) {},
 		Flags: []cli.Flag{
 			&cli.BoolFlag{
 				Name:        "binary-keys",
-				Usage:       "read and log keys as binary (base58)",
-				Value:       binKeys,
-				Destination: &binKeys,
+				Usage:       "read and log keys as binary (base58), same as --key-encoding=base58",
+				Value:       k.binKeys,
+				Destination: &k.binKeys,
 			},
 			&cli.BoolFlag{
 				Name:        "binary-values",
-				Usage:       "read and log values as binary (base58)",
-				Value:       binValues,
-				Destination: &binValues,
+				Usage:       "read and log values as binary (base58), same as --value-encoding=base58",
+				Value:       k.binValues,
+				Destination: &k.binValues,
+			},
+			&cli.StringFlag{
+				Name:        "key-encoding",
+				Usage:       "encoding to read and log keys with: raw, hex, base64, or base58 (default: from --binary-keys)",
+				Destination: &k.keyEncoding,
+			},
+			&cli.StringFlag{
+				Name:        "value-encoding",
+				Usage:       "encoding to log values with: raw, hex, base64, or base58 (default: from --binary-values)",
+				Destination: &k.valueEncoding,
 			},
 			&cli.StringFlag{
 				Name:        "file",
 				Usage:       "path to the kvfile to read",
 				Aliases:     []string{"f"},
-				Value:       filePath,
-				Destination: &filePath,
+				Value:       k.filePath,
+				Destination: &k.filePath,
 			},
 			&cli.BoolFlag{
 				Name:        "compress",
-				Usage:       "use kvfile compression",
-				Value:       readCompressed,
-				Destination: &readCompressed,
+				Usage:       "write compressed kvfiles and force reading as a compressed kvfile (detected automatically)",
+				Value:       k.compressed,
+				Destination: &k.compressed,
+			},
+			&cli.GenericFlag{
+				Name:  "max-value-size",
+				Usage: "maximum size of the values to read, for example 512M or 2G (default: 1e9 bytes)",
+				Value: &k.maxValueSize,
 			},
+			&cli.GenericFlag{
+				Name:  "max-key-size",
+				Usage: "maximum size of the index entries to read, which limits the key size, for example 4K (default: 2K)",
+				Value: &k.maxKeySize,
+			},
+		},
+		Before: func(c *cli.Context) error {
+			return k.resolveEncodings()
 		},
 		Commands: []*cli.Command{
-			{
-				Name:  "count",
-				Usage: "Print the number of keys in a k/v file.",
-				Action: func(c *cli.Context) error {
-					reader, rel, err := openKVFile(filePath)
-					if rel != nil {
-						defer rel()
-					}
-					if err != nil {
-						return err
-					}
+			k.countCommand(),
+			k.keysCommand(),
+			k.valuesCommand(),
+			k.getCommand(),
+			k.lookupCommand(),
+			k.sizeCommand(),
+			k.entryCommand(),
+			k.catCommand(),
+			k.writeCommand(),
+			k.scanCommand(),
+			k.rangeCommand(),
+			k.grepCommand(),
+			k.sampleCommand(),
+			k.existsCommand(),
+			k.exportCommand(),
+			k.importCommand(),
+			k.importCSVCommand(),
+			k.fromTarCommand(),
+			k.toTarCommand(),
+			k.mergeCommand(),
+			k.copyCommand(),
+			k.splitCommand(),
+			k.setCommand(),
+			k.deleteCommand(),
+			k.editCommand(),
+			k.pruneCommand(),
+			k.replCommand(),
+			k.verifyCommand(),
+			k.hashCommand(),
+			k.validateKeysCommand(),
+			k.indexDumpCommand(),
+			k.diffCommand(),
+			k.convertCommand(),
+		},
+	}
+}
 
-					numKeys := reader.Size()
-					fmt.Printf("%d\n", numKeys)
-					return nil
-				},
-			},
-			{
-				Name:  "keys",
-				Usage: "Print all keys in a k/v file in sorted order.",
-				Action: func(c *cli.Context) error {
-					reader, rel, err := openKVFile(filePath)
-					if rel != nil {
-						defer rel()
-					}
-					if err != nil {
-						return err
-					}
+// resolveEncodings sets the key and value encodings from the binary flags if
+// unset and checks them.
+func (k *kvfileCli) resolveEncodings() error {
+	if k.keyEncoding == "" {
+		k.keyEncoding = encodingRaw
+		if k.binKeys {
+			k.keyEncoding = encodingBase58
+		}
+	}
+	if k.valueEncoding == "" {
+		k.valueEncoding = encodingRaw
+		if k.binValues {
+			k.valueEncoding = encodingBase58
+		}
+	}
+	if err := checkEncoding("--key-encoding", k.keyEncoding); err != nil {
+		return err
+	}
+	return checkEncoding("--value-encoding", k.valueEncoding)
+}
 
-					return iterateAndPrintKeys(reader)
-				},
+// countCommand builds the count command.
+func (k *kvfileCli) countCommand() *cli.Command {
+	var prefix string
+	var skipHidden bool
+	return &cli.Command{
+		Name:  "count",
+		Usage: "Print the number of index entries in a k/v file, including tombstones and expired entries unless --skip-hidden is set.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "prefix",
+				Usage:       "only count the index entries with the prefix, including tombstones and expired entries",
+				Destination: &prefix,
 			},
-			{
-				Name:  "values",
-				Usage: "Print all key-value pairs in a k/v file.",
-				Action: func(c *cli.Context) error {
-					reader, rel, err := openKVFile(filePath)
-					if rel != nil {
-						defer rel()
-					}
-					if err != nil {
-						return err
-					}
-
-					return printAll(reader)
-				},
+			&cli.BoolFlag{
+				Name:        "skip-hidden",
+				Usage:       "do not count tombstones and expired entries, scanning the matching entries",
+				Destination: &skipHidden,
 			},
-			{
-				Name:  "get",
-				Usage: "Get the value for a specific key.",
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:        "key",
-						Usage:       "the key to look up",
-						Value:       keyStr,
-						Destination: &keyStr,
-					},
-				},
-				Action: func(c *cli.Context) error {
-					if keyStr == "" {
-						return fmt.Errorf("please provide a key")
-					}
+		},
+		Action: func(c *cli.Context) error {
+			prefixKey, err := k.parseKey("prefix", prefix)
+			if err != nil {
+				return err
+			}
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
 
-					reader, rel, err := openKVFile(filePath)
-					if rel != nil {
-						defer rel()
-					}
-					if err != nil {
-						return err
-					}
+			var numKeys uint64
+			switch {
+			case skipHidden:
+				numKeys, err = countVisibleKeys(reader, prefixKey)
+			case len(prefixKey) == 0:
+				numKeys = reader.Size()
+			default:
+				numKeys, err = reader.CountPrefix(prefixKey)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(k.stdout, "%d\n", numKeys)
+			return nil
+		},
+	}
+}
 
-					val, found, err := reader.Get([]byte(keyStr))
-					if err != nil {
-						return err
-					}
-					if !found {
-						return errors.Errorf("Key %q not found.\n", keyStr)
-					}
-					printData(val, binValues)
-					return nil
-				},
+// keysCommand builds the keys command.
+func (k *kvfileCli) keysCommand() *cli.Command {
+	var prefix string
+	var offset, limit int
+	var format outputFormat
+	return &cli.Command{
+		Name:  "keys",
+		Usage: "Print all keys in a k/v file in sorted order.",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:        "prefix",
+				Usage:       "only print the keys with the prefix",
+				Destination: &prefix,
 			},
-			{
-				Name:  "write",
-				Usage: "Write a new kvfile from JSON input.",
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:        "json",
-						Usage:       "the JSON data to write",
-						Value:       valueStr,
-						Destination: &valueStr,
-					},
-				},
-				Action: func(c *cli.Context) error {
-					if filePath == "" {
-						return fmt.Errorf("please provide a file path")
-					}
-					if valueStr == "" {
-						return fmt.Errorf("please provide JSON data to write")
-					}
-
-					var data map[string]string
-					err := json.Unmarshal([]byte(valueStr), &data)
-					if err != nil {
-						return err
-					}
+			&cli.IntFlag{
+				Name:        "offset",
+				Usage:       "number of index entries to skip, including tombstones and expired entries",
+				Destination: &offset,
+			},
+			&cli.IntFlag{
+				Name:        "limit",
+				Usage:       "maximum number of index entries to list, printing the next offset to stderr if more remain: tombstones and expired entries are not printed, so a page can be short or empty (default: no limit)",
+				Destination: &limit,
+			},
+		}, format.flags()...),
+		Action: func(c *cli.Context) error {
+			if offset < 0 {
+				return errors.Errorf("invalid --offset: %v", offset)
+			}
+			if limit < 0 {
+				return errors.Errorf("invalid --limit: %v", limit)
+			}
+			prefixKey, err := k.parseKey("prefix", prefix)
+			if err != nil {
+				return err
+			}
+			printer, err := k.newEntryPrinter(&format)
+			if err != nil {
+				return err
+			}
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
 
-					file, err := os.Create(filePath)
-					if err != nil {
-						return err
-					}
-					defer file.Close()
+			start, end, err := reader.SearchPrefixRange(prefixKey)
+			if err != nil {
+				return err
+			}
+			// jump to the offset within the prefix range
+			start = min(start+uint64(offset), end)
+			more := limit != 0 && end-start > uint64(limit)
+			if more {
+				end = start + uint64(limit)
+			}
+			if err := printKeys(reader, start, end, printer); err != nil {
+				return err
+			}
+			if err := printer.flush(); err != nil {
+				return err
+			}
+			if more {
+				fmt.Fprintf(k.stderr, "next offset: %d\n", offset+limit)
+			}
+			return nil
+		},
+	}
+}
 
-					keys := make([][]byte, 0, len(data))
-					for k := range data {
-						keys = append(keys, []byte(k))
-					}
+// valuesCommand builds the values command.
+func (k *kvfileCli) valuesCommand() *cli.Command {
+	var format outputFormat
+	return &cli.Command{
+		Name:  "values",
+		Usage: "Print all key-value pairs in a k/v file.",
+		Flags: format.flags(),
+		Action: func(c *cli.Context) error {
+			printer, err := k.newEntryPrinter(&format)
+			if err != nil {
+				return err
+			}
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
 
-					return kvfile.Write(file, keys, func(wr io.Writer, key []byte) (uint64, error) {
-						val := data[string(key)]
-						n, err := wr.Write([]byte(val))
-						return uint64(n), err
-					})
-				},
-			},
+			printed, err := printAll(reader, printer)
+			if err != nil {
+				return err
+			}
+			if printed == 0 && format.format == formatText && !format.print0 {
+				fmt.Fprintln(k.stdout, "No key-value pairs found.")
+				return nil
+			}
+			return printer.flush()
 		},
 	}
+}
 
-	err := app.Run(os.Args)
-	if err != nil {
-		os.Stderr.WriteString(err.Error() + "\n")
-		os.Exit(1)
+// getCommand builds the get command.
+func (k *kvfileCli) getCommand() *cli.Command {
+	var keyIn keyInput
+	var outputPath string
+	var meta bool
+	return &cli.Command{
+		Name:  "get",
+		Usage: "Get the value for a specific key.",
+		Flags: append(keyIn.flags("the key to look up"),
+			&cli.StringFlag{
+				Name:        "output",
+				Usage:       "path to write the raw value to, - for stdout (default: print encoded value)",
+				Destination: &outputPath,
+			},
+			&cli.BoolFlag{
+				Name:        "meta",
+				Usage:       "print the index entry as JSON instead of the value",
+				Destination: &meta,
+			},
+		),
+		Action: func(c *cli.Context) error {
+			if meta && outputPath != "" {
+				return errors.New("cannot use --meta with --output")
+			}
+			key, err := k.parseKeyInput(&keyIn)
+			if err != nil {
+				return err
+			}
+			keyStr := formatData(key, k.keyEncoding)
+
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
+
+			if meta {
+				return k.printEntryInfo(reader, key)
+			}
+			if outputPath != "" {
+				readTo := func(out io.Writer) error {
+					_, found, err := reader.ReadTo(key, out)
+					if err == nil && !found {
+						err = errors.Errorf("Key %q not found.\n", keyStr)
+					}
+					return err
+				}
+				if outputPath == "-" {
+					return readTo(k.stdout)
+				}
+				return createOutputFile(outputPath, readTo)
+			}
+
+			val, found, err := reader.Get(key)
+			if err != nil {
+				return err
+			}
+			if !found {
+				return errors.Errorf("Key %q not found.\n", keyStr)
+			}
+			k.printData(val, k.valueEncoding)
+			return nil
+		},
 	}
 }
 
-// shared helper to open kvfile based on flags
-func openKVFile(filePath string) (*kvfile.Reader, func(), error) {
-	if filePath == "" {
+// openKVFile opens the kvfile at the file path flag.
+func (k *kvfileCli) openKVFile() (*kvfile.Reader, func(), error) {
+	if k.filePath == "" {
 		return nil, nil, errors.New("please provide a file path")
 	}
+	reader, rel, _, err := k.openKVFilePath(k.filePath, k.compressed)
+	return reader, rel, err
+}
 
+// openKVFilePath opens the kvfile at the path.
+//
+// Detects if the file is compressed unless forceCompressed is set. Uses the
+// size limit flags. Returns if the file is compressed.
+func (k *kvfileCli) openKVFilePath(filePath string, forceCompressed bool) (*kvfile.Reader, func(), bool, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 
-	if readCompressed {
-		reader, readerRel, err := kvfile_compress.BuildCompressReader(file)
-		if err != nil {
-			_ = file.Close()
-			return nil, nil, err
+	opts := &kvfile_compress.CompressReaderOptions{
+		ReaderOptions: kvfile.ReaderOptions{
+			MaxValueSize:      uint64(k.maxValueSize),
+			MaxIndexEntrySize: uint64(k.maxKeySize),
+		},
+	}
+	var reader *kvfile.Reader
+	var readerRel func()
+	compressed := forceCompressed
+	if forceCompressed {
+		reader, readerRel, err = kvfile_compress.BuildCompressReaderWithOptions(file, opts)
+	} else {
+		var fi os.FileInfo
+		fi, err = file.Stat()
+		if err == nil {
+			reader, readerRel, compressed, err = kvfile_compress.BuildAutoReaderWithOptions(file, uint64(fi.Size()), opts)
 		}
-		return reader, func() {
-			readerRel()
-			_ = file.Close()
-		}, nil
 	}
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, false, err
+	}
+	return reader, func() {
+		readerRel()
+		_ = file.Close()
+	}, compressed, nil
+}
 
-	reader, err := kvfile.BuildReaderWithFile(file)
-	return reader, nil, err
+// countVisibleKeys counts the keys with the prefix by scanning the entries.
+//
+// Entries hidden by the reader (tombstones and expired entries) are skipped.
+func countVisibleKeys(reader *kvfile.Reader, prefix []byte) (uint64, error) {
+	var count uint64
+	err := reader.ScanPrefixEntries(prefix, func(indexEntry *kvfile.IndexEntry, indexEntryIdx int) error {
+		count++
+		return nil
+	})
+	return count, err
 }
 
-func iterateAndPrintKeys(reader *kvfile.Reader) error {
-	size := reader.Size()
-	for i := uint64(0); i < size; i++ {
+// printKeys prints the keys of the index entries in the range [start, end).
+//
+// Entries hidden by the reader (tombstones and expired entries) are skipped.
+func printKeys(reader *kvfile.Reader, start, end uint64, printer *entryPrinter) error {
+	for i := start; i < end; i++ {
 		indexEntry, err := reader.ReadIndexEntry(i)
 		if err != nil {
 			return err
 		}
-		printData(indexEntry.GetKey(), binKeys)
+		if reader.IsEntryHidden(indexEntry) {
+			continue
+		}
+		if err := printer.printKey(indexEntry.GetKey()); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func printData(key []byte, bin bool) {
-	var output string
-	if bin {
-		output = b58.Encode(key)
-	} else {
-		output = string(key)
-	}
-	os.Stdout.WriteString(output + "\n")
+func (k *kvfileCli) printData(data []byte, encoding string) {
+	io.WriteString(k.stdout, formatData(data, encoding)+"\n")
 }
 
-func printAll(reader *kvfile.Reader) error {
-	size := reader.Size()
-	if size == 0 {
-		fmt.Println("No key-value pairs found.")
-		return nil
-	}
-
-	for i := uint64(0); i < size; i++ {
-		indexEntry, err := reader.ReadIndexEntry(i)
+// printAll prints the key/value pairs and returns the number printed.
+//
+// Entries hidden by the reader (tombstones and expired entries) are skipped.
+func printAll(reader *kvfile.Reader, printer *entryPrinter) (uint64, error) {
+	var printed uint64
+	err := reader.ScanPrefixEntries(nil, func(indexEntry *kvfile.IndexEntry, indexEntryIdx int) error {
+		val, err := reader.GetWithEntry(indexEntry, indexEntryIdx)
 		if err != nil {
 			return err
 		}
-		key := indexEntry.GetKey()
-		printData(key, binKeys)
-
-		val, err := reader.GetWithEntry(indexEntry, int(i))
-		if err != nil {
-			return err
-		}
-		printData(val, binValues)
-	}
-
-	return nil
+		printed++
+		return printer.printEntry(indexEntry.GetKey(), val, true)
+	})
+	return printed, err
 }
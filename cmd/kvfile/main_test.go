@@ -0,0 +1,2005 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aperturerobotics/go-kvfile"
+	kvfile_compress "github.com/aperturerobotics/go-kvfile/compress"
+)
+
+// runCli runs the CLI with the stdin and arguments.
+//
+// Returns stdout, stderr, and the exit code.
+func runCli(t testing.TB, stdin io.Reader, args ...string) (string, string, int) {
+	if stdin == nil {
+		stdin = strings.NewReader("")
+	}
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"kvfile"}, args...), stdin, &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+// openTestFile opens the kvfile at the path.
+func openTestFile(t testing.TB, path string) *kvfile.Reader {
+	return openTestFileWithOptions(t, path, kvfile.ReaderOptions{})
+}
+
+// openTestFileWithOptions opens the kvfile at the path with the reader options.
+func openTestFileWithOptions(t testing.TB, path string, opts kvfile.ReaderOptions) *kvfile.Reader {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := kvfile.BuildReaderWithOptions(bytes.NewReader(data), uint64(len(data)), opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return rdr
+}
+
+// writeEntriesTestFile writes a kvfile with the write callback to a temp file.
+func writeEntriesTestFile(t testing.TB, write func(wr *kvfile.Writer) error) string {
+	var buf bytes.Buffer
+	wr := kvfile.NewWriter(&buf)
+	if err := write(wr); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	path := filepath.Join(t.TempDir(), "test.kv")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	return path
+}
+
+// readTestEntries reads the index entries of the kvfile including tombstones.
+func readTestEntries(t testing.TB, path string) map[string]*kvfile.IndexEntry {
+	rdr := openTestFileWithOptions(t, path, kvfile.ReaderOptions{ExposeTombstones: true})
+	entries := make(map[string]*kvfile.IndexEntry)
+	for i := uint64(0); i < rdr.Size(); i++ {
+		entry, err := rdr.ReadIndexEntry(i)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		entries[string(entry.GetKey())] = entry
+	}
+	return entries
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.kv")
+
+	// a few MB of JSON through a pipe
+	value := strings.Repeat("v", 1000)
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = io.WriteString(pw, "{")
+		for i := 0; i < 4000; i++ {
+			if i != 0 {
+				_, _ = io.WriteString(pw, ",")
+			}
+			_, _ = fmt.Fprintf(pw, "%q:%q", fmt.Sprintf("key-%05d", i), value)
+		}
+		_, _ = io.WriteString(pw, "}")
+		_ = pw.Close()
+	}()
+	if _, stderr, code := runCli(t, pr, "-f", path, "write"); code != 0 {
+		t.Fatalf("write failed: %v %s", code, stderr)
+	}
+	rdr := openTestFile(t, path)
+	if rdr.Size() != 4000 {
+		t.Fatalf("unexpected size: %v", rdr.Size())
+	}
+	if val, found, err := rdr.Get([]byte("key-01234")); err != nil || !found || string(val) != value {
+		t.Fatalf("unexpected value: %v %v", found, err)
+	}
+
+	// --input and --json
+	inputPath := filepath.Join(dir, "input.json")
+	if err := os.WriteFile(inputPath, []byte(`{"a":"1","b":"2"}`), 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, stderr, code := runCli(t, nil, "-f", path, "write", "--input", inputPath); code != 0 {
+		t.Fatalf("write failed: %v %s", code, stderr)
+	}
+	if stdout, _, code := runCli(t, nil, "-f", path, "--binary-values=false", "get", "--key", "b"); code != 0 || stdout != "2\n" {
+		t.Fatalf("unexpected get output: %v %q", code, stdout)
+	}
+	if _, stderr, code := runCli(t, nil, "-f", path, "write", "--json", `{"c":"3"}`); code != 0 {
+		t.Fatalf("write failed: %v %s", code, stderr)
+	}
+	if rdr := openTestFile(t, path); rdr.Size() != 1 {
+		t.Fatalf("unexpected size: %v", rdr.Size())
+	}
+
+	// empty object
+	if _, stderr, code := runCli(t, strings.NewReader("{}"), "-f", path, "write"); code != 0 {
+		t.Fatalf("write failed: %v %s", code, stderr)
+	}
+	if rdr := openTestFile(t, path); rdr.Size() != 0 {
+		t.Fatalf("unexpected size: %v", rdr.Size())
+	}
+
+	// invalid JSON and I/O errors are distinguished
+	if _, stderr, code := runCli(t, strings.NewReader("{"), "-f", path, "write"); code != 1 || !strings.Contains(stderr, "invalid JSON input") {
+		t.Fatalf("expected invalid JSON error: %v %s", code, stderr)
+	}
+	if _, stderr, code := runCli(t, nil, "-f", path, "write", "--input", filepath.Join(dir, "missing.json")); code != 1 || !strings.Contains(stderr, "read input") {
+		t.Fatalf("expected read input error: %v %s", code, stderr)
+	}
+}
+
+func TestWriteCompress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.kv")
+	input := `{"a":"1","b":"` + strings.Repeat("2", 10000) + `"}`
+	if _, stderr, code := runCli(t, strings.NewReader(input), "-f", path, "--compress", "write", "--compress-level", "fastest"); code != 0 {
+		t.Fatalf("write failed: %v %s", code, stderr)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !kvfile_compress.IsCompressed(data) || len(data) > 1000 {
+		t.Fatalf("expected compressed file: %v bytes", len(data))
+	}
+
+	// read with --compress and with auto detection
+	for _, args := range [][]string{{"--compress"}, nil} {
+		args = append(append([]string{"-f", path, "--binary-values=false"}, args...), "get", "--key", "a")
+		if stdout, stderr, code := runCli(t, nil, args...); code != 0 || stdout != "1\n" {
+			t.Fatalf("unexpected get output: %v %q %s", code, stdout, stderr)
+		}
+	}
+
+	if _, _, code := runCli(t, strings.NewReader("{}"), "-f", path, "--compress", "write", "--compress-level", "invalid"); code != 1 {
+		t.Fatal("expected error with invalid compress level")
+	}
+	if _, _, code := runCli(t, strings.NewReader("{}"), "-f", path, "write", "--compress-level", "best"); code != 1 {
+		t.Fatal("expected error with compress level without --compress")
+	}
+}
+
+// writeTestFile writes a kvfile with the key/value pairs to a temp file.
+func writeTestFile(t testing.TB, vals map[string]string) string {
+	path := filepath.Join(t.TempDir(), "test.kv")
+	keys := make([][]byte, 0, len(vals))
+	for key := range vals {
+		keys = append(keys, []byte(key))
+	}
+	var buf bytes.Buffer
+	err := kvfile.Write(&buf, keys, func(wr io.Writer, key []byte) (uint64, error) {
+		n, err := io.WriteString(wr, vals[string(key)])
+		return uint64(n), err
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	return path
+}
+
+func TestScan(t *testing.T) {
+	path := writeTestFile(t, map[string]string{
+		"a/1": "v1",
+		"a/2": "v2",
+		"a/3": "v3",
+		"b/1": "v4",
+	})
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"--prefix", "a/"}, "a/1\nv1\na/2\nv2\na/3\nv3\n"},
+		{nil, "a/1\nv1\na/2\nv2\na/3\nv3\nb/1\nv4\n"},
+		{[]string{"--prefix", "a/", "--keys-only"}, "a/1\na/2\na/3\n"},
+		{[]string{"--prefix", "a/", "--limit", "2"}, "a/1\nv1\na/2\nv2\n"},
+		{[]string{"--prefix", "c/"}, ""},
+	}
+	for _, tc := range tests {
+		args := append([]string{"-f", path, "--binary-values=false", "scan"}, tc.args...)
+		stdout, stderr, code := runCli(t, nil, args...)
+		if code != 0 || stdout != tc.expected {
+			t.Fatalf("unexpected scan output for %v: %v %q %s", tc.args, code, stdout, stderr)
+		}
+	}
+}
+
+func TestExists(t *testing.T) {
+	path := writeTestFile(t, map[string]string{"a": "1"})
+	tests := []struct {
+		args     []string
+		code     int
+		expected string
+	}{
+		{[]string{"-f", path, "exists", "--key", "a"}, 0, ""},
+		{[]string{"-f", path, "exists", "--key", "b"}, 1, ""},
+		{[]string{"-f", path, "exists", "--key", "a", "--verbose"}, 0, "a: found\n"},
+		{[]string{"-f", path, "exists", "--key", "b", "--verbose"}, 1, "b: not found\n"},
+		{[]string{"-f", path + ".missing", "exists", "--key", "a"}, 2, ""},
+		{[]string{"-f", path, "exists"}, 2, ""},
+	}
+	for _, tc := range tests {
+		stdout, stderr, code := runCli(t, nil, tc.args...)
+		if code != tc.code || stdout != tc.expected {
+			t.Fatalf("unexpected exists result for %v: %v %q %s", tc.args, code, stdout, stderr)
+		}
+		if code != 2 && stderr != "" {
+			t.Fatalf("unexpected stderr for %v: %s", tc.args, stderr)
+		}
+	}
+}
+
+// contentDigest computes the content digest of the kvfile at the path.
+func contentDigest(t testing.TB, path string) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, rel, _, err := kvfile_compress.BuildAutoReader(f, uint64(fi.Size()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rel()
+	digest, err := kvfile.ContentDigest(rdr, sha256.New)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return digest
+}
+
+func TestExportImport(t *testing.T) {
+	path := writeTestFile(t, map[string]string{
+		"a":          "1",
+		"b\xff\x00":  "binary key",
+		"c":          "binary \xfe value",
+		"empty":      "",
+		"multi-line": "line 1\nline 2",
+		"html":       "<&>",
+	})
+	dir := t.TempDir()
+	exportPath := filepath.Join(dir, "export.jsonl")
+	if _, stderr, code := runCli(t, nil, "-f", path, "export", "--output", exportPath); code != 0 {
+		t.Fatalf("export failed: %v %s", code, stderr)
+	}
+	stdout, _, code := runCli(t, nil, "-f", path, "export")
+	exported, err := os.ReadFile(exportPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if code != 0 || stdout != string(exported) {
+		t.Fatalf("expected export to stdout to match the output file: %v", code)
+	}
+	if lines := strings.Count(stdout, "\n"); lines != 6 {
+		t.Fatalf("expected one line per entry: %v\n%s", lines, stdout)
+	}
+
+	importPath := filepath.Join(dir, "import.kv")
+	if _, stderr, code := runCli(t, strings.NewReader(stdout), "-f", importPath, "import"); code != 0 {
+		t.Fatalf("import failed: %v %s", code, stderr)
+	}
+	if !bytes.Equal(contentDigest(t, path), contentDigest(t, importPath)) {
+		t.Fatal("expected imported content to match the original")
+	}
+
+	// duplicate keys report the line number and remove the output
+	dupPath := filepath.Join(dir, "dup.kv")
+	input := `{"key":"a","value":"1"}` + "\n" + `{"key":"b","value":"2"}` + "\n" + `{"key":"a","value":"3"}` + "\n"
+	_, stderr, code := runCli(t, strings.NewReader(input), "-f", dupPath, "import")
+	if code != 1 || !strings.Contains(stderr, "line 3") {
+		t.Fatalf("expected duplicate key error with line number: %v %s", code, stderr)
+	}
+	if _, err := os.Stat(dupPath); !os.IsNotExist(err) {
+		t.Fatalf("expected output to be removed: %v", err)
+	}
+	if _, stderr, code := runCli(t, strings.NewReader("{\n"), "-f", dupPath, "import"); code != 1 || !strings.Contains(stderr, "line 1") {
+		t.Fatalf("expected invalid JSON error with line number: %v %s", code, stderr)
+	}
+}
+
+func TestGetOutput(t *testing.T) {
+	value := "binary\x00value\n"
+	path := writeTestFile(t, map[string]string{"a": value})
+	outPath := filepath.Join(t.TempDir(), "out.bin")
+	if _, stderr, code := runCli(t, nil, "-f", path, "get", "--key", "a", "--output", outPath); code != 0 {
+		t.Fatalf("get failed: %v %s", code, stderr)
+	}
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(data) != value {
+		t.Fatalf("unexpected output file: %q", string(data))
+	}
+	if stdout, stderr, code := runCli(t, nil, "-f", path, "get", "--key", "a", "--output", "-"); code != 0 || stdout != value {
+		t.Fatalf("unexpected raw stdout: %v %q %s", code, stdout, stderr)
+	}
+
+	// missing keys do not create the output file
+	missingPath := filepath.Join(t.TempDir(), "missing.bin")
+	if _, _, code := runCli(t, nil, "-f", path, "get", "--key", "b", "--output", missingPath); code != 1 {
+		t.Fatalf("expected error for missing key: %v", code)
+	}
+	if _, err := os.Stat(missingPath); !os.IsNotExist(err) {
+		t.Fatalf("expected output to not exist: %v", err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	inputs := []string{
+		writeTestFile(t, map[string]string{"a": "1", "shared": "first"}),
+		writeTestFile(t, map[string]string{"b": "2", "shared": "second"}),
+		writeTestFile(t, map[string]string{"c": "3", "shared": "last"}),
+	}
+	// one compressed input
+	compressedPath := filepath.Join(t.TempDir(), "compressed.kv")
+	if _, stderr, code := runCli(t, nil, "-f", inputs[2], "export", "--output", compressedPath+".jsonl"); code != 0 {
+		t.Fatalf("export failed: %v %s", code, stderr)
+	}
+	if _, stderr, code := runCli(t, nil, "-f", compressedPath, "--compress", "import", "--input", compressedPath+".jsonl"); code != 0 {
+		t.Fatalf("import failed: %v %s", code, stderr)
+	}
+	inputs[2] = compressedPath
+
+	outPath := filepath.Join(t.TempDir(), "out.kv")
+	for policy, expected := range map[string]string{"first-wins": "first", "last-wins": "last"} {
+		args := append([]string{"-f", outPath, "merge", "--on-conflict", policy}, inputs...)
+		if _, stderr, code := runCli(t, nil, args...); code != 0 {
+			t.Fatalf("merge failed: %v %s", code, stderr)
+		}
+		stdout, _, _ := runCli(t, nil, "-f", outPath, "--binary-values=false", "values")
+		if want := "a\n1\nb\n2\nc\n3\nshared\n" + expected + "\n"; stdout != want {
+			t.Fatalf("unexpected merged values for %s: %q", policy, stdout)
+		}
+	}
+
+	args := append([]string{"-f", outPath, "merge"}, inputs...)
+	if _, stderr, code := runCli(t, nil, args...); code != 1 || !strings.Contains(stderr, `"shared"`) {
+		t.Fatalf("expected conflict error: %v %s", code, stderr)
+	}
+	if _, err := os.Stat(outPath); !os.IsNotExist(err) {
+		t.Fatalf("expected output to be removed: %v", err)
+	}
+
+	// compressed output
+	args = append([]string{"-f", outPath, "--compress", "merge", "--on-conflict", "first-wins"}, inputs...)
+	if _, stderr, code := runCli(t, nil, args...); code != 0 {
+		t.Fatalf("merge failed: %v %s", code, stderr)
+	}
+	if stdout, _, _ := runCli(t, nil, "-f", outPath, "count"); stdout != "4\n" {
+		t.Fatalf("unexpected count: %q", stdout)
+	}
+
+	if _, _, code := runCli(t, nil, "-f", inputs[0], "merge", inputs[0], inputs[1]); code != 1 {
+		t.Fatal("expected error merging into an input")
+	}
+}
+
+func TestMergeEntryAttrs(t *testing.T) {
+	expires := time.UnixMilli(4102444800000)
+	older := writeTestFile(t, map[string]string{"deleted": "old", "expires": "old", "meta": "old"})
+	newer := writeEntriesTestFile(t, func(wr *kvfile.Writer) error {
+		if err := wr.WriteTombstone([]byte("deleted")); err != nil {
+			return err
+		}
+		if err := wr.WriteValueWithExpiry([]byte("expires"), strings.NewReader("new"), expires); err != nil {
+			return err
+		}
+		return wr.WriteValueBytesMeta([]byte("meta"), []byte("new"), []byte("test-meta"))
+	})
+
+	outPath := filepath.Join(t.TempDir(), "out.kv")
+	if _, stderr, code := runCli(t, nil, "-f", outPath, "merge", "--on-conflict", "last-wins", older, newer); code != 0 {
+		t.Fatalf("merge failed: %v %s", code, stderr)
+	}
+	entries := readTestEntries(t, outPath)
+	if !entries["deleted"].GetTombstone() {
+		t.Fatal("expected the tombstone to win the conflict")
+	}
+	if got := entries["expires"].GetExpiresUnixMs(); got != uint64(expires.UnixMilli()) {
+		t.Fatalf("unexpected merged expiry: %v", got)
+	}
+	if got := string(entries["meta"].GetMeta()); got != "test-meta" {
+		t.Fatalf("unexpected merged meta: %q", got)
+	}
+	if _, _, code := runCli(t, nil, "-f", outPath, "get", "--key", "deleted"); code == 0 {
+		t.Fatal("expected the deleted key to be missing")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	path := writeTestFile(t, map[string]string{"key-a": "1", "key-b": "2", "key-c": "3"})
+	if stdout, stderr, code := runCli(t, nil, "-f", path, "verify", "--deep"); code != 0 || stdout != "" || stderr != "" {
+		t.Fatalf("expected quiet success: %v %q %q", code, stdout, stderr)
+	}
+
+	// flip key-b to key-d in the index
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	data[bytes.Index(data, []byte("key-b"))+4] = 'd'
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	_, stderr, code := runCli(t, nil, "-f", path, "verify")
+	if code != 1 || !strings.Contains(stderr, `verify entry 2 at offset `) || !strings.Contains(stderr, `"key-c" is out of order`) {
+		t.Fatalf("expected verify failure: %v %s", code, stderr)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	vals := map[string]string{"a/1": "1", "a/2": "2", "b/1": "3"}
+	oldPath := writeTestFile(t, vals)
+	// compressed copy of the old file
+	compressedPath := filepath.Join(t.TempDir(), "compressed.kv")
+	if _, stderr, code := runCli(t, nil, "-f", compressedPath, "--compress", "write", "--json", `{"a/1":"1","a/2":"2","b/1":"3"}`); code != 0 {
+		t.Fatalf("write failed: %v %s", code, stderr)
+	}
+	disjointPath := writeTestFile(t, map[string]string{"c": "4"})
+	changedPath := writeTestFile(t, map[string]string{"a/1": "1", "a/2": "22", "b/1": "3"})
+
+	tests := []struct {
+		args     []string
+		code     int
+		expected string
+	}{
+		{[]string{"diff", oldPath, compressedPath}, 0, ""},
+		{[]string{"diff", oldPath, disjointPath}, 1, "- a/1\n- a/2\n- b/1\n+ c\n"},
+		{[]string{"diff", oldPath, changedPath}, 1, "~ a/2\n"},
+		{[]string{"diff", "--values", changedPath, compressedPath}, 1, "~ a/2 (2 -> 1 bytes)\n"},
+		{[]string{"diff", "--prefix", "b/", oldPath, changedPath}, 0, ""},
+		{[]string{"diff", "--prefix", "a/", "--values", oldPath, disjointPath}, 1, "- a/1 (1 bytes)\n- a/2 (1 bytes)\n"},
+		{[]string{"diff", "--json", compressedPath, changedPath}, 1, `{"change":"changed","key":"a/2","old_size":1,"new_size":2}` + "\n"},
+		{[]string{"diff", "--json", disjointPath, changedPath}, 1, `{"change":"added","key":"a/1","new_size":1}` + "\n" +
+			`{"change":"added","key":"a/2","new_size":2}` + "\n" +
+			`{"change":"added","key":"b/1","new_size":1}` + "\n" +
+			`{"change":"removed","key":"c","old_size":1}` + "\n"},
+		{[]string{"diff", oldPath}, 2, ""},
+		{[]string{"diff", oldPath, oldPath + ".missing"}, 2, ""},
+	}
+	for _, tc := range tests {
+		stdout, stderr, code := runCli(t, nil, tc.args...)
+		if code != tc.code || stdout != tc.expected {
+			t.Fatalf("unexpected diff result for %v: %v %q %s", tc.args, code, stdout, stderr)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	path := writeTestFile(t, map[string]string{"a": strings.Repeat("1", 1000), "b": strings.Repeat("2", 1000)})
+	dir := t.TempDir()
+	compressedPath := filepath.Join(dir, "compressed.kv")
+	plainPath := filepath.Join(dir, "plain.kv")
+	copyPath := filepath.Join(dir, "copy.kv")
+
+	steps := []struct {
+		in, out, to string
+	}{
+		{path, compressedPath, "compressed"},
+		{compressedPath, plainPath, "plain"},
+		{path, copyPath, "plain"},
+	}
+	for _, step := range steps {
+		stdout, stderr, code := runCli(t, nil, "-f", step.in, "convert", "--output", step.out, "--to", step.to)
+		if code != 0 {
+			t.Fatalf("convert to %s failed: %v %s", step.to, code, stderr)
+		}
+		inInfo, _ := os.Stat(step.in)
+		outInfo, _ := os.Stat(step.out)
+		if expected := fmt.Sprintf("%d -> %d bytes\n", inInfo.Size(), outInfo.Size()); stdout != expected {
+			t.Fatalf("unexpected sizes output: %q != %q", stdout, expected)
+		}
+		if _, stderr, code := runCli(t, nil, "diff", path, step.out); code != 0 {
+			t.Fatalf("converted file differs: %v %s", code, stderr)
+		}
+	}
+	if data, _ := os.ReadFile(compressedPath); !kvfile_compress.IsCompressed(data) {
+		t.Fatal("expected compressed output")
+	}
+	if data, _ := os.ReadFile(plainPath); kvfile_compress.IsCompressed(data) {
+		t.Fatal("expected plain output")
+	}
+
+	if _, stderr, code := runCli(t, nil, "-f", plainPath, "convert", "--output", plainPath, "--to", "compressed"); code != 1 || !strings.Contains(stderr, "--force") {
+		t.Fatalf("expected in place conversion to be refused: %v %s", code, stderr)
+	}
+	if _, stderr, code := runCli(t, nil, "-f", plainPath, "convert", "--output", plainPath, "--to", "compressed", "--force"); code != 0 {
+		t.Fatalf("convert in place failed: %v %s", code, stderr)
+	}
+	if _, stderr, code := runCli(t, nil, "diff", path, plainPath); code != 0 {
+		t.Fatalf("converted file differs: %v %s", code, stderr)
+	}
+	if _, _, code := runCli(t, nil, "-f", path, "convert", "--output", copyPath, "--to", "zip"); code != 1 {
+		t.Fatal("expected invalid format error")
+	}
+}
+
+func TestConvertEntryAttrs(t *testing.T) {
+	expires := time.UnixMilli(4102444800000)
+	path := writeEntriesTestFile(t, func(wr *kvfile.Writer) error {
+		if err := wr.WriteTombstone([]byte("deleted")); err != nil {
+			return err
+		}
+		if err := wr.WriteValueWithExpiry([]byte("expires"), strings.NewReader("val"), expires); err != nil {
+			return err
+		}
+		return wr.WriteValueBytesMeta([]byte("meta"), []byte("val"), []byte("test-meta"))
+	})
+	dir := t.TempDir()
+	compressedPath := filepath.Join(dir, "compressed.kv")
+	plainPath := filepath.Join(dir, "plain.kv")
+	if _, stderr, code := runCli(t, nil, "-f", path, "convert", "--output", compressedPath, "--to", "compressed"); code != 0 {
+		t.Fatalf("convert to compressed failed: %v %s", code, stderr)
+	}
+	if _, stderr, code := runCli(t, nil, "-f", compressedPath, "convert", "--output", plainPath, "--to", "plain"); code != 0 {
+		t.Fatalf("convert to plain failed: %v %s", code, stderr)
+	}
+
+	expected := readTestEntries(t, path)
+	entries := readTestEntries(t, plainPath)
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %v entries: %v", len(expected), len(entries))
+	}
+	for key, want := range expected {
+		got := entries[key]
+		if got.GetTombstone() != want.GetTombstone() || got.GetExpiresUnixMs() != want.GetExpiresUnixMs() || !bytes.Equal(got.GetMeta(), want.GetMeta()) || got.GetSize() != want.GetSize() {
+			t.Fatalf("unexpected converted entry for %s: %v != %v", key, got, want)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	path := writeTestFile(t, map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"})
+	tests := []struct {
+		args     []string
+		code     int
+		expected string
+	}{
+		{[]string{"range"}, 0, "a\nb\nc\nd\n"},
+		{[]string{"range", "--start", "b", "--end", "d"}, 0, "b\nc\n"},
+		{[]string{"range", "--start", "bb"}, 0, "c\nd\n"},
+		{[]string{"range", "--end", "c"}, 0, "a\nb\n"},
+		{[]string{"range", "--start", "c", "--end", "c"}, 0, ""},
+		{[]string{"range", "--start", "b", "--values"}, 0, "b\n2\nc\n3\nd\n4\n"},
+		{[]string{"range", "--start", "b", "--limit", "1"}, 0, "b\n"},
+		{[]string{"--binary-keys", "range", "--end", "2h"}, 0, "2g\n"},
+		{[]string{"range", "--start", "d", "--end", "b"}, 1, ""},
+	}
+	for _, tc := range tests {
+		args := append([]string{"-f", path, "--binary-values=false"}, tc.args...)
+		stdout, stderr, code := runCli(t, nil, args...)
+		if code != tc.code || stdout != tc.expected {
+			t.Fatalf("unexpected range output for %v: %v %q %s", tc.args, code, stdout, stderr)
+		}
+	}
+}
+
+func TestEncodings(t *testing.T) {
+	path := writeTestFile(t, map[string]string{"\xff\x00k": "\x01\x02", "a": "v"})
+	tests := []struct {
+		args     []string
+		code     int
+		expected string
+	}{
+		{[]string{"--key-encoding", "hex", "keys"}, 0, "61\nff006b\n"},
+		{[]string{"--key-encoding", "hex", "--value-encoding", "hex", "get", "--key", "ff006b"}, 0, "0102\n"},
+		{[]string{"--key-encoding", "hex", "--value-encoding", "base64", "scan", "--prefix", "ff"}, 0, "ff006b\nAQI=\n"},
+		{[]string{"--key-encoding", "base64", "exists", "--key", "/wBr"}, 0, ""},
+		{[]string{"--key-encoding", "base58", "--value-encoding", "raw", "get", "--key", "2g"}, 0, "v\n"},
+		{[]string{"--binary-keys", "keys"}, 0, "2g\n2UeqG\n"},
+		{[]string{"--value-encoding", "raw", "values"}, 0, "a\nv\n\xff\x00k\n\x01\x02\n"},
+		{[]string{"--key-encoding", "hex", "get", "--key", "zz"}, 1, ""},
+		{[]string{"--key-encoding", "rot13", "keys"}, 1, ""},
+	}
+	for _, tc := range tests {
+		args := append([]string{"-f", path}, tc.args...)
+		stdout, stderr, code := runCli(t, nil, args...)
+		if code != tc.code || stdout != tc.expected {
+			t.Fatalf("unexpected output for %v: %v %q %s", tc.args, code, stdout, stderr)
+		}
+	}
+
+	_, stderr, _ := runCli(t, nil, "-f", path, "--key-encoding", "hex", "get", "--key", "zz")
+	if !strings.Contains(stderr, "decode --key as hex") {
+		t.Fatalf("expected decode error naming the flag: %s", stderr)
+	}
+}
+
+func TestSetDelete(t *testing.T) {
+	path := writeTestFile(t, map[string]string{"a": "\x00\x01", "b": "2", "c": "3"})
+	dir := t.TempDir()
+	outPath := filepath.Join(dir, "out.kv")
+	valuePath := filepath.Join(dir, "value")
+	if err := os.WriteFile(valuePath, []byte("from-file"), 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	steps := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"-f", path, "set", "--key", "b", "--value", "22", "--output", outPath}, "a\n0001\nb\n3232\nc\n33\n"},
+		{[]string{"-f", outPath, "set", "--key", "d", "--value-file", valuePath, "--in-place"}, "a\n0001\nb\n3232\nc\n33\nd\n66726f6d2d66696c65\n"},
+		{[]string{"-f", outPath, "delete", "--key", "c", "--in-place"}, "a\n0001\nb\n3232\nd\n66726f6d2d66696c65\n"},
+		{[]string{"-f", outPath, "delete", "--key", "missing", "--in-place"}, "a\n0001\nb\n3232\nd\n66726f6d2d66696c65\n"},
+		{[]string{"-f", outPath, "set", "--key", "e", "--value", "", "--in-place"}, "a\n0001\nb\n3232\nd\n66726f6d2d66696c65\ne\n\n"},
+	}
+	for _, step := range steps {
+		if _, stderr, code := runCli(t, nil, step.args...); code != 0 {
+			t.Fatalf("%v failed: %v %s", step.args, code, stderr)
+		}
+		stdout, _, _ := runCli(t, nil, "-f", outPath, "--value-encoding", "hex", "values")
+		if stdout != step.expected {
+			t.Fatalf("unexpected values after %v: %q", step.args, stdout)
+		}
+	}
+	// the input is unchanged
+	if stdout, _, _ := runCli(t, nil, "-f", path, "--value-encoding", "raw", "values"); stdout != "a\n\x00\x01\nb\n2\nc\n3\n" {
+		t.Fatalf("unexpected input values: %q", stdout)
+	}
+
+	errorCases := [][]string{
+		{"-f", outPath, "delete", "--key", "missing", "--in-place", "--strict"},
+		{"-f", outPath, "delete", "--key", "a"},
+		{"-f", outPath, "delete", "--key", "a", "--in-place", "--output", path},
+		{"-f", outPath, "delete", "--key", "a", "--output", outPath},
+		{"-f", outPath, "set", "--key", "a", "--in-place"},
+	}
+	for _, args := range errorCases {
+		if _, _, code := runCli(t, nil, args...); code != 1 {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+
+	// compressed files stay compressed
+	compressedPath := filepath.Join(dir, "compressed.kv")
+	if _, stderr, code := runCli(t, nil, "-f", outPath, "convert", "--output", compressedPath, "--to", "compressed"); code != 0 {
+		t.Fatalf("convert failed: %v %s", code, stderr)
+	}
+	if _, stderr, code := runCli(t, nil, "-f", compressedPath, "delete", "--key", "a", "--in-place"); code != 0 {
+		t.Fatalf("delete failed: %v %s", code, stderr)
+	}
+	if data, _ := os.ReadFile(compressedPath); !kvfile_compress.IsCompressed(data) {
+		t.Fatal("expected compressed output")
+	}
+	if stdout, _, _ := runCli(t, nil, "-f", compressedPath, "keys"); stdout != "b\nd\ne\n" {
+		t.Fatalf("unexpected keys: %q", stdout)
+	}
+}
+
+func TestRepl(t *testing.T) {
+	path := writeTestFile(t, map[string]string{"a/1": "v1", "a/2": "value-2", "b/1": "\x00"})
+	script := strings.Join([]string{
+		"keys a/",
+		"get a/2",
+		"size a/2",
+		"get missing",
+		"",
+		"bogus",
+		"encoding hex",
+		"get 622f31",
+		"get zz",
+		"encoding key raw",
+		"encoding",
+		"stats",
+		"quit",
+		"get a/1",
+	}, "\n")
+	stdout, stderr, code := runCli(t, strings.NewReader(script), "-f", path, "--binary-values=false", "repl")
+	if code != 0 {
+		t.Fatalf("repl failed: %v %s", code, stderr)
+	}
+	expected := "> a/1\na/2\n" +
+		"> value-2\n" +
+		"> 7\n" +
+		"> " +
+		"> " +
+		"> " +
+		"> " +
+		"> 00\n" +
+		"> " +
+		"> " +
+		"> key: raw\nvalue: hex\n" +
+		"> entries: 3\ntotal value size: 10\nmax key size: 3\nmax value size: 7\n" +
+		"> "
+	if stdout != expected {
+		t.Fatalf("unexpected repl output: %q", stdout)
+	}
+	for _, msg := range []string{`key "missing" not found`, `unknown command "bogus"`, "decode key as hex"} {
+		if !strings.Contains(stderr, msg) {
+			t.Fatalf("expected %q in stderr: %s", msg, stderr)
+		}
+	}
+
+	// ends at the end of stdin
+	stdout, _, code = runCli(t, strings.NewReader("get a/1\n"), "-f", path, "--binary-values=false", "repl")
+	if code != 0 || stdout != "> v1\n> \n" {
+		t.Fatalf("unexpected repl output: %v %q", code, stdout)
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	vals := map[string]string{"a": "line 1\nline 2", "b\tc": "back\\slash", "d": ""}
+	path := writeTestFile(t, vals)
+	tsvUnescaper := strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n", `\r`, "\r")
+
+	for _, cmd := range []string{"values", "scan"} {
+		// json
+		stdout, stderr, code := runCli(t, nil, "-f", path, cmd, "--format", "json")
+		if code != 0 {
+			t.Fatalf("%s --format json failed: %v %s", cmd, code, stderr)
+		}
+		got := make(map[string]string)
+		for _, line := range strings.Split(strings.TrimSuffix(stdout, "\n"), "\n") {
+			key, value, err := kvfile.ParseJSONLEntry([]byte(line))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			got[string(key)] = string(value)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(vals) {
+			t.Fatalf("%s --format json did not round-trip: %q", cmd, stdout)
+		}
+
+		// tsv
+		stdout, stderr, code = runCli(t, nil, "-f", path, "--value-encoding", "raw", cmd, "--format", "tsv")
+		if code != 0 {
+			t.Fatalf("%s --format tsv failed: %v %s", cmd, code, stderr)
+		}
+		got = make(map[string]string)
+		for _, line := range strings.Split(strings.TrimSuffix(stdout, "\n"), "\n") {
+			fields := strings.Split(line, "\t")
+			if len(fields) != 2 {
+				t.Fatalf("%s --format tsv: invalid line: %q", cmd, line)
+			}
+			got[tsvUnescaper.Replace(fields[0])] = tsvUnescaper.Replace(fields[1])
+		}
+		if fmt.Sprint(got) != fmt.Sprint(vals) {
+			t.Fatalf("%s --format tsv did not round-trip: %q", cmd, stdout)
+		}
+
+		// print0
+		stdout, stderr, code = runCli(t, nil, "-f", path, "--value-encoding", "raw", cmd, "--print0")
+		if code != 0 {
+			t.Fatalf("%s --print0 failed: %v %s", cmd, code, stderr)
+		}
+		fields := strings.Split(strings.TrimSuffix(stdout, "\x00"), "\x00")
+		if len(fields) != 6 {
+			t.Fatalf("%s --print0: unexpected output: %q", cmd, stdout)
+		}
+		got = make(map[string]string)
+		for i := 0; i < len(fields); i += 2 {
+			got[fields[i]] = fields[i+1]
+		}
+		if fmt.Sprint(got) != fmt.Sprint(vals) {
+			t.Fatalf("%s --print0 did not round-trip: %q", cmd, stdout)
+		}
+	}
+
+	tests := []struct {
+		args     []string
+		code     int
+		expected string
+	}{
+		{[]string{"keys", "--format", "json"}, 0, `{"key":"a"}` + "\n" + `{"key":"b\tc"}` + "\n" + `{"key":"d"}` + "\n"},
+		{[]string{"keys", "--format", "tsv"}, 0, "a\nb\\tc\nd\n"},
+		{[]string{"keys", "--print0"}, 0, "a\x00b\tc\x00d\x00"},
+		{[]string{"scan", "--keys-only", "--format", "tsv"}, 0, "a\nb\\tc\nd\n"},
+		{[]string{"keys", "--format", "xml"}, 1, ""},
+		{[]string{"keys", "--format", "json", "--print0"}, 1, ""},
+	}
+	for _, tc := range tests {
+		args := append([]string{"-f", path}, tc.args...)
+		stdout, stderr, code := runCli(t, nil, args...)
+		if code != tc.code || stdout != tc.expected {
+			t.Fatalf("unexpected output for %v: %v %q %s", tc.args, code, stdout, stderr)
+		}
+	}
+}
+
+func TestKeyInput(t *testing.T) {
+	// "k\x00y" is 6b0079 in hex, awB5 in base64, and cwZi in base58
+	path := writeTestFile(t, map[string]string{"k\x00y": "value", "other": "2"})
+	outPath := filepath.Join(t.TempDir(), "out.kv")
+	tests := []struct {
+		args     []string
+		code     int
+		expected string
+	}{
+		{[]string{"get", "--key-hex", "6b0079"}, 0, "value\n"},
+		{[]string{"exists", "--key-base64", "awB5"}, 0, ""},
+		{[]string{"exists", "--key-base58", "cwZi"}, 0, ""},
+		{[]string{"exists", "--key-hex", "6b00"}, 1, ""},
+		{[]string{"delete", "--key-hex", "6b0079", "--output", outPath}, 0, ""},
+		{[]string{"get", "--key", "other", "--key-hex", "6b0079"}, 1, ""},
+		{[]string{"exists", "--key-hex", "6b0079", "--key-base64", "awB5"}, 2, ""},
+		{[]string{"get"}, 1, ""},
+		{[]string{"get", "--key-hex", "6b0"}, 1, ""},
+	}
+	for _, tc := range tests {
+		args := append([]string{"-f", path, "--binary-values=false"}, tc.args...)
+		stdout, stderr, code := runCli(t, nil, args...)
+		if code != tc.code || stdout != tc.expected {
+			t.Fatalf("unexpected output for %v: %v %q %s", tc.args, code, stdout, stderr)
+		}
+	}
+	if stdout, _, _ := runCli(t, nil, "-f", outPath, "keys"); stdout != "other\n" {
+		t.Fatalf("unexpected keys after delete: %q", stdout)
+	}
+	_, stderr, _ := runCli(t, nil, "-f", path, "get", "--key-hex", "6b0")
+	if !strings.Contains(stderr, "decode --key-hex as hex") {
+		t.Fatalf("expected decode error naming the flag: %s", stderr)
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	dir := t.TempDir()
+	outPath := filepath.Join(dir, "out.kv")
+	csvPath := filepath.Join(dir, "data.csv")
+	csvData := "id,name,value\n" +
+		"1,a,plain\n" +
+		"2,b,\"multi\nline\"\n" +
+		"3,c,\"with \"\"quotes\"\", and comma\"\n"
+	if err := os.WriteFile(csvPath, []byte(csvData), 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, stderr, code := runCli(t, nil, "-f", outPath, "import-csv", "--input", csvPath, "--header", "--key-column", "1", "--value-column", "2"); code != 0 {
+		t.Fatalf("import-csv failed: %v %s", code, stderr)
+	}
+	expected := map[string]string{"a": "plain", "b": "multi\nline", "c": `with "quotes", and comma`}
+	for key, value := range expected {
+		stdout, stderr, code := runCli(t, nil, "-f", outPath, "get", "--key", key, "--output", "-")
+		if code != 0 || stdout != value {
+			t.Fatalf("unexpected value for %s: %v %q %s", key, code, stdout, stderr)
+		}
+	}
+
+	// tsv from stdin with compression
+	tsvData := "k1\tv1\nk2\t\"v\t2\"\n"
+	if _, stderr, code := runCli(t, strings.NewReader(tsvData), "-f", outPath, "--compress", "import-csv", "--tsv"); code != 0 {
+		t.Fatalf("import-csv --tsv failed: %v %s", code, stderr)
+	}
+	if stdout, _, _ := runCli(t, nil, "-f", outPath, "--value-encoding", "raw", "values"); stdout != "k1\nv1\nk2\nv\t2\n" {
+		t.Fatalf("unexpected values: %q", stdout)
+	}
+
+	errorCases := []struct {
+		input string
+		args  []string
+		msg   string
+	}{
+		{"a,1\nb,2\na,3\n", nil, `duplicate key "a" on line 3: first seen on line 1`},
+		{"a,1\nb\n", nil, "line 2: expected at least 2 columns"},
+		{"a,1\n\"b,2\n", nil, "read CSV"},
+		{",1\n", nil, "line 1: key cannot be empty"},
+		{"a;1\n", []string{"--delimiter", ";;"}, "invalid --delimiter"},
+		{"a\t1\n", []string{"--tsv", "--delimiter", ";"}, "cannot be used together"},
+	}
+	for _, tc := range errorCases {
+		args := append([]string{"-f", outPath, "import-csv"}, tc.args...)
+		_, stderr, code := runCli(t, strings.NewReader(tc.input), args...)
+		if code != 1 || !strings.Contains(stderr, tc.msg) {
+			t.Fatalf("expected error %q for %q: %v %s", tc.msg, tc.input, code, stderr)
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	path := writeTestFile(t, map[string]string{"old/a": "1", "old/b": "2", "keep": "3", "b": "4"})
+	outPath := filepath.Join(t.TempDir(), "out.kv")
+	tests := []struct {
+		args     []string
+		code     int
+		expected string
+		values   string
+	}{
+		{[]string{"--only-prefix", "old/", "--strip-prefix", "old/", "--add-prefix", "new/"}, 0, "copied 2 entries\n", "new/a\n1\nnew/b\n2\n"},
+		// keys without the prefix pass through
+		{[]string{"--strip-prefix", "k", "--add-prefix", "x/"}, 0, "copied 4 entries\n", "x/b\n4\nx/eep\n3\nx/old/a\n1\nx/old/b\n2\n"},
+		{[]string{"--strip-prefix", "old/", "--only-prefix", "o", "--strict"}, 0, "copied 2 entries\n", "a\n1\nb\n2\n"},
+		{[]string{"--strip-prefix", "old/", "--strict"}, 1, "", ""},
+		{[]string{"--strip-prefix", "old/", "--add-prefix", "new/"}, 1, "", ""},
+	}
+	for _, tc := range tests {
+		args := append([]string{"-f", path, "copy", "--output", outPath}, tc.args...)
+		stdout, stderr, code := runCli(t, nil, args...)
+		if code != tc.code || stdout != tc.expected {
+			t.Fatalf("unexpected output for %v: %v %q %s", tc.args, code, stdout, stderr)
+		}
+		if code != 0 {
+			continue
+		}
+		if stdout, _, _ := runCli(t, nil, "-f", outPath, "--binary-values=false", "values"); stdout != tc.values {
+			t.Fatalf("unexpected values for %v: %q", tc.args, stdout)
+		}
+	}
+
+	_, stderr, _ := runCli(t, nil, "-f", path, "copy", "--output", outPath, "--strip-prefix", "old/", "--add-prefix", "new/")
+	if !strings.Contains(stderr, `keys "b" and "old/b" both map to "new/b"`) {
+		t.Fatalf("expected collision error naming both keys: %s", stderr)
+	}
+	_, stderr, _ = runCli(t, nil, "-f", path, "copy", "--output", outPath, "--strip-prefix", "old/", "--strict")
+	if !strings.Contains(stderr, `key "b" does not have the prefix "old/"`) {
+		t.Fatalf("expected strict error: %s", stderr)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.kv")
+	if _, stderr, code := runCli(t, nil, "-f", path, "write", "--progress", "--json", `{"a":"1","b":"22","c":"333"}`); code != 0 || !strings.Contains(stderr, "3 entries, 6 bytes written") {
+		t.Fatalf("expected progress for write: %v %q", code, stderr)
+	}
+	if _, stderr, code := runCli(t, nil, "-f", path, "write", "--json", `{"a":"1"}`); code != 0 || stderr != "" {
+		t.Fatalf("expected no progress by default: %v %q", code, stderr)
+	}
+
+	outPath := filepath.Join(dir, "out.kv")
+	runCli(t, nil, "-f", path, "write", "--json", `{"a":"1","b":"22","c":"333"}`)
+	stdout, stderr, code := runCli(t, nil, "-f", path, "convert", "--progress", "--output", outPath, "--to", "compressed")
+	if code != 0 || !strings.Contains(stderr, "3 entries, 6 bytes written") || !strings.HasSuffix(stdout, " bytes\n") || strings.Contains(stdout, "entries") {
+		t.Fatalf("expected progress for convert on stderr only: %v %q %q", code, stdout, stderr)
+	}
+	if _, stderr, code := runCli(t, strings.NewReader(`{"key":"a","value":"1"}`+"\n"), "-f", outPath, "import", "--progress"); code != 0 || !strings.Contains(stderr, "1 entries, 1 bytes written") {
+		t.Fatalf("expected progress for import: %v %q", code, stderr)
+	}
+	if _, stderr, code := runCli(t, strings.NewReader("a,1\nb,2\n"), "-f", outPath, "import-csv", "--progress"); code != 0 || !strings.Contains(stderr, "2 entries, 2 bytes written") {
+		t.Fatalf("expected progress for import-csv: %v %q", code, stderr)
+	}
+	if _, stderr, code := runCli(t, nil, "-f", outPath, "merge", "--progress", path); code != 0 || !strings.Contains(stderr, "3 entries, 6 bytes written") {
+		t.Fatalf("expected progress for merge: %v %q", code, stderr)
+	}
+}
+
+func TestPrefixFilters(t *testing.T) {
+	path := writeTestFile(t, map[string]string{
+		"tenant-4/a":  "1",
+		"tenant-42/a": "2",
+		"tenant-42/b": "3",
+		"tenant-43/a": "4",
+		"tenant-5/a":  "5",
+	})
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"count"}, "5\n"},
+		{[]string{"count", "--prefix", "tenant-42/"}, "2\n"},
+		{[]string{"count", "--prefix", "tenant-4"}, "4\n"},
+		{[]string{"count", "--prefix", "tenant-6"}, "0\n"},
+		{[]string{"keys", "--prefix", "tenant-42/"}, "tenant-42/a\ntenant-42/b\n"},
+		{[]string{"keys", "--prefix", "tenant-4/"}, "tenant-4/a\n"},
+		{[]string{"keys", "--prefix", "tenant-6"}, ""},
+		{[]string{"keys", "--prefix", "tenant-43", "--format", "json"}, `{"key":"tenant-43/a"}` + "\n"},
+		{[]string{"--key-encoding", "hex", "keys", "--prefix", "74656e616e742d35"}, "74656e616e742d352f61\n"},
+	}
+	for _, tc := range tests {
+		args := append([]string{"-f", path}, tc.args...)
+		stdout, stderr, code := runCli(t, nil, args...)
+		if code != 0 || stdout != tc.expected {
+			t.Fatalf("unexpected output for %v: %v %q %s", tc.args, code, stdout, stderr)
+		}
+	}
+}
+
+func TestKeysPagination(t *testing.T) {
+	vals := make(map[string]string)
+	for i := 0; i < 10; i++ {
+		vals[fmt.Sprintf("a/%d", i)] = "v"
+		vals[fmt.Sprintf("b/%d", i)] = "v"
+	}
+	path := writeTestFile(t, vals)
+	tests := []struct {
+		args     []string
+		expected string
+		next     string
+	}{
+		{[]string{"--offset", "8", "--limit", "3"}, "a/8\na/9\nb/0\n", "next offset: 11\n"},
+		{[]string{"--offset", "18", "--limit", "3"}, "b/8\nb/9\n", ""},
+		{[]string{"--offset", "17", "--limit", "3"}, "b/7\nb/8\nb/9\n", ""},
+		{[]string{"--offset", "19"}, "b/9\n", ""},
+		{[]string{"--offset", "20"}, "", ""},
+		{[]string{"--offset", "100", "--limit", "5"}, "", ""},
+		{[]string{"--prefix", "b/", "--offset", "2", "--limit", "2"}, "b/2\nb/3\n", "next offset: 4\n"},
+		{[]string{"--prefix", "b/", "--offset", "8", "--limit", "5"}, "b/8\nb/9\n", ""},
+		{[]string{"--prefix", "a/", "--limit", "1"}, "a/0\n", "next offset: 1\n"},
+	}
+	for _, tc := range tests {
+		args := append([]string{"-f", path, "keys"}, tc.args...)
+		stdout, stderr, code := runCli(t, nil, args...)
+		if code != 0 || stdout != tc.expected || stderr != tc.next {
+			t.Fatalf("unexpected output for %v: %v %q %q", tc.args, code, stdout, stderr)
+		}
+	}
+	if _, _, code := runCli(t, nil, "-f", path, "keys", "--offset", "-1"); code != 1 {
+		t.Fatal("expected error for a negative offset")
+	}
+}
+
+func TestHiddenEntries(t *testing.T) {
+	path := writeEntriesTestFile(t, func(wr *kvfile.Writer) error {
+		for _, key := range []string{"a", "c", "e"} {
+			if err := wr.WriteValue([]byte(key), strings.NewReader("val-"+key)); err != nil {
+				return err
+			}
+		}
+		for _, key := range []string{"b", "d"} {
+			if err := wr.WriteTombstone([]byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	tests := []struct {
+		args     []string
+		expected string
+		next     string
+	}{
+		{[]string{"keys"}, "a\nc\ne\n", ""},
+		// the offset counts the hidden entries
+		{[]string{"keys", "--offset", "1", "--limit", "1"}, "", "next offset: 2\n"},
+		{[]string{"keys", "--offset", "2", "--limit", "2"}, "c\n", "next offset: 4\n"},
+		{[]string{"keys", "--offset", "4"}, "e\n", ""},
+		{[]string{"count"}, "5\n", ""},
+		{[]string{"count", "--prefix", "b"}, "1\n", ""},
+		{[]string{"count", "--skip-hidden"}, "3\n", ""},
+		{[]string{"count", "--skip-hidden", "--prefix", "b"}, "0\n", ""},
+		{[]string{"--binary-values=false", "values"}, "a\nval-a\nc\nval-c\ne\nval-e\n", ""},
+	}
+	for _, tc := range tests {
+		stdout, stderr, code := runCli(t, nil, append([]string{"-f", path}, tc.args...)...)
+		if code != 0 || stdout != tc.expected || stderr != tc.next {
+			t.Fatalf("unexpected output for %v: %v %q %q", tc.args, code, stdout, stderr)
+		}
+	}
+
+	// only tombstones
+	path = writeEntriesTestFile(t, func(wr *kvfile.Writer) error {
+		return wr.WriteTombstone([]byte("a"))
+	})
+	if stdout, _, _ := runCli(t, nil, "-f", path, "values"); stdout != "No key-value pairs found.\n" {
+		t.Fatalf("unexpected values output: %q", stdout)
+	}
+}
+
+func TestGrep(t *testing.T) {
+	path := writeTestFile(t, map[string]string{
+		"a/1": "hello world",
+		"a/2": "error: code 42 in module",
+		"b/1": "\x00\xffbinary error\x00",
+		"b/2": strings.Repeat("x", 100) + "needle" + strings.Repeat("y", 100),
+	})
+	tests := []struct {
+		args     []string
+		code     int
+		expected string
+	}{
+		{[]string{"--pattern", "error"}, 0, "a/2\nb/1\n"},
+		{[]string{"--pattern", "error", "--prefix", "b/"}, 0, "b/1\n"},
+		{[]string{"--pattern", "\xffbin"}, 0, "b/1\n"},
+		{[]string{"--pattern", "code [0-9]+"}, 1, ""},
+		{[]string{"--pattern", "code [0-9]+", "--regex"}, 0, "a/2\n"},
+		{[]string{"--pattern", "^h.*d$", "--regex"}, 0, "a/1\n"},
+		{[]string{"--pattern", "missing"}, 1, ""},
+		{[]string{"--pattern", "needle", "--show-value"}, 0, `b/2: offset 100: "` + strings.Repeat("x", 20) + "needle" + strings.Repeat("y", 20) + `"` + "\n"},
+		{[]string{"--pattern", "binary", "--show-value"}, 0, `b/1: offset 2: "\x00\xffbinary error\x00"` + "\n"},
+		{[]string{"--pattern", "(", "--regex"}, 2, ""},
+		{nil, 2, ""},
+	}
+	for _, tc := range tests {
+		args := append([]string{"-f", path, "grep"}, tc.args...)
+		stdout, stderr, code := runCli(t, nil, args...)
+		if code != tc.code || stdout != tc.expected {
+			t.Fatalf("unexpected output for %v: %v %q %s", tc.args, code, stdout, stderr)
+		}
+	}
+}
+
+func TestSize(t *testing.T) {
+	vals := map[string]string{"small": "12345", "empty": "", "big": strings.Repeat("x", 3*1024*1024/2), "k\x00": "1"}
+	path := writeTestFile(t, vals)
+	compressedPath := filepath.Join(t.TempDir(), "compressed.kv")
+	if _, stderr, code := runCli(t, nil, "-f", path, "convert", "--output", compressedPath, "--to", "compressed"); code != 0 {
+		t.Fatalf("convert failed: %v %s", code, stderr)
+	}
+	for _, p := range []string{path, compressedPath} {
+		tests := []struct {
+			args     []string
+			code     int
+			expected string
+		}{
+			{[]string{"--key", "small"}, 0, "5\n"},
+			{[]string{"--key", "empty"}, 0, "0\n"},
+			{[]string{"--key", "missing"}, 1, ""},
+			{[]string{"--key-hex", "6b00"}, 0, "1\n"},
+			{[]string{"--key", "small", "--json"}, 0, `{"key":"small","size":5}` + "\n"},
+			{[]string{"--key", "empty", "--json"}, 0, `{"key":"empty","size":0}` + "\n"},
+			{[]string{"--key", "small", "--human"}, 0, "5 B\n"},
+			{[]string{"--key", "big", "--human"}, 0, "1.5 MiB\n"},
+		}
+		for _, tc := range tests {
+			args := append([]string{"-f", p, "size"}, tc.args...)
+			stdout, stderr, code := runCli(t, nil, args...)
+			if code != tc.code || stdout != tc.expected {
+				t.Fatalf("unexpected output for %v: %v %q %s", tc.args, code, stdout, stderr)
+			}
+		}
+	}
+}
+
+func TestCat(t *testing.T) {
+	original := strings.Repeat("0123456789abcdef", 1000)
+	vals := map[string]string{"other": "x"}
+	var chunks []string
+	for i := 0; i*1000 < len(original); i++ {
+		chunk := original[i*1000 : min((i+1)*1000, len(original))]
+		vals[fmt.Sprintf("blob-123/%04d", i)] = chunk
+		chunks = append(chunks, chunk)
+	}
+	path := writeTestFile(t, vals)
+
+	stdout, stderr, code := runCli(t, nil, "-f", path, "cat", "--prefix", "blob-123/")
+	if code != 0 || stdout != original {
+		t.Fatalf("unexpected cat output: %v %d bytes %s", code, len(stdout), stderr)
+	}
+	stdout, stderr, code = runCli(t, nil, "-f", path, "cat", "--prefix", "blob-123/", "--separator", "\n")
+	if code != 0 || stdout != strings.Join(chunks, "\n") {
+		t.Fatalf("unexpected cat output with separator: %v %d bytes %s", code, len(stdout), stderr)
+	}
+
+	keysPath := filepath.Join(t.TempDir(), "keys.txt")
+	if err := os.WriteFile(keysPath, []byte("other\nblob-123/0001\n\nblob-123/0000\n"), 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	stdout, stderr, code = runCli(t, nil, "-f", path, "cat", "--keys-from", keysPath)
+	if code != 0 || stdout != "x"+chunks[1]+chunks[0] {
+		t.Fatalf("unexpected cat --keys-from output: %v %q %s", code, stdout, stderr)
+	}
+	stdout, stderr, code = runCli(t, strings.NewReader("other\nmissing\n"), "-f", path, "cat", "--keys-from", "-")
+	if code != 1 || stdout != "" || !strings.Contains(stderr, "missing") {
+		t.Fatalf("expected missing key error: %v %q %s", code, stdout, stderr)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	vals := map[string]string{
+		"tenant-1/a":  "1a",
+		"tenant-13/a": "13a",
+		"tenant-13/b": "13b",
+		"tenant-2/a":  "2a",
+		"tenant-20/a": "20a",
+	}
+	path := writeTestFile(t, vals)
+	outPath := filepath.Join(t.TempDir(), "out.kv")
+
+	// the overlapping tenant-2 prefix covers tenant-20/
+	stdout, stderr, code := runCli(t, nil, "-f", path, "prune", "--output", outPath, "--prefix", "tenant-13/", "--prefix", "tenant-2", "--prefix", "tenant-20/")
+	if code != 0 || stdout != "removed 4 entries, 11 bytes\n" {
+		t.Fatalf("unexpected prune output: %v %q %s", code, stdout, stderr)
+	}
+	reader := openTestFile(t, outPath)
+	if reader.Size() != 1 {
+		t.Fatalf("expected 1 entry: %v", reader.Size())
+	}
+	if val, found, err := reader.Get([]byte("tenant-1/a")); err != nil || !found || string(val) != "1a" {
+		t.Fatalf("unexpected value for tenant-1/a: %q %v %v", val, found, err)
+	}
+	if n, err := reader.CountPrefix([]byte("tenant-13/")); err != nil || n != 0 {
+		t.Fatalf("expected tenant-13/ to be removed: %v %v", n, err)
+	}
+
+	// refuse to remove every key
+	_, stderr, code = runCli(t, nil, "-f", path, "prune", "--in-place", "--prefix", "tenant-1", "--prefix", "tenant-2")
+	if code != 1 || !strings.Contains(stderr, "--allow-empty") {
+		t.Fatalf("expected prune to refuse removing every key: %v %s", code, stderr)
+	}
+	if openTestFile(t, path).Size() != uint64(len(vals)) {
+		t.Fatal("expected the kvfile to be unchanged")
+	}
+	_, stderr, code = runCli(t, nil, "-f", path, "prune", "--in-place", "--prefix", "tenant-1", "--prefix", "tenant-2", "--allow-empty")
+	if code != 0 {
+		t.Fatalf("unexpected prune error: %v %s", code, stderr)
+	}
+	if openTestFile(t, path).Size() != 0 {
+		t.Fatal("expected an empty kvfile")
+	}
+}
+
+func TestEntry(t *testing.T) {
+	var buf bytes.Buffer
+	wr := kvfile.NewWriter(&buf)
+	if err := wr.WriteValue([]byte("a"), strings.NewReader("value-a")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.WriteValueBytesMeta([]byte("b"), []byte("value-bb"), []byte("meta-b")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	path := filepath.Join(t.TempDir(), "test.kv")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	reader := openTestFile(t, path)
+	offset, size, _, idx, err := reader.GetValuePosition([]byte("b"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, args := range [][]string{{"get", "--meta", "--key", "b"}, {"entry", "--key", "b"}} {
+		stdout, stderr, code := runCli(t, nil, append([]string{"-f", path}, args...)...)
+		if code != 0 {
+			t.Fatalf("unexpected error for %v: %v %s", args, code, stderr)
+		}
+		var info entryInfo
+		if err := json.Unmarshal([]byte(stdout), &info); err != nil {
+			t.Fatal(err.Error())
+		}
+		if info.Key != "b" || info.Index != idx || info.Offset != offset || info.Size != size || string(info.Meta) != "meta-b" {
+			t.Fatalf("unexpected entry for %v: %s", args, stdout)
+		}
+	}
+	if _, _, code := runCli(t, nil, "-f", path, "entry", "--key", "missing"); code != 1 {
+		t.Fatalf("expected missing key error: %v", code)
+	}
+}
+
+func TestIndexDump(t *testing.T) {
+	path := writeTestFile(t, map[string]string{"key-a": "1", "key-b": "22", "key-c": "333"})
+	reader := openTestFile(t, path)
+	offsets := make([]uint64, 3)
+	valueOffsets := make([]uint64, 3)
+	for i := range offsets {
+		offsets[i] = reader.IndexEntryOffset(uint64(i))
+		indexEntry, err := reader.ReadIndexEntry(uint64(i))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		valueOffsets[i] = indexEntry.GetOffset()
+	}
+	stdout, stderr, code := runCli(t, nil, "-f", path, "index-dump", "--start", "1")
+	expected := fmt.Sprintf(`values: 0-6
+index entries: 6
+index entry positions: %d
+entry count: 3
+1	offset %d	key "key-b"	value %d+2
+2	offset %d	key "key-c"	value %d+3
+`, reader.Layout().IndexEntryIndexesPos, offsets[1], valueOffsets[1], offsets[2], valueOffsets[2])
+	if code != 0 || stdout != expected {
+		t.Fatalf("unexpected index-dump output: %v %q %s", code, stdout, stderr)
+	}
+
+	// corrupt the wire type of the first field of key-b
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	data[offsets[1]] = 0x0f
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	stdout, stderr, code = runCli(t, nil, "-f", path, "index-dump", "--json", "--limit", "2")
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if code != 1 || len(lines) != 3 || !strings.Contains(stderr, "found 1 corrupt entries") {
+		t.Fatalf("expected index-dump to report the corrupt entry: %v %q %s", code, stdout, stderr)
+	}
+	var layout indexDumpLayout
+	if err := json.Unmarshal([]byte(lines[0]), &layout); err != nil || layout.EntryCount != 3 {
+		t.Fatalf("unexpected layout record: %s %v", lines[0], err)
+	}
+	var entries [2]indexDumpEntry
+	for i := range entries {
+		if err := json.Unmarshal([]byte(lines[i+1]), &entries[i]); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if entries[0].Key != "key-a" || entries[0].Error != "" {
+		t.Fatalf("unexpected first entry: %s", lines[1])
+	}
+	if entries[1].Index != 1 || entries[1].Offset != offsets[1] || entries[1].Error == "" {
+		t.Fatalf("expected the second entry to be flagged: %s", lines[2])
+	}
+}
+
+// writeSparseTestFile writes a kvfile with the index entries after a sparse
+// values region of valuesSize bytes.
+func writeSparseTestFile(t testing.TB, valuesSize uint64, entries []*kvfile.IndexEntry) string {
+	path := filepath.Join(t.TempDir(), "sparse.kv")
+	var index []byte
+	var positions []byte
+	for _, entry := range entries {
+		data, err := entry.MarshalVT()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		index = append(index, data...)
+		positions = binary.LittleEndian.AppendUint64(positions, valuesSize+uint64(len(index)))
+		index = binary.AppendUvarint(index, uint64(len(data)))
+	}
+	index = append(index, positions...)
+	index = binary.LittleEndian.AppendUint64(index, uint64(len(entries)))
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer file.Close()
+	if _, err := file.WriteAt(index, int64(valuesSize)); err != nil {
+		t.Fatal(err.Error())
+	}
+	return path
+}
+
+func TestSizeLimits(t *testing.T) {
+	// a 1.5 GiB value
+	bigPath := writeSparseTestFile(t, 3<<29, []*kvfile.IndexEntry{{Key: []byte("big"), Size: 3 << 29}})
+	if _, stderr, code := runCli(t, nil, "-f", bigPath, "size", "--key", "big"); code != 1 || !strings.Contains(stderr, "max size") {
+		t.Fatalf("expected value size limit error: %v %s", code, stderr)
+	}
+	stdout, stderr, code := runCli(t, nil, "--max-value-size", "2G", "-f", bigPath, "size", "--key", "big")
+	if code != 0 || stdout != "1610612736\n" {
+		t.Fatalf("unexpected size output: %v %q %s", code, stdout, stderr)
+	}
+
+	// a 3 KiB key
+	longKey := strings.Repeat("k", 3<<10)
+	longKeyPath := writeSparseTestFile(t, 0, []*kvfile.IndexEntry{{Key: []byte(longKey)}})
+	if _, _, code := runCli(t, nil, "-f", longKeyPath, "count"); code != 1 {
+		t.Fatalf("expected index entry size limit error: %v", code)
+	}
+	stdout, stderr, code = runCli(t, nil, "--max-key-size", "4KiB", "-f", longKeyPath, "keys")
+	if code != 0 || stdout != longKey+"\n" {
+		t.Fatalf("unexpected keys output: %v %d bytes %s", code, len(stdout), stderr)
+	}
+
+	if _, stderr, code := runCli(t, nil, "--max-value-size", "2X", "-f", bigPath, "count"); code != 1 || !strings.Contains(stderr, `invalid size: "2X"`) {
+		t.Fatalf("expected invalid size error: %v %s", code, stderr)
+	}
+}
+
+func TestHash(t *testing.T) {
+	path := writeTestFile(t, map[string]string{"a": "1", "b": "22", "c": "333"})
+	compressedPath := filepath.Join(t.TempDir(), "compressed.kv")
+	if _, stderr, code := runCli(t, nil, "-f", path, "convert", "--output", compressedPath, "--to", "compressed"); code != 0 {
+		t.Fatalf("convert failed: %v %s", code, stderr)
+	}
+
+	for _, algo := range []string{"sha256", "sha512"} {
+		plain, stderr, code := runCli(t, nil, "-f", path, "hash", "--algo", algo)
+		if code != 0 {
+			t.Fatalf("unexpected error: %v %s", code, stderr)
+		}
+		compressed, stderr, code := runCli(t, nil, "-f", compressedPath, "hash", "--algo", algo)
+		if code != 0 {
+			t.Fatalf("unexpected error: %v %s", code, stderr)
+		}
+		if plain != compressed {
+			t.Fatalf("expected the same %s digest: %q != %q", algo, plain, compressed)
+		}
+		if algo == "sha256" && plain != hex.EncodeToString(contentDigest(t, path))+"\n" {
+			t.Fatalf("expected the library content digest: %q", plain)
+		}
+	}
+
+	if _, stderr, code := runCli(t, nil, "-f", path, "hash", "--algo", "md5"); code != 1 || !strings.Contains(stderr, "invalid --algo") {
+		t.Fatalf("expected invalid algo error: %v %s", code, stderr)
+	}
+}
+
+func TestSample(t *testing.T) {
+	vals := map[string]string{"other": "x"}
+	for i := 0; i < 50; i++ {
+		vals[fmt.Sprintf("k/%02d", i)] = fmt.Sprintf("v%d", i)
+	}
+	path := writeTestFile(t, vals)
+
+	stdout, stderr, code := runCli(t, nil, "-f", path, "sample", "-n", "5", "--prefix", "k/", "--seed", "42")
+	keys := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	if code != 0 || len(keys) != 5 {
+		t.Fatalf("unexpected sample output: %v %q %s", code, stdout, stderr)
+	}
+	for i, key := range keys {
+		if !strings.HasPrefix(key, "k/") || (i != 0 && keys[i-1] >= key) {
+			t.Fatalf("expected distinct sorted keys with the prefix: %v", keys)
+		}
+	}
+	again, _, _ := runCli(t, nil, "-f", path, "sample", "-n", "5", "--prefix", "k/", "--seed", "42")
+	if again != stdout {
+		t.Fatalf("expected the same sample with the same seed: %q != %q", again, stdout)
+	}
+
+	// more samples than entries prints them all
+	stdout, stderr, code = runCli(t, nil, "--binary-values=false", "-f", path, "sample", "-n", "100", "--values", "--format", "tsv")
+	if lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n"); code != 0 || len(lines) != len(vals) || lines[len(lines)-1] != "other\tx" {
+		t.Fatalf("unexpected sample output: %v %q %s", code, stdout, stderr)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	vals := make(map[string]string)
+	for _, prefix := range []string{"a/", "b/", "c/"} {
+		for i := 0; i < 20; i++ {
+			vals[fmt.Sprintf("%s%02d", prefix, i)] = strings.Repeat("v", 50+i)
+		}
+	}
+	path := writeTestFile(t, vals)
+
+	// checkShards checks each key is in exactly one shard and returns the manifest
+	checkShards := func(outDir, stdout string, maxBytes int64) []*splitShard {
+		var manifest splitManifest
+		if err := json.Unmarshal([]byte(stdout), &manifest); err != nil {
+			t.Fatalf("invalid manifest: %v %s", err, stdout)
+		}
+		seen := make(map[string]bool)
+		for _, shard := range manifest.Shards {
+			shardPath := filepath.Join(outDir, shard.File)
+			if fi, err := os.Stat(shardPath); err != nil || (maxBytes != 0 && fi.Size() > maxBytes) {
+				t.Fatalf("expected shard %s under %d bytes: %v %v", shard.File, maxBytes, fi.Size(), err)
+			}
+			reader := openTestFile(t, shardPath)
+			if err := reader.Verify(true); err != nil {
+				t.Fatal(err.Error())
+			}
+			if reader.Size() != shard.Entries {
+				t.Fatalf("unexpected entry count for %s: %v", shard.File, reader.Size())
+			}
+			err := reader.ScanPrefix(nil, func(key, value []byte) error {
+				if seen[string(key)] {
+					t.Fatalf("key %q is in multiple shards", key)
+				}
+				seen[string(key)] = true
+				if vals[string(key)] != string(value) {
+					t.Fatalf("unexpected value for %q", key)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			minKey, maxKey, _, err := reader.Bounds()
+			if err != nil || string(minKey) != shard.FirstKey || string(maxKey) != shard.LastKey {
+				t.Fatalf("unexpected bounds for %s: %q %q %v", shard.File, minKey, maxKey, err)
+			}
+		}
+		if len(seen) != len(vals) {
+			t.Fatalf("expected %d keys in the shards: %d", len(vals), len(seen))
+		}
+		return manifest.Shards
+	}
+
+	sizeDir := filepath.Join(t.TempDir(), "size")
+	stdout, stderr, code := runCli(t, nil, "-f", path, "split", "--output-dir", sizeDir, "--max-bytes", "1K")
+	if code != 0 {
+		t.Fatalf("unexpected split error: %v %s", code, stderr)
+	}
+	if shards := checkShards(sizeDir, stdout, 1024); len(shards) < 4 {
+		t.Fatalf("expected at least 4 shards: %v", len(shards))
+	}
+
+	prefixDir := filepath.Join(t.TempDir(), "prefix")
+	stdout, stderr, code = runCli(t, nil, "-f", path, "split", "--output-dir", prefixDir, "--by-prefix-depth", "1")
+	if code != 0 {
+		t.Fatalf("unexpected split error: %v %s", code, stderr)
+	}
+	shards := checkShards(prefixDir, stdout, 0)
+	if len(shards) != 3 || shards[1].Prefix == nil || *shards[1].Prefix != "b/" || shards[1].FirstKey != "b/00" || shards[1].LastKey != "b/19" {
+		t.Fatalf("expected a shard per prefix: %s", stdout)
+	}
+
+	if _, stderr, code := runCli(t, nil, "-f", path, "split", "--output-dir", sizeDir, "--max-bytes", "64"); code != 1 || !strings.Contains(stderr, "does not fit") {
+		t.Fatalf("expected entry size error: %v %s", code, stderr)
+	}
+}
+
+func TestSplitEntryAttrs(t *testing.T) {
+	expires := time.UnixMilli(4102444800000)
+	path := writeEntriesTestFile(t, func(wr *kvfile.Writer) error {
+		if err := wr.WriteTombstone([]byte("a/deleted")); err != nil {
+			return err
+		}
+		if err := wr.WriteValueWithExpiry([]byte("b/expires"), strings.NewReader("val"), expires); err != nil {
+			return err
+		}
+		return wr.WriteValueBytesMeta([]byte("c/meta"), []byte("val"), []byte("test-meta"))
+	})
+	outDir := filepath.Join(t.TempDir(), "out")
+	stdout, stderr, code := runCli(t, nil, "-f", path, "split", "--output-dir", outDir, "--by-prefix-depth", "1")
+	if code != 0 {
+		t.Fatalf("unexpected split error: %v %s", code, stderr)
+	}
+	var manifest splitManifest
+	if err := json.Unmarshal([]byte(stdout), &manifest); err != nil {
+		t.Fatalf("invalid manifest: %v %s", err, stdout)
+	}
+	entries := make(map[string]*kvfile.IndexEntry)
+	for _, shard := range manifest.Shards {
+		for key, entry := range readTestEntries(t, filepath.Join(outDir, shard.File)) {
+			entries[key] = entry
+		}
+	}
+	if len(entries) != 3 || !entries["a/deleted"].GetTombstone() {
+		t.Fatalf("expected the tombstone to be copied: %v", len(entries))
+	}
+	if got := entries["b/expires"].GetExpiresUnixMs(); got != uint64(expires.UnixMilli()) {
+		t.Fatalf("unexpected split expiry: %v", got)
+	}
+	if got := string(entries["c/meta"].GetMeta()); got != "test-meta" {
+		t.Fatalf("unexpected split meta: %q", got)
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	binKey := make([]byte, 64)
+	for i := range binKey {
+		binKey[i] = byte(255 - i)
+	}
+	// the last byte of the key is a newline
+	binKey[63] = '\n'
+	path := writeTestFile(t, map[string]string{string(binKey): "value", string(binKey[:63]): "stripped"})
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "key.bin")
+	if err := os.WriteFile(keyPath, binKey, 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tests := []struct {
+		args     []string
+		code     int
+		expected string
+	}{
+		{[]string{"get", "--key-file", keyPath}, 0, "value\n"},
+		{[]string{"get", "--key-file", keyPath, "--strip-newline"}, 0, "stripped\n"},
+		{[]string{"size", "--key-file", keyPath}, 0, "5\n"},
+		{[]string{"exists", "--key-file", keyPath}, 0, ""},
+		{[]string{"exists", "--key-file", filepath.Join(dir, "missing")}, 2, ""},
+		{[]string{"get", "--key-file", keyPath, "--key", "other"}, 1, ""},
+		{[]string{"get", "--key", "other", "--strip-newline"}, 1, ""},
+	}
+	for _, tc := range tests {
+		args := append([]string{"-f", path, "--binary-values=false"}, tc.args...)
+		stdout, stderr, code := runCli(t, nil, args...)
+		if code != tc.code || stdout != tc.expected {
+			t.Fatalf("unexpected output for %v: %v %q %s", tc.args, code, stdout, stderr)
+		}
+	}
+}
+
+func TestEdit(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+	path := writeTestFile(t, map[string]string{"settings.json": `{"a":1}`, "bin": "\x00\xff", "other": "x"})
+	scriptDir := t.TempDir()
+	writeEditor := func(name, script string) string {
+		scriptPath := filepath.Join(scriptDir, name)
+		if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+			t.Fatal(err.Error())
+		}
+		return scriptPath
+	}
+
+	// unchanged content does not rewrite the kvfile
+	t.Setenv("KVFILE_EDITOR", writeEditor("noop.sh", `case "$1" in *.json) ;; *) exit 1 ;; esac`))
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, stderr, code := runCli(t, nil, "-f", path, "edit", "--key", "settings.json")
+	if code != 0 || !strings.Contains(stderr, "no changes") {
+		t.Fatalf("expected no changes: %v %s", code, stderr)
+	}
+	if after, err := os.Stat(path); err != nil || !os.SameFile(before, after) {
+		t.Fatalf("expected the kvfile to be unchanged: %v", err)
+	}
+
+	t.Setenv("KVFILE_EDITOR", writeEditor("edit.sh", `printf '{"a":2}' > "$1"`))
+	if _, stderr, code := runCli(t, nil, "-f", path, "edit", "--key", "settings.json"); code != 0 {
+		t.Fatalf("unexpected edit error: %v %s", code, stderr)
+	}
+	if stdout, _, _ := runCli(t, nil, "--binary-values=false", "-f", path, "get", "--key", "settings.json"); stdout != "{\"a\":2}\n" {
+		t.Fatalf("unexpected value after edit: %q", stdout)
+	}
+	if stdout, _, _ := runCli(t, nil, "--binary-values=false", "-f", path, "get", "--key", "other"); stdout != "x\n" {
+		t.Fatalf("unexpected other value after edit: %q", stdout)
+	}
+
+	// binary values require --force
+	if _, stderr, code := runCli(t, nil, "-f", path, "edit", "--key", "bin"); code != 1 || !strings.Contains(stderr, "--force") {
+		t.Fatalf("expected binary value error: %v %s", code, stderr)
+	}
+	if _, stderr, code := runCli(t, nil, "-f", path, "edit", "--key", "bin", "--force"); code != 0 {
+		t.Fatalf("unexpected edit error: %v %s", code, stderr)
+	}
+
+	// the editor failing leaves the kvfile unchanged
+	t.Setenv("KVFILE_EDITOR", writeEditor("fail.sh", `printf changed > "$1"; exit 1`))
+	if _, stderr, code := runCli(t, nil, "-f", path, "edit", "--key", "other"); code != 1 || !strings.Contains(stderr, "run editor") {
+		t.Fatalf("expected editor error: %v %s", code, stderr)
+	}
+
+	if entries, err := os.ReadDir(tmpDir); err != nil || len(entries) != 0 {
+		t.Fatalf("expected the temporary files to be removed: %v %v", entries, err)
+	}
+}
+
+func TestWriteStreaming(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.kv")
+
+	// duplicate keys are detected by the writer and remove the output
+	_, stderr, code := runCli(t, strings.NewReader(`{"a":"1","b":"2","a":"3"}`), "-f", path, "write")
+	if code != 1 || !strings.Contains(stderr, `duplicate key while writing: "a"`) {
+		t.Fatalf("expected duplicate key error: %v %s", code, stderr)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected the output to be removed: %v", err)
+	}
+
+	// errors late in the input remove the partially written output
+	for _, input := range []string{`{"a":"1","b":2}`, `{"a":"1","b":"2"`, `{"a":"1"} {}`, `["a"]`} {
+		_, stderr, code := runCli(t, strings.NewReader(input), "-f", path, "write")
+		if code != 1 || !strings.Contains(stderr, "invalid JSON input") {
+			t.Fatalf("expected invalid JSON error for %s: %v %s", input, code, stderr)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("expected the output to be removed for %s: %v", input, err)
+		}
+	}
+
+	if testing.Short() {
+		t.Skip("skipping large input in short mode")
+	}
+
+	// 256 MiB of JSON written with a modest heap: the soft memory limit makes
+	// the collector run often so the heap measures the live memory
+	defer debug.SetMemoryLimit(debug.SetMemoryLimit(32 << 20))
+	const numValues = 256
+	value := strings.Repeat("v", 1<<20)
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = io.WriteString(pw, "{")
+		for i := 0; i < numValues; i++ {
+			if i != 0 {
+				_, _ = io.WriteString(pw, ",")
+			}
+			_, _ = fmt.Fprintf(pw, "%q:%q", fmt.Sprintf("key-%05d", i), value)
+		}
+		_, _ = io.WriteString(pw, "}")
+		_ = pw.Close()
+	}()
+
+	var peakHeap atomic.Uint64
+	done := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		var stats runtime.MemStats
+		for {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapInuse > peakHeap.Load() {
+				peakHeap.Store(stats.HeapInuse)
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+	}()
+	_, stderr, code = runCli(t, pr, "-f", path, "write")
+	close(done)
+	<-sampled
+	if code != 0 {
+		t.Fatalf("write failed: %v %s", code, stderr)
+	}
+	if peak := peakHeap.Load(); peak > 128<<20 {
+		t.Fatalf("expected the heap to stay under 128 MiB: %v", peak)
+	}
+	if rdr := openTestFile(t, path); rdr.Size() != numValues {
+		t.Fatalf("unexpected size: %v", rdr.Size())
+	}
+}
+
+func TestValidateKeys(t *testing.T) {
+	path := writeTestFile(t, map[string]string{
+		"config/a":  "1",
+		"Config/A":  "2",
+		"bad\xff":   "3",
+		" padded":   "4",
+		"long-key0": "5",
+	})
+	stdout, stderr, code := runCli(t, nil, "-f", path, "validate-keys", "--max-length", "8")
+	expected := `utf8: 1
+  "bad\xff"
+length: 1
+  "long-key0"
+whitespace: 1
+  " padded"
+case: 1
+  "config/a" (duplicate of "Config/A")
+`
+	if code != 1 || stdout != expected || !strings.Contains(stderr, "4 key checks failed") {
+		t.Fatalf("unexpected validate-keys output: %v %q %s", code, stdout, stderr)
+	}
+
+	stdout, stderr, code = runCli(t, nil, "-f", path, "validate-keys", "--checks", "utf8,case", "--limit", "0", "--json")
+	var report []*keyCheckResult
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("invalid JSON output: %v %s", err, stdout)
+	}
+	if code != 1 || len(report) != 2 || report[0].Check != "utf8" || report[0].Count != 1 || len(report[0].Keys) != 0 || report[1].Count != 1 {
+		t.Fatalf("unexpected validate-keys JSON output: %v %s %s", code, stdout, stderr)
+	}
+
+	clean := writeTestFile(t, map[string]string{"a": "1", "b": "2"})
+	if stdout, stderr, code := runCli(t, nil, "-f", clean, "validate-keys"); code != 0 || stderr != "" || !strings.Contains(stdout, "case: 0\n") {
+		t.Fatalf("expected the checks to pass: %v %q %s", code, stdout, stderr)
+	}
+	if _, stderr, code := runCli(t, nil, "-f", clean, "validate-keys", "--checks", "utf8,bogus"); code != 1 || !strings.Contains(stderr, `unknown check "bogus"`) {
+		t.Fatalf("expected unknown check error: %v %s", code, stderr)
+	}
+}
+
+// tarEntry is an entry in a tar built by buildTestTar.
+type tarEntry struct {
+	typeflag byte
+	name     string
+	body     string
+}
+
+// buildTestTar builds a tar with the entries.
+func buildTestTar(t testing.TB, entries []tarEntry) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, entry := range entries {
+		hdr := &tar.Header{Typeflag: entry.typeflag, Name: entry.name, Mode: 0o644}
+		switch entry.typeflag {
+		case tar.TypeReg:
+			hdr.Size = int64(len(entry.body))
+		case tar.TypeSymlink:
+			hdr.Linkname = entry.body
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err.Error())
+		}
+		if entry.typeflag == tar.TypeReg {
+			if _, err := io.WriteString(tw, entry.body); err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	return buf.Bytes()
+}
+
+// readTestTar reads the regular files in a tar.
+func readTestTar(t testing.TB, data []byte) map[string]string {
+	files := make(map[string]string)
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if hdr.Typeflag != tar.TypeReg || hdr.Mode != 0o644 {
+			t.Fatalf("unexpected tar entry: %v", hdr)
+		}
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		files[hdr.Name] = string(body)
+	}
+}
+
+func TestTar(t *testing.T) {
+	blob := make([]byte, 256<<10)
+	for i := range blob {
+		blob[i] = byte(i * 7)
+	}
+	files := map[string]string{
+		"dir/a.txt":  "hello",
+		"dir/empty":  "",
+		"binary.bin": string(blob),
+	}
+	data := buildTestTar(t, []tarEntry{
+		{tar.TypeDir, "dir/", ""},
+		{tar.TypeReg, "dir/a.txt", "hello"},
+		{tar.TypeReg, "dir/empty", ""},
+		{tar.TypeSymlink, "link", "dir/a.txt"},
+		{tar.TypeReg, "binary.bin", string(blob)},
+	})
+
+	dir := t.TempDir()
+	tarPath := filepath.Join(dir, "in.tar")
+	if err := os.WriteFile(tarPath, data, 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	kvPath := filepath.Join(dir, "out.kv")
+	_, stderr, code := runCli(t, nil, "-f", kvPath, "from-tar", "--input", tarPath)
+	if code != 0 || !strings.Contains(stderr, "skipping dir/: not a regular file") || !strings.Contains(stderr, "skipping link: not a regular file") {
+		t.Fatalf("unexpected from-tar output: %v %s", code, stderr)
+	}
+	stdout, stderr, code := runCli(t, nil, "-f", kvPath, "to-tar")
+	if code != 0 {
+		t.Fatalf("to-tar failed: %s", stderr)
+	}
+	if got := readTestTar(t, []byte(stdout)); !reflect.DeepEqual(got, files) {
+		t.Fatalf("round trip mismatch: %v", got)
+	}
+
+	// compressed tars are detected on read and chosen by extension on write
+	for _, ext := range []string{".tar.gz", ".tar.zst"} {
+		compPath := filepath.Join(dir, "out"+ext)
+		if _, stderr, code := runCli(t, nil, "-f", kvPath, "to-tar", "--output", compPath, "--mtime", "2024-01-02T03:04:05Z"); code != 0 {
+			t.Fatalf("to-tar %s failed: %s", ext, stderr)
+		}
+		compData, err := os.ReadFile(compPath)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if bytes.HasPrefix(compData, []byte("dir/")) || bytes.HasPrefix(compData, []byte("binary")) {
+			t.Fatalf("expected %s output to be compressed", ext)
+		}
+		rtPath := filepath.Join(dir, "rt"+ext+".kv")
+		if _, stderr, code := runCli(t, bytes.NewReader(compData), "-f", rtPath, "from-tar"); code != 0 {
+			t.Fatalf("from-tar %s failed: %s", ext, stderr)
+		}
+		if !bytes.Equal(contentDigest(t, rtPath), contentDigest(t, kvPath)) {
+			t.Fatalf("round trip through %s changed the contents", ext)
+		}
+	}
+
+	dups := buildTestTar(t, []tarEntry{
+		{tar.TypeReg, "a", "first"},
+		{tar.TypeReg, "b", "b"},
+		{tar.TypeReg, "a", "last"},
+	})
+	for _, tc := range []struct{ policy, expected string }{
+		{"first-wins", "first"},
+		{"last-wins", "last"},
+	} {
+		dupPath := filepath.Join(dir, tc.policy+".kv")
+		if _, stderr, code := runCli(t, bytes.NewReader(dups), "-f", dupPath, "from-tar", "--on-conflict", tc.policy); code != 0 {
+			t.Fatalf("from-tar %s failed: %s", tc.policy, stderr)
+		}
+		stdout, _, _ := runCli(t, nil, "-f", dupPath, "to-tar")
+		if got := readTestTar(t, []byte(stdout)); !reflect.DeepEqual(got, map[string]string{"a": tc.expected, "b": "b"}) {
+			t.Fatalf("unexpected %s contents: %v", tc.policy, got)
+		}
+	}
+	errPath := filepath.Join(dir, "error.kv")
+	if _, stderr, code := runCli(t, bytes.NewReader(dups), "-f", errPath, "from-tar"); code != 1 || !strings.Contains(stderr, `duplicate name "a" in tar: entries 1 and 3`) {
+		t.Fatalf("expected duplicate name error: %v %s", code, stderr)
+	}
+	if _, err := os.Stat(errPath); !os.IsNotExist(err) {
+		t.Fatalf("expected the output to be removed: %v", err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	path := writeTestFile(t, map[string]string{
+		"a":     "1",
+		"b":     "two",
+		"bin":   "\xff\x00",
+		"empty": "",
+	})
+	stdin := "a\nmissing\n\nb\r\nempty\na"
+	stdout, stderr, code := runCli(t, strings.NewReader(stdin), "-f", path, "--value-encoding", "raw", "lookup", "--miss", "<miss>")
+	if code != 0 || stdout != "1\n<miss>\n<miss>\ntwo\n\n1\n" {
+		t.Fatalf("unexpected lookup output: %v %q %s", code, stdout, stderr)
+	}
+	if _, _, code := runCli(t, strings.NewReader(stdin), "-f", path, "lookup", "--fail-on-miss"); code != 1 {
+		t.Fatalf("expected exit code 1 with --fail-on-miss: %v", code)
+	}
+	if _, _, code := runCli(t, strings.NewReader("a\nb\n"), "-f", path, "lookup", "--fail-on-miss"); code != 0 {
+		t.Fatalf("expected exit code 0 without misses: %v", code)
+	}
+
+	stdout, stderr, code = runCli(t, strings.NewReader("62696e\n6d697373696e67\n61\n"), "-f", path, "--key-encoding", "hex", "lookup", "--format", "json")
+	expected := `{"key":"bin","value_base64":"/wA=","found":true}
+{"key":"missing","found":false}
+{"key":"a","value":"1","found":true}
+`
+	if code != 0 || stdout != expected {
+		t.Fatalf("unexpected lookup json output: %v %q %s", code, stdout, stderr)
+	}
+	if _, stderr, code := runCli(t, strings.NewReader("zz\n"), "-f", path, "--key-encoding", "hex", "lookup"); code != 2 || !strings.Contains(stderr, "invalid key on line 1") {
+		t.Fatalf("expected invalid key error: %v %s", code, stderr)
+	}
+}
+
+func TestLookupFlush(t *testing.T) {
+	path := writeTestFile(t, map[string]string{"a": "1", "b": "2"})
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan int, 1)
+	go func() {
+		done <- run([]string{"kvfile", "-f", path, "--value-encoding", "raw", "lookup"}, inR, outW, io.Discard)
+		outW.Close()
+	}()
+
+	// each value must be written before the next key is read
+	br := bufio.NewReader(outR)
+	for _, tc := range []struct{ key, value string }{{"a", "1"}, {"b", "2"}} {
+		if _, err := io.WriteString(inW, tc.key+"\n"); err != nil {
+			t.Fatal(err.Error())
+		}
+		line, err := br.ReadString('\n')
+		if err != nil || line != tc.value+"\n" {
+			t.Fatalf("unexpected lookup output for %s: %q %v", tc.key, line, err)
+		}
+	}
+	inW.Close()
+	if code := <-done; code != 0 {
+		t.Fatalf("lookup failed: %v", code)
+	}
+}
+
+func TestTableFormat(t *testing.T) {
+	path := writeTestFile(t, map[string]string{
+		"short":                  "v",
+		"a/much/longer/key/name": "x",
+		"bin":                    "\x1b[31mred\n\xff",
+		"long":                   strings.Repeat("abcdefghij", 10),
+		"utf8/日本語":               "été",
+	})
+	stdout, stderr, code := runCli(t, nil, "-f", path, "--value-encoding", "raw", "values", "--format", "table", "--max-width", "40")
+	expected := `KEY                  VALUE
+a/much/longer/key/…  x
+bin                  \x1b[31mred\n\xff
+long                 abcdef… (100 bytes)
+short                v
+utf8/日本語          été
+`
+	if code != 0 || stdout != expected {
+		t.Fatalf("unexpected table output: %v\n%s%s", code, stdout, stderr)
+	}
+
+	stdout, _, _ = runCli(t, nil, "-f", path, "--value-encoding", "hex", "scan", "--prefix", "b", "--format", "table", "--max-width", "40", "--no-color")
+	if expected := "KEY  VALUE\nbin  1b5b33316d7265640aff\n"; stdout != expected {
+		t.Fatalf("unexpected hex table output: %q", stdout)
+	}
+
+	t.Setenv("COLUMNS", "8")
+	stdout, _, _ = runCli(t, nil, "-f", path, "keys", "--format", "table")
+	if expected := "KEY\na/much/…\nbin\nlong\nshort\nutf8/日…\n"; stdout != expected {
+		t.Fatalf("unexpected keys table output: %q", stdout)
+	}
+
+	if _, stderr, code := runCli(t, nil, "-f", path, "values", "--max-width", "40"); code != 1 || !strings.Contains(stderr, "--max-width and --no-color require --format table") {
+		t.Fatalf("expected --max-width error: %v %s", code, stderr)
+	}
+}
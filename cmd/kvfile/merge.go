@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+
+	"github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// Conflict policies for the merge command.
+const (
+	conflictFirstWins = "first-wins"
+	conflictLastWins  = "last-wins"
+	conflictError     = "error"
+)
+
+// mergeCommand builds the merge command.
+func (k *kvfileCli) mergeCommand() *cli.Command {
+	onConflict := conflictError
+	var progress bool
+	return &cli.Command{
+		Name:      "merge",
+		Usage:     "Merge the kvfiles given as arguments into the file at --file.",
+		ArgsUsage: "<input.kv>...",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "on-conflict",
+				Usage:       "policy for keys in multiple inputs: first-wins, last-wins, or error",
+				Value:       onConflict,
+				Destination: &onConflict,
+			},
+			k.progressFlag(&progress),
+		},
+		Action: func(c *cli.Context) error {
+			if k.filePath == "" {
+				return errors.New("please provide a file path")
+			}
+			switch onConflict {
+			case conflictFirstWins, conflictLastWins, conflictError:
+			default:
+				return errors.Errorf("invalid --on-conflict: %q", onConflict)
+			}
+			inputPaths := c.Args().Slice()
+			if len(inputPaths) == 0 {
+				return errors.New("please provide the files to merge")
+			}
+			compressOpts, err := k.buildCompressOptions("")
+			if err != nil {
+				return err
+			}
+
+			outPath, err := filepath.Abs(k.filePath)
+			if err != nil {
+				return err
+			}
+			readers := make([]*kvfile.Reader, len(inputPaths))
+			for i, inputPath := range inputPaths {
+				if absPath, err := filepath.Abs(inputPath); err != nil {
+					return err
+				} else if absPath == outPath {
+					return errors.Errorf("output file cannot be an input: %s", inputPath)
+				}
+				reader, rel, _, err := k.openKVFilePath(inputPath, false)
+				if err != nil {
+					return errors.Wrapf(err, "open %s", inputPath)
+				}
+				defer rel()
+				readers[i] = reader
+			}
+
+			reporter := k.newProgressReporter(progress)
+			defer reporter.finish()
+			return createOutputFile(k.filePath, func(file io.Writer) error {
+				return k.writeOutput(file, compressOpts, func(out io.Writer) error {
+					wr, err := reporter.newWriter(out)
+					if err != nil {
+						return err
+					}
+					return mergeReaders(wr, readers, inputPaths, onConflict)
+				})
+			})
+		},
+	}
+}
+
+// mergeCursor is the position of a merge or diff in an input.
+type mergeCursor struct {
+	reader *kvfile.Reader
+	prefix []byte
+	idx    uint64
+	entry  *kvfile.IndexEntry
+}
+
+// newMergeCursor builds a cursor at the first entry with the key prefix.
+func newMergeCursor(reader *kvfile.Reader, prefix []byte) (*mergeCursor, error) {
+	c := &mergeCursor{reader: reader, prefix: prefix}
+	if len(prefix) != 0 {
+		_, idx, err := reader.SearchIndexEntryWithPrefix(prefix, false)
+		if err != nil {
+			return nil, err
+		}
+		c.idx = uint64(idx)
+	}
+	if err := c.next(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// next reads the next index entry, setting entry to nil at the end.
+func (c *mergeCursor) next() error {
+	c.entry = nil
+	if c.idx >= c.reader.Size() {
+		return nil
+	}
+	entry, err := c.reader.ReadIndexEntry(c.idx)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(entry.GetKey(), c.prefix) {
+		return nil
+	}
+	c.entry = entry
+	c.idx++
+	return nil
+}
+
+// valueReader returns a reader for the value of the current entry.
+func (c *mergeCursor) valueReader() (*io.SectionReader, error) {
+	return c.reader.GetValueReaderWithEntry(c.entry, int(c.idx-1))
+}
+
+// copyEntry copies the current entry to the writer.
+func (c *mergeCursor) copyEntry(wr *kvfile.Writer) error {
+	return wr.CopyEntry(c.reader, c.entry, int(c.idx-1))
+}
+
+// mergeReaders writes the entries of the readers in key order to the writer and
+// closes the writer.
+//
+// The entries are copied with their metadata, expiry, and tombstones and the
+// values are streamed from the readers.
+func mergeReaders(wr *kvfile.Writer, readers []*kvfile.Reader, names []string, onConflict string) error {
+	cursors := make([]*mergeCursor, len(readers))
+	for i, reader := range readers {
+		var err error
+		cursors[i], err = newMergeCursor(reader, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	var matches []int
+	for {
+		// find the inputs with the smallest key
+		matches = matches[:0]
+		var minKey []byte
+		for i, cursor := range cursors {
+			if cursor.entry == nil {
+				continue
+			}
+			key := cursor.entry.GetKey()
+			if cmp := bytes.Compare(key, minKey); len(matches) == 0 || cmp < 0 {
+				matches, minKey = append(matches[:0], i), key
+			} else if cmp == 0 {
+				matches = append(matches, i)
+			}
+		}
+		if len(matches) == 0 {
+			break
+		}
+
+		src := matches[0]
+		if len(matches) > 1 {
+			switch onConflict {
+			case conflictError:
+				return errors.Errorf("key %q is in %s and %s", minKey, names[matches[0]], names[matches[1]])
+			case conflictLastWins:
+				src = matches[len(matches)-1]
+			}
+		}
+
+		if err := cursors[src].copyEntry(wr); err != nil {
+			return err
+		}
+		for _, i := range matches {
+			if err := cursors[i].next(); err != nil {
+				return err
+			}
+		}
+	}
+	return wr.Close()
+}
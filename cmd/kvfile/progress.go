@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aperturerobotics/go-kvfile"
+	"github.com/urfave/cli/v2"
+)
+
+// progressReporter prints the progress of writing a kvfile to stderr.
+//
+// On a terminal the progress is updated in place, otherwise a line is printed
+// periodically. A nil reporter reports nothing.
+type progressReporter struct {
+	out      io.Writer
+	tty      bool
+	interval time.Duration
+	start    time.Time
+	last     time.Time
+
+	entries, valueBytes uint64
+}
+
+// isTerminal checks if the writer is a terminal.
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := file.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// progressFlag builds the --progress flag, enabled by default if stderr is a
+// terminal.
+func (k *kvfileCli) progressFlag(dest *bool) cli.Flag {
+	return &cli.BoolFlag{
+		Name:        "progress",
+		Usage:       "print the entries and bytes written and the throughput to stderr",
+		Value:       isTerminal(k.stderr),
+		Destination: dest,
+	}
+}
+
+// newProgressReporter builds a progress reporter writing to stderr.
+//
+// Returns nil if progress is not set.
+func (k *kvfileCli) newProgressReporter(progress bool) *progressReporter {
+	if !progress {
+		return nil
+	}
+	p := &progressReporter{out: k.stderr, tty: isTerminal(k.stderr), interval: time.Second}
+	if p.tty {
+		p.interval = 100 * time.Millisecond
+	}
+	p.start = time.Now()
+	p.last = p.start
+	return p
+}
+
+// newWriter builds a kvfile writer reporting progress to the reporter.
+func (p *progressReporter) newWriter(out io.Writer) (*kvfile.Writer, error) {
+	var opts kvfile.WriterOptions
+	if p != nil {
+		opts.Progress = p.update
+	}
+	return kvfile.NewWriterWithOptions(out, opts)
+}
+
+// update records the progress, printing it if the interval has passed.
+func (p *progressReporter) update(entries, valueBytes uint64) {
+	p.entries, p.valueBytes = entries, valueBytes
+	if now := time.Now(); now.Sub(p.last) >= p.interval {
+		p.last = now
+		p.print(now)
+	}
+}
+
+// finish prints the final progress.
+func (p *progressReporter) finish() {
+	if p == nil {
+		return
+	}
+	p.print(time.Now())
+	if p.tty {
+		io.WriteString(p.out, "\n")
+	}
+}
+
+// print prints the progress.
+func (p *progressReporter) print(now time.Time) {
+	var rate float64
+	if elapsed := now.Sub(p.start).Seconds(); elapsed > 0 {
+		rate = float64(p.valueBytes) / elapsed / (1 << 20)
+	}
+	line := fmt.Sprintf("%d entries, %d bytes written, %.1f MiB/s", p.entries, p.valueBytes, rate)
+	if p.tty {
+		// clear the line and return to the start
+		fmt.Fprintf(p.out, "\r\x1b[K%s", line)
+	} else {
+		fmt.Fprintln(p.out, line)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// pruneCommand builds the prune command.
+func (k *kvfileCli) pruneCommand() *cli.Command {
+	var prefixes cli.StringSlice
+	var outputPath string
+	var inPlace, allowEmpty bool
+	return &cli.Command{
+		Name:  "prune",
+		Usage: "Rewrite a kvfile without the keys with the prefixes.",
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:        "prefix",
+				Usage:       "prefix of the keys to remove, can be repeated",
+				Destination: &prefixes,
+			},
+			&cli.StringFlag{
+				Name:        "output",
+				Usage:       "path to write the new kvfile to",
+				Destination: &outputPath,
+			},
+			&cli.BoolFlag{
+				Name:        "in-place",
+				Usage:       "atomically replace the kvfile",
+				Destination: &inPlace,
+			},
+			&cli.BoolFlag{
+				Name:        "allow-empty",
+				Usage:       "allow removing every key",
+				Destination: &allowEmpty,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if len(prefixes.Value()) == 0 {
+				return errors.New("please provide at least one --prefix")
+			}
+			prefixKeys := make([][]byte, len(prefixes.Value()))
+			for i, prefix := range prefixes.Value() {
+				var err error
+				prefixKeys[i], err = k.parseKey("prefix", prefix)
+				if err != nil {
+					return err
+				}
+			}
+
+			var removed, removedBytes uint64
+			err := k.rewriteFile(outputPath, inPlace, func(reader *kvfile.Reader) error {
+				if allowEmpty {
+					return nil
+				}
+				count, err := countPrefixes(reader, prefixKeys)
+				if err != nil {
+					return err
+				}
+				if count == reader.Size() {
+					return errors.New("refusing to remove every key: use --allow-empty to write an empty kvfile")
+				}
+				return nil
+			}, func(out io.Writer, reader *kvfile.Reader) error {
+				var err error
+				removed, removedBytes, err = kvfile.WriteExcludingPrefix(out, reader, prefixKeys)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(k.stdout, "removed %d entries, %d bytes\n", removed, removedBytes)
+			return nil
+		},
+	}
+}
+
+// countPrefixes returns the number of keys with any of the prefixes.
+func countPrefixes(reader *kvfile.Reader, prefixes [][]byte) (uint64, error) {
+	// a prefix of another prefix covers the keys of both
+	prefixes = slices.Clone(prefixes)
+	slices.SortFunc(prefixes, bytes.Compare)
+	var count uint64
+	for i, prefix := range prefixes {
+		if i != 0 && bytes.HasPrefix(prefix, prefixes[i-1]) {
+			prefixes[i] = prefixes[i-1]
+			continue
+		}
+		n, err := reader.CountPrefix(prefix)
+		if err != nil {
+			return 0, err
+		}
+		count += n
+	}
+	return count, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+
+	"github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// rangeCommand builds the range command.
+func (k *kvfileCli) rangeCommand() *cli.Command {
+	var start, end string
+	var values bool
+	var limit int
+	return &cli.Command{
+		Name:  "range",
+		Usage: "Print the keys in the range [--start, --end).",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "start",
+				Usage:       "the first key in the range (default: unbounded)",
+				Destination: &start,
+			},
+			&cli.StringFlag{
+				Name:        "end",
+				Usage:       "the key after the end of the range (default: unbounded)",
+				Destination: &end,
+			},
+			&cli.BoolFlag{
+				Name:        "values",
+				Usage:       "print the key-value pairs",
+				Destination: &values,
+			},
+			&cli.IntFlag{
+				Name:        "limit",
+				Usage:       "maximum number of entries to print (default: no limit)",
+				Destination: &limit,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if limit < 0 {
+				return errors.Errorf("invalid --limit: %v", limit)
+			}
+			startKey, err := k.parseKey("start", start)
+			if err != nil {
+				return err
+			}
+			endKey, err := k.parseKey("end", end)
+			if err != nil {
+				return err
+			}
+			if len(startKey) != 0 && len(endKey) != 0 && bytes.Compare(startKey, endKey) > 0 {
+				return errors.Errorf("--start %q is after --end %q", start, end)
+			}
+
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
+
+			var count int
+			err = reader.ScanRangeEntries(startKey, endKey, func(indexEntry *kvfile.IndexEntry, indexEntryIdx int) error {
+				if limit != 0 && count == limit {
+					return errStopScan
+				}
+				count++
+				k.printData(indexEntry.GetKey(), k.keyEncoding)
+				if !values {
+					return nil
+				}
+				value, err := reader.GetWithEntry(indexEntry, indexEntryIdx)
+				if err != nil {
+					return err
+				}
+				k.printData(value, k.valueEncoding)
+				return nil
+			})
+			if err == errStopScan {
+				err = nil
+			}
+			return err
+		},
+	}
+}
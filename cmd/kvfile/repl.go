@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// errQuitRepl stops the repl.
+var errQuitRepl = errors.New("quit")
+
+// replHelp is the help text for the repl.
+const replHelp = `Commands:
+  get <key>                       print the value for a key
+  keys [prefix]                   print the keys with the prefix
+  size <key>                      print the size of the value for a key
+  stats                           print the number of entries and sizes
+  encoding [key|value] <encoding> set the encoding: raw, hex, base64, or base58
+  encoding                        print the encodings
+  help                            print this help
+  quit                            exit the repl
+`
+
+// replCommand builds the repl command.
+func (k *kvfileCli) replCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "repl",
+		Usage: "Open a k/v file and read commands from stdin, type help for a list.",
+		Action: func(c *cli.Context) error {
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
+			return k.runRepl(reader)
+		},
+	}
+}
+
+// runRepl reads and runs commands from stdin until quit or the end of stdin.
+//
+// Errors from commands are printed to stderr.
+func (k *kvfileCli) runRepl(reader *kvfile.Reader) error {
+	scanner := bufio.NewScanner(k.stdin)
+	for {
+		io.WriteString(k.stdout, "> ")
+		if !scanner.Scan() {
+			io.WriteString(k.stdout, "\n")
+			return scanner.Err()
+		}
+		args := strings.Fields(scanner.Text())
+		if len(args) == 0 {
+			continue
+		}
+		err := k.runReplCommand(reader, args[0], args[1:])
+		if err == errQuitRepl {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintf(k.stderr, "error: %v\n", err)
+		}
+	}
+}
+
+// runReplCommand runs a repl command.
+func (k *kvfileCli) runReplCommand(reader *kvfile.Reader, cmd string, args []string) error {
+	switch cmd {
+	case "get", "size":
+		if len(args) != 1 {
+			return errors.Errorf("usage: %s <key>", cmd)
+		}
+		key, err := parseData("key", args[0], k.keyEncoding)
+		if err != nil {
+			return err
+		}
+		if cmd == "size" {
+			size, err := reader.GetValueSize(key)
+			if err != nil {
+				return err
+			}
+			if size < 0 {
+				return errors.Errorf("key %q not found", args[0])
+			}
+			fmt.Fprintf(k.stdout, "%d\n", size)
+			return nil
+		}
+		value, found, err := reader.Get(key)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return errors.Errorf("key %q not found", args[0])
+		}
+		k.printData(value, k.valueEncoding)
+		return nil
+	case "keys":
+		if len(args) > 1 {
+			return errors.New("usage: keys [prefix]")
+		}
+		var prefix []byte
+		if len(args) == 1 {
+			var err error
+			prefix, err = parseData("prefix", args[0], k.keyEncoding)
+			if err != nil {
+				return err
+			}
+		}
+		return reader.ScanPrefixKeys(prefix, func(key []byte) error {
+			k.printData(key, k.keyEncoding)
+			return nil
+		})
+	case "stats":
+		if len(args) != 0 {
+			return errors.New("usage: stats")
+		}
+		stats, err := reader.Stats()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(k.stdout, "entries: %d\ntotal value size: %d\nmax key size: %d\nmax value size: %d\n", stats.EntryCount, stats.TotalValueSize, stats.MaxKeySize, stats.MaxValueSize)
+		return nil
+	case "encoding":
+		switch len(args) {
+		case 0:
+			fmt.Fprintf(k.stdout, "key: %s\nvalue: %s\n", k.keyEncoding, k.valueEncoding)
+			return nil
+		case 1:
+			if err := checkEncoding("encoding", args[0]); err != nil {
+				return err
+			}
+			k.keyEncoding, k.valueEncoding = args[0], args[0]
+			return nil
+		case 2:
+			if err := checkEncoding("encoding", args[1]); err != nil {
+				return err
+			}
+			switch args[0] {
+			case "key":
+				k.keyEncoding = args[1]
+			case "value":
+				k.valueEncoding = args[1]
+			default:
+				return errors.Errorf("unknown encoding target %q: expected key or value", args[0])
+			}
+			return nil
+		default:
+			return errors.New("usage: encoding [key|value] <encoding>")
+		}
+	case "help":
+		io.WriteString(k.stdout, replHelp)
+		return nil
+	case "quit", "exit":
+		return errQuitRepl
+	default:
+		return errors.Errorf("unknown command %q: type help for a list", cmd)
+	}
+}
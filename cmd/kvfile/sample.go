@@ -0,0 +1,85 @@
+package main
+
+import (
+	"math/rand/v2"
+
+	"github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// sampleCommand builds the sample command.
+func (k *kvfileCli) sampleCommand() *cli.Command {
+	count := 10
+	var prefix string
+	var seed uint64
+	var values bool
+	var format outputFormat
+	return &cli.Command{
+		Name:  "sample",
+		Usage: "Print entries chosen uniformly at random in key order.",
+		Flags: append([]cli.Flag{
+			&cli.IntFlag{
+				Name:        "count",
+				Aliases:     []string{"n"},
+				Usage:       "number of entries to print",
+				Value:       count,
+				Destination: &count,
+			},
+			&cli.StringFlag{
+				Name:        "prefix",
+				Usage:       "only sample the keys with the prefix",
+				Destination: &prefix,
+			},
+			&cli.Uint64Flag{
+				Name:        "seed",
+				Usage:       "seed for a reproducible sample (default: random)",
+				Destination: &seed,
+			},
+			&cli.BoolFlag{
+				Name:        "values",
+				Usage:       "print the values",
+				Destination: &values,
+			},
+		}, format.flags()...),
+		Action: func(c *cli.Context) error {
+			if count < 0 {
+				return errors.Errorf("invalid --count: %v", count)
+			}
+			prefixKey, err := k.parseKey("prefix", prefix)
+			if err != nil {
+				return err
+			}
+			printer, err := k.newEntryPrinter(&format)
+			if err != nil {
+				return err
+			}
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
+
+			rng := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+			if c.IsSet("seed") {
+				rng = rand.New(rand.NewPCG(seed, 0))
+			}
+			err = reader.SampleEntries(prefixKey, count, rng, func(indexEntry *kvfile.IndexEntry, indexEntryIdx int) error {
+				if !values {
+					return printer.printKey(indexEntry.GetKey())
+				}
+				val, err := reader.GetWithEntry(indexEntry, indexEntryIdx)
+				if err != nil {
+					return err
+				}
+				return printer.printEntry(indexEntry.GetKey(), val, true)
+			})
+			if err != nil {
+				return err
+			}
+			return printer.flush()
+		},
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// errStopScan stops a scan early.
+var errStopScan = errors.New("stop scan")
+
+// scanCommand builds the scan command.
+func (k *kvfileCli) scanCommand() *cli.Command {
+	var prefix string
+	var keysOnly bool
+	var limit int
+	var format outputFormat
+	return &cli.Command{
+		Name:  "scan",
+		Usage: "Print the key-value pairs with a key prefix.",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:        "prefix",
+				Usage:       "the key prefix to scan (default: all keys)",
+				Destination: &prefix,
+			},
+			&cli.BoolFlag{
+				Name:        "keys-only",
+				Usage:       "print the keys only",
+				Destination: &keysOnly,
+			},
+			&cli.IntFlag{
+				Name:        "limit",
+				Usage:       "maximum number of entries to print (default: no limit)",
+				Destination: &limit,
+			},
+		}, format.flags()...),
+		Action: func(c *cli.Context) error {
+			if limit < 0 {
+				return errors.Errorf("invalid --limit: %v", limit)
+			}
+			prefixKey, err := k.parseKey("prefix", prefix)
+			if err != nil {
+				return err
+			}
+			printer, err := k.newEntryPrinter(&format)
+			if err != nil {
+				return err
+			}
+
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
+
+			var count int
+			err = reader.ScanPrefixEntries(prefixKey, func(indexEntry *kvfile.IndexEntry, indexEntryIdx int) error {
+				if limit != 0 && count == limit {
+					return errStopScan
+				}
+				count++
+				if keysOnly {
+					return printer.printKey(indexEntry.GetKey())
+				}
+				value, err := reader.GetWithEntry(indexEntry, indexEntryIdx)
+				if err != nil {
+					return err
+				}
+				return printer.printEntry(indexEntry.GetKey(), value, true)
+			})
+			if err == errStopScan {
+				err = nil
+			}
+			if err != nil {
+				return err
+			}
+			return printer.flush()
+		},
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// sizeEntry is the size --json output.
+//
+// Keys which are valid UTF-8 are stored as strings, otherwise they are stored
+// base64 encoded in key_base64.
+type sizeEntry struct {
+	Key       string `json:"key,omitempty"`
+	KeyBase64 []byte `json:"key_base64,omitempty"`
+	Size      int64  `json:"size"`
+}
+
+// sizeCommand builds the size command.
+func (k *kvfileCli) sizeCommand() *cli.Command {
+	var keyIn keyInput
+	var jsonOut, human bool
+	return &cli.Command{
+		Name:  "size",
+		Usage: "Print the size of the value for a key without reading it: exits 1 if not found.",
+		Flags: append(keyIn.flags("the key to look up"),
+			&cli.BoolFlag{
+				Name:        "json",
+				Usage:       "print the key and size as JSON",
+				Destination: &jsonOut,
+			},
+			&cli.BoolFlag{
+				Name:        "human",
+				Usage:       "print the size in KiB, MiB, or GiB",
+				Destination: &human,
+			},
+		),
+		Action: func(c *cli.Context) error {
+			key, err := k.parseKeyInput(&keyIn)
+			if err != nil {
+				return err
+			}
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
+
+			size, err := reader.GetValueSize(key)
+			if err != nil {
+				return err
+			}
+			if size < 0 {
+				return errors.Errorf("Key %q not found.", formatData(key, k.keyEncoding))
+			}
+			if jsonOut {
+				entry := &sizeEntry{Size: size}
+				if utf8.Valid(key) {
+					entry.Key = string(key)
+				} else {
+					entry.KeyBase64 = key
+				}
+				data, err := json.Marshal(entry)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(k.stdout, "%s\n", data)
+				return nil
+			}
+			if human {
+				fmt.Fprintln(k.stdout, formatSize(size))
+				return nil
+			}
+			fmt.Fprintf(k.stdout, "%d\n", size)
+			return nil
+		},
+	}
+}
+
+// formatSize formats a size in bytes with binary units.
+func formatSize(size int64) string {
+	if size < 1024 {
+		return strconv.FormatInt(size, 10) + " B"
+	}
+	value, unit := float64(size)/1024, "KiB"
+	for _, next := range []string{"MiB", "GiB", "TiB"} {
+		if value < 1024 {
+			break
+		}
+		value, unit = value/1024, next
+	}
+	return strconv.FormatFloat(value, 'f', 1, 64) + " " + unit
+}
+
+// sizeUnits are the suffixes for byte sizes, in order of size.
+var sizeUnits = []string{"K", "M", "G", "T"}
+
+// byteSize is a flag value for a size in bytes with an optional K, M, G, or T
+// suffix, for example 512M.
+//
+// The suffixes are powers of 1024, are not case sensitive, and can be followed
+// by B or iB.
+type byteSize uint64
+
+// Set parses the size.
+func (s *byteSize) Set(value string) error {
+	num := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(value)), "B")
+	mult := uint64(1)
+	for i, unit := range sizeUnits {
+		if trimmed, ok := strings.CutSuffix(strings.TrimSuffix(num, "I"), unit); ok {
+			num, mult = trimmed, uint64(1)<<(10*(i+1))
+			break
+		}
+	}
+	n, err := strconv.ParseUint(num, 10, 64)
+	if err != nil || n > math.MaxInt64/mult {
+		return errors.Errorf("invalid size: %q", value)
+	}
+	*s = byteSize(n * mult)
+	return nil
+}
+
+// String returns the size in bytes or empty if zero.
+func (s *byteSize) String() string {
+	if s == nil || *s == 0 {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*s), 10)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aperturerobotics/go-kvfile"
+	protobuf_go_lite "github.com/aperturerobotics/protobuf-go-lite"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// splitShard is a shard of the split command.
+type splitShard struct {
+	// File is the name of the shard file in the output directory.
+	File string `json:"file"`
+	// Prefix is the key prefix of the shard with --by-prefix-depth.
+	Prefix *string `json:"prefix,omitempty"`
+	// FirstKey is the first key in the shard.
+	FirstKey string `json:"first_key"`
+	// LastKey is the last key in the shard.
+	LastKey string `json:"last_key"`
+	// Entries is the number of entries in the shard.
+	Entries uint64 `json:"entries"`
+
+	// prefix, firstKey, and lastKey are the prefix and first and last keys.
+	prefix, firstKey, lastKey []byte
+	// start is the index of the first entry of the shard in the input.
+	start uint64
+	// size is the size of the shard file.
+	size uint64
+	// valuesSize is the size of the values in the shard file.
+	valuesSize uint64
+}
+
+// splitManifest is the output of the split command.
+type splitManifest struct {
+	Shards []*splitShard `json:"shards"`
+}
+
+// splitCommand builds the split command.
+func (k *kvfileCli) splitCommand() *cli.Command {
+	var outputDir string
+	var maxBytes byteSize
+	var prefixDepth int
+	return &cli.Command{
+		Name:  "split",
+		Usage: "Split a kvfile into shards by size or key prefix, printing a JSON manifest.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output-dir",
+				Usage:       "directory to write the numbered shard files to",
+				Destination: &outputDir,
+			},
+			&cli.GenericFlag{
+				Name:  "max-bytes",
+				Usage: "maximum size of an uncompressed shard file, for example 512M or 1G",
+				Value: &maxBytes,
+			},
+			&cli.IntFlag{
+				Name:        "by-prefix-depth",
+				Usage:       "write the keys with the same first N /-separated segments to the same shards",
+				Destination: &prefixDepth,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if outputDir == "" {
+				return errors.New("please provide an output directory")
+			}
+			if prefixDepth < 0 {
+				return errors.Errorf("invalid --by-prefix-depth: %v", prefixDepth)
+			}
+			if maxBytes == 0 && prefixDepth == 0 {
+				return errors.New("please provide --max-bytes or --by-prefix-depth")
+			}
+			compressOpts, err := k.buildCompressOptions("")
+			if err != nil {
+				return err
+			}
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
+
+			shards, err := planSplit(reader, uint64(maxBytes), prefixDepth)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(outputDir, 0o755); err != nil {
+				return err
+			}
+			for _, shard := range shards {
+				err := createOutputFile(filepath.Join(outputDir, shard.File), func(file io.Writer) error {
+					return k.writeOutput(file, compressOpts, func(out io.Writer) error {
+						wr := kvfile.NewWriter(out)
+						for i := shard.start; i < shard.start+shard.Entries; i++ {
+							indexEntry, err := reader.ReadIndexEntry(i)
+							if err != nil {
+								return err
+							}
+							if err := wr.CopyEntry(reader, indexEntry, int(i)); err != nil {
+								return err
+							}
+						}
+						return wr.Close()
+					})
+				})
+				if err != nil {
+					return errors.Wrap(err, shard.File)
+				}
+				if prefixDepth != 0 {
+					prefix := formatData(shard.prefix, k.keyEncoding)
+					shard.Prefix = &prefix
+				}
+				shard.FirstKey = formatData(shard.firstKey, k.keyEncoding)
+				shard.LastKey = formatData(shard.lastKey, k.keyEncoding)
+			}
+
+			data, err := json.MarshalIndent(&splitManifest{Shards: shards}, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(k.stdout, "%s\n", data)
+			return nil
+		},
+	}
+}
+
+// planSplit reads the index and returns the shards for the entries.
+//
+// Starts a new shard when the key prefix changes if prefixDepth is set, and
+// before the size of the shard file would exceed maxBytes if set. All entries
+// are included with their metadata, expiry, and tombstones, including the
+// entries hidden by the reader.
+func planSplit(reader *kvfile.Reader, maxBytes uint64, prefixDepth int) ([]*splitShard, error) {
+	var shards []*splitShard
+	var shard *splitShard
+	size := reader.Size()
+	for i := uint64(0); i < size; i++ {
+		indexEntry, err := reader.ReadIndexEntry(i)
+		if err != nil {
+			return nil, err
+		}
+		key := indexEntry.GetKey()
+		var prefix []byte
+		if prefixDepth != 0 {
+			prefix = keyPrefix(key, prefixDepth)
+		}
+		// the size of the entry in the shard: the value, the index entry and
+		// its size varint, and the entry position
+		entrySize := func(valueOffset uint64) uint64 {
+			size := (&kvfile.IndexEntry{
+				Key:           key,
+				Offset:        valueOffset,
+				Size:          indexEntry.GetSize(),
+				ExpiresUnixMs: indexEntry.GetExpiresUnixMs(),
+				Meta:          indexEntry.GetMeta(),
+				Tombstone:     indexEntry.GetTombstone(),
+			}).SizeVT()
+			return indexEntry.GetSize() + uint64(size+protobuf_go_lite.SizeOfVarint(uint64(size))) + 8
+		}
+
+		newShard := shard == nil || (prefixDepth != 0 && !bytes.Equal(prefix, shard.prefix))
+		if !newShard && maxBytes != 0 && shard.size+entrySize(shard.valuesSize) > maxBytes {
+			newShard = true
+		}
+		if newShard {
+			// the empty shard file contains the entry count
+			shard = &splitShard{File: fmt.Sprintf("%05d.kv", len(shards)), prefix: prefix, firstKey: key, start: i, size: 8}
+			if maxBytes != 0 && shard.size+entrySize(0) > maxBytes {
+				return nil, errors.Errorf("entry %q does not fit in --max-bytes", key)
+			}
+			shards = append(shards, shard)
+		}
+		shard.lastKey = key
+		shard.Entries++
+		shard.size += entrySize(shard.valuesSize)
+		shard.valuesSize += indexEntry.GetSize()
+	}
+	return shards, nil
+}
+
+// keyPrefix returns the first depth /-separated segments of the key including
+// the trailing separator, or the key if it has fewer segments.
+func keyPrefix(key []byte, depth int) []byte {
+	var pos int
+	for range depth {
+		idx := bytes.IndexByte(key[pos:], '/')
+		if idx < 0 {
+			return key
+		}
+		pos += idx + 1
+	}
+	return key[:pos]
+}
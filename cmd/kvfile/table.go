@@ -0,0 +1,203 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultTableWidth is the width of tables if the terminal width is unknown.
+const defaultTableWidth = 80
+
+// tableGap separates the columns of a table.
+const tableGap = "  "
+
+// ANSI escapes used in tables.
+const (
+	ansiBold  = "\x1b[1m"
+	ansiDim   = "\x1b[2m"
+	ansiReset = "\x1b[0m"
+)
+
+// tableRow is a row of a table.
+type tableRow struct {
+	key string
+	// value is the value cell cut to two more columns than the table width so
+	// that it is longer than the table width if it was truncated
+	value string
+	// size is the size of the value in bytes
+	size int
+}
+
+// tableWriter buffers rows and prints them as an aligned table on flush.
+type tableWriter struct {
+	out       io.Writer
+	width     int
+	color     bool
+	withValue bool
+	rows      []tableRow
+}
+
+// terminalWidth returns the width of the terminal from $COLUMNS.
+func terminalWidth() int {
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	return defaultTableWidth
+}
+
+// sanitizeCell escapes control characters and invalid UTF-8 so that the cell
+// cannot change the state of the terminal.
+func sanitizeCell(s string) string {
+	var sb strings.Builder
+	for len(s) != 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		switch {
+		case r == utf8.RuneError && size == 1:
+			sb.WriteString(`\x`)
+			sb.WriteString(strconv.FormatUint(uint64(s[0])|0x100, 16)[1:])
+		case unicode.IsControl(r):
+			quoted := strconv.QuoteRune(r)
+			sb.WriteString(quoted[1 : len(quoted)-1])
+		default:
+			sb.WriteString(s[:size])
+		}
+		s = s[size:]
+	}
+	return sb.String()
+}
+
+// runeWidth returns the number of terminal columns used by the rune.
+//
+// East Asian wide and fullwidth runes use two columns.
+func runeWidth(r rune) int {
+	switch {
+	case r >= 0x1100 && r <= 0x115f,
+		r >= 0x2e80 && r <= 0xa4cf && r != 0x303f,
+		r >= 0xac00 && r <= 0xd7a3,
+		r >= 0xf900 && r <= 0xfaff,
+		r >= 0xfe30 && r <= 0xfe4f,
+		r >= 0xff00 && r <= 0xff60,
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x1f300 && r <= 0x1f64f,
+		r >= 0x1f900 && r <= 0x1f9ff,
+		r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// cellWidth returns the number of terminal columns used by the cell.
+func cellWidth(cell string) int {
+	var width int
+	for _, r := range cell {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// cutWidth returns the longest prefix of s using at most width columns.
+func cutWidth(s string, width int) string {
+	var used int
+	for i, r := range s {
+		used += runeWidth(r)
+		if used > width {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// truncateCell truncates the cell to width columns, ending with an ellipsis and
+// the suffix if truncated.
+//
+// The suffix is dropped if there is no room for it.
+func truncateCell(cell string, width int, suffix string) (string, bool) {
+	if cellWidth(cell) <= width {
+		return cell, false
+	}
+	marker := "…" + suffix
+	if cellWidth(marker) >= width {
+		marker = "…"
+	}
+	return cutWidth(cell, max(width-cellWidth(marker), 0)) + marker, true
+}
+
+// addRow adds a row with the formatted key and the formatted value if
+// withValue is set to the table.
+func (t *tableWriter) addRow(key, value string, size int, withValue bool) {
+	t.withValue = withValue
+	row := tableRow{key: sanitizeCell(key), size: size}
+	if withValue {
+		row.value = sanitizeCell(cutWidth(value, t.width+2))
+	}
+	t.rows = append(t.rows, row)
+}
+
+// flush prints the rows and clears the table.
+//
+// The key column is as wide as the widest key, up to half of the width if
+// there is a value column.
+func (t *tableWriter) flush() error {
+	keyHeader, valueHeader := "KEY", "VALUE"
+	keyWidth := cellWidth(keyHeader)
+	for _, row := range t.rows {
+		keyWidth = max(keyWidth, cellWidth(row.key))
+	}
+	keyWidth = min(keyWidth, t.width)
+	valueWidth := 0
+	if t.withValue {
+		keyWidth = max(min(keyWidth, (t.width-len(tableGap))/2), 1)
+		valueWidth = max(t.width-keyWidth-len(tableGap), 1)
+	}
+
+	var sb strings.Builder
+	writeCell := func(cell string, width int, pad bool) {
+		sb.WriteString(cell)
+		if pad {
+			sb.WriteString(strings.Repeat(" ", max(width-cellWidth(cell), 0)))
+		}
+	}
+	writeTruncated := func(cell string, width int, suffix string, pad bool) {
+		cell, truncated := truncateCell(cell, width, suffix)
+		if t.color && truncated {
+			i := strings.LastIndex(cell, "…")
+			sb.WriteString(cell[:i])
+			sb.WriteString(ansiDim + cell[i:] + ansiReset)
+			writeCell("", width-cellWidth(cell), pad)
+			return
+		}
+		writeCell(cell, width, pad)
+	}
+
+	if t.color {
+		sb.WriteString(ansiBold)
+	}
+	if t.withValue {
+		writeCell(keyHeader, keyWidth, true)
+		sb.WriteString(tableGap)
+		sb.WriteString(valueHeader)
+	} else {
+		sb.WriteString(keyHeader)
+	}
+	if t.color {
+		sb.WriteString(ansiReset)
+	}
+	sb.WriteString("\n")
+
+	for _, row := range t.rows {
+		writeTruncated(row.key, keyWidth, "", t.withValue)
+		if t.withValue {
+			sb.WriteString(tableGap)
+			writeTruncated(row.value, valueWidth, " ("+strconv.Itoa(row.size)+" bytes)", false)
+		}
+		sb.WriteString("\n")
+	}
+	t.rows = t.rows[:0]
+	_, err := io.WriteString(t.out, sb.String())
+	return err
+}
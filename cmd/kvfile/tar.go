@@ -0,0 +1,366 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aperturerobotics/go-kvfile"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// Compressions for the tar commands.
+const (
+	tarCompressionAuto = "auto"
+	tarCompressionNone = "none"
+	tarCompressionGzip = "gzip"
+	tarCompressionZstd = "zstd"
+)
+
+// Magic numbers at the start of compressed tar streams.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// tarFileMode is the mode of the files written by to-tar.
+const tarFileMode = 0o644
+
+// fromTarCommand builds the from-tar command.
+func (k *kvfileCli) fromTarCommand() *cli.Command {
+	var inputPath string
+	compression := tarCompressionAuto
+	onConflict := conflictError
+	var progress bool
+	return &cli.Command{
+		Name:  "from-tar",
+		Usage: "Write a new kvfile from the regular files in a tar read from stdin or --input.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "input",
+				Usage:       "path to the tar to read (default: stdin)",
+				Destination: &inputPath,
+			},
+			&cli.StringFlag{
+				Name:        "compression",
+				Usage:       "compression of the tar: auto, none, gzip, or zstd",
+				Value:       compression,
+				Destination: &compression,
+			},
+			&cli.StringFlag{
+				Name:        "on-conflict",
+				Usage:       "policy for names in the tar multiple times: first-wins, last-wins, or error",
+				Value:       onConflict,
+				Destination: &onConflict,
+			},
+			k.progressFlag(&progress),
+		},
+		Action: func(c *cli.Context) error {
+			if k.filePath == "" {
+				return errors.New("please provide a file path")
+			}
+			switch onConflict {
+			case conflictFirstWins, conflictLastWins, conflictError:
+			default:
+				return errors.Errorf("invalid --on-conflict: %q", onConflict)
+			}
+			compressOpts, err := k.buildCompressOptions("")
+			if err != nil {
+				return err
+			}
+
+			in := k.stdin
+			if inputPath != "" {
+				file, err := os.Open(inputPath)
+				if err != nil {
+					return errors.Wrap(err, "read input")
+				}
+				defer file.Close()
+				in = file
+			}
+			in, closeIn, err := decompressTar(in, compression)
+			if err != nil {
+				return err
+			}
+			defer closeIn()
+
+			// last-wins needs to know the last entry for each name before
+			// writing: stage the tar to a temporary file and read it twice.
+			var keep map[string]int
+			if onConflict == conflictLastWins {
+				stage, err := os.CreateTemp("", "kvfile-tar-*")
+				if err != nil {
+					return err
+				}
+				defer os.Remove(stage.Name())
+				defer stage.Close()
+				if _, err := io.Copy(stage, in); err != nil {
+					return errors.Wrap(err, "read input")
+				}
+				if _, err := stage.Seek(0, io.SeekStart); err != nil {
+					return err
+				}
+				keep, err = lastTarEntries(stage)
+				if err != nil {
+					return err
+				}
+				if _, err := stage.Seek(0, io.SeekStart); err != nil {
+					return err
+				}
+				in = stage
+			}
+
+			reporter := k.newProgressReporter(progress)
+			defer reporter.finish()
+			return createOutputFile(k.filePath, func(file io.Writer) error {
+				return k.writeOutput(file, compressOpts, func(out io.Writer) error {
+					wr, err := reporter.newWriter(out)
+					if err != nil {
+						return err
+					}
+					return importTar(wr, in, onConflict, keep, k.stderr)
+				})
+			})
+		},
+	}
+}
+
+// toTarCommand builds the to-tar command.
+func (k *kvfileCli) toTarCommand() *cli.Command {
+	var outputPath, mtime string
+	compression := tarCompressionAuto
+	return &cli.Command{
+		Name:  "to-tar",
+		Usage: "Write the key-value pairs as a tar of regular files to stdout or --output.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output",
+				Usage:       "path to write the tar to (default: stdout)",
+				Destination: &outputPath,
+			},
+			&cli.StringFlag{
+				Name:        "compression",
+				Usage:       "compression of the tar: auto (from the --output extension), none, gzip, or zstd",
+				Value:       compression,
+				Destination: &compression,
+			},
+			&cli.StringFlag{
+				Name:        "mtime",
+				Usage:       "modification time of the files in RFC 3339 format (default: the modification time of the kvfile)",
+				Destination: &mtime,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if compression == tarCompressionAuto {
+				compression = tarCompressionFromPath(outputPath)
+			}
+			switch compression {
+			case tarCompressionNone, tarCompressionGzip, tarCompressionZstd:
+			default:
+				return errors.Errorf("invalid --compression: %q", compression)
+			}
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
+
+			var modTime time.Time
+			if mtime != "" {
+				modTime, err = time.Parse(time.RFC3339, mtime)
+				if err != nil {
+					return errors.Wrap(err, "invalid --mtime")
+				}
+			} else {
+				info, err := os.Stat(k.filePath)
+				if err != nil {
+					return err
+				}
+				modTime = info.ModTime()
+			}
+
+			writeTar := func(out io.Writer) error {
+				return exportTar(out, reader, compression, modTime)
+			}
+			if outputPath == "" {
+				return writeTar(k.stdout)
+			}
+			return createOutputFile(outputPath, writeTar)
+		},
+	}
+}
+
+// tarCompressionFromPath returns the compression for a tar at path from the
+// extension.
+func tarCompressionFromPath(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".gz"), strings.HasSuffix(path, ".tgz"):
+		return tarCompressionGzip
+	case strings.HasSuffix(path, ".zst"), strings.HasSuffix(path, ".tzst"):
+		return tarCompressionZstd
+	default:
+		return tarCompressionNone
+	}
+}
+
+// decompressTar returns a reader for the decompressed tar.
+//
+// If compression is auto, the compression is detected from the first bytes.
+func decompressTar(in io.Reader, compression string) (io.Reader, func(), error) {
+	if compression == tarCompressionAuto {
+		br := bufio.NewReader(in)
+		magic, err := br.Peek(len(zstdMagic))
+		if err != nil && err != io.EOF {
+			return nil, nil, errors.Wrap(err, "read input")
+		}
+		switch {
+		case bytes.HasPrefix(magic, gzipMagic):
+			compression = tarCompressionGzip
+		case bytes.HasPrefix(magic, zstdMagic):
+			compression = tarCompressionZstd
+		default:
+			compression = tarCompressionNone
+		}
+		in = br
+	}
+
+	switch compression {
+	case tarCompressionNone:
+		return in, func() {}, nil
+	case tarCompressionGzip:
+		gzr, err := gzip.NewReader(in)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "read gzip")
+		}
+		return gzr, func() { _ = gzr.Close() }, nil
+	case tarCompressionZstd:
+		zr, err := zstd.NewReader(in)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "read zstd")
+		}
+		return zr, zr.Close, nil
+	default:
+		return nil, nil, errors.Errorf("invalid --compression: %q", compression)
+	}
+}
+
+// lastTarEntries returns the index of the last regular file in the tar for
+// each name.
+func lastTarEntries(in io.Reader) (map[string]int, error) {
+	last := make(map[string]int)
+	tr := tar.NewReader(in)
+	for i := 0; ; i++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return last, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "read tar")
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			last[hdr.Name] = i
+		}
+	}
+}
+
+// importTar writes the regular files in the tar read from in to the writer
+// and closes the writer.
+//
+// Other entries are skipped with a warning written to warn. If keep is set,
+// only the entry at the index in keep is written for each name.
+func importTar(wr *kvfile.Writer, in io.Reader, onConflict string, keep map[string]int, warn io.Writer) error {
+	// seen maps each name to the index of the first entry with the name
+	seen := make(map[string]int)
+	tr := tar.NewReader(in)
+	for i := 0; ; i++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "read tar")
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			fmt.Fprintf(warn, "skipping %s: not a regular file\n", hdr.Name)
+			continue
+		}
+		if keep != nil {
+			if keep[hdr.Name] != i {
+				continue
+			}
+		} else if prev, ok := seen[hdr.Name]; ok {
+			if onConflict == conflictError {
+				return errors.Errorf("duplicate name %q in tar: entries %d and %d", hdr.Name, prev+1, i+1)
+			}
+			continue
+		}
+		seen[hdr.Name] = i
+		if err := wr.WriteValue([]byte(hdr.Name), tr); err != nil {
+			return err
+		}
+	}
+	return wr.Close()
+}
+
+// exportTar writes the key-value pairs from the reader to out as a tar of
+// regular files with the compression.
+//
+// Values are streamed from the reader. Entries hidden by the reader are skipped.
+func exportTar(out io.Writer, reader *kvfile.Reader, compression string, modTime time.Time) error {
+	var zw io.WriteCloser
+	switch compression {
+	case tarCompressionGzip:
+		zw = gzip.NewWriter(out)
+	case tarCompressionZstd:
+		var err error
+		zw, err = zstd.NewWriter(out)
+		if err != nil {
+			return err
+		}
+	}
+	if zw != nil {
+		out = zw
+	}
+
+	tw := tar.NewWriter(out)
+	modTime = modTime.Truncate(time.Second)
+	err := reader.ScanPrefixEntries(nil, func(indexEntry *kvfile.IndexEntry, indexEntryIdx int) error {
+		valueRdr, err := reader.GetValueReaderWithEntry(indexEntry, indexEntryIdx)
+		if err != nil {
+			return err
+		}
+		key := indexEntry.GetKey()
+		err = tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     string(key),
+			Mode:     tarFileMode,
+			Size:     valueRdr.Size(),
+			ModTime:  modTime,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "write tar header for %q", key)
+		}
+		_, err = io.Copy(tw, valueRdr)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if zw != nil {
+		return zw.Close()
+	}
+	return nil
+}
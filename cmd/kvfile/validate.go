@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// Key checks for the validate-keys command.
+const (
+	keyCheckUTF8       = "utf8"
+	keyCheckLength     = "length"
+	keyCheckWhitespace = "whitespace"
+	keyCheckCase       = "case"
+)
+
+// keyChecks are the key checks in report order.
+var keyChecks = []string{keyCheckUTF8, keyCheckLength, keyCheckWhitespace, keyCheckCase}
+
+// keyIssue is a key failing a check.
+//
+// Keys which are valid UTF-8 are stored as strings, otherwise they are stored
+// base64 encoded in key_base64.
+type keyIssue struct {
+	Key       string `json:"key,omitempty"`
+	KeyBase64 []byte `json:"key_base64,omitempty"`
+	// DuplicateOf is the first key equal to the key ignoring case.
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+}
+
+// keyCheckResult is the result of a check.
+type keyCheckResult struct {
+	Check string `json:"check"`
+	// Count is the number of keys failing the check.
+	Count uint64 `json:"count"`
+	// Keys are the first keys failing the check up to the limit.
+	Keys []*keyIssue `json:"keys,omitempty"`
+}
+
+// validateKeysCommand builds the validate-keys command.
+func (k *kvfileCli) validateKeysCommand() *cli.Command {
+	checks := strings.Join(keyChecks, ",")
+	maxLength, limit := 1024, 10
+	var jsonOut bool
+	return &cli.Command{
+		Name:  "validate-keys",
+		Usage: "Check the keys for invalid UTF-8, length, whitespace, and case duplicates: exits 1 if a check fails.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "checks",
+				Usage:       "comma-separated checks to run: utf8, length, whitespace, and case",
+				Value:       checks,
+				Destination: &checks,
+			},
+			&cli.IntFlag{
+				Name:        "max-length",
+				Usage:       "maximum key length in bytes for the length check",
+				Value:       maxLength,
+				Destination: &maxLength,
+			},
+			&cli.IntFlag{
+				Name:        "limit",
+				Usage:       "maximum number of keys to list per check",
+				Value:       limit,
+				Destination: &limit,
+			},
+			&cli.BoolFlag{
+				Name:        "json",
+				Usage:       "print the results as JSON",
+				Destination: &jsonOut,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if maxLength < 0 {
+				return errors.Errorf("invalid --max-length: %v", maxLength)
+			}
+			if limit < 0 {
+				return errors.Errorf("invalid --limit: %v", limit)
+			}
+			results := make(map[string]*keyCheckResult)
+			for _, check := range strings.Split(checks, ",") {
+				check = strings.TrimSpace(check)
+				if !slices.Contains(keyChecks, check) {
+					return errors.Errorf("invalid --checks: unknown check %q", check)
+				}
+				results[check] = &keyCheckResult{Check: check}
+			}
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
+
+			add := func(check string, issue *keyIssue) {
+				if result := results[check]; result != nil {
+					result.Count++
+					if len(result.Keys) < limit {
+						result.Keys = append(result.Keys, issue)
+					}
+				}
+			}
+			// folded maps the lower case keys to the first key
+			var folded map[string]string
+			if results[keyCheckCase] != nil {
+				folded = make(map[string]string)
+			}
+			size := reader.Size()
+			for i := uint64(0); i < size; i++ {
+				indexEntry, err := reader.ReadIndexEntry(i)
+				if err != nil {
+					return err
+				}
+				key := indexEntry.GetKey()
+				issue := &keyIssue{Key: string(key)}
+				if !utf8.Valid(key) {
+					issue = &keyIssue{KeyBase64: key}
+					add(keyCheckUTF8, issue)
+				}
+				if len(key) > maxLength {
+					add(keyCheckLength, issue)
+				}
+				if len(bytes.TrimFunc(key, unicode.IsSpace)) != len(key) {
+					add(keyCheckWhitespace, issue)
+				}
+				if folded != nil && issue.KeyBase64 == nil {
+					lower := strings.ToLower(issue.Key)
+					if first, ok := folded[lower]; ok {
+						add(keyCheckCase, &keyIssue{Key: issue.Key, DuplicateOf: first})
+					} else {
+						folded[lower] = issue.Key
+					}
+				}
+			}
+
+			var report []*keyCheckResult
+			var failed int
+			for _, check := range keyChecks {
+				if result := results[check]; result != nil {
+					report = append(report, result)
+					if result.Count != 0 {
+						failed++
+					}
+				}
+			}
+			if err := k.printKeyChecks(report, jsonOut); err != nil {
+				return err
+			}
+			if failed != 0 {
+				return errors.Errorf("%d key checks failed", failed)
+			}
+			return nil
+		},
+	}
+}
+
+// printKeyChecks prints the results of the key checks.
+func (k *kvfileCli) printKeyChecks(report []*keyCheckResult, jsonOut bool) error {
+	if jsonOut {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(k.stdout, "%s\n", data)
+		return nil
+	}
+	for _, result := range report {
+		fmt.Fprintf(k.stdout, "%s: %d\n", result.Check, result.Count)
+		for _, issue := range result.Keys {
+			key := []byte(issue.Key)
+			if issue.KeyBase64 != nil {
+				key = issue.KeyBase64
+			}
+			if issue.DuplicateOf != "" {
+				fmt.Fprintf(k.stdout, "  %q (duplicate of %q)\n", key, issue.DuplicateOf)
+				continue
+			}
+			fmt.Fprintf(k.stdout, "  %q\n", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/urfave/cli/v2"
+)
+
+// verifyCommand builds the verify command.
+func (k *kvfileCli) verifyCommand() *cli.Command {
+	var deep, checksums bool
+	return &cli.Command{
+		Name:  "verify",
+		Usage: "Check the structure of a k/v file, exiting 1 if a problem is found.",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:        "deep",
+				Usage:       "read every value",
+				Destination: &deep,
+			},
+			&cli.BoolFlag{
+				Name:        "checksums",
+				Usage:       "verify the checksums of compressed kvfiles by reading every value (plain kvfiles have no checksums)",
+				Destination: &checksums,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			reader, rel, err := k.openKVFile()
+			if rel != nil {
+				defer rel()
+			}
+			if err != nil {
+				return err
+			}
+
+			// the frame checksums are verified when the values are read
+			return reader.Verify(deep || checksums)
+		},
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	kvfile_compress "github.com/aperturerobotics/go-kvfile/compress"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// writeCommand builds the write command.
+func (k *kvfileCli) writeCommand() *cli.Command {
+	var jsonStr, inputPath, compressLevel string
+	var progress bool
+	return &cli.Command{
+		Name:  "write",
+		Usage: "Write a new kvfile from JSON input.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "json",
+				Usage:       "the JSON data to write",
+				Destination: &jsonStr,
+			},
+			&cli.StringFlag{
+				Name:        "input",
+				Usage:       "path to a file with the JSON data to write (default: stdin)",
+				Destination: &inputPath,
+			},
+			&cli.StringFlag{
+				Name:        "compress-level",
+				Usage:       "zstd level with --compress: fastest, default, better, or best (default: best)",
+				Destination: &compressLevel,
+			},
+			k.progressFlag(&progress),
+		},
+		Action: func(c *cli.Context) error {
+			if k.filePath == "" {
+				return errors.New("please provide a file path")
+			}
+			if jsonStr != "" && inputPath != "" {
+				return errors.New("--json and --input cannot be used together")
+			}
+			compressOpts, err := k.buildCompressOptions(compressLevel)
+			if err != nil {
+				return err
+			}
+
+			var input io.Reader
+			switch {
+			case jsonStr != "":
+				input = strings.NewReader(jsonStr)
+			case inputPath != "":
+				file, err := os.Open(inputPath)
+				if err != nil {
+					return errors.Wrap(err, "read input")
+				}
+				defer file.Close()
+				input = file
+			default:
+				input = k.stdin
+			}
+
+			// the output file is removed if the input is invalid
+			reporter := k.newProgressReporter(progress)
+			defer reporter.finish()
+			return createOutputFile(k.filePath, func(file io.Writer) error {
+				return k.writeOutput(file, compressOpts, func(out io.Writer) error {
+					wr, err := reporter.newWriter(out)
+					if err != nil {
+						return err
+					}
+					err = readJSONObject(input, func(key, value string) error {
+						return wr.WriteValue([]byte(key), strings.NewReader(value))
+					})
+					if err != nil {
+						return err
+					}
+					return wr.Close()
+				})
+			})
+		},
+	}
+}
+
+// readJSONObject reads a JSON object of string values, calling the callback
+// with each key and value as they are decoded.
+//
+// Only one value is held in memory at a time. Duplicate keys are passed to the
+// callback.
+func readJSONObject(in io.Reader, cb func(key, value string) error) error {
+	dec := json.NewDecoder(in)
+	// readErr wraps the decoder error as invalid JSON or an input error
+	readErr := func(err error) error {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case err == io.EOF || err == io.ErrUnexpectedEOF:
+			return errors.New("invalid JSON input: unexpected end of input")
+		case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+			return errors.Wrap(err, "invalid JSON input")
+		default:
+			return errors.Wrap(err, "read input")
+		}
+	}
+
+	if tok, err := dec.Token(); err != nil {
+		return readErr(err)
+	} else if tok != json.Delim('{') {
+		return errors.Errorf("invalid JSON input: expected an object, found %v", tok)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return readErr(err)
+		}
+		key := tok.(string)
+		var value string
+		if err := dec.Decode(&value); err != nil {
+			return readErr(err)
+		}
+		if err := cb(key, value); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return readErr(err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		if err != nil {
+			return readErr(err)
+		}
+		return errors.New("invalid JSON input: unexpected data after the object")
+	}
+	return nil
+}
+
+// buildCompressOptions builds the compress options from the --compress flag
+// and the compress level.
+//
+// Returns nil if not writing compressed files.
+func (k *kvfileCli) buildCompressOptions(compressLevel string) (*kvfile_compress.CompressOptions, error) {
+	if !k.compressed {
+		if compressLevel != "" {
+			return nil, errors.New("--compress-level requires --compress")
+		}
+		return nil, nil
+	}
+	opts := &kvfile_compress.CompressOptions{}
+	if compressLevel != "" {
+		ok, level := zstd.EncoderLevelFromString(compressLevel)
+		if !ok {
+			return nil, errors.Errorf("invalid --compress-level: %q", compressLevel)
+		}
+		opts.Level = level
+	}
+	return opts, nil
+}
+
+// writeOutput calls the callback with the output writer, compressing the
+// output if compressOpts is set.
+func (k *kvfileCli) writeOutput(out io.Writer, compressOpts *kvfile_compress.CompressOptions, cb func(out io.Writer) error) error {
+	if compressOpts == nil {
+		return cb(out)
+	}
+	return kvfile_compress.UseCompressedWriterWithOptions(out, compressOpts, cb)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aperturerobotics/go-kvfile"
+	kvfile_gen "github.com/aperturerobotics/go-kvfile/gen"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// Input formats.
+const (
+	formatKVFile = "kvfile"
+	formatJSONL  = "jsonl"
+)
+
+func main() {
+	os.Exit(run(os.Args, os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the CLI with the arguments and streams and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	err := newApp(stdin, stdout, stderr).Run(args)
+	if err == nil {
+		return 0
+	}
+	if msg := err.Error(); msg != "" {
+		io.WriteString(stderr, msg+"\n")
+	}
+	var exitErr cli.ExitCoder
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// newApp builds the CLI app.
+func newApp(stdin io.Reader, stdout, stderr io.Writer) *cli.App {
+	var inputPath, inputFormat, outputPath string
+	var getters cli.StringSlice
+	opts := kvfile_gen.Options{Name: kvfile_gen.DefaultName}
+	return &cli.App{
+		Name:      "kvfilegen",
+		Usage:     "Generate a Go source file embedding a kvfile",
+		UsageText: "kvfilegen --package <name> [options]",
+		Authors: []*cli.Author{
+			{Name: "Christian Stewart", Email: "christian@aperture.us"},
+		},
+		Reader:    stdin,
+		Writer:    stdout,
+		ErrWriter: stderr,
+		// there are no subcommands
+		HideHelpCommand: true,
+		// errors are printed by run
+		ExitErrHandler: func(c *cli.Context, err error) {},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "input",
+				Aliases:     []string{"i"},
+				Usage:       "path to the kvfile or JSON Lines to read (default: stdin)",
+				Destination: &inputPath,
+			},
+			&cli.StringFlag{
+				Name:        "format",
+				Usage:       "input format: kvfile or jsonl (default: jsonl for .jsonl and .json inputs, otherwise kvfile)",
+				Destination: &inputFormat,
+			},
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Usage:       "path to write the Go source to (default: stdout)",
+				Destination: &outputPath,
+			},
+			&cli.StringFlag{
+				Name:        "package",
+				Usage:       "package name of the generated file",
+				Required:    true,
+				Destination: &opts.Package,
+			},
+			&cli.StringFlag{
+				Name:        "name",
+				Usage:       "name of the function returning the Reader",
+				Value:       opts.Name,
+				Destination: &opts.Name,
+			},
+			&cli.StringSliceFlag{
+				Name:        "getter",
+				Usage:       "generate a getter for a key: Name=key or Name:type=key with type bytes or string, can be repeated",
+				Destination: &getters,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			for _, getter := range getters.Value() {
+				g, err := parseGetter(getter)
+				if err != nil {
+					return err
+				}
+				opts.Getters = append(opts.Getters, g)
+			}
+			data, err := readInput(stdin, inputPath, inputFormat)
+			if err != nil {
+				return err
+			}
+			src, err := kvfile_gen.GenerateSource(data, opts)
+			if err != nil {
+				return err
+			}
+			if outputPath == "" {
+				_, err = stdout.Write(src)
+				return err
+			}
+			return os.WriteFile(outputPath, src, 0o644)
+		},
+	}
+}
+
+// parseGetter parses a Name=key or Name:type=key getter flag.
+func parseGetter(value string) (kvfile_gen.Getter, error) {
+	name, key, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return kvfile_gen.Getter{}, errors.Errorf("invalid --getter: %q: expected Name=key or Name:type=key", value)
+	}
+	name, getterType, _ := strings.Cut(name, ":")
+	return kvfile_gen.Getter{Name: name, Key: []byte(key), Type: kvfile_gen.GetterType(getterType)}, nil
+}
+
+// readInput reads the input and converts JSON Lines to a kvfile.
+func readInput(stdin io.Reader, inputPath, inputFormat string) ([]byte, error) {
+	in := stdin
+	if inputPath != "" {
+		file, err := os.Open(inputPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "read input")
+		}
+		defer file.Close()
+		in = file
+	}
+	if inputFormat == "" {
+		inputFormat = formatKVFile
+		switch filepath.Ext(inputPath) {
+		case ".jsonl", ".json":
+			inputFormat = formatJSONL
+		}
+	}
+	switch inputFormat {
+	case formatKVFile:
+		data, err := io.ReadAll(in)
+		if err != nil {
+			return nil, errors.Wrap(err, "read input")
+		}
+		return data, nil
+	case formatJSONL:
+		var buf bytes.Buffer
+		if err := kvfile.ImportJSONL(&buf, in); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, errors.Errorf("invalid --format: %q: expected kvfile or jsonl", inputFormat)
+	}
+}
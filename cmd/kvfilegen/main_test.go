@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aperturerobotics/go-kvfile"
+)
+
+// runCli runs the CLI with the stdin and arguments.
+//
+// Returns stdout, stderr, and the exit code.
+func runCli(t testing.TB, stdin io.Reader, args ...string) (string, string, int) {
+	if stdin == nil {
+		stdin = strings.NewReader("")
+	}
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"kvfilegen"}, args...), stdin, &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+func TestGenerate(t *testing.T) {
+	jsonl := "{\"key\":\"hello\",\"value\":\"world\"}\n{\"key\":\"a=b\",\"value\":\"c\"}\n"
+	stdout, stderr, code := runCli(t, strings.NewReader(jsonl),
+		"--package", "tables", "--format", "jsonl",
+		"--getter", "Hello:string=hello", "--getter", "AB=a=b",
+	)
+	if code != 0 {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr)
+	}
+	for _, expected := range []string{
+		"package tables\n",
+		"func Reader() (*kvfile.Reader, error) {",
+		"func Hello() (string, error) {",
+		"func AB() ([]byte, error) {",
+		`rd.Get([]byte("a=b"))`,
+	} {
+		if !strings.Contains(stdout, expected) {
+			t.Fatalf("expected %q in the output:\n%s", expected, stdout)
+		}
+	}
+
+	// kvfile input detected by extension and output to a file
+	dir := t.TempDir()
+	inPath, outPath := filepath.Join(dir, "table.kvf"), filepath.Join(dir, "table.go")
+	var buf bytes.Buffer
+	if err := kvfile.ImportJSONL(&buf, strings.NewReader(jsonl)); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.WriteFile(inPath, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	_, stderr, code = runCli(t, nil, "--package", "tables", "--name", "Table", "-i", inPath, "-o", outPath)
+	if code != 0 {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr)
+	}
+	src, err := os.ReadFile(outPath)
+	if err != nil || !strings.Contains(string(src), "func Table() (*kvfile.Reader, error) {") {
+		t.Fatalf("unexpected output file: %v\n%s", err, src)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"--format", "jsonl"},
+		{"--package", "tables", "--format", "csv"},
+		{"--package", "tables", "--format", "jsonl", "--getter", "Hello"},
+		{"--package", "tables", "--format", "jsonl", "--getter", "Missing=missing"},
+	} {
+		_, stderr, code := runCli(t, strings.NewReader("{\"key\":\"hello\",\"value\":\"world\"}\n"), args...)
+		if code == 0 || stderr == "" {
+			t.Fatalf("expected an error for %v", args)
+		}
+	}
+}
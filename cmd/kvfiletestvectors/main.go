@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	kvfile_testvectors "github.com/aperturerobotics/go-kvfile/testvectors"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+func main() {
+	os.Exit(run(os.Args, os.Stdout, os.Stderr))
+}
+
+// run runs the CLI with the arguments and streams and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	err := newApp(stdout, stderr).Run(args)
+	if err == nil {
+		return 0
+	}
+	if msg := err.Error(); msg != "" {
+		io.WriteString(stderr, msg+"\n")
+	}
+	var exitErr cli.ExitCoder
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// newApp builds the CLI app.
+func newApp(stdout, stderr io.Writer) *cli.App {
+	var outputDir string
+	return &cli.App{
+		Name:      "kvfiletestvectors",
+		Usage:     "Generate kvfile test vectors and a JSON manifest",
+		UsageText: "kvfiletestvectors --output <dir>",
+		Authors: []*cli.Author{
+			{Name: "Christian Stewart", Email: "christian@aperture.us"},
+		},
+		Writer:    stdout,
+		ErrWriter: stderr,
+		// there are no subcommands
+		HideHelpCommand: true,
+		// errors are printed by run
+		ExitErrHandler: func(c *cli.Context, err error) {},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Usage:       "directory to write the vectors and " + kvfile_testvectors.ManifestName + " to",
+				Required:    true,
+				Destination: &outputDir,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return kvfile_testvectors.WriteDir(outputDir)
+		},
+	}
+}
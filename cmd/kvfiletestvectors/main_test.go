@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	kvfile_testvectors "github.com/aperturerobotics/go-kvfile/testvectors"
+)
+
+func TestWriteVectors(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "vectors")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"kvfiletestvectors", "-o", dir}, &stdout, &stderr); code != 0 {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	data, err := os.ReadFile(filepath.Join(dir, kvfile_testvectors.ManifestName))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Contains(data, []byte(`"name": "empty"`)) {
+		t.Fatalf("unexpected manifest:\n%s", data)
+	}
+
+	// --output is required
+	stderr.Reset()
+	if code := run([]string{"kvfiletestvectors"}, &stdout, &stderr); code == 0 {
+		t.Fatal("expected an error without --output")
+	}
+}
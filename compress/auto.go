@@ -0,0 +1,99 @@
+package kvfile_compress
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+)
+
+// zstdFrameMagic is the magic number at the start of a zstd frame.
+var zstdFrameMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// zstdSkippableFrameMagic is the magic number of a zstd skippable frame.
+// The low 4 bits are user-defined.
+const zstdSkippableFrameMagic uint32 = 0x184d2a50
+
+// IsCompressed checks if the data at the start of a file is zstd compressed.
+//
+// Checks for a zstd frame or skippable frame magic number. A compressed file
+// with no data starts with the skippable seek table frame.
+func IsCompressed(head []byte) bool {
+	if len(head) < 4 {
+		return false
+	}
+	if bytes.Equal(head[:4], zstdFrameMagic) {
+		return true
+	}
+	return binary.LittleEndian.Uint32(head[:4])&^0xf == zstdSkippableFrameMagic
+}
+
+// BuildAutoReader builds a reader for a plain or compressed kvfile.
+//
+// Detects compression by checking for the zstd magic number at the start of
+// the file. Compressed files without the seekable zstd footer at the end are
+// opened with the hybrid layout (see OpenHybridReader). A plain kvfile with a
+// first value starting with the magic number looks compressed: if opening the
+// file as compressed fails it is opened as a plain kvfile instead.
+//
+// Returns the reader, a function to call to release the reader, and if the
+// file was detected as compressed. The release function is never nil.
+func BuildAutoReader(rd ReadSeekerAt, size uint64) (*kvfile.Reader, func(), bool, error) {
+	return BuildAutoReaderWithOptions(rd, size, nil)
+}
+
+// BuildAutoReaderWithOptions builds a reader for a plain or compressed kvfile
+// with the options.
+//
+// opts can be nil to use the defaults. Plain kvfiles use opts.ReaderOptions.
+// See BuildAutoReader.
+func BuildAutoReaderWithOptions(rd ReadSeekerAt, size uint64, opts *CompressReaderOptions) (*kvfile.Reader, func(), bool, error) {
+	head := make([]byte, 4)
+	if size >= uint64(len(head)) {
+		if _, err := rd.ReadAt(head, 0); err != nil {
+			return nil, func() {}, false, err
+		}
+	}
+	if !IsCompressed(head) {
+		kvReader, err := kvfile.BuildReaderWithOptions(rd, size, opts.readerOptions())
+		if err != nil {
+			return nil, func() {}, false, errors.Wrap(err, "open kvfile")
+		}
+		return kvReader, func() {}, false, nil
+	}
+
+	kvReader, rel, err := buildCompressedAutoReader(rd, size, opts)
+	if err == nil {
+		return kvReader, rel, true, nil
+	}
+	// a plain kvfile with a first value starting with the zstd magic
+	if plainReader, plainErr := kvfile.BuildReaderWithOptions(rd, size, opts.readerOptions()); plainErr == nil {
+		return plainReader, func() {}, false, nil
+	}
+	return nil, func() {}, true, err
+}
+
+// buildCompressedAutoReader opens a file detected as compressed with the
+// seekable or hybrid layout.
+func buildCompressedAutoReader(rd ReadSeekerAt, size uint64, opts *CompressReaderOptions) (*kvfile.Reader, func(), error) {
+	seekableTail, err := isSeekableTail(rd, size)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !seekableTail {
+		r, err := OpenHybridReaderWithOptions(rd, size, opts)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "open hybrid compressed kvfile")
+		}
+		return r.Reader, func() {
+			_ = r.Close()
+		}, nil
+	}
+
+	kvReader, rel, err := BuildCompressReaderWithOptions(rd, opts)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "open compressed kvfile")
+	}
+	return kvReader, rel, nil
+}
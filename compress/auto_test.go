@@ -0,0 +1,156 @@
+package kvfile_compress
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+)
+
+func TestBuildAutoReader(t *testing.T) {
+	keys := [][]byte{[]byte("test-1"), []byte("test-2")}
+	writeValue := func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write(append([]byte("val-"), key...))
+		return uint64(nw), err
+	}
+
+	var plainBuf, compressedBuf, emptyBuf bytes.Buffer
+	if err := kvfile.Write(&plainBuf, keys, writeValue); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := WriteCompress(&compressedBuf, keys, writeValue); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := UseCompressedWriter(&emptyBuf, func(writer io.Writer) error { return nil }); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, tc := range []struct {
+		data       []byte
+		compressed bool
+		size       uint64
+	}{
+		{plainBuf.Bytes(), false, 2},
+		{compressedBuf.Bytes(), true, 2},
+		{emptyBuf.Bytes(), true, 0},
+		{nil, false, 0},
+	} {
+		rdr, rel, compressed, err := BuildAutoReader(bytes.NewReader(tc.data), uint64(len(tc.data)))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if compressed != tc.compressed {
+			t.Fatalf("expected compressed = %v", tc.compressed)
+		}
+		if rdr.Size() != tc.size {
+			t.Fatalf("unexpected size: %v", rdr.Size())
+		}
+		if tc.size != 0 {
+			val, found, err := rdr.Get(keys[1])
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if !found || string(val) != "val-test-2" {
+				t.Fatalf("unexpected value: %v %s", found, string(val))
+			}
+		}
+		rel()
+	}
+
+	// not a kvfile
+	notKvfile := []byte("this is not a kvfile")
+	_, rel, compressed, err := BuildAutoReader(bytes.NewReader(notKvfile), uint64(len(notKvfile)))
+	if err == nil || compressed || !strings.HasPrefix(err.Error(), "open kvfile: ") {
+		t.Fatalf("expected plain kvfile error: %v %v", compressed, err)
+	}
+	rel()
+
+	// corrupt compressed kvfile: without the seekable footer opened as hybrid
+	corrupt := append(bytes.Clone(zstdFrameMagic), notKvfile...)
+	_, rel, compressed, err = BuildAutoReader(bytes.NewReader(corrupt), uint64(len(corrupt)))
+	if err == nil || !compressed || !strings.HasPrefix(err.Error(), "open hybrid compressed kvfile: ") {
+		t.Fatalf("expected hybrid compressed kvfile error: %v %v", compressed, err)
+	}
+	rel()
+
+	// corrupt compressed kvfile with the seekable footer
+	corrupt = append(corrupt, 0xb1, 0xea, 0x92, 0x8f)
+	_, rel, compressed, err = BuildAutoReader(bytes.NewReader(corrupt), uint64(len(corrupt)))
+	if err == nil || !compressed || !strings.HasPrefix(err.Error(), "open compressed kvfile: ") {
+		t.Fatalf("expected compressed kvfile error: %v %v", compressed, err)
+	}
+	rel()
+}
+
+func TestBuildAutoReaderPlainZstdValue(t *testing.T) {
+	// the first value is zstd data, so the plain file starts with the magic
+	var zstdValue bytes.Buffer
+	if err := WriteCompress(&zstdValue, [][]byte{[]byte("inner")}, func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write([]byte("inner-value"))
+		return uint64(nw), err
+	}); err != nil {
+		t.Fatal(err.Error())
+	}
+	var plainBuf bytes.Buffer
+	wr := kvfile.NewWriter(&plainBuf)
+	if err := wr.WriteValue([]byte("test-1"), bytes.NewReader(zstdValue.Bytes())); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.WriteValue([]byte("test-2"), strings.NewReader("val-2")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !IsCompressed(plainBuf.Bytes()) {
+		t.Fatal("expected plain file to look compressed")
+	}
+
+	rdr, rel, compressed, err := BuildAutoReader(bytes.NewReader(plainBuf.Bytes()), uint64(plainBuf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rel()
+	if compressed {
+		t.Fatal("expected plain file to be opened as plain")
+	}
+	val, found, err := rdr.Get([]byte("test-1"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !found || !bytes.Equal(val, zstdValue.Bytes()) {
+		t.Fatalf("unexpected value: %v %d", found, len(val))
+	}
+}
+
+func TestBuildAutoReaderWithOptions(t *testing.T) {
+	keys := [][]byte{[]byte("test-1")}
+	writeValue := func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write(bytes.Repeat([]byte("v"), 100))
+		return uint64(nw), err
+	}
+	var plainBuf, compressedBuf, hybridBuf bytes.Buffer
+	if err := kvfile.Write(&plainBuf, keys, writeValue); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := WriteCompress(&compressedBuf, keys, writeValue); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := WriteHybrid(&hybridBuf, keys, writeValue, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	opts := &CompressReaderOptions{ReaderOptions: kvfile.ReaderOptions{MaxValueSize: 10}}
+	for _, data := range [][]byte{plainBuf.Bytes(), compressedBuf.Bytes(), hybridBuf.Bytes()} {
+		rdr, rel, _, err := BuildAutoReaderWithOptions(bytes.NewReader(data), uint64(len(data)), opts)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if _, _, err := rdr.Get(keys[0]); err == nil || !strings.Contains(err.Error(), "max size 10") {
+			t.Fatalf("expected value size limit error: %v", err)
+		}
+		rel()
+	}
+}
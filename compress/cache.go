@@ -0,0 +1,174 @@
+package kvfile_compress
+
+import (
+	"container/list"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	seekable "github.com/SaveTheRbtz/zstd-seekable-format-go"
+	"github.com/cespare/xxhash/v2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// frameCache reads from a seekable zstd stream keeping the most recently used
+// decompressed frames in memory.
+//
+// The most recently read frame is always kept: reading the index and values in
+// turn during a scan with a single cached frame decompresses the same frames
+// repeatedly. Frames are verified against the zstd content checksum and the
+// seek table checksum. Concurrency safe.
+type frameCache struct {
+	// sd is the seek table of the stream
+	sd seekable.Decoder
+	// rd is the compressed stream
+	rd io.ReaderAt
+	// dec is the zstd decoder
+	dec *zstd.Decoder
+	// size is the size of the compressed stream
+	size int64
+	// maxMemory is the maximum decompressed size of a frame, if non-zero
+	maxMemory uint64
+	// budget is the maximum size of the cached frames
+	budget int
+	// checksums indicates the frames are verified against the seek table checksums
+	checksums bool
+
+	mtx sync.Mutex
+	// cached is the size of the cached frames
+	cached int
+	// frames maps the frame id to the element in lru
+	frames map[int64]*list.Element
+	// lru contains the cached frames, most recently used first
+	lru *list.List
+}
+
+// cachedFrame is a decompressed frame in the frameCache.
+type cachedFrame struct {
+	id   int64
+	data []byte
+}
+
+// newFrameCache builds a new frameCache with the memory budget in bytes.
+//
+// size is the size of the compressed stream. If maxMemory is non-zero, frames
+// with a larger decompressed size in the seek table are rejected before
+// reading them. If checksums is set, verifies the frames against the seek
+// table checksums.
+func newFrameCache(sd seekable.Decoder, rd io.ReaderAt, size int64, dec *zstd.Decoder, maxMemory uint64, budget int, checksums bool) *frameCache {
+	return &frameCache{
+		sd:        sd,
+		rd:        rd,
+		size:      size,
+		dec:       dec,
+		maxMemory: maxMemory,
+		budget:    budget,
+		checksums: checksums,
+		frames:    make(map[int64]*list.Element),
+		lru:       list.New(),
+	}
+}
+
+// readSeekTableChecksumFlag checks if the seek table of the seekable zstd
+// stream with the size contains frame checksums.
+func readSeekTableChecksumFlag(rd io.ReaderAt, size int64) (bool, error) {
+	// footer: [number of frames uint32][descriptor byte][magic uint32]
+	var footer [9]byte
+	if size < int64(len(footer)) {
+		return false, errors.New("seekable zstd stream is too small")
+	}
+	if _, err := rd.ReadAt(footer[:], size-int64(len(footer))); err != nil && err != io.EOF {
+		return false, err
+	}
+	if binary.LittleEndian.Uint32(footer[5:]) != seekableFooterMagic {
+		return false, errors.New("invalid seekable zstd footer magic")
+	}
+	return footer[4]&(1<<7) != 0, nil
+}
+
+// ReadAt reads decompressed data from the frames.
+func (c *frameCache) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.Errorf("negative offset: %v", off)
+	}
+	var nr int
+	pos := uint64(off)
+	for nr < len(p) {
+		frame := c.sd.GetIndexByDecompOffset(pos)
+		if frame == nil {
+			return nr, io.EOF
+		}
+		data, err := c.getFrame(frame.ID)
+		if err != nil {
+			return nr, err
+		}
+		n := copy(p[nr:], data[pos-frame.DecompOffset:])
+		nr += n
+		pos += uint64(n)
+	}
+	return nr, nil
+}
+
+// getFrame returns the decompressed frame, decompressing it if not cached.
+func (c *frameCache) getFrame(id int64) ([]byte, error) {
+	c.mtx.Lock()
+	if elem, ok := c.frames[id]; ok {
+		c.lru.MoveToFront(elem)
+		data := elem.Value.(*cachedFrame).data
+		c.mtx.Unlock()
+		return data, nil
+	}
+	c.mtx.Unlock()
+
+	frame := c.sd.GetIndexByID(id)
+	if frame == nil {
+		return nil, errors.Errorf("frame not found: %v", id)
+	}
+	// the seek table is untrusted: check the sizes before allocating
+	if c.maxMemory != 0 && uint64(frame.DecompSize) > c.maxMemory {
+		return nil, errors.Wrapf(zstd.ErrDecoderSizeExceeded, "frame at %v decompresses to %v bytes", frame.CompOffset, frame.DecompSize)
+	}
+	if frame.CompOffset > uint64(c.size) || uint64(frame.CompSize) > uint64(c.size)-frame.CompOffset {
+		return nil, errors.Errorf("frame at %v with size %v exceeds the stream size %v", frame.CompOffset, frame.CompSize, c.size)
+	}
+	src := make([]byte, frame.CompSize)
+	if _, err := c.rd.ReadAt(src, int64(frame.CompOffset)); err != nil && err != io.EOF {
+		return nil, errors.Wrapf(err, "read compressed frame at %v", frame.CompOffset)
+	}
+	data, err := c.dec.DecodeAll(src, nil)
+	if err != nil {
+		if errors.Is(err, zstd.ErrCRCMismatch) {
+			return nil, &FrameChecksumError{Offset: frame.CompOffset, Size: uint64(frame.CompSize), Err: err}
+		}
+		return nil, errors.Wrapf(err, "decompress frame at %v", frame.CompOffset)
+	}
+	if len(data) != int(frame.DecompSize) {
+		return nil, errors.Errorf("unexpected decompressed frame size at %v: %v != %v", frame.CompOffset, len(data), frame.DecompSize)
+	}
+	if c.checksums {
+		// the seek table stores the lower 32 bits of the XXH64 digest
+		if sum := uint32(xxhash.Sum64(data)); sum != frame.Checksum {
+			return nil, &FrameChecksumError{
+				Offset: frame.CompOffset,
+				Size:   uint64(frame.CompSize),
+				Err:    errors.Wrapf(ErrSeekTableChecksum, "expected %08x but got %08x", frame.Checksum, sum),
+			}
+		}
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if _, ok := c.frames[id]; ok {
+		return data, nil
+	}
+	c.frames[id] = c.lru.PushFront(&cachedFrame{id: id, data: data})
+	c.cached += len(data)
+	for c.cached > c.budget && c.lru.Len() > 1 {
+		elem := c.lru.Back()
+		evicted := c.lru.Remove(elem).(*cachedFrame)
+		delete(c.frames, evicted.id)
+		c.cached -= len(evicted.data)
+	}
+	return data, nil
+}
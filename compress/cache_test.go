@@ -0,0 +1,120 @@
+package kvfile_compress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+// countingReadSeeker counts the bytes read with ReadAt.
+type countingReadSeeker struct {
+	*bytes.Reader
+	bytes atomic.Int64
+}
+
+// ReadAt reads from the underlying reader and counts the bytes.
+func (c *countingReadSeeker) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.Reader.ReadAt(p, off)
+	c.bytes.Add(int64(n))
+	return n, err
+}
+
+// scanFrameCacheTestFile scans the file returning the compressed bytes read.
+func scanFrameCacheTestFile(t testing.TB, data []byte, frameCacheSize int) int64 {
+	rd := &countingReadSeeker{Reader: bytes.NewReader(data)}
+	rdr, err := OpenCompressReaderWithOptions(rd, &CompressReaderOptions{FrameCacheSize: frameCacheSize})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rdr.Close()
+	var count int
+	err = rdr.ScanPrefix(nil, func(key, value []byte) error {
+		if !bytes.Equal(value, testValue(key)) {
+			t.Fatalf("unexpected value for %s", string(key))
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if count != int(rdr.Size()) {
+		t.Fatalf("expected %d entries in scan: %d", rdr.Size(), count)
+	}
+	return rd.bytes.Load()
+}
+
+func TestFrameCache(t *testing.T) {
+	_, data := writeFrameSizeTestFile(t, 2000, &CompressOptions{FrameSize: 4096})
+
+	uncached := scanFrameCacheTestFile(t, data, 0)
+	cached := scanFrameCacheTestFile(t, data, 1024*1024)
+	if cached > int64(len(data)) || cached >= uncached {
+		t.Fatalf("expected each frame to be decompressed once with the cache: %v >= %v", cached, uncached)
+	}
+
+	// small budgets evict frames
+	for _, frameCacheSize := range []int{1, 4096, 8192} {
+		_ = scanFrameCacheTestFile(t, data, frameCacheSize)
+	}
+
+	if _, err := OpenCompressReaderWithOptions(bytes.NewReader(data), &CompressReaderOptions{FrameCacheSize: -1}); err == nil {
+		t.Fatal("expected error with invalid frame cache size")
+	}
+}
+
+func benchmarkFrameCacheScan(b *testing.B, frameCacheSize int) {
+	_, data := writeFrameSizeTestFile(b, 10000, &CompressOptions{FrameSize: 64 * 1024})
+	var compressedRead int64
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		compressedRead += scanFrameCacheTestFile(b, data, frameCacheSize)
+	}
+	b.ReportMetric(float64(compressedRead)/float64(b.N), "compressed-bytes/scan")
+}
+
+func BenchmarkScanNoFrameCache(b *testing.B) {
+	benchmarkFrameCacheScan(b, 0)
+}
+
+func BenchmarkScanFrameCache(b *testing.B) {
+	benchmarkFrameCacheScan(b, 1024*1024)
+}
+
+// setLastFrameSizes overwrites the sizes of the last frame in the seek table.
+func setLastFrameSizes(data []byte, compSize, decompSize uint32) []byte {
+	data = bytes.Clone(data)
+	footer := data[len(data)-9:]
+	entrySize := 8
+	if footer[4]&(1<<7) != 0 {
+		entrySize = 12
+	}
+	entry := data[len(data)-9-entrySize:]
+	if compSize != 0 {
+		binary.LittleEndian.PutUint32(entry, compSize)
+	}
+	if decompSize != 0 {
+		binary.LittleEndian.PutUint32(entry[4:], decompSize)
+	}
+	return data
+}
+
+func TestFrameCacheUntrustedSeekTable(t *testing.T) {
+	_, data := writeFrameSizeTestFile(t, 1000, &CompressOptions{FrameSize: 4096})
+
+	// decompressed size above the limit is rejected before decompressing
+	crafted := setLastFrameSizes(data, 0, 0xfffffff0)
+	_, err := OpenCompressReaderWithOptions(bytes.NewReader(crafted), &CompressReaderOptions{MaxMemory: 1024 * 1024})
+	var limitErr *DecoderLimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected decoder limit error: %v", err)
+	}
+
+	// compressed size past the end of the stream is rejected before reading
+	crafted = setLastFrameSizes(data, 0xfffffff0, 0)
+	if _, err := OpenCompressReader(bytes.NewReader(crafted)); err == nil {
+		t.Fatal("expected error with compressed frame size past the end of the stream")
+	}
+}
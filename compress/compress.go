@@ -1,35 +1,313 @@
 package kvfile_compress
 
 import (
+	"bytes"
 	"io"
 
 	seekable "github.com/SaveTheRbtz/zstd-seekable-format-go"
 	kvfile "github.com/aperturerobotics/go-kvfile"
 	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
 )
 
+// CompressOptions are options for writing compressed kvfiles.
+type CompressOptions struct {
+	// Level is the zstd encoder level.
+	// If zero, uses zstd.SpeedBestCompression.
+	Level zstd.EncoderLevel
+	// WindowSize is the maximum zstd window size in bytes.
+	// Must be a power of two between zstd.MinWindowSize and zstd.MaxWindowSize.
+	// If zero, uses the zstd default for the level.
+	WindowSize int
+	// EncoderOptions are additional options for the zstd encoder.
+	// Applied after Level and WindowSize.
+	EncoderOptions []zstd.EOption
+	// FrameSize is the size in bytes of the uncompressed data in each seekable
+	// zstd frame. Must be between MinFrameSize and MaxFrameSize.
+	//
+	// Reading a value decompresses each frame containing part of it. Small
+	// frames (4-64KiB) reduce read amplification for point lookups, large
+	// frames (1-8MiB) improve the compression ratio and scan throughput.
+	//
+	// If zero, each write to the compressed writer is written as a frame.
+	FrameSize int
+	// Concurrency is the maximum number of frames to compress concurrently.
+	//
+	// If FrameSize is set and Concurrency is greater than 1, frames are
+	// compressed in parallel in batches of Concurrency frames, buffering up to
+	// Concurrency frames in memory. Set to 1 for memory-constrained environments.
+	// If zero, uses the zstd default and compresses frames sequentially.
+	Concurrency int
+	// AlignValueSize starts a new frame before and after each value of at
+	// least AlignValueSize bytes written with a kvfile.Writer.
+	//
+	// Each aligned value maps to a whole number of frames so reading it does
+	// not decompress parts of other values. Values with an unknown size and
+	// values written by kvfile.Write are not aligned: WriteCompressWithOptions
+	// and WriteCompressWithEncoder return an error if set. Set to 1 to align
+	// every value with a known size. Requires FrameSize. If zero, values are
+	// not aligned.
+	AlignValueSize int
+	// Stats is set to the statistics of the written file if not nil.
+	// Avoids reopening the file to call Stats.
+	Stats *CompressStats
+}
+
+// MinFrameSize is the minimum seekable frame size in CompressOptions.
+const MinFrameSize = 1024
+
+// MaxFrameSize is the maximum seekable frame size in CompressOptions.
+const MaxFrameSize = 256 * 1024 * 1024
+
+// validate checks the options.
+func (o *CompressOptions) validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.FrameSize != 0 && (o.FrameSize < MinFrameSize || o.FrameSize > MaxFrameSize) {
+		return errors.Errorf("invalid frame size %v: must be between %v and %v", o.FrameSize, MinFrameSize, MaxFrameSize)
+	}
+	if o.Concurrency < 0 {
+		return errors.Errorf("invalid concurrency: %v", o.Concurrency)
+	}
+	if o.AlignValueSize < 0 {
+		return errors.Errorf("invalid align value size: %v", o.AlignValueSize)
+	}
+	if o.AlignValueSize != 0 && o.FrameSize == 0 {
+		return errors.New("align value size requires frame size")
+	}
+	return nil
+}
+
+// validateWrite checks the options for writing with kvfile.Write.
+//
+// kvfile.Write streams values with an unknown size which cannot be aligned.
+func (o *CompressOptions) validateWrite() error {
+	if o != nil && o.AlignValueSize != 0 {
+		return errors.New("align value size requires writing values with a kvfile.Writer")
+	}
+	return o.validate()
+}
+
+// buildEncoder builds the zstd encoder with the options.
+// opts can be nil to use the defaults.
+func (o *CompressOptions) buildEncoder() (*zstd.Encoder, error) {
+	level := zstd.SpeedBestCompression
+	var encOpts []zstd.EOption
+	if o != nil {
+		if o.Level != 0 {
+			level = o.Level
+		}
+		if o.WindowSize != 0 {
+			encOpts = append(encOpts, zstd.WithWindowSize(o.WindowSize))
+		}
+		if o.Concurrency != 0 {
+			encOpts = append(encOpts, zstd.WithEncoderConcurrency(o.Concurrency))
+		}
+		encOpts = append(encOpts, o.EncoderOptions...)
+	}
+	encOpts = append([]zstd.EOption{zstd.WithEncoderLevel(level)}, encOpts...)
+	return zstd.NewWriter(nil, encOpts...)
+}
+
 // UseCompressedWriter builds a compressed writer and closes it after the
 // callback returns.
 func UseCompressedWriter(writer io.Writer, cb func(writer io.Writer) error) error {
-	zenc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+	return UseCompressedWriterWithOptions(writer, nil, cb)
+}
+
+// UseCompressedWriterWithOptions builds a compressed writer with the options
+// and closes it after the callback returns.
+//
+// The writer passed to the callback implements kvfile.ValueAligner.
+// opts can be nil to use the defaults. Invalid options return an error before
+// calling the callback.
+func UseCompressedWriterWithOptions(writer io.Writer, opts *CompressOptions, cb func(writer io.Writer) error) error {
+	w, err := newCompressedWriter(writer, opts)
 	if err != nil {
 		return err
 	}
-	defer zenc.Close()
+	return useCompressedWriter(w, cb)
+}
 
-	w, err := seekable.NewWriter(writer, zenc)
+// UseCompressedWriterWithEncoder builds a compressed writer using an existing
+// zstd encoder and closes it after the callback returns.
+//
+// Reusing an encoder avoids the cost of allocating the encoder tables and
+// window for each file. The encoder is never closed. The encoder can be shared
+// between concurrent writers: the writers only use EncodeAll which is safe to
+// call concurrently, limited by zstd.WithEncoderConcurrency.
+//
+// opts can be nil to use the defaults. The encoder options in opts (Level,
+// WindowSize, and EncoderOptions) must not be set.
+func UseCompressedWriterWithEncoder(writer io.Writer, enc *zstd.Encoder, opts *CompressOptions, cb func(writer io.Writer) error) error {
+	if opts != nil && (opts.Level != 0 || opts.WindowSize != 0 || len(opts.EncoderOptions) != 0) {
+		return errors.New("encoder options cannot be set when using an existing encoder")
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	w, err := newCompressedWriterWithEncoder(writer, opts, enc)
 	if err != nil {
 		return err
 	}
+	return useCompressedWriter(w, cb)
+}
 
-	if err = cb(w); err != nil {
-		_ = w.Close()
+// useCompressedWriter calls the callback with the writer and closes it.
+func useCompressedWriter(w *compressedWriter, cb func(writer io.Writer) error) error {
+	if err := cb(w); err != nil {
+		w.abort()
 		return err
 	}
-
 	return w.Close()
 }
 
+// compressedWriter writes a seekable zstd stream with the compress options.
+type compressedWriter struct {
+	// opts are the compress options, can be nil
+	opts *CompressOptions
+	// zenc is the zstd encoder
+	zenc *zstd.Encoder
+	// closeEnc indicates zenc is closed with the writer
+	closeEnc bool
+	// sw is the seekable writer
+	sw seekable.Writer
+	// w is the writer for uncompressed data
+	w io.Writer
+	// out and frames count the compressed and uncompressed bytes if using Stats
+	out, frames *countingWriter
+	// fw splits the data into frames if using FrameSize
+	fw *frameWriter
+	// bw compresses frames in parallel if using Concurrency
+	bw *batchWriter
+	// alignNext starts a new frame before the next value if using AlignValueSize
+	alignNext bool
+}
+
+// newCompressedWriter builds a new compressedWriter.
+//
+// opts can be nil to use the defaults.
+func newCompressedWriter(writer io.Writer, opts *CompressOptions) (*compressedWriter, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	zenc, err := opts.buildEncoder()
+	if err != nil {
+		return nil, err
+	}
+	c, err := newCompressedWriterWithEncoder(writer, opts, zenc)
+	if err != nil {
+		zenc.Close()
+		return nil, err
+	}
+	c.closeEnc = true
+	return c, nil
+}
+
+// newCompressedWriterWithEncoder builds a new compressedWriter with the encoder.
+//
+// The options must be valid. The encoder is not closed.
+func newCompressedWriterWithEncoder(writer io.Writer, opts *CompressOptions, zenc *zstd.Encoder) (*compressedWriter, error) {
+	var err error
+	c := &compressedWriter{opts: opts, zenc: zenc}
+	if opts != nil && opts.Stats != nil {
+		c.out = &countingWriter{w: writer}
+		writer = c.out
+	}
+
+	// compress frames in parallel with the batch writer
+	var senc seekable.ZSTDEncoder = zenc
+	if opts != nil && opts.FrameSize != 0 && opts.Concurrency > 1 {
+		c.bw = newBatchWriter(zenc, opts.Concurrency)
+		senc = c.bw.pre
+	}
+
+	c.sw, err = seekable.NewWriter(writer, senc)
+	if err != nil {
+		return nil, err
+	}
+
+	c.w = c.sw
+	if c.bw != nil {
+		c.bw.w = c.w
+		c.w = c.bw
+	}
+	if c.out != nil {
+		c.frames = &countingWriter{w: c.w}
+		c.w = c.frames
+	}
+	if opts != nil && opts.FrameSize != 0 {
+		c.fw = newFrameWriter(c.w, opts.FrameSize)
+		c.w = c.fw
+	}
+	return c, nil
+}
+
+// Write writes uncompressed data to the stream.
+func (c *compressedWriter) Write(p []byte) (int, error) {
+	return c.w.Write(p)
+}
+
+// AlignValue starts a new frame before values larger than AlignValueSize and
+// before the value following them. Values with an unknown size are not
+// aligned.
+func (c *compressedWriter) AlignValue(size int64) error {
+	if c.fw == nil || c.opts.AlignValueSize == 0 {
+		return nil
+	}
+	align := size >= int64(c.opts.AlignValueSize)
+	flush := align || c.alignNext
+	c.alignNext = align
+	if !flush {
+		return nil
+	}
+	return c.fw.Flush()
+}
+
+// Close flushes the remaining data and writes the seek table.
+// Closes the encoder if owned by the writer.
+func (c *compressedWriter) Close() error {
+	defer c.closeEncoder()
+	if c.fw != nil {
+		if err := c.fw.Flush(); err != nil {
+			_ = c.sw.Close()
+			return err
+		}
+	}
+	if c.bw != nil {
+		if err := c.bw.Flush(); err != nil {
+			_ = c.sw.Close()
+			return err
+		}
+	}
+	if err := c.sw.Close(); err != nil {
+		return err
+	}
+	if c.out != nil {
+		*c.opts.Stats = CompressStats{
+			CompressedSize:   c.out.bytes,
+			DecompressedSize: c.frames.bytes,
+			FrameCount:       c.frames.writes,
+		}
+	}
+	return nil
+}
+
+// abort closes the stream without flushing the buffered data.
+// Closes the encoder if owned by the writer.
+func (c *compressedWriter) abort() {
+	_ = c.sw.Close()
+	c.closeEncoder()
+}
+
+// closeEncoder closes the encoder if owned by the writer.
+func (c *compressedWriter) closeEncoder() {
+	if c.closeEnc {
+		_ = c.zenc.Close()
+	}
+}
+
 // WriteCompress writes the given key/value pairs to the store in writer.
 // Uses seekable zstd compression.
 //
@@ -38,7 +316,31 @@ func UseCompressedWriter(writer io.Writer, cb func(writer io.Writer) error) erro
 // Note: keys must not contain duplicate keys.
 // writeValue should write the given value to the writer returning the number of bytes written.
 func WriteCompress(writer io.Writer, keys [][]byte, writeValue func(wr io.Writer, key []byte) (uint64, error)) error {
-	return UseCompressedWriter(writer, func(w io.Writer) error {
+	return WriteCompressWithOptions(writer, keys, writeValue, nil)
+}
+
+// WriteCompressWithOptions writes the given key/value pairs to the store in
+// writer with the compression options.
+//
+// opts can be nil to use the defaults. See WriteCompress.
+func WriteCompressWithOptions(writer io.Writer, keys [][]byte, writeValue func(wr io.Writer, key []byte) (uint64, error), opts *CompressOptions) error {
+	if err := opts.validateWrite(); err != nil {
+		return err
+	}
+	return UseCompressedWriterWithOptions(writer, opts, func(w io.Writer) error {
+		return kvfile.Write(w, keys, writeValue)
+	})
+}
+
+// WriteCompressWithEncoder writes the given key/value pairs to the store in
+// writer using an existing zstd encoder.
+//
+// The encoder is never closed. See UseCompressedWriterWithEncoder and WriteCompress.
+func WriteCompressWithEncoder(writer io.Writer, keys [][]byte, writeValue func(wr io.Writer, key []byte) (uint64, error), enc *zstd.Encoder, opts *CompressOptions) error {
+	if err := opts.validateWrite(); err != nil {
+		return err
+	}
+	return UseCompressedWriterWithEncoder(writer, enc, opts, func(w io.Writer) error {
 		return kvfile.Write(w, keys, writeValue)
 	})
 }
@@ -52,30 +354,59 @@ type ReadSeekerAt interface {
 // BuildCompressReader reads key/value pairs from the compressed reader.
 // Uses seekable zstd compression.
 // Returns a function to call to release the zstd reader.
+//
+// See OpenCompressReader.
 func BuildCompressReader(rd ReadSeekerAt) (*kvfile.Reader, func(), error) {
-	dec, err := zstd.NewReader(nil)
+	r, err := OpenCompressReader(rd)
 	if err != nil {
 		return nil, nil, err
 	}
-	r, err := seekable.NewReader(rd, dec)
-	if err != nil {
-		dec.Close()
-		return nil, nil, err
+	return r.Reader, func() {
+		_ = r.Close()
+	}, nil
+}
+
+// BuildCompressReaderFromBytes reads key/value pairs from a compressed kvfile
+// in memory, for example a file embedded with go:embed.
+//
+// Returns ErrNotCompressed if the data does not start with a zstd frame.
+// Returns a function to call to release the zstd reader.
+func BuildCompressReaderFromBytes(data []byte) (*kvfile.Reader, func(), error) {
+	if !IsCompressed(data) {
+		return nil, nil, ErrNotCompressed
 	}
-	size, err := r.Seek(0, io.SeekEnd)
+	return BuildCompressReader(bytes.NewReader(data))
+}
+
+// BuildCompressReaderWithOptions reads key/value pairs from the compressed
+// reader with the options.
+//
+// opts can be nil to use the defaults. See OpenCompressReaderWithOptions.
+func BuildCompressReaderWithOptions(rd ReadSeekerAt, opts *CompressReaderOptions) (*kvfile.Reader, func(), error) {
+	r, err := OpenCompressReaderWithOptions(rd, opts)
 	if err != nil {
-		dec.Close()
-		_ = r.Close()
 		return nil, nil, err
 	}
-	kvReader, err := kvfile.BuildReader(r, uint64(size))
-	if err != nil {
-		dec.Close()
+	return r.Reader, func() {
 		_ = r.Close()
+	}, nil
+}
+
+// BuildCompressReaderWithDecoder reads key/value pairs from the compressed
+// reader using an existing zstd decoder.
+//
+// The decoder can be shared between many readers: reads use DecodeAll which
+// is safe to call concurrently. The number of concurrent decodes is limited
+// by zstd.WithDecoderConcurrency. See DecoderPool.
+//
+// Returns a function to call to release the seekable reader.
+// The release function does not close the decoder.
+func BuildCompressReaderWithDecoder(rd ReadSeekerAt, dec *zstd.Decoder) (*kvfile.Reader, func(), error) {
+	r, err := OpenCompressReaderWithDecoder(rd, dec)
+	if err != nil {
 		return nil, nil, err
 	}
-	return kvReader, func() {
-		dec.Close()
+	return r.Reader, func() {
 		_ = r.Close()
 	}, nil
 }
@@ -3,7 +3,15 @@ package kvfile_compress
 import (
 	"bytes"
 	"io"
+	"runtime"
+	"strconv"
+	"sync"
 	"testing"
+
+	seekable "github.com/SaveTheRbtz/zstd-seekable-format-go"
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
 )
 
 func TestKvCompress(t *testing.T) {
@@ -68,3 +76,487 @@ func TestKvCompress(t *testing.T) {
 		}
 	}
 }
+
+func TestKvCompressOptions(t *testing.T) {
+	keys := [][]byte{
+		[]byte("test-1"),
+		[]byte("test-2"),
+	}
+	writeValue := func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write(bytes.Repeat(key, 100))
+		return uint64(nw), err
+	}
+
+	var buf bytes.Buffer
+	err := WriteCompressWithOptions(&buf, keys, writeValue, &CompressOptions{Level: zstd.SpeedFastest})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, rdrRelease, err := BuildCompressReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rdrRelease()
+	for _, key := range keys {
+		data, found, err := rdr.Get(key)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !found || !bytes.Equal(data, bytes.Repeat(key, 100)) {
+			t.Fatalf("unexpected value for %s: %v", string(key), found)
+		}
+	}
+
+	// invalid options return an error before writing
+	var invalidBuf bytes.Buffer
+	err = WriteCompressWithOptions(&invalidBuf, keys, writeValue, &CompressOptions{WindowSize: 1000})
+	if err == nil {
+		t.Fatal("expected error with invalid window size")
+	}
+	if invalidBuf.Len() != 0 {
+		t.Fatalf("expected nothing to be written: %v", invalidBuf.Len())
+	}
+}
+
+// writeFrameSizeTestFile writes n keys with 200 byte values.
+func writeFrameSizeTestFile(t testing.TB, n int, opts *CompressOptions) ([][]byte, []byte) {
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = []byte("key-" + strconv.Itoa(i))
+	}
+	var buf bytes.Buffer
+	err := WriteCompressWithOptions(&buf, keys, func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write(bytes.Repeat(key, 200/len(key)+1)[:200])
+		return uint64(nw), err
+	}, opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return keys, buf.Bytes()
+}
+
+func TestKvCompressFrameSize(t *testing.T) {
+	var frameCounts []int64
+	for _, frameSize := range []int{4096, 1024 * 1024} {
+		keys, data := writeFrameSizeTestFile(t, 1000, &CompressOptions{FrameSize: frameSize})
+		rdr, rdrRelease, err := BuildCompressReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		for _, key := range keys {
+			val, found, err := rdr.Get(key)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if !found || !bytes.Equal(val, bytes.Repeat(key, 200/len(key)+1)[:200]) {
+				t.Fatalf("unexpected value for %s: %v", string(key), found)
+			}
+		}
+		rdrRelease()
+
+		dec, err := zstd.NewReader(nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		sr, err := seekable.NewReader(bytes.NewReader(data), dec)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		frameCounts = append(frameCounts, sr.(seekable.Decoder).NumFrames())
+		_ = sr.Close()
+		dec.Close()
+	}
+	if frameCounts[0] <= frameCounts[1] || frameCounts[1] != 1 {
+		t.Fatalf("unexpected frame counts: %v", frameCounts)
+	}
+
+	for _, frameSize := range []int{-1, 1, MaxFrameSize + 1} {
+		var buf bytes.Buffer
+		if err := UseCompressedWriterWithOptions(&buf, &CompressOptions{FrameSize: frameSize}, func(writer io.Writer) error {
+			return nil
+		}); err == nil {
+			t.Fatalf("expected error with invalid frame size %v", frameSize)
+		}
+	}
+}
+
+func benchmarkFrameSizeGet(b *testing.B, frameSize int) {
+	keys, data := writeFrameSizeTestFile(b, 10000, &CompressOptions{FrameSize: frameSize})
+	rdr, rdrRelease, err := BuildCompressReader(bytes.NewReader(data))
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+	defer rdrRelease()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// stride through the keys to avoid hitting the cached frame
+		_, found, err := rdr.Get(keys[(i*7919)%len(keys)])
+		if err != nil || !found {
+			b.Fatalf("get failed: %v %v", found, err)
+		}
+	}
+}
+
+func BenchmarkGetFrameSize4KiB(b *testing.B) {
+	benchmarkFrameSizeGet(b, 4096)
+}
+
+func BenchmarkGetFrameSize1MiB(b *testing.B) {
+	benchmarkFrameSizeGet(b, 1024*1024)
+}
+
+func TestKvCompressConcurrency(t *testing.T) {
+	_, sequential := writeFrameSizeTestFile(t, 1000, &CompressOptions{FrameSize: 4096, Concurrency: 1})
+	keys, parallel := writeFrameSizeTestFile(t, 1000, &CompressOptions{FrameSize: 4096, Concurrency: 4})
+	if !bytes.Equal(sequential, parallel) {
+		t.Fatal("expected parallel compression output to match sequential")
+	}
+	rdr, rdrRelease, err := BuildCompressReader(bytes.NewReader(parallel))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rdrRelease()
+	if _, found, err := rdr.Get(keys[500]); err != nil || !found {
+		t.Fatalf("get failed: %v %v", found, err)
+	}
+
+	var buf bytes.Buffer
+	if err := UseCompressedWriterWithOptions(&buf, &CompressOptions{Concurrency: -1}, func(writer io.Writer) error {
+		return nil
+	}); err == nil {
+		t.Fatal("expected error with invalid concurrency")
+	}
+}
+
+func benchmarkCompressConcurrency(b *testing.B, concurrency int) {
+	// 64MiB of compressible data
+	chunk := make([]byte, 1024*1024)
+	for i := range chunk {
+		chunk[i] = byte((i * i) >> 7)
+	}
+	opts := &CompressOptions{Level: zstd.SpeedDefault, FrameSize: 1024 * 1024, Concurrency: concurrency}
+	b.SetBytes(64 * int64(len(chunk)))
+	for i := 0; i < b.N; i++ {
+		err := UseCompressedWriterWithOptions(io.Discard, opts, func(writer io.Writer) error {
+			for j := 0; j < 64; j++ {
+				if _, err := writer.Write(chunk); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+}
+
+func BenchmarkCompressConcurrency1(b *testing.B) {
+	benchmarkCompressConcurrency(b, 1)
+}
+
+func BenchmarkCompressConcurrencyGOMAXPROCS(b *testing.B) {
+	benchmarkCompressConcurrency(b, runtime.GOMAXPROCS(0))
+}
+
+// alignTestValue returns the test value for the i-th key.
+// Every tenth value is larger than the 4KiB test frame size.
+func alignTestValue(key []byte, i int) []byte {
+	size := 100
+	if i%10 == 0 {
+		size = 20000
+	}
+	return bytes.Repeat(key, size/len(key)+1)[:size]
+}
+
+// writeAlignTestFile writes n keys with a kvfile.Writer to a compressed file.
+func writeAlignTestFile(t testing.TB, n int, opts *CompressOptions) ([][]byte, []byte) {
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = []byte("key-" + strconv.Itoa(i))
+	}
+	var buf bytes.Buffer
+	err := UseCompressedWriterWithOptions(&buf, opts, func(writer io.Writer) error {
+		wr := kvfile.NewWriter(writer)
+		for i, key := range keys {
+			if err := wr.WriteValue(key, bytes.NewReader(alignTestValue(key, i))); err != nil {
+				return err
+			}
+		}
+		return wr.Close()
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return keys, buf.Bytes()
+}
+
+// valueFrameBytes returns the size of the frames decompressed to read each value.
+func valueFrameBytes(t testing.TB, data []byte) map[string]uint64 {
+	rdr, err := OpenCompressReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rdr.Close()
+	dec := rdr.sr.(seekable.Decoder)
+	frameBytes := make(map[string]uint64)
+	err = rdr.ScanPrefixEntries(nil, func(indexEntry *kvfile.IndexEntry, indexEntryIdx int) error {
+		var total uint64
+		pos, end := indexEntry.GetOffset(), indexEntry.GetOffset()+indexEntry.GetSize()
+		for pos < end {
+			frame := dec.GetIndexByDecompOffset(pos)
+			if frame == nil {
+				return errors.Errorf("no frame at offset %v", pos)
+			}
+			total += uint64(frame.DecompSize)
+			pos = frame.DecompOffset + uint64(frame.DecompSize)
+		}
+		frameBytes[string(indexEntry.GetKey())] = total
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return frameBytes
+}
+
+func TestKvCompressAlignValues(t *testing.T) {
+	for _, alignValueSize := range []int{0, 4096} {
+		opts := &CompressOptions{FrameSize: 4096, AlignValueSize: alignValueSize}
+		keys, data := writeAlignTestFile(t, 200, opts)
+		rdr, rdrRelease, err := BuildCompressReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		for i, key := range keys {
+			val, found, err := rdr.Get(key)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if !found || !bytes.Equal(val, alignTestValue(key, i)) {
+				t.Fatalf("unexpected value for %s: %v", string(key), found)
+			}
+		}
+		rdrRelease()
+
+		// aligned large values are read without decompressing other values
+		frameBytes := valueFrameBytes(t, data)
+		var misaligned int
+		for i := 0; i < len(keys); i += 10 {
+			if frameBytes[string(keys[i])] != uint64(len(alignTestValue(keys[i], i))) {
+				misaligned++
+			}
+		}
+		if alignValueSize != 0 && misaligned != 0 {
+			t.Fatalf("expected large values to be aligned to frames: %v misaligned", misaligned)
+		}
+		if alignValueSize == 0 && misaligned == 0 {
+			t.Fatal("expected large values to be misaligned without alignment")
+		}
+	}
+
+	for _, opts := range []*CompressOptions{{AlignValueSize: 4096}, {FrameSize: 4096, AlignValueSize: -1}} {
+		var buf bytes.Buffer
+		if err := UseCompressedWriterWithOptions(&buf, opts, func(writer io.Writer) error {
+			return nil
+		}); err == nil {
+			t.Fatalf("expected error with invalid align value size: %v", opts.AlignValueSize)
+		}
+	}
+}
+
+func TestKvCompressAlignWriteCompress(t *testing.T) {
+	keys := [][]byte{[]byte("key-1"), []byte("key-2")}
+	writeValue := func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write(key)
+		return uint64(nw), err
+	}
+	opts := &CompressOptions{FrameSize: 4096, AlignValueSize: 1}
+
+	// kvfile.Write streams values with an unknown size which cannot be aligned
+	var buf bytes.Buffer
+	if err := WriteCompressWithOptions(&buf, keys, writeValue, opts); err == nil {
+		t.Fatal("expected error with align value size in WriteCompressWithOptions")
+	}
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer enc.Close()
+	if err := WriteCompressWithEncoder(&buf, keys, writeValue, enc, opts); err == nil {
+		t.Fatal("expected error with align value size in WriteCompressWithEncoder")
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected nothing to be written: %v bytes", buf.Len())
+	}
+}
+
+func benchmarkAlignValuesGet(b *testing.B, alignValueSize int) {
+	opts := &CompressOptions{FrameSize: 4096, AlignValueSize: alignValueSize}
+	keys, data := writeAlignTestFile(b, 10000, opts)
+	frameBytes := valueFrameBytes(b, data)
+	rdr, rdrRelease, err := BuildCompressReader(bytes.NewReader(data))
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+	defer rdrRelease()
+	var decompressed uint64
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// read the large values, striding to avoid hitting the cached frame
+		key := keys[((i*7919)%(len(keys)/10))*10]
+		decompressed += frameBytes[string(key)]
+		_, found, err := rdr.Get(key)
+		if err != nil || !found {
+			b.Fatalf("get failed: %v %v", found, err)
+		}
+	}
+	b.ReportMetric(float64(decompressed)/float64(b.N), "decompressed-bytes/get")
+}
+
+func BenchmarkGetUnalignedValues(b *testing.B) {
+	benchmarkAlignValuesGet(b, 0)
+}
+
+func BenchmarkGetAlignedValues(b *testing.B) {
+	benchmarkAlignValuesGet(b, 4096)
+}
+
+func TestBuildCompressReaderFromBytes(t *testing.T) {
+	keys, data := writeFrameSizeTestFile(t, 100, nil)
+	rdr, rdrRelease, err := BuildCompressReaderFromBytes(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rdrRelease()
+	for _, key := range keys {
+		val, found, err := rdr.Get(key)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !found || !bytes.Equal(val, testValue(key)) {
+			t.Fatalf("unexpected value for %s: %v", string(key), found)
+		}
+	}
+
+	// empty compressed kvfile
+	_, emptyData := writeFrameSizeTestFile(t, 0, nil)
+	emptyRdr, emptyRelease, err := BuildCompressReaderFromBytes(emptyData)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer emptyRelease()
+	if emptyRdr.Size() != 0 {
+		t.Fatalf("unexpected size: %v", emptyRdr.Size())
+	}
+
+	// plain kvfiles and other data are rejected
+	var plainBuf bytes.Buffer
+	if err := kvfile.Write(&plainBuf, keys, func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write(testValue(key))
+		return uint64(nw), err
+	}); err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, data := range [][]byte{nil, plainBuf.Bytes(), []byte("not compressed")} {
+		if _, _, err := BuildCompressReaderFromBytes(data); err != ErrNotCompressed {
+			t.Fatalf("expected not compressed error: %v", err)
+		}
+	}
+}
+
+// writeTinyTestFile writes a compressed kvfile with a few small values.
+func writeTinyTestFile(writer io.Writer, enc *zstd.Encoder) error {
+	keys := [][]byte{[]byte("key-1"), []byte("key-2"), []byte("key-3")}
+	writeValue := func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write(testValue(key))
+		return uint64(nw), err
+	}
+	if enc == nil {
+		return WriteCompress(writer, keys, writeValue)
+	}
+	return WriteCompressWithEncoder(writer, keys, writeValue, enc, nil)
+}
+
+func TestKvCompressWithEncoder(t *testing.T) {
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer enc.Close()
+
+	var expected bytes.Buffer
+	if err := writeTinyTestFile(&expected, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the encoder is not closed and can be shared between concurrent writers
+	var wg sync.WaitGroup
+	outs := make([]bytes.Buffer, 8)
+	errs := make([]error, len(outs))
+	for i := range outs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = writeTinyTestFile(&outs[i], enc)
+		}(i)
+	}
+	wg.Wait()
+	for i := range outs {
+		if errs[i] != nil {
+			t.Fatal(errs[i].Error())
+		}
+		if !bytes.Equal(outs[i].Bytes(), expected.Bytes()) {
+			t.Fatalf("unexpected output for writer %d", i)
+		}
+	}
+
+	// the callback error does not close the encoder
+	errTest := errors.New("test error")
+	if err := UseCompressedWriterWithEncoder(io.Discard, enc, nil, func(writer io.Writer) error {
+		return errTest
+	}); err != errTest {
+		t.Fatalf("expected test error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := writeTinyTestFile(&buf, enc); err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, rel, err := BuildCompressReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rel()
+	if val, found, err := rdr.Get([]byte("key-2")); err != nil || !found || !bytes.Equal(val, testValue([]byte("key-2"))) {
+		t.Fatalf("get failed: %v %v", found, err)
+	}
+
+	// encoder options cannot be combined with an existing encoder
+	if err := UseCompressedWriterWithEncoder(io.Discard, enc, &CompressOptions{Level: zstd.SpeedFastest}, func(writer io.Writer) error {
+		return nil
+	}); err == nil {
+		t.Fatal("expected error with encoder options")
+	}
+}
+
+func BenchmarkWriteTinyFiles(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if err := writeTinyTestFile(io.Discard, nil); err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+}
+
+func BenchmarkWriteTinyFilesWithEncoder(b *testing.B) {
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+	defer enc.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := writeTinyTestFile(io.Discard, enc); err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+}
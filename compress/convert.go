@@ -0,0 +1,91 @@
+package kvfile_compress
+
+import (
+	"io"
+
+	seekable "github.com/SaveTheRbtz/zstd-seekable-format-go"
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressReader writes the entries from the reader to a compressed kvfile.
+//
+// Values are streamed from the source reader and are not buffered in memory.
+// All entries are copied in key order with their metadata, expiry, and
+// tombstones, including the entries hidden by the source reader options.
+// opts can be nil to use the defaults.
+func CompressReader(dst io.Writer, src *kvfile.Reader, opts *CompressOptions) error {
+	return UseCompressedWriterWithOptions(dst, opts, func(writer io.Writer) error {
+		return copyReader(writer, src)
+	})
+}
+
+// DecompressReader writes the entries from the reader to a plain kvfile.
+//
+// Usually src is a reader from BuildCompressReader.
+// See CompressReader for details.
+func DecompressReader(dst io.Writer, src *kvfile.Reader) error {
+	return copyReader(dst, src)
+}
+
+// Recompress re-encodes a compressed kvfile with the options.
+//
+// The decompressed data is streamed from the source to the destination without
+// parsing or buffering the kvfile: the logical content is byte-identical. Use
+// to change the compression level or frame size, for example when archiving
+// files written with zstd.SpeedFastest. AlignValueSize has no effect.
+//
+// Returns the statistics of the source and destination files.
+// Returns ErrNotCompressed if the source is not compressed.
+// opts can be nil to use the defaults.
+func Recompress(dst io.Writer, src ReadSeekerAt, opts *CompressOptions) (srcStats, dstStats CompressStats, err error) {
+	srcStats, err = Stats(src)
+	if err != nil {
+		return srcStats, dstStats, err
+	}
+
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		return srcStats, dstStats, err
+	}
+	defer dec.Close()
+	sr, err := seekable.NewReader(src, dec)
+	if err != nil {
+		return srcStats, dstStats, err
+	}
+	defer sr.Close()
+
+	var dstOpts CompressOptions
+	if opts != nil {
+		dstOpts = *opts
+	}
+	dstOpts.AlignValueSize = 0
+	dstOpts.Stats = &dstStats
+	err = UseCompressedWriterWithOptions(dst, &dstOpts, func(writer io.Writer) error {
+		_, err := io.Copy(writer, sr)
+		return err
+	})
+	if err != nil {
+		return srcStats, dstStats, err
+	}
+	if opts != nil && opts.Stats != nil {
+		*opts.Stats = dstStats
+	}
+	return srcStats, dstStats, nil
+}
+
+// copyReader writes the entries from the reader to a kvfile.
+func copyReader(dst io.Writer, src *kvfile.Reader) error {
+	wr := kvfile.NewWriter(dst)
+	size := src.Size()
+	for i := uint64(0); i < size; i++ {
+		indexEntry, err := src.ReadIndexEntry(i)
+		if err != nil {
+			return err
+		}
+		if err := wr.CopyEntry(src, indexEntry, int(i)); err != nil {
+			return err
+		}
+	}
+	return wr.Close()
+}
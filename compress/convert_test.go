@@ -0,0 +1,194 @@
+package kvfile_compress
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	seekable "github.com/SaveTheRbtz/zstd-seekable-format-go"
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestCompressReader(t *testing.T) {
+	keys := [][]byte{[]byte("test-3"), []byte("test-1"), []byte("test-empty"), []byte("test-2")}
+	vals := map[string][]byte{
+		"test-1":     []byte("val-1"),
+		"test-2":     bytes.Repeat([]byte("val-2"), 10000),
+		"test-3":     []byte("val-3"),
+		"test-empty": {},
+	}
+	var plainBuf bytes.Buffer
+	err := kvfile.Write(&plainBuf, keys, func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write(vals[string(key)])
+		return uint64(nw), err
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	plainRdr, err := kvfile.BuildReader(bytes.NewReader(plainBuf.Bytes()), uint64(plainBuf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// plain -> compressed
+	var compressedBuf bytes.Buffer
+	if err := CompressReader(&compressedBuf, plainRdr, &CompressOptions{FrameSize: 4096}); err != nil {
+		t.Fatal(err.Error())
+	}
+	compressedRdr, rel, err := BuildCompressReader(bytes.NewReader(compressedBuf.Bytes()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rel()
+
+	// compressed -> plain
+	var outBuf bytes.Buffer
+	if err := DecompressReader(&outBuf, compressedRdr); err != nil {
+		t.Fatal(err.Error())
+	}
+	outRdr, err := kvfile.BuildReader(bytes.NewReader(outBuf.Bytes()), uint64(outBuf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var got []string
+	err = outRdr.ScanPrefix(nil, func(key, value []byte) error {
+		if !bytes.Equal(value, vals[string(key)]) {
+			t.Fatalf("unexpected value for %s: %d bytes", string(key), len(value))
+		}
+		got = append(got, string(key))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(got) != len(keys) || got[0] != "test-1" || got[3] != "test-empty" {
+		t.Fatalf("unexpected keys: %v", got)
+	}
+
+	// the values are written in key order so the files are identical
+	var sortedBuf bytes.Buffer
+	if err := DecompressReader(&sortedBuf, plainRdr); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(sortedBuf.Bytes(), outBuf.Bytes()) {
+		t.Fatal("expected round-trip output to match")
+	}
+}
+
+func TestCompressReaderEntryAttrs(t *testing.T) {
+	expires := time.UnixMilli(4102444800000)
+	var plainBuf bytes.Buffer
+	wr := kvfile.NewWriter(&plainBuf)
+	if err := wr.WriteTombstone([]byte("deleted")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.WriteValueWithExpiry([]byte("expires"), strings.NewReader("val-expires"), expires); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.WriteValueBytesMeta([]byte("meta"), []byte("val-meta"), []byte("test-meta")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	plainRdr, err := kvfile.BuildReader(bytes.NewReader(plainBuf.Bytes()), uint64(plainBuf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var compressedBuf bytes.Buffer
+	if err := CompressReader(&compressedBuf, plainRdr, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	compressedRdr, rel, err := BuildCompressReader(bytes.NewReader(compressedBuf.Bytes()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rel()
+	var outBuf bytes.Buffer
+	if err := DecompressReader(&outBuf, compressedRdr); err != nil {
+		t.Fatal(err.Error())
+	}
+	outRdr, err := kvfile.BuildReader(bytes.NewReader(outBuf.Bytes()), uint64(outBuf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if outRdr.Size() != plainRdr.Size() {
+		t.Fatalf("expected %v entries: %v", plainRdr.Size(), outRdr.Size())
+	}
+	for i := uint64(0); i < plainRdr.Size(); i++ {
+		want, err := plainRdr.ReadIndexEntry(i)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		got, err := outRdr.ReadIndexEntry(i)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !bytes.Equal(got.GetKey(), want.GetKey()) || got.GetTombstone() != want.GetTombstone() || got.GetExpiresUnixMs() != want.GetExpiresUnixMs() || !bytes.Equal(got.GetMeta(), want.GetMeta()) || got.GetSize() != want.GetSize() {
+			t.Fatalf("unexpected entry %v: %v != %v", i, got, want)
+		}
+	}
+	val, found, err := outRdr.Get([]byte("expires"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !found || string(val) != "val-expires" {
+		t.Fatalf("unexpected value: %v %q", found, val)
+	}
+}
+
+func TestRecompress(t *testing.T) {
+	keys, srcData := writeFrameSizeTestFile(t, 2000, &CompressOptions{Level: zstd.SpeedFastest, FrameSize: 4096})
+
+	var dstBuf bytes.Buffer
+	srcStats, dstStats, err := Recompress(&dstBuf, bytes.NewReader(srcData), &CompressOptions{FrameSize: 64 * 1024})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if srcStats.CompressedSize != uint64(len(srcData)) || dstStats.CompressedSize != uint64(dstBuf.Len()) {
+		t.Fatalf("unexpected sizes: %v %v", srcStats, dstStats)
+	}
+	if dstStats.CompressedSize >= srcStats.CompressedSize || dstStats.FrameCount >= srcStats.FrameCount {
+		t.Fatalf("expected recompressed file to be smaller: %v %v", srcStats, dstStats)
+	}
+
+	// the decompressed content is identical
+	decompress := func(data []byte) []byte {
+		dec, err := zstd.NewReader(nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		defer dec.Close()
+		sr, err := seekable.NewReader(bytes.NewReader(data), dec)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		defer sr.Close()
+		out, err := io.ReadAll(sr)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return out
+	}
+	if !bytes.Equal(decompress(srcData), decompress(dstBuf.Bytes())) {
+		t.Fatal("expected recompressed content to be identical")
+	}
+	rdr, rel, err := BuildCompressReader(bytes.NewReader(dstBuf.Bytes()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rel()
+	if val, found, err := rdr.Get(keys[1000]); err != nil || !found || !bytes.Equal(val, testValue(keys[1000])) {
+		t.Fatalf("get failed: %v %v", found, err)
+	}
+
+	if _, _, err := Recompress(io.Discard, bytes.NewReader([]byte("not compressed")), nil); err != ErrNotCompressed {
+		t.Fatalf("expected not compressed error: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package kvfile_compress
+
+import (
+	"sync"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	"github.com/klauspost/compress/zstd"
+)
+
+// DecoderPool shares a zstd decoder between compressed readers.
+//
+// The decoder is created when the first reader is opened and closed when the
+// last reader is released. Concurrency safe.
+type DecoderPool struct {
+	opts []zstd.DOption
+
+	mtx  sync.Mutex
+	dec  *zstd.Decoder
+	refs int
+}
+
+// NewDecoderPool builds a new DecoderPool with the decoder options.
+func NewDecoderPool(opts ...zstd.DOption) *DecoderPool {
+	return &DecoderPool{opts: opts}
+}
+
+// BuildCompressReader reads key/value pairs from the compressed reader using
+// the shared decoder.
+//
+// Returns a function to call to release the reader.
+func (p *DecoderPool) BuildCompressReader(rd ReadSeekerAt) (*kvfile.Reader, func(), error) {
+	dec, err := p.acquire()
+	if err != nil {
+		return nil, nil, err
+	}
+	kvReader, rel, err := BuildCompressReaderWithDecoder(rd, dec)
+	if err != nil {
+		p.release()
+		return nil, nil, err
+	}
+	var once sync.Once
+	return kvReader, func() {
+		once.Do(func() {
+			rel()
+			p.release()
+		})
+	}, nil
+}
+
+// acquire returns the shared decoder adding a reference.
+func (p *DecoderPool) acquire() (*zstd.Decoder, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.dec == nil {
+		dec, err := zstd.NewReader(nil, p.opts...)
+		if err != nil {
+			return nil, err
+		}
+		p.dec = dec
+	}
+	p.refs++
+	return p.dec, nil
+}
+
+// release removes a reference to the shared decoder.
+func (p *DecoderPool) release() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.refs--
+	if p.refs == 0 && p.dec != nil {
+		p.dec.Close()
+		p.dec = nil
+	}
+}
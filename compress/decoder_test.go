@@ -0,0 +1,86 @@
+package kvfile_compress
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+func TestSharedDecoder(t *testing.T) {
+	keys, data := writeFrameSizeTestFile(t, 100, &CompressOptions{FrameSize: 1024})
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer dec.Close()
+	pool := NewDecoderPool()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var rel func()
+			var err error
+			rdr := bytes.NewReader(data)
+			if i%2 == 0 {
+				_, rel, err = BuildCompressReaderWithDecoder(rdr, dec)
+			} else {
+				_, rel, err = pool.BuildCompressReader(rdr)
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+			rel()
+		}(i)
+	}
+	wg.Wait()
+
+	// many readers sharing one decoder, read concurrently
+	readers := make([]func(i int) error, 16)
+	for i := range readers {
+		rdr, rel, err := BuildCompressReaderWithDecoder(bytes.NewReader(data), dec)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		defer rel()
+		readers[i] = func(j int) error {
+			key := keys[j%len(keys)]
+			_, found, err := rdr.Get(key)
+			if err == nil && !found {
+				err = errors.Errorf("key not found: %s", string(key))
+			}
+			return err
+		}
+	}
+	for i := range readers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if err := readers[i](i * j); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err.Error())
+	}
+
+	// the shared decoder is still usable after releasing readers
+	if _, err := dec.DecodeAll(zstdFrameMagic, nil); err == zstd.ErrDecoderClosed {
+		t.Fatal("expected shared decoder to not be closed")
+	}
+	if pool.dec != nil || pool.refs != 0 {
+		t.Fatalf("expected pool decoder to be released: %v", pool.refs)
+	}
+}
@@ -0,0 +1,132 @@
+package kvfile_compress
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// frameWriter buffers writes to the seekable writer into fixed size frames.
+//
+// Each write to the seekable writer is written as a separate frame.
+type frameWriter struct {
+	w    io.Writer
+	buf  []byte
+	size int
+}
+
+// newFrameWriter builds a new frameWriter with the frame size.
+func newFrameWriter(w io.Writer, size int) *frameWriter {
+	return &frameWriter{w: w, size: size}
+}
+
+// Write writes data to the frame buffer, writing each full frame.
+func (f *frameWriter) Write(p []byte) (int, error) {
+	var nw int
+	for len(p) != 0 {
+		// write full frames directly without copying
+		if len(f.buf) == 0 && len(p) >= f.size {
+			if _, err := f.w.Write(p[:f.size]); err != nil {
+				return nw, err
+			}
+			nw += f.size
+			p = p[f.size:]
+			continue
+		}
+
+		if f.buf == nil {
+			f.buf = make([]byte, 0, f.size)
+		}
+		n := min(f.size-len(f.buf), len(p))
+		f.buf = append(f.buf, p[:n]...)
+		nw += n
+		p = p[n:]
+		if len(f.buf) == f.size {
+			if err := f.Flush(); err != nil {
+				return nw, err
+			}
+		}
+	}
+	return nw, nil
+}
+
+// Flush writes the buffered data as a frame, if any.
+func (f *frameWriter) Flush() error {
+	if len(f.buf) == 0 {
+		return nil
+	}
+	_, err := f.w.Write(f.buf)
+	f.buf = f.buf[:0]
+	return err
+}
+
+// batchWriter compresses batches of frames in parallel and writes them to the
+// seekable writer in order.
+//
+// Each write to the batchWriter is a frame.
+type batchWriter struct {
+	enc    *zstd.Encoder
+	pre    *precompressedEncoder
+	w      io.Writer
+	frames [][]byte
+	size   int
+}
+
+// newBatchWriter builds a new batchWriter with the batch size.
+//
+// The seekable writer must use pre as the encoder and be set as w.
+func newBatchWriter(enc *zstd.Encoder, size int) *batchWriter {
+	return &batchWriter{enc: enc, pre: &precompressedEncoder{}, size: size}
+}
+
+// Write adds the frame to the batch, compressing the batch if full.
+func (b *batchWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	b.frames = append(b.frames, bytes.Clone(p))
+	if len(b.frames) == b.size {
+		if err := b.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush compresses the frames in the batch in parallel and writes them.
+func (b *batchWriter) Flush() error {
+	compressed := make([][]byte, len(b.frames))
+	var wg sync.WaitGroup
+	for i, frame := range b.frames {
+		wg.Add(1)
+		go func(i int, frame []byte) {
+			defer wg.Done()
+			compressed[i] = b.enc.EncodeAll(frame, nil)
+		}(i, frame)
+	}
+	wg.Wait()
+
+	frames := b.frames
+	b.frames = b.frames[:0]
+	for i, frame := range frames {
+		b.pre.next = compressed[i]
+		if _, err := b.w.Write(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// precompressedEncoder returns the frame compressed by the batchWriter.
+type precompressedEncoder struct {
+	next []byte
+}
+
+// EncodeAll returns the compressed frame.
+func (p *precompressedEncoder) EncodeAll(src, dst []byte) []byte {
+	out := append(dst, p.next...)
+	p.next = nil
+	return out
+}
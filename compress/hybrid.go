@@ -0,0 +1,108 @@
+package kvfile_compress
+
+import (
+	"encoding/binary"
+	"io"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+)
+
+// The hybrid layout compresses the values with seekable zstd and writes the
+// index and footer uncompressed after the compressed values:
+//
+//	[seekable zstd values][index entries][footer][positions][count]
+//
+// Opening the file reads the index directly without decompressing anything,
+// only value reads go through decompression. The value offsets in the index
+// are positions in the decompressed value stream.
+
+// ErrNotHybrid is returned if the file does not use the hybrid layout.
+var ErrNotHybrid = errors.New("kvfile does not use the hybrid compressed layout")
+
+// seekableFooterMagic is the magic number at the end of a seekable zstd stream.
+const seekableFooterMagic uint32 = 0x8f92eab1
+
+// NewHybridWriter builds a kvfile writer with the hybrid layout.
+//
+// The values are compressed with the options, the index is not compressed.
+// opts can be nil to use the defaults.
+func NewHybridWriter(out io.Writer, opts *CompressOptions) (*kvfile.Writer, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	return kvfile.NewWriterWithOptions(out, kvfile.WriterOptions{
+		ValueWriter: func(out io.Writer) (io.WriteCloser, error) {
+			return newCompressedWriter(out, opts)
+		},
+	})
+}
+
+// WriteHybrid writes the given key/value pairs with the hybrid layout.
+//
+// Note: keys must not contain duplicates or an error will be returned.
+// writeValue should write the given value to the writer returning the number of bytes written.
+// opts can be nil to use the defaults.
+func WriteHybrid(writer io.Writer, keys [][]byte, writeValue kvfile.WriteValueFunc, opts *CompressOptions) error {
+	w, err := NewHybridWriter(writer, opts)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := w.WriteValueFrom(key, writeValue); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// OpenHybridReader opens a kvfile with the hybrid layout.
+//
+// Returns ErrNotHybrid if the file does not use the hybrid layout.
+func OpenHybridReader(rd io.ReaderAt, size uint64) (*CompressedReader, error) {
+	return OpenHybridReaderWithOptions(rd, size, nil)
+}
+
+// OpenHybridReaderWithOptions opens a kvfile with the hybrid layout with the
+// options.
+//
+// opts can be nil to use the defaults.
+// Returns ErrNotHybrid if the file does not use the hybrid layout.
+func OpenHybridReaderWithOptions(rd io.ReaderAt, size uint64, opts *CompressReaderOptions) (*CompressedReader, error) {
+	dec, err := opts.buildDecoder()
+	if err != nil {
+		return nil, err
+	}
+	r := &CompressedReader{dec: dec}
+	readerOpts := opts.readerOptions()
+	readerOpts.OpenValues = func(rd io.ReaderAt, valuesSize uint64) (io.ReaderAt, error) {
+		if valuesSize != 0 {
+			if err := r.openFrames(io.NewSectionReader(rd, 0, int64(valuesSize)), int64(valuesSize), dec, opts); err != nil {
+				return nil, err
+			}
+		}
+		return &compressedReaderAt{r: r}, nil
+	}
+	kvReader, err := kvfile.BuildReaderWithOptions(rd, size, readerOpts)
+	if err == nil && !kvReader.ExternalValues() {
+		err = ErrNotHybrid
+	}
+	if err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	r.Reader = kvReader
+	return r, nil
+}
+
+// isSeekableTail checks if the file ends with the seekable zstd footer.
+func isSeekableTail(rd io.ReaderAt, size uint64) (bool, error) {
+	if size < 4 {
+		return false, nil
+	}
+	tail := make([]byte, 4)
+	if _, err := rd.ReadAt(tail, int64(size)-4); err != nil {
+		return false, err
+	}
+	return binary.LittleEndian.Uint32(tail) == seekableFooterMagic, nil
+}
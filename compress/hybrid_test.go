@@ -0,0 +1,139 @@
+package kvfile_compress
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"testing"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+)
+
+// testValue returns the 200 byte test value for the key.
+func testValue(key []byte) []byte {
+	return bytes.Repeat(key, 200/len(key)+1)[:200]
+}
+
+// writeHybridTestFile writes n keys with 200 byte values with the hybrid layout.
+func writeHybridTestFile(t testing.TB, n int, opts *CompressOptions) ([][]byte, []byte) {
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = []byte("key-" + strconv.Itoa(i))
+	}
+	var buf bytes.Buffer
+	err := WriteHybrid(&buf, keys, func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write(testValue(key))
+		return uint64(nw), err
+	}, opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return keys, buf.Bytes()
+}
+
+func TestHybrid(t *testing.T) {
+	keys, data := writeHybridTestFile(t, 1000, &CompressOptions{FrameSize: 4096})
+	if !IsCompressed(data) {
+		t.Fatal("expected hybrid file to start with a zstd frame")
+	}
+
+	// the plain reader refuses to read the compressed values
+	if _, err := kvfile.BuildReader(bytes.NewReader(data), uint64(len(data))); err != kvfile.ErrExternalValues {
+		t.Fatalf("expected external values error: %v", err)
+	}
+
+	rdr, err := OpenHybridReader(bytes.NewReader(data), uint64(len(data)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rdr.Close()
+	if rdr.Size() != uint64(len(keys)) {
+		t.Fatalf("unexpected size: %v", rdr.Size())
+	}
+	for _, key := range keys {
+		val, found, err := rdr.Get(key)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !found || !bytes.Equal(val, testValue(key)) {
+			t.Fatalf("unexpected value for %s: %v", string(key), found)
+		}
+	}
+
+	// auto detection
+	autoRdr, rel, compressed, err := BuildAutoReader(bytes.NewReader(data), uint64(len(data)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rel()
+	if !compressed {
+		t.Fatal("expected hybrid file to be detected as compressed")
+	}
+	if val, found, err := autoRdr.Get(keys[10]); err != nil || !found || !bytes.Equal(val, testValue(keys[10])) {
+		t.Fatalf("unexpected value: %v %v", found, err)
+	}
+
+	// plain and fully compressed files are rejected
+	_, compressedData := writeFrameSizeTestFile(t, 10, nil)
+	if _, err := OpenHybridReader(bytes.NewReader(compressedData), uint64(len(compressedData))); err == nil {
+		t.Fatal("expected error opening fully compressed file")
+	}
+	var plainBuf bytes.Buffer
+	if err := kvfile.NewWriter(&plainBuf).Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := OpenHybridReader(bytes.NewReader(plainBuf.Bytes()), uint64(plainBuf.Len())); err != ErrNotHybrid {
+		t.Fatalf("expected not hybrid error: %v", err)
+	}
+}
+
+func TestHybridEmpty(t *testing.T) {
+	_, data := writeHybridTestFile(t, 0, nil)
+	rdr, err := OpenHybridReader(bytes.NewReader(data), uint64(len(data)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rdr.Close()
+	if rdr.Size() != 0 {
+		t.Fatalf("unexpected size: %v", rdr.Size())
+	}
+}
+
+func TestHybridWriteCount(t *testing.T) {
+	keys := [][]byte{[]byte("test-1")}
+	err := WriteHybrid(&bytes.Buffer{}, keys, func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write([]byte("val-1"))
+		return uint64(nw) + 1, err
+	}, nil)
+	if err == nil {
+		t.Fatal("expected write count mismatch error")
+	}
+}
+
+func benchmarkOpenGet(b *testing.B, data []byte, open func(data []byte) (*CompressedReader, error), key []byte) {
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rdr, err := open(data)
+		if err != nil {
+			b.Fatal(err.Error())
+		}
+		if _, found, err := rdr.Get(key); err != nil || !found {
+			b.Fatalf("get failed: %v %v", found, err)
+		}
+		_ = rdr.Close()
+	}
+}
+
+func BenchmarkOpenGetCompressed(b *testing.B) {
+	keys, data := writeFrameSizeTestFile(b, 10000, &CompressOptions{FrameSize: 64 * 1024})
+	benchmarkOpenGet(b, data, func(data []byte) (*CompressedReader, error) {
+		return OpenCompressReader(bytes.NewReader(data))
+	}, keys[5000])
+}
+
+func BenchmarkOpenGetHybrid(b *testing.B) {
+	keys, data := writeHybridTestFile(b, 10000, &CompressOptions{FrameSize: 64 * 1024})
+	benchmarkOpenGet(b, data, func(data []byte) (*CompressedReader, error) {
+		return OpenHybridReader(bytes.NewReader(data), uint64(len(data)))
+	}, keys[5000])
+}
@@ -0,0 +1,301 @@
+package kvfile_compress
+
+import (
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	seekable "github.com/SaveTheRbtz/zstd-seekable-format-go"
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// ErrReaderClosed is returned when reading from a closed CompressedReader.
+var ErrReaderClosed = errors.New("compressed reader is closed")
+
+// ErrSeekTableChecksum is wrapped by the FrameChecksumError returned when a
+// decompressed frame does not match the checksum in the seek table.
+var ErrSeekTableChecksum = errors.New("frame does not match the seek table checksum")
+
+// CompressReaderOptions are options for reading compressed kvfiles.
+//
+// Services reading untrusted files should set MaxMemory and MaxWindow to bound
+// the memory used by the decoder. The zero value uses the zstd defaults.
+type CompressReaderOptions struct {
+	// MaxMemory is the maximum decompressed size of a frame in bytes.
+	// If zero, uses the zstd default (64GiB).
+	MaxMemory uint64
+	// MaxWindow is the maximum window size in bytes.
+	// Must be at least zstd.MinWindowSize.
+	// If zero, uses the zstd default (512MiB).
+	MaxWindow uint64
+	// LowMem reduces the memory used by the decoder at the cost of speed.
+	LowMem bool
+	// IgnoreChecksum skips verifying the frame checksums.
+	//
+	// Skips both the zstd content checksum and the seek table checksum of
+	// each frame. Improves the throughput of large reads, but corrupted frames
+	// are not detected. By default frames failing verification return a
+	// *FrameChecksumError.
+	IgnoreChecksum bool
+	// FrameCacheSize is the maximum size in bytes of the decompressed frames
+	// to keep in memory.
+	//
+	// Reads of values in recently read frames, such as consecutive values in a
+	// prefix scan, are served from the cache without decompressing the frame
+	// again. Should fit at least two frames: the frame containing the index
+	// and the frame containing the values. The most recently read frame is
+	// always kept, so if zero only that frame is kept.
+	FrameCacheSize int
+	// DecoderOptions are additional options for the zstd decoder.
+	// Applied after MaxMemory, MaxWindow, LowMem, and IgnoreChecksum.
+	DecoderOptions []zstd.DOption
+	// ReaderOptions are the options for the kvfile reader.
+	// OpenValues is replaced when opening a file with the hybrid layout.
+	ReaderOptions kvfile.ReaderOptions
+}
+
+// maxMemory returns the maximum decompressed size of a frame, if set.
+// opts can be nil to use the defaults.
+func (o *CompressReaderOptions) maxMemory() uint64 {
+	if o == nil {
+		return 0
+	}
+	return o.MaxMemory
+}
+
+// frameCacheSize returns the frame cache size.
+// opts can be nil to use the defaults.
+func (o *CompressReaderOptions) frameCacheSize() int {
+	if o == nil {
+		return 0
+	}
+	return o.FrameCacheSize
+}
+
+// verifyChecksums checks if frames are verified against the seek table.
+// opts can be nil to use the defaults.
+func (o *CompressReaderOptions) verifyChecksums() bool {
+	return o == nil || !o.IgnoreChecksum
+}
+
+// readerOptions returns the options for the kvfile reader.
+// opts can be nil to use the defaults.
+func (o *CompressReaderOptions) readerOptions() kvfile.ReaderOptions {
+	if o == nil {
+		return kvfile.ReaderOptions{}
+	}
+	return o.ReaderOptions
+}
+
+// buildDecoder builds the zstd decoder with the options.
+// opts can be nil to use the defaults.
+func (o *CompressReaderOptions) buildDecoder() (*zstd.Decoder, error) {
+	var decOpts []zstd.DOption
+	if o != nil {
+		if o.MaxMemory != 0 {
+			decOpts = append(decOpts, zstd.WithDecoderMaxMemory(o.MaxMemory))
+		}
+		if o.MaxWindow != 0 {
+			decOpts = append(decOpts, zstd.WithDecoderMaxWindow(o.MaxWindow))
+		}
+		if o.LowMem {
+			decOpts = append(decOpts, zstd.WithDecoderLowmem(true))
+		}
+		if o.IgnoreChecksum {
+			decOpts = append(decOpts, zstd.IgnoreChecksum(true))
+		}
+		decOpts = append(decOpts, o.DecoderOptions...)
+	}
+	return zstd.NewReader(nil, decOpts...)
+}
+
+// DecoderLimitError is returned when decompressing a frame exceeds the decoder
+// memory limits. See CompressReaderOptions.
+type DecoderLimitError struct {
+	// Err is the zstd limit error.
+	Err error
+}
+
+// Error returns the error string.
+func (e *DecoderLimitError) Error() string {
+	return "compressed kvfile exceeds decoder limits: " + e.Err.Error()
+}
+
+// Unwrap returns the zstd limit error.
+func (e *DecoderLimitError) Unwrap() error {
+	return e.Err
+}
+
+// FrameChecksumError is returned when a compressed frame fails checksum
+// verification against the zstd content checksum or the seek table.
+//
+// The frame data is corrupted: the compressed byte range of the frame can be
+// read again from the source to recover.
+type FrameChecksumError struct {
+	// Offset is the offset of the frame in the compressed stream.
+	Offset uint64
+	// Size is the compressed size of the frame.
+	Size uint64
+	// Err is zstd.ErrCRCMismatch or wraps ErrSeekTableChecksum.
+	Err error
+}
+
+// Error returns the error string.
+func (e *FrameChecksumError) Error() string {
+	return "compressed frame at offset " + strconv.FormatUint(e.Offset, 10) +
+		" (" + strconv.FormatUint(e.Size, 10) + " bytes) failed checksum verification: " + e.Err.Error()
+}
+
+// Unwrap returns the checksum error.
+func (e *FrameChecksumError) Unwrap() error {
+	return e.Err
+}
+
+// isDecoderLimitError checks if the error is a zstd decoder limit error.
+func isDecoderLimitError(err error) bool {
+	return errors.Is(err, zstd.ErrDecoderSizeExceeded) ||
+		errors.Is(err, zstd.ErrWindowSizeExceeded) ||
+		errors.Is(err, zstd.ErrFrameSizeExceeded)
+}
+
+// CompressedReader reads key/value pairs from a compressed kvfile.
+//
+// Call Close to release the seekable reader and decoder.
+// Reads after Close return ErrReaderClosed.
+type CompressedReader struct {
+	*kvfile.Reader
+
+	// sr is the seekable reader
+	sr seekable.Reader
+	// dec is the decoder to close with the reader, if any
+	dec *zstd.Decoder
+	// frames reads and caches the decompressed frames of sr
+	frames *frameCache
+	// closed indicates Close was called
+	closed atomic.Bool
+	// closeOnce guards closing the reader
+	closeOnce sync.Once
+}
+
+// OpenCompressReader opens a compressed kvfile.
+// Uses seekable zstd compression.
+func OpenCompressReader(rd ReadSeekerAt) (*CompressedReader, error) {
+	return OpenCompressReaderWithOptions(rd, nil)
+}
+
+// OpenCompressReaderWithOptions opens a compressed kvfile with the options.
+//
+// opts can be nil to use the defaults. Reads exceeding the decoder limits
+// return a *DecoderLimitError.
+func OpenCompressReaderWithOptions(rd ReadSeekerAt, opts *CompressReaderOptions) (*CompressedReader, error) {
+	dec, err := opts.buildDecoder()
+	if err != nil {
+		return nil, err
+	}
+	r, err := openCompressReader(rd, dec, opts)
+	if err != nil {
+		dec.Close()
+		return nil, err
+	}
+	r.dec = dec
+	return r, nil
+}
+
+// OpenCompressReaderWithDecoder opens a compressed kvfile using an existing
+// zstd decoder.
+//
+// Close does not close the decoder. The seek table checksums are verified.
+// See BuildCompressReaderWithDecoder.
+func OpenCompressReaderWithDecoder(rd ReadSeekerAt, dec *zstd.Decoder) (*CompressedReader, error) {
+	return openCompressReader(rd, dec, nil)
+}
+
+// openCompressReader opens a compressed kvfile with the decoder.
+//
+// opts can be nil to use the defaults.
+func openCompressReader(rd ReadSeekerAt, dec *zstd.Decoder, opts *CompressReaderOptions) (*CompressedReader, error) {
+	streamSize, err := rd.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	r := &CompressedReader{}
+	if err := r.openFrames(rd, streamSize, dec, opts); err != nil {
+		return nil, err
+	}
+	kvReader, err := kvfile.BuildReaderWithOptions(&compressedReaderAt{r: r}, uint64(r.frames.sd.Size()), opts.readerOptions())
+	if err != nil {
+		_ = r.sr.Close()
+		return nil, err
+	}
+	r.Reader = kvReader
+	return r, nil
+}
+
+// openFrames opens the seekable zstd stream with the size for reading frames.
+//
+// opts can be nil to use the defaults.
+func (r *CompressedReader) openFrames(rd ReadSeekerAt, size int64, dec *zstd.Decoder, opts *CompressReaderOptions) error {
+	frameCacheSize := opts.frameCacheSize()
+	if frameCacheSize < 0 {
+		return errors.Errorf("invalid frame cache size: %v", frameCacheSize)
+	}
+	var checksums bool
+	if opts.verifyChecksums() {
+		var err error
+		checksums, err = readSeekTableChecksumFlag(rd, size)
+		if err != nil {
+			return err
+		}
+	}
+	sr, err := seekable.NewReader(rd, dec)
+	if err != nil {
+		return err
+	}
+	sd, ok := sr.(seekable.Decoder)
+	if !ok {
+		_ = sr.Close()
+		return errors.New("seekable reader does not expose the seek table")
+	}
+	r.sr = sr
+	r.frames = newFrameCache(sd, rd, size, dec, opts.maxMemory(), frameCacheSize, checksums)
+	return nil
+}
+
+// Close releases the seekable reader and the decoder.
+// Calling Close more than once has no effect.
+func (r *CompressedReader) Close() error {
+	r.closeOnce.Do(func() {
+		r.closed.Store(true)
+		if r.sr != nil {
+			_ = r.sr.Close()
+		}
+		if r.dec != nil {
+			r.dec.Close()
+		}
+	})
+	return nil
+}
+
+// compressedReaderAt reads from the seekable reader if not closed.
+type compressedReaderAt struct {
+	r *CompressedReader
+}
+
+// ReadAt reads from the seekable reader.
+func (c *compressedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if c.r.closed.Load() {
+		return 0, ErrReaderClosed
+	}
+	if c.r.frames == nil {
+		// hybrid file without values
+		return 0, io.EOF
+	}
+	n, err := c.r.frames.ReadAt(p, off)
+	if err != nil && isDecoderLimitError(err) {
+		err = &DecoderLimitError{Err: err}
+	}
+	return n, err
+}
@@ -0,0 +1,208 @@
+package kvfile_compress
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+
+	seekable "github.com/SaveTheRbtz/zstd-seekable-format-go"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestCompressedReaderClose(t *testing.T) {
+	keys, data := writeFrameSizeTestFile(t, 10, nil)
+	rdr, err := OpenCompressReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, found, err := rdr.Get(keys[0]); err != nil || !found {
+		t.Fatalf("get failed: %v %v", found, err)
+	}
+	if err := rdr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := rdr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, _, err := rdr.Get(keys[0]); !errors.Is(err, ErrReaderClosed) {
+		t.Fatalf("expected reader closed error: %v", err)
+	}
+}
+
+func TestCompressReaderOptions(t *testing.T) {
+	keys, data := writeFrameSizeTestFile(t, 1000, &CompressOptions{FrameSize: 64 * 1024})
+
+	// limits above the frame size do not affect reads
+	for _, opts := range []*CompressReaderOptions{nil, {}, {MaxMemory: 1024 * 1024, MaxWindow: 1024 * 1024, LowMem: true}} {
+		rdr, err := OpenCompressReaderWithOptions(bytes.NewReader(data), opts)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if val, found, err := rdr.Get(keys[500]); err != nil || !found || !bytes.Equal(val, testValue(keys[500])) {
+			t.Fatalf("get failed: %v %v", found, err)
+		}
+		_ = rdr.Close()
+	}
+
+	// limits below the frame size are reported when reading the index
+	for _, opts := range []*CompressReaderOptions{{MaxMemory: 4096}, {MaxWindow: 4096}} {
+		_, err := OpenCompressReaderWithOptions(bytes.NewReader(data), opts)
+		var limitErr *DecoderLimitError
+		if !errors.As(err, &limitErr) {
+			t.Fatalf("expected decoder limit error: %v", err)
+		}
+	}
+}
+
+// writeChecksumTestFile writes n keys with incompressible 256 byte values.
+//
+// Returns the values by key. The frames contain the values as raw literals,
+// so changing a byte of a frame body changes the decompressed value.
+func writeChecksumTestFile(t *testing.T, n int) ([][]byte, map[string][]byte, []byte) {
+	keys := make([][]byte, n)
+	vals := make(map[string][]byte, n)
+	for i := range keys {
+		keys[i] = []byte("key-" + strconv.Itoa(1000+i))
+		var value []byte
+		for j := range 8 {
+			sum := sha256.Sum256(append(bytes.Clone(keys[i]), byte(j)))
+			value = append(value, sum[:]...)
+		}
+		vals[string(keys[i])] = value
+	}
+	var buf bytes.Buffer
+	err := WriteCompressWithOptions(&buf, keys, func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write(vals[string(key)])
+		return uint64(nw), err
+	}, &CompressOptions{FrameSize: 4096})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return keys, vals, buf.Bytes()
+}
+
+// readChecksumTestFile reads every key of the file with the options.
+//
+// Returns the number of values not matching vals and the first read error.
+func readChecksumTestFile(t *testing.T, data []byte, keys [][]byte, vals map[string][]byte, opts *CompressReaderOptions) (int, error) {
+	rdr, err := OpenCompressReaderWithOptions(bytes.NewReader(data), opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rdr.Close()
+	var readErr error
+	var mismatched int
+	for _, key := range keys {
+		val, found, err := rdr.Get(key)
+		if err != nil {
+			if readErr == nil {
+				readErr = err
+			}
+			continue
+		}
+		if !found {
+			t.Fatalf("key not found: %s", string(key))
+		}
+		if !bytes.Equal(val, vals[string(key)]) {
+			mismatched++
+		}
+	}
+	return mismatched, readErr
+}
+
+func TestFrameChecksumError(t *testing.T) {
+	keys, vals, data := writeChecksumTestFile(t, 200)
+	rdr, err := OpenCompressReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sd := rdr.sr.(seekable.Decoder)
+	frame, numFrames := sd.GetIndexByID(2), sd.NumFrames()
+	_ = rdr.Close()
+
+	// corrupt a byte in the middle of the compressed block of the third frame
+	corruptBody := bytes.Clone(data)
+	corruptBody[frame.CompOffset+uint64(frame.CompSize)/2] ^= 0xff
+	// corrupt the checksum of the third frame in the seek table
+	// seek table: [entries: compressed size, size, checksum][9 byte footer]
+	corruptTable := bytes.Clone(data)
+	corruptTable[len(data)-9-int(numFrames-frame.ID)*12+8] ^= 0xff
+
+	for _, frameCacheSize := range []int{0, 64 * 1024} {
+		for _, tc := range []struct {
+			name    string
+			data    []byte
+			wrapErr error
+		}{
+			{"Body", corruptBody, zstd.ErrCRCMismatch},
+			{"SeekTable", corruptTable, ErrSeekTableChecksum},
+		} {
+			name := tc.name + "/FrameCacheSize=" + strconv.Itoa(frameCacheSize)
+			_, err := readChecksumTestFile(t, tc.data, keys, vals, &CompressReaderOptions{FrameCacheSize: frameCacheSize})
+			var checksumErr *FrameChecksumError
+			if !errors.As(err, &checksumErr) {
+				t.Fatalf("%s: expected frame checksum error: %v", name, err)
+			}
+			if checksumErr.Offset != frame.CompOffset || checksumErr.Size != uint64(frame.CompSize) {
+				t.Fatalf("%s: unexpected frame in checksum error: %v %v", name, checksumErr.Offset, checksumErr.Size)
+			}
+			if !errors.Is(err, tc.wrapErr) {
+				t.Fatalf("%s: expected checksum error to wrap %v: %v", name, tc.wrapErr, err)
+			}
+
+			// skipping verification returns the values as stored
+			mismatched, err := readChecksumTestFile(t, tc.data, keys, vals, &CompressReaderOptions{FrameCacheSize: frameCacheSize, IgnoreChecksum: true})
+			if err != nil {
+				t.Fatalf("%s: expected no error when ignoring checksums: %v", name, err)
+			}
+			if expected := tc.wrapErr == zstd.ErrCRCMismatch; (mismatched != 0) != expected {
+				t.Fatalf("%s: unexpected number of corrupted values when ignoring checksums: %d", name, mismatched)
+			}
+		}
+	}
+}
+
+func benchmarkChecksumScan(b *testing.B, opts *CompressReaderOptions) {
+	// 256 64KiB values in 1MiB frames
+	value := make([]byte, 64*1024)
+	for i := range value {
+		value[i] = byte((i * i) >> 7)
+	}
+	keys := make([][]byte, 256)
+	for i := range keys {
+		keys[i] = []byte("key-" + strconv.Itoa(1000+i))
+	}
+	var buf bytes.Buffer
+	err := WriteCompressWithOptions(&buf, keys, func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write(value)
+		return uint64(nw), err
+	}, &CompressOptions{Level: zstd.SpeedFastest, FrameSize: 1024 * 1024})
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+	rdr, err := OpenCompressReaderWithOptions(bytes.NewReader(buf.Bytes()), opts)
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+	defer rdr.Close()
+	b.SetBytes(int64(len(keys) * len(value)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := rdr.ScanPrefix(nil, func(key, value []byte) error {
+			return nil
+		}); err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+}
+
+func BenchmarkScanVerifyChecksum(b *testing.B) {
+	benchmarkChecksumScan(b, nil)
+}
+
+func BenchmarkScanIgnoreChecksum(b *testing.B) {
+	benchmarkChecksumScan(b, &CompressReaderOptions{IgnoreChecksum: true})
+}
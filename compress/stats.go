@@ -0,0 +1,89 @@
+package kvfile_compress
+
+import (
+	"io"
+
+	seekable "github.com/SaveTheRbtz/zstd-seekable-format-go"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// ErrNotCompressed is returned if the file is not a compressed kvfile.
+var ErrNotCompressed = errors.New("kvfile is not compressed")
+
+// CompressStats contains statistics about a compressed kvfile.
+type CompressStats struct {
+	// CompressedSize is the size of the compressed file in bytes.
+	CompressedSize uint64
+	// DecompressedSize is the size of the decompressed kvfile in bytes.
+	DecompressedSize uint64
+	// FrameCount is the number of seekable zstd frames.
+	FrameCount uint64
+}
+
+// Ratio returns the compression ratio: decompressed size / compressed size.
+func (s *CompressStats) Ratio() float64 {
+	if s.CompressedSize == 0 {
+		return 0
+	}
+	return float64(s.DecompressedSize) / float64(s.CompressedSize)
+}
+
+// Stats reads the statistics of a compressed kvfile from the seek table.
+//
+// Does not decompress any frames.
+// Returns ErrNotCompressed if the file is not compressed.
+func Stats(rd ReadSeekerAt) (CompressStats, error) {
+	var stats CompressStats
+	compressedSize, err := rd.Seek(0, io.SeekEnd)
+	if err != nil {
+		return stats, err
+	}
+	head := make([]byte, 4)
+	if compressedSize >= int64(len(head)) {
+		if _, err := rd.ReadAt(head, 0); err != nil {
+			return stats, err
+		}
+	}
+	if !IsCompressed(head) {
+		return stats, ErrNotCompressed
+	}
+
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		return stats, err
+	}
+	defer dec.Close()
+	r, err := seekable.NewReader(rd, dec)
+	if err != nil {
+		return stats, err
+	}
+	defer r.Close()
+
+	// the seekable reader implements the Decoder interface
+	sd, ok := r.(seekable.Decoder)
+	if !ok {
+		return stats, errors.New("seekable reader does not expose the seek table")
+	}
+	stats.CompressedSize = uint64(compressedSize)
+	stats.DecompressedSize = uint64(sd.Size())
+	stats.FrameCount = uint64(sd.NumFrames())
+	return stats, nil
+}
+
+// countingWriter counts the bytes and non-empty writes to a writer.
+type countingWriter struct {
+	w      io.Writer
+	bytes  uint64
+	writes uint64
+}
+
+// Write writes to the underlying writer and counts the bytes.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.bytes += uint64(n)
+	if len(p) != 0 {
+		c.writes++
+	}
+	return n, err
+}
@@ -0,0 +1,57 @@
+package kvfile_compress
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+)
+
+func TestStats(t *testing.T) {
+	keys := [][]byte{[]byte("test-1"), []byte("test-2"), []byte("test-3")}
+	writeValue := func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write(bytes.Repeat(key, 1000))
+		return uint64(nw), err
+	}
+
+	// the decompressed data is the plain kvfile
+	var plainBuf bytes.Buffer
+	if err := kvfile.Write(&plainBuf, keys, writeValue); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var writeStats CompressStats
+	var buf bytes.Buffer
+	frameSize := 4096
+	err := WriteCompressWithOptions(&buf, keys, writeValue, &CompressOptions{
+		FrameSize: frameSize,
+		Stats:     &writeStats,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := CompressStats{
+		CompressedSize:   uint64(buf.Len()),
+		DecompressedSize: uint64(plainBuf.Len()),
+		FrameCount:       uint64((plainBuf.Len() + frameSize - 1) / frameSize),
+	}
+	if writeStats != expected {
+		t.Fatalf("unexpected write stats: %v != %v", writeStats, expected)
+	}
+	stats, err := Stats(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if stats != expected {
+		t.Fatalf("unexpected stats: %v != %v", stats, expected)
+	}
+	if ratio := stats.Ratio(); ratio <= 1 {
+		t.Fatalf("expected compression ratio > 1: %v", ratio)
+	}
+
+	if _, err := Stats(bytes.NewReader(plainBuf.Bytes())); err != ErrNotCompressed {
+		t.Fatalf("expected not compressed error: %v", err)
+	}
+}
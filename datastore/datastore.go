@@ -0,0 +1,244 @@
+package kvfile_datastore
+
+import (
+	"context"
+	"path"
+	"time"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
+)
+
+// ErrReadOnly is returned by the methods which would modify the Datastore.
+var ErrReadOnly = errors.New("kvfile datastore is read-only")
+
+// Datastore is a read-only datastore backed by a kvfile.
+//
+// Keys are stored in the kvfile as the datastore key strings, for example
+// "/foo/bar". Put and Delete return ErrReadOnly.
+type Datastore struct {
+	rd *kvfile.Reader
+}
+
+// NewDatastore builds a read-only datastore with a kvfile reader.
+func NewDatastore(rd *kvfile.Reader) *Datastore {
+	return &Datastore{rd: rd}
+}
+
+// Get retrieves the value named by the key.
+//
+// Returns ds.ErrNotFound if the key does not exist.
+func (d *Datastore) Get(ctx context.Context, key ds.Key) ([]byte, error) {
+	value, found, err := d.rd.Get(key.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ds.ErrNotFound
+	}
+	return value, nil
+}
+
+// Has checks if the key exists.
+func (d *Datastore) Has(ctx context.Context, key ds.Key) (bool, error) {
+	return d.rd.Exists(key.Bytes())
+}
+
+// GetSize returns the size of the value named by the key.
+//
+// Returns ds.ErrNotFound if the key does not exist.
+func (d *Datastore) GetSize(ctx context.Context, key ds.Key) (int, error) {
+	size, err := d.rd.GetValueSize(key.Bytes())
+	if err != nil {
+		return -1, err
+	}
+	if size < 0 {
+		return -1, ds.ErrNotFound
+	}
+	return int(size), nil
+}
+
+// Query searches the datastore.
+//
+// Results are read in order from the sorted index if the query has no orders
+// or is ordered by key. Other orders are applied in memory.
+func (d *Datastore) Query(ctx context.Context, q dsq.Query) (dsq.Results, error) {
+	start, end, err := d.rd.SearchPrefixRange(queryPrefix(q.Prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	var descending, naiveOrder bool
+	if len(q.Orders) != 0 {
+		switch q.Orders[0].(type) {
+		case dsq.OrderByKey, *dsq.OrderByKey:
+		case dsq.OrderByKeyDescending, *dsq.OrderByKeyDescending:
+			descending = true
+		default:
+			naiveOrder = true
+		}
+	}
+
+	it := &queryIterator{
+		rd:         d.rd,
+		q:          q,
+		next:       start,
+		end:        end,
+		descending: descending,
+	}
+	if descending {
+		it.next = end
+		it.end = start
+	}
+	if !naiveOrder {
+		it.offset, it.limit = q.Offset, q.Limit
+		return dsq.ResultsFromIterator(q, dsq.Iterator{Next: it.nextResult}), nil
+	}
+
+	var qr dsq.Results = dsq.ResultsFromIterator(q, dsq.Iterator{Next: it.nextResult})
+	qr = dsq.NaiveOrder(qr, q.Orders...)
+	if q.Offset != 0 {
+		qr = dsq.NaiveOffset(qr, q.Offset)
+	}
+	if q.Limit != 0 {
+		qr = dsq.NaiveLimit(qr, q.Limit)
+	}
+	return qr, nil
+}
+
+// Put returns ErrReadOnly.
+func (d *Datastore) Put(ctx context.Context, key ds.Key, value []byte) error {
+	return ErrReadOnly
+}
+
+// Delete returns ErrReadOnly.
+func (d *Datastore) Delete(ctx context.Context, key ds.Key) error {
+	return ErrReadOnly
+}
+
+// Sync does nothing as the datastore is read-only.
+func (d *Datastore) Sync(ctx context.Context, prefix ds.Key) error {
+	return nil
+}
+
+// Close does nothing: the reader is not closed.
+func (d *Datastore) Close() error {
+	return nil
+}
+
+// queryPrefix returns the key prefix for a query prefix.
+//
+// A prefix selects the strict children of the prefix: /foo selects /foo/bar
+// but not /foobar or /foo.
+func queryPrefix(prefix string) []byte {
+	if prefix == "" {
+		return nil
+	}
+	if prefix[0] != '/' {
+		prefix = "/" + prefix
+	}
+	prefix = path.Clean(prefix)
+	if prefix == "/" {
+		return nil
+	}
+	return []byte(prefix + "/")
+}
+
+// queryIterator iterates over the index entries in a range for a query.
+type queryIterator struct {
+	rd *kvfile.Reader
+	q  dsq.Query
+	// next is the index of the next entry, or one after it if descending
+	next uint64
+	// end is the end of the range, or the start if descending
+	end        uint64
+	descending bool
+	// offset is the number of matching entries left to skip
+	offset int
+	// limit is the number of entries left to return, if not zero
+	limit int
+	done  bool
+}
+
+// nextResult returns the next result of the query.
+func (it *queryIterator) nextResult() (dsq.Result, bool) {
+	for !it.done {
+		if it.next == it.end {
+			it.done = true
+			break
+		}
+		idx := it.next
+		if it.descending {
+			idx--
+			it.next--
+		} else {
+			it.next++
+		}
+
+		indexEntry, err := it.rd.ReadIndexEntry(idx)
+		if err != nil {
+			it.done = true
+			return dsq.Result{Error: err}, true
+		}
+		if it.rd.IsEntryHidden(indexEntry) {
+			continue
+		}
+		// skip the offset without reading the values if there are no filters
+		if it.offset != 0 && len(it.q.Filters) == 0 {
+			it.offset--
+			continue
+		}
+		entry, ok, err := it.buildEntry(indexEntry, idx)
+		if err != nil {
+			it.done = true
+			return dsq.Result{Error: err}, true
+		}
+		if !ok {
+			continue
+		}
+		if it.offset != 0 {
+			it.offset--
+			continue
+		}
+		if it.limit != 0 {
+			it.limit--
+			it.done = it.limit == 0
+		}
+		return dsq.Result{Entry: entry}, true
+	}
+	return dsq.Result{}, false
+}
+
+// buildEntry builds the query entry for the index entry at the index.
+//
+// Returns false if the entry does not match the filters.
+func (it *queryIterator) buildEntry(indexEntry *kvfile.IndexEntry, idx uint64) (dsq.Entry, bool, error) {
+	entry := dsq.Entry{
+		Key:  string(indexEntry.GetKey()),
+		Size: int(indexEntry.GetSize()),
+	}
+	if it.q.ReturnExpirations {
+		if expires := indexEntry.GetExpiresUnixMs(); expires != 0 {
+			entry.Expiration = time.UnixMilli(int64(expires))
+		}
+	}
+	// filters may inspect the value, read it first unless keys only
+	if !it.q.KeysOnly {
+		var err error
+		entry.Value, err = it.rd.GetWithEntry(indexEntry, int(idx))
+		if err != nil {
+			return dsq.Entry{}, false, err
+		}
+	}
+	for _, filter := range it.q.Filters {
+		if !filter.Filter(entry) {
+			return dsq.Entry{}, false, nil
+		}
+	}
+	return entry, true, nil
+}
+
+// _ is a type assertion
+var _ ds.Datastore = ((*Datastore)(nil))
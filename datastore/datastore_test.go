@@ -0,0 +1,208 @@
+package kvfile_datastore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	dstest "github.com/ipfs/go-datastore/test"
+)
+
+// rebuildDatastore runs the read-only Datastore against the dstest suites.
+//
+// Writes are applied to a map and the kvfile is rebuilt on the next read.
+type rebuildDatastore struct {
+	t    *testing.T
+	vals map[string][]byte
+	ro   *Datastore
+}
+
+func (d *rebuildDatastore) reader() *Datastore {
+	if d.ro == nil {
+		keys := make([][]byte, 0, len(d.vals))
+		for key := range d.vals {
+			keys = append(keys, []byte(key))
+		}
+		var buf bytes.Buffer
+		err := kvfile.Write(&buf, keys, func(wr io.Writer, key []byte) (uint64, error) {
+			nw, err := wr.Write(d.vals[string(key)])
+			return uint64(nw), err
+		})
+		if err != nil {
+			d.t.Fatal(err.Error())
+		}
+		rd, err := kvfile.BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+		if err != nil {
+			d.t.Fatal(err.Error())
+		}
+		d.ro = NewDatastore(rd)
+	}
+	return d.ro
+}
+
+func (d *rebuildDatastore) Put(ctx context.Context, key ds.Key, value []byte) error {
+	d.vals[key.String()] = bytes.Clone(value)
+	d.ro = nil
+	return nil
+}
+
+func (d *rebuildDatastore) Delete(ctx context.Context, key ds.Key) error {
+	delete(d.vals, key.String())
+	d.ro = nil
+	return nil
+}
+
+func (d *rebuildDatastore) Get(ctx context.Context, key ds.Key) ([]byte, error) {
+	return d.reader().Get(ctx, key)
+}
+
+func (d *rebuildDatastore) Has(ctx context.Context, key ds.Key) (bool, error) {
+	return d.reader().Has(ctx, key)
+}
+
+func (d *rebuildDatastore) GetSize(ctx context.Context, key ds.Key) (int, error) {
+	return d.reader().GetSize(ctx, key)
+}
+
+func (d *rebuildDatastore) Query(ctx context.Context, q dsq.Query) (dsq.Results, error) {
+	return d.reader().Query(ctx, q)
+}
+
+func (d *rebuildDatastore) Sync(ctx context.Context, prefix ds.Key) error {
+	return d.reader().Sync(ctx, prefix)
+}
+
+func (d *rebuildDatastore) Close() error {
+	return nil
+}
+
+func TestDatastoreSuite(t *testing.T) {
+	dstest.SubtestAll(t, &rebuildDatastore{t: t, vals: make(map[string][]byte)})
+}
+
+func TestDatastore(t *testing.T) {
+	ctx := context.Background()
+	vals := map[string][]byte{
+		"/a":     []byte("1"),
+		"/a/b":   []byte("22"),
+		"/a/c":   []byte("333"),
+		"/a/c/d": []byte("4444"),
+		"/ab":    []byte("5"),
+		"/b":     []byte("6"),
+	}
+	keys := make([][]byte, 0, len(vals))
+	for key := range vals {
+		keys = append(keys, []byte(key))
+	}
+	var buf bytes.Buffer
+	err := kvfile.Write(&buf, keys, func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write(vals[string(key)])
+		return uint64(nw), err
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rd, err := kvfile.BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	d := NewDatastore(rd)
+
+	if val, err := d.Get(ctx, ds.NewKey("a/b")); err != nil || string(val) != "22" {
+		t.Fatalf("unexpected get result: %q %v", val, err)
+	}
+	if _, err := d.Get(ctx, ds.NewKey("/missing")); err != ds.ErrNotFound {
+		t.Fatalf("expected ErrNotFound: %v", err)
+	}
+	if size, err := d.GetSize(ctx, ds.NewKey("/a/c")); err != nil || size != 3 {
+		t.Fatalf("unexpected size: %v %v", size, err)
+	}
+	if _, err := d.GetSize(ctx, ds.NewKey("/missing")); err != ds.ErrNotFound {
+		t.Fatalf("expected ErrNotFound: %v", err)
+	}
+	if err := d.Put(ctx, ds.NewKey("/x"), nil); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected ErrReadOnly: %v", err)
+	}
+	if err := d.Delete(ctx, ds.NewKey("/a")); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected ErrReadOnly: %v", err)
+	}
+
+	queryKeys := func(q dsq.Query) []string {
+		res, err := d.Query(ctx, q)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		entries, err := res.Rest()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		keys := make([]string, len(entries))
+		for i, entry := range entries {
+			if entry.Size != len(vals[entry.Key]) || (!q.KeysOnly && !bytes.Equal(entry.Value, vals[entry.Key])) {
+				t.Fatalf("unexpected entry: %v", entry)
+			}
+			if q.KeysOnly && entry.Value != nil {
+				t.Fatalf("expected keys only: %v", entry)
+			}
+			keys[i] = entry.Key
+		}
+		return keys
+	}
+	tests := []struct {
+		q        dsq.Query
+		expected []string
+	}{
+		{dsq.Query{Prefix: "/a"}, []string{"/a/b", "/a/c", "/a/c/d"}},
+		{dsq.Query{Prefix: "/a", KeysOnly: true, Offset: 1, Limit: 1}, []string{"/a/c"}},
+		{dsq.Query{Prefix: "/a", Orders: []dsq.Order{dsq.OrderByKeyDescending{}}, Limit: 2}, []string{"/a/c/d", "/a/c"}},
+		{dsq.Query{Orders: []dsq.Order{dsq.OrderByValueDescending{}}, Limit: 2}, []string{"/b", "/ab"}},
+		{dsq.Query{Filters: []dsq.Filter{dsq.FilterKeyCompare{Op: dsq.GreaterThan, Key: "/a/c"}}, Offset: 1}, []string{"/ab", "/b"}},
+	}
+	for _, tc := range tests {
+		if keys := queryKeys(tc.q); !slices.Equal(keys, tc.expected) {
+			t.Fatalf("unexpected keys for %v: %v", tc.q, keys)
+		}
+	}
+}
+
+func TestDatastoreHidden(t *testing.T) {
+	var buf bytes.Buffer
+	wr := kvfile.NewWriter(&buf)
+	if err := wr.WriteValue([]byte("/a"), bytes.NewReader([]byte("1"))); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.WriteValueWithExpiry([]byte("/b"), bytes.NewReader([]byte("2")), time.UnixMilli(1000)); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.WriteTombstone([]byte("/c")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	rd, err := kvfile.BuildReaderWithOptions(bytes.NewReader(buf.Bytes()), uint64(buf.Len()), kvfile.ReaderOptions{FilterExpired: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	res, err := NewDatastore(rd).Query(context.Background(), dsq.Query{Orders: []dsq.Order{dsq.OrderByKeyDescending{}}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != 1 || entries[0].Key != "/a" {
+		t.Fatalf("expected hidden entries to be skipped: %v", entries)
+	}
+}
+
+// _ is a type assertion
+var _ ds.Datastore = ((*rebuildDatastore)(nil))
@@ -0,0 +1,337 @@
+package kvfile
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// A delta is a patch stream which transforms an old file to a new file:
+//
+//	[magic "KVFD"][version (1 byte)]
+//	[base digest length (uvarint)][base digest]
+//	[new digest length (uvarint)][new digest]
+//	[record]...[end (1 byte)]
+//
+// The digests are the ContentDigest of the old and new files with SHA-256.
+// The records are in key order and are one of:
+//
+//	put:    [1][index entry length (uvarint)][index entry][value]
+//	delete: [2][key length (uvarint)][key]
+//
+// The index entry of a put contains the key, the value size, and the entry
+// metadata. Entries of the old file without a record are unchanged.
+
+// deltaMagic is the magic number at the start of a delta.
+var deltaMagic = []byte("KVFD")
+
+// deltaVersion is the delta format version.
+const deltaVersion = 1
+
+// delta record types
+const (
+	deltaOpEnd = iota
+	deltaOpPut
+	deltaOpDelete
+)
+
+// ErrDeltaBaseMismatch is returned by ApplyDelta if the old file is not the
+// file the delta was created from.
+var ErrDeltaBaseMismatch = errors.New("delta base digest does not match the old file")
+
+// CreateDelta writes a delta from the old file to the new file.
+//
+// The delta contains the added and changed entries with the full values and
+// the removed keys. Computes the content digests of both files, then compares
+// the entries in key order, so the values of each file are read twice. All
+// entries are included regardless of the reader options.
+func CreateDelta(patch io.Writer, old, new *Reader) error {
+	baseDigest, err := ContentDigest(old, sha256.New)
+	if err != nil {
+		return errors.Wrap(err, "digest old file")
+	}
+	newDigest, err := ContentDigest(new, sha256.New)
+	if err != nil {
+		return errors.Wrap(err, "digest new file")
+	}
+
+	bw := bufio.NewWriter(patch)
+	header := append(bytes.Clone(deltaMagic), deltaVersion)
+	header = binary.AppendUvarint(header, uint64(len(baseDigest)))
+	header = append(header, baseDigest...)
+	header = binary.AppendUvarint(header, uint64(len(newDigest)))
+	header = append(header, newDigest...)
+	if _, err := bw.Write(header); err != nil {
+		return err
+	}
+
+	bufA, bufB := make([]byte, equalBufSize), make([]byte, equalBufSize)
+	oldIt, newIt := &entryIterator{r: old}, &entryIterator{r: new}
+	if err := oldIt.next(); err != nil {
+		return err
+	}
+	if err := newIt.next(); err != nil {
+		return err
+	}
+	for oldIt.entry != nil || newIt.entry != nil {
+		switch cmp := compareEntryKeys(oldIt.entry, newIt.entry); {
+		case cmp < 0:
+			// removed key
+			record := binary.AppendUvarint([]byte{deltaOpDelete}, uint64(len(oldIt.entry.GetKey())))
+			if _, err := bw.Write(append(record, oldIt.entry.GetKey()...)); err != nil {
+				return err
+			}
+			err = oldIt.next()
+		case cmp > 0:
+			// added key
+			if err := writeDeltaPut(bw, newIt); err != nil {
+				return err
+			}
+			err = newIt.next()
+		default:
+			equal := entryMetaEqual(oldIt.entry, newIt.entry)
+			if equal {
+				equal, err = entryValuesEqual(old, oldIt.entry, oldIt.idx, new, newIt.entry, newIt.idx, bufA, bufB)
+				if err != nil {
+					return err
+				}
+			}
+			if !equal {
+				// changed entry
+				if err := writeDeltaPut(bw, newIt); err != nil {
+					return err
+				}
+			}
+			if err = oldIt.next(); err == nil {
+				err = newIt.next()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := bw.WriteByte(deltaOpEnd); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// writeDeltaPut writes a put record for the current entry of the iterator.
+func writeDeltaPut(bw *bufio.Writer, it *entryIterator) error {
+	entry := &IndexEntry{
+		Key:           it.entry.GetKey(),
+		Size:          it.entry.GetSize(),
+		ExpiresUnixMs: it.entry.GetExpiresUnixMs(),
+		Meta:          it.entry.GetMeta(),
+		Tombstone:     it.entry.GetTombstone(),
+	}
+	data, err := entry.MarshalVT()
+	if err != nil {
+		return err
+	}
+	record := binary.AppendUvarint([]byte{deltaOpPut}, uint64(len(data)))
+	if _, err := bw.Write(append(record, data...)); err != nil {
+		return err
+	}
+	_, err = it.r.ReadToWithEntry(it.entry, it.idx, bw)
+	return err
+}
+
+// ApplyDelta writes the new file from the old file and a delta.
+//
+// Unchanged values are streamed from the old file and changed values from the
+// delta. Returns ErrDeltaBaseMismatch before writing anything if the content
+// digest of the old file does not match the delta. Returns an error after
+// writing if the content digest of the written file does not match the new
+// file. The file is written with the default writer options, so it is Equal
+// to the new file but the layout can differ.
+func ApplyDelta(dst io.Writer, old *Reader, patch io.Reader) error {
+	br := bufio.NewReader(patch)
+	header := make([]byte, len(deltaMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return errors.Wrap(err, "read delta header")
+	}
+	if !bytes.Equal(header[:len(deltaMagic)], deltaMagic) {
+		return errors.New("not a kvfile delta")
+	}
+	if header[len(deltaMagic)] != deltaVersion {
+		return errors.Errorf("unsupported delta version: %v", header[len(deltaMagic)])
+	}
+	baseDigest, err := readDeltaBytes(br, sha256.Size)
+	if err != nil {
+		return errors.Wrap(err, "read base digest")
+	}
+	newDigest, err := readDeltaBytes(br, sha256.Size)
+	if err != nil {
+		return errors.Wrap(err, "read new digest")
+	}
+	oldDigest, err := ContentDigest(old, sha256.New)
+	if err != nil {
+		return errors.Wrap(err, "digest old file")
+	}
+	if !bytes.Equal(oldDigest, baseDigest) {
+		return ErrDeltaBaseMismatch
+	}
+
+	wr, err := NewWriterWithOptions(dst, WriterOptions{ContentDigest: sha256.New})
+	if err != nil {
+		return err
+	}
+	oldIt := &entryIterator{r: old}
+	if err := oldIt.next(); err != nil {
+		return err
+	}
+	var prevKey []byte
+	for {
+		op, err := br.ReadByte()
+		if err != nil {
+			return errors.Wrap(err, "read delta record")
+		}
+		if op == deltaOpEnd {
+			break
+		}
+		var recordEntry *IndexEntry
+		var recordKey []byte
+		switch op {
+		case deltaOpPut:
+			data, err := readDeltaBytes(br, maxIndexEntrySize)
+			if err != nil {
+				return errors.Wrap(err, "read delta entry")
+			}
+			recordEntry = &IndexEntry{}
+			if err := recordEntry.UnmarshalVT(data); err != nil {
+				return errors.Wrap(err, "read delta entry")
+			}
+			recordKey = recordEntry.GetKey()
+		case deltaOpDelete:
+			recordKey, err = readDeltaBytes(br, maxIndexEntrySize)
+			if err != nil {
+				return errors.Wrap(err, "read delta key")
+			}
+		default:
+			return errors.Errorf("invalid delta record type: %v", op)
+		}
+		if prevKey != nil && bytes.Compare(recordKey, prevKey) <= 0 {
+			return &KeyOrderError{Prev: prevKey, Key: recordKey}
+		}
+		prevKey = recordKey
+
+		// copy the unchanged entries before the record
+		for oldIt.entry != nil && bytes.Compare(oldIt.entry.GetKey(), recordKey) < 0 {
+			if err := copyDeltaEntry(wr, oldIt); err != nil {
+				return err
+			}
+		}
+		replaced := oldIt.entry != nil && bytes.Equal(oldIt.entry.GetKey(), recordKey)
+		if recordEntry == nil && !replaced {
+			return errors.Errorf("delta removes missing key %q", recordKey)
+		}
+		if replaced {
+			if err := oldIt.next(); err != nil {
+				return err
+			}
+		}
+		if recordEntry != nil {
+			valueSize := recordEntry.GetSize()
+			if err := wr.writeEntry(recordEntry, io.LimitReader(br, int64(valueSize)), int64(valueSize)); err != nil {
+				return err
+			}
+			if recordEntry.GetSize() != valueSize {
+				return errors.Wrapf(io.ErrUnexpectedEOF, "read delta value for %q", recordKey)
+			}
+		}
+	}
+	for oldIt.entry != nil {
+		if err := copyDeltaEntry(wr, oldIt); err != nil {
+			return err
+		}
+	}
+	if err := wr.Close(); err != nil {
+		return err
+	}
+	writtenDigest, err := wr.ContentDigest()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(writtenDigest, newDigest) {
+		return errors.New("delta result digest does not match the new file")
+	}
+	return nil
+}
+
+// copyDeltaEntry copies the current entry of the iterator to the writer and
+// advances the iterator.
+func copyDeltaEntry(wr *Writer, it *entryIterator) error {
+	valueRdr, err := it.r.GetValueReaderWithEntry(it.entry, it.idx)
+	if err != nil {
+		return err
+	}
+	entry := &IndexEntry{
+		Key:           it.entry.GetKey(),
+		ExpiresUnixMs: it.entry.GetExpiresUnixMs(),
+		Meta:          it.entry.GetMeta(),
+		Tombstone:     it.entry.GetTombstone(),
+	}
+	if err := wr.writeEntry(entry, valueRdr, valueRdr.Size()); err != nil {
+		return err
+	}
+	return it.next()
+}
+
+// readDeltaBytes reads a length-prefixed byte string of at most maxLen bytes.
+func readDeltaBytes(br *bufio.Reader, maxLen int) ([]byte, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(maxLen) {
+		return nil, errors.Errorf("length %v > max length %v", n, maxLen)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(br, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// entryIterator iterates over all index entries of a Reader.
+type entryIterator struct {
+	r *Reader
+	// entry is the current entry, nil at the end
+	entry *IndexEntry
+	// idx is the index of the current entry
+	idx int
+	// pos is the index of the next entry
+	pos uint64
+}
+
+// next reads the next entry, setting entry to nil at the end.
+func (it *entryIterator) next() error {
+	if it.pos >= it.r.Size() {
+		it.entry = nil
+		return nil
+	}
+	entry, err := it.r.ReadIndexEntry(it.pos)
+	if err != nil {
+		return err
+	}
+	it.entry, it.idx = entry, int(it.pos)
+	it.pos++
+	return nil
+}
+
+// compareEntryKeys compares the keys of two entries where nil sorts last.
+func compareEntryKeys(a, b *IndexEntry) int {
+	switch {
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	default:
+		return bytes.Compare(a.GetKey(), b.GetKey())
+	}
+}
@@ -0,0 +1,233 @@
+package kvfile
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// buildDeltaTestFile writes a kvfile with the values and builds a Reader.
+//
+// Writes the keys in sorted order. Keys in meta are written with the metadata.
+func buildDeltaTestFile(t *testing.T, vals map[string]string, meta map[string]string) *Reader {
+	var buf bytes.Buffer
+	if err := writeDeltaTestFile(&buf, vals, meta); err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return rdr
+}
+
+// writeDeltaTestFile writes a kvfile with the values.
+func writeDeltaTestFile(w io.Writer, vals map[string]string, meta map[string]string) error {
+	keys := make([][]byte, 0, len(vals))
+	for key := range vals {
+		keys = append(keys, []byte(key))
+	}
+	slices.SortFunc(keys, bytes.Compare)
+	wr := NewWriter(w)
+	for _, key := range keys {
+		var err error
+		if m, ok := meta[string(key)]; ok {
+			err = wr.WriteValueBytesMeta(key, []byte(vals[string(key)]), []byte(m))
+		} else {
+			err = wr.WriteValue(key, bytes.NewReader([]byte(vals[string(key)])))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return wr.Close()
+}
+
+// applyDeltaTest applies the delta and builds a Reader for the result.
+func applyDeltaTest(old *Reader, patch []byte) (*Reader, error) {
+	var out bytes.Buffer
+	if err := ApplyDelta(&out, old, bytes.NewReader(patch)); err != nil {
+		return nil, err
+	}
+	return BuildReader(bytes.NewReader(out.Bytes()), uint64(out.Len()))
+}
+
+func TestDelta(t *testing.T) {
+	oldVals := map[string]string{
+		"a":       "unchanged",
+		"b":       "old value",
+		"c":       "removed",
+		"d":       "same value new meta",
+		"e":       string(bytes.Repeat([]byte("x"), 100<<10)),
+		"removed": "also removed",
+	}
+	newVals := map[string]string{
+		"a":     "unchanged",
+		"b":     "new value",
+		"added": "added value",
+		"d":     "same value new meta",
+		"e":     string(bytes.Repeat([]byte("x"), 100<<10-1)) + "y",
+		"empty": "",
+		"z":     "last",
+	}
+	for i := range 100 {
+		key := "many/" + strconv.Itoa(i)
+		oldVals[key] = key
+		if i%10 != 0 {
+			newVals[key] = key
+		}
+	}
+	oldRdr := buildDeltaTestFile(t, oldVals, nil)
+	newRdr := buildDeltaTestFile(t, newVals, map[string]string{"d": "meta"})
+
+	var patch bytes.Buffer
+	if err := CreateDelta(&patch, oldRdr, newRdr); err != nil {
+		t.Fatal(err.Error())
+	}
+	// the unchanged large value is not in the delta
+	if patch.Len() > 200<<10-1000 || patch.Len() < 100<<10 {
+		t.Fatalf("unexpected delta size: %d", patch.Len())
+	}
+	result, err := applyDeltaTest(oldRdr, patch.Bytes())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	equal, err := Equal(result, newRdr)
+	if err != nil || !equal {
+		t.Fatalf("expected the result to equal the new file: %v %v", equal, err)
+	}
+	for _, key := range []string{"c", "removed", "many/0"} {
+		if found, err := result.Exists([]byte(key)); err != nil || found {
+			t.Fatalf("expected %s to be removed: %v %v", key, found, err)
+		}
+	}
+
+	// identical files
+	patch.Reset()
+	if err := CreateDelta(&patch, newRdr, newRdr); err != nil {
+		t.Fatal(err.Error())
+	}
+	result, err = applyDeltaTest(newRdr, patch.Bytes())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if equal, err := Equal(result, newRdr); err != nil || !equal {
+		t.Fatalf("expected the result to equal the file: %v %v", equal, err)
+	}
+
+	// from and to an empty file
+	emptyRdr := buildDeltaTestFile(t, nil, nil)
+	for _, files := range [][2]*Reader{{emptyRdr, newRdr}, {newRdr, emptyRdr}} {
+		patch.Reset()
+		if err := CreateDelta(&patch, files[0], files[1]); err != nil {
+			t.Fatal(err.Error())
+		}
+		result, err := applyDeltaTest(files[0], patch.Bytes())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if equal, err := Equal(result, files[1]); err != nil || !equal {
+			t.Fatalf("expected the result to equal the new file: %v %v", equal, err)
+		}
+	}
+}
+
+func TestDeltaErrors(t *testing.T) {
+	oldRdr := buildDeltaTestFile(t, map[string]string{"a": "1", "b": "2"}, nil)
+	newRdr := buildDeltaTestFile(t, map[string]string{"a": "1", "c": "3"}, nil)
+	var patch bytes.Buffer
+	if err := CreateDelta(&patch, oldRdr, newRdr); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// wrong base
+	var out bytes.Buffer
+	if err := ApplyDelta(&out, newRdr, bytes.NewReader(patch.Bytes())); err != ErrDeltaBaseMismatch {
+		t.Fatalf("expected base mismatch error: %v", err)
+	}
+	if out.Len() != 0 {
+		t.Fatal("expected nothing to be written with the wrong base")
+	}
+
+	// truncated at every position
+	for n := 0; n < patch.Len(); n++ {
+		if _, err := applyDeltaTest(oldRdr, patch.Bytes()[:n]); err == nil {
+			t.Fatalf("expected an error for the delta truncated to %d bytes", n)
+		}
+	}
+
+	// corrupt value: the digest of the result does not match
+	corrupt := bytes.Clone(patch.Bytes())
+	corrupt[len(corrupt)-2] ^= 0xff
+	if _, err := applyDeltaTest(oldRdr, corrupt); err == nil {
+		t.Fatal("expected an error for the corrupt delta")
+	}
+
+	// bad magic
+	corrupt = bytes.Clone(patch.Bytes())
+	corrupt[0] = 'X'
+	if _, err := applyDeltaTest(oldRdr, corrupt); err == nil {
+		t.Fatal("expected an error for the bad magic")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	vals := map[string]string{"a": "1", "b": "2", "c": string(bytes.Repeat([]byte("z"), 3*equalBufSize))}
+	rdr := buildDeltaTestFile(t, vals, nil)
+
+	// different layout, same contents
+	var buf bytes.Buffer
+	wr, err := NewWriterWithOptions(&buf, WriterOptions{ValueHeaders: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if err := wr.WriteValue([]byte(key), bytes.NewReader([]byte(vals[key]))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	other, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if equal, err := Equal(rdr, other); err != nil || !equal {
+		t.Fatalf("expected the files to be equal: %v %v", equal, err)
+	}
+
+	changed := map[string]string{"a": "1", "b": "2", "c": vals["c"][:len(vals["c"])-1] + "y"}
+	for _, other := range []*Reader{
+		buildDeltaTestFile(t, changed, nil),
+		buildDeltaTestFile(t, vals, map[string]string{"b": "meta"}),
+		buildDeltaTestFile(t, map[string]string{"a": "1", "b": "2"}, nil),
+		buildDeltaTestFile(t, map[string]string{"a": "1", "b": "2", "d": vals["c"]}, nil),
+	} {
+		if equal, err := Equal(rdr, other); err != nil || equal {
+			t.Fatalf("expected the files to differ: %v %v", equal, err)
+		}
+	}
+
+	// expiry is compared
+	buf.Reset()
+	wr = NewWriter(&buf)
+	for _, key := range []string{"a", "b", "c"} {
+		if err := wr.WriteValueWithExpiry([]byte(key), bytes.NewReader([]byte(vals[key])), time.UnixMilli(1)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	expiring, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if equal, err := Equal(rdr, expiring); err != nil || equal {
+		t.Fatalf("expected the expiry to differ: %v %v", equal, err)
+	}
+}
@@ -0,0 +1,84 @@
+package kvfile
+
+import (
+	"encoding/binary"
+	"hash"
+
+	"github.com/pkg/errors"
+)
+
+// The content digest depends only on the logical key/value content of a file.
+//
+// For each entry in key order the digest hashes:
+//
+//	[key length (uint64 LE)][key][tombstone (1 byte)][value length (uint64 LE)][value]
+//
+// The lengths and the tombstone flag make the stream unambiguous: a tombstone
+// differs from an empty value. The Writer computes the digest while writing,
+// which requires the values to be written in key order.
+
+// ContentDigest computes the canonical content digest of the file.
+//
+// The digest does not depend on the physical layout: the order values were
+// written, format options, or footer blocks. Two files with the same key/value
+// pairs have the same digest. Values are streamed in chunks. All entries are
+// included regardless of the reader options.
+func ContentDigest(r *Reader, h func() hash.Hash) ([]byte, error) {
+	digest := h()
+	size := r.Size()
+	for i := uint64(0); i < size; i++ {
+		indexEntry, err := r.ReadIndexEntry(i)
+		if err != nil {
+			return nil, err
+		}
+		valueIdx, valueLen, err := r.GetValuePositionWithEntry(indexEntry, int(i))
+		if err != nil {
+			return nil, err
+		}
+		writeEntryDigestHeader(digest, indexEntry, uint64(valueLen))
+		if _, err := r.readValueTo(valueIdx, valueLen, digest); err != nil {
+			return nil, err
+		}
+	}
+	return digest.Sum(nil), nil
+}
+
+// writeEntryDigestHeader writes the entry fields before the value to the
+// content digest.
+func writeEntryDigestHeader(digest hash.Hash, entry *IndexEntry, valueSize uint64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(len(entry.GetKey())))
+	_, _ = digest.Write(buf[:])
+	_, _ = digest.Write(entry.GetKey())
+	var tombstone [1]byte
+	if entry.GetTombstone() {
+		tombstone[0] = 1
+	}
+	_, _ = digest.Write(tombstone[:])
+	binary.LittleEndian.PutUint64(buf[:], valueSize)
+	_, _ = digest.Write(buf[:])
+}
+
+// ContentDigest returns the content digest computed while writing.
+//
+// Requires WriterOptions.ContentDigest to be set. Returns an error if the
+// writer has not been closed yet. The result is identical to ContentDigest
+// with the same hash function on the written file.
+func (w *Writer) ContentDigest() ([]byte, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.opts.ContentDigest == nil {
+		return nil, errors.New("writer content digest is not enabled")
+	}
+	if !w.fin || w.digest == nil {
+		return nil, errors.New("writer content digest is not available until closed")
+	}
+	return w.digest, nil
+}
+
+// digestsEnabledLocked checks if the writer computes the content digest while
+// writing, for WriterOptions.ContentDigest or WriterOptions.SignatureKey.
+func (w *Writer) digestsEnabledLocked() bool {
+	return w.digestHash != nil || w.signHash != nil
+}
@@ -0,0 +1,125 @@
+package kvfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestContentDigest(t *testing.T) {
+	keys := [][]byte{
+		[]byte("test-1"),
+		[]byte("test-2"),
+		[]byte("test-3"),
+		[]byte("test-empty"),
+	}
+	vals := [][]byte{
+		[]byte("val-1"),
+		bytes.Repeat([]byte("val-2"), 1000),
+		[]byte("val-3"),
+		nil,
+	}
+
+	digestOf := func(data []byte) []byte {
+		rdr, err := BuildReader(bytes.NewReader(data), uint64(len(data)))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		digest, err := ContentDigest(rdr, sha256.New)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return digest
+	}
+
+	// write in key order with Write
+	var buf1 bytes.Buffer
+	var index int
+	err := Write(&buf1, keys, func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write(vals[index])
+		index++
+		return uint64(nw), err
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	digest1 := digestOf(buf1.Bytes())
+
+	// write with a different layout
+	var buf2 bytes.Buffer
+	wr, err := NewWriterWithOptions(&buf2, WriterOptions{
+		ValueHeaders:  true,
+		HashIndex:     true,
+		ContentDigest: sha256.New,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for i := range keys {
+		if err := wr.WriteValue(keys[i], bytes.NewReader(vals[i])); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	// the digest is computed while writing in key order
+	var orderErr *KeyOrderError
+	if err := wr.WriteValue(keys[0], bytes.NewReader(vals[0])); !errors.As(err, &orderErr) {
+		t.Fatalf("expected key order error: %v", err)
+	}
+	if _, err := wr.ContentDigest(); err == nil {
+		t.Fatal("expected error before close")
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if bytes.Equal(buf1.Bytes(), buf2.Bytes()) {
+		t.Fatal("expected files to have a different layout")
+	}
+	digest2 := digestOf(buf2.Bytes())
+	if !bytes.Equal(digest1, digest2) {
+		t.Fatalf("expected identical digests: %x != %x", digest1, digest2)
+	}
+	writerDigest, err := wr.ContentDigest()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(writerDigest, digest1) {
+		t.Fatalf("expected writer digest to match: %x != %x", writerDigest, digest1)
+	}
+
+	// change a single byte of a value
+	changed := bytes.Clone(buf1.Bytes())
+	changed[bytes.Index(changed, []byte("val-3"))+4] = '4'
+	if digest3 := digestOf(changed); bytes.Equal(digest1, digest3) {
+		t.Fatal("expected digest to change with the value")
+	}
+
+	// a tombstone differs from an empty value
+	var buf4 bytes.Buffer
+	wr, err = NewWriterWithOptions(&buf4, WriterOptions{ContentDigest: sha256.New})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for i, key := range keys {
+		if i == len(keys)-1 {
+			err = wr.WriteTombstone(key)
+		} else {
+			err = wr.WriteValue(key, bytes.NewReader(vals[i]))
+		}
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	digest4 := digestOf(buf4.Bytes())
+	if bytes.Equal(digest1, digest4) {
+		t.Fatal("expected tombstone to change the digest")
+	}
+	if writerDigest, err := wr.ContentDigest(); err != nil || !bytes.Equal(writerDigest, digest4) {
+		t.Fatalf("expected writer digest to match with a tombstone: %x != %x: %v", writerDigest, digest4, err)
+	}
+}
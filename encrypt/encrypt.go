@@ -0,0 +1,261 @@
+package kvfile_encrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+	"strconv"
+	"sync/atomic"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+)
+
+// Encrypted files store each value sealed with AES-GCM:
+//
+//	[nonce (12 bytes)][ciphertext][tag (16 bytes)]
+//
+// The nonce is random and stored alongside the value. The entry key is used as
+// the additional data so a value cannot be moved to another key undetected.
+//
+// Only the values are encrypted: the keys, the index, and the value sizes are
+// stored in plaintext. The file is marked with the encrypted values format
+// flag so the plain kvfile Reader refuses to open it.
+//
+// Random nonces are safe for up to 2^32 values encrypted with the same key.
+
+// ErrNotEncrypted is returned if the file was not written with encrypted values.
+var ErrNotEncrypted = errors.New("kvfile values are not encrypted")
+
+// AuthError is returned if a value fails authentication.
+//
+// The key is wrong or the value was modified.
+type AuthError struct {
+	// Key is the key of the entry with the value that failed authentication.
+	Key []byte
+}
+
+// Error returns the error string.
+func (e *AuthError) Error() string {
+	return "decrypt value for key " + strconv.Quote(string(e.Key)) + ": message authentication failed"
+}
+
+// randRead fills the buffer with random bytes for the nonces.
+var randRead = rand.Read
+
+// newAEAD builds the AES-GCM cipher from the key.
+//
+// The key must be 16, 24, or 32 bytes.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Writer writes a kvfile encrypting each value.
+// Concurrency safe.
+type Writer struct {
+	w    *kvfile.Writer
+	aead cipher.AEAD
+	// fin is set if the writer was closed by an error
+	fin atomic.Bool
+}
+
+// NewWriter builds a new writer encrypting values with the key.
+//
+// The key must be 16, 24, or 32 bytes to select AES-128, AES-192, or AES-256.
+func NewWriter(out io.Writer, key []byte) (*Writer, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	w, err := kvfile.NewWriterWithOptions(out, kvfile.WriterOptions{EncryptedValues: true})
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, aead: aead}, nil
+}
+
+// WriteValueBytes encrypts and writes a key/value pair.
+//
+// The writer is closed if an error is returned.
+func (w *Writer) WriteValueBytes(key, value []byte) error {
+	if w.fin.Load() {
+		return errors.New("writer is already closed")
+	}
+	nonceSize := w.aead.NonceSize()
+	sealed := make([]byte, nonceSize, nonceSize+len(value)+w.aead.Overhead())
+	if _, err := randRead(sealed); err != nil {
+		w.fin.Store(true)
+		return errors.Wrap(err, "generate nonce")
+	}
+	sealed = w.aead.Seal(sealed, sealed, value, key)
+	return w.w.WriteValue(key, bytes.NewReader(sealed))
+}
+
+// WriteValue reads the value and encrypts and writes the key/value pair.
+//
+// The value is buffered in memory before encrypting.
+// The writer is closed if an error is returned.
+func (w *Writer) WriteValue(key []byte, valueRdr io.Reader) error {
+	value, err := io.ReadAll(valueRdr)
+	if err != nil {
+		w.fin.Store(true)
+		return err
+	}
+	return w.WriteValueBytes(key, value)
+}
+
+// Close completes the Writer by writing the index to the file.
+func (w *Writer) Close() error {
+	if w.fin.Load() {
+		return errors.New("writer is already closed")
+	}
+	return w.w.Close()
+}
+
+// WriteEncrypted writes the given key/value pairs to writer encrypting each value.
+//
+// The key must be 16, 24, or 32 bytes to select AES-128, AES-192, or AES-256.
+// Note: keys must not contain duplicates or an error will be returned.
+// writeValue should write the given value to the writer returning the number of bytes written.
+func WriteEncrypted(writer io.Writer, key []byte, keys [][]byte, writeValue kvfile.WriteValueFunc) error {
+	w, err := NewWriter(writer, key)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, entryKey := range keys {
+		buf.Reset()
+		nw, err := writeValue(&buf, entryKey)
+		if err != nil {
+			return err
+		}
+		if nw != uint64(buf.Len()) {
+			return errors.Errorf("write value returned %v but wrote %v bytes", nw, buf.Len())
+		}
+		if err := w.WriteValueBytes(entryKey, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// Reader reads a kvfile decrypting each value.
+type Reader struct {
+	rd   *kvfile.Reader
+	aead cipher.AEAD
+}
+
+// BuildEncryptedReader builds a reader decrypting values with the key.
+//
+// Returns ErrNotEncrypted if the file was not written with encrypted values.
+func BuildEncryptedReader(rd io.ReaderAt, size uint64, key []byte) (*Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	kvReader, err := kvfile.BuildReaderWithOptions(rd, size, kvfile.ReaderOptions{AllowEncryptedValues: true})
+	if err != nil {
+		return nil, err
+	}
+	if !kvReader.EncryptedValues() {
+		return nil, ErrNotEncrypted
+	}
+	return &Reader{rd: kvReader, aead: aead}, nil
+}
+
+// GetReader returns the underlying kvfile reader.
+//
+// Values read from the underlying reader are encrypted.
+func (r *Reader) GetReader() *kvfile.Reader {
+	return r.rd
+}
+
+// Size returns the number of key/value pairs in the store.
+func (r *Reader) Size() uint64 {
+	return r.rd.Size()
+}
+
+// Exists checks if the given key exists in the store.
+func (r *Reader) Exists(key []byte) (bool, error) {
+	return r.rd.Exists(key)
+}
+
+// Get looks up and decrypts the value for the given key.
+//
+// Returns nil, false, nil if not found.
+// Returns an *AuthError if the value fails authentication.
+func (r *Reader) Get(key []byte) ([]byte, bool, error) {
+	sealed, found, err := r.rd.Get(key)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	value, err := r.open(key, sealed)
+	if err != nil {
+		return nil, true, err
+	}
+	return value, true, nil
+}
+
+// GetWithEntry decrypts the value for the given index entry.
+//
+// Returns an *AuthError if the value fails authentication.
+func (r *Reader) GetWithEntry(indexEntry *kvfile.IndexEntry, indexEntryIdx int) ([]byte, error) {
+	sealed, err := r.rd.GetWithEntry(indexEntry, indexEntryIdx)
+	if err != nil {
+		return nil, err
+	}
+	return r.open(indexEntry.GetKey(), sealed)
+}
+
+// ReadTo decrypts the value for the given key to the writer.
+//
+// The value is authenticated before writing any of it to the writer.
+// Returns number of bytes written, found, and any error.
+// Returns 0, false, nil if not found.
+func (r *Reader) ReadTo(key []byte, to io.Writer) (int, bool, error) {
+	value, found, err := r.Get(key)
+	if err != nil || !found {
+		return 0, found, err
+	}
+	nw, err := to.Write(value)
+	return nw, true, err
+}
+
+// ScanPrefixKeys iterates over keys with a prefix.
+//
+// The key is not decrypted: it is stored in plaintext.
+func (r *Reader) ScanPrefixKeys(prefix []byte, cb func(key []byte) error) error {
+	return r.rd.ScanPrefixKeys(prefix, cb)
+}
+
+// ScanPrefix iterates over key/value pairs with a prefix decrypting each value.
+//
+// Returns an *AuthError if a value fails authentication.
+func (r *Reader) ScanPrefix(prefix []byte, cb func(key, value []byte) error) error {
+	return r.rd.ScanPrefixEntries(prefix, func(indexEntry *kvfile.IndexEntry, indexEntryIdx int) error {
+		value, err := r.GetWithEntry(indexEntry, indexEntryIdx)
+		if err != nil {
+			return err
+		}
+		return cb(indexEntry.GetKey(), value)
+	})
+}
+
+// open authenticates and decrypts the sealed value for the key.
+func (r *Reader) open(key, sealed []byte) ([]byte, error) {
+	nonceSize := r.aead.NonceSize()
+	if len(sealed) < nonceSize+r.aead.Overhead() {
+		return nil, &AuthError{Key: key}
+	}
+	value, err := r.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], key)
+	if err != nil {
+		return nil, &AuthError{Key: key}
+	}
+	return value, nil
+}
@@ -0,0 +1,170 @@
+package kvfile_encrypt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+)
+
+var testKey = bytes.Repeat([]byte{0x42}, 32)
+
+// writeTestFile writes an encrypted file with the test keys and values.
+func writeTestFile(t *testing.T, keys, vals [][]byte) []byte {
+	var buf bytes.Buffer
+	var index int
+	err := WriteEncrypted(&buf, testKey, keys, func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write(vals[index])
+		if err != nil {
+			return 0, err
+		}
+		index++
+		return uint64(nw), nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return buf.Bytes()
+}
+
+func TestKvEncrypt(t *testing.T) {
+	keys := [][]byte{
+		[]byte("test-2"),
+		[]byte("test-1"),
+		[]byte("test-3"),
+	}
+	vals := [][]byte{
+		[]byte("val-2"),
+		[]byte("val-1"),
+		{},
+	}
+	data := writeTestFile(t, keys, vals)
+	if bytes.Contains(data, vals[0]) {
+		t.Fatal("expected value to be encrypted")
+	}
+
+	rdr, err := BuildEncryptedReader(bytes.NewReader(data), uint64(len(data)), testKey)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if rdr.Size() != uint64(len(keys)) {
+		t.Fatalf("unexpected size: %v", rdr.Size())
+	}
+	for i, key := range keys {
+		val, found, err := rdr.Get(key)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !found || !bytes.Equal(val, vals[i]) {
+			t.Fatalf("unexpected value for %s: %v %s", string(key), found, string(val))
+		}
+
+		var out bytes.Buffer
+		nr, found, err := rdr.ReadTo(key, &out)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !found || nr != len(vals[i]) || !bytes.Equal(out.Bytes(), vals[i]) {
+			t.Fatalf("unexpected read to for %s: %v %s", string(key), found, out.String())
+		}
+	}
+
+	_, found, err := rdr.Get([]byte("does-not-exist"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if found {
+		t.Fatal("expected key to not exist")
+	}
+
+	var scanned []string
+	err = rdr.ScanPrefix([]byte("test-"), func(key, value []byte) error {
+		scanned = append(scanned, string(key)+"="+string(value))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(scanned) != 3 || scanned[0] != "test-1=val-1" || scanned[2] != "test-3=" {
+		t.Fatalf("unexpected scan: %v", scanned)
+	}
+}
+
+func TestKvEncryptTamper(t *testing.T) {
+	keys := [][]byte{[]byte("test-1")}
+	vals := [][]byte{[]byte("val-1")}
+	data := writeTestFile(t, keys, vals)
+
+	// flip a byte of the ciphertext after the nonce
+	data[12] ^= 0x01
+	rdr, err := BuildEncryptedReader(bytes.NewReader(data), uint64(len(data)), testKey)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, _, err = rdr.Get(keys[0])
+	var authErr *AuthError
+	if !errors.As(err, &authErr) {
+		t.Fatalf("expected auth error: %v", err)
+	}
+	if !bytes.Equal(authErr.Key, keys[0]) {
+		t.Fatalf("expected auth error to name the key: %s", string(authErr.Key))
+	}
+
+	// wrong key
+	data[12] ^= 0x01
+	rdr, err = BuildEncryptedReader(bytes.NewReader(data), uint64(len(data)), bytes.Repeat([]byte{0x43}, 32))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, _, err := rdr.Get(keys[0]); !errors.As(err, &authErr) {
+		t.Fatalf("expected auth error with the wrong key: %v", err)
+	}
+}
+
+func TestKvEncryptPlainReader(t *testing.T) {
+	data := writeTestFile(t, [][]byte{[]byte("test-1")}, [][]byte{[]byte("val-1")})
+	_, err := kvfile.BuildReader(bytes.NewReader(data), uint64(len(data)))
+	if err != kvfile.ErrEncryptedValues {
+		t.Fatalf("expected plain reader to reject encrypted file: %v", err)
+	}
+
+	// the encrypted reader rejects plain files
+	var buf bytes.Buffer
+	wr := kvfile.NewWriter(&buf)
+	if err := wr.WriteValue([]byte("test-1"), bytes.NewReader([]byte("val-1"))); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = BuildEncryptedReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()), testKey)
+	if err != ErrNotEncrypted {
+		t.Fatalf("expected encrypted reader to reject plain file: %v", err)
+	}
+}
+
+func TestKvEncryptRandError(t *testing.T) {
+	defer func(prev func([]byte) (int, error)) { randRead = prev }(randRead)
+	randRead = func([]byte) (int, error) {
+		return 0, errors.New("test rand error")
+	}
+
+	wr, err := NewWriter(&bytes.Buffer{}, testKey)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.WriteValueBytes([]byte("test-1"), []byte("val-1")); err == nil {
+		t.Fatal("expected nonce error")
+	}
+
+	// the writer is closed after the error
+	randRead = func(b []byte) (int, error) { return len(b), nil }
+	if err := wr.WriteValueBytes([]byte("test-2"), []byte("val-2")); err == nil {
+		t.Fatal("expected closed writer error")
+	}
+	if err := wr.Close(); err == nil {
+		t.Fatal("expected closed writer error")
+	}
+}
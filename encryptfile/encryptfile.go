@@ -0,0 +1,407 @@
+package kvfile_encryptfile
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"strconv"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+)
+
+// Encrypted files store the entire kvfile in fixed-size chunks sealed with
+// AES-GCM:
+//
+//	[header (40 bytes)][chunk 0][chunk 1]...[final chunk]
+//
+// The header contains the magic number, the version, the chunk size, a random
+// salt, and a key check tag. The file key is derived from the key and the salt
+// with HMAC-SHA256 so each file uses a different AES-256 key.
+//
+// Each chunk is the ciphertext of ChunkSize bytes of plaintext followed by the
+// 16 byte tag. The final chunk holds the remaining 1 to ChunkSize bytes, or
+// zero bytes if the file is empty. The nonce contains the chunk index and if
+// the chunk is the final chunk, so chunks cannot be reordered and the file
+// cannot be truncated undetected. The header is the additional data.
+//
+// The layout is deterministic: the plaintext offset of each chunk is the chunk
+// index times the chunk size, so reads decrypt only the chunks in the range.
+
+// headerMagic is the magic number at the start of the file.
+var headerMagic = []byte("KVFE")
+
+const (
+	// version is the format version.
+	version = 1
+	// headerSize is the size of the header.
+	headerSize = 40
+	// saltSize is the size of the salt in the header.
+	saltSize = 16
+	// headerAADSize is the size of the header used as additional data.
+	headerAADSize = 24
+	// tagSize is the size of the AES-GCM tag.
+	tagSize = 16
+)
+
+// nonce kinds
+const (
+	nonceChunk = iota
+	nonceFinalChunk
+	nonceKeyCheck
+)
+
+// DefaultChunkSize is the default plaintext size of each chunk.
+const DefaultChunkSize = 64 * 1024
+
+// MinChunkSize is the minimum chunk size in EncryptOptions.
+const MinChunkSize = 512
+
+// MaxChunkSize is the maximum chunk size in EncryptOptions.
+const MaxChunkSize = 16 * 1024 * 1024
+
+// ErrNotEncrypted is returned if the file is not an encrypted file.
+var ErrNotEncrypted = errors.New("file is not an encrypted kvfile")
+
+// ErrWrongKey is returned if the key check in the header fails.
+//
+// The key is wrong or the header was modified.
+var ErrWrongKey = errors.New("wrong key for encrypted file")
+
+// ChunkAuthError is returned if a chunk fails authentication.
+//
+// The chunk was modified, reordered, or the file was truncated.
+type ChunkAuthError struct {
+	// Index is the index of the chunk.
+	Index uint64
+}
+
+// Error returns the error string.
+func (e *ChunkAuthError) Error() string {
+	return "decrypt chunk " + strconv.FormatUint(e.Index, 10) + ": message authentication failed"
+}
+
+// EncryptOptions are options for writing encrypted files.
+type EncryptOptions struct {
+	// ChunkSize is the plaintext size of each chunk.
+	// Must be between MinChunkSize and MaxChunkSize.
+	//
+	// Reads decrypt each chunk containing part of the range. Small chunks
+	// reduce read amplification for point lookups, large chunks reduce the
+	// size overhead of the tags and improve scan throughput.
+	//
+	// If zero, uses DefaultChunkSize.
+	ChunkSize int
+}
+
+// chunkSize returns the chunk size with the options.
+// opts can be nil to use the defaults.
+func (o *EncryptOptions) chunkSize() (int, error) {
+	if o == nil || o.ChunkSize == 0 {
+		return DefaultChunkSize, nil
+	}
+	if o.ChunkSize < MinChunkSize || o.ChunkSize > MaxChunkSize {
+		return 0, errors.Errorf("invalid chunk size %v: must be between %v and %v", o.ChunkSize, MinChunkSize, MaxChunkSize)
+	}
+	return o.ChunkSize, nil
+}
+
+// newAEAD derives the file key from the key and salt and builds the cipher.
+//
+// The key must be 16, 24, or 32 bytes.
+func newAEAD(key, salt []byte) (cipher.AEAD, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, aes.KeySizeError(len(key))
+	}
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(headerMagic)
+	_, _ = mac.Write(salt)
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// buildNonce builds the nonce for the kind and chunk index.
+func buildNonce(nonce []byte, kind byte, idx uint64) []byte {
+	nonce = nonce[:12]
+	clear(nonce)
+	nonce[0] = kind
+	binary.BigEndian.PutUint64(nonce[4:], idx)
+	return nonce
+}
+
+// UseEncryptedWriter builds an encrypted writer and closes it after the
+// callback returns.
+func UseEncryptedWriter(w io.Writer, key []byte, cb func(w io.Writer) error) error {
+	return UseEncryptedWriterWithOptions(w, key, nil, cb)
+}
+
+// UseEncryptedWriterWithOptions builds an encrypted writer with the options and
+// closes it after the callback returns.
+//
+// opts can be nil to use the defaults. Invalid options or keys return an error
+// before calling the callback.
+func UseEncryptedWriterWithOptions(w io.Writer, key []byte, opts *EncryptOptions, cb func(w io.Writer) error) error {
+	ew, err := newEncryptedWriter(w, key, opts)
+	if err != nil {
+		return err
+	}
+	if err := cb(ew); err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+// WriteEncrypted writes the given key/value pairs to an encrypted file.
+//
+// See kvfile.Write.
+func WriteEncrypted(w io.Writer, key []byte, keys [][]byte, writeValue kvfile.WriteValueFunc) error {
+	return UseEncryptedWriter(w, key, func(w io.Writer) error {
+		return kvfile.Write(w, keys, writeValue)
+	})
+}
+
+// encryptedWriter encrypts the data written to it in chunks.
+type encryptedWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	// buf contains the plaintext of the current chunk
+	buf []byte
+	// out is the buffer for the sealed chunk
+	out   []byte
+	nonce [12]byte
+	// idx is the index of the current chunk
+	idx uint64
+}
+
+// newEncryptedWriter builds the writer and writes the header.
+func newEncryptedWriter(w io.Writer, key []byte, opts *EncryptOptions) (*encryptedWriter, error) {
+	chunkSize, err := opts.chunkSize()
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, headerAADSize, headerSize)
+	copy(header, headerMagic)
+	header[4] = version
+	binary.LittleEndian.PutUint32(header[8:], uint32(chunkSize))
+	if _, err := rand.Read(header[12:headerAADSize]); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key, header[12:headerAADSize])
+	if err != nil {
+		return nil, err
+	}
+	e := &encryptedWriter{
+		w:    w,
+		aead: aead,
+		buf:  make([]byte, 0, chunkSize),
+		out:  make([]byte, 0, chunkSize+tagSize),
+	}
+	header = aead.Seal(header, buildNonce(e.nonce[:], nonceKeyCheck, 0), nil, header)
+	e.header = header
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Write encrypts and writes the data.
+//
+// A full chunk is written once more data follows it, so the final chunk is
+// never empty unless the file is empty.
+func (e *encryptedWriter) Write(p []byte) (int, error) {
+	var nw int
+	for len(p) != 0 {
+		if len(e.buf) == cap(e.buf) {
+			if err := e.writeChunk(false); err != nil {
+				return nw, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):cap(e.buf)], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		nw += n
+	}
+	return nw, nil
+}
+
+// writeChunk seals and writes the buffered chunk.
+func (e *encryptedWriter) writeChunk(final bool) error {
+	kind := byte(nonceChunk)
+	if final {
+		kind = nonceFinalChunk
+	}
+	e.out = e.aead.Seal(e.out[:0], buildNonce(e.nonce[:], kind, e.idx), e.buf, e.header[:headerAADSize])
+	if _, err := e.w.Write(e.out); err != nil {
+		return err
+	}
+	e.buf = e.buf[:0]
+	e.idx++
+	return nil
+}
+
+// Close writes the final chunk.
+func (e *encryptedWriter) Close() error {
+	return e.writeChunk(true)
+}
+
+// ReadSeekerAt is the interface BuildEncryptedReader accepts.
+type ReadSeekerAt interface {
+	io.ReadSeeker
+	io.ReaderAt
+}
+
+// Reader reads the plaintext of an encrypted file.
+//
+// Implements io.ReaderAt, io.ReadSeeker, and Size, so it can be passed to
+// kvfile.BuildReader or to the compress package for files written with
+// encrypt(compress(kvfile)). Each read decrypts the chunks in the range: wrap
+// it with kvfile.NewCachingReaderAt to cache decrypted chunks.
+type Reader struct {
+	*io.SectionReader
+}
+
+// BuildEncryptedReader opens an encrypted file.
+//
+// Returns ErrNotEncrypted if the header is invalid, ErrWrongKey if the key
+// check fails, and a *ChunkAuthError if the final chunk fails authentication.
+// Reads return a *ChunkAuthError if a chunk fails authentication.
+func BuildEncryptedReader(rd ReadSeekerAt, key []byte) (*Reader, error) {
+	size, err := rd.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if size < headerSize {
+		return nil, ErrNotEncrypted
+	}
+	header := make([]byte, headerSize)
+	if _, err := rd.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header[:4], headerMagic) || header[4] != version {
+		return nil, ErrNotEncrypted
+	}
+	chunkSize := int64(binary.LittleEndian.Uint32(header[8:]))
+	if chunkSize < MinChunkSize || chunkSize > MaxChunkSize {
+		return nil, ErrNotEncrypted
+	}
+	aead, err := newAEAD(key, header[12:headerAADSize])
+	if err != nil {
+		return nil, err
+	}
+	d := &decryptReaderAt{
+		rd:        rd,
+		aead:      aead,
+		aad:       header[:headerAADSize],
+		chunkSize: chunkSize,
+	}
+	var nonce [12]byte
+	if _, err := aead.Open(nil, buildNonce(nonce[:], nonceKeyCheck, 0), header[headerAADSize:], d.aad); err != nil {
+		return nil, ErrWrongKey
+	}
+
+	// compute the plaintext size from the layout
+	sealedSize := chunkSize + tagSize
+	dataSize := size - headerSize
+	chunks := (dataSize + sealedSize - 1) / sealedSize
+	lastSize := dataSize - (chunks-1)*sealedSize
+	if chunks == 0 || lastSize < tagSize || (chunks > 1 && lastSize == tagSize) {
+		return nil, &ChunkAuthError{Index: uint64(max(chunks-1, 0))}
+	}
+	d.chunks = uint64(chunks)
+	d.size = dataSize - chunks*tagSize
+
+	// authenticate the final chunk to detect truncation
+	if _, err := d.readChunk(d.chunks-1, nil); err != nil {
+		return nil, err
+	}
+	return &Reader{SectionReader: io.NewSectionReader(d, 0, d.size)}, nil
+}
+
+// BuildReader opens an encrypted file and builds a kvfile.Reader.
+//
+// See BuildEncryptedReader.
+func BuildReader(rd ReadSeekerAt, key []byte) (*kvfile.Reader, error) {
+	r, err := BuildEncryptedReader(rd, key)
+	if err != nil {
+		return nil, err
+	}
+	return kvfile.BuildReader(r, uint64(r.Size()))
+}
+
+// decryptReaderAt decrypts the chunks containing each read.
+type decryptReaderAt struct {
+	rd        io.ReaderAt
+	aead      cipher.AEAD
+	aad       []byte
+	chunkSize int64
+	chunks    uint64
+	size      int64
+}
+
+// readChunk reads and decrypts the chunk appending to buf.
+func (d *decryptReaderAt) readChunk(idx uint64, buf []byte) ([]byte, error) {
+	sealedSize := d.chunkSize + tagSize
+	off := headerSize + int64(idx)*sealedSize
+	kind := byte(nonceChunk)
+	if idx == d.chunks-1 {
+		kind = nonceFinalChunk
+		sealedSize = d.size - int64(idx)*d.chunkSize + tagSize
+	}
+	sealed := make([]byte, sealedSize)
+	if _, err := d.rd.ReadAt(sealed, off); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	var nonce [12]byte
+	out, err := d.aead.Open(buf, buildNonce(nonce[:], kind, idx), sealed, d.aad)
+	if err != nil {
+		return nil, &ChunkAuthError{Index: idx}
+	}
+	return out, nil
+}
+
+// ReadAt decrypts len(p) bytes starting at off.
+func (d *decryptReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= d.size {
+		return 0, io.EOF
+	}
+	var nr int
+	var chunk []byte
+	for nr < len(p) && off < d.size {
+		idx := off / d.chunkSize
+		var err error
+		chunk, err = d.readChunk(uint64(idx), chunk[:0])
+		if err != nil {
+			return nr, err
+		}
+		n := copy(p[nr:], chunk[off-idx*d.chunkSize:])
+		nr += n
+		off += int64(n)
+	}
+	if nr < len(p) {
+		return nr, io.EOF
+	}
+	return nr, nil
+}
+
+// _ is a type assertion
+var (
+	_ ReadSeekerAt = ((*Reader)(nil))
+	_ io.ReaderAt  = ((*decryptReaderAt)(nil))
+)
@@ -0,0 +1,217 @@
+package kvfile_encryptfile
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"testing"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	kvfile_compress "github.com/aperturerobotics/go-kvfile/compress"
+	"github.com/pkg/errors"
+)
+
+var testKey = bytes.Repeat([]byte{0x42}, 32)
+
+// buildTestKeys builds count keys with values of increasing size.
+func buildTestKeys(count int) ([][]byte, kvfile.WriteValueFunc) {
+	keys := make([][]byte, count)
+	for i := range keys {
+		keys[i] = []byte("key-" + strconv.Itoa(1000+i))
+	}
+	return keys, func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write(bytes.Repeat(key, len(key)*3))
+		return uint64(nw), err
+	}
+}
+
+// checkTestKeys checks the reader contains the keys from buildTestKeys.
+func checkTestKeys(t *testing.T, rdr *kvfile.Reader, keys [][]byte) {
+	t.Helper()
+	if rdr.Size() != uint64(len(keys)) {
+		t.Fatalf("expected %d entries: %d", len(keys), rdr.Size())
+	}
+	for _, key := range keys {
+		val, found, err := rdr.Get(key)
+		if err != nil || !found || !bytes.Equal(val, bytes.Repeat(key, len(key)*3)) {
+			t.Fatalf("unexpected value for %s: %v %v", key, found, err)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	keys, writeValue := buildTestKeys(100)
+	var plain bytes.Buffer
+	if err := kvfile.Write(&plain, keys, writeValue); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, chunkSize := range []int{MinChunkSize, 1000, 0} {
+		var buf bytes.Buffer
+		err := UseEncryptedWriterWithOptions(&buf, testKey, &EncryptOptions{ChunkSize: chunkSize}, func(w io.Writer) error {
+			return kvfile.Write(w, keys, writeValue)
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if bytes.Contains(buf.Bytes(), keys[0]) {
+			t.Fatal("expected the keys to be encrypted")
+		}
+
+		r, err := BuildEncryptedReader(bytes.NewReader(buf.Bytes()), testKey)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		decrypted, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(decrypted, plain.Bytes()) {
+			t.Fatalf("unexpected plaintext for chunk size %d: %v", chunkSize, err)
+		}
+		rdr, err := kvfile.BuildReader(r, uint64(r.Size()))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		checkTestKeys(t, rdr, keys)
+	}
+
+	// plaintext sizes around the chunk boundaries
+	for _, size := range []int{0, 1, MinChunkSize - 1, MinChunkSize, MinChunkSize + 1, MinChunkSize * 3} {
+		data := bytes.Repeat([]byte{'x'}, size)
+		var buf bytes.Buffer
+		err := UseEncryptedWriterWithOptions(&buf, testKey, &EncryptOptions{ChunkSize: MinChunkSize}, func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		r, err := BuildEncryptedReader(bytes.NewReader(buf.Bytes()), testKey)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		decrypted, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(decrypted, data) {
+			t.Fatalf("unexpected plaintext for size %d: %d %v", size, len(decrypted), err)
+		}
+	}
+
+	if err := UseEncryptedWriterWithOptions(io.Discard, testKey, &EncryptOptions{ChunkSize: 1}, nil); err == nil {
+		t.Fatal("expected invalid chunk size error")
+	}
+	if err := UseEncryptedWriter(io.Discard, []byte("short"), nil); err == nil {
+		t.Fatal("expected invalid key size error")
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	keys, writeValue := buildTestKeys(10)
+	var buf bytes.Buffer
+	if err := WriteEncrypted(&buf, testKey, keys, writeValue); err != nil {
+		t.Fatal(err.Error())
+	}
+	wrongKey := bytes.Repeat([]byte{0x43}, 32)
+	if _, err := BuildReader(bytes.NewReader(buf.Bytes()), wrongKey); err != ErrWrongKey {
+		t.Fatalf("expected wrong key error: %v", err)
+	}
+	rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), testKey)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	checkTestKeys(t, rdr, keys)
+
+	// plain kvfile
+	var plain bytes.Buffer
+	if err := kvfile.Write(&plain, keys, writeValue); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := BuildReader(bytes.NewReader(plain.Bytes()), testKey); err != ErrNotEncrypted {
+		t.Fatalf("expected not encrypted error: %v", err)
+	}
+}
+
+func TestTamper(t *testing.T) {
+	keys, writeValue := buildTestKeys(100)
+	var buf bytes.Buffer
+	err := UseEncryptedWriterWithOptions(&buf, testKey, &EncryptOptions{ChunkSize: MinChunkSize}, func(w io.Writer) error {
+		return kvfile.Write(w, keys, writeValue)
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	data := buf.Bytes()
+	sealedSize := MinChunkSize + tagSize
+
+	// modified chunk: detected when the chunk is read
+	tampered := bytes.Clone(data)
+	tampered[headerSize+sealedSize+10] ^= 1
+	r, err := BuildEncryptedReader(bytes.NewReader(tampered), testKey)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var authErr *ChunkAuthError
+	if _, err := io.ReadAll(r); !errors.As(err, &authErr) || authErr.Index != 1 {
+		t.Fatalf("expected chunk 1 auth error: %v", err)
+	}
+
+	// swapped chunks
+	tampered = bytes.Clone(data)
+	copy(tampered[headerSize:], data[headerSize+sealedSize:headerSize+sealedSize*2])
+	copy(tampered[headerSize+sealedSize:], data[headerSize:headerSize+sealedSize])
+	r, err = BuildEncryptedReader(bytes.NewReader(tampered), testKey)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := r.ReadAt(make([]byte, 10), 0); !errors.As(err, &authErr) || authErr.Index != 0 {
+		t.Fatalf("expected chunk 0 auth error: %v", err)
+	}
+
+	// modified header
+	tampered = bytes.Clone(data)
+	tampered[20] ^= 1
+	if _, err := BuildEncryptedReader(bytes.NewReader(tampered), testKey); err != ErrWrongKey {
+		t.Fatalf("expected wrong key error for modified header: %v", err)
+	}
+
+	// truncated at a chunk boundary and mid-chunk
+	for _, size := range []int{len(data) - (len(data)-headerSize)%sealedSize, len(data) - 1} {
+		_, err := BuildEncryptedReader(bytes.NewReader(data[:size]), testKey)
+		if !errors.As(err, &authErr) {
+			t.Fatalf("expected auth error for truncated size %d: %v", size, err)
+		}
+	}
+}
+
+func TestCompressStacking(t *testing.T) {
+	keys, writeValue := buildTestKeys(200)
+
+	// encrypt(compress(kvfile))
+	var buf bytes.Buffer
+	err := UseEncryptedWriterWithOptions(&buf, testKey, &EncryptOptions{ChunkSize: 4096}, func(w io.Writer) error {
+		opts := &kvfile_compress.CompressOptions{FrameSize: kvfile_compress.MinFrameSize}
+		return kvfile_compress.WriteCompressWithOptions(w, keys, writeValue, opts)
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var plain bytes.Buffer
+	if err := kvfile.Write(&plain, keys, writeValue); err != nil {
+		t.Fatal(err.Error())
+	}
+	if buf.Len() >= plain.Len() {
+		t.Fatalf("expected the compressed file to be smaller: %d >= %d", buf.Len(), plain.Len())
+	}
+
+	r, err := BuildEncryptedReader(bytes.NewReader(buf.Bytes()), testKey)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, rel, compressed, err := kvfile_compress.BuildAutoReader(r, uint64(r.Size()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rel()
+	if !compressed {
+		t.Fatal("expected the decrypted file to be compressed")
+	}
+	checkTestKeys(t, rdr, keys)
+}
@@ -0,0 +1,75 @@
+package kvfile
+
+import (
+	"bytes"
+	"io"
+)
+
+// equalBufSize is the size of the buffers used to compare values.
+const equalBufSize = 32 * 1024
+
+// Equal checks if two files contain the same entries.
+//
+// Compares the keys, values, and entry metadata (expiry, meta, and tombstones)
+// of all entries regardless of the reader options. The physical layout is
+// ignored. Values are compared in chunks.
+func Equal(a, b *Reader) (bool, error) {
+	if a.Size() != b.Size() {
+		return false, nil
+	}
+	bufA, bufB := make([]byte, equalBufSize), make([]byte, equalBufSize)
+	for i := uint64(0); i < a.Size(); i++ {
+		entryA, err := a.ReadIndexEntry(i)
+		if err != nil {
+			return false, err
+		}
+		entryB, err := b.ReadIndexEntry(i)
+		if err != nil {
+			return false, err
+		}
+		if !entryMetaEqual(entryA, entryB) {
+			return false, nil
+		}
+		equal, err := entryValuesEqual(a, entryA, int(i), b, entryB, int(i), bufA, bufB)
+		if err != nil || !equal {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// entryMetaEqual checks if the index entries have the same key, value size,
+// and metadata, ignoring the value offset.
+func entryMetaEqual(a, b *IndexEntry) bool {
+	return bytes.Equal(a.GetKey(), b.GetKey()) &&
+		a.GetSize() == b.GetSize() &&
+		a.GetExpiresUnixMs() == b.GetExpiresUnixMs() &&
+		bytes.Equal(a.GetMeta(), b.GetMeta()) &&
+		a.GetTombstone() == b.GetTombstone()
+}
+
+// entryValuesEqual compares the values of two entries of the same size.
+func entryValuesEqual(a *Reader, entryA *IndexEntry, idxA int, b *Reader, entryB *IndexEntry, idxB int, bufA, bufB []byte) (bool, error) {
+	rdA, err := a.GetValueReaderWithEntry(entryA, idxA)
+	if err != nil {
+		return false, err
+	}
+	rdB, err := b.GetValueReaderWithEntry(entryB, idxB)
+	if err != nil {
+		return false, err
+	}
+	for remaining := rdA.Size(); remaining > 0; {
+		n := int(min(remaining, int64(len(bufA))))
+		if _, err := io.ReadFull(rdA, bufA[:n]); err != nil {
+			return false, err
+		}
+		if _, err := io.ReadFull(rdB, bufB[:n]); err != nil {
+			return false, err
+		}
+		if !bytes.Equal(bufA[:n], bufB[:n]) {
+			return false, nil
+		}
+		remaining -= int64(n)
+	}
+	return true, nil
+}
@@ -0,0 +1,88 @@
+package kvfile_expvar
+
+import (
+	"expvar"
+	"time"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+)
+
+// Counter names in the expvar map.
+const (
+	// Gets is the number of Get and ReadTo calls.
+	Gets = "gets"
+	// GetHits is the number of Get and ReadTo calls which found the key.
+	GetHits = "get_hits"
+	// GetMisses is the number of Get and ReadTo calls which did not find the key.
+	GetMisses = "get_misses"
+	// GetBytes is the total size of the values read by Get and ReadTo.
+	GetBytes = "get_bytes"
+	// GetNanos is the total duration of Get and ReadTo calls in nanoseconds.
+	GetNanos = "get_ns"
+	// Scans is the number of scans.
+	Scans = "scans"
+	// ScanEntries is the number of entries returned by scans.
+	ScanEntries = "scan_entries"
+	// ScanBytes is the total size of the values read by scans.
+	ScanBytes = "scan_bytes"
+	// ScanNanos is the total duration of scans in nanoseconds.
+	ScanNanos = "scan_ns"
+	// ReadAtCalls is the number of reads from the underlying ReaderAt.
+	ReadAtCalls = "read_at_calls"
+	// ReadAtBytes is the number of bytes read from the underlying ReaderAt.
+	ReadAtBytes = "read_at_bytes"
+)
+
+// NewHooks constructs ReaderHooks which add to counters in the map.
+//
+// The counters are set in the map, replacing any existing values.
+func NewHooks(m *expvar.Map) *kvfile.ReaderHooks {
+	counter := func(name string) *expvar.Int {
+		v := new(expvar.Int)
+		m.Set(name, v)
+		return v
+	}
+	gets, getHits, getMisses := counter(Gets), counter(GetHits), counter(GetMisses)
+	getBytes, getNanos := counter(GetBytes), counter(GetNanos)
+	scans, scanEntries := counter(Scans), counter(ScanEntries)
+	scanBytes, scanNanos := counter(ScanBytes), counter(ScanNanos)
+	readAtCalls, readAtBytes := counter(ReadAtCalls), counter(ReadAtBytes)
+	return &kvfile.ReaderHooks{
+		OnGet: func(key []byte, found bool, bytes int64, dur time.Duration) {
+			gets.Add(1)
+			if found {
+				getHits.Add(1)
+			} else {
+				getMisses.Add(1)
+			}
+			getBytes.Add(bytes)
+			getNanos.Add(int64(dur))
+		},
+		OnScan: func(entries int, bytes int64, dur time.Duration) {
+			scans.Add(1)
+			scanEntries.Add(int64(entries))
+			scanBytes.Add(bytes)
+			scanNanos.Add(int64(dur))
+		},
+		OnReadAt: func(bytes int) {
+			readAtCalls.Add(1)
+			readAtBytes.Add(int64(bytes))
+		},
+	}
+}
+
+// Publish publishes a new expvar map with the name and returns hooks for it.
+//
+// Like expvar.NewMap, panics if the name is already registered.
+func Publish(name string) *kvfile.ReaderHooks {
+	return NewHooks(expvar.NewMap(name))
+}
+
+// CacheStatsVar returns an expvar.Var reporting the CachingReaderAt counters.
+//
+// For example: expvar.Publish("kvfile_cache", CacheStatsVar(cache)).
+func CacheStatsVar(c *kvfile.CachingReaderAt) expvar.Var {
+	return expvar.Func(func() any {
+		return c.Stats()
+	})
+}
@@ -0,0 +1,68 @@
+package kvfile_expvar
+
+import (
+	"bytes"
+	"expvar"
+	"testing"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+)
+
+func TestHooks(t *testing.T) {
+	var buf bytes.Buffer
+	wr := kvfile.NewWriter(&buf)
+	for _, key := range []string{"a/1", "a/2", "b/1"} {
+		if err := wr.WriteValue([]byte(key), bytes.NewReader([]byte("val-"+key))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	m := new(expvar.Map).Init()
+	rdr, err := kvfile.BuildReaderWithOptions(bytes.NewReader(buf.Bytes()), uint64(buf.Len()), kvfile.ReaderOptions{Hooks: NewHooks(m)})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, found, err := rdr.Get([]byte("a/1")); err != nil || !found {
+		t.Fatalf("expected a/1: %v %v", found, err)
+	}
+	if _, found, err := rdr.Get([]byte("missing")); err != nil || found {
+		t.Fatalf("expected missing: %v %v", found, err)
+	}
+	if err := rdr.ScanPrefix([]byte("a/"), func(key, value []byte) error { return nil }); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := map[string]int64{
+		Gets:        2,
+		GetHits:     1,
+		GetMisses:   1,
+		GetBytes:    int64(len("val-a/1")),
+		Scans:       1,
+		ScanEntries: 2,
+		ScanBytes:   int64(len("val-a/1") + len("val-a/2")),
+	}
+	for name, value := range expected {
+		if actual := m.Get(name).(*expvar.Int).Value(); actual != value {
+			t.Fatalf("expected %s to be %d: %d", name, value, actual)
+		}
+	}
+	if m.Get(ReadAtCalls).(*expvar.Int).Value() == 0 || m.Get(ReadAtBytes).(*expvar.Int).Value() == 0 {
+		t.Fatal("expected reads to be counted")
+	}
+}
+
+func TestCacheStatsVar(t *testing.T) {
+	cache := kvfile.NewCachingReaderAt(bytes.NewReader([]byte("hello world")), 4, 2)
+	p := make([]byte, 5)
+	for range 2 {
+		if _, err := cache.ReadAt(p, 0); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if str := CacheStatsVar(cache).String(); str != `{"Hits":2,"Misses":2,"Blocks":2}` {
+		t.Fatalf("unexpected cache stats: %s", str)
+	}
+}
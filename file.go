@@ -0,0 +1,216 @@
+package kvfile
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// DirectAlignment is the alignment of the offsets, sizes, and buffers of reads
+// from files opened with OpenFileOptions.Direct.
+const DirectAlignment = 4096
+
+// directBufferSize is the size of the aligned buffers used for direct reads.
+const directBufferSize = 1024 * 1024
+
+// DefaultDropCacheInterval is the default OpenFileOptions.DropCacheInterval.
+const DefaultDropCacheInterval = 32 * 1024 * 1024
+
+// OpenFileOptions are options for OpenFileWithOptions.
+//
+// The page cache options are only supported on Linux and are ignored on other
+// platforms.
+type OpenFileOptions struct {
+	// Sequential advises the kernel that the file will be read sequentially
+	// (POSIX_FADV_SEQUENTIAL), increasing the readahead for scans.
+	Sequential bool
+	// DropCache advises the kernel to drop the cached pages of the file
+	// (POSIX_FADV_DONTNEED) every DropCacheInterval bytes read and on Close, so
+	// scanning a large file does not evict other data from the page cache.
+	DropCache bool
+	// DropCacheInterval is the number of bytes to read between dropping the
+	// cached pages with DropCache. If zero, uses DefaultDropCacheInterval.
+	DropCacheInterval int64
+	// Direct opens the file with O_DIRECT to bypass the page cache.
+	//
+	// Each read is expanded to DirectAlignment boundaries and read into an
+	// aligned buffer, so small reads of the index read at least one block. If
+	// the filesystem does not support O_DIRECT the file is opened without it:
+	// see File.Direct.
+	Direct bool
+	// ReaderOptions are the options for the Reader.
+	ReaderOptions ReaderOptions
+}
+
+// File is a Reader for a kvfile opened from a path.
+type File struct {
+	*Reader
+	file      *os.File
+	direct    bool
+	dropCache bool
+}
+
+// OpenFile opens the kvfile at the path.
+//
+// The File must be closed when done.
+func OpenFile(path string) (*File, error) {
+	return OpenFileWithOptions(path, OpenFileOptions{})
+}
+
+// OpenFileWithOptions opens the kvfile at the path with the options.
+//
+// The File must be closed when done.
+func OpenFileWithOptions(path string, opts OpenFileOptions) (*File, error) {
+	file, directPread, err := openFile(path, opts.Direct)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{file: file, direct: directPread != nil, dropCache: opts.DropCache}
+	fi, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	var rd io.ReaderAt = file
+	if directPread != nil {
+		rd = newDirectReaderAt(directPread)
+	}
+	if opts.Sequential {
+		adviseFile(file, fileAdviceSequential)
+	}
+	if opts.DropCache {
+		interval := opts.DropCacheInterval
+		if interval <= 0 {
+			interval = DefaultDropCacheInterval
+		}
+		rd = &dropCacheReaderAt{rd: rd, file: file, interval: interval}
+	}
+	f.Reader, err = BuildReaderWithOptions(rd, uint64(fi.Size()), opts.ReaderOptions)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// GetFile returns the underlying file.
+func (f *File) GetFile() *os.File {
+	return f.file
+}
+
+// Direct returns if the file was opened with O_DIRECT.
+func (f *File) Direct() bool {
+	return f.direct
+}
+
+// Close closes the file, dropping the cached pages if using DropCache.
+func (f *File) Close() error {
+	if f.dropCache {
+		adviseFile(f.file, fileAdviceDropCache)
+	}
+	return f.file.Close()
+}
+
+// fileAdvice is advice for the kernel about how a file will be read.
+type fileAdvice int
+
+const (
+	// fileAdviceSequential is POSIX_FADV_SEQUENTIAL.
+	fileAdviceSequential fileAdvice = iota
+	// fileAdviceDropCache is POSIX_FADV_DONTNEED.
+	fileAdviceDropCache
+)
+
+// preadFunc reads from the file at the offset with a single system call.
+//
+// Returns 0, nil at the end of the file.
+type preadFunc func(p []byte, off int64) (int, error)
+
+// directReaderAt reads with aligned offsets, sizes, and buffers.
+type directReaderAt struct {
+	pread preadFunc
+	bufs  sync.Pool
+}
+
+// newDirectReaderAt builds a new directReaderAt.
+func newDirectReaderAt(pread preadFunc) *directReaderAt {
+	d := &directReaderAt{pread: pread}
+	d.bufs.New = func() any {
+		buf := alignedBuffer(directBufferSize)
+		return &buf
+	}
+	return d
+}
+
+// ReadAt reads len(p) bytes at off through an aligned buffer.
+func (d *directReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	bufp := d.bufs.Get().(*[]byte)
+	defer d.bufs.Put(bufp)
+	buf := *bufp
+
+	var nr int
+	for nr < len(p) {
+		pos := off + int64(nr)
+		alignedPos := pos &^ (DirectAlignment - 1)
+		skip := int(pos - alignedPos)
+		readLen := min(alignUp(skip+len(p)-nr), len(buf))
+		n, err := d.pread(buf[:readLen], alignedPos)
+		if n > skip {
+			nr += copy(p[nr:], buf[skip:n])
+		} else if err == nil {
+			// no data at the position: the end of the file
+			err = io.EOF
+		}
+		if err != nil && nr < len(p) {
+			return nr, err
+		}
+	}
+	return nr, nil
+}
+
+// alignUp rounds n up to a multiple of DirectAlignment.
+func alignUp(n int) int {
+	return (n + DirectAlignment - 1) &^ (DirectAlignment - 1)
+}
+
+// alignedBuffer allocates a buffer with the address aligned to DirectAlignment.
+func alignedBuffer(size int) []byte {
+	buf := make([]byte, size+DirectAlignment)
+	shift := int(uintptr(unsafe.Pointer(unsafe.SliceData(buf))) & (DirectAlignment - 1))
+	if shift != 0 {
+		shift = DirectAlignment - shift
+	}
+	return buf[shift : shift+size : shift+size]
+}
+
+// dropCacheReaderAt drops the cached pages of the file every interval bytes.
+type dropCacheReaderAt struct {
+	rd       io.ReaderAt
+	file     *os.File
+	interval int64
+	read     atomic.Int64
+}
+
+// ReadAt reads from the underlying ReaderAt.
+func (d *dropCacheReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := d.rd.ReadAt(p, off)
+	total := d.read.Add(int64(n))
+	if total/d.interval != (total-int64(n))/d.interval {
+		adviseFile(d.file, fileAdviceDropCache)
+	}
+	return n, err
+}
+
+// _ is a type assertion
+var (
+	_ io.ReaderAt = ((*directReaderAt)(nil))
+	_ io.ReaderAt = ((*dropCacheReaderAt)(nil))
+)
@@ -0,0 +1,73 @@
+package kvfile
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// openFile opens the file for reading, with O_DIRECT if direct is set.
+//
+// Returns the function to read from the file with O_DIRECT, or nil if the file
+// was opened without O_DIRECT because direct is not set or the filesystem does
+// not support it.
+func openFile(path string, direct bool) (*os.File, preadFunc, error) {
+	if direct {
+		file, err := os.OpenFile(path, os.O_RDONLY|unix.O_DIRECT, 0)
+		if err == nil {
+			return file, newFilePread(file), nil
+		}
+		if !errors.Is(err, unix.EINVAL) {
+			return nil, nil, err
+		}
+		// the filesystem does not support O_DIRECT
+	}
+	file, err := os.Open(path)
+	return file, nil, err
+}
+
+// newFilePread builds a preadFunc for the file.
+//
+// os.File ReadAt retries short reads at unaligned offsets which fails with
+// O_DIRECT at the end of the file, so the reads call pread directly.
+func newFilePread(file *os.File) preadFunc {
+	return func(p []byte, off int64) (int, error) {
+		rc, err := file.SyscallConn()
+		if err != nil {
+			return 0, err
+		}
+		var n int
+		var readErr error
+		err = rc.Read(func(fd uintptr) bool {
+			for {
+				n, readErr = unix.Pread(int(fd), p, off)
+				if readErr != unix.EINTR {
+					return true
+				}
+			}
+		})
+		if err != nil {
+			return 0, err
+		}
+		if readErr != nil {
+			return 0, &os.PathError{Op: "pread", Path: file.Name(), Err: readErr}
+		}
+		return n, nil
+	}
+}
+
+// adviseFile gives the kernel advice about the file, ignoring errors.
+func adviseFile(file *os.File, advice fileAdvice) {
+	rc, err := file.SyscallConn()
+	if err != nil {
+		return
+	}
+	fadvice := unix.FADV_SEQUENTIAL
+	if advice == fileAdviceDropCache {
+		fadvice = unix.FADV_DONTNEED
+	}
+	_ = rc.Control(func(fd uintptr) {
+		_ = unix.Fadvise(int(fd), 0, 0, fadvice)
+	})
+}
@@ -0,0 +1,16 @@
+package kvfile
+
+import "testing"
+
+func TestOpenFileDirect(t *testing.T) {
+	path, vals := writeUnalignedTestFile(t)
+	f, err := OpenFileWithOptions(path, OpenFileOptions{Direct: true, DropCache: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer f.Close()
+	if !f.Direct() {
+		t.Skip("the filesystem does not support O_DIRECT")
+	}
+	checkFileValues(t, f, vals)
+}
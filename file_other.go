@@ -0,0 +1,16 @@
+//go:build !linux
+
+package kvfile
+
+import "os"
+
+// openFile opens the file for reading.
+//
+// O_DIRECT is only supported on Linux: always returns a nil preadFunc.
+func openFile(path string, direct bool) (*os.File, preadFunc, error) {
+	file, err := os.Open(path)
+	return file, nil, err
+}
+
+// adviseFile does nothing: file advice is only supported on Linux.
+func adviseFile(file *os.File, advice fileAdvice) {}
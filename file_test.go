@@ -0,0 +1,136 @@
+package kvfile
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// writeUnalignedTestFile writes a kvfile with values of odd sizes so the value
+// offsets are not aligned to DirectAlignment.
+func writeUnalignedTestFile(t testing.TB) (string, map[string][]byte) {
+	vals := make(map[string][]byte)
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	for i := range 200 {
+		key := "key-" + strconv.Itoa(1000+i)
+		value := bytes.Repeat([]byte(key), (i*37)%400)
+		if i%50 == 0 {
+			// values crossing the direct buffer size
+			value = bytes.Repeat([]byte{byte(i)}, directBufferSize+i+1)
+		}
+		vals[key] = value
+		if err := wr.WriteValue([]byte(key), bytes.NewReader(value)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	path := filepath.Join(t.TempDir(), "unaligned.kvf")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	return path, vals
+}
+
+// checkFileValues checks the values with Get, ReadTo, and ScanPrefix.
+func checkFileValues(t *testing.T, f *File, vals map[string][]byte) {
+	t.Helper()
+	for key, value := range vals {
+		got, found, err := f.Get([]byte(key))
+		if err != nil || !found || !bytes.Equal(got, value) {
+			t.Fatalf("unexpected value for %s: %d bytes %v %v", key, len(got), found, err)
+		}
+	}
+	var count int
+	err := f.ScanPrefix(nil, func(key, value []byte) error {
+		count++
+		if !bytes.Equal(value, vals[string(key)]) {
+			return errors.Errorf("unexpected scanned value for %s", key)
+		}
+		return nil
+	})
+	if err != nil || count != len(vals) {
+		t.Fatalf("unexpected scan: %d %v", count, err)
+	}
+	var out bytes.Buffer
+	if _, found, err := f.ReadTo([]byte("key-1050"), &out); err != nil || !found || !bytes.Equal(out.Bytes(), vals["key-1050"]) {
+		t.Fatalf("unexpected ReadTo: %v %v", found, err)
+	}
+}
+
+func TestOpenFile(t *testing.T) {
+	path, vals := writeUnalignedTestFile(t)
+	for _, opts := range []OpenFileOptions{
+		{},
+		{Sequential: true},
+		{DropCache: true, DropCacheInterval: 4096},
+		{Direct: true},
+		{Direct: true, Sequential: true, DropCache: true},
+	} {
+		f, err := OpenFileWithOptions(path, opts)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		checkFileValues(t, f, vals)
+		if err := f.Close(); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	if _, err := OpenFile(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(err) {
+		t.Fatalf("expected not exist error: %v", err)
+	}
+}
+
+func TestDirectReaderAt(t *testing.T) {
+	data := make([]byte, DirectAlignment*5+123)
+	for i := range data {
+		data[i] = byte(i % 253)
+	}
+	var calls int
+	rd := newDirectReaderAt(func(p []byte, off int64) (int, error) {
+		calls++
+		if off%DirectAlignment != 0 || len(p)%DirectAlignment != 0 || uintptr(unsafe.Pointer(&p[0]))%DirectAlignment != 0 {
+			return 0, errors.Errorf("unaligned read: %d bytes at %d", len(p), off)
+		}
+		if off >= int64(len(data)) {
+			return 0, nil
+		}
+		// short reads of at most two blocks
+		if len(p) > DirectAlignment*2 {
+			p = p[:DirectAlignment*2]
+		}
+		return copy(p, data[off:]), nil
+	})
+
+	for _, off := range []int64{0, 1, 100, DirectAlignment - 1, DirectAlignment, DirectAlignment*3 + 7, int64(len(data)) - 10} {
+		for _, length := range []int{1, 10, DirectAlignment, DirectAlignment*2 + 5, len(data)} {
+			p := make([]byte, length)
+			n, err := rd.ReadAt(p, off)
+			expected := min(length, len(data)-int(off))
+			if n != expected || !bytes.Equal(p[:n], data[off:off+int64(n)]) {
+				t.Fatalf("unexpected read of %d bytes at %d: %d %v", length, off, n, err)
+			}
+			if n < length && err != io.EOF {
+				t.Fatalf("expected EOF for a short read of %d bytes at %d: %v", length, off, err)
+			}
+			if n == length && err != nil {
+				t.Fatalf("unexpected error for a full read of %d bytes at %d: %v", length, off, err)
+			}
+		}
+	}
+	if calls == 0 {
+		t.Fatal("expected reads through the pread function")
+	}
+	if _, err := rd.ReadAt(make([]byte, 1), int64(len(data))); err != io.EOF {
+		t.Fatalf("expected EOF at the end: %v", err)
+	}
+}
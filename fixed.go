@@ -0,0 +1,116 @@
+package kvfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+// The fixed-width key layout stores the index as a list of records:
+//
+//	[key (key width)][offset (offset width)][size (size width)]
+//
+// The offset and size are little-endian with the minimum number of bytes
+// required for the largest offset and size in the file. The widths are stored
+// in the format footer block.
+
+// readFixedIndexLayout initializes the reader for the fixed-width key layout.
+//
+// indexEnd is the position just after the last index record.
+func (r *Reader) readFixedIndexLayout(indexEntryCount, indexEnd uint64) error {
+	recordSize := r.fixedRecordSize()
+	if indexEntryCount > indexEnd/recordSize {
+		return errors.Errorf("index entry count too large: %v", indexEntryCount)
+	}
+	r.indexEntryCount = indexEntryCount
+	r.indexEntryListPos = indexEnd - indexEntryCount*recordSize
+	r.indexEntryIndexesPos = indexEnd
+	return nil
+}
+
+// fixedRecordSize returns the size of a fixed-width index record.
+func (r *Reader) fixedRecordSize() uint64 {
+	return r.fixedKeyWidth + r.fixedOffsetWidth + r.fixedSizeWidth
+}
+
+// readFixedIndexEntry reads the fixed-width index record at the given index.
+func (r *Reader) readFixedIndexEntry(indexEntryIdx uint64) (*IndexEntry, error) {
+	recordSize := r.fixedRecordSize()
+	recordPos := r.indexEntryListPos + indexEntryIdx*recordSize
+	buf := make([]byte, recordSize)
+	if _, err := r.rd.ReadAt(buf, int64(recordPos)); err != nil {
+		return nil, err
+	}
+	keyWidth, offsetWidth := r.fixedKeyWidth, r.fixedOffsetWidth
+	indexEntry := &IndexEntry{
+		Key:    buf[:keyWidth],
+		Offset: readFixedUint(buf[keyWidth : keyWidth+offsetWidth]),
+		Size:   readFixedUint(buf[keyWidth+offsetWidth:]),
+	}
+	if off := indexEntry.GetOffset(); off > r.indexEntryListPos && !r.ExternalValues() {
+		return nil, errors.Errorf("invalid index entry at %v: offset %v is greater than index entry pos", recordPos, off)
+	}
+	return indexEntry, nil
+}
+
+// fixedRecordWidths determines the minimum offset and size widths for the index.
+func fixedRecordWidths(index []*IndexEntry) (offsetWidth, sizeWidth int) {
+	var maxOffset, maxSize uint64
+	for _, indexEntry := range index {
+		maxOffset = max(maxOffset, indexEntry.GetOffset())
+		maxSize = max(maxSize, indexEntry.GetSize())
+	}
+	return fixedUintWidth(maxOffset), fixedUintWidth(maxSize)
+}
+
+// fixedUintWidth returns the number of bytes needed to store the value (min 1).
+func fixedUintWidth(val uint64) int {
+	return max(1, (bits.Len64(val)+7)/8)
+}
+
+// appendFixedUint appends the little-endian value with the given width.
+func appendFixedUint(buf []byte, val uint64, width int) []byte {
+	for i := 0; i < width; i++ {
+		buf = append(buf, byte(val>>(8*i)))
+	}
+	return buf
+}
+
+// readFixedUint reads a little-endian value with the width of the buffer.
+func readFixedUint(buf []byte) uint64 {
+	var full [8]byte
+	copy(full[:], buf)
+	return binary.LittleEndian.Uint64(full[:])
+}
+
+// writeFixedIndexEntries writes the sorted index as fixed-width records.
+//
+// returns the number of bytes written.
+func writeFixedIndexEntries(writer io.Writer, index []*IndexEntry, keyWidth, offsetWidth, sizeWidth int) (uint64, error) {
+	var nw uint64
+	buf := make([]byte, 0, keyWidth+offsetWidth+sizeWidth)
+	var prevKey []byte
+	for i, indexEntry := range index {
+		key := indexEntry.GetKey()
+		if len(key) != keyWidth {
+			return nw, errors.Errorf("key length %v does not match fixed key width %v", len(key), keyWidth)
+		}
+		if i != 0 && bytes.Equal(key, prevKey) {
+			return nw, errors.Errorf("duplicate key while writing: %q", key)
+		}
+		prevKey = key
+
+		buf = append(buf[:0], key...)
+		buf = appendFixedUint(buf, indexEntry.GetOffset(), offsetWidth)
+		buf = appendFixedUint(buf, indexEntry.GetSize(), sizeWidth)
+		n, err := writeFull(writer, buf)
+		nw += uint64(n)
+		if err != nil {
+			return nw, err
+		}
+	}
+	return nw, nil
+}
@@ -0,0 +1,142 @@
+package kvfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"strconv"
+	"testing"
+)
+
+// buildHashKeys builds n sorted-order-independent 32-byte keys.
+func buildHashKeys(n int) [][]byte {
+	keys := make([][]byte, n)
+	for i := range keys {
+		h := sha256.Sum256(binary.LittleEndian.AppendUint64(nil, uint64(i)))
+		keys[i] = h[:]
+	}
+	return keys
+}
+
+// writeHashKeys writes the keys with a small value to a buffer.
+func writeHashKeys(t testing.TB, keys [][]byte, opts WriterOptions) []byte {
+	var buf bytes.Buffer
+	wr, err := NewWriterWithOptions(&buf, opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for i, key := range keys {
+		if err := wr.WriteValue(key, bytes.NewReader([]byte(strconv.Itoa(i)))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	return buf.Bytes()
+}
+
+func TestFixedKeyWidth(t *testing.T) {
+	keys := buildHashKeys(100)
+	data := writeHashKeys(t, keys, WriterOptions{FixedKeyWidth: 32})
+
+	rdr, err := BuildReader(bytes.NewReader(data), uint64(len(data)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if rdr.Size() != uint64(len(keys)) {
+		t.Fatalf("unexpected size: %v", rdr.Size())
+	}
+	for i, key := range keys {
+		val, found, err := rdr.Get(key)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !found || string(val) != strconv.Itoa(i) {
+			t.Fatalf("unexpected value for key %d: %v %s", i, found, string(val))
+		}
+	}
+
+	notFound := bytes.Repeat([]byte{0xff}, 32)
+	keyExists, err := rdr.Exists(notFound)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if keyExists {
+		t.Fatal("expected key to not exist")
+	}
+
+	var prev []byte
+	var count int
+	err = rdr.ScanPrefixKeys(nil, func(key []byte) error {
+		if prev != nil && bytes.Compare(prev, key) >= 0 {
+			t.Fatalf("keys out of order: %x >= %x", prev, key)
+		}
+		prev = bytes.Clone(key)
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if count != len(keys) {
+		t.Fatalf("expected %d keys in scan: %d", len(keys), count)
+	}
+
+	// the default layout is larger
+	defaultData := writeHashKeys(t, keys, WriterOptions{})
+	if len(defaultData) <= len(data) {
+		t.Fatalf("expected fixed layout to be smaller: %v >= %v", len(data), len(defaultData))
+	}
+}
+
+func TestFixedKeyWidthEmpty(t *testing.T) {
+	data := writeHashKeys(t, nil, WriterOptions{FixedKeyWidth: 32})
+	rdr, err := BuildReader(bytes.NewReader(data), uint64(len(data)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if rdr.Size() != 0 {
+		t.Fatalf("unexpected size: %v", rdr.Size())
+	}
+}
+
+func TestFixedKeyWidthWrongWidth(t *testing.T) {
+	var buf bytes.Buffer
+	wr, err := NewWriterWithOptions(&buf, WriterOptions{FixedKeyWidth: 32})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.WriteValue([]byte("short"), bytes.NewReader([]byte("value"))); err == nil {
+		t.Fatal("expected error writing key with the wrong width")
+	}
+	// nothing should have been written
+	if buf.Len() != 0 || wr.GetPos() != 0 {
+		t.Fatalf("expected no data to be written: %v", buf.Len())
+	}
+}
+
+func benchmarkGet(b *testing.B, opts WriterOptions) {
+	keys := buildHashKeys(100000)
+	data := writeHashKeys(b, keys, opts)
+	rdr, err := BuildReader(bytes.NewReader(data), uint64(len(data)))
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+	b.ReportMetric(float64(len(data))/float64(len(keys)), "file-bytes/entry")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, found, err := rdr.Get(keys[i%len(keys)])
+		if err != nil || !found {
+			b.Fatalf("get failed: %v %v", found, err)
+		}
+	}
+}
+
+func BenchmarkGetDefaultLayout(b *testing.B) {
+	benchmarkGet(b, WriterOptions{})
+}
+
+func BenchmarkGetFixedKeyWidth(b *testing.B) {
+	benchmarkGet(b, WriterOptions{FixedKeyWidth: 32})
+}
@@ -0,0 +1,320 @@
+package kvfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	protobuf_go_lite "github.com/aperturerobotics/protobuf-go-lite"
+	"github.com/pkg/errors"
+)
+
+// The footer extension area is an optional sequence of blocks written between
+// the index entries and the index entry positions list:
+//
+//	[values][index entries][block data...][directory][dir length][magic][positions][count]
+//
+// The directory is a list of (type, length) varint pairs, one per block, in
+// the order the block data was written. The directory length is a fixed size
+// uint64 followed by footerMagic.
+//
+// Old readers locate index entries via the positions list and never read the
+// footer area, so adding blocks to a file does not break them. Layouts without
+// a positions list (see WriterOptions.FixedKeyWidth) place the footer directly
+// before the trailing count.
+//
+// Readers skip blocks with unknown types. Files without any blocks have no
+// footer area and are parsed exactly as before.
+
+// footerMagic marks the end of the footer extension area.
+//
+// The last byte has the high bit set: it can never be the final byte of the
+// index entry size varint which precedes the positions list in files without a
+// footer. Interpreted as a position or entry count it is always out of range.
+var footerMagic = []byte{'K', 'V', 'F', 'I', 'L', 'E', 'X', 0x81}
+
+// footerTailSize is the size of the directory length and magic.
+const footerTailSize = 16
+
+// footerReadSize is the number of bytes read at the end of the footer when
+// opening a file. Usually contains the entire directory and format block.
+const footerReadSize = 512
+
+// maxFooterDirSize is the maximum size of the footer block directory.
+var maxFooterDirSize uint64 = 64 * 1024
+
+// Footer block types used by kvfile.
+//
+// Types below MinUserFooterBlockType are reserved for kvfile.
+const (
+	// FooterBlockFormat contains the format flags of the file.
+	FooterBlockFormat uint32 = 1
+	// FooterBlockBounds contains the min and max keys in the file.
+	FooterBlockBounds uint32 = 2
+	// FooterBlockHashIndex contains the hash table for point lookups.
+	FooterBlockHashIndex uint32 = 3
+	// FooterBlockStats contains statistics about the entries in the file.
+	FooterBlockStats uint32 = 4
+	// FooterBlockMerkleTree contains the Merkle tree over the entries.
+	FooterBlockMerkleTree uint32 = 5
+	// FooterBlockSignature contains the signature of the contents.
+	FooterBlockSignature uint32 = 6
+)
+
+// MinUserFooterBlockType is the minimum type of a footer block added with
+// Writer.AddFooterBlock.
+const MinUserFooterBlockType uint32 = 1024
+
+// maxFooterBlockSize is the maximum size of a footer block.
+var maxFooterBlockSize uint64 = 64 * 1024 * 1024
+
+// formatFlagFixedKeyWidth indicates the index is a list of fixed-width records.
+const formatFlagFixedKeyWidth uint64 = 1 << 0
+
+// formatFlagValueHeaders indicates each value is preceded by a header.
+const formatFlagValueHeaders uint64 = 1 << 1
+
+// formatFlagEncryptedValues indicates the values are encrypted.
+const formatFlagEncryptedValues uint64 = 1 << 2
+
+// formatFlagExternalValues indicates the values are not stored at their
+// offsets in the file, for example if the values are compressed.
+const formatFlagExternalValues uint64 = 1 << 3
+
+// knownFormatFlags contains all format flags supported by the reader.
+const knownFormatFlags = formatFlagFixedKeyWidth | formatFlagValueHeaders | formatFlagEncryptedValues | formatFlagExternalValues
+
+// footerBlock is the location of a block in the footer extension area.
+type footerBlock struct {
+	// typ is the block type
+	typ uint32
+	// pos is the position of the block data in the file
+	pos uint64
+	// size is the size of the block data
+	size uint64
+}
+
+// footerBlockData is a footer block to be written.
+type footerBlockData struct {
+	// typ is the block type
+	typ uint32
+	// data is the block data
+	data []byte
+}
+
+// isFooterMagic checks if the buffer contains the footer magic.
+func isFooterMagic(buf []byte) bool {
+	return bytes.Equal(buf, footerMagic)
+}
+
+// readFooter reads the footer extension area ending at footerEnd.
+//
+// Returns the position of the start of the footer area.
+func (r *Reader) readFooter(footerEnd uint64) (uint64, error) {
+	if footerEnd < footerTailSize {
+		return 0, errors.Errorf("invalid footer end position: %v", footerEnd)
+	}
+
+	// read the end of the footer, usually including the directory.
+	chunkSize := uint64(footerReadSize)
+	if chunkSize > footerEnd {
+		chunkSize = footerEnd
+	}
+	chunkPos := footerEnd - chunkSize
+	chunk := make([]byte, chunkSize)
+	if _, err := r.rd.ReadAt(chunk, int64(chunkPos)); err != nil {
+		return 0, err
+	}
+	if !isFooterMagic(chunk[len(chunk)-8:]) {
+		return 0, errors.Errorf("invalid footer magic at %v", footerEnd-8)
+	}
+
+	dirLen := binary.LittleEndian.Uint64(chunk[len(chunk)-16:])
+	if dirLen > maxFooterDirSize || dirLen > footerEnd-footerTailSize {
+		return 0, errors.Errorf("invalid footer directory size: %v", dirLen)
+	}
+	dirPos := footerEnd - footerTailSize - dirLen
+	var dir []byte
+	if dirPos >= chunkPos {
+		dir = chunk[dirPos-chunkPos : uint64(len(chunk))-footerTailSize]
+	} else {
+		dir = make([]byte, dirLen)
+		if _, err := r.rd.ReadAt(dir, int64(dirPos)); err != nil {
+			return 0, err
+		}
+	}
+
+	// parse the directory
+	var blocks []footerBlock
+	var blocksSize uint64
+	for len(dir) != 0 {
+		typ, n := protobuf_go_lite.ConsumeVarint(dir)
+		if n < 0 || typ > uint64(^uint32(0)) {
+			return 0, errors.New("invalid footer directory block type")
+		}
+		dir = dir[n:]
+		size, n := protobuf_go_lite.ConsumeVarint(dir)
+		if n < 0 {
+			return 0, errors.New("invalid footer directory block size")
+		}
+		dir = dir[n:]
+		if size > dirPos || blocksSize+size > dirPos {
+			return 0, errors.Errorf("invalid footer block size: %v", size)
+		}
+		blocks = append(blocks, footerBlock{typ: uint32(typ), size: size})
+		blocksSize += size
+	}
+
+	footerStart := dirPos - blocksSize
+	pos := footerStart
+	for i := range blocks {
+		blocks[i].pos = pos
+		pos += blocks[i].size
+	}
+	r.footerBlocks = blocks
+
+	// parse the format block
+	for _, block := range blocks {
+		if block.typ != FooterBlockFormat {
+			continue
+		}
+		var data []byte
+		if block.pos >= chunkPos {
+			data = chunk[block.pos-chunkPos : block.pos-chunkPos+block.size]
+		} else {
+			var err error
+			data, err = r.readFooterBlockData(block)
+			if err != nil {
+				return 0, err
+			}
+		}
+		if err := r.parseFormatBlock(data); err != nil {
+			return 0, err
+		}
+		break
+	}
+
+	return footerStart, nil
+}
+
+// FooterBlock reads the data of the first footer block with the given type.
+//
+// Returns nil, false, nil if the file has no block with the type.
+func (r *Reader) FooterBlock(typ uint32) ([]byte, bool, error) {
+	block, found := r.findFooterBlock(typ)
+	if !found {
+		return nil, false, nil
+	}
+	data, err := r.readFooterBlockData(block)
+	if err != nil {
+		return nil, true, err
+	}
+	return data, true, nil
+}
+
+// findFooterBlock finds the first footer block with the given type.
+func (r *Reader) findFooterBlock(typ uint32) (footerBlock, bool) {
+	for _, block := range r.footerBlocks {
+		if block.typ == typ {
+			return block, true
+		}
+	}
+	return footerBlock{}, false
+}
+
+// readFooterBlockData reads the data for the footer block.
+func (r *Reader) readFooterBlockData(block footerBlock) ([]byte, error) {
+	if block.size > maxFooterBlockSize {
+		return nil, errors.Errorf("footer block too large: %v > %v", block.size, maxFooterBlockSize)
+	}
+	data := make([]byte, block.size)
+	if _, err := r.rd.ReadAt(data, int64(block.pos)); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// parseFormatBlock parses the format flags block.
+//
+// The block contains varints: flags, then if formatFlagFixedKeyWidth is set:
+// the key width, offset width, and size width.
+func (r *Reader) parseFormatBlock(data []byte) error {
+	flags, n := protobuf_go_lite.ConsumeVarint(data)
+	if n < 0 {
+		return errors.New("invalid format block")
+	}
+	if unknown := flags &^ knownFormatFlags; unknown != 0 {
+		return errors.Errorf("unsupported format flags: %x", unknown)
+	}
+	data = data[n:]
+	if flags&formatFlagFixedKeyWidth != 0 {
+		var widths [3]uint64
+		for i := range widths {
+			width, n := protobuf_go_lite.ConsumeVarint(data)
+			if n < 0 {
+				return errors.New("invalid fixed-width layout in format block")
+			}
+			data = data[n:]
+			widths[i] = width
+		}
+		keyWidth, offsetWidth, sizeWidth := widths[0], widths[1], widths[2]
+		if keyWidth == 0 || keyWidth > r.indexEntrySizeLimit() {
+			return errors.Errorf("invalid fixed key width in format block: %v", keyWidth)
+		}
+		if offsetWidth == 0 || offsetWidth > 8 || sizeWidth == 0 || sizeWidth > 8 {
+			return errors.Errorf("invalid fixed offset or size width in format block: %v %v", offsetWidth, sizeWidth)
+		}
+		r.fixedKeyWidth, r.fixedOffsetWidth, r.fixedSizeWidth = keyWidth, offsetWidth, sizeWidth
+	}
+	r.formatFlags = flags
+	return nil
+}
+
+// buildFormatBlock builds the format flags block.
+//
+// fields are appended after the flags as varints.
+func buildFormatBlock(flags uint64, fields ...uint64) *footerBlockData {
+	data := protobuf_go_lite.AppendVarint(nil, flags)
+	for _, field := range fields {
+		data = protobuf_go_lite.AppendVarint(data, field)
+	}
+	return &footerBlockData{typ: FooterBlockFormat, data: data}
+}
+
+// writeFooter writes the footer blocks, directory, and tail.
+//
+// Returns the number of bytes written.
+func writeFooter(writer io.Writer, blocks []*footerBlockData) (uint64, error) {
+	var nw uint64
+	var dir []byte
+	for _, block := range blocks {
+		n, err := writeFull(writer, block.data)
+		nw += uint64(n)
+		if err != nil {
+			return nw, err
+		}
+		dir = protobuf_go_lite.AppendVarint(dir, uint64(block.typ))
+		dir = protobuf_go_lite.AppendVarint(dir, uint64(len(block.data)))
+	}
+	if uint64(len(dir)) > maxFooterDirSize {
+		return nw, errors.Errorf("footer directory too large: %v > %v", len(dir), maxFooterDirSize)
+	}
+	dir = binary.LittleEndian.AppendUint64(dir, uint64(len(dir)))
+	dir = append(dir, footerMagic...)
+	n, err := writeFull(writer, dir)
+	nw += uint64(n)
+	return nw, err
+}
+
+// writeFull writes all of buf to the writer.
+func writeFull(writer io.Writer, buf []byte) (int, error) {
+	var nw int
+	for nw < len(buf) {
+		n, err := writer.Write(buf[nw:])
+		nw += n
+		if err != nil {
+			return nw, err
+		}
+	}
+	return nw, nil
+}
@@ -0,0 +1,135 @@
+package kvfile
+
+import (
+	"bytes"
+	"testing"
+)
+
+// writeFooterTestFile writes a file with the footer blocks.
+func writeFooterTestFile(t *testing.T, opts WriterOptions, blocks map[uint32][]byte) []byte {
+	var buf bytes.Buffer
+	wr, err := NewWriterWithOptions(&buf, opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, key := range []string{"test-2", "test-1"} {
+		if err := wr.WriteValue([]byte(key), bytes.NewReader([]byte("val"))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	for typ, data := range blocks {
+		if err := wr.AddFooterBlock(typ, data); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	return buf.Bytes()
+}
+
+func TestFooterBlocks(t *testing.T) {
+	blocks := map[uint32][]byte{
+		MinUserFooterBlockType:     []byte("first block"),
+		MinUserFooterBlockType + 1: bytes.Repeat([]byte("second block"), 100),
+		MinUserFooterBlockType + 2: {},
+	}
+	for _, opts := range []WriterOptions{
+		{},
+		{WriteBounds: true, HashIndex: true, ValueHeaders: true},
+		{FixedKeyWidth: 6},
+	} {
+		data := writeFooterTestFile(t, opts, blocks)
+		rdr, err := BuildReader(bytes.NewReader(data), uint64(len(data)))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		for typ, expected := range blocks {
+			blockData, found, err := rdr.FooterBlock(typ)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if !found || !bytes.Equal(blockData, expected) {
+				t.Fatalf("unexpected footer block %v: %v %d", typ, found, len(blockData))
+			}
+		}
+		_, found, err := rdr.FooterBlock(MinUserFooterBlockType + 3)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if found {
+			t.Fatal("expected footer block to not exist")
+		}
+
+		// the blocks do not affect reading the entries
+		val, found, err := rdr.Get([]byte("test-1"))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !found || string(val) != "val" {
+			t.Fatalf("unexpected value: %v %s", found, string(val))
+		}
+	}
+}
+
+func TestFooterBlockUnknown(t *testing.T) {
+	// an unknown reserved block type is skipped by the reader
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("valval")
+	index := []*IndexEntry{
+		{Key: []byte("test-2"), Offset: 3, Size: 3},
+		{Key: []byte("test-1"), Offset: 0, Size: 3},
+	}
+	footer := []*footerBlockData{{typ: MinUserFooterBlockType - 1, data: []byte("unknown")}}
+	if _, err := writeIndex(&buf, index, uint64(buf.Len()), nil, footer); err != nil {
+		t.Fatal(err.Error())
+	}
+	data := buf.Bytes()
+	rdr, err := BuildReader(bytes.NewReader(data), uint64(len(data)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	val, found, err := rdr.Get([]byte("test-2"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !found || string(val) != "val" {
+		t.Fatalf("unexpected value: %v %s", found, string(val))
+	}
+}
+
+func TestFooterBlockLimits(t *testing.T) {
+	wr := NewWriter(&bytes.Buffer{})
+	if err := wr.AddFooterBlock(FooterBlockBounds, nil); err == nil {
+		t.Fatal("expected error adding reserved footer block type")
+	}
+	if err := wr.AddFooterBlock(MinUserFooterBlockType, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.AddFooterBlock(MinUserFooterBlockType, nil); err == nil {
+		t.Fatal("expected error adding duplicate footer block type")
+	}
+
+	prevMaxSize := maxFooterBlockSize
+	defer func() {
+		maxFooterBlockSize = prevMaxSize
+	}()
+	maxFooterBlockSize = 8
+	if err := wr.AddFooterBlock(MinUserFooterBlockType+1, make([]byte, 9)); err == nil {
+		t.Fatal("expected error adding footer block exceeding the size limit")
+	}
+
+	// the reader rejects blocks exceeding the size limit
+	maxFooterBlockSize = prevMaxSize
+	data := writeFooterTestFile(t, WriterOptions{}, map[uint32][]byte{
+		MinUserFooterBlockType: make([]byte, 9),
+	})
+	maxFooterBlockSize = 8
+	rdr, err := BuildReader(bytes.NewReader(data), uint64(len(data)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, _, err := rdr.FooterBlock(MinUserFooterBlockType); err == nil {
+		t.Fatal("expected error reading footer block exceeding the size limit")
+	}
+}
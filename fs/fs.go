@@ -0,0 +1,415 @@
+package kvfile_fs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+)
+
+// Modes of the files and directories in the FS.
+const (
+	fileMode = 0o444
+	dirMode  = fs.ModeDir | 0o555
+)
+
+// errStopScan stops a scan early.
+var errStopScan = errors.New("stop scan")
+
+// FS is a read-only fs.FS backed by a kvfile.
+//
+// Each key is a slash-separated path to a file with the value as the contents,
+// for example "a/b/c.txt". Directories are derived from the key paths: "a" and
+// "a/b" are directories in the example. Keys which are not valid paths (see
+// fs.ValidPath) cannot be opened and are not listed. If a key is both a file
+// and the parent of other keys, it is a file.
+//
+// FS implements fs.ReadDirFS, fs.StatFS, and fs.SubFS. fs.WalkDir and fs.Glob
+// list directories with prefix searches of the index.
+type FS struct {
+	rd *kvfile.Reader
+	// prefix is prepended to the paths to get the keys, "" or ending with "/"
+	prefix string
+	// modTime is the modification time of the files
+	modTime time.Time
+}
+
+// NewFS builds a read-only FS with a kvfile reader.
+//
+// modTime is the modification time of the files and directories, can be zero.
+func NewFS(rd *kvfile.Reader, modTime time.Time) *FS {
+	return &FS{rd: rd, modTime: modTime}
+}
+
+// Open opens the file or directory with the name.
+func (f *FS) Open(name string) (fs.File, error) {
+	info, err := f.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &dirFile{info: info, it: f.newDirIterator(name)}, nil
+	}
+	valueRdr, err := f.rd.GetValueReaderWithEntry(info.entry, info.entryIdx)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &file{info: info, SectionReader: valueRdr}, nil
+}
+
+// Stat returns the info for the file or directory with the name.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	return f.stat("stat", name)
+}
+
+// ReadDir reads the named directory and returns the entries sorted by name.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	info, err := f.stat("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	var entries []fs.DirEntry
+	it := f.newDirIterator(name)
+	for {
+		entry, err := it.next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return entries, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// Sub returns a FS for the subtree rooted at the directory.
+func (f *FS) Sub(dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	if dir == "." {
+		return f, nil
+	}
+	return &FS{rd: f.rd, prefix: f.prefix + dir + "/", modTime: f.modTime}, nil
+}
+
+// stat looks up the file or directory with the name.
+func (f *FS) stat(op, name string) (*fileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return f.dirInfo("."), nil
+	}
+	key := f.prefix + name
+	_, valueLen, entry, entryIdx, err := f.rd.GetValuePosition([]byte(key))
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if valueLen >= 0 {
+		return &fileInfo{
+			name:     path.Base(name),
+			size:     valueLen,
+			mode:     fileMode,
+			modTime:  f.modTime,
+			entry:    entry,
+			entryIdx: entryIdx,
+		}, nil
+	}
+	isDir, err := f.hasChildren(key)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if !isDir {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return f.dirInfo(name), nil
+}
+
+// dirInfo returns the info for the directory with the name.
+func (f *FS) dirInfo(name string) *fileInfo {
+	return &fileInfo{name: path.Base(name), mode: dirMode, modTime: f.modTime}
+}
+
+// hasChildren checks if there are visible keys under the directory key.
+func (f *FS) hasChildren(dirKey string) (bool, error) {
+	var found bool
+	err := f.rd.ScanPrefixEntries([]byte(dirKey+"/"), func(indexEntry *kvfile.IndexEntry, indexEntryIdx int) error {
+		found = true
+		return errStopScan
+	})
+	if err == errStopScan {
+		err = nil
+	}
+	return found, err
+}
+
+// newDirIterator builds an iterator over the entries of the directory.
+func (f *FS) newDirIterator(name string) *dirIterator {
+	prefix := f.prefix
+	if name != "." {
+		prefix += name + "/"
+	}
+	return &dirIterator{fs: f, prefix: prefix}
+}
+
+// dirIterator lists the entries of a directory in name order.
+//
+// The children are found in key order with prefix searches: each file is one
+// index entry and each subdirectory is skipped with a binary search, so the
+// keys under subdirectories are not read.
+//
+// The key order differs from the name order if a subdirectory name is a prefix
+// of another name followed by a byte sorting before '/', for example the
+// directory "a" (keys "a/...") and file "a.txt". Before emitting a name, the
+// subdirectories which sort before it by name are emitted first.
+type dirIterator struct {
+	fs     *FS
+	prefix string
+	// idx is the index of the next index entry to read
+	idx uint64
+	// started indicates idx was positioned at the start of the prefix
+	started bool
+	// pending are the entries to return before reading more
+	pending []fs.DirEntry
+	// early contains the subdirectories emitted before their keys were reached
+	early map[string]struct{}
+	done  bool
+}
+
+// next returns the next entry, or io.EOF at the end.
+func (it *dirIterator) next() (fs.DirEntry, error) {
+	for len(it.pending) == 0 {
+		if it.done {
+			return nil, io.EOF
+		}
+		if err := it.readNext(); err != nil {
+			return nil, err
+		}
+	}
+	entry := it.pending[0]
+	it.pending = it.pending[1:]
+	return entry, nil
+}
+
+// readNext reads the next child from the index to pending.
+func (it *dirIterator) readNext() error {
+	rd := it.fs.rd
+	if !it.started {
+		_, idx, err := rd.SearchIndexEntryWithPrefix([]byte(it.prefix), false)
+		if err != nil {
+			return err
+		}
+		it.idx, it.started = uint64(idx), true
+	}
+	if it.idx >= rd.Size() {
+		it.done = true
+		return nil
+	}
+	indexEntry, err := rd.ReadIndexEntry(it.idx)
+	if err != nil {
+		return err
+	}
+	key := indexEntry.GetKey()
+	if !bytes.HasPrefix(key, []byte(it.prefix)) {
+		it.done = true
+		return nil
+	}
+
+	rest := key[len(it.prefix):]
+	slash := bytes.IndexByte(rest, '/')
+	if slash < 0 {
+		// a file in the directory
+		it.idx++
+		name := string(rest)
+		if rd.IsEntryHidden(indexEntry) || !validName(name) {
+			return nil
+		}
+		if err := it.emitEarlyDirs(name); err != nil {
+			return err
+		}
+		it.pending = append(it.pending, &dirEntry{info: &fileInfo{
+			name:     name,
+			size:     int64(indexEntry.GetSize()),
+			mode:     fileMode,
+			modTime:  it.fs.modTime,
+			entry:    indexEntry,
+			entryIdx: int(it.idx - 1),
+		}})
+		return nil
+	}
+
+	// a subdirectory: skip the keys under it
+	name := string(rest[:slash])
+	_, nextIdx, err := rd.SearchIndexEntryWithKey([]byte(it.prefix + name + "0"))
+	if err != nil {
+		return err
+	}
+	it.idx = uint64(nextIdx)
+	if _, ok := it.early[name]; ok || !validName(name) {
+		return nil
+	}
+	if err := it.emitEarlyDirs(name); err != nil {
+		return err
+	}
+	ok, err := it.isDir(name)
+	if err != nil || !ok {
+		return err
+	}
+	it.pending = append(it.pending, &dirEntry{info: it.fs.dirInfo(name)})
+	return nil
+}
+
+// emitEarlyDirs adds the subdirectories which sort before the name by name but
+// after it by key to pending.
+func (it *dirIterator) emitEarlyDirs(name string) error {
+	for i := 1; i < len(name); i++ {
+		if name[i] >= '/' {
+			continue
+		}
+		dirName := name[:i]
+		if _, ok := it.early[dirName]; ok || !validName(dirName) {
+			continue
+		}
+		ok, err := it.isDir(dirName)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if it.early == nil {
+			it.early = make(map[string]struct{})
+		}
+		it.early[dirName] = struct{}{}
+		it.pending = append(it.pending, &dirEntry{info: it.fs.dirInfo(dirName)})
+	}
+	return nil
+}
+
+// isDir checks if the child is a directory: it has visible children and is
+// not a file.
+func (it *dirIterator) isDir(name string) (bool, error) {
+	key := it.prefix + name
+	isFile, err := it.fs.rd.Exists([]byte(key))
+	if err != nil || isFile {
+		return false, err
+	}
+	return it.fs.hasChildren(key)
+}
+
+// validName checks if the name is a valid path element.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".."
+}
+
+// fileInfo implements fs.FileInfo.
+type fileInfo struct {
+	name     string
+	size     int64
+	mode     fs.FileMode
+	modTime  time.Time
+	entry    *kvfile.IndexEntry
+	entryIdx int
+}
+
+func (i *fileInfo) Name() string       { return i.name }
+func (i *fileInfo) Size() int64        { return i.size }
+func (i *fileInfo) Mode() fs.FileMode  { return i.mode }
+func (i *fileInfo) ModTime() time.Time { return i.modTime }
+func (i *fileInfo) IsDir() bool        { return i.mode.IsDir() }
+
+// Sys returns the index entry for files and nil for directories.
+func (i *fileInfo) Sys() any {
+	if i.entry == nil {
+		return nil
+	}
+	return i.entry
+}
+
+// dirEntry implements fs.DirEntry.
+type dirEntry struct {
+	info *fileInfo
+}
+
+func (e *dirEntry) Name() string               { return e.info.name }
+func (e *dirEntry) IsDir() bool                { return e.info.IsDir() }
+func (e *dirEntry) Type() fs.FileMode          { return e.info.mode.Type() }
+func (e *dirEntry) Info() (fs.FileInfo, error) { return e.info, nil }
+func (e *dirEntry) String() string             { return fs.FormatDirEntry(e) }
+
+// file is an open file with the value reader.
+type file struct {
+	*io.SectionReader
+	info *fileInfo
+}
+
+// Stat returns the file info.
+func (f *file) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// Close does nothing.
+func (f *file) Close() error {
+	return nil
+}
+
+// dirFile is an open directory.
+type dirFile struct {
+	info *fileInfo
+	it   *dirIterator
+}
+
+// Stat returns the directory info.
+func (d *dirFile) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+// Read returns an error as directories cannot be read.
+func (d *dirFile) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+// Close does nothing.
+func (d *dirFile) Close() error {
+	return nil
+}
+
+// ReadDir returns the next n entries of the directory, or all remaining
+// entries if n <= 0.
+//
+// The entries are read from the index as needed.
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	for n <= 0 || len(entries) < n {
+		entry, err := d.it.next()
+		if err == io.EOF {
+			if n > 0 && len(entries) == 0 {
+				return nil, io.EOF
+			}
+			break
+		}
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// _ is a type assertion
+var (
+	_ fs.ReadDirFS   = ((*FS)(nil))
+	_ fs.StatFS      = ((*FS)(nil))
+	_ fs.SubFS       = ((*FS)(nil))
+	_ fs.ReadDirFile = ((*dirFile)(nil))
+	_ io.ReaderAt    = ((*file)(nil))
+	_ io.Seeker      = ((*file)(nil))
+)
@@ -0,0 +1,209 @@
+package kvfile_fs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"strconv"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+)
+
+// buildTestFS writes a kvfile with the keys and builds a FS.
+//
+// The value of each key is the key.
+func buildTestFS(t testing.TB, keys []string) *FS {
+	var buf bytes.Buffer
+	wr := kvfile.NewWriter(&buf)
+	for _, key := range keys {
+		if err := wr.WriteValue([]byte(key), bytes.NewReader([]byte(key))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	rd, err := kvfile.BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return NewFS(rd, time.Unix(1700000000, 0))
+}
+
+// testKeys is a nested fixture with names sorting differently by key and name.
+var testKeys = []string{
+	"README.md",
+	"a/b/c.txt",
+	"a/b/d/e.txt",
+	"a/b.txt",
+	"a-b/x",
+	"a.txt",
+	"docs/guide.md",
+	"docs/guide/intro.md",
+	"docs/api/v1.md",
+	// invalid path elements are not listed
+	"/abs",
+	"bad//key",
+	"dot/./file",
+	"dir/",
+}
+
+func TestWalkDir(t *testing.T) {
+	fsys := buildTestFS(t, testKeys)
+	var paths []string
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			p += "/"
+		}
+		paths = append(paths, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []string{
+		"./",
+		"README.md",
+		"a/",
+		"a/b/",
+		"a/b/c.txt",
+		"a/b/d/",
+		"a/b/d/e.txt",
+		"a/b.txt",
+		"a-b/",
+		"a-b/x",
+		"a.txt",
+		"bad/",
+		"dir/",
+		"docs/",
+		"docs/api/",
+		"docs/api/v1.md",
+		"docs/guide/",
+		"docs/guide/intro.md",
+		"docs/guide.md",
+		"dot/",
+	}
+	if !slices.Equal(paths, expected) {
+		t.Fatalf("unexpected paths:\n%q\nexpected:\n%q", paths, expected)
+	}
+}
+
+func TestFSConformance(t *testing.T) {
+	keys := []string{
+		"README.md",
+		"a/b/c.txt",
+		"a/b/d/e.txt",
+		"a/b.txt",
+		"a-b/x",
+		"a.txt",
+		"docs/guide.md",
+		"docs/guide/intro.md",
+	}
+	fsys := buildTestFS(t, keys)
+	if err := fstest.TestFS(fsys, keys...); err != nil {
+		t.Fatal(err.Error())
+	}
+	sub, err := fs.Sub(fsys, "a")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := fstest.TestFS(sub, "b/c.txt", "b/d/e.txt", "b.txt"); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestFS(t *testing.T) {
+	fsys := buildTestFS(t, append(slices.Clone(testKeys), "conflict", "conflict/child"))
+
+	data, err := fs.ReadFile(fsys, "a/b/c.txt")
+	if err != nil || string(data) != "a/b/c.txt" {
+		t.Fatalf("unexpected file contents: %q %v", data, err)
+	}
+	f, err := fsys.Open("docs/guide.md")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	seeker := f.(io.ReadSeeker)
+	if _, err := seeker.Seek(5, io.SeekStart); err != nil {
+		t.Fatal(err.Error())
+	}
+	if rest, err := io.ReadAll(seeker); err != nil || string(rest) != "guide.md" {
+		t.Fatalf("unexpected contents after seek: %q %v", rest, err)
+	}
+
+	// a key which is also a parent is a file
+	info, err := fs.Stat(fsys, "conflict")
+	if err != nil || info.IsDir() || info.Size() != int64(len("conflict")) {
+		t.Fatalf("expected conflict to be a file: %v %v", info, err)
+	}
+	if _, err := fsys.ReadDir("conflict"); err == nil {
+		t.Fatal("expected an error listing a file")
+	}
+	if data, err := fs.ReadFile(fsys, "conflict/child"); err != nil || string(data) != "conflict/child" {
+		t.Fatalf("expected the child to be readable: %q %v", data, err)
+	}
+
+	for _, name := range []string{"missing", "a/missing", "a/b/c.txt/x"} {
+		if _, err := fs.Stat(fsys, name); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("expected not exist for %s: %v", name, err)
+		}
+	}
+	if _, err := fsys.Open("/abs"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("expected invalid path error: %v", err)
+	}
+
+	matches, err := fs.Glob(fsys, "a/*.txt")
+	if err != nil || !slices.Equal(matches, []string{"a/b.txt"}) {
+		t.Fatalf("unexpected glob matches: %v %v", matches, err)
+	}
+	matches, err = fs.Glob(fsys, "docs/*/*.md")
+	if err != nil || !slices.Equal(matches, []string{"docs/api/v1.md", "docs/guide/intro.md"}) {
+		t.Fatalf("unexpected glob matches: %v %v", matches, err)
+	}
+}
+
+func TestReadDirPaged(t *testing.T) {
+	const count = 1000
+	keys := make([]string, 0, count*2)
+	for i := range count {
+		name := "big/" + strconv.Itoa(100000+i)
+		// each file has a sibling directory with many keys
+		keys = append(keys, name+".bin", name+"/nested/value")
+	}
+	fsys := buildTestFS(t, keys)
+	f, err := fsys.Open("big")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	dir := f.(fs.ReadDirFile)
+	var names []string
+	for {
+		entries, err := dir.ReadDir(64)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(entries) > 64 {
+			t.Fatalf("expected at most 64 entries: %d", len(entries))
+		}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) != count*2 || !slices.IsSorted(names) {
+		t.Fatalf("expected %d sorted names: %d %v", count*2, len(names), names[:4])
+	}
+	if names[0] != "100000" || names[1] != "100000.bin" {
+		t.Fatalf("unexpected first names: %v", names[:2])
+	}
+}
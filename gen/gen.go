@@ -0,0 +1,226 @@
+package kvfile_gen
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"go/format"
+	"go/token"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+)
+
+// DefaultName is the default name of the accessor function.
+const DefaultName = "Reader"
+
+// dataLineSize is the number of bytes of data per line of the literal.
+const dataLineSize = 32
+
+// GetterType is the return type of a generated getter function.
+type GetterType string
+
+const (
+	// GetterBytes returns the value as a []byte.
+	GetterBytes GetterType = "bytes"
+	// GetterString returns the value as a string.
+	GetterString GetterType = "string"
+)
+
+// Getter is a typed getter function for a well-known key.
+type Getter struct {
+	// Name is the name of the function.
+	Name string
+	// Key is the key to look up. Must exist in the kvfile.
+	Key []byte
+	// Type is the return type. If empty, uses GetterBytes.
+	Type GetterType
+}
+
+// Options are options for Generate.
+type Options struct {
+	// Package is the package name of the generated file.
+	Package string
+	// Name is the name of the function returning the Reader.
+	// If empty, uses DefaultName.
+	Name string
+	// Getters are the getter functions to generate.
+	Getters []Getter
+}
+
+// Generate writes a Go source file containing the kvfile data and an accessor
+// function returning a Reader built with kvfile.NewReaderFromBytes.
+//
+// The file stores the SHA-256 checksum of the data. The accessor returns an
+// error if the data does not match the checksum, so edits to the generated
+// file are detected. The generated file only imports the kvfile package and
+// the standard library.
+func Generate(w io.Writer, data []byte, opts Options) error {
+	src, err := GenerateSource(data, opts)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// GenerateSource returns the formatted Go source file.
+//
+// See Generate.
+func GenerateSource(data []byte, opts Options) ([]byte, error) {
+	tmplData, err := buildTemplateData(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := sourceTemplate.Execute(&buf, tmplData); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// templateData is the data passed to the source template.
+type templateData struct {
+	Package  string
+	Name     string
+	Data     string
+	Checksum string
+	Lines    []string
+	Getters  []templateGetter
+}
+
+// templateGetter is a getter passed to the source template.
+type templateGetter struct {
+	Name     string
+	Key      string
+	NotFound string
+	String   bool
+}
+
+// buildTemplateData validates the options and builds the template data.
+func buildTemplateData(data []byte, opts Options) (*templateData, error) {
+	if !token.IsIdentifier(opts.Package) {
+		return nil, errors.Errorf("invalid package name: %q", opts.Package)
+	}
+	name := opts.Name
+	if name == "" {
+		name = DefaultName
+	}
+	if !token.IsIdentifier(name) {
+		return nil, errors.Errorf("invalid name: %q", name)
+	}
+	rd, err := kvfile.NewReaderFromBytes(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "open kvfile")
+	}
+
+	lowerName := lowerFirst(name)
+	t := &templateData{
+		Package: opts.Package,
+		Name:    name,
+		Data:    lowerName + "Data",
+	}
+	sum := sha256.Sum256(data)
+	t.Checksum = hex.EncodeToString(sum[:])
+	for len(data) != 0 {
+		line := data[:min(len(data), dataLineSize)]
+		data = data[len(line):]
+		t.Lines = append(t.Lines, strconv.Quote(string(line)))
+	}
+
+	names := map[string]struct{}{name: {}, t.Data: {}, lowerName + "SHA256": {}, lowerName + "Once": {}}
+	for _, getter := range opts.Getters {
+		if !token.IsIdentifier(getter.Name) {
+			return nil, errors.Errorf("invalid getter name: %q", getter.Name)
+		}
+		if _, ok := names[getter.Name]; ok {
+			return nil, errors.Errorf("duplicate name: %q", getter.Name)
+		}
+		names[getter.Name] = struct{}{}
+		switch getter.Type {
+		case "", GetterBytes, GetterString:
+		default:
+			return nil, errors.Errorf("invalid getter type for %s: %q", getter.Name, getter.Type)
+		}
+		found, err := rd.Exists(getter.Key)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, errors.Errorf("key for getter %s not found: %q", getter.Name, getter.Key)
+		}
+		t.Getters = append(t.Getters, templateGetter{
+			Name:     getter.Name,
+			Key:      strconv.Quote(string(getter.Key)),
+			NotFound: strconv.Quote("key not found: " + strconv.Quote(string(getter.Key))),
+			String:   getter.Type == GetterString,
+		})
+	}
+	return t, nil
+}
+
+// lowerFirst lowercases the first letter of the identifier.
+func lowerFirst(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[size:]
+}
+
+// sourceTemplate is the template for the generated file.
+var sourceTemplate = template.Must(template.New("source").Funcs(template.FuncMap{
+	"lowerFirst": lowerFirst,
+	"join":       strings.Join,
+}).Parse(`// Code generated by kvfilegen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+)
+
+// {{lowerFirst .Name}}SHA256 is the SHA-256 checksum of {{.Data}}.
+const {{lowerFirst .Name}}SHA256 = "{{.Checksum}}"
+
+// {{.Data}} contains the kvfile.
+var {{.Data}} = []byte({{if .Lines}}"" +
+	{{join .Lines " +\n\t"}}{{else}}""{{end}})
+
+// {{lowerFirst .Name}}Once builds the Reader once.
+var {{lowerFirst .Name}}Once = sync.OnceValues(func() (*kvfile.Reader, error) {
+	sum := sha256.Sum256({{.Data}})
+	if hex.EncodeToString(sum[:]) != {{lowerFirst .Name}}SHA256 {
+		return nil, errors.New("kvfile data does not match the checksum: the generated file was modified")
+	}
+	return kvfile.NewReaderFromBytes({{.Data}})
+})
+
+// {{.Name}} returns the Reader for the kvfile.
+//
+// Returns an error if the data does not match the checksum.
+func {{.Name}}() (*kvfile.Reader, error) {
+	return {{lowerFirst .Name}}Once()
+}
+{{range .Getters}}
+// {{.Name}} returns the value of the {{.Key}} key.
+func {{.Name}}() ({{if .String}}string{{else}}[]byte{{end}}, error) {
+	rd, err := {{$.Name}}()
+	if err != nil {
+		return {{if .String}}""{{else}}nil{{end}}, err
+	}
+	val, found, err := rd.Get([]byte({{.Key}}))
+	if err == nil && !found {
+		err = errors.New({{.NotFound}})
+	}
+	return {{if .String}}string(val){{else}}val{{end}}, err
+}
+{{end}}`))
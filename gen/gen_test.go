@@ -0,0 +1,136 @@
+package kvfile_gen
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+)
+
+// testMain is the program using the generated package.
+const testMain = `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	rd, err := Table()
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	hello, err := Hello()
+	if err != nil {
+		panic(err)
+	}
+	bin, err := Bin()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%d %s %x\n", rd.Size(), hello, bin)
+}
+`
+
+// buildTestFile writes the fixture kvfile.
+func buildTestFile(t *testing.T) []byte {
+	var buf bytes.Buffer
+	wr := kvfile.NewWriter(&buf)
+	for key, value := range map[string][]byte{
+		"hello":  []byte("world"),
+		"bin":    {0x00, 0xff, '"', '\n'},
+		"other":  []byte("value"),
+		"\xffbk": []byte("binary key"),
+	} {
+		if err := wr.WriteValue([]byte(key), bytes.NewReader(value)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	return buf.Bytes()
+}
+
+// runGenerated runs the generated source with testMain in a temporary package
+// in the module and returns the output.
+func runGenerated(t *testing.T, src []byte) (string, error) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	dir, err := os.MkdirTemp(".", "gentest-")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	if err := os.WriteFile(filepath.Join(dir, "table.go"), src, 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(testMain), 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	out, err := exec.Command(goBin, "run", "./"+dir).CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+func TestGenerate(t *testing.T) {
+	data := buildTestFile(t)
+	opts := Options{
+		Package: "main",
+		Name:    "Table",
+		Getters: []Getter{
+			{Name: "Hello", Key: []byte("hello"), Type: GetterString},
+			{Name: "Bin", Key: []byte("bin")},
+		},
+	}
+	var buf bytes.Buffer
+	if err := Generate(&buf, data, opts); err != nil {
+		t.Fatal(err.Error())
+	}
+	src := buf.Bytes()
+	if !bytes.HasPrefix(src, []byte("// Code generated by kvfilegen. DO NOT EDIT.\n")) {
+		t.Fatalf("expected the generated code header: %s", src[:64])
+	}
+
+	out, err := runGenerated(t, src)
+	if err != nil || out != "4 world 00ff220a" {
+		t.Fatalf("unexpected output: %q %v", out, err)
+	}
+
+	// edited data is detected by the checksum
+	edited := bytes.Replace(src, []byte("world"), []byte("WORLD"), 1)
+	if bytes.Equal(edited, src) {
+		t.Fatal("expected the value in the data literal")
+	}
+	out, err = runGenerated(t, edited)
+	if err == nil || !strings.Contains(out, "does not match the checksum") {
+		t.Fatalf("expected checksum error: %q %v", out, err)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	data := buildTestFile(t)
+	for _, opts := range []Options{
+		{Package: "not a package"},
+		{Package: "tables", Name: "1Reader"},
+		{Package: "tables", Getters: []Getter{{Name: "Missing", Key: []byte("missing")}}},
+		{Package: "tables", Getters: []Getter{{Name: "Hello", Key: []byte("hello"), Type: "int"}}},
+		{Package: "tables", Getters: []Getter{{Name: "Reader", Key: []byte("hello")}}},
+		{Package: "tables", Getters: []Getter{{Name: "readerData", Key: []byte("hello")}}},
+	} {
+		if _, err := GenerateSource(data, opts); err == nil {
+			t.Fatalf("expected an error for %v", opts)
+		}
+	}
+	if _, err := GenerateSource([]byte("not a kvfile"), Options{Package: "tables"}); err == nil {
+		t.Fatal("expected an error for an invalid kvfile")
+	}
+}
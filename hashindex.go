@@ -0,0 +1,149 @@
+package kvfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+// The hash index footer block is an open-addressing hash table with linear
+// probing. The number of slots is a power of two, at least twice the number of
+// entries. Each slot is a little-endian uint64:
+//
+//	[fingerprint (24 bits)][entry index + 1 (40 bits)]
+//
+// An empty slot is zero. The slot index is the low bits of the key hash and the
+// fingerprint is the high 24 bits of the key hash, see hashIndexKey.
+
+// hashIndexIdxBits is the number of bits in a slot for the entry index.
+const hashIndexIdxBits = 40
+
+// hashIndexIdxMask is the mask for the entry index in a slot.
+const hashIndexIdxMask = 1<<hashIndexIdxBits - 1
+
+// hashIndexReadSlots is the number of slots to read at a time when probing.
+const hashIndexReadSlots = 8
+
+// maxHashIndexProbes is the max number of slots to probe before falling back
+// to a binary search of the index.
+const maxHashIndexProbes = 64
+
+// hashIndexKey hashes a key for the hash index.
+//
+// Uses FNV-1a 64 with the murmur3 fmix64 finalizer.
+// Tests override this to force hash collisions.
+var hashIndexKey = func(key []byte) uint64 {
+	h := uint64(14695981039346656037)
+	for _, c := range key {
+		h ^= uint64(c)
+		h *= 1099511628211
+	}
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// hashIndexFingerprint returns the fingerprint stored in the slot for the hash.
+func hashIndexFingerprint(h uint64) uint64 {
+	return h >> hashIndexIdxBits
+}
+
+// buildHashIndexBlock builds the hash index footer block for the sorted index.
+func buildHashIndexBlock(index []*IndexEntry) (*footerBlockData, error) {
+	if uint64(len(index)) >= hashIndexIdxMask {
+		return nil, errors.Errorf("too many entries for hash index: %v", len(index))
+	}
+	slotCount := uint64(1) << bits.Len64(uint64(len(index))*2-1)
+	mask := slotCount - 1
+	slots := make([]uint64, slotCount)
+	for i, indexEntry := range index {
+		h := hashIndexKey(indexEntry.GetKey())
+		slot := hashIndexFingerprint(h)<<hashIndexIdxBits | (uint64(i) + 1)
+		for j := h & mask; ; j = (j + 1) & mask {
+			if slots[j] == 0 {
+				slots[j] = slot
+				break
+			}
+		}
+	}
+	data := make([]byte, 0, slotCount*8)
+	for _, slot := range slots {
+		data = binary.LittleEndian.AppendUint64(data, slot)
+	}
+	return &footerBlockData{typ: FooterBlockHashIndex, data: data}, nil
+}
+
+// searchHashIndex looks up the index entry for the key with the hash index.
+//
+// Returns ok=false if the hash index is not present or the lookup exceeded the
+// max number of probes. Otherwise returns the entry or nil if not found.
+func (r *Reader) searchHashIndex(key []byte) (entry *IndexEntry, idx int, ok bool, err error) {
+	block, found := r.findFooterBlock(FooterBlockHashIndex)
+	if !found {
+		return nil, 0, false, nil
+	}
+	slotCount := block.size / 8
+	if slotCount == 0 || slotCount&(slotCount-1) != 0 {
+		return nil, 0, false, errors.Errorf("invalid hash index size: %v", block.size)
+	}
+	mask := slotCount - 1
+
+	h := hashIndexKey(key)
+	fingerprint := hashIndexFingerprint(h)
+	buf := make([]byte, hashIndexReadSlots*8)
+	start := h & mask
+	for probe := uint64(0); probe < maxHashIndexProbes && probe < slotCount; {
+		// read a run of slots without wrapping around the end of the table
+		slotIdx := (start + probe) & mask
+		readSlots := min(hashIndexReadSlots, slotCount-slotIdx, maxHashIndexProbes-probe)
+		readBuf := buf[:readSlots*8]
+		if _, err := r.rd.ReadAt(readBuf, int64(block.pos+slotIdx*8)); err != nil {
+			return nil, 0, false, err
+		}
+		for i := uint64(0); i < readSlots; i++ {
+			slot := binary.LittleEndian.Uint64(readBuf[i*8:])
+			if slot == 0 {
+				// empty slot: the key is not in the table
+				return nil, 0, true, nil
+			}
+			if slot>>hashIndexIdxBits != fingerprint {
+				continue
+			}
+			entryIdx := slot&hashIndexIdxMask - 1
+			if entryIdx >= r.indexEntryCount {
+				return nil, 0, false, errors.Errorf("invalid hash index entry index: %v", entryIdx)
+			}
+			indexEntry, err := r.ReadIndexEntry(entryIdx)
+			if err != nil {
+				return nil, 0, false, err
+			}
+			if bytes.Equal(indexEntry.GetKey(), key) {
+				return indexEntry, int(entryIdx), true, nil
+			}
+		}
+		probe += readSlots
+	}
+
+	// too many probes
+	return nil, 0, false, nil
+}
+
+// lookupIndexEntry looks up the index entry for the key.
+//
+// Checks the bloom filter if built, then uses the hash index if present,
+// otherwise uses a binary search. Returns nil if not found.
+func (r *Reader) lookupIndexEntry(key []byte) (*IndexEntry, int, error) {
+	if !r.bloomMayContain(key) {
+		return nil, 0, nil
+	}
+	entry, idx, ok, err := r.searchHashIndex(key)
+	if err != nil || ok {
+		return entry, idx, err
+	}
+	return r.SearchIndexEntryWithKey(key)
+}
@@ -0,0 +1,127 @@
+package kvfile
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+)
+
+// writeHashIndexTestFile writes n keys with the hash index enabled.
+func writeHashIndexTestFile(t testing.TB, n int, hashIndex bool) []byte {
+	var buf bytes.Buffer
+	wr, err := NewWriterWithOptions(&buf, WriterOptions{HashIndex: hashIndex})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for i := 0; i < n; i++ {
+		key := []byte("key-" + strconv.Itoa(i))
+		if err := wr.WriteValue(key, bytes.NewReader([]byte("val-"+strconv.Itoa(i)))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	return buf.Bytes()
+}
+
+// checkHashIndexTestFile checks all keys and some misses in the file.
+func checkHashIndexTestFile(t *testing.T, data []byte, n int) {
+	rdr, err := BuildReader(bytes.NewReader(data), uint64(len(data)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for i := 0; i < n; i++ {
+		key := []byte("key-" + strconv.Itoa(i))
+		val, found, err := rdr.Get(key)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !found || string(val) != "val-"+strconv.Itoa(i) {
+			t.Fatalf("unexpected value for %s: %v %s", string(key), found, string(val))
+		}
+	}
+	for i := n; i < n*2; i++ {
+		key := []byte("key-" + strconv.Itoa(i))
+		keyExists, err := rdr.Exists(key)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if keyExists {
+			t.Fatalf("expected key to not exist: %s", string(key))
+		}
+	}
+}
+
+func TestHashIndex(t *testing.T) {
+	data := writeHashIndexTestFile(t, 1000, true)
+	checkHashIndexTestFile(t, data, 1000)
+
+	// the hash index is used for lookups
+	counter := &countingReaderAt{rd: bytes.NewReader(data)}
+	rdr, err := BuildReader(counter, uint64(len(data)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	counter.reads.Store(0)
+	if _, found, err := rdr.Get([]byte("key-500")); err != nil || !found {
+		t.Fatalf("get failed: %v %v", found, err)
+	}
+	// slot read + entry read (3) + value read
+	if reads := counter.reads.Load(); reads > 5 {
+		t.Fatalf("expected hash index lookup: %v reads", reads)
+	}
+}
+
+func TestHashIndexCollisions(t *testing.T) {
+	prevHash := hashIndexKey
+	defer func() {
+		hashIndexKey = prevHash
+	}()
+
+	// weak hashes forcing collisions in the slots and fingerprints
+	weakHashes := []func(key []byte) uint64{
+		func(key []byte) uint64 {
+			return uint64(key[len(key)-1])
+		},
+		func(key []byte) uint64 {
+			return 42
+		},
+	}
+	for _, weakHash := range weakHashes {
+		hashIndexKey = weakHash
+		data := writeHashIndexTestFile(t, 200, true)
+		checkHashIndexTestFile(t, data, 200)
+	}
+}
+
+func benchmarkHashIndexGet(b *testing.B, hashIndex bool) {
+	n := 100000
+	data := writeHashIndexTestFile(b, n, hashIndex)
+	counter := &countingReaderAt{rd: bytes.NewReader(data)}
+	rdr, err := BuildReader(counter, uint64(len(data)))
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = []byte("key-" + strconv.Itoa(i))
+	}
+	counter.reads.Store(0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, found, err := rdr.Get(keys[i%n])
+		if err != nil || !found {
+			b.Fatalf("get failed: %v %v", found, err)
+		}
+	}
+	b.ReportMetric(float64(counter.reads.Load())/float64(b.N), "readat/op")
+}
+
+func BenchmarkGetBinarySearch(b *testing.B) {
+	benchmarkHashIndexGet(b, false)
+}
+
+func BenchmarkGetHashIndex(b *testing.B) {
+	benchmarkHashIndexGet(b, true)
+}
@@ -0,0 +1,51 @@
+package kvfile
+
+import (
+	"io"
+	"time"
+)
+
+// ReaderHooks are callbacks for instrumenting a Reader.
+//
+// Any of the callbacks may be nil. The callbacks may be called concurrently
+// and must not retain the key after returning. Durations include the time
+// spent in scan callbacks.
+type ReaderHooks struct {
+	// OnGet is called after Get or ReadTo with the size of the value read, and
+	// for each key of GetMany.
+	OnGet func(key []byte, found bool, bytes int64, dur time.Duration)
+	// OnScan is called after a scan with the number of entries and the size of
+	// the values read. Scans over index entries do not read values.
+	OnScan func(entries int, bytes int64, dur time.Duration)
+	// OnReadAt is called after each read from the underlying ReaderAt.
+	OnReadAt func(bytes int)
+}
+
+// getOnGet returns the OnGet hook or nil.
+func (h *ReaderHooks) getOnGet() func(key []byte, found bool, bytes int64, dur time.Duration) {
+	if h == nil {
+		return nil
+	}
+	return h.OnGet
+}
+
+// getOnScan returns the OnScan hook or nil.
+func (h *ReaderHooks) getOnScan() func(entries int, bytes int64, dur time.Duration) {
+	if h == nil {
+		return nil
+	}
+	return h.OnScan
+}
+
+// hookReaderAt calls the OnReadAt hook after each read.
+type hookReaderAt struct {
+	rd       io.ReaderAt
+	onReadAt func(bytes int)
+}
+
+// ReadAt reads from the underlying ReaderAt.
+func (h *hookReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := h.rd.ReadAt(p, off)
+	h.onReadAt(n)
+	return n, err
+}
@@ -0,0 +1,79 @@
+package kvfile
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestReaderHooks(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	for _, key := range []string{"a/1", "a/2", "b/1"} {
+		if err := wr.WriteValue([]byte(key), bytes.NewReader([]byte("val-"+key))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var gets, hits, getBytes, scans, scanEntries, scanBytes, readAtBytes int64
+	var keys []string
+	hooks := &ReaderHooks{
+		OnGet: func(key []byte, found bool, bytes int64, dur time.Duration) {
+			keys = append(keys, string(key))
+			gets++
+			if found {
+				hits++
+			}
+			getBytes += bytes
+		},
+		OnScan: func(entries int, bytes int64, dur time.Duration) {
+			scans++
+			scanEntries += int64(entries)
+			scanBytes += bytes
+		},
+		OnReadAt: func(bytes int) {
+			readAtBytes += int64(bytes)
+		},
+	}
+	rdr, err := BuildReaderWithOptions(bytes.NewReader(buf.Bytes()), uint64(buf.Len()), ReaderOptions{Hooks: hooks})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// reading the footer is counted
+	if readAtBytes == 0 {
+		t.Fatal("expected the footer read to be counted")
+	}
+
+	if _, found, err := rdr.Get([]byte("a/1")); err != nil || !found {
+		t.Fatalf("expected a/1: %v %v", found, err)
+	}
+	if _, found, err := rdr.ReadTo([]byte("b/1"), io.Discard); err != nil || !found {
+		t.Fatalf("expected b/1: %v %v", found, err)
+	}
+	if _, found, err := rdr.Get([]byte("missing")); err != nil || found {
+		t.Fatalf("expected missing: %v %v", found, err)
+	}
+	if gets != 3 || hits != 2 || getBytes != int64(len("val-a/1")+len("val-b/1")) {
+		t.Fatalf("unexpected get counters: %d %d %d", gets, hits, getBytes)
+	}
+	if len(keys) != 3 || keys[0] != "a/1" || keys[2] != "missing" {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+
+	if err := rdr.ScanPrefix([]byte("a/"), func(key, value []byte) error { return nil }); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := rdr.ScanPrefixKeys(nil, func(key []byte) error { return nil }); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := rdr.ScanRangeEntries([]byte("a/2"), nil, func(indexEntry *IndexEntry, indexEntryIdx int) error { return nil }); err != nil {
+		t.Fatal(err.Error())
+	}
+	if scans != 3 || scanEntries != 2+3+2 || scanBytes != int64(len("val-a/1")+len("val-a/2")) {
+		t.Fatalf("unexpected scan counters: %d %d %d", scans, scanEntries, scanBytes)
+	}
+}
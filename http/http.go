@@ -0,0 +1,157 @@
+package kvfile_http
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+)
+
+// KeyFunc maps a request to a key.
+//
+// Returns false if the request does not map to a key.
+type KeyFunc func(req *http.Request) ([]byte, bool)
+
+// ContentTypeFunc returns the content type for an entry.
+//
+// If it returns an empty string the content type is detected from the value.
+type ContentTypeFunc func(key []byte, indexEntry *kvfile.IndexEntry) string
+
+// Option configures the Handler.
+type Option func(h *handler)
+
+// WithKeyFunc sets the function mapping requests to keys.
+//
+// Defaults to DefaultKeyFunc.
+func WithKeyFunc(keyFunc KeyFunc) Option {
+	return func(h *handler) {
+		h.keyFunc = keyFunc
+	}
+}
+
+// WithContentType sets the function returning the content type of entries.
+//
+// Defaults to DefaultContentType.
+func WithContentType(contentType ContentTypeFunc) Option {
+	return func(h *handler) {
+		h.contentType = contentType
+	}
+}
+
+// WithModTime sets the Last-Modified time of the values.
+//
+// If zero, Last-Modified is not sent.
+func WithModTime(modTime time.Time) Option {
+	return func(h *handler) {
+		h.modTime = modTime
+	}
+}
+
+// DefaultKeyFunc maps the request path without the leading slash to a key.
+func DefaultKeyFunc(req *http.Request) ([]byte, bool) {
+	key := strings.TrimPrefix(req.URL.Path, "/")
+	return []byte(key), key != ""
+}
+
+// DefaultContentType returns the entry metadata if it is a media type,
+// otherwise the type for the extension of the key.
+func DefaultContentType(key []byte, indexEntry *kvfile.IndexEntry) string {
+	if meta := string(indexEntry.GetMeta()); meta != "" {
+		if _, _, err := mime.ParseMediaType(meta); err == nil {
+			return meta
+		}
+	}
+	return mime.TypeByExtension(path.Ext(string(key)))
+}
+
+// handler implements the Handler.
+type handler struct {
+	reader      *kvfile.Reader
+	keyFunc     KeyFunc
+	contentType ContentTypeFunc
+	modTime     time.Time
+}
+
+// Handler builds a http.Handler serving the values in the kvfile.
+//
+// Supports GET and HEAD requests. Values are streamed from the reader with
+// support for Range and conditional requests. Returns 404 if the key is not
+// found. The raw value is served: encrypted values are not decrypted.
+func Handler(r *kvfile.Reader, opts ...Option) http.Handler {
+	h := &handler{
+		reader:      r,
+		keyFunc:     DefaultKeyFunc,
+		contentType: DefaultContentType,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// ServeHTTP serves a request.
+func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	key, ok := h.keyFunc(req)
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	_, valueLen, indexEntry, indexEntryIdx, err := h.reader.GetValuePosition(key)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if valueLen < 0 {
+		http.NotFound(w, req)
+		return
+	}
+	valueRdr, err := h.reader.GetValueReaderWithEntry(indexEntry, indexEntryIdx)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	header := w.Header()
+	header.Set("ETag", EntryETag(indexEntry))
+	if contentType := h.contentType(key, indexEntry); contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	http.ServeContent(w, req, string(key), h.modTime, valueRdr)
+}
+
+// EntryETag returns a weak ETag for an index entry.
+//
+// The ETag is derived from the value offset and size and a CRC-32 of the key,
+// metadata, and expiry: a kvfile rewritten with a different value at the
+// same position with the same size has the same ETag. It is weak as it does
+// not identify the value bytes, so If-Range requests always get the full
+// value instead of a range of a different value.
+func EntryETag(indexEntry *kvfile.IndexEntry) string {
+	var buf [8]byte
+	crc := crc32.NewIEEE()
+	_, _ = crc.Write(indexEntry.GetKey())
+	_, _ = crc.Write(indexEntry.GetMeta())
+	binary.BigEndian.PutUint64(buf[:], indexEntry.GetExpiresUnixMs())
+	_, _ = crc.Write(buf[:])
+
+	var sb strings.Builder
+	sb.WriteString(`W/"`)
+	sb.WriteString(strconv.FormatUint(indexEntry.GetOffset(), 16))
+	sb.WriteByte('-')
+	sb.WriteString(strconv.FormatUint(indexEntry.GetSize(), 16))
+	sb.WriteByte('-')
+	sb.WriteString(strconv.FormatUint(uint64(crc.Sum32()), 16))
+	sb.WriteByte('"')
+	return sb.String()
+}
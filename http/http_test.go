@@ -0,0 +1,145 @@
+package kvfile_http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+)
+
+// serve serves the request with the handler and returns the response.
+func serve(t *testing.T, h http.Handler, method, target string, header http.Header) (*http.Response, string) {
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	resp := rec.Result()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return resp, string(body)
+}
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	wr := kvfile.NewWriter(&buf)
+	if err := wr.WriteValue([]byte("blob.txt"), strings.NewReader("hello world")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.WriteValueBytesMeta([]byte("data"), []byte(`{"a":1}`), []byte("application/json")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.WriteTombstone([]byte("deleted")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	rd, err := kvfile.BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	h := Handler(rd)
+
+	// full GET
+	resp, body := serve(t, h, http.MethodGet, "/blob.txt", nil)
+	if resp.StatusCode != http.StatusOK || body != "hello world" {
+		t.Fatalf("unexpected response: %v %q", resp.StatusCode, body)
+	}
+	if resp.ContentLength != 11 || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Fatalf("unexpected headers: %v", resp.Header)
+	}
+	etag := resp.Header.Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) || !strings.HasSuffix(etag, `"`) {
+		t.Fatalf("unexpected etag: %q", etag)
+	}
+
+	// content type from the metadata
+	resp, body = serve(t, h, http.MethodGet, "/data", nil)
+	if resp.StatusCode != http.StatusOK || body != `{"a":1}` || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response: %v %q %v", resp.StatusCode, body, resp.Header)
+	}
+	if resp.Header.Get("ETag") == etag {
+		t.Fatal("expected different etags for different entries")
+	}
+
+	// ranged GET
+	resp, body = serve(t, h, http.MethodGet, "/blob.txt", http.Header{"Range": {"bytes=6-"}})
+	if resp.StatusCode != http.StatusPartialContent || body != "world" || resp.Header.Get("Content-Range") != "bytes 6-10/11" {
+		t.Fatalf("unexpected range response: %v %q %v", resp.StatusCode, body, resp.Header)
+	}
+	resp, _ = serve(t, h, http.MethodGet, "/blob.txt", http.Header{"Range": {"bytes=20-30"}})
+	if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("unexpected unsatisfiable range response: %v", resp.StatusCode)
+	}
+
+	// If-None-Match
+	resp, body = serve(t, h, http.MethodGet, "/blob.txt", http.Header{"If-None-Match": {etag}})
+	if resp.StatusCode != http.StatusNotModified || body != "" {
+		t.Fatalf("unexpected conditional response: %v %q", resp.StatusCode, body)
+	}
+	resp, _ = serve(t, h, http.MethodGet, "/blob.txt", http.Header{"If-None-Match": {`"other"`}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected conditional response: %v", resp.StatusCode)
+	}
+
+	// If-Range with the weak ETag returns the full value
+	resp, body = serve(t, h, http.MethodGet, "/blob.txt", http.Header{"Range": {"bytes=6-"}, "If-Range": {etag}})
+	if resp.StatusCode != http.StatusOK || body != "hello world" {
+		t.Fatalf("unexpected If-Range response: %v %q", resp.StatusCode, body)
+	}
+
+	// HEAD
+	resp, body = serve(t, h, http.MethodHead, "/blob.txt", nil)
+	if resp.StatusCode != http.StatusOK || body != "" || resp.Header.Get("Content-Length") != "11" {
+		t.Fatalf("unexpected head response: %v %q %v", resp.StatusCode, body, resp.Header)
+	}
+
+	// miss
+	for _, target := range []string{"/missing", "/deleted", "/"} {
+		if resp, _ := serve(t, h, http.MethodGet, target, nil); resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("unexpected response for %s: %v", target, resp.StatusCode)
+		}
+	}
+	if resp, _ := serve(t, h, http.MethodPost, "/blob.txt", nil); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("unexpected post response: %v", resp.StatusCode)
+	}
+}
+
+func TestHandlerOptions(t *testing.T) {
+	var buf bytes.Buffer
+	err := kvfile.Write(&buf, [][]byte{[]byte("data")}, func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := io.WriteString(wr, `{"a":1}`)
+		return uint64(nw), err
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rd, err := kvfile.BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	h := Handler(
+		rd,
+		WithKeyFunc(func(req *http.Request) ([]byte, bool) {
+			return bytes.CutPrefix([]byte(req.URL.Path), []byte("/static/"))
+		}),
+		WithContentType(func(key []byte, indexEntry *kvfile.IndexEntry) string {
+			return "application/x-" + string(key)
+		}),
+	)
+	resp, body := serve(t, h, http.MethodGet, "/static/data", nil)
+	if resp.StatusCode != http.StatusOK || body != `{"a":1}` || resp.Header.Get("Content-Type") != "application/x-data" {
+		t.Fatalf("unexpected response: %v %q %v", resp.StatusCode, body, resp.Header)
+	}
+	if resp, _ := serve(t, h, http.MethodGet, "/data", nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unexpected response outside the prefix: %v", resp.StatusCode)
+	}
+}
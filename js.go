@@ -0,0 +1,175 @@
+//go:build js && wasm
+
+package kvfile
+
+import (
+	"bytes"
+	"io"
+	"syscall/js"
+
+	"github.com/pkg/errors"
+)
+
+// NewReaderFromJSArrayBuffer constructs a new Reader from a js ArrayBuffer.
+//
+// The value can also be a typed array or DataView over an ArrayBuffer. The Go
+// wasm heap cannot share memory with a js ArrayBuffer, so the bytes are copied
+// into Go memory once and the Reader does not make further copies.
+func NewReaderFromJSArrayBuffer(v js.Value) (*Reader, error) {
+	data, err := jsBytes(v)
+	if err != nil {
+		return nil, err
+	}
+	return BuildReader(bytes.NewReader(data), uint64(len(data)))
+}
+
+// NewReaderFromJSBlob constructs a new Reader from a js Blob or File.
+//
+// The file is loaded lazily with a JSBlobReaderAt. Reads block the calling
+// goroutine until the browser returns the data, so the Reader must not be used
+// from a js callback running on the event loop.
+func NewReaderFromJSBlob(blob js.Value) (*Reader, error) {
+	rd := NewJSBlobReaderAt(blob)
+	return BuildReader(rd, uint64(rd.Size()))
+}
+
+// JSBlobReaderAt is an io.ReaderAt over a js Blob or File.
+//
+// Each ReadAt reads the requested range with Blob.slice. The range is read
+// with Blob.arrayBuffer if available, otherwise with a FileReader.
+type JSBlobReaderAt struct {
+	blob js.Value
+	size int64
+}
+
+// NewJSBlobReaderAt constructs a new JSBlobReaderAt.
+func NewJSBlobReaderAt(blob js.Value) *JSBlobReaderAt {
+	return &JSBlobReaderAt{blob: blob, size: int64(blob.Get("size").Float())}
+}
+
+// Size returns the size of the blob.
+func (b *JSBlobReaderAt) Size() int64 {
+	return b.size
+}
+
+// ReadAt reads len(p) bytes from the blob starting at off.
+func (b *JSBlobReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= b.size {
+		return 0, io.EOF
+	}
+	end := off + int64(len(p))
+	if end > b.size {
+		end = b.size
+	}
+	buf, err := readJSBlob(b.blob.Call("slice", off, end))
+	if err != nil {
+		return 0, err
+	}
+	n, err := jsCopyBytes(p, buf)
+	if err != nil {
+		return n, err
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// readJSBlob reads the contents of a Blob to an ArrayBuffer.
+func readJSBlob(blob js.Value) (js.Value, error) {
+	if blob.Get("arrayBuffer").Type() == js.TypeFunction {
+		return awaitJSPromise(blob.Call("arrayBuffer"))
+	}
+
+	// older browsers do not have Blob.arrayBuffer
+	reader := js.Global().Get("FileReader").New()
+	done := make(chan error, 1)
+	onLoad := js.FuncOf(func(this js.Value, args []js.Value) any {
+		done <- nil
+		return nil
+	})
+	defer onLoad.Release()
+	onError := js.FuncOf(func(this js.Value, args []js.Value) any {
+		done <- errors.Errorf("read blob: %s", jsErrorString(reader.Get("error")))
+		return nil
+	})
+	defer onError.Release()
+	reader.Set("onload", onLoad)
+	reader.Set("onerror", onError)
+	reader.Call("readAsArrayBuffer", blob)
+	if err := <-done; err != nil {
+		return js.Undefined(), err
+	}
+	return reader.Get("result"), nil
+}
+
+// awaitJSPromise blocks until the promise settles.
+func awaitJSPromise(promise js.Value) (js.Value, error) {
+	type result struct {
+		value js.Value
+		err   error
+	}
+	done := make(chan result, 1)
+	onResolve := js.FuncOf(func(this js.Value, args []js.Value) any {
+		done <- result{value: args[0]}
+		return nil
+	})
+	defer onResolve.Release()
+	onReject := js.FuncOf(func(this js.Value, args []js.Value) any {
+		done <- result{err: errors.New(jsErrorString(args[0]))}
+		return nil
+	})
+	defer onReject.Release()
+	promise.Call("then", onResolve, onReject)
+	res := <-done
+	return res.value, res.err
+}
+
+// jsErrorString returns the message of a js error value.
+func jsErrorString(v js.Value) string {
+	if v.Type() == js.TypeObject && v.Get("message").Type() == js.TypeString {
+		return v.Get("message").String()
+	}
+	return js.Global().Get("String").Invoke(v).String()
+}
+
+// jsBytes copies an ArrayBuffer, typed array, or DataView to a byte slice.
+func jsBytes(v js.Value) ([]byte, error) {
+	u8, err := jsUint8Array(v)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, u8.Get("byteLength").Int())
+	js.CopyBytesToGo(data, u8)
+	return data, nil
+}
+
+// jsCopyBytes copies an ArrayBuffer to p.
+func jsCopyBytes(p []byte, v js.Value) (int, error) {
+	u8, err := jsUint8Array(v)
+	if err != nil {
+		return 0, err
+	}
+	return js.CopyBytesToGo(p, u8), nil
+}
+
+// jsUint8Array returns a Uint8Array view of an ArrayBuffer, typed array, or DataView.
+func jsUint8Array(v js.Value) (js.Value, error) {
+	uint8Array := js.Global().Get("Uint8Array")
+	switch {
+	case v.InstanceOf(uint8Array):
+		return v, nil
+	case v.InstanceOf(js.Global().Get("ArrayBuffer")):
+		return uint8Array.New(v), nil
+	case v.Type() == js.TypeObject && v.Get("buffer").Type() == js.TypeObject && v.Get("byteOffset").Type() == js.TypeNumber:
+		return uint8Array.New(v.Get("buffer"), v.Get("byteOffset"), v.Get("byteLength")), nil
+	default:
+		return js.Undefined(), errors.New("expected an ArrayBuffer or ArrayBuffer view")
+	}
+}
+
+// _ is a type assertion
+var _ io.ReaderAt = ((*JSBlobReaderAt)(nil))
@@ -0,0 +1,70 @@
+//go:build js && wasm
+
+package kvfile
+
+import (
+	"bytes"
+	"syscall/js"
+	"testing"
+)
+
+// buildTestJSFile writes a kvfile with the keys and copies it to a Uint8Array.
+func buildTestJSFile(t *testing.T, keys []string) js.Value {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	for _, key := range keys {
+		if err := wr.WriteValue([]byte(key), bytes.NewReader([]byte("val-"+key))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	u8 := js.Global().Get("Uint8Array").New(buf.Len())
+	js.CopyBytesToJS(u8, buf.Bytes())
+	return u8
+}
+
+func TestJSReaders(t *testing.T) {
+	keys := []string{"a", "b", "c"}
+	u8 := buildTestJSFile(t, keys)
+
+	checkGet := func(name string, rdr *Reader) {
+		for _, key := range keys {
+			val, found, err := rdr.Get([]byte(key))
+			if err != nil || !found || string(val) != "val-"+key {
+				t.Fatalf("%s: unexpected value for %s: %q %v %v", name, key, val, found, err)
+			}
+		}
+		if _, found, err := rdr.Get([]byte("missing")); err != nil || found {
+			t.Fatalf("%s: expected missing key to not be found: %v %v", name, found, err)
+		}
+	}
+
+	rdr, err := NewReaderFromJSArrayBuffer(u8.Get("buffer"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	checkGet("array buffer", rdr)
+
+	// a view at an offset into a larger buffer
+	padded := js.Global().Get("Uint8Array").New(u8.Length() + 8)
+	padded.Call("set", u8, 4)
+	view := js.Global().Get("DataView").New(padded.Get("buffer"), 4, u8.Length())
+	rdr, err = NewReaderFromJSArrayBuffer(view)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	checkGet("data view", rdr)
+
+	if _, err := NewReaderFromJSArrayBuffer(js.ValueOf("not a buffer")); err == nil {
+		t.Fatal("expected an error for a string")
+	}
+
+	blob := js.Global().Get("Blob").New([]any{u8})
+	rdr, err = NewReaderFromJSBlob(blob)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	checkGet("blob", rdr)
+}
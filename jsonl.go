@@ -0,0 +1,239 @@
+package kvfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// JSONLEntry is an entry in the JSON Lines format.
+//
+// Keys which are valid UTF-8 are stored as strings, otherwise they are stored
+// base64 encoded in key_base64. Values are stored in value or value_base64
+// depending on the JSONLValueEncoding.
+type JSONLEntry struct {
+	Key         string  `json:"key,omitempty"`
+	KeyBase64   []byte  `json:"key_base64,omitempty"`
+	Value       *string `json:"value,omitempty"`
+	ValueBase64 []byte  `json:"value_base64,omitempty"`
+}
+
+// JSONLValueEncoding is the encoding of values in JSON Lines.
+type JSONLValueEncoding int
+
+const (
+	// JSONLValueAuto stores values which are valid UTF-8 as strings and other
+	// values base64 encoded.
+	JSONLValueAuto JSONLValueEncoding = iota
+	// JSONLValueBase64 stores all values base64 encoded.
+	JSONLValueBase64
+)
+
+// NewJSONLEntry builds the JSON Lines entry for the key and value.
+func NewJSONLEntry(key, value []byte, valueEncoding JSONLValueEncoding) *JSONLEntry {
+	entry := &JSONLEntry{}
+	if utf8.Valid(key) {
+		entry.Key = string(key)
+	} else {
+		entry.KeyBase64 = key
+	}
+	if valueEncoding == JSONLValueAuto && utf8.Valid(value) {
+		valueStr := string(value)
+		entry.Value = &valueStr
+	} else {
+		entry.ValueBase64 = value
+	}
+	return entry
+}
+
+// ParseJSONLEntry parses a JSON Lines entry returning the key and value.
+func ParseJSONLEntry(line []byte) ([]byte, []byte, error) {
+	var entry JSONLEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return nil, nil, err
+	}
+	key := []byte(entry.Key)
+	if entry.KeyBase64 != nil {
+		if entry.Key != "" {
+			return nil, nil, errors.New("key and key_base64 cannot both be set")
+		}
+		key = entry.KeyBase64
+	}
+	if len(key) == 0 {
+		return nil, nil, errors.New("key cannot be empty")
+	}
+	value := entry.ValueBase64
+	if entry.Value != nil {
+		if value != nil {
+			return nil, nil, errors.New("value and value_base64 cannot both be set")
+		}
+		value = []byte(*entry.Value)
+	}
+	return key, value, nil
+}
+
+// JSONLLineError is returned when importing a malformed JSON Lines entry.
+type JSONLLineError struct {
+	// Line is the line number starting at 1.
+	Line int
+	// Err is the parse error.
+	Err error
+}
+
+// Error returns the error string.
+func (e *JSONLLineError) Error() string {
+	return "invalid JSON on line " + strconv.Itoa(e.Line) + ": " + e.Err.Error()
+}
+
+// Unwrap returns the parse error.
+func (e *JSONLLineError) Unwrap() error {
+	return e.Err
+}
+
+// JSONLDuplicates is the handling of duplicate keys when importing JSON Lines.
+type JSONLDuplicates int
+
+const (
+	// JSONLDuplicatesError returns an error for a duplicate key.
+	JSONLDuplicatesError JSONLDuplicates = iota
+	// JSONLDuplicatesFirstWins keeps the first value and skips duplicates.
+	JSONLDuplicatesFirstWins
+)
+
+// JSONLOption is an option for ExportJSONL and ImportJSONL.
+type JSONLOption func(o *jsonlOptions)
+
+// jsonlOptions are the options for ExportJSONL and ImportJSONL.
+type jsonlOptions struct {
+	valueEncoding JSONLValueEncoding
+	prefix        []byte
+	duplicates    JSONLDuplicates
+	onMalformed   func(err *JSONLLineError)
+}
+
+// WithJSONLValueEncoding sets the value encoding used by ExportJSONL.
+//
+// Defaults to JSONLValueAuto.
+func WithJSONLValueEncoding(valueEncoding JSONLValueEncoding) JSONLOption {
+	return func(o *jsonlOptions) {
+		o.valueEncoding = valueEncoding
+	}
+}
+
+// WithJSONLPrefix exports only the keys with the prefix.
+func WithJSONLPrefix(prefix []byte) JSONLOption {
+	return func(o *jsonlOptions) {
+		o.prefix = prefix
+	}
+}
+
+// WithJSONLDuplicates sets the handling of duplicate keys by ImportJSONL.
+//
+// Defaults to JSONLDuplicatesError.
+func WithJSONLDuplicates(duplicates JSONLDuplicates) JSONLOption {
+	return func(o *jsonlOptions) {
+		o.duplicates = duplicates
+	}
+}
+
+// WithJSONLSkipMalformed skips malformed lines when importing.
+//
+// The callback is called with each skipped line, and may be nil. By default
+// ImportJSONL returns a *JSONLLineError for the first malformed line.
+func WithJSONLSkipMalformed(onMalformed func(err *JSONLLineError)) JSONLOption {
+	return func(o *jsonlOptions) {
+		if onMalformed == nil {
+			onMalformed = func(err *JSONLLineError) {}
+		}
+		o.onMalformed = onMalformed
+	}
+}
+
+// buildJSONLOptions applies the options.
+func buildJSONLOptions(opts []JSONLOption) *jsonlOptions {
+	o := &jsonlOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// ExportJSONL writes the key/value pairs in the store as JSON Lines.
+//
+// Each line is a JSONLEntry. The entries are streamed in key order.
+func ExportJSONL(w io.Writer, r Store, opts ...JSONLOption) error {
+	o := buildJSONLOptions(opts)
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	scan := r.ScanPrefix
+	if rdr, ok := r.(*Reader); ok {
+		// each value is encoded before the next is read
+		scan = rdr.ScanPrefixReuse
+	}
+	err := scan(o.prefix, func(key, value []byte) error {
+		return enc.Encode(NewJSONLEntry(key, value, o.valueEncoding))
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// ImportJSONL writes a kvfile to dst from JSON Lines read from src.
+//
+// Blank lines are skipped. Values are written as each line is read, so only
+// the index and the current line are kept in memory.
+func ImportJSONL(dst io.Writer, src io.Reader, opts ...JSONLOption) error {
+	return ImportJSONLToWriter(NewWriter(dst), src, opts...)
+}
+
+// ImportJSONLToWriter writes the JSON Lines read from src to the Writer and
+// closes the Writer.
+func ImportJSONLToWriter(wr *Writer, src io.Reader, opts ...JSONLOption) error {
+	o := buildJSONLOptions(opts)
+	// seen maps each key to the line number it was read on
+	seen := make(map[string]int)
+	br := bufio.NewReader(src)
+	for lineNum := 1; ; lineNum++ {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return errors.Wrap(err, "read input")
+		}
+		if len(bytes.TrimSpace(line)) != 0 {
+			if werr := importJSONLLine(wr, o, seen, line, lineNum); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return wr.Close()
+}
+
+// importJSONLLine parses a line and writes the entry to the Writer.
+func importJSONLLine(wr *Writer, o *jsonlOptions, seen map[string]int, line []byte, lineNum int) error {
+	key, value, err := ParseJSONLEntry(line)
+	if err != nil {
+		lineErr := &JSONLLineError{Line: lineNum, Err: err}
+		if o.onMalformed == nil {
+			return lineErr
+		}
+		o.onMalformed(lineErr)
+		return nil
+	}
+	if prevLine, ok := seen[string(key)]; ok {
+		if o.duplicates == JSONLDuplicatesFirstWins {
+			return nil
+		}
+		return errors.Errorf("duplicate key %q on line %d: first seen on line %d", key, lineNum, prevLine)
+	}
+	seen[string(key)] = lineNum
+	return wr.WriteValue(key, bytes.NewReader(value))
+}
@@ -0,0 +1,124 @@
+package kvfile
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestJSONLRoundTrip(t *testing.T) {
+	kvs := []KV{
+		{Key: []byte("binary"), Value: []byte{0x00, 0xff, 0xfe, '\n', 0x80}},
+		{Key: []byte("empty"), Value: nil},
+		{Key: []byte("lines"), Value: []byte("line one\nline two\r\n\"quoted\" <tag>\n")},
+		{Key: []byte("other/a"), Value: []byte("hello")},
+		{Key: []byte{'k', 0xff}, Value: []byte("binary key")},
+	}
+	store := make(MapStore)
+	for _, kv := range kvs {
+		store[string(kv.Key)] = kv.Value
+	}
+
+	for _, valueEncoding := range []JSONLValueEncoding{JSONLValueAuto, JSONLValueBase64} {
+		var jsonl bytes.Buffer
+		if err := ExportJSONL(&jsonl, store, WithJSONLValueEncoding(valueEncoding)); err != nil {
+			t.Fatal(err.Error())
+		}
+		lines := strings.Split(strings.TrimSuffix(jsonl.String(), "\n"), "\n")
+		if len(lines) != len(kvs) {
+			t.Fatalf("expected one line per entry: %q", jsonl.String())
+		}
+		if valueEncoding == JSONLValueAuto && lines[3] != `{"key":"lines","value":"line one\nline two\r\n\"quoted\" <tag>\n"}` {
+			t.Fatalf("unexpected line: %s", lines[3])
+		}
+		if valueEncoding == JSONLValueBase64 && strings.Contains(jsonl.String(), `"value":`) {
+			t.Fatalf("expected only base64 values: %s", jsonl.String())
+		}
+
+		var buf bytes.Buffer
+		if err := ImportJSONL(&buf, &jsonl); err != nil {
+			t.Fatal(err.Error())
+		}
+		rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if rdr.Size() != uint64(len(kvs)) {
+			t.Fatalf("expected %d entries: %d", len(kvs), rdr.Size())
+		}
+		for _, kv := range kvs {
+			val, found, err := rdr.Get(kv.Key)
+			if err != nil || !found || !bytes.Equal(val, kv.Value) {
+				t.Fatalf("unexpected value for %q: %q %v %v", kv.Key, val, found, err)
+			}
+		}
+	}
+
+	// prefix filter
+	var jsonl bytes.Buffer
+	if err := ExportJSONL(&jsonl, store, WithJSONLPrefix([]byte("other/"))); err != nil {
+		t.Fatal(err.Error())
+	}
+	if jsonl.String() != "{\"key\":\"other/a\",\"value\":\"hello\"}\n" {
+		t.Fatalf("unexpected prefix export: %q", jsonl.String())
+	}
+}
+
+func TestImportJSONLDuplicates(t *testing.T) {
+	input := "{\"key\":\"a\",\"value\":\"1\"}\n\n{\"key\":\"b\",\"value\":\"2\"}\n{\"key\":\"a\",\"value\":\"3\"}\n"
+	var buf bytes.Buffer
+	err := ImportJSONL(&buf, strings.NewReader(input))
+	if err == nil || !strings.Contains(err.Error(), "on line 4: first seen on line 1") {
+		t.Fatalf("expected duplicate key error: %v", err)
+	}
+
+	buf.Reset()
+	if err := ImportJSONL(&buf, strings.NewReader(input), WithJSONLDuplicates(JSONLDuplicatesFirstWins)); err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if val, _, err := rdr.Get([]byte("a")); err != nil || string(val) != "1" {
+		t.Fatalf("expected the first value to win: %q %v", val, err)
+	}
+}
+
+func TestImportJSONLMalformed(t *testing.T) {
+	input := strings.Join([]string{
+		`{"key":"a","value":"1"}`,
+		`not json`,
+		`{"key":""}`,
+		`{"key":"b","value":"2","value_base64":"Mg=="}`,
+		`{"key":"c","value":"3"}`,
+	}, "\n")
+
+	var buf bytes.Buffer
+	err := ImportJSONL(&buf, strings.NewReader(input))
+	var lineErr *JSONLLineError
+	if !errors.As(err, &lineErr) || lineErr.Line != 2 {
+		t.Fatalf("expected an error on line 2: %v", err)
+	}
+
+	var skipped []int
+	buf.Reset()
+	err = ImportJSONL(&buf, strings.NewReader(input), WithJSONLSkipMalformed(func(err *JSONLLineError) {
+		skipped = append(skipped, err.Line)
+	}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(skipped) != 3 || skipped[0] != 2 || skipped[1] != 3 || skipped[2] != 4 {
+		t.Fatalf("unexpected skipped lines: %v", skipped)
+	}
+	rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if rdr.Size() != 2 {
+		t.Fatalf("expected 2 entries: %d", rdr.Size())
+	}
+}
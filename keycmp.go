@@ -0,0 +1,85 @@
+package kvfile
+
+import (
+	"bytes"
+
+	protobuf_go_lite "github.com/aperturerobotics/protobuf-go-lite"
+)
+
+// keyCompareWindow is the number of bytes at the start of an index entry read
+// to compare the key when searching, if the entry is larger.
+//
+// The comparison is usually decided within the first few bytes of long keys,
+// so the rest of the entry is only read if the window ties. Zero reads the
+// entire entry. Tests override this to compare the paths.
+var keyCompareWindow = 64
+
+// indexEntryKeyTag is the tag of the IndexEntry key field, which is encoded
+// first: field 1 with the length-delimited wire type.
+const indexEntryKeyTag = 1<<3 | 2
+
+// compareIndexEntryKey compares the key of the index entry with the key.
+//
+// Reads a window at the start of large entries and only reads the entire
+// entry if the window does not decide the comparison. Returns the index entry
+// if it was read, which is always the case if the keys are equal.
+func (r *Reader) compareIndexEntryKey(indexEntryIdx uint64, key []byte) (int, *IndexEntry, error) {
+	if r.fixedKeyWidth != 0 || indexEntryIdx >= r.indexEntryCount {
+		indexEntry, err := r.ReadIndexEntry(indexEntryIdx)
+		if err != nil {
+			return 0, nil, err
+		}
+		return bytes.Compare(indexEntry.GetKey(), key), indexEntry, nil
+	}
+	indexEntryPos, indexEntrySize, err := r.readIndexEntryBounds(indexEntryIdx)
+	if err != nil {
+		return 0, nil, err
+	}
+	if window := keyCompareWindow; window != 0 && indexEntrySize > uint64(window) {
+		buf := make([]byte, window)
+		if _, err := r.rd.ReadAt(buf, indexEntryPos); err != nil {
+			return 0, nil, err
+		}
+		if cmp, ok := compareKeyWindow(buf, key); ok && cmp != 0 {
+			return cmp, nil, nil
+		}
+	}
+	indexEntry, err := r.readIndexEntryAt(indexEntryPos, indexEntrySize)
+	if err != nil {
+		return 0, nil, err
+	}
+	return bytes.Compare(indexEntry.GetKey(), key), indexEntry, nil
+}
+
+// compareKeyWindow compares the key in the start of an encoded IndexEntry with
+// the key without parsing the entry.
+//
+// Returns the result of bytes.Compare and true if the window decides the
+// comparison, or false if the window is too short or does not start with the
+// key field.
+func compareKeyWindow(window, key []byte) (int, bool) {
+	if len(window) == 0 || window[0] != indexEntryKeyTag {
+		return 0, false
+	}
+	keyLen, n := protobuf_go_lite.ConsumeVarint(window[1:])
+	if n < 0 {
+		return 0, false
+	}
+	entryKey := window[1+n:]
+	if keyLen < uint64(len(entryKey)) {
+		entryKey = entryKey[:keyLen]
+	}
+	keyPrefix := key[:min(len(key), len(entryKey))]
+	if cmp := bytes.Compare(entryKey, keyPrefix); cmp != 0 {
+		return cmp, true
+	}
+	if uint64(len(entryKey)) == keyLen {
+		// the entire entry key is in the window
+		return bytes.Compare(entryKey, key), true
+	}
+	if len(key) <= len(entryKey) {
+		// the key is a prefix of the longer entry key
+		return 1, true
+	}
+	return 0, false
+}
@@ -0,0 +1,133 @@
+package kvfile
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// writeLongKeyTestFile writes n entries with keys of about 1.5KB which share
+// a prefix of prefixLen bytes.
+func writeLongKeyTestFile(t testing.TB, n, prefixLen int) ([]byte, [][]byte) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	keys := make([][]byte, n)
+	for i := range keys {
+		key := bytes.Repeat([]byte{'p'}, prefixLen)
+		key = append(key, strconv.Itoa(100000+i)...)
+		key = append(key, bytes.Repeat([]byte{'s'}, 1500-len(key))...)
+		keys[i] = key
+		if err := wr.WriteValue(key, bytes.NewReader([]byte("val-"+strconv.Itoa(i)))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	return buf.Bytes(), keys
+}
+
+// getLongKeys looks up the keys and some missing keys and returns the number
+// of bytes read.
+func getLongKeys(t *testing.T, data []byte, keys [][]byte) int64 {
+	counter := &countingReaderAt{rd: bytes.NewReader(data)}
+	rdr, err := BuildReader(counter, uint64(len(data)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	counter.bytes.Store(0)
+	for i, key := range keys {
+		val, found, err := rdr.Get(key)
+		if err != nil || !found || string(val) != "val-"+strconv.Itoa(i) {
+			t.Fatalf("unexpected value for key %v: %v %v", i, found, err)
+		}
+		missing := append(bytes.Clone(key[:len(key)-1]), 't')
+		if found, err := rdr.Exists(missing); err != nil || found {
+			t.Fatalf("expected key to not exist: %v %v", found, err)
+		}
+		if found, err := rdr.Exists(key[:len(key)-1]); err != nil || found {
+			t.Fatalf("expected key prefix to not exist: %v %v", found, err)
+		}
+	}
+	return counter.bytes.Load()
+}
+
+func TestCompareIndexEntryKey(t *testing.T) {
+	prev := keyCompareWindow
+	defer func() {
+		keyCompareWindow = prev
+	}()
+
+	// shared prefixes shorter and longer than the window
+	for _, prefixLen := range []int{0, 60, 100} {
+		data, keys := writeLongKeyTestFile(t, 100, prefixLen)
+		keyCompareWindow = 0
+		fullBytes := getLongKeys(t, data, keys)
+		keyCompareWindow = prev
+		windowBytes := getLongKeys(t, data, keys)
+		// probes decided in the window do not read the entire keys
+		if prefixLen == 0 && windowBytes*2 > fullBytes {
+			t.Fatalf("expected fewer bytes read with the key window: %v >= %v / 2", windowBytes, fullBytes)
+		}
+	}
+}
+
+func FuzzCompareKeyWindow(f *testing.F) {
+	f.Add([]byte("key"), []byte("key"), 8)
+	f.Add([]byte("key-1"), []byte("key"), 4)
+	f.Add([]byte("key"), []byte("key-1"), 64)
+	f.Add(bytes.Repeat([]byte("a"), 200), bytes.Repeat([]byte("a"), 199), 64)
+	f.Add([]byte{}, []byte("a"), 2)
+	f.Fuzz(func(t *testing.T, entryKey, key []byte, window int) {
+		data, err := (&IndexEntry{Key: entryKey, Offset: 1, Size: 2}).MarshalVT()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if window < 0 || window > len(data) {
+			window = len(data)
+		}
+		cmp, ok := compareKeyWindow(data[:window], key)
+		if ok && cmp != bytes.Compare(entryKey, key) {
+			t.Fatalf("compare %q with %q in window %v: got %v", entryKey, key, window, cmp)
+		}
+		if window == len(data) && len(entryKey) != 0 && !ok {
+			t.Fatalf("expected the entire entry to decide the comparison")
+		}
+	})
+}
+
+func benchmarkLongKeyGet(b *testing.B, window int) {
+	prev := keyCompareWindow
+	keyCompareWindow = window
+	defer func() {
+		keyCompareWindow = prev
+	}()
+
+	n := 10000
+	data, keys := writeLongKeyTestFile(b, n, 0)
+	counter := &countingReaderAt{rd: &remoteReaderAt{rd: bytes.NewReader(data), latency: 50 * time.Microsecond}}
+	rdr, err := BuildReader(counter, uint64(len(data)))
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+	counter.reads.Store(0)
+	counter.bytes.Store(0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, found, err := rdr.Get(keys[i%n])
+		if err != nil || !found {
+			b.Fatalf("get failed: %v %v", found, err)
+		}
+	}
+	b.ReportMetric(float64(counter.reads.Load())/float64(b.N), "readat/op")
+	b.ReportMetric(float64(counter.bytes.Load())/float64(b.N), "readbytes/op")
+}
+
+func BenchmarkLongKeyGetFullEntry(b *testing.B) {
+	benchmarkLongKeyGet(b, 0)
+}
+
+func BenchmarkLongKeyGetKeyWindow(b *testing.B) {
+	benchmarkLongKeyGet(b, keyCompareWindow)
+}
@@ -6,6 +6,9 @@ import (
 	"io"
 	"io/fs"
 	"math"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	protobuf_go_lite "github.com/aperturerobotics/protobuf-go-lite"
 	"github.com/pkg/errors"
@@ -16,14 +19,81 @@ import (
 // this is also an upper bound on key length
 var maxIndexEntrySize = 2048
 
+// maxMetaSize is the maximum size of the metadata attached to an entry.
+var maxMetaSize = 256
+
 // maxValueSize is the maximum value size we will read
 // currently set to 1GB
 var maxValueSize uint32 = 1e9
 
+// ReaderOptions are optional settings for a Reader.
+type ReaderOptions struct {
+	// FilterExpired hides entries with an expiry time that has passed.
+	// Get returns not found and scans skip expired entries.
+	// Entries without an expiry time never expire.
+	FilterExpired bool
+	// Now returns the current time used to check entry expiry.
+	// If nil, uses time.Now.
+	Now func() time.Time
+	// AllowEncryptedValues allows opening files with encrypted values.
+	//
+	// The values are returned as stored (encrypted). If unset, opening a file
+	// written with WriterOptions.EncryptedValues returns ErrEncryptedValues.
+	AllowEncryptedValues bool
+	// ExposeTombstones returns tombstone entries from lookups and scans.
+	// By default tombstones are hidden as if the key does not exist.
+	// Check IndexEntry.Tombstone to distinguish them from empty values.
+	ExposeTombstones bool
+	// OpenValues opens the reader for values stored outside of the file
+	// layout, required to open files written with WriterOptions.ValueWriter.
+	//
+	// valuesSize is the size of the region at the start of the file before the
+	// index containing the value stream, zero if the file has no entries. The
+	// returned ReaderAt reads the values at the offsets in the index.
+	OpenValues func(rd io.ReaderAt, valuesSize uint64) (io.ReaderAt, error)
+	// MaxValueSize is the maximum size of a value to read in bytes.
+	// If zero, uses the default of 1e9.
+	MaxValueSize uint64
+	// MaxIndexEntrySize is the maximum size of an index entry in bytes, which
+	// limits the size of the keys. If zero, uses the default of 2048.
+	MaxIndexEntrySize uint64
+	// CachePositions reads the list of index entry positions into memory on
+	// the first index entry read and serves the positions from memory.
+	//
+	// Without the cache each index entry read starts with an 8 byte read from
+	// the positions list, so a binary search makes O(log n) small reads. The
+	// index entries are still read from the file. Uses 8 bytes of memory per
+	// entry. Has no effect with the fixed-width key layout.
+	CachePositions bool
+	// PreloadPositionsSize reads the list of index entry positions into memory
+	// when building the Reader if the list is at most PreloadPositionsSize bytes
+	// (8 bytes per entry), as with CachePositions.
+	//
+	// If zero, the positions are not preloaded.
+	PreloadPositionsSize uint64
+	// Hooks are optional callbacks for instrumenting the Reader.
+	Hooks *ReaderHooks
+	// Trace is called after each read from the underlying ReaderAt if set.
+	Trace TraceFunc
+}
+
+// ErrExternalValues is returned when opening a file with values stored outside
+// of the file layout without ReaderOptions.OpenValues.
+var ErrExternalValues = errors.New("kvfile values are stored externally: use a reader supporting the value format")
+
+// ErrEncryptedValues is returned when opening a file with encrypted values
+// without ReaderOptions.AllowEncryptedValues.
+var ErrEncryptedValues = errors.New("kvfile values are encrypted: use a decrypting reader")
+
 // Reader is a key/value file reader.
 type Reader struct {
 	// rd is the reader
 	rd io.ReaderAt
+	// valueRd is the reader for values
+	// this is rd unless the values are stored externally
+	valueRd io.ReaderAt
+	// opts are the reader options
+	opts ReaderOptions
 	// indexEntryCount is the number of entries in the index entries list.
 	// if 0, the file is empty
 	indexEntryCount uint64
@@ -31,22 +101,105 @@ type Reader struct {
 	indexEntryIndexesPos uint64
 	// indexEntryListPos is the position in the file of the first index entry.
 	indexEntryListPos uint64
+	// footerBlocks contains the blocks in the footer extension area.
+	footerBlocks []footerBlock
+	// formatFlags contains the flags from the format footer block.
+	formatFlags uint64
+	// fixedKeyWidth is the key width if using the fixed-width key layout.
+	fixedKeyWidth uint64
+	// fixedOffsetWidth is the number of bytes for offsets in fixed-width records.
+	fixedOffsetWidth uint64
+	// fixedSizeWidth is the number of bytes for sizes in fixed-width records.
+	fixedSizeWidth uint64
+	// positionsMtx guards reading the positions list
+	positionsMtx sync.Mutex
+	// positions contains the index entry positions list if cached
+	positions atomic.Pointer[[]byte]
+	// bloom is the in-memory bloom filter of the keys if built
+	bloom atomic.Pointer[bloomFilter]
 }
 
 // BuildReader constructs a new Reader, reading the number of index entries.
 func BuildReader(rd io.ReaderAt, fileSize uint64) (*Reader, error) {
+	return BuildReaderWithOptions(rd, fileSize, ReaderOptions{})
+}
+
+// BuildReaderWithOptions constructs a new Reader with the given options.
+func BuildReaderWithOptions(rd io.ReaderAt, fileSize uint64, opts ReaderOptions) (*Reader, error) {
+	r, err := buildReader(rd, fileSize, opts)
+	if err != nil {
+		return nil, err
+	}
+	if r.formatFlags&formatFlagExternalValues != 0 {
+		valuesRd := r.rd
+		if tr, ok := valuesRd.(*traceReaderAt); ok {
+			valuesRd = tr.rd
+		}
+		r.valueRd, err = opts.OpenValues(valuesRd, r.indexEntryListPos)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Trace != nil {
+			r.valueRd = &traceReaderAt{rd: r.valueRd, op: TraceOpValue, trace: opts.Trace}
+		}
+	}
+	return r, nil
+}
+
+// buildReader constructs a new Reader reading the file layout.
+func buildReader(rd io.ReaderAt, fileSize uint64, opts ReaderOptions) (*Reader, error) {
+	if opts.Hooks != nil && opts.Hooks.OnReadAt != nil {
+		rd = &hookReaderAt{rd: rd, onReadAt: opts.Hooks.OnReadAt}
+	}
+	r := &Reader{rd: rd, valueRd: rd, opts: opts}
+	if opts.Trace != nil {
+		r.rd = &traceReaderAt{rd: rd, op: TraceOpIndex, trace: opts.Trace}
+		r.valueRd = &traceReaderAt{rd: rd, op: TraceOpValue, trace: opts.Trace}
+		rd = r.rd
+	}
 	if fileSize == 0 {
-		return &Reader{rd: rd, indexEntryCount: 0}, nil
+		return r, nil
+	}
+	if fileSize < 8 {
+		return nil, errors.Errorf("file too small: %v", fileSize)
 	}
 
-	// read the number of index entries
-	indexEntryCountPos := int64(fileSize) - 8
-	buf := make([]byte, 8)
-	_, err := rd.ReadAt(buf, indexEntryCountPos)
+	// read the number of index entries along with the last index entry pos
+	tailPos := int64(fileSize) - 16
+	if tailPos < 0 {
+		tailPos = 0
+	}
+	tail := make([]byte, int64(fileSize)-tailPos)
+	_, err := rd.ReadAt(tail, tailPos)
 	if err != nil {
 		return nil, err
 	}
-	indexEntryCount := binary.LittleEndian.Uint64(buf)
+	indexEntryCountPos := int64(fileSize) - 8
+	indexEntryCount := binary.LittleEndian.Uint64(tail[len(tail)-8:])
+
+	// check for a footer directly before the number of index entries
+	// this is the case if there are no index entry positions
+	var footerStart uint64
+	hasFooter := len(tail) == 16 && isFooterMagic(tail[:8])
+	if hasFooter {
+		footerStart, err = r.readFooter(uint64(indexEntryCountPos))
+		if err != nil {
+			return nil, err
+		}
+		if err := r.checkFormatFlags(); err != nil {
+			return nil, err
+		}
+	}
+	if r.fixedKeyWidth != 0 {
+		if !hasFooter {
+			return nil, errors.New("fixed key width layout must have the footer before the index entry count")
+		}
+		if err := r.readFixedIndexLayout(indexEntryCount, footerStart); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+
 	if indexEntryCount > math.MaxUint64/8 || indexEntryCount*8 > uint64(indexEntryCountPos) {
 		return nil, errors.Errorf("index entry count too large: %v", indexEntryCount)
 	}
@@ -54,20 +207,36 @@ func BuildReader(rd io.ReaderAt, fileSize uint64) (*Reader, error) {
 	if indexEntryIndexesPos < 0 {
 		return nil, errors.Errorf("invalid count of index entries for file size: %v", indexEntryCount)
 	}
-	// clear the buf
-	for i := 0; i < len(buf); i++ {
-		buf[i] = 0
+	r.indexEntryIndexesPos = uint64(indexEntryIndexesPos)
+	if indexEntryCount == 0 {
+		return r, nil
 	}
-	// read the first index entry pos
-	_, err = rd.ReadAt(buf, indexEntryIndexesPos)
+
+	// read the first index entry pos along with the footer tail (if any)
+	headPos := indexEntryIndexesPos - footerTailSize
+	if headPos < 0 {
+		headPos = 0
+	}
+	head := make([]byte, indexEntryIndexesPos-headPos+8)
+	_, err = rd.ReadAt(head, headPos)
 	if err != nil {
 		return nil, err
 	}
-	firstIndexEntryLenPos := binary.LittleEndian.Uint64(buf)
-	// read the size of the first index entry
-	for i := 0; i < len(buf); i++ {
-		buf[i] = 0
+	firstIndexEntryLenPos := binary.LittleEndian.Uint64(head[len(head)-8:])
+	if !hasFooter && len(head) == footerTailSize+8 && isFooterMagic(head[8:16]) {
+		if _, err := r.readFooter(uint64(indexEntryIndexesPos)); err != nil {
+			return nil, err
+		}
+		if err := r.checkFormatFlags(); err != nil {
+			return nil, err
+		}
+		if r.fixedKeyWidth != 0 {
+			return nil, errors.New("fixed key width layout cannot have index entry positions")
+		}
 	}
+
+	// read the size of the first index entry
+	buf := make([]byte, 8)
 	_, err = rd.ReadAt(buf, int64(firstIndexEntryLenPos))
 	if err != nil {
 		return nil, err
@@ -76,20 +245,60 @@ func BuildReader(rd io.ReaderAt, fileSize uint64) (*Reader, error) {
 	if indexEntrySizeLen < 0 {
 		return nil, errors.Errorf("invalid index entry size varint at %v", firstIndexEntryLenPos)
 	}
-	if indexEntrySize > uint64(maxIndexEntrySize) {
-		return nil, errors.Errorf("invalid index entry size at %v: %v > %v", firstIndexEntryLenPos, indexEntrySize, maxIndexEntrySize)
+	if limit := r.indexEntrySizeLimit(); indexEntrySize > limit {
+		return nil, errors.Errorf("invalid index entry size at %v: %v > %v", firstIndexEntryLenPos, indexEntrySize, limit)
 	}
 	// determine the position of the first IndexEntry entry
 	indexEntryListPos := int64(firstIndexEntryLenPos) - int64(indexEntrySize)
 	if indexEntryListPos < 0 {
 		return nil, errors.Errorf("invalid index entry size at %v: %v > %v", firstIndexEntryLenPos, indexEntrySize, firstIndexEntryLenPos)
 	}
-	return &Reader{
-		rd:                   rd,
-		indexEntryCount:      indexEntryCount,
-		indexEntryIndexesPos: uint64(indexEntryIndexesPos),
-		indexEntryListPos:    uint64(indexEntryListPos),
-	}, nil
+	r.indexEntryCount = indexEntryCount
+	r.indexEntryListPos = uint64(indexEntryListPos)
+	if opts.PreloadPositionsSize != 0 && indexEntryCount*8 <= opts.PreloadPositionsSize {
+		if _, err := r.loadPositions(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// valueSizeLimit returns the maximum size of a value to read.
+func (r *Reader) valueSizeLimit() uint64 {
+	if r.opts.MaxValueSize != 0 {
+		return r.opts.MaxValueSize
+	}
+	return uint64(maxValueSize)
+}
+
+// indexEntrySizeLimit returns the maximum size of an index entry to read.
+func (r *Reader) indexEntrySizeLimit() uint64 {
+	if r.opts.MaxIndexEntrySize != 0 {
+		return r.opts.MaxIndexEntrySize
+	}
+	return uint64(maxIndexEntrySize)
+}
+
+// checkFormatFlags checks the format flags against the reader options.
+func (r *Reader) checkFormatFlags() error {
+	if r.formatFlags&formatFlagEncryptedValues != 0 && !r.opts.AllowEncryptedValues {
+		return ErrEncryptedValues
+	}
+	if r.formatFlags&formatFlagExternalValues != 0 && r.opts.OpenValues == nil {
+		return ErrExternalValues
+	}
+	return nil
+}
+
+// EncryptedValues checks if the file was written with encrypted values.
+func (r *Reader) EncryptedValues() bool {
+	return r.formatFlags&formatFlagEncryptedValues != 0
+}
+
+// ExternalValues checks if the file was written with values stored outside of
+// the file layout. See WriterOptions.ValueWriter.
+func (r *Reader) ExternalValues() bool {
+	return r.formatFlags&formatFlagExternalValues != 0
 }
 
 // ReaderAtSeeker is a ReaderAt and a ReadSeeker.
@@ -129,40 +338,56 @@ func BuildReaderWithFile(f FileReaderAt) (*Reader, error) {
 	return BuildReader(f, uint64(size))
 }
 
+// NewReaderFromBytes constructs a new Reader from a kvfile in memory, for
+// example a file embedded with go:embed.
+//
+// The data must not be modified while the Reader is in use.
+func NewReaderFromBytes(data []byte) (*Reader, error) {
+	return BuildReader(bytes.NewReader(data), uint64(len(data)))
+}
+
 // ReadIndexEntry reads the index entry at the given index.
 func (r *Reader) ReadIndexEntry(indexEntryIdx uint64) (*IndexEntry, error) {
 	if indexEntryIdx >= r.indexEntryCount {
 		return nil, errors.Errorf("out-of-bounds read of index entry: %v > %v", indexEntryIdx, r.indexEntryCount)
 	}
-	// determine the position of the entry in the positions list
-	indexEntryLocPos := r.indexEntryIndexesPos + (8 * indexEntryIdx)
-	// read the entry position
-	buf := make([]byte, 8, 10)
-	_, err := r.rd.ReadAt(buf, int64(indexEntryLocPos))
+	if r.fixedKeyWidth != 0 {
+		return r.readFixedIndexEntry(indexEntryIdx)
+	}
+	indexEntryPos, indexEntrySize, err := r.readIndexEntryBounds(indexEntryIdx)
 	if err != nil {
 		return nil, err
 	}
+	return r.readIndexEntryAt(indexEntryPos, indexEntrySize)
+}
+
+// readIndexEntryBounds reads the position and size of the index entry.
+func (r *Reader) readIndexEntryBounds(indexEntryIdx uint64) (int64, uint64, error) {
 	// determine the position of the index entry size varint
-	indexEntrySizePos := binary.LittleEndian.Uint64(buf)
-	// read the index entry size varint
-	buf = buf[:10]
-	for i := 0; i < len(buf); i++ {
-		buf[i] = 0
+	indexEntrySizePos, err := r.readIndexEntrySizePos(indexEntryIdx)
+	if err != nil {
+		return 0, 0, err
 	}
+	// read the index entry size varint
+	buf := make([]byte, 10)
 	_, err = r.rd.ReadAt(buf, int64(indexEntrySizePos))
 	if err != nil {
-		return nil, err
+		return 0, 0, err
 	}
 	indexEntrySize, indexEntrySizeLen := protobuf_go_lite.ConsumeVarint(buf)
 	if indexEntrySizeLen < 0 {
-		return nil, errors.Errorf("invalid index entry size varint at %v", indexEntrySizePos)
+		return 0, 0, errors.Errorf("invalid index entry size varint at %v", indexEntrySizePos)
 	}
-	if indexEntrySize > uint64(maxIndexEntrySize) {
-		return nil, errors.Errorf("invalid index entry size at %v: %v > %v", indexEntrySizePos, indexEntrySize, maxIndexEntrySize)
+	if limit := r.indexEntrySizeLimit(); indexEntrySize > limit {
+		return 0, 0, errors.Errorf("invalid index entry size at %v: %v > %v", indexEntrySizePos, indexEntrySize, limit)
 	}
-	buf = make([]byte, indexEntrySize)
-	indexEntryPos := int64(indexEntrySizePos) - int64(indexEntrySize)
-	_, err = r.rd.ReadAt(buf, indexEntryPos)
+	return int64(indexEntrySizePos) - int64(indexEntrySize), indexEntrySize, nil
+}
+
+// readIndexEntryAt reads and parses the index entry at the position.
+func (r *Reader) readIndexEntryAt(indexEntryPos int64, indexEntrySize uint64) (*IndexEntry, error) {
+	buf := make([]byte, indexEntrySize)
+	_, err := r.rd.ReadAt(buf, indexEntryPos)
 	if err != nil {
 		return nil, err
 	}
@@ -170,7 +395,7 @@ func (r *Reader) ReadIndexEntry(indexEntryIdx uint64) (*IndexEntry, error) {
 	if err := indexEntry.UnmarshalVT(buf); err != nil {
 		return nil, errors.Errorf("invalid index entry at %v: %v", indexEntryPos, err.Error())
 	}
-	if off := indexEntry.GetOffset(); off > uint64(indexEntryPos) {
+	if off := indexEntry.GetOffset(); off > uint64(indexEntryPos) && !r.ExternalValues() {
 		return nil, errors.Errorf("invalid index entry at %v: offset %v is greater than index entry pos", indexEntryPos, off)
 	}
 	return indexEntry, nil
@@ -181,20 +406,15 @@ func (r *Reader) ReadIndexEntry(indexEntryIdx uint64) (*IndexEntry, error) {
 // If not found, returns nil, idx, err and idx is the index where the searched
 // element would appear if inserted into the list.
 func (r *Reader) SearchIndexEntryWithKey(key []byte) (*IndexEntry, int, error) {
-	var entry *IndexEntry
-	var err error
-
 	// binary search from sort.Search
 	i, j := 0, int(r.indexEntryCount)
 	for i < j {
 		h := int(uint(i+j) >> 1) // avoid overflow when computing h
 
-		entry, err = r.ReadIndexEntry(uint64(h))
+		cmp, entry, err := r.compareIndexEntryKey(uint64(h), key)
 		if err != nil {
 			return nil, h, err
 		}
-
-		cmp := bytes.Compare(entry.GetKey(), key)
 		if cmp == 0 {
 			return entry, h, nil
 		}
@@ -263,6 +483,34 @@ func (r *Reader) SearchIndexEntryWithPrefix(prefix []byte, last bool) (*IndexEnt
 	return nil, i, nil
 }
 
+// SearchPrefixRange returns the range [start, end) of the index entries with
+// the key prefix.
+//
+// If no keys have the prefix, start == end.
+func (r *Reader) SearchPrefixRange(prefix []byte) (start, end uint64, err error) {
+	first, firstIdx, err := r.SearchIndexEntryWithPrefix(prefix, false)
+	if err != nil || first == nil {
+		return uint64(firstIdx), uint64(firstIdx), err
+	}
+	_, lastIdx, err := r.SearchIndexEntryWithPrefix(prefix, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint64(firstIdx), uint64(lastIdx) + 1, nil
+}
+
+// CountPrefix returns the number of index entries with the key prefix.
+//
+// Uses two binary searches instead of scanning the entries. Entries hidden by
+// the reader options are counted, as with Size.
+func (r *Reader) CountPrefix(prefix []byte) (uint64, error) {
+	start, end, err := r.SearchPrefixRange(prefix)
+	if err != nil {
+		return 0, err
+	}
+	return end - start, nil
+}
+
 // Size returns the number of key/value pairs in the store.
 func (r *Reader) Size() uint64 {
 	return r.indexEntryCount
@@ -270,21 +518,57 @@ func (r *Reader) Size() uint64 {
 
 // Exists checks if the given key exists in the store.
 func (r *Reader) Exists(key []byte) (bool, error) {
-	elem, _, err := r.SearchIndexEntryWithKey(key)
+	elem, _, err := r.lookupIndexEntry(key)
+	if elem != nil && r.isEntryHidden(elem) {
+		elem = nil
+	}
 	return elem != nil, err
 }
 
+// IsEntryHidden checks if the entry is hidden from lookups and scans.
+//
+// Tombstones are hidden unless ExposeTombstones is set and expired entries are
+// hidden if FilterExpired is set.
+func (r *Reader) IsEntryHidden(indexEntry *IndexEntry) bool {
+	return r.isEntryHidden(indexEntry)
+}
+
+// isEntryHidden checks if the entry should be hidden from lookups and scans.
+func (r *Reader) isEntryHidden(indexEntry *IndexEntry) bool {
+	if indexEntry.GetTombstone() && !r.opts.ExposeTombstones {
+		return true
+	}
+	return r.isEntryExpired(indexEntry)
+}
+
+// isEntryExpired checks if the entry is hidden because it expired.
+func (r *Reader) isEntryExpired(indexEntry *IndexEntry) bool {
+	if r.opts.FilterExpired {
+		if expires := indexEntry.GetExpiresUnixMs(); expires != 0 {
+			now := r.opts.Now
+			if now == nil {
+				now = time.Now
+			}
+			nowMs := now().UnixMilli()
+			if nowMs >= 0 && uint64(nowMs) >= expires {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // GetValuePositionWithEntry determines the position and length of the value with an entry.
 //
 // Returns -1, 1, nil, -1, nil if not found.
 func (r *Reader) GetValuePositionWithEntry(indexEntry *IndexEntry, indexEntryIdx int) (idx, length int64, err error) {
 	valueOffset := int64(indexEntry.GetOffset())
 	valueSize := int64(indexEntry.GetSize())
-	if valueSize > int64(maxValueSize) {
-		return -1, -1, errors.Errorf("value size %v > max size %v", valueSize, maxValueSize)
+	if limit := r.valueSizeLimit(); indexEntry.GetSize() > limit {
+		return -1, -1, errors.Errorf("value size %v > max size %v", valueSize, limit)
 	}
 	valueEnd := valueOffset + valueSize
-	if valueEnd < valueSize || valueEnd >= int64(r.indexEntryIndexesPos) {
+	if valueEnd < valueSize || (valueEnd > int64(r.indexEntryListPos) && r.formatFlags&formatFlagExternalValues == 0) {
 		return -1, -1, errors.Errorf("value size %v out of bounds", valueSize)
 	}
 	return valueOffset, valueSize, nil
@@ -294,8 +578,8 @@ func (r *Reader) GetValuePositionWithEntry(indexEntry *IndexEntry, indexEntryIdx
 //
 // Returns -1, 1, nil, -1, nil if not found.
 func (r *Reader) GetValuePosition(key []byte) (idx, length int64, indexEntry *IndexEntry, indexEntryIdx int, err error) {
-	indexEntry, indexEntryIdx, err = r.SearchIndexEntryWithKey(key)
-	if indexEntry == nil {
+	indexEntry, indexEntryIdx, err = r.lookupIndexEntry(key)
+	if indexEntry == nil || r.isEntryHidden(indexEntry) {
 		return -1, -1, nil, -1, err
 	}
 	idx, length, err = r.GetValuePositionWithEntry(indexEntry, indexEntryIdx)
@@ -305,12 +589,23 @@ func (r *Reader) GetValuePosition(key []byte) (idx, length int64, indexEntry *In
 // Get looks up the value for the given key.
 // Returns nil, false, nil if not found
 func (r *Reader) Get(key []byte) ([]byte, bool, error) {
+	if r.opts.Hooks.getOnGet() == nil {
+		return r.get(key)
+	}
+	start := time.Now()
+	data, found, err := r.get(key)
+	r.opts.Hooks.OnGet(key, found, int64(len(data)), time.Since(start))
+	return data, found, err
+}
+
+// get looks up the value for the given key.
+func (r *Reader) get(key []byte) ([]byte, bool, error) {
 	valueIdx, valueLen, _, _, err := r.GetValuePosition(key)
 	if err != nil || valueLen < 0 || valueIdx < 0 {
 		return nil, false, err
 	}
 	readBuf := make([]byte, valueLen)
-	_, err = r.rd.ReadAt(readBuf, valueIdx)
+	_, err = r.valueRd.ReadAt(readBuf, valueIdx)
 	if err != nil {
 		return nil, true, err
 	}
@@ -319,6 +614,13 @@ func (r *Reader) Get(key []byte) ([]byte, bool, error) {
 
 // GetWithEntry returns the value for the given index entry.
 func (r *Reader) GetWithEntry(indexEntry *IndexEntry, indexEntryIdx int) ([]byte, error) {
+	return r.readValueWithEntry(indexEntry, indexEntryIdx, nil)
+}
+
+// readValueWithEntry reads the value for the given index entry.
+//
+// Reads into buf if it has enough capacity, otherwise allocates a new buffer.
+func (r *Reader) readValueWithEntry(indexEntry *IndexEntry, indexEntryIdx int, buf []byte) ([]byte, error) {
 	valueIdx, valueLen, err := r.GetValuePositionWithEntry(indexEntry, indexEntryIdx)
 	if err == nil && (valueLen < 0 || valueIdx < 0) {
 		err = errors.New("entry value not found")
@@ -326,8 +628,11 @@ func (r *Reader) GetWithEntry(indexEntry *IndexEntry, indexEntryIdx int) ([]byte
 	if err != nil {
 		return nil, err
 	}
-	readBuf := make([]byte, valueLen)
-	_, err = r.rd.ReadAt(readBuf, valueIdx)
+	if buf == nil || int64(cap(buf)) < valueLen {
+		buf = make([]byte, valueLen)
+	}
+	readBuf := buf[:valueLen]
+	_, err = r.valueRd.ReadAt(readBuf, valueIdx)
 	if err != nil {
 		return nil, err
 	}
@@ -339,10 +644,52 @@ func (r *Reader) GetWithEntry(indexEntry *IndexEntry, indexEntryIdx int) ([]byte
 // Returns number of bytes read, found, and any error.
 // Returns 0, false, nil if not found.
 func (r *Reader) ReadTo(key []byte, to io.Writer) (int, bool, error) {
+	if r.opts.Hooks.getOnGet() == nil {
+		return r.readTo(key, to)
+	}
+	start := time.Now()
+	nr, found, err := r.readTo(key, to)
+	r.opts.Hooks.OnGet(key, found, int64(nr), time.Since(start))
+	return nr, found, err
+}
+
+// readTo reads the value for the given key to the writer.
+func (r *Reader) readTo(key []byte, to io.Writer) (int, bool, error) {
 	valueIdx, valueLen, _, _, err := r.GetValuePosition(key)
 	if err != nil || valueLen < 0 || valueIdx < 0 {
 		return 0, false, err
 	}
+	nr, err := r.readValueTo(valueIdx, valueLen, to)
+	if err != nil {
+		return 0, true, err
+	}
+	return int(nr), true, nil
+}
+
+// ReadToWithEntry reads the value for the given index entry to the writer.
+//
+// Returns number of bytes read and any error.
+func (r *Reader) ReadToWithEntry(indexEntry *IndexEntry, indexEntryIdx int, to io.Writer) (int64, error) {
+	valueIdx, valueLen, err := r.GetValuePositionWithEntry(indexEntry, indexEntryIdx)
+	if err != nil {
+		return 0, err
+	}
+	return r.readValueTo(valueIdx, valueLen, to)
+}
+
+// GetValueReaderWithEntry returns a reader for the value of the index entry.
+//
+// The value is read from the underlying ReaderAt as the reader is read.
+func (r *Reader) GetValueReaderWithEntry(indexEntry *IndexEntry, indexEntryIdx int) (*io.SectionReader, error) {
+	valueIdx, valueLen, err := r.GetValuePositionWithEntry(indexEntry, indexEntryIdx)
+	if err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(r.valueRd, valueIdx, valueLen), nil
+}
+
+// readValueTo reads the value at the position to the writer in chunks.
+func (r *Reader) readValueTo(valueIdx, valueLen int64, to io.Writer) (int64, error) {
 	readBufSize := 2048
 	if vl := int(valueLen); vl < readBufSize {
 		readBufSize = vl
@@ -355,26 +702,41 @@ func (r *Reader) ReadTo(key []byte, to io.Writer) (int, bool, error) {
 		if len(readBuf) > remaining {
 			readBuf = readBuf[:remaining]
 		}
-		nread, err := r.rd.ReadAt(readBuf, pos)
+		nread, err := r.valueRd.ReadAt(readBuf, pos)
 		if err != nil {
-			return 0, true, err
+			return 0, err
 		}
 		var nw int
 		for nw < nread && nw < len(readBuf) {
 			njw, err := to.Write(readBuf[nw:])
 			if err != nil {
-				return 0, true, err
+				return 0, err
 			}
 			nw += njw
 		}
 		pos += int64(nread)
 		nr += int64(nw)
 	}
-	return int(nr), true, nil
+	return nr, nil
 }
 
 // ScanPrefixEntries iterates over entries with the given key prefix.
 func (r *Reader) ScanPrefixEntries(prefix []byte, cb func(indexEntry *IndexEntry, indexEntryIdx int) error) error {
+	if r.opts.Hooks.getOnScan() == nil {
+		return r.scanPrefixEntries(prefix, cb)
+	}
+	var entries int
+	start := time.Now()
+	err := r.scanPrefixEntries(prefix, func(indexEntry *IndexEntry, indexEntryIdx int) error {
+		entries++
+		return cb(indexEntry, indexEntryIdx)
+	})
+	r.opts.Hooks.OnScan(entries, 0, time.Since(start))
+	return err
+}
+
+// scanPrefixEntries iterates over entries with the given key prefix.
+func (r *Reader) scanPrefixEntries(prefix []byte, cb func(indexEntry *IndexEntry, indexEntryIdx int) error) error {
 	// Find the first key with the prefix.
 	firstMatch, firstIndex, err := r.SearchIndexEntryWithPrefix(prefix, false)
 	if err != nil || firstMatch == nil {
@@ -383,8 +745,10 @@ func (r *Reader) ScanPrefixEntries(prefix []byte, cb func(indexEntry *IndexEntry
 	}
 
 	// Emit the first key.
-	if err := cb(firstMatch, firstIndex); err != nil {
-		return err
+	if !r.isEntryHidden(firstMatch) {
+		if err := cb(firstMatch, firstIndex); err != nil {
+			return err
+		}
 	}
 
 	// Iterate until the prefix no longer matches.
@@ -397,6 +761,9 @@ func (r *Reader) ScanPrefixEntries(prefix []byte, cb func(indexEntry *IndexEntry
 		if !bytes.HasPrefix(indexEntry.GetKey(), prefix) {
 			break
 		}
+		if r.isEntryHidden(indexEntry) {
+			continue
+		}
 		if err := cb(indexEntry, i); err != nil {
 			return err
 		}
@@ -414,13 +781,99 @@ func (r *Reader) ScanPrefixKeys(prefix []byte, cb func(key []byte) error) error
 
 // ScanPrefix iterates over key/value pairs with a prefix.
 func (r *Reader) ScanPrefix(prefix []byte, cb func(key, value []byte) error) error {
-	return r.ScanPrefixEntries(prefix, func(indexEntry *IndexEntry, indexEntryIdx int) error {
-		data, err := r.GetWithEntry(indexEntry, indexEntryIdx)
+	return r.scanPrefix(prefix, false, cb)
+}
+
+// ScanPrefixReuse iterates over key/value pairs with a prefix, reading each
+// value into a buffer reused across the callbacks.
+//
+// Avoids allocating a buffer for each value when scanning many entries. The
+// value is only valid until the callback returns: the callback must copy the
+// value to retain it.
+func (r *Reader) ScanPrefixReuse(prefix []byte, cb func(key, value []byte) error) error {
+	return r.scanPrefix(prefix, true, cb)
+}
+
+// scanPrefix iterates over key/value pairs with a prefix.
+//
+// If reuse is set the values are read into a buffer reused across callbacks.
+func (r *Reader) scanPrefix(prefix []byte, reuse bool, cb func(key, value []byte) error) error {
+	onScan := r.opts.Hooks.getOnScan()
+	var start time.Time
+	if onScan != nil {
+		start = time.Now()
+	}
+	var entries int
+	var nbytes int64
+	var buf []byte
+	err := r.scanPrefixEntries(prefix, func(indexEntry *IndexEntry, indexEntryIdx int) error {
+		if !reuse {
+			buf = nil
+		}
+		data, err := r.readValueWithEntry(indexEntry, indexEntryIdx, buf)
 		if err != nil {
 			return err
 		}
+		buf = data
+		entries++
+		nbytes += int64(len(data))
 		return cb(indexEntry.GetKey(), data)
 	})
+	if onScan != nil {
+		onScan(entries, nbytes, time.Since(start))
+	}
+	return err
+}
+
+// ScanRangeEntries iterates over entries with keys in the range [start, end).
+//
+// If start is empty the range begins at the first key.
+// If end is empty the range continues to the last key.
+func (r *Reader) ScanRangeEntries(start, end []byte, cb func(indexEntry *IndexEntry, indexEntryIdx int) error) error {
+	if r.opts.Hooks.getOnScan() == nil {
+		return r.scanRangeEntries(start, end, cb)
+	}
+	var entries int
+	startTime := time.Now()
+	err := r.scanRangeEntries(start, end, func(indexEntry *IndexEntry, indexEntryIdx int) error {
+		entries++
+		return cb(indexEntry, indexEntryIdx)
+	})
+	r.opts.Hooks.OnScan(entries, 0, time.Since(startTime))
+	return err
+}
+
+// scanRangeEntries iterates over entries with keys in the range [start, end).
+func (r *Reader) scanRangeEntries(start, end []byte, cb func(indexEntry *IndexEntry, indexEntryIdx int) error) error {
+	// Find the first key at or after start.
+	var firstIndex int
+	if len(start) != 0 {
+		var err error
+		_, firstIndex, err = r.SearchIndexEntryWithKey(start)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Iterate until the end of the range.
+	size := int(r.Size())
+	for i := firstIndex; i < size; i++ {
+		indexEntry, err := r.ReadIndexEntry(uint64(i))
+		if err != nil {
+			return err
+		}
+		if len(end) != 0 && bytes.Compare(indexEntry.GetKey(), end) >= 0 {
+			break
+		}
+		if r.isEntryHidden(indexEntry) {
+			continue
+		}
+		if err := cb(indexEntry, i); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // GetValueSize looks up the size of the value for the given key without reading the value.
@@ -2,9 +2,15 @@ package kvfile
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"io"
+	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -263,3 +269,603 @@ func TestWriter(t *testing.T) {
 		t.Fatalf("search prefix last=false failed: %v %v", prefixIdx, string(prefixEntry.GetKey()))
 	}
 }
+
+// plainReader hides the io.WriterTo of the underlying reader.
+type plainReader struct {
+	rd io.Reader
+}
+
+// Read reads from the underlying reader.
+func (p *plainReader) Read(b []byte) (int, error) {
+	return p.rd.Read(b)
+}
+
+// plainWriter hides the io.ReaderFrom of the underlying writer.
+type plainWriter struct {
+	w io.Writer
+}
+
+// Write writes to the underlying writer.
+func (p *plainWriter) Write(b []byte) (int, error) {
+	return p.w.Write(b)
+}
+
+func TestWriterCopyPaths(t *testing.T) {
+	vals := [][]byte{
+		[]byte("small"),
+		nil,
+		bytes.Repeat([]byte("large"), 20000),
+		[]byte("x"),
+	}
+	valuePath := filepath.Join(t.TempDir(), "value")
+	if err := os.WriteFile(valuePath, vals[2], 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	sources := map[string]func(t *testing.T, val []byte) io.Reader{
+		"bytes": func(t *testing.T, val []byte) io.Reader {
+			return bytes.NewReader(val)
+		},
+		"plain": func(t *testing.T, val []byte) io.Reader {
+			return &plainReader{rd: bytes.NewReader(val)}
+		},
+		"one-byte": func(t *testing.T, val []byte) io.Reader {
+			return iotest.OneByteReader(bytes.NewReader(val))
+		},
+		"file": func(t *testing.T, val []byte) io.Reader {
+			if !bytes.Equal(val, vals[2]) {
+				return bytes.NewReader(val)
+			}
+			f, err := os.Open(valuePath)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			t.Cleanup(func() { _ = f.Close() })
+			return f
+		},
+	}
+	for name, source := range sources {
+		for _, readerFrom := range []bool{true, false} {
+			for _, digest := range []bool{true, false} {
+				var buf bytes.Buffer
+				var out io.Writer = &buf
+				if !readerFrom {
+					out = &plainWriter{w: &buf}
+				}
+				var opts WriterOptions
+				if digest {
+					opts.ContentDigest = sha256.New
+				}
+				wr, err := NewWriterWithOptions(out, opts)
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+				for i, val := range vals {
+					if err := wr.WriteValue([]byte("key-"+strconv.Itoa(i)), source(t, val)); err != nil {
+						t.Fatal(err.Error())
+					}
+				}
+				if err := wr.Close(); err != nil {
+					t.Fatal(err.Error())
+				}
+				if wr.GetPos() != uint64(buf.Len()) {
+					t.Fatalf("%v: position %v does not match the size %v", name, wr.GetPos(), buf.Len())
+				}
+				rdr, err := NewReaderFromBytes(buf.Bytes())
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+				for i, val := range vals {
+					data, found, err := rdr.Get([]byte("key-" + strconv.Itoa(i)))
+					if err != nil || !found || !bytes.Equal(data, val) {
+						t.Fatalf("%v: unexpected value for key %v: %v %v", name, i, found, err)
+					}
+				}
+				if digest {
+					expected, err := ContentDigest(rdr, sha256.New)
+					if err != nil {
+						t.Fatal(err.Error())
+					}
+					written, err := wr.ContentDigest()
+					if err != nil {
+						t.Fatal(err.Error())
+					}
+					if !bytes.Equal(written, expected) {
+						t.Fatalf("%v: content digest mismatch", name)
+					}
+				}
+			}
+		}
+	}
+}
+
+// benchmarkWriteValue writes 16 small values to a new Writer per iteration.
+func benchmarkWriteValue(b *testing.B, newValue func(i int) io.Reader) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		wr := NewWriter(io.Discard)
+		for j := 0; j < 16; j++ {
+			if err := wr.WriteValue([]byte("key-"+strconv.Itoa(j)), newValue(j)); err != nil {
+				b.Fatal(err.Error())
+			}
+		}
+		if err := wr.Close(); err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+}
+
+func BenchmarkWriteValueBytesReader(b *testing.B) {
+	value := []byte("small value")
+	benchmarkWriteValue(b, func(i int) io.Reader {
+		return bytes.NewReader(value)
+	})
+}
+
+func BenchmarkWriteValueFile(b *testing.B) {
+	valuePath := filepath.Join(b.TempDir(), "value")
+	if err := os.WriteFile(valuePath, []byte("small value"), 0o644); err != nil {
+		b.Fatal(err.Error())
+	}
+	f, err := os.Open(valuePath)
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+	defer f.Close()
+	benchmarkWriteValue(b, func(i int) io.Reader {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			b.Fatal(err.Error())
+		}
+		return f
+	})
+}
+
+func TestWriteValueFrom(t *testing.T) {
+	keys := []string{"test-1", "test-2", "test-3"}
+	writeValue := func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write(append([]byte("val-"), key...))
+		return uint64(nw), err
+	}
+	for _, opts := range []WriterOptions{{}, {ValueHeaders: true, ContentDigest: sha256.New}} {
+		var buf bytes.Buffer
+		wr, err := NewWriterWithOptions(&buf, opts)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		for _, key := range keys {
+			if err := wr.WriteValueFrom([]byte(key), writeValue); err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+		if err := wr.Close(); err != nil {
+			t.Fatal(err.Error())
+		}
+		rdr, err := NewReaderFromBytes(buf.Bytes())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		for _, key := range keys {
+			val, found, err := rdr.Get([]byte(key))
+			if err != nil || !found || string(val) != "val-"+key {
+				t.Fatalf("unexpected value for %s: %v %q %v", key, found, string(val), err)
+			}
+		}
+
+		// the returned count must match the written bytes
+		wr, err = NewWriterWithOptions(&bytes.Buffer{}, opts)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		err = wr.WriteValueFrom([]byte("test-1"), func(wr io.Writer, key []byte) (uint64, error) {
+			nw, err := writeValue(wr, key)
+			return nw + 1, err
+		})
+		if err == nil {
+			t.Fatal("expected write count mismatch error")
+		}
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	var buf bytes.Buffer
+	start := time.UnixMilli(1700000000000)
+
+	wr := NewWriter(&buf)
+	if err := wr.WriteValue([]byte("test-1"), bytes.NewReader([]byte("val-1"))); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.WriteValueWithExpiry([]byte("test-2"), bytes.NewReader([]byte("val-2")), start.Add(time.Minute)); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.WriteValueWithExpiry([]byte("test-3"), bytes.NewReader([]byte("val-3")), start.Add(time.Hour)); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	now := start
+	bufReader := bytes.NewReader(buf.Bytes())
+	rdr, err := BuildReaderWithOptions(bufReader, uint64(buf.Len()), ReaderOptions{
+		FilterExpired: true,
+		Now:           func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	scanKeys := func() []string {
+		var keys []string
+		err := rdr.ScanPrefixKeys([]byte("test-"), func(key []byte) error {
+			keys = append(keys, string(key))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return keys
+	}
+
+	// before expiry: all keys are visible
+	if keys := scanKeys(); len(keys) != 3 {
+		t.Fatalf("expected 3 keys before expiry: %v", keys)
+	}
+	data, found, err := rdr.Get([]byte("test-2"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !found || string(data) != "val-2" {
+		t.Fatalf("expected test-2 to exist before expiry: %v %s", found, string(data))
+	}
+
+	// cross the expiry boundary of test-2
+	now = start.Add(time.Minute)
+	_, found, err = rdr.Get([]byte("test-2"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if found {
+		t.Fatal("expected test-2 to be expired")
+	}
+	keyExists, err := rdr.Exists([]byte("test-2"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if keyExists {
+		t.Fatal("expected expired key to not exist")
+	}
+	if keys := scanKeys(); len(keys) != 2 || keys[0] != "test-1" || keys[1] != "test-3" {
+		t.Fatalf("unexpected keys after expiry: %v", keys)
+	}
+
+	// entries without an expiry never expire
+	now = start.Add(time.Hour * 24 * 365)
+	if keys := scanKeys(); len(keys) != 1 || keys[0] != "test-1" {
+		t.Fatalf("unexpected keys after all expired: %v", keys)
+	}
+
+	// filtering is disabled by default
+	rdr, err = BuildReader(bufReader, uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if keys := scanKeys(); len(keys) != 3 {
+		t.Fatalf("expected 3 keys without filtering: %v", keys)
+	}
+	entry, _, err := rdr.SearchIndexEntryWithKey([]byte("test-2"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if entry.GetExpiresUnixMs() != uint64(start.Add(time.Minute).UnixMilli()) {
+		t.Fatalf("unexpected expiry: %v", entry.GetExpiresUnixMs())
+	}
+}
+
+func TestEntryMeta(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	if err := wr.WriteValueBytesMeta([]byte("test-1"), []byte("val-1"), []byte("text/plain")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.WriteValue([]byte("test-2"), bytes.NewReader([]byte("val-2"))); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.WriteValueBytesMeta([]byte("test-3"), []byte("val-3"), []byte{0x01}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// metadata over the limit
+	if err := wr.WriteValueBytesMeta([]byte("test-4"), nil, make([]byte, maxMetaSize+1)); err == nil {
+		t.Fatal("expected error for metadata over the size limit")
+	}
+	// index entry over the limit
+	longKey := bytes.Repeat([]byte("k"), maxIndexEntrySize-maxMetaSize/2)
+	if err := wr.WriteValueBytesMeta(longKey, nil, make([]byte, maxMetaSize)); err == nil {
+		t.Fatal("expected error for index entry over the size limit")
+	}
+
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	bufReader := bytes.NewReader(buf.Bytes())
+	rdr, err := BuildReader(bufReader, uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if rdr.Size() != 3 {
+		t.Fatalf("expected 3 entries: %v", rdr.Size())
+	}
+
+	entry, _, err := rdr.SearchIndexEntryWithKey([]byte("test-1"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(entry.GetMeta()) != "text/plain" {
+		t.Fatalf("unexpected metadata: %v", entry.GetMeta())
+	}
+
+	expected := [][]byte{[]byte("text/plain"), nil, {0x01}}
+	var i int
+	err = rdr.ScanPrefixEntries([]byte("test-"), func(indexEntry *IndexEntry, indexEntryIdx int) error {
+		if !bytes.Equal(indexEntry.GetMeta(), expected[i]) {
+			return errors.Errorf("unexpected metadata for %s: %v", string(indexEntry.GetKey()), indexEntry.GetMeta())
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	data, found, err := rdr.Get([]byte("test-3"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !found || string(data) != "val-3" {
+		t.Fatalf("unexpected value: %v %s", found, string(data))
+	}
+}
+
+func TestScanRange(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		if err := wr.WriteValue([]byte(key), bytes.NewReader([]byte("val-"+key))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tests := []struct {
+		start, end string
+		expected   string
+	}{
+		{"", "", "abcd"},
+		{"b", "d", "bc"},
+		{"bb", "", "cd"},
+		{"", "c", "ab"},
+		{"c", "c", ""},
+		{"d", "b", ""},
+		{"e", "", ""},
+	}
+	for _, tc := range tests {
+		var keys []byte
+		err := rdr.ScanRangeEntries([]byte(tc.start), []byte(tc.end), func(indexEntry *IndexEntry, indexEntryIdx int) error {
+			keys = append(keys, indexEntry.GetKey()...)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if string(keys) != tc.expected {
+			t.Fatalf("range [%q, %q): expected %q got %q", tc.start, tc.end, tc.expected, keys)
+		}
+	}
+}
+
+func TestWriterProgress(t *testing.T) {
+	var entries, valueBytes []uint64
+	var buf bytes.Buffer
+	wr, err := NewWriterWithOptions(&buf, WriterOptions{
+		ValueHeaders: true,
+		Progress: func(e, b uint64) {
+			entries, valueBytes = append(entries, e), append(valueBytes, b)
+		},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, val := range []string{"a", "bcd", ""} {
+		if err := wr.WriteValue([]byte("key-"+val), bytes.NewReader([]byte(val))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !slices.Equal(entries, []uint64{1, 2, 3}) || !slices.Equal(valueBytes, []uint64{1, 4, 4}) {
+		t.Fatalf("unexpected progress: %v %v", entries, valueBytes)
+	}
+}
+
+func TestCountPrefix(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	for _, key := range []string{"a", "a/1", "a/2", "ab", "b/1", "c"} {
+		if err := wr.WriteValue([]byte(key), bytes.NewReader(nil)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tests := []struct {
+		prefix     string
+		start, end uint64
+	}{
+		{"", 0, 6},
+		{"a", 0, 4},
+		{"a/", 1, 3},
+		{"b", 4, 5},
+		{"bb", 5, 5},
+		{"d", 6, 6},
+		{"0", 0, 0},
+	}
+	for _, tc := range tests {
+		start, end, err := rdr.SearchPrefixRange([]byte(tc.prefix))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if start != tc.start || end != tc.end {
+			t.Fatalf("prefix %q: expected [%v, %v) got [%v, %v)", tc.prefix, tc.start, tc.end, start, end)
+		}
+		count, err := rdr.CountPrefix([]byte(tc.prefix))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if count != tc.end-tc.start {
+			t.Fatalf("prefix %q: unexpected count %v", tc.prefix, count)
+		}
+	}
+}
+
+// writeScanReuseTestFile writes n entries with value sizes varying between
+// consecutive entries. The value of entry i is valueSize(i) bytes of byte(i).
+func writeScanReuseTestFile(t testing.TB, n int) []byte {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	for i := 0; i < n; i++ {
+		key := []byte("key-" + strconv.Itoa(100000+i))
+		if err := wr.WriteValue(key, bytes.NewReader(bytes.Repeat([]byte{byte(i)}, scanReuseValueSize(i)))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	return buf.Bytes()
+}
+
+// scanReuseValueSize returns the size of the value of entry i.
+func scanReuseValueSize(i int) int {
+	switch i % 4 {
+	case 0:
+		return 100000
+	case 1:
+		return 0
+	case 2:
+		return 3
+	default:
+		return 5000 + i
+	}
+}
+
+func TestScanPrefixReuse(t *testing.T) {
+	n := 40
+	rdr, err := NewReaderFromBytes(writeScanReuseTestFile(t, n))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var i int
+	var prev []byte
+	err = rdr.ScanPrefixReuse([]byte("key-"), func(key, value []byte) error {
+		expected := bytes.Repeat([]byte{byte(i)}, scanReuseValueSize(i))
+		if string(key) != "key-"+strconv.Itoa(100000+i) || !bytes.Equal(value, expected) {
+			t.Fatalf("entry %v: unexpected key %q or value of %v bytes", i, key, len(value))
+		}
+		// the buffer grows to the largest value and is then reused
+		if i > 0 && len(value) != 0 && len(prev) != 0 && &value[0] != &prev[0] {
+			t.Fatalf("entry %v: expected the buffer to be reused", i)
+		}
+		prev = value[:cap(value)]
+		i++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if i != n {
+		t.Fatalf("expected %v entries but got %v", n, i)
+	}
+}
+
+func benchmarkScanPrefix(b *testing.B, reuse bool) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	for i := 0; i < 10000; i++ {
+		key := []byte("key-" + strconv.Itoa(100000+i))
+		if err := wr.WriteValue(key, bytes.NewReader(bytes.Repeat([]byte{byte(i)}, 256))); err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		b.Fatal(err.Error())
+	}
+	rdr, err := NewReaderFromBytes(buf.Bytes())
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+	scan := rdr.ScanPrefix
+	if reuse {
+		scan = rdr.ScanPrefixReuse
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := scan([]byte("key-"), func(key, value []byte) error {
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+}
+
+func BenchmarkScanPrefix(b *testing.B) {
+	benchmarkScanPrefix(b, false)
+}
+
+func BenchmarkScanPrefixReuse(b *testing.B) {
+	benchmarkScanPrefix(b, true)
+}
+
+func TestReaderSizeLimits(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	if err := wr.WriteValue([]byte("test-key"), bytes.NewReader(make([]byte, 100))); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	rdr, err := BuildReaderWithOptions(bytes.NewReader(buf.Bytes()), uint64(buf.Len()), ReaderOptions{MaxValueSize: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, _, err := rdr.Get([]byte("test-key")); err == nil {
+		t.Fatal("expected value size limit error")
+	}
+	rdr, err = BuildReaderWithOptions(bytes.NewReader(buf.Bytes()), uint64(buf.Len()), ReaderOptions{MaxValueSize: 100})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if val, found, err := rdr.Get([]byte("test-key")); err != nil || !found || len(val) != 100 {
+		t.Fatalf("unexpected value: %v %v %v", len(val), found, err)
+	}
+
+	if _, err := BuildReaderWithOptions(bytes.NewReader(buf.Bytes()), uint64(buf.Len()), ReaderOptions{MaxIndexEntrySize: 4}); err == nil {
+		t.Fatal("expected index entry size limit error")
+	}
+}
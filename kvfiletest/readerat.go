@@ -0,0 +1,208 @@
+package kvfiletest
+
+import (
+	"bytes"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+)
+
+// ReaderAtFactory builds an io.ReaderAt backed by the data.
+//
+// The ReaderAt must return the data and must not modify it.
+type ReaderAtFactory func(data []byte) io.ReaderAt
+
+// concurrentReaders is the number of goroutines in the concurrent subtest.
+const concurrentReaders = 8
+
+// BuildCorpusFile writes the key/value pairs to a kvfile in memory.
+//
+// The values are written in key order so the file is deterministic.
+func BuildCorpusFile(vals map[string][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	wr := kvfile.NewWriter(&buf)
+	for _, key := range slices.Sorted(maps.Keys(vals)) {
+		if err := wr.WriteValue([]byte(key), bytes.NewReader(vals[key])); err != nil {
+			return nil, err
+		}
+	}
+	if err := wr.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// TestReaderAt checks that ReaderAts built with the factory follow the
+// io.ReaderAt contract and can back a kvfile.Reader.
+//
+// The reference files are written from StoreCorpus, from a corpus of empty
+// values, and with no entries. Each is checked with raw reads around the end
+// of the file, with TestStore on a Reader built with BuildReader, and with
+// concurrent reads.
+func TestReaderAt(t *testing.T, factory ReaderAtFactory) {
+	zeroVals := map[string][]byte{"a": {}, "b": nil, "c/d": {}}
+	files := []struct {
+		name string
+		vals map[string][]byte
+	}{
+		{"Corpus", StoreCorpus()},
+		{"EmptyValues", zeroVals},
+		{"Empty", map[string][]byte{}},
+	}
+	for _, file := range files {
+		data, err := BuildCorpusFile(file.vals)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		t.Run("ReadAt"+file.name, func(t *testing.T) {
+			if err := CheckReadAt(factory(bytes.Clone(data)), data); err != nil {
+				t.Fatal(err.Error())
+			}
+		})
+	}
+
+	t.Run("Reader", func(t *testing.T) {
+		TestStore(t, func(t *testing.T, vals map[string][]byte) kvfile.Store {
+			return buildFactoryReader(t, factory, vals)
+		})
+	})
+
+	t.Run("EmptyValues", func(t *testing.T) {
+		rd := buildFactoryReader(t, factory, zeroVals)
+		for key := range zeroVals {
+			value, found, err := rd.Get([]byte(key))
+			if err != nil || !found || len(value) != 0 {
+				t.Fatalf("Get(%q) for an empty value returned %d bytes, %v, %v", key, len(value), found, err)
+			}
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		vals := StoreCorpus()
+		data, err := BuildCorpusFile(vals)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		rdAt := factory(bytes.Clone(data))
+		rd, err := kvfile.BuildReader(rdAt, uint64(len(data)))
+		if err != nil {
+			t.Fatalf("BuildReader failed: %v", err)
+		}
+		var wg sync.WaitGroup
+		for i := range concurrentReaders {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for key, val := range vals {
+					value, found, err := rd.Get([]byte(key))
+					if err != nil || !found || !bytes.Equal(value, val) {
+						t.Errorf("concurrent Get(%q) returned %d bytes, %v, %v: expected %d bytes", key, len(value), found, err, len(val))
+						return
+					}
+				}
+				// overlapping raw reads at different offsets in each goroutine
+				buf := make([]byte, 61)
+				for off := i; off+len(buf) <= len(data); off += 97 {
+					n, err := rdAt.ReadAt(buf, int64(off))
+					if n != len(buf) || !bytes.Equal(buf, data[off:off+len(buf)]) {
+						t.Errorf("concurrent ReadAt(%d bytes, off %d) returned the wrong data: %d bytes, %v", len(buf), off, n, err)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+// buildFactoryReader writes the key/value pairs to a kvfile and builds a
+// Reader with a ReaderAt from the factory.
+func buildFactoryReader(t *testing.T, factory ReaderAtFactory, vals map[string][]byte) *kvfile.Reader {
+	data, err := BuildCorpusFile(vals)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rd, err := kvfile.BuildReader(factory(data), uint64(len(data)))
+	if err != nil {
+		t.Fatalf("BuildReader with a %d byte file failed: %v", len(data), err)
+	}
+	return rd
+}
+
+// CheckReadAt checks that ReadAt returns the data and follows the io.ReaderAt
+// contract, returning an error describing the first violation.
+//
+// The checks read every range ending at or near the end of the data, ranges
+// at the start and middle, and ranges crossing and past the end.
+func CheckReadAt(rd io.ReaderAt, data []byte) error {
+	size := int64(len(data))
+	var offsets []int64
+	for _, off := range []int64{0, 1, size / 2, size - 8, size - 2, size - 1, size, size + 1, size + 4096} {
+		if off >= 0 {
+			offsets = append(offsets, off)
+		}
+	}
+	lengths := []int64{0, 1, 2, 7, 8, 9, 64, 4096, size}
+	for _, off := range offsets {
+		for _, length := range lengths {
+			if err := checkReadAtRange(rd, data, off, length); err != nil {
+				return err
+			}
+		}
+		// the read ending exactly at the end of the data
+		if off < size {
+			if err := checkReadAtRange(rd, data, off, size-off); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := rd.ReadAt(make([]byte, 1), -1); err == nil {
+		return errors.New("ReadAt with a negative offset must return an error")
+	}
+	return nil
+}
+
+// checkReadAtRange checks a single ReadAt call.
+func checkReadAtRange(rd io.ReaderAt, data []byte, off, length int64) error {
+	size := int64(len(data))
+	buf := make([]byte, length)
+	n, err := rd.ReadAt(buf, off)
+	desc := "ReadAt(" + strconv.FormatInt(length, 10) + " bytes, off " + strconv.FormatInt(off, 10) + ") with size " + strconv.FormatInt(size, 10)
+	if n < 0 || int64(n) > length {
+		return errors.Errorf("%s returned n = %d out of range", desc, n)
+	}
+	expected := max(min(length, size-off), 0)
+	if int64(n) != expected {
+		if int64(n) < expected && err != nil {
+			return errors.Errorf("%s returned %d bytes: expected %d bytes before the end of the data: %v", desc, n, expected, err)
+		}
+		return errors.Errorf("%s returned %d bytes and %v: expected %d bytes", desc, n, err, expected)
+	}
+	if n != 0 && !bytes.Equal(buf[:n], data[off:off+int64(n)]) {
+		return errors.Errorf("%s returned the wrong data", desc)
+	}
+	if length == 0 {
+		return nil
+	}
+	if int64(n) < length {
+		// io.ReaderAt must return a non-nil error if n < len(p)
+		if err == nil {
+			return errors.Errorf("%s returned a short read of %d bytes without an error: must return io.EOF", desc, n)
+		}
+		if off <= size && err != io.EOF {
+			return errors.Errorf("%s returned %d bytes at the end of the data with %v: must return io.EOF", desc, n, err)
+		}
+		return nil
+	}
+	// a full read may return nil or io.EOF
+	if err != nil && err != io.EOF {
+		return errors.Errorf("%s returned all %d bytes with an error: %v", desc, n, err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package kvfiletest
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+)
+
+func TestBytesReaderAt(t *testing.T) {
+	TestReaderAt(t, func(data []byte) io.ReaderAt {
+		return bytes.NewReader(data)
+	})
+}
+
+func TestCachingReaderAt(t *testing.T) {
+	TestReaderAt(t, func(data []byte) io.ReaderAt {
+		return kvfile.NewCachingReaderAt(bytes.NewReader(data), 64, 8)
+	})
+}
+
+func TestSectionReaderAt(t *testing.T) {
+	TestReaderAt(t, func(data []byte) io.ReaderAt {
+		// the data in the middle of a larger buffer
+		padded := append(append(bytes.Repeat([]byte{0xee}, 100), data...), bytes.Repeat([]byte{0xee}, 100)...)
+		return io.NewSectionReader(bytes.NewReader(padded), 100, int64(len(data)))
+	})
+}
+
+// shortReaderAt returns at most max bytes from each read without an error.
+type shortReaderAt struct {
+	rd  io.ReaderAt
+	max int
+}
+
+func (s *shortReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) > s.max {
+		p = p[:s.max]
+	}
+	return s.rd.ReadAt(p, off)
+}
+
+// noEOFReaderAt returns nil instead of io.EOF.
+type noEOFReaderAt struct {
+	rd io.ReaderAt
+}
+
+func (n *noEOFReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	nr, err := n.rd.ReadAt(p, off)
+	if err == io.EOF {
+		err = nil
+	}
+	return nr, err
+}
+
+// unexpectedEOFReaderAt returns io.ErrUnexpectedEOF instead of io.EOF.
+type unexpectedEOFReaderAt struct {
+	rd io.ReaderAt
+}
+
+func (u *unexpectedEOFReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	nr, err := u.rd.ReadAt(p, off)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return nr, err
+}
+
+func TestCheckReadAt(t *testing.T) {
+	data, err := BuildCorpusFile(StoreCorpus())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := CheckReadAt(bytes.NewReader(data), data); err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, tc := range []struct {
+		rd       io.ReaderAt
+		expected string
+	}{
+		{&shortReaderAt{rd: bytes.NewReader(data), max: 4}, "expected 7 bytes"},
+		{&noEOFReaderAt{rd: bytes.NewReader(data)}, "without an error: must return io.EOF"},
+		{&unexpectedEOFReaderAt{rd: bytes.NewReader(data)}, "unexpected EOF: must return io.EOF"},
+		{bytes.NewReader(data[:len(data)-1]), "before the end of the data: EOF"},
+		{bytes.NewReader(append([]byte{data[0] ^ 1}, data[1:]...)), "returned the wrong data"},
+	} {
+		err := CheckReadAt(tc.rd, data)
+		if err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Fatalf("expected %q in the error: %v", tc.expected, err)
+		}
+	}
+}
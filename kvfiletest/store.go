@@ -0,0 +1,188 @@
+package kvfiletest
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+)
+
+// StoreFactory builds a Store containing the key/value pairs.
+type StoreFactory func(t *testing.T, vals map[string][]byte) kvfile.Store
+
+// StoreCorpus returns the key/value pairs used by TestStore.
+//
+// The keys include prefixes of other keys, binary bytes, and keys sorting
+// around separators. The values include empty, binary, and large values.
+func StoreCorpus() map[string][]byte {
+	binary := make([]byte, 256)
+	for i := range binary {
+		binary[i] = byte(i)
+	}
+	return map[string][]byte{
+		"a":                             []byte("value-a"),
+		"a/b":                           []byte("value-a/b"),
+		"a/b/c":                         {},
+		"a/bc":                          []byte("value-a/bc"),
+		"a\x00":                         []byte("nul"),
+		"a\xff":                         []byte("high"),
+		"b":                             binary,
+		"bin\x00\x01\xfe\xff":           []byte("binary key"),
+		"large":                         bytes.Repeat([]byte("0123456789abcdef"), 4096),
+		"z/" + strings.Repeat("k", 512): []byte("long key"),
+	}
+}
+
+// TestStore checks that Stores built with the factory satisfy the invariants
+// of the Store interface.
+//
+// The factory is called with StoreCorpus and with an empty map.
+func TestStore(t *testing.T, factory StoreFactory) {
+	vals := StoreCorpus()
+	store := factory(t, vals)
+	keys := make([]string, 0, len(vals))
+	for key := range vals {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	t.Run("Get", func(t *testing.T) {
+		for _, key := range keys {
+			value, found, err := store.Get([]byte(key))
+			if err != nil {
+				t.Fatalf("Get(%q) returned an error: %v", key, err)
+			}
+			if !found {
+				t.Fatalf("Get(%q) did not find a key in the store", key)
+			}
+			if !bytes.Equal(value, vals[key]) {
+				t.Fatalf("Get(%q) returned a value of %d bytes, expected %d bytes", key, len(value), len(vals[key]))
+			}
+		}
+		for _, key := range missingKeys(vals) {
+			value, found, err := store.Get([]byte(key))
+			if err != nil || found || value != nil {
+				t.Fatalf("Get(%q) for a missing key must return nil, false, nil: %d bytes, %v, %v", key, len(value), found, err)
+			}
+		}
+	})
+
+	t.Run("GetOwnership", func(t *testing.T) {
+		value, _, err := store.Get([]byte("a"))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		for i := range value {
+			value[i] = 'x'
+		}
+		value, _, err = store.Get([]byte("a"))
+		if err != nil || !bytes.Equal(value, vals["a"]) {
+			t.Fatalf("modifying the value returned by Get must not change the store: %q %v", value, err)
+		}
+	})
+
+	t.Run("Exists", func(t *testing.T) {
+		for _, key := range keys {
+			if found, err := store.Exists([]byte(key)); err != nil || !found {
+				t.Fatalf("Exists(%q) must return true for a key in the store: %v, %v", key, found, err)
+			}
+		}
+		for _, key := range missingKeys(vals) {
+			if found, err := store.Exists([]byte(key)); err != nil || found {
+				t.Fatalf("Exists(%q) must return false for a missing key: %v, %v", key, found, err)
+			}
+		}
+	})
+
+	t.Run("ScanPrefix", func(t *testing.T) {
+		for _, prefix := range scanPrefixes(keys) {
+			var expected []string
+			for _, key := range keys {
+				if strings.HasPrefix(key, prefix) {
+					expected = append(expected, key)
+				}
+			}
+			var scanned []string
+			err := store.ScanPrefix([]byte(prefix), func(key, value []byte) error {
+				if !bytes.Equal(value, vals[string(key)]) {
+					t.Fatalf("ScanPrefix(%q) returned a value of %d bytes for %q, expected %d bytes", prefix, len(value), key, len(vals[string(key)]))
+				}
+				scanned = append(scanned, string(key))
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ScanPrefix(%q) returned an error: %v", prefix, err)
+			}
+			if !slices.Equal(scanned, expected) {
+				t.Fatalf("ScanPrefix(%q) must return the keys with the prefix in sorted order:\n%q\nexpected:\n%q", prefix, scanned, expected)
+			}
+		}
+	})
+
+	t.Run("ScanPrefixStop", func(t *testing.T) {
+		errStop := errors.New("stop")
+		var calls int
+		err := store.ScanPrefix(nil, func(key, value []byte) error {
+			calls++
+			return errStop
+		})
+		if !errors.Is(err, errStop) || calls != 1 {
+			t.Fatalf("ScanPrefix must stop and return the callback error: %d calls, %v", calls, err)
+		}
+	})
+
+	t.Run("Size", func(t *testing.T) {
+		if size := store.Size(); size < uint64(len(vals)) {
+			t.Fatalf("Size() must count all the keys: %d < %d", size, len(vals))
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		empty := factory(t, map[string][]byte{})
+		if value, found, err := empty.Get([]byte("a")); err != nil || found || value != nil {
+			t.Fatalf("Get on an empty store must return nil, false, nil: %v, %v", found, err)
+		}
+		if found, err := empty.Exists([]byte("a")); err != nil || found {
+			t.Fatalf("Exists on an empty store must return false: %v, %v", found, err)
+		}
+		err := empty.ScanPrefix(nil, func(key, value []byte) error {
+			return errors.Errorf("ScanPrefix on an empty store returned %q", key)
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	})
+}
+
+// missingKeys returns keys near the keys in vals which are not in vals.
+func missingKeys(vals map[string][]byte) []string {
+	candidates := []string{"\x00", "0", "a/", "a/b/", "aa", "b\x00", "c", "z", "z/", "\xff\xff"}
+	for key := range vals {
+		candidates = append(candidates, key+"\x00", key+"/missing", key[:len(key)-1]+"\x00")
+	}
+	var missing []string
+	for _, key := range candidates {
+		if _, ok := vals[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	slices.Sort(missing)
+	return slices.Compact(missing)
+}
+
+// scanPrefixes returns the prefixes to scan: every prefix of every key, plus
+// prefixes matching no keys.
+func scanPrefixes(keys []string) []string {
+	prefixes := []string{"", "0", "c", "\xff"}
+	for _, key := range keys {
+		for i := 1; i <= len(key) && i <= 8; i++ {
+			prefixes = append(prefixes, key[:i])
+		}
+		prefixes = append(prefixes, key, key+"\x00")
+	}
+	slices.Sort(prefixes)
+	return slices.Compact(prefixes)
+}
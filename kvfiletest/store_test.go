@@ -0,0 +1,128 @@
+package kvfiletest
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+)
+
+func TestReaderStore(t *testing.T) {
+	TestStore(t, func(t *testing.T, vals map[string][]byte) kvfile.Store {
+		keys := make([][]byte, 0, len(vals))
+		for key := range vals {
+			keys = append(keys, []byte(key))
+		}
+		var buf bytes.Buffer
+		err := kvfile.Write(&buf, keys, func(wr io.Writer, key []byte) (uint64, error) {
+			nw, err := wr.Write(vals[string(key)])
+			return uint64(nw), err
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		rdr, err := kvfile.BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return rdr
+	})
+}
+
+func TestMapStore(t *testing.T) {
+	TestStore(t, func(t *testing.T, vals map[string][]byte) kvfile.Store {
+		return kvfile.MapStore(vals)
+	})
+}
+
+func TestLayeredReaderStore(t *testing.T) {
+	TestStore(t, func(t *testing.T, vals map[string][]byte) kvfile.Store {
+		// split the values across two layers with shadowed keys
+		base, top := make(map[string][]byte), make(map[string][]byte)
+		var i int
+		for key, val := range vals {
+			if i%2 == 0 {
+				base[key] = []byte("shadowed")
+				top[key] = val
+			} else {
+				base[key] = val
+			}
+			i++
+		}
+		var layers []*kvfile.Reader
+		for _, layer := range []map[string][]byte{base, top} {
+			keys := make([][]byte, 0, len(layer))
+			for key := range layer {
+				keys = append(keys, []byte(key))
+			}
+			var buf bytes.Buffer
+			err := kvfile.Write(&buf, keys, func(wr io.Writer, key []byte) (uint64, error) {
+				nw, err := wr.Write(layer[string(key)])
+				return uint64(nw), err
+			})
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			rdr, err := kvfile.BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			layers = append(layers, rdr)
+		}
+		return kvfile.NewLayeredReader(layers...)
+	})
+}
+
+func TestOverlayStore(t *testing.T) {
+	TestStore(t, func(t *testing.T, vals map[string][]byte) kvfile.Store {
+		// split the values across the base and the overrides with overridden
+		// and deleted keys
+		base := make(map[string][]byte)
+		overrides := make(map[string][]byte)
+		var deleted []string
+		var i int
+		for key, val := range vals {
+			switch i % 3 {
+			case 0:
+				base[key] = []byte("overridden")
+				overrides[key] = val
+			case 1:
+				base[key] = val
+				base[key+"-deleted"] = val
+				deleted = append(deleted, key+"-deleted")
+			default:
+				overrides[key] = val
+			}
+			i++
+		}
+		keys := make([][]byte, 0, len(base))
+		for key := range base {
+			keys = append(keys, []byte(key))
+		}
+		var buf bytes.Buffer
+		err := kvfile.Write(&buf, keys, func(wr io.Writer, key []byte) (uint64, error) {
+			nw, err := wr.Write(base[string(key)])
+			return uint64(nw), err
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		rdr, err := kvfile.BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		store := kvfile.NewOverlayStore(rdr)
+		for key, val := range overrides {
+			if err := store.Put([]byte(key), val); err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+		for _, key := range deleted {
+			if err := store.Delete([]byte(key)); err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+		return store
+	})
+}
@@ -0,0 +1,437 @@
+package kvfile_kvjournal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+)
+
+// A journal file is a header followed by records:
+//
+//	[magic "KVJL"][version (1 byte)]
+//	[payload length (uint32 LE)][payload crc32c (uint32 LE)][payload]...
+//
+// The payload of a record is:
+//
+//	[op (1 byte)][key length (uvarint)][key][value]
+//
+// Records are only appended. A crash while appending can leave a torn record
+// at the end of the file: when opening, an incomplete or corrupt final record
+// is truncated. A corrupt record before the end of the file is not a torn
+// append and is reported as a *CorruptError without changing the file.
+
+// journalMagic is the magic number at the start of a journal.
+var journalMagic = []byte("KVJL")
+
+// journalVersion is the journal format version.
+const journalVersion = 1
+
+// journalHeaderSize is the size of the journal header.
+const journalHeaderSize = 5
+
+// recordHeaderSize is the size of the length and checksum before a payload.
+const recordHeaderSize = 8
+
+// maxRecordSize is the maximum size of a record payload.
+const maxRecordSize = 1 << 30
+
+// journal record types
+const (
+	opPut    = 1
+	opDelete = 2
+)
+
+// crcTable is the crc32c table for the record checksums.
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// File is the storage for a Journal, implemented by *os.File.
+type File interface {
+	io.ReaderAt
+	io.WriterAt
+	// Truncate changes the size of the file.
+	Truncate(size int64) error
+	// Sync commits the contents of the file to stable storage.
+	Sync() error
+}
+
+// CorruptError is returned by Open if a record before the end of the journal
+// is corrupt.
+//
+// The file is not changed: the records after the corrupt record may have been
+// committed with Sync. Offset is the size of the valid prefix of the file.
+type CorruptError struct {
+	// Offset is the offset of the corrupt record.
+	Offset int64
+	// Err is the error parsing the record.
+	Err error
+}
+
+// Error returns the error string.
+func (e *CorruptError) Error() string {
+	return "corrupt kvjournal record at offset " + strconv.FormatInt(e.Offset, 10) + ": " + e.Err.Error()
+}
+
+// Unwrap returns the error parsing the record.
+func (e *CorruptError) Unwrap() error {
+	return e.Err
+}
+
+// journalEntry is the latest record for a key.
+type journalEntry struct {
+	// value is the value if not deleted
+	value []byte
+	// deleted indicates the key was deleted
+	deleted bool
+}
+
+// Journal is an append-only log of Put and Delete records.
+//
+// The latest record for each key is kept in memory. Layer the journal over a
+// base kvfile with a View and merge it into a new kvfile with View.Compact.
+// Concurrency safe.
+type Journal struct {
+	mtx sync.RWMutex
+	f   File
+	// size is the end of the last complete record
+	size int64
+	// entries contains the latest record for each key
+	entries map[string]journalEntry
+	// discarded is the number of bytes discarded from the end when opening
+	discarded int64
+	// err is set if a failed write could not be rolled back
+	err error
+}
+
+// Open opens a journal, replaying the records in the file.
+//
+// An empty file is initialized as an empty journal. If the file ends with an
+// incomplete or corrupt record, for example after a crash while appending, the
+// file is truncated after the last complete record. Returns a *CorruptError if
+// a corrupt record is followed by more data.
+func Open(f File) (*Journal, error) {
+	j := &Journal{f: f, entries: make(map[string]journalEntry)}
+	sr := io.NewSectionReader(f, 0, 1<<62)
+	br := bufio.NewReader(sr)
+	header := make([]byte, journalHeaderSize)
+	n, err := io.ReadFull(br, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	if n != 0 && !bytes.HasPrefix(header[:n], journalMagic[:min(n, len(journalMagic))]) {
+		return nil, errors.New("not a kvjournal file")
+	}
+	if n < journalHeaderSize {
+		// new journal or crash while writing the header
+		header = append(slices.Clone(journalMagic), journalVersion)
+		if err := f.Truncate(0); err != nil {
+			return nil, err
+		}
+		if _, err := f.WriteAt(header, 0); err != nil {
+			return nil, err
+		}
+		if err := f.Sync(); err != nil {
+			return nil, err
+		}
+		j.size, j.discarded = journalHeaderSize, int64(n)
+		return j, nil
+	}
+	if header[len(journalMagic)] != journalVersion {
+		return nil, errors.Errorf("unsupported kvjournal version: %v", header[len(journalMagic)])
+	}
+
+	j.size = journalHeaderSize
+	for {
+		recordSize, err := j.replayRecord(br)
+		if err == io.EOF {
+			return j, nil
+		}
+		var corruptErr *CorruptError
+		if errors.As(err, &corruptErr) {
+			// only the final record can be torn
+			if _, err := br.Peek(1); err != io.EOF {
+				if err != nil {
+					return nil, err
+				}
+				return nil, corruptErr
+			}
+			break
+		}
+		if err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		j.size += recordSize
+	}
+
+	// torn final record: discard the rest of the file
+	if _, err := io.Copy(io.Discard, br); err != nil {
+		return nil, err
+	}
+	fileSize, err := sr.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	j.discarded = fileSize - j.size
+	if err := f.Truncate(j.size); err != nil {
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// OpenFile opens or creates the journal file at the path.
+//
+// The file is closed by Close.
+func OpenFile(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	j, err := Open(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// replayRecord reads the next record and applies it to the entries.
+//
+// Returns io.EOF at the end of the file, io.ErrUnexpectedEOF if the record is
+// incomplete, and a *CorruptError if the record is corrupt. br has been read
+// past the record if it is corrupt.
+func (j *Journal) replayRecord(br *bufio.Reader) (int64, error) {
+	var header [recordHeaderSize]byte
+	n, err := io.ReadFull(br, header[:])
+	if n == 0 && err == io.EOF {
+		return 0, io.EOF
+	}
+	if err != nil {
+		return 0, err
+	}
+	payloadSize := binary.LittleEndian.Uint32(header[:4])
+	if payloadSize > maxRecordSize {
+		// skip the payload to check if it ends the file
+		n, err := io.CopyN(io.Discard, br, int64(payloadSize))
+		if n != int64(payloadSize) {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		return 0, &CorruptError{Offset: j.size, Err: errors.Errorf("record too large: %v", payloadSize)}
+	}
+	payload, err := io.ReadAll(io.LimitReader(br, int64(payloadSize)))
+	if err != nil {
+		return 0, err
+	}
+	if len(payload) != int(payloadSize) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(header[4:]) {
+		return 0, &CorruptError{Offset: j.size, Err: errors.New("record checksum mismatch")}
+	}
+	op, key, value, err := parseRecord(payload)
+	if err != nil {
+		return 0, &CorruptError{Offset: j.size, Err: err}
+	}
+	j.apply(op, key, value)
+	return recordHeaderSize + int64(payloadSize), nil
+}
+
+// parseRecord parses a record payload.
+func parseRecord(payload []byte) (op byte, key, value []byte, err error) {
+	if len(payload) == 0 {
+		return 0, nil, nil, errors.New("empty record")
+	}
+	op = payload[0]
+	keyLen, n := binary.Uvarint(payload[1:])
+	if n <= 0 || keyLen == 0 || keyLen > uint64(len(payload)-1-n) {
+		return 0, nil, nil, errors.New("invalid record key")
+	}
+	key = payload[1+n : 1+n+int(keyLen)]
+	value = payload[1+n+int(keyLen):]
+	switch op {
+	case opPut:
+	case opDelete:
+		if len(value) != 0 {
+			return 0, nil, nil, errors.New("invalid delete record")
+		}
+	default:
+		return 0, nil, nil, errors.Errorf("invalid record type: %v", op)
+	}
+	return op, key, value, nil
+}
+
+// apply applies a record to the entries.
+func (j *Journal) apply(op byte, key, value []byte) {
+	if op == opDelete {
+		j.entries[string(key)] = journalEntry{deleted: true}
+	} else {
+		j.entries[string(key)] = journalEntry{value: value}
+	}
+}
+
+// Put appends a record setting the value for the key.
+//
+// The record is written to the file but not synced, see Sync.
+func (j *Journal) Put(key, value []byte) error {
+	return j.append(opPut, key, value)
+}
+
+// Delete appends a record deleting the key.
+//
+// Deleting a key which does not exist is not an error. The record is written
+// to the file but not synced, see Sync.
+func (j *Journal) Delete(key []byte) error {
+	return j.append(opDelete, key, nil)
+}
+
+// append writes a record and applies it to the entries.
+func (j *Journal) append(op byte, key, value []byte) error {
+	if len(key) == 0 {
+		return errors.New("key cannot be empty")
+	}
+	if size := len(key) + len(value); size > maxRecordSize-1-binary.MaxVarintLen64 {
+		return errors.Errorf("record too large: %v", size)
+	}
+	record := make([]byte, recordHeaderSize, recordHeaderSize+1+binary.MaxVarintLen64+len(key)+len(value))
+	record = append(record, op)
+	record = binary.AppendUvarint(record, uint64(len(key)))
+	keyEnd := len(record) + len(key)
+	record = append(record, key...)
+	record = append(record, value...)
+	payload := record[recordHeaderSize:]
+	binary.LittleEndian.PutUint32(record[:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:], crc32.Checksum(payload, crcTable))
+
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	if j.err != nil {
+		return j.err
+	}
+	if _, err := j.f.WriteAt(record, j.size); err != nil {
+		// remove the partial record so later records are not lost on replay
+		if terr := j.f.Truncate(j.size); terr != nil {
+			j.err = errors.Wrap(terr, "roll back failed journal write")
+		}
+		return err
+	}
+	j.size += int64(len(record))
+	// the entries reference the record instead of the caller's buffers
+	j.apply(op, record[keyEnd-len(key):keyEnd], record[keyEnd:])
+	return nil
+}
+
+// Sync commits the records written so far to stable storage.
+func (j *Journal) Sync() error {
+	j.mtx.RLock()
+	defer j.mtx.RUnlock()
+	return j.f.Sync()
+}
+
+// Len returns the number of keys with a record in the journal.
+func (j *Journal) Len() int {
+	j.mtx.RLock()
+	defer j.mtx.RUnlock()
+	return len(j.entries)
+}
+
+// Size returns the size of the journal file in bytes.
+func (j *Journal) Size() int64 {
+	j.mtx.RLock()
+	defer j.mtx.RUnlock()
+	return j.size
+}
+
+// Discarded returns the number of bytes discarded from the end of the file
+// when opening the journal, non-zero if the last record was torn or corrupt.
+func (j *Journal) Discarded() int64 {
+	return j.discarded
+}
+
+// Close closes the file if it implements io.Closer.
+func (j *Journal) Close() error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	if closer, ok := j.f.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// lookup returns the latest record for the key.
+func (j *Journal) lookup(key []byte) (journalEntry, bool) {
+	j.mtx.RLock()
+	defer j.mtx.RUnlock()
+	entry, ok := j.entries[string(key)]
+	return entry, ok
+}
+
+// snapshotPrefix returns the sorted keys with the prefix and the records.
+func (j *Journal) snapshotPrefix(prefix []byte) ([]string, map[string]journalEntry) {
+	j.mtx.RLock()
+	defer j.mtx.RUnlock()
+	var keys []string
+	entries := make(map[string]journalEntry)
+	for key, entry := range j.entries {
+		if strings.HasPrefix(key, string(prefix)) {
+			keys = append(keys, key)
+			entries[key] = entry
+		}
+	}
+	slices.Sort(keys)
+	return keys, entries
+}
+
+// resetLocked truncates the journal to the header and clears the entries.
+func (j *Journal) resetLocked() error {
+	if err := j.f.Truncate(journalHeaderSize); err != nil {
+		return err
+	}
+	if err := j.f.Sync(); err != nil {
+		return err
+	}
+	j.size = journalHeaderSize
+	clear(j.entries)
+	return nil
+}
+
+// cloneEntriesLocked returns the puts and deletes in the journal.
+func (j *Journal) cloneEntriesLocked() (set map[string][]byte, remove [][]byte) {
+	set = make(map[string][]byte)
+	for key, entry := range j.entries {
+		if entry.deleted {
+			remove = append(remove, []byte(key))
+		} else {
+			set[key] = entry.value
+		}
+	}
+	return set, remove
+}
+
+// sortedPutsLocked returns the puts in the journal sorted by key.
+func (j *Journal) sortedPutsLocked() []kvfile.KV {
+	var kvs []kvfile.KV
+	for _, key := range slices.Sorted(maps.Keys(j.entries)) {
+		if entry := j.entries[key]; !entry.deleted {
+			kvs = append(kvs, kvfile.KV{Key: []byte(key), Value: entry.value})
+		}
+	}
+	return kvs
+}
@@ -0,0 +1,368 @@
+package kvfile_kvjournal
+
+import (
+	"bytes"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	"github.com/aperturerobotics/go-kvfile/kvfiletest"
+	"github.com/pkg/errors"
+)
+
+// openTestJournal opens a journal file in a temporary directory.
+func openTestJournal(t *testing.T) (*Journal, string) {
+	path := filepath.Join(t.TempDir(), "journal")
+	j, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Cleanup(func() { _ = j.Close() })
+	return j, path
+}
+
+// scanView returns the key/value pairs in the view with the prefix.
+func scanView(t *testing.T, v *View, prefix string) map[string]string {
+	vals := make(map[string]string)
+	var prev string
+	err := v.ScanPrefix([]byte(prefix), func(key, value []byte) error {
+		if len(vals) != 0 && string(key) <= prev {
+			t.Fatalf("keys out of order: %q after %q", key, prev)
+		}
+		prev = string(key)
+		vals[string(key)] = string(value)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return vals
+}
+
+func TestViewStore(t *testing.T) {
+	kvfiletest.TestStore(t, func(t *testing.T, vals map[string][]byte) kvfile.Store {
+		// split the values across the base and the journal with shadowed and
+		// deleted keys
+		base := make(map[string][]byte)
+		j, _ := openTestJournal(t)
+		var i int
+		for key, val := range vals {
+			var err error
+			switch i % 3 {
+			case 0:
+				base[key] = []byte("shadowed")
+				err = j.Put([]byte(key), val)
+			case 1:
+				base[key] = val
+				err = j.Put([]byte(key+"-deleted"), val)
+				if err == nil {
+					err = j.Delete([]byte(key + "-deleted"))
+				}
+			default:
+				err = j.Put([]byte(key), val)
+			}
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			i++
+		}
+		keys := make([][]byte, 0, len(base))
+		for key := range base {
+			keys = append(keys, []byte(key))
+		}
+		var buf bytes.Buffer
+		err := kvfile.Write(&buf, keys, func(wr io.Writer, key []byte) (uint64, error) {
+			nw, err := wr.Write(base[string(key)])
+			return uint64(nw), err
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		rd, err := kvfile.BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return NewView(rd, j)
+	})
+}
+
+func TestJournalRecovery(t *testing.T) {
+	j, path := openTestJournal(t)
+	var buf bytes.Buffer
+	err := kvfile.Write(&buf, [][]byte{[]byte("a"), []byte("b"), []byte("c")}, func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := io.WriteString(wr, "base-"+string(key))
+		return uint64(nw), err
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	base, err := kvfile.BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for i := range 10 {
+		if err := j.Put([]byte("key-"+strconv.Itoa(i)), []byte("value-"+strconv.Itoa(i))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := j.Put([]byte("a"), []byte("journal-a")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := j.Delete([]byte("b")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := j.Sync(); err != nil {
+		t.Fatal(err.Error())
+	}
+	committed := j.Size()
+	if err := j.Put([]byte("torn"), bytes.Repeat([]byte("x"), 100)); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// crash in the middle of the last record
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, cut := range []int64{committed + 1, committed + 8, committed + 50, int64(len(data)) - 1} {
+		if err := os.WriteFile(path, data[:cut], 0o644); err != nil {
+			t.Fatal(err.Error())
+		}
+		j, err := OpenFile(path)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if j.Size() != committed || j.Discarded() != cut-committed {
+			t.Fatalf("cut at %d: unexpected size after recovery: %d %d", cut, j.Size(), j.Discarded())
+		}
+		if info, err := os.Stat(path); err != nil || info.Size() != committed {
+			t.Fatalf("cut at %d: expected the file to be truncated: %v", cut, err)
+		}
+		view := NewView(base, j)
+		if found, err := view.Exists([]byte("torn")); err != nil || found {
+			t.Fatalf("cut at %d: expected the torn record to be discarded: %v %v", cut, found, err)
+		}
+		if val, found, err := view.Get([]byte("key-9")); err != nil || !found || string(val) != "value-9" {
+			t.Fatalf("cut at %d: unexpected value for key-9: %q %v %v", cut, val, found, err)
+		}
+		// appending after recovery
+		if err := j.Put([]byte("after"), []byte("recovery")); err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := j.Close(); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	// the records appended after recovery are replayed
+	j, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer j.Close()
+	if j.Discarded() != 0 || j.Len() != 13 {
+		t.Fatalf("unexpected journal after reopen: %d %d", j.Discarded(), j.Len())
+	}
+	view := NewView(base, j)
+	expected := map[string]string{"a": "journal-a", "after": "recovery", "c": "base-c"}
+	for i := range 10 {
+		expected["key-"+strconv.Itoa(i)] = "value-" + strconv.Itoa(i)
+	}
+	if vals := scanView(t, view, ""); !maps.Equal(vals, expected) {
+		t.Fatalf("unexpected view contents: %v", vals)
+	}
+
+	// compaction writes the view and truncates the journal
+	outPath := filepath.Join(t.TempDir(), "compacted.kvf")
+	out, err := os.Create(outPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := view.Compact(out, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if j.Len() != 0 || j.Size() != journalHeaderSize {
+		t.Fatalf("expected the journal to be truncated: %d %d", j.Len(), j.Size())
+	}
+	compacted, err := kvfile.OpenFile(outPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer compacted.Close()
+	if vals := scanView(t, NewView(compacted.Reader, j), ""); !maps.Equal(vals, expected) {
+		t.Fatalf("unexpected compacted contents: %v", vals)
+	}
+	if compacted.Size() != uint64(len(expected)) {
+		t.Fatalf("unexpected compacted size: %d", compacted.Size())
+	}
+}
+
+func TestJournalCorrupt(t *testing.T) {
+	j, path := openTestJournal(t)
+	for _, key := range []string{"a", "b", "c"} {
+		if err := j.Put([]byte(key), []byte("value-"+key)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// a corrupt record followed by more records is reported without changing
+	// the file
+	corrupt := bytes.Clone(data)
+	corrupt[bytes.Index(corrupt, []byte("value-b"))] ^= 0xff
+	if err := os.WriteFile(path, corrupt, 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = OpenFile(path)
+	var corruptErr *CorruptError
+	if !errors.As(err, &corruptErr) {
+		t.Fatalf("expected a corrupt record error: %v", err)
+	}
+	// the record starts before the header, op, key length, and key
+	if expected := int64(bytes.Index(data, []byte("value-b"))) - recordHeaderSize - 3; corruptErr.Offset != expected {
+		t.Fatalf("unexpected corrupt record offset: %d != %d", corruptErr.Offset, expected)
+	}
+	if after, err := os.ReadFile(path); err != nil || !bytes.Equal(after, corrupt) {
+		t.Fatalf("expected the file not to be changed: %v", err)
+	}
+
+	// a corrupt final record is truncated
+	corrupt = bytes.Clone(data)
+	corrupt[bytes.Index(corrupt, []byte("value-c"))] ^= 0xff
+	if err := os.WriteFile(path, corrupt, 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	j, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if j.Len() != 2 || j.Discarded() == 0 {
+		t.Fatalf("expected the first two records: %d %d", j.Len(), j.Discarded())
+	}
+	_ = j.Close()
+
+	// a torn header is rewritten
+	if err := os.WriteFile(path, data[:3], 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	j, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if j.Len() != 0 || j.Size() != journalHeaderSize {
+		t.Fatalf("expected an empty journal: %d %d", j.Len(), j.Size())
+	}
+	_ = j.Close()
+
+	if err := os.WriteFile(path, []byte("not a journal"), 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := OpenFile(path); err == nil {
+		t.Fatal("expected an error for a file which is not a journal")
+	}
+}
+
+func TestCompactWithoutBase(t *testing.T) {
+	j, _ := openTestJournal(t)
+	for _, key := range []string{"b", "a", "c"} {
+		if err := j.Put([]byte(key), []byte("value-"+key)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := j.Delete([]byte("c")); err != nil {
+		t.Fatal(err.Error())
+	}
+	var buf bytes.Buffer
+	if err := NewView(nil, j).Compact(&buf, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	rd, err := kvfile.NewReaderFromBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if vals := scanView(t, NewView(rd, j), ""); !maps.Equal(vals, map[string]string{"a": "value-a", "b": "value-b"}) {
+		t.Fatalf("unexpected compacted contents: %v", vals)
+	}
+}
+
+func TestCompactInstallOrder(t *testing.T) {
+	j, journalPath := openTestJournal(t)
+	for _, key := range []string{"a", "b"} {
+		if err := j.Put([]byte(key), []byte("value-"+key)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := j.Sync(); err != nil {
+		t.Fatal(err.Error())
+	}
+	journalSize := j.Size()
+
+	dir := t.TempDir()
+	basePath, tmpPath := filepath.Join(dir, "base.kvf"), filepath.Join(dir, "base.kvf.tmp")
+	compact := func(install func() error) error {
+		out, err := os.Create(tmpPath)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		defer out.Close()
+		return NewView(nil, j).Compact(out, install)
+	}
+
+	// a crash before the new base is installed keeps the journal records
+	errCrash := errors.New("crash before install")
+	err := compact(func() error {
+		if fi, err := os.Stat(journalPath); err != nil || fi.Size() != journalSize {
+			t.Fatalf("expected the journal to be intact during install: %v", err)
+		}
+		return errCrash
+	})
+	if err != errCrash {
+		t.Fatalf("expected the install error: %v", err)
+	}
+	if j.Len() != 2 || j.Size() != journalSize {
+		t.Fatalf("expected the journal to be kept: %d %d", j.Len(), j.Size())
+	}
+	reopened, err := OpenFile(journalPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if reopened.Len() != 2 {
+		t.Fatalf("expected the records after reopening: %d", reopened.Len())
+	}
+	_ = reopened.Close()
+
+	// the journal is truncated after the new base is installed
+	err = compact(func() error {
+		return os.Rename(tmpPath, basePath)
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if j.Len() != 0 || j.Size() != journalHeaderSize {
+		t.Fatalf("expected the journal to be truncated: %d %d", j.Len(), j.Size())
+	}
+	base, err := kvfile.OpenFile(basePath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer base.Close()
+	if vals := scanView(t, NewView(base.Reader, j), ""); !maps.Equal(vals, map[string]string{"a": "value-a", "b": "value-b"}) {
+		t.Fatalf("unexpected installed contents: %v", vals)
+	}
+}
@@ -0,0 +1,179 @@
+package kvfile_kvjournal
+
+import (
+	"bytes"
+	"io"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+)
+
+// View layers the journal over a base kvfile.
+//
+// The latest journal record for a key shadows the base entry: a put replaces
+// the value and a delete hides the key. Entries hidden by the base reader
+// options and tombstones in the base are not visible. Concurrency safe.
+type View struct {
+	// base is the base kvfile, nil if there is no base yet
+	base *kvfile.Reader
+	// journal contains the changes since the base was written
+	journal *Journal
+}
+
+// NewView builds a new View with the base kvfile and the journal.
+//
+// base can be nil if there is no base kvfile yet.
+func NewView(base *kvfile.Reader, journal *Journal) *View {
+	return &View{base: base, journal: journal}
+}
+
+// GetBase returns the base kvfile.
+func (v *View) GetBase() *kvfile.Reader {
+	return v.base
+}
+
+// GetJournal returns the journal.
+func (v *View) GetJournal() *Journal {
+	return v.journal
+}
+
+// Get looks up the value for the given key.
+//
+// Returns nil, false, nil if not found or deleted.
+func (v *View) Get(key []byte) ([]byte, bool, error) {
+	if entry, ok := v.journal.lookup(key); ok {
+		if entry.deleted {
+			return nil, false, nil
+		}
+		return bytes.Clone(entry.value), true, nil
+	}
+	if v.base == nil {
+		return nil, false, nil
+	}
+	_, valueLen, indexEntry, indexEntryIdx, err := v.base.GetValuePosition(key)
+	if err != nil || valueLen < 0 || indexEntry.GetTombstone() {
+		return nil, false, err
+	}
+	value, err := v.base.GetWithEntry(indexEntry, indexEntryIdx)
+	if err != nil {
+		return nil, true, err
+	}
+	return value, true, nil
+}
+
+// Exists checks if the given key exists in the view.
+func (v *View) Exists(key []byte) (bool, error) {
+	if entry, ok := v.journal.lookup(key); ok {
+		return !entry.deleted, nil
+	}
+	if v.base == nil {
+		return false, nil
+	}
+	_, valueLen, indexEntry, _, err := v.base.GetValuePosition(key)
+	return err == nil && valueLen >= 0 && !indexEntry.GetTombstone(), err
+}
+
+// ScanPrefix iterates over the key/value pairs with a prefix in key order.
+//
+// The journal records with the prefix are copied when the scan starts: writes
+// to the journal during the scan are not visible to it.
+func (v *View) ScanPrefix(prefix []byte, cb func(key, value []byte) error) error {
+	keys, entries := v.journal.snapshotPrefix(prefix)
+
+	// emitJournal calls the callback for the journal puts before the key
+	emitJournal := func(before []byte) error {
+		for len(keys) != 0 && (before == nil || keys[0] < string(before)) {
+			key := keys[0]
+			keys = keys[1:]
+			if entry := entries[key]; !entry.deleted {
+				if err := cb([]byte(key), bytes.Clone(entry.value)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if v.base != nil {
+		err := v.base.ScanPrefixEntries(prefix, func(indexEntry *kvfile.IndexEntry, indexEntryIdx int) error {
+			key := indexEntry.GetKey()
+			if err := emitJournal(key); err != nil {
+				return err
+			}
+			if _, ok := entries[string(key)]; ok {
+				// shadowed by the journal: emit the journal record instead
+				return emitJournal(append(bytes.Clone(key), 0))
+			}
+			if indexEntry.GetTombstone() {
+				return nil
+			}
+			value, err := v.base.GetWithEntry(indexEntry, indexEntryIdx)
+			if err != nil {
+				return err
+			}
+			return cb(key, value)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return emitJournal(nil)
+}
+
+// Size returns the number of entries in the base and the journal.
+//
+// Keys in both and deleted keys are counted, so Size is an upper bound of the
+// number of keys in the view.
+func (v *View) Size() uint64 {
+	size := uint64(v.journal.Len())
+	if v.base != nil {
+		size += v.base.Size()
+	}
+	return size
+}
+
+// Compact writes the view to dst as a new kvfile and truncates the journal.
+//
+// The base entries are copied with their metadata, expiry, and tombstones and
+// the journal records are applied. If dst has a Sync method it is called
+// after writing. Then install is called to make the written file the new
+// base, for example by renaming it over the base file and syncing the
+// directory. The journal is truncated only after install returns, so a crash
+// before the new base is in place cannot lose the records: if install returns
+// an error the journal is kept and the error is returned. install can be nil.
+//
+// The journal is locked while compacting: install must not call the journal.
+// After Compact the journal is empty: build a new View with the written file
+// as the base instead of using this View.
+func (v *View) Compact(dst io.Writer, install func() error) error {
+	j := v.journal
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	if j.err != nil {
+		return j.err
+	}
+
+	var err error
+	if v.base != nil {
+		set, remove := j.cloneEntriesLocked()
+		err = kvfile.Amend(dst, v.base, set, remove)
+	} else {
+		err = kvfile.WriteFromSortedIterator(dst, kvfile.NewSliceIterator(j.sortedPutsLocked()))
+	}
+	if err != nil {
+		return err
+	}
+	if syncer, ok := dst.(interface{ Sync() error }); ok {
+		if err := syncer.Sync(); err != nil {
+			return err
+		}
+	}
+	if install != nil {
+		if err := install(); err != nil {
+			return err
+		}
+	}
+	return j.resetLocked()
+}
+
+// _ is a type assertion
+var _ kvfile.Store = ((*View)(nil))
@@ -0,0 +1,198 @@
+package kvfile
+
+import (
+	"bytes"
+	"io"
+)
+
+// LayeredReader reads from a stack of kvfiles as a single merged view.
+//
+// Entries in higher layers shadow entries with the same key in lower layers.
+// A tombstone in a higher layer hides the key in all lower layers. Expired
+// entries (see ReaderOptions.FilterExpired) are skipped as if they were not
+// present in that layer.
+type LayeredReader struct {
+	// layers contains the layers from the lowest (base) to the highest
+	layers []*Reader
+}
+
+// NewLayeredReader builds a new LayeredReader.
+//
+// Layers are ordered from the lowest (base) to the highest (top) layer.
+// Tombstones are respected regardless of ReaderOptions.ExposeTombstones.
+func NewLayeredReader(layers ...*Reader) *LayeredReader {
+	return &LayeredReader{layers: layers}
+}
+
+// GetLayers returns the layers from the lowest to the highest layer.
+func (l *LayeredReader) GetLayers() []*Reader {
+	return l.layers
+}
+
+// lookup finds the topmost visible entry for the key.
+//
+// Returns nil if the key does not exist or the entry is a tombstone.
+func (l *LayeredReader) lookup(key []byte) (*Reader, *IndexEntry, int, error) {
+	for i := len(l.layers) - 1; i >= 0; i-- {
+		layer := l.layers[i]
+		indexEntry, indexEntryIdx, err := layer.lookupIndexEntry(key)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if indexEntry == nil || layer.isEntryExpired(indexEntry) {
+			continue
+		}
+		if indexEntry.GetTombstone() {
+			return nil, nil, 0, nil
+		}
+		return layer, indexEntry, indexEntryIdx, nil
+	}
+	return nil, nil, 0, nil
+}
+
+// Exists checks if the given key exists in the merged view.
+func (l *LayeredReader) Exists(key []byte) (bool, error) {
+	_, indexEntry, _, err := l.lookup(key)
+	return indexEntry != nil, err
+}
+
+// Get looks up the value for the given key from the topmost layer.
+//
+// Returns nil, false, nil if not found or deleted.
+func (l *LayeredReader) Get(key []byte) ([]byte, bool, error) {
+	layer, indexEntry, indexEntryIdx, err := l.lookup(key)
+	if err != nil || indexEntry == nil {
+		return nil, false, err
+	}
+	data, err := layer.GetWithEntry(indexEntry, indexEntryIdx)
+	if err != nil {
+		return nil, true, err
+	}
+	return data, true, nil
+}
+
+// ReadTo reads the value for the given key from the topmost layer to the writer.
+//
+// Returns number of bytes read, found, and any error.
+// Returns 0, false, nil if not found or deleted.
+func (l *LayeredReader) ReadTo(key []byte, to io.Writer) (int, bool, error) {
+	layer, indexEntry, indexEntryIdx, err := l.lookup(key)
+	if err != nil || indexEntry == nil {
+		return 0, false, err
+	}
+	nr, err := layer.ReadToWithEntry(indexEntry, indexEntryIdx, to)
+	if err != nil {
+		return 0, true, err
+	}
+	return int(nr), true, nil
+}
+
+// layerCursor iterates over the entries with a prefix in a layer.
+type layerCursor struct {
+	// rd is the layer reader
+	rd *Reader
+	// prefix is the key prefix
+	prefix []byte
+	// idx is the index of the current entry
+	idx int
+	// entry is the current entry or nil if done
+	entry *IndexEntry
+}
+
+// next advances the cursor to the next entry with the prefix.
+func (c *layerCursor) next() error {
+	c.idx++
+	c.entry = nil
+	if c.idx >= int(c.rd.Size()) {
+		return nil
+	}
+	indexEntry, err := c.rd.ReadIndexEntry(uint64(c.idx))
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(indexEntry.GetKey(), c.prefix) {
+		c.entry = indexEntry
+	}
+	return nil
+}
+
+// ScanPrefixEntries iterates over the merged entries with the given key prefix.
+//
+// Calls the callback with the layer reader containing the topmost visible entry
+// for each key in sorted order. Tombstones and the keys they hide are skipped.
+func (l *LayeredReader) ScanPrefixEntries(prefix []byte, cb func(layer *Reader, indexEntry *IndexEntry, indexEntryIdx int) error) error {
+	cursors := make([]*layerCursor, len(l.layers))
+	for i, layer := range l.layers {
+		firstMatch, firstIndex, err := layer.SearchIndexEntryWithPrefix(prefix, false)
+		if err != nil {
+			return err
+		}
+		cursors[i] = &layerCursor{rd: layer, prefix: prefix, idx: firstIndex, entry: firstMatch}
+	}
+
+	for {
+		// find the smallest key among the cursors
+		var key []byte
+		for _, cursor := range cursors {
+			if cursor.entry != nil && (key == nil || bytes.Compare(cursor.entry.GetKey(), key) < 0) {
+				key = cursor.entry.GetKey()
+			}
+		}
+		if key == nil {
+			return nil
+		}
+
+		// select the topmost visible entry with the key
+		var match *layerCursor
+		var matchEntry *IndexEntry
+		var matchIdx int
+		for i := len(cursors) - 1; i >= 0; i-- {
+			cursor := cursors[i]
+			if cursor.entry == nil || !bytes.Equal(cursor.entry.GetKey(), key) {
+				continue
+			}
+			if match == nil && !cursor.rd.isEntryExpired(cursor.entry) {
+				match, matchEntry, matchIdx = cursor, cursor.entry, cursor.idx
+			}
+			if err := cursor.next(); err != nil {
+				return err
+			}
+		}
+		if match == nil || matchEntry.GetTombstone() {
+			continue
+		}
+		if err := cb(match.rd, matchEntry, matchIdx); err != nil {
+			return err
+		}
+	}
+}
+
+// ScanPrefixKeys iterates over the merged keys with a prefix.
+func (l *LayeredReader) ScanPrefixKeys(prefix []byte, cb func(key []byte) error) error {
+	return l.ScanPrefixEntries(prefix, func(layer *Reader, indexEntry *IndexEntry, indexEntryIdx int) error {
+		return cb(indexEntry.GetKey())
+	})
+}
+
+// ScanPrefix iterates over the merged key/value pairs with a prefix.
+func (l *LayeredReader) ScanPrefix(prefix []byte, cb func(key, value []byte) error) error {
+	return l.ScanPrefixEntries(prefix, func(layer *Reader, indexEntry *IndexEntry, indexEntryIdx int) error {
+		value, err := layer.GetWithEntry(indexEntry, indexEntryIdx)
+		if err != nil {
+			return err
+		}
+		return cb(indexEntry.GetKey(), value)
+	})
+}
+
+// Size returns the total number of entries in the layers.
+//
+// Shadowed entries and entries hidden by tombstones are counted, as with
+// Reader.Size, so Size is an upper bound of the number of merged keys.
+func (l *LayeredReader) Size() uint64 {
+	var size uint64
+	for _, layer := range l.layers {
+		size += layer.Size()
+	}
+	return size
+}
@@ -0,0 +1,134 @@
+package kvfile
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+// buildLayer writes a layer with the values and tombstones.
+func buildLayer(t *testing.T, vals map[string]string, tombstones []string, opts ReaderOptions) *Reader {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	for key, val := range vals {
+		if err := wr.WriteValue([]byte(key), bytes.NewReader([]byte(val))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	for _, key := range tombstones {
+		if err := wr.WriteTombstone([]byte(key)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := BuildReaderWithOptions(bytes.NewReader(buf.Bytes()), uint64(buf.Len()), opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return rdr
+}
+
+func TestTombstone(t *testing.T) {
+	vals := map[string]string{"test-1": "val-1"}
+	rdr := buildLayer(t, vals, []string{"test-2"}, ReaderOptions{})
+	if rdr.Size() != 2 {
+		t.Fatalf("unexpected size: %v", rdr.Size())
+	}
+	_, found, err := rdr.Get([]byte("test-2"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if found {
+		t.Fatal("expected tombstone to be hidden")
+	}
+	var keys []string
+	err = rdr.ScanPrefixKeys(nil, func(key []byte) error {
+		keys = append(keys, string(key))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(keys) != 1 || keys[0] != "test-1" {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+
+	// expose tombstones for tooling
+	rdr = buildLayer(t, vals, []string{"test-2"}, ReaderOptions{ExposeTombstones: true})
+	_, _, indexEntry, _, err := rdr.GetValuePosition([]byte("test-2"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !indexEntry.GetTombstone() || indexEntry.GetSize() != 0 {
+		t.Fatalf("expected tombstone entry: %v", indexEntry.String())
+	}
+}
+
+func TestLayeredReader(t *testing.T) {
+	base := buildLayer(t, map[string]string{
+		"test-1": "base-1",
+		"test-2": "base-2",
+		"test-3": "base-3",
+		"test-4": "base-4",
+	}, nil, ReaderOptions{})
+	mid := buildLayer(t, map[string]string{
+		"test-3": "mid-3",
+		"test-5": "mid-5",
+	}, []string{"test-2"}, ReaderOptions{})
+	// re-adds a key deleted in a lower layer
+	top := buildLayer(t, map[string]string{
+		"test-2": "top-2",
+	}, []string{"test-4", "test-6"}, ReaderOptions{})
+
+	rdr := NewLayeredReader(base, mid)
+	_, found, err := rdr.Get([]byte("test-2"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if found {
+		t.Fatal("expected deleted key to not be found")
+	}
+	keyExists, err := rdr.Exists([]byte("test-2"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if keyExists {
+		t.Fatal("expected deleted key to not exist")
+	}
+	val, found, err := rdr.Get([]byte("test-3"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !found || string(val) != "mid-3" {
+		t.Fatalf("expected overlay value: %v %s", found, string(val))
+	}
+
+	var kvs []string
+	scan := func(rdr *LayeredReader) []string {
+		kvs = kvs[:0]
+		err := rdr.ScanPrefix([]byte("test-"), func(key, value []byte) error {
+			kvs = append(kvs, string(key)+"="+string(value))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return kvs
+	}
+	expected := []string{"test-1=base-1", "test-3=mid-3", "test-4=base-4", "test-5=mid-5"}
+	if got := scan(rdr); !slices.Equal(got, expected) {
+		t.Fatalf("unexpected merged scan: %v", got)
+	}
+
+	rdr = NewLayeredReader(base, mid, top)
+	expected = []string{"test-1=base-1", "test-2=top-2", "test-3=mid-3", "test-5=mid-5"}
+	if got := scan(rdr); !slices.Equal(got, expected) {
+		t.Fatalf("unexpected merged scan: %v", got)
+	}
+	var out bytes.Buffer
+	if _, found, err := rdr.ReadTo([]byte("test-2"), &out); err != nil || !found || out.String() != "top-2" {
+		t.Fatalf("unexpected read to: %v %v %s", found, err, out.String())
+	}
+}
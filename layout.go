@@ -0,0 +1,61 @@
+package kvfile
+
+import (
+	"io"
+
+	protobuf_go_lite "github.com/aperturerobotics/protobuf-go-lite"
+)
+
+// Layout contains the positions of the regions of a file.
+type Layout struct {
+	// ValuesEnd is the end of the values region, which starts at 0.
+	// The values region is empty if the values are stored externally.
+	ValuesEnd uint64
+	// IndexEntryListPos is the position of the first index entry.
+	IndexEntryListPos uint64
+	// IndexEntryIndexesPos is the position of the list of index entry positions.
+	// For the fixed-width key layout this is the end of the index records.
+	IndexEntryIndexesPos uint64
+	// EntryCount is the number of index entries.
+	EntryCount uint64
+	// FixedKeyWidth is the key width if using the fixed-width key layout.
+	FixedKeyWidth uint64
+}
+
+// Layout returns the positions of the regions of the file.
+func (r *Reader) Layout() *Layout {
+	layout := &Layout{
+		IndexEntryListPos:    r.indexEntryListPos,
+		IndexEntryIndexesPos: r.indexEntryIndexesPos,
+		EntryCount:           r.indexEntryCount,
+		FixedKeyWidth:        r.fixedKeyWidth,
+	}
+	if !r.ExternalValues() {
+		layout.ValuesEnd = r.indexEntryListPos
+	}
+	return layout
+}
+
+// IndexEntryOffset returns the position of the index entry record in the file.
+//
+// Returns the position of the entry in the positions list, or of the entry size
+// varint, if the position of the entry cannot be read.
+func (r *Reader) IndexEntryOffset(indexEntryIdx uint64) uint64 {
+	if r.fixedKeyWidth != 0 {
+		return r.indexEntryListPos + indexEntryIdx*r.fixedRecordSize()
+	}
+	indexEntrySizePos, err := r.readIndexEntrySizePos(indexEntryIdx)
+	if err != nil {
+		return r.indexEntryIndexesPos + (8 * indexEntryIdx)
+	}
+	// the entry size varint follows the entry
+	buf := make([]byte, 10)
+	if _, err := r.rd.ReadAt(buf, int64(indexEntrySizePos)); err != nil && err != io.EOF {
+		return indexEntrySizePos
+	}
+	indexEntrySize, indexEntrySizeLen := protobuf_go_lite.ConsumeVarint(buf)
+	if indexEntrySizeLen < 0 || indexEntrySize > indexEntrySizePos {
+		return indexEntrySizePos
+	}
+	return indexEntrySizePos - indexEntrySize
+}
@@ -0,0 +1,37 @@
+package kvfile
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLayout(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	for _, key := range []string{"a", "b", "c"} {
+		if err := wr.WriteValue([]byte(key), bytes.NewReader([]byte("val-"+key))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	layout := rdr.Layout()
+	if layout.EntryCount != 3 || layout.ValuesEnd != 15 || layout.IndexEntryListPos != 15 {
+		t.Fatalf("unexpected layout: %+v", layout)
+	}
+	if layout.IndexEntryIndexesPos+3*8+8 != uint64(buf.Len()) {
+		t.Fatalf("unexpected index entry positions list position: %+v", layout)
+	}
+	if off := rdr.IndexEntryOffset(0); off != layout.IndexEntryListPos {
+		t.Fatalf("expected the first entry at the index start: %v", off)
+	}
+	if off := rdr.IndexEntryOffset(2); off <= rdr.IndexEntryOffset(1) || off >= layout.IndexEntryIndexesPos {
+		t.Fatalf("unexpected offset for the last entry: %v", off)
+	}
+}
@@ -0,0 +1,303 @@
+package kvfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+
+	protobuf_go_lite "github.com/aperturerobotics/protobuf-go-lite"
+	"github.com/pkg/errors"
+)
+
+// The Merkle tree is a binary SHA-256 hash tree over the entries in key order
+// with the structure of RFC 6962 (Certificate Transparency). The leaf hash of
+// an entry is:
+//
+//	SHA-256(0x00 [key length (uint64 LE)][key][value])
+//
+// and the hash of an inner node is SHA-256(0x01 [left][right]). The tree is
+// built level by level: nodes are hashed in pairs and an unpaired last node
+// is moved up to the next level unchanged. The root of a tree with no entries
+// is the hash of the empty string.
+//
+// The Merkle tree footer block contains the node hashes of every level, from
+// the leaves up to the root, concatenated.
+
+// MerkleHashSize is the size of the hashes in the Merkle tree.
+const MerkleHashSize = sha256.Size
+
+// Merkle tree hash prefixes to separate leaves from inner nodes.
+const (
+	merkleLeafPrefix  = 0
+	merkleInnerPrefix = 1
+)
+
+// maxMerkleProofLen is the maximum number of hashes in a proof.
+const maxMerkleProofLen = 64
+
+// ErrKeyNotFound is returned by Prove if the key is not in the file.
+var ErrKeyNotFound = errors.New("key not found")
+
+// Proof is a Merkle inclusion proof for an entry.
+//
+// The proof is O(log n) hashes: verifying it with VerifyProof shows that the
+// entry is in the file with the root hash.
+type Proof struct {
+	// Index is the index of the entry in the file.
+	Index uint64
+	// Count is the number of entries in the file.
+	Count uint64
+	// Path contains the sibling hashes from the leaf to the root.
+	Path [][]byte
+}
+
+// MarshalBinary encodes the proof.
+//
+// The encoding is varints: index, count, number of hashes, then the hashes.
+func (p Proof) MarshalBinary() ([]byte, error) {
+	data := protobuf_go_lite.AppendVarint(nil, p.Index)
+	data = protobuf_go_lite.AppendVarint(data, p.Count)
+	data = protobuf_go_lite.AppendVarint(data, uint64(len(p.Path)))
+	for _, h := range p.Path {
+		if len(h) != MerkleHashSize {
+			return nil, errors.Errorf("invalid proof hash size: %v", len(h))
+		}
+		data = append(data, h...)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes a proof encoded with MarshalBinary.
+func (p *Proof) UnmarshalBinary(data []byte) error {
+	var fields [3]uint64
+	for i := range fields {
+		field, n := protobuf_go_lite.ConsumeVarint(data)
+		if n < 0 {
+			return errors.New("invalid proof")
+		}
+		fields[i], data = field, data[n:]
+	}
+	if fields[2] > maxMerkleProofLen || uint64(len(data)) != fields[2]*MerkleHashSize {
+		return errors.New("invalid proof length")
+	}
+	p.Index, p.Count, p.Path = fields[0], fields[1], make([][]byte, fields[2])
+	for i := range p.Path {
+		p.Path[i] = bytes.Clone(data[i*MerkleHashSize : (i+1)*MerkleHashSize])
+	}
+	return nil
+}
+
+// VerifyProof checks that the proof shows the key/value pair is in the file
+// with the root hash.
+func VerifyProof(root []byte, key, value []byte, p Proof) bool {
+	if p.Index >= p.Count || len(p.Path) > maxMerkleProofLen {
+		return false
+	}
+	leaf := newMerkleLeafHash(key)
+	_, _ = leaf.Write(value)
+
+	// RFC 9162 section 2.1.3.2
+	fn, sn := p.Index, p.Count-1
+	r := leaf.Sum(nil)
+	for _, h := range p.Path {
+		if sn == 0 || len(h) != MerkleHashSize {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			r = hashMerkleNode(h, r)
+			for fn&1 == 0 && fn != 0 {
+				fn, sn = fn>>1, sn>>1
+			}
+		} else {
+			r = hashMerkleNode(r, h)
+		}
+		fn, sn = fn>>1, sn>>1
+	}
+	return sn == 0 && bytes.Equal(r, root)
+}
+
+// RootHash returns the root hash of the Merkle tree over the entries.
+//
+// If the file has the Merkle tree footer block (see WriterOptions.MerkleTree)
+// reads the root from the footer. Otherwise builds the tree by reading all of
+// the values, which is O(n). All entries are included regardless of the
+// reader options.
+func (r *Reader) RootHash() ([]byte, error) {
+	if r.Size() == 0 {
+		return hashMerkleEmpty(), nil
+	}
+	tree, err := r.merkleTree()
+	if err != nil {
+		return nil, err
+	}
+	levels := merkleLevelSizes(r.Size())
+	return tree(len(levels)-1, 0)
+}
+
+// Prove builds a Merkle inclusion proof for the entry with the key.
+//
+// Reads O(log n) hashes from the Merkle tree footer block if present,
+// otherwise builds the tree by reading all of the values, which is O(n).
+// Hidden entries can be proven as all entries are in the tree. Returns
+// ErrKeyNotFound if the key is not in the file.
+func (r *Reader) Prove(key []byte) (Proof, error) {
+	entry, idx, err := r.SearchIndexEntryWithKey(key)
+	if err != nil {
+		return Proof{}, err
+	}
+	if entry == nil {
+		return Proof{}, ErrKeyNotFound
+	}
+	tree, err := r.merkleTree()
+	if err != nil {
+		return Proof{}, err
+	}
+	p := Proof{Index: uint64(idx), Count: r.Size()}
+	pos := p.Index
+	for level, size := range merkleLevelSizes(p.Count) {
+		if size == 1 {
+			break
+		}
+		if sibling := pos ^ 1; sibling < size {
+			h, err := tree(level, sibling)
+			if err != nil {
+				return Proof{}, err
+			}
+			p.Path = append(p.Path, h)
+		}
+		pos >>= 1
+	}
+	return p, nil
+}
+
+// merkleNodeFunc returns the hash of the node at the index in the level.
+type merkleNodeFunc func(level int, idx uint64) ([]byte, error)
+
+// merkleTree returns a function to read the nodes of the Merkle tree.
+//
+// Reads the nodes from the footer block if present, otherwise builds the tree.
+func (r *Reader) merkleTree() (merkleNodeFunc, error) {
+	levels := merkleLevelSizes(r.Size())
+	block, found := r.findFooterBlock(FooterBlockMerkleTree)
+	if found {
+		var nodeCount uint64
+		for _, size := range levels {
+			nodeCount += size
+		}
+		if block.size != nodeCount*MerkleHashSize {
+			return nil, errors.Errorf("invalid merkle tree size: %v", block.size)
+		}
+		return func(level int, idx uint64) ([]byte, error) {
+			pos := block.pos
+			for _, size := range levels[:level] {
+				pos += size * MerkleHashSize
+			}
+			h := make([]byte, MerkleHashSize)
+			if _, err := r.rd.ReadAt(h, int64(pos+idx*MerkleHashSize)); err != nil {
+				return nil, err
+			}
+			return h, nil
+		}, nil
+	}
+
+	leaves := make([][]byte, r.Size())
+	for i := range leaves {
+		indexEntry, err := r.ReadIndexEntry(uint64(i))
+		if err != nil {
+			return nil, err
+		}
+		leaf := newMerkleLeafHash(indexEntry.GetKey())
+		if _, err := r.ReadToWithEntry(indexEntry, i, leaf); err != nil {
+			return nil, err
+		}
+		leaves[i] = leaf.Sum(nil)
+	}
+	nodes := buildMerkleLevels(leaves)
+	return func(level int, idx uint64) ([]byte, error) {
+		return nodes[level][idx], nil
+	}, nil
+}
+
+// buildMerkleTreeBlock builds the Merkle tree footer block for the sorted index.
+func buildMerkleTreeBlock(index []*IndexEntry, leafHashes map[*IndexEntry][]byte) (*footerBlockData, error) {
+	leaves := make([][]byte, len(index))
+	for i, indexEntry := range index {
+		leaves[i] = leafHashes[indexEntry]
+		if len(leaves[i]) != MerkleHashSize {
+			return nil, errors.Errorf("missing merkle leaf hash for %q", indexEntry.GetKey())
+		}
+	}
+	var data []byte
+	for _, level := range buildMerkleLevels(leaves) {
+		for _, h := range level {
+			data = append(data, h...)
+		}
+	}
+	if uint64(len(data)) > maxFooterBlockSize {
+		return nil, errors.Errorf("too many entries for merkle tree: %v", len(index))
+	}
+	return &footerBlockData{typ: FooterBlockMerkleTree, data: data}, nil
+}
+
+// buildMerkleLevels builds the levels of the tree from the leaf hashes.
+func buildMerkleLevels(leaves [][]byte) [][][]byte {
+	if len(leaves) == 0 {
+		return nil
+	}
+	levels := [][][]byte{leaves}
+	for level := leaves; len(level) > 1; {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+			} else {
+				next = append(next, hashMerkleNode(level[i], level[i+1]))
+			}
+		}
+		levels = append(levels, next)
+		level = next
+	}
+	return levels
+}
+
+// merkleLevelSizes returns the number of nodes in each level of the tree.
+func merkleLevelSizes(count uint64) []uint64 {
+	if count == 0 {
+		return nil
+	}
+	sizes := []uint64{count}
+	for count > 1 {
+		count = (count + 1) / 2
+		sizes = append(sizes, count)
+	}
+	return sizes
+}
+
+// newMerkleLeafHash returns a hash for the leaf with the key.
+//
+// The value must be written to the hash before calling Sum.
+func newMerkleLeafHash(key []byte) hash.Hash {
+	h := sha256.New()
+	var buf [9]byte
+	buf[0] = merkleLeafPrefix
+	binary.LittleEndian.PutUint64(buf[1:], uint64(len(key)))
+	_, _ = h.Write(buf[:])
+	_, _ = h.Write(key)
+	return h
+}
+
+// hashMerkleNode hashes an inner node with the children.
+func hashMerkleNode(left, right []byte) []byte {
+	h := sha256.New()
+	_, _ = h.Write([]byte{merkleInnerPrefix})
+	_, _ = h.Write(left)
+	_, _ = h.Write(right)
+	return h.Sum(nil)
+}
+
+// hashMerkleEmpty returns the root hash of an empty tree.
+func hashMerkleEmpty() []byte {
+	h := sha256.Sum256(nil)
+	return h[:]
+}
@@ -0,0 +1,144 @@
+package kvfile
+
+import (
+	"bytes"
+	"math/bits"
+	"strconv"
+	"testing"
+)
+
+// rfc6962Root computes the Merkle tree hash of the leaves as defined in RFC 6962.
+func rfc6962Root(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		return hashMerkleEmpty()
+	case 1:
+		return leaves[0]
+	}
+	// the largest power of two smaller than the number of leaves
+	k := 1 << (bits.Len(uint(len(leaves)-1)) - 1)
+	return hashMerkleNode(rfc6962Root(leaves[:k]), rfc6962Root(leaves[k:]))
+}
+
+// buildMerkleTestFile writes count entries and builds a Reader.
+func buildMerkleTestFile(t *testing.T, count int, merkleTree bool) (*Reader, []KV) {
+	kvs := make([]KV, count)
+	var buf bytes.Buffer
+	wr, err := NewWriterWithOptions(&buf, WriterOptions{MerkleTree: merkleTree})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// write in reverse order: the tree is built over the sorted entries
+	for i := count - 1; i >= 0; i-- {
+		key := []byte("key-" + strconv.Itoa(1000+i))
+		kvs[i] = KV{Key: key, Value: bytes.Repeat(key, i%4)}
+		if err := wr.WriteValue(kvs[i].Key, bytes.NewReader(kvs[i].Value)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return rdr, kvs
+}
+
+func TestMerkleProofs(t *testing.T) {
+	for count := 0; count <= 33; count++ {
+		var roots [][]byte
+		for _, merkleTree := range []bool{true, false} {
+			rdr, kvs := buildMerkleTestFile(t, count, merkleTree)
+			if _, found := rdr.findFooterBlock(FooterBlockMerkleTree); found != (merkleTree && count != 0) {
+				t.Fatalf("unexpected merkle tree footer block: %v", found)
+			}
+			root, err := rdr.RootHash()
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			roots = append(roots, root)
+
+			leaves := make([][]byte, len(kvs))
+			for i, kv := range kvs {
+				leaf := newMerkleLeafHash(kv.Key)
+				_, _ = leaf.Write(kv.Value)
+				leaves[i] = leaf.Sum(nil)
+			}
+			if expected := rfc6962Root(leaves); !bytes.Equal(root, expected) {
+				t.Fatalf("count %d: root does not match rfc 6962: %x != %x", count, root, expected)
+			}
+
+			for i, kv := range kvs {
+				p, err := rdr.Prove(kv.Key)
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+				if p.Index != uint64(i) || p.Count != uint64(count) || len(p.Path) > bits.Len(uint(count)) {
+					t.Fatalf("count %d: unexpected proof for entry %d: %v %v %v", count, i, p.Index, p.Count, len(p.Path))
+				}
+				if !VerifyProof(root, kv.Key, kv.Value, p) {
+					t.Fatalf("count %d: proof for entry %d did not verify", count, i)
+				}
+
+				// tampered value, key, and index
+				if VerifyProof(root, kv.Key, append(bytes.Clone(kv.Value), 'x'), p) {
+					t.Fatalf("count %d: expected tampered value to be rejected", count)
+				}
+				if VerifyProof(root, append(bytes.Clone(kv.Key), 'x'), kv.Value, p) {
+					t.Fatalf("count %d: expected tampered key to be rejected", count)
+				}
+				if count > 1 {
+					moved := p
+					moved.Index = uint64((i + 1) % count)
+					if VerifyProof(root, kv.Key, kv.Value, moved) {
+						t.Fatalf("count %d: expected moved index to be rejected", count)
+					}
+				}
+			}
+		}
+		if !bytes.Equal(roots[0], roots[1]) {
+			t.Fatalf("count %d: footer root does not match the computed root", count)
+		}
+	}
+}
+
+func TestMerkleProofFirstLast(t *testing.T) {
+	rdr, kvs := buildMerkleTestFile(t, 1000, true)
+	root, err := rdr.RootHash()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, kv := range []KV{kvs[0], kvs[len(kvs)-1]} {
+		p, err := rdr.Prove(kv.Key)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		data, err := p.MarshalBinary()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		var decoded Proof
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatal(err.Error())
+		}
+		if !VerifyProof(root, kv.Key, kv.Value, decoded) {
+			t.Fatalf("proof for %s did not verify", kv.Key)
+		}
+		if VerifyProof(root, kv.Key, []byte("tampered"), decoded) {
+			t.Fatalf("expected tampered value for %s to be rejected", kv.Key)
+		}
+		decoded.Path[len(decoded.Path)-1][0] ^= 1
+		if VerifyProof(root, kv.Key, kv.Value, decoded) {
+			t.Fatalf("expected tampered proof for %s to be rejected", kv.Key)
+		}
+		if err := decoded.UnmarshalBinary(data[:len(data)-1]); err == nil {
+			t.Fatal("expected an error for a truncated proof")
+		}
+	}
+
+	if _, err := rdr.Prove([]byte("missing")); err != ErrKeyNotFound {
+		t.Fatalf("expected key not found: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package kvfile
+
+import (
+	"bytes"
+	"sync"
+)
+
+// MessageUnmarshaler is a protobuf message which can be decoded.
+type MessageUnmarshaler interface {
+	// UnmarshalVT unmarshals the message.
+	UnmarshalVT(data []byte) error
+}
+
+// MessageMarshaler is a protobuf message which can be encoded.
+//
+// If the message also implements MarshalToSizedBufferVT it is encoded directly
+// to a pooled buffer of SizeVT bytes.
+type MessageMarshaler interface {
+	// MarshalVT marshals the message.
+	MarshalVT() ([]byte, error)
+	// SizeVT returns the size of the message when marshaled.
+	SizeVT() int
+}
+
+// messageBufPool contains buffers for marshaling messages.
+var messageBufPool sync.Pool
+
+// maxPooledMessageBuf is the largest buffer returned to the pool.
+const maxPooledMessageBuf = 64 * 1024
+
+// GetMessage looks up the value for the given key and decodes it to msg.
+//
+// Returns a *DecodeError if the value cannot be decoded.
+func (r *Reader) GetMessage(key []byte, msg MessageUnmarshaler) (bool, error) {
+	data, found, err := r.Get(key)
+	if err != nil || !found {
+		return found, err
+	}
+	if err := msg.UnmarshalVT(data); err != nil {
+		return true, &DecodeError{Key: bytes.Clone(key), Err: err}
+	}
+	return true, nil
+}
+
+// ScanPrefixMessages iterates over decoded messages with keys with a prefix.
+//
+// newMsg is called to construct the message for each value.
+// Returns a *DecodeError if a value cannot be decoded.
+func ScanPrefixMessages[M MessageUnmarshaler](r *Reader, prefix []byte, newMsg func() M, cb func(key []byte, msg M) error) error {
+	return r.ScanPrefix(prefix, func(key, data []byte) error {
+		msg := newMsg()
+		if err := msg.UnmarshalVT(data); err != nil {
+			return &DecodeError{Key: bytes.Clone(key), Err: err}
+		}
+		return cb(key, msg)
+	})
+}
+
+// WriteMessage encodes the message and writes it as the value for the key.
+//
+// The writer is closed if an error is returned while writing the value.
+func (w *Writer) WriteMessage(key []byte, msg MessageMarshaler) error {
+	sizedMsg, ok := msg.(interface {
+		MarshalToSizedBufferVT(data []byte) (int, error)
+	})
+	if !ok {
+		data, err := msg.MarshalVT()
+		if err != nil {
+			return err
+		}
+		return w.writeEntry(&IndexEntry{Key: key}, bytes.NewReader(data), int64(len(data)))
+	}
+
+	size := msg.SizeVT()
+	var buf []byte
+	if pooled, ok := messageBufPool.Get().(*[]byte); ok && cap(*pooled) >= size {
+		buf = (*pooled)[:size]
+	} else {
+		buf = make([]byte, size)
+	}
+	defer func() {
+		if cap(buf) <= maxPooledMessageBuf {
+			messageBufPool.Put(&buf)
+		}
+	}()
+
+	n, err := sizedMsg.MarshalToSizedBufferVT(buf)
+	if err != nil {
+		return err
+	}
+	// MarshalToSizedBufferVT writes to the end of the buffer
+	data := buf[size-n:]
+	return w.writeEntry(&IndexEntry{Key: key}, bytes.NewReader(data), int64(len(data)))
+}
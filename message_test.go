@@ -0,0 +1,89 @@
+package kvfile
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// marshalOnlyEntry hides MarshalToSizedBufferVT to test the MarshalVT path.
+type marshalOnlyEntry struct {
+	m *IndexEntry
+}
+
+func (e marshalOnlyEntry) MarshalVT() ([]byte, error) { return e.m.MarshalVT() }
+func (e marshalOnlyEntry) SizeVT() int                { return e.m.SizeVT() }
+
+func TestMessages(t *testing.T) {
+	msgs := map[string]*IndexEntry{
+		"entry/a":   {Key: []byte("key-a"), Offset: 1, Size: 2},
+		"entry/b":   {Key: bytes.Repeat([]byte("b"), 100), Meta: []byte("meta")},
+		"entry/c":   {},
+		"other":     {Tombstone: true},
+		"unsized/d": {Key: []byte("key-d"), ExpiresUnixMs: 4},
+	}
+	keys := make([]string, 0, len(msgs))
+	for key := range msgs {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	for _, key := range keys {
+		var err error
+		if key == "unsized/d" {
+			err = wr.WriteMessage([]byte(key), marshalOnlyEntry{m: msgs[key]})
+		} else {
+			err = wr.WriteMessage([]byte(key), msgs[key])
+		}
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.WriteValue([]byte("zzz"), bytes.NewReader([]byte{0xff})); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, key := range keys {
+		msg := &IndexEntry{}
+		found, err := rdr.GetMessage([]byte(key), msg)
+		if err != nil || !found || !msg.EqualVT(msgs[key]) {
+			t.Fatalf("unexpected message for %s: %v %v %v", key, msg, found, err)
+		}
+	}
+	if found, err := rdr.GetMessage([]byte("missing"), &IndexEntry{}); err != nil || found {
+		t.Fatalf("expected missing key: %v %v", found, err)
+	}
+	var decodeErr *DecodeError
+	if _, err := rdr.GetMessage([]byte("zzz"), &IndexEntry{}); !errors.As(err, &decodeErr) || string(decodeErr.Key) != "zzz" {
+		t.Fatalf("expected decode error: %v", err)
+	}
+
+	var scanned []string
+	err = ScanPrefixMessages(rdr, []byte("entry/"), func() *IndexEntry { return &IndexEntry{} }, func(key []byte, msg *IndexEntry) error {
+		if !msg.EqualVT(msgs[string(key)]) {
+			t.Fatalf("unexpected message for %s: %v", key, msg)
+		}
+		scanned = append(scanned, string(key))
+		return nil
+	})
+	if err != nil || !slices.Equal(scanned, keys[:3]) {
+		t.Fatalf("unexpected scan: %v %v", scanned, err)
+	}
+	err = ScanPrefixMessages(rdr, nil, func() *IndexEntry { return &IndexEntry{} }, func(key []byte, msg *IndexEntry) error {
+		return nil
+	})
+	if !errors.As(err, &decodeErr) || string(decodeErr.Key) != "zzz" {
+		t.Fatalf("expected decode error from scan: %v", err)
+	}
+}
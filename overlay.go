@@ -0,0 +1,192 @@
+package kvfile
+
+import (
+	"bytes"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// OverlayStore layers in-memory overrides over a Reader.
+//
+// Put overrides the value of a key and Delete hides a key of the base. Get,
+// Exists, and ScanPrefix check the overrides first and merge them with the
+// base in key order. Entries hidden by the base reader options and tombstones
+// in the base are not visible.
+//
+// The overrides are copy-on-write: reads never block and each write copies
+// the overrides, so it is intended for a small number of overrides which
+// change rarely. Concurrency safe.
+type OverlayStore struct {
+	// base is the base reader
+	base *Reader
+	// mtx serializes writes
+	mtx sync.Mutex
+	// overlay contains the current overrides, replaced on each write
+	overlay atomic.Pointer[overlayState]
+}
+
+// overlayState is an immutable set of overrides.
+type overlayState struct {
+	// entries contains the override for each key
+	entries map[string]overlayEntry
+	// keys contains the keys of entries in sorted order
+	keys []string
+}
+
+// overlayEntry is an override for a key.
+type overlayEntry struct {
+	// value is the value if not deleted
+	value []byte
+	// deleted indicates the key is hidden
+	deleted bool
+}
+
+// NewOverlayStore builds a new OverlayStore with no overrides.
+func NewOverlayStore(base *Reader) *OverlayStore {
+	s := &OverlayStore{base: base}
+	s.overlay.Store(&overlayState{})
+	return s
+}
+
+// GetBase returns the base reader.
+func (s *OverlayStore) GetBase() *Reader {
+	return s.base
+}
+
+// Put overrides the value for the key. The value is copied.
+func (s *OverlayStore) Put(key, value []byte) error {
+	if len(key) == 0 {
+		return errors.New("key cannot be empty")
+	}
+	s.update(string(key), &overlayEntry{value: bytes.Clone(value)})
+	return nil
+}
+
+// Delete hides the key, whether or not it exists in the base.
+func (s *OverlayStore) Delete(key []byte) error {
+	if len(key) == 0 {
+		return errors.New("key cannot be empty")
+	}
+	s.update(string(key), &overlayEntry{deleted: true})
+	return nil
+}
+
+// Revert removes the override for the key, making the base entry visible.
+func (s *OverlayStore) Revert(key []byte) {
+	s.update(string(key), nil)
+}
+
+// Overrides returns the number of keys with an override.
+func (s *OverlayStore) Overrides() int {
+	return len(s.overlay.Load().keys)
+}
+
+// update replaces the override for the key, removing it if entry is nil.
+func (s *OverlayStore) update(key string, entry *overlayEntry) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	prev := s.overlay.Load()
+	entries := maps.Clone(prev.entries)
+	if entries == nil {
+		entries = make(map[string]overlayEntry)
+	}
+	if entry != nil {
+		entries[key] = *entry
+	} else {
+		delete(entries, key)
+	}
+	s.overlay.Store(&overlayState{entries: entries, keys: slices.Sorted(maps.Keys(entries))})
+}
+
+// Get looks up the value for the given key.
+//
+// Returns nil, false, nil if not found or deleted.
+func (s *OverlayStore) Get(key []byte) ([]byte, bool, error) {
+	if entry, ok := s.overlay.Load().entries[string(key)]; ok {
+		if entry.deleted {
+			return nil, false, nil
+		}
+		return bytes.Clone(entry.value), true, nil
+	}
+	_, valueLen, indexEntry, indexEntryIdx, err := s.base.GetValuePosition(key)
+	if err != nil || valueLen < 0 || indexEntry.GetTombstone() {
+		return nil, false, err
+	}
+	value, err := s.base.GetWithEntry(indexEntry, indexEntryIdx)
+	if err != nil {
+		return nil, true, err
+	}
+	return value, true, nil
+}
+
+// Exists checks if the given key exists in the store.
+func (s *OverlayStore) Exists(key []byte) (bool, error) {
+	if entry, ok := s.overlay.Load().entries[string(key)]; ok {
+		return !entry.deleted, nil
+	}
+	_, valueLen, indexEntry, _, err := s.base.GetValuePosition(key)
+	return err == nil && valueLen >= 0 && !indexEntry.GetTombstone(), err
+}
+
+// ScanPrefix iterates over the key/value pairs with a prefix in key order.
+//
+// Uses the overrides at the start of the scan.
+func (s *OverlayStore) ScanPrefix(prefix []byte, cb func(key, value []byte) error) error {
+	overlay := s.overlay.Load()
+	start, _ := slices.BinarySearch(overlay.keys, string(prefix))
+	keys := overlay.keys[start:]
+
+	// emitOverlay calls the callback for the overrides before the key
+	emitOverlay := func(before []byte) error {
+		for len(keys) != 0 && strings.HasPrefix(keys[0], string(prefix)) && (before == nil || keys[0] < string(before)) {
+			key := keys[0]
+			keys = keys[1:]
+			if entry := overlay.entries[key]; !entry.deleted {
+				if err := cb([]byte(key), bytes.Clone(entry.value)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	err := s.base.ScanPrefixEntries(prefix, func(indexEntry *IndexEntry, indexEntryIdx int) error {
+		key := indexEntry.GetKey()
+		if err := emitOverlay(key); err != nil {
+			return err
+		}
+		if _, ok := overlay.entries[string(key)]; ok {
+			// overridden: emit the override instead
+			return emitOverlay(append(bytes.Clone(key), 0))
+		}
+		if indexEntry.GetTombstone() {
+			return nil
+		}
+		value, err := s.base.GetWithEntry(indexEntry, indexEntryIdx)
+		if err != nil {
+			return err
+		}
+		return cb(key, value)
+	})
+	if err != nil {
+		return err
+	}
+	return emitOverlay(nil)
+}
+
+// Size returns the number of entries in the base and the overrides.
+//
+// Overridden and deleted keys are counted, so Size is an upper bound of the
+// number of keys in the store.
+func (s *OverlayStore) Size() uint64 {
+	return s.base.Size() + uint64(len(s.overlay.Load().keys))
+}
+
+// _ is a type assertion
+var _ Store = ((*OverlayStore)(nil))
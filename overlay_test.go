@@ -0,0 +1,108 @@
+package kvfile
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestOverlayStore(t *testing.T) {
+	base := buildDeltaTestFile(t, map[string]string{
+		"flags/a":   "base-a",
+		"flags/c":   "base-c",
+		"flags/e":   "base-e",
+		"other":     "base-other",
+		"flagsx":    "base-flagsx",
+		"flags/tmp": "base-tmp",
+	}, nil)
+	store := NewOverlayStore(base)
+
+	// override, runtime delete of a base key, and new keys
+	for key, value := range map[string]string{"flags/a": "over-a", "flags/b": "over-b", "flags/d": "", "flags/z": "over-z"} {
+		if err := store.Put([]byte(key), []byte(value)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := store.Delete([]byte("flags/c")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := store.Delete([]byte("flags/missing")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if val, found, err := store.Get([]byte("flags/a")); err != nil || !found || string(val) != "over-a" {
+		t.Fatalf("expected the override: %q %v %v", val, found, err)
+	}
+	if val, found, err := store.Get([]byte("flags/c")); err != nil || found || val != nil {
+		t.Fatalf("expected the base key to be deleted: %q %v %v", val, found, err)
+	}
+	if found, err := store.Exists([]byte("flags/c")); err != nil || found {
+		t.Fatalf("expected the base key to be deleted: %v %v", found, err)
+	}
+	if val, found, err := store.Get([]byte("flags/e")); err != nil || !found || string(val) != "base-e" {
+		t.Fatalf("expected the base value: %q %v %v", val, found, err)
+	}
+
+	var scanned []string
+	err := store.ScanPrefix([]byte("flags/"), func(key, value []byte) error {
+		scanned = append(scanned, string(key)+"="+string(value))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []string{"flags/a=over-a", "flags/b=over-b", "flags/d=", "flags/e=base-e", "flags/tmp=base-tmp", "flags/z=over-z"}
+	if len(scanned) != len(expected) {
+		t.Fatalf("unexpected scan: %q", scanned)
+	}
+	for i := range expected {
+		if scanned[i] != expected[i] {
+			t.Fatalf("unexpected scan: %q", scanned)
+		}
+	}
+
+	// reverting makes the base entry visible
+	store.Revert([]byte("flags/c"))
+	if val, found, err := store.Get([]byte("flags/c")); err != nil || !found || string(val) != "base-c" {
+		t.Fatalf("expected the base value after revert: %q %v %v", val, found, err)
+	}
+	if store.Overrides() != 5 {
+		t.Fatalf("unexpected override count: %d", store.Overrides())
+	}
+}
+
+func TestOverlayStoreConcurrent(t *testing.T) {
+	base := buildDeltaTestFile(t, map[string]string{"a": "base-a", "b": "base-b"}, nil)
+	store := NewOverlayStore(base)
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 200 {
+				if i == 0 {
+					if err := store.Put([]byte("a"), []byte("over-"+strconv.Itoa(j))); err != nil {
+						t.Error(err.Error())
+						return
+					}
+					continue
+				}
+				val, found, err := store.Get([]byte("a"))
+				if err != nil || !found || !bytes.HasPrefix(val, []byte("over-")) && string(val) != "base-a" {
+					t.Errorf("unexpected value: %q %v %v", val, found, err)
+					return
+				}
+				var n int
+				if err := store.ScanPrefix(nil, func(key, value []byte) error {
+					n++
+					return nil
+				}); err != nil || n != 2 {
+					t.Errorf("unexpected scan: %d %v", n, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
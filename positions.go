@@ -0,0 +1,48 @@
+package kvfile
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// readIndexEntrySizePos reads the position of the index entry size varint from
+// the index entry positions list.
+//
+// Uses the positions list in memory if cached, see ReaderOptions.CachePositions.
+func (r *Reader) readIndexEntrySizePos(indexEntryIdx uint64) (uint64, error) {
+	if r.opts.CachePositions || r.positions.Load() != nil {
+		positions, err := r.loadPositions()
+		if err != nil {
+			return 0, err
+		}
+		if indexEntryIdx >= r.indexEntryCount {
+			return 0, errors.Errorf("out-of-bounds read of index entry position: %v", indexEntryIdx)
+		}
+		return binary.LittleEndian.Uint64(positions[8*indexEntryIdx:]), nil
+	}
+	buf := make([]byte, 8)
+	if _, err := r.rd.ReadAt(buf, int64(r.indexEntryIndexesPos+8*indexEntryIdx)); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buf), nil
+}
+
+// loadPositions returns the index entry positions list, reading it with a
+// single read on the first call.
+func (r *Reader) loadPositions() ([]byte, error) {
+	if positions := r.positions.Load(); positions != nil {
+		return *positions, nil
+	}
+	r.positionsMtx.Lock()
+	defer r.positionsMtx.Unlock()
+	if positions := r.positions.Load(); positions != nil {
+		return *positions, nil
+	}
+	positions := make([]byte, 8*r.indexEntryCount)
+	if _, err := r.rd.ReadAt(positions, int64(r.indexEntryIndexesPos)); err != nil {
+		return nil, err
+	}
+	r.positions.Store(&positions)
+	return positions, nil
+}
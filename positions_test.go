@@ -0,0 +1,159 @@
+package kvfile
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// regionReaderAt counts the calls to ReadAt starting in a region.
+type regionReaderAt struct {
+	rd         io.ReaderAt
+	start, end int64
+	reads      atomic.Int64
+}
+
+// ReadAt reads from the underlying reader and counts the call.
+func (c *regionReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= c.start && off < c.end {
+		c.reads.Add(1)
+	}
+	return c.rd.ReadAt(p, off)
+}
+
+// newPositionsReaderAt builds a ReaderAt counting reads of the positions list.
+func newPositionsReaderAt(t testing.TB, data []byte) *regionReaderAt {
+	rdr, err := NewReaderFromBytes(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	layout := rdr.Layout()
+	start := int64(layout.IndexEntryIndexesPos)
+	return &regionReaderAt{rd: bytes.NewReader(data), start: start, end: start + int64(8*layout.EntryCount)}
+}
+
+// getAllKeys looks up each key written by writeHashIndexTestFile.
+func getAllKeys(t testing.TB, rdr *Reader, n int) {
+	for i := 0; i < n; i++ {
+		val, found, err := rdr.Get([]byte("key-" + strconv.Itoa(i)))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !found || string(val) != "val-"+strconv.Itoa(i) {
+			t.Fatalf("unexpected value for key %v: %v %q", i, found, val)
+		}
+	}
+}
+
+func TestCachePositions(t *testing.T) {
+	n := 1000
+	data := writeHashIndexTestFile(t, n, false)
+
+	// without the cache each index entry read reads a position
+	counter := newPositionsReaderAt(t, data)
+	rdr, err := BuildReader(counter, uint64(len(data)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	getAllKeys(t, rdr, n)
+	if reads := counter.reads.Load(); reads < int64(n) {
+		t.Fatalf("expected at least %v position reads but got %v", n, reads)
+	}
+
+	// the cache reads the positions once on the first lookup
+	counter = newPositionsReaderAt(t, data)
+	rdr, err = BuildReaderWithOptions(counter, uint64(len(data)), ReaderOptions{CachePositions: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if rdr.positions.Load() != nil {
+		t.Fatal("expected no position reads before the first lookup")
+	}
+	counter.reads.Store(0)
+	getAllKeys(t, rdr, n)
+	if reads := counter.reads.Load(); reads != 1 {
+		t.Fatalf("expected 1 position read but got %v", reads)
+	}
+	if off := rdr.IndexEntryOffset(1); off <= rdr.IndexEntryOffset(0) {
+		t.Fatalf("unexpected index entry offset: %v", off)
+	}
+	if _, err := rdr.ReadIndexEntry(uint64(n)); err == nil {
+		t.Fatal("expected an error reading an out-of-bounds entry")
+	}
+}
+
+func TestPreloadPositions(t *testing.T) {
+	n := 100
+	data := writeHashIndexTestFile(t, n, false)
+
+	// preloaded when building the reader
+	counter := newPositionsReaderAt(t, data)
+	rdr, err := BuildReaderWithOptions(counter, uint64(len(data)), ReaderOptions{PreloadPositionsSize: uint64(8 * n)})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if rdr.positions.Load() == nil {
+		t.Fatal("expected the positions to be preloaded")
+	}
+	counter.reads.Store(0)
+	getAllKeys(t, rdr, n)
+	if reads := counter.reads.Load(); reads != 0 {
+		t.Fatalf("expected no position reads after preloading but got %v", reads)
+	}
+
+	// over the threshold: not cached
+	counter = newPositionsReaderAt(t, data)
+	rdr, err = BuildReaderWithOptions(counter, uint64(len(data)), ReaderOptions{PreloadPositionsSize: uint64(8*n - 1)})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if rdr.positions.Load() != nil {
+		t.Fatal("expected the positions not to be preloaded")
+	}
+}
+
+// remoteReaderAt simulates a remote ReaderAt with a latency for each read.
+type remoteReaderAt struct {
+	rd      io.ReaderAt
+	latency time.Duration
+}
+
+// ReadAt waits for the latency and reads from the underlying reader.
+func (r *remoteReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	time.Sleep(r.latency)
+	return r.rd.ReadAt(p, off)
+}
+
+func benchmarkRemoteGet(b *testing.B, opts ReaderOptions) {
+	n := 100000
+	data := writeHashIndexTestFile(b, n, false)
+	counter := &countingReaderAt{rd: &remoteReaderAt{rd: bytes.NewReader(data), latency: 50 * time.Microsecond}}
+	rdr, err := BuildReaderWithOptions(counter, uint64(len(data)), opts)
+	if err != nil {
+		b.Fatal(err.Error())
+	}
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = []byte("key-" + strconv.Itoa(i))
+	}
+	counter.reads.Store(0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, found, err := rdr.Get(keys[i%n])
+		if err != nil || !found {
+			b.Fatalf("get failed: %v %v", found, err)
+		}
+	}
+	b.ReportMetric(float64(counter.reads.Load())/float64(b.N), "readat/op")
+}
+
+func BenchmarkRemoteGet(b *testing.B) {
+	benchmarkRemoteGet(b, ReaderOptions{})
+}
+
+func BenchmarkRemoteGetCachePositions(b *testing.B) {
+	benchmarkRemoteGet(b, ReaderOptions{CachePositions: true})
+}
@@ -0,0 +1,140 @@
+package kvfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	protobuf_go_lite "github.com/aperturerobotics/protobuf-go-lite"
+)
+
+// maxValueHeaderSize is the maximum size of a value header.
+var maxValueHeaderSize = maxIndexEntrySize + maxMetaSize + 5*binary.MaxVarintLen64
+
+// RecoverIndex rebuilds a kvfile with a damaged or missing index.
+//
+// Only files written with value headers (see WriterOptions.ValueHeaders) can
+// be recovered: without the headers the values cannot be located, and
+// ErrNoValueHeaders is returned.
+//
+// Scans the values region from the start of the file and writes each pair
+// which can be validated to dst as a new kvfile with value headers and a fresh
+// index. Tombstones, expiry, and metadata are restored from the headers. If a
+// key occurs more than once the last occurrence is kept. Stops at the end of
+// the values or at the first record which cannot be parsed, for example if the
+// file was truncated.
+//
+// Values are streamed from src and are not buffered in memory.
+// Returns the number of recovered entries.
+func RecoverIndex(src io.ReaderAt, size uint64, dst io.Writer) (uint64, error) {
+	magic := make([]byte, len(valueHeadersMagic))
+	if size < uint64(len(magic)) {
+		return 0, ErrNoValueHeaders
+	}
+	if _, err := src.ReadAt(magic, 0); err != nil {
+		return 0, err
+	}
+	if err := checkValueHeadersMagic(magic); err != nil {
+		return 0, err
+	}
+
+	// scan the headers first to find the last occurrence of each key
+	type record struct {
+		entry     *IndexEntry
+		valuePos  uint64
+		valueSize uint64
+	}
+	var records []record
+	last := make(map[string]int)
+	pos := uint64(len(magic))
+	buf := make([]byte, maxValueHeaderSize)
+	for pos < size {
+		hdr := buf[:min(uint64(len(buf)), size-pos)]
+		n, err := src.ReadAt(hdr, int64(pos))
+		if err != nil && (err != io.EOF || n == 0) {
+			return 0, err
+		}
+		entry, valueSize, hdrLen, ok := parseValueHeader(hdr[:n])
+		if !ok {
+			break
+		}
+		valuePos := pos + hdrLen
+		if valueSize > size-valuePos {
+			// truncated value
+			break
+		}
+		last[string(entry.GetKey())] = len(records)
+		records = append(records, record{entry: entry, valuePos: valuePos, valueSize: valueSize})
+		pos = valuePos + valueSize
+	}
+
+	wr, err := NewWriterWithOptions(dst, WriterOptions{ValueHeaders: true})
+	if err != nil {
+		return 0, err
+	}
+
+	var recovered uint64
+	for i, rec := range records {
+		if last[string(rec.entry.GetKey())] != i {
+			continue
+		}
+		valueRdr := io.NewSectionReader(src, int64(rec.valuePos), int64(rec.valueSize))
+		if err := wr.writeEntry(rec.entry, valueRdr, int64(rec.valueSize)); err != nil {
+			return recovered, err
+		}
+		recovered++
+	}
+
+	return recovered, wr.Close()
+}
+
+// parseValueHeader parses a value header from the buffer.
+//
+// The returned entry has the key and attributes set and does not reference buf.
+// Returns ok=false if the buffer does not contain a valid value header or if
+// the buffer contains the end of values marker.
+func parseValueHeader(buf []byte) (entry *IndexEntry, valueSize, hdrLen uint64, ok bool) {
+	keyLen, n := protobuf_go_lite.ConsumeVarint(buf)
+	if n < 0 || keyLen == 0 {
+		return nil, 0, 0, false
+	}
+	keyLen--
+	if keyLen > uint64(maxIndexEntrySize) || keyLen > uint64(len(buf)-n) {
+		return nil, 0, 0, false
+	}
+	entry = &IndexEntry{Key: bytes.Clone(buf[n : n+int(keyLen)])}
+	hdrLen = uint64(n) + keyLen
+
+	valueSize, n = protobuf_go_lite.ConsumeVarint(buf[hdrLen:])
+	if n < 0 || valueSize > uint64(maxValueSize) {
+		return nil, 0, 0, false
+	}
+	hdrLen += uint64(n)
+
+	flags, n := protobuf_go_lite.ConsumeVarint(buf[hdrLen:])
+	if n < 0 {
+		return nil, 0, 0, false
+	}
+	hdrLen += uint64(n)
+	entry.Tombstone = flags&valueHeaderFlagTombstone != 0
+	if entry.Tombstone && valueSize != 0 {
+		return nil, 0, 0, false
+	}
+	if flags&valueHeaderFlagExpires != 0 {
+		entry.ExpiresUnixMs, n = protobuf_go_lite.ConsumeVarint(buf[hdrLen:])
+		if n < 0 {
+			return nil, 0, 0, false
+		}
+		hdrLen += uint64(n)
+	}
+	if flags&valueHeaderFlagMeta != 0 {
+		metaLen, n := protobuf_go_lite.ConsumeVarint(buf[hdrLen:])
+		if n < 0 || metaLen > uint64(maxMetaSize) || metaLen > uint64(len(buf))-hdrLen-uint64(n) {
+			return nil, 0, 0, false
+		}
+		hdrLen += uint64(n)
+		entry.Meta = bytes.Clone(buf[hdrLen : hdrLen+metaLen])
+		hdrLen += metaLen
+	}
+	return entry, valueSize, hdrLen, true
+}
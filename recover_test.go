@@ -0,0 +1,153 @@
+package kvfile
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecoverIndex(t *testing.T) {
+	const count = 10
+	var buf bytes.Buffer
+	wr, err := NewWriterWithOptions(&buf, WriterOptions{ValueHeaders: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// record the end position of each value
+	valueEnds := make([]uint64, count)
+	for i := 0; i < count; i++ {
+		key := []byte("test-" + strconv.Itoa(i))
+		if err := wr.WriteValue(key, strings.NewReader(strings.Repeat("v", 100+i))); err != nil {
+			t.Fatal(err.Error())
+		}
+		valueEnds[i] = wr.GetPos()
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	fixture := buf.Bytes()
+
+	cases := []struct {
+		size      uint64
+		recovered uint64
+	}{
+		// intact file
+		{uint64(len(fixture)), count},
+		// truncated index
+		{uint64(len(fixture)) - 100, count},
+		// truncated after the values
+		{valueEnds[count-1], count},
+		// truncated in the middle of a value
+		{valueEnds[4] - 10, 4},
+		// truncated at the end of a value
+		{valueEnds[4], 5},
+		// truncated in the middle of a header
+		{valueEnds[4] + 2, 5},
+		// only the magic remains
+		{uint64(len(valueHeadersMagic)), 0},
+	}
+	for _, c := range cases {
+		var out bytes.Buffer
+		recovered, err := RecoverIndex(bytes.NewReader(fixture[:c.size]), c.size, &out)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if recovered != c.recovered {
+			t.Fatalf("truncated at %v: expected %v recovered: %v", c.size, c.recovered, recovered)
+		}
+
+		rdr, err := BuildReader(bytes.NewReader(out.Bytes()), uint64(out.Len()))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if rdr.Size() != recovered {
+			t.Fatalf("expected %v entries in recovered file: %v", recovered, rdr.Size())
+		}
+		for i := 0; i < int(recovered); i++ {
+			val, found, err := rdr.Get([]byte("test-" + strconv.Itoa(i)))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if !found || string(val) != strings.Repeat("v", 100+i) {
+				t.Fatalf("unexpected recovered value %d: %v %d", i, found, len(val))
+			}
+		}
+	}
+
+	// files without value headers cannot be recovered
+	var plain bytes.Buffer
+	plainWr := NewWriter(&plain)
+	if err := plainWr.WriteValue([]byte("test"), strings.NewReader("value")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := plainWr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := RecoverIndex(bytes.NewReader(plain.Bytes()), uint64(plain.Len()), &bytes.Buffer{}); err != ErrNoValueHeaders {
+		t.Fatalf("expected ErrNoValueHeaders: %v", err)
+	}
+}
+
+func TestRecoverIndexAttrs(t *testing.T) {
+	expires := time.UnixMilli(4102444800000)
+	var buf bytes.Buffer
+	wr, err := NewWriterWithOptions(&buf, WriterOptions{ValueHeaders: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.WriteValue([]byte("dup"), strings.NewReader("first")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.WriteTombstone([]byte("deleted")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.WriteValueWithExpiry([]byte("expires"), strings.NewReader("val"), expires); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.WriteValueBytesMeta([]byte("meta"), []byte("val"), []byte("test-meta")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.WriteValue([]byte("dup"), strings.NewReader("second")); err != nil {
+		t.Fatal(err.Error())
+	}
+	// the duplicate key fails the index, leaving only the values
+	if err := wr.Close(); err == nil {
+		t.Fatal("expected duplicate key error")
+	}
+
+	var out bytes.Buffer
+	recovered, err := RecoverIndex(bytes.NewReader(buf.Bytes()), uint64(buf.Len()), &out)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if recovered != 4 {
+		t.Fatalf("expected 4 recovered: %v", recovered)
+	}
+
+	rdr, err := BuildReaderWithOptions(bytes.NewReader(out.Bytes()), uint64(out.Len()), ReaderOptions{ExposeTombstones: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	entries := make(map[string]*IndexEntry)
+	for i := uint64(0); i < rdr.Size(); i++ {
+		entry, err := rdr.ReadIndexEntry(i)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		entries[string(entry.GetKey())] = entry
+	}
+	if !entries["deleted"].GetTombstone() {
+		t.Fatal("expected tombstone to be recovered")
+	}
+	if got := entries["expires"].GetExpiresUnixMs(); got != uint64(expires.UnixMilli()) {
+		t.Fatalf("unexpected recovered expiry: %v", got)
+	}
+	if got := string(entries["meta"].GetMeta()); got != "test-meta" {
+		t.Fatalf("unexpected recovered meta: %q", got)
+	}
+	if val, found, err := rdr.Get([]byte("dup")); err != nil || !found || string(val) != "second" {
+		t.Fatalf("expected last duplicate to be kept: %v %q %v", found, string(val), err)
+	}
+}
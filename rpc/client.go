@@ -0,0 +1,201 @@
+package kvfile_rpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	srpc "github.com/aperturerobotics/starpc/srpc"
+	"github.com/pkg/errors"
+)
+
+// ErrFileNotFound is returned if the server does not have the file id.
+var ErrFileNotFound = errors.New("kvfile not found")
+
+// Client reads a remote kvfile with the KvFile service.
+//
+// The methods mirror the kvfile.Reader API with an additional context.
+type Client struct {
+	client SRPCKvFileClient
+	fileID string
+}
+
+// NewClient constructs a new Client for the file id.
+//
+// Use an empty file id if the server exposes a single file.
+func NewClient(c srpc.Client, fileID string) *Client {
+	return &Client{client: NewSRPCKvFileClient(c), fileID: fileID}
+}
+
+// checkStatus converts a response status to an error.
+//
+// Returns false if the key was not found.
+func checkStatus(status Status) (bool, error) {
+	switch status {
+	case Status_STATUS_OK:
+		return true, nil
+	case Status_STATUS_NOT_FOUND:
+		return false, nil
+	case Status_STATUS_FILE_NOT_FOUND:
+		return false, ErrFileNotFound
+	default:
+		return false, errors.Errorf("unknown status: %v", status.String())
+	}
+}
+
+// Get looks up the value for the given key.
+// Returns nil, false, nil if not found
+func (c *Client) Get(ctx context.Context, key []byte) ([]byte, bool, error) {
+	var buf bytes.Buffer
+	_, found, err := c.ReadTo(ctx, key, &buf)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	return buf.Bytes(), true, nil
+}
+
+// ReadTo reads the value for the given key to the writer.
+//
+// Returns number of bytes read, found, and any error.
+// Returns 0, false, nil if not found.
+func (c *Client) ReadTo(ctx context.Context, key []byte, to io.Writer) (int64, bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	strm, err := c.client.Get(ctx, &GetRequest{FileId: c.fileID, Key: key})
+	if err != nil {
+		return 0, false, err
+	}
+	defer strm.Close()
+
+	resp, err := strm.Recv()
+	if err != nil {
+		return 0, false, err
+	}
+	if found, err := checkStatus(resp.GetStatus()); err != nil || !found {
+		return 0, found, err
+	}
+	size := int64(resp.GetSize())
+	var nw int64
+	for {
+		if int64(len(resp.GetData())) > size-nw {
+			return nw, true, errors.New("received more data than the value size")
+		}
+		n, err := to.Write(resp.GetData())
+		nw += int64(n)
+		if err != nil {
+			return nw, true, err
+		}
+		if nw == size {
+			return nw, true, nil
+		}
+		resp, err = strm.Recv()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nw, true, err
+		}
+	}
+}
+
+// Exists checks if the given key exists in the store.
+func (c *Client) Exists(ctx context.Context, key []byte) (bool, error) {
+	resp, err := c.client.Exists(ctx, &KeyRequest{FileId: c.fileID, Key: key})
+	if err != nil {
+		return false, err
+	}
+	return checkStatus(resp.GetStatus())
+}
+
+// GetValueSize looks up the size of the value for the given key without reading the value.
+// Returns -1, nil if not found.
+func (c *Client) GetValueSize(ctx context.Context, key []byte) (int64, error) {
+	resp, err := c.client.GetValueSize(ctx, &KeyRequest{FileId: c.fileID, Key: key})
+	if err != nil {
+		return -1, err
+	}
+	if found, err := checkStatus(resp.GetStatus()); err != nil || !found {
+		return -1, err
+	}
+	return int64(resp.GetSize()), nil
+}
+
+// ScanPrefix iterates over key/value pairs with a prefix.
+//
+// If limit is not zero returns at most limit entries.
+func (c *Client) ScanPrefix(ctx context.Context, prefix []byte, limit uint64, cb func(key, value []byte) error) error {
+	return c.scanPrefix(ctx, &ScanPrefixRequest{FileId: c.fileID, Prefix: prefix, Limit: limit}, cb)
+}
+
+// ScanPrefixKeys iterates over keys with a prefix.
+//
+// If limit is not zero returns at most limit keys.
+func (c *Client) ScanPrefixKeys(ctx context.Context, prefix []byte, limit uint64, cb func(key []byte) error) error {
+	req := &ScanPrefixRequest{FileId: c.fileID, Prefix: prefix, Limit: limit, KeysOnly: true}
+	return c.scanPrefix(ctx, req, func(key, value []byte) error {
+		return cb(key)
+	})
+}
+
+// scanPrefix runs the scan request reassembling the chunked values.
+func (c *Client) scanPrefix(ctx context.Context, req *ScanPrefixRequest, cb func(key, value []byte) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	strm, err := c.client.ScanPrefix(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer strm.Close()
+
+	var key, value []byte
+	var size uint64
+	pending := false
+	for {
+		resp, err := strm.Recv()
+		if err == io.EOF {
+			if pending {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := checkStatus(resp.GetStatus()); err != nil {
+			return err
+		}
+		if !pending {
+			key, size = resp.GetKey(), resp.GetSize()
+			value = make([]byte, 0, size)
+		}
+		if uint64(len(value)+len(resp.GetData())) > size {
+			return errors.New("received more data than the value size")
+		}
+		value = append(value, resp.GetData()...)
+		pending = uint64(len(value)) < size
+		if pending {
+			continue
+		}
+		if err := cb(key, value); err != nil {
+			return err
+		}
+	}
+}
+
+// Stat returns statistics about the entries in the file.
+func (c *Client) Stat(ctx context.Context) (*kvfile.Stats, error) {
+	resp, err := c.client.Stat(ctx, &StatRequest{FileId: c.fileID})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := checkStatus(resp.GetStatus()); err != nil {
+		return nil, err
+	}
+	return &kvfile.Stats{
+		EntryCount:     resp.GetEntryCount(),
+		TotalValueSize: resp.GetTotalValueSize(),
+		MaxKeySize:     resp.GetMaxKeySize(),
+		MaxValueSize:   resp.GetMaxValueSize(),
+	}, nil
+}
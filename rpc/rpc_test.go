@@ -0,0 +1,141 @@
+package kvfile_rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	srpc "github.com/aperturerobotics/starpc/srpc"
+)
+
+// startTestServer starts a server over an in-memory pipe and returns a client.
+func startTestServer(t *testing.T, lookup FileLookupFunc, maxChunkSize int) srpc.Client {
+	mux := srpc.NewMux()
+	if err := NewServer(lookup, maxChunkSize).Register(mux); err != nil {
+		t.Fatal(err.Error())
+	}
+	return srpc.NewClient(srpc.NewServerPipe(srpc.NewServer(mux)))
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	large := bytes.Repeat([]byte("0123456789"), 1000)
+	vals := map[string][]byte{
+		"a/1":   []byte("one"),
+		"a/2":   large,
+		"a/3":   {},
+		"b/1":   []byte("other"),
+		"large": large,
+	}
+	fileKeys := make([][]byte, 0, len(vals))
+	for key := range vals {
+		fileKeys = append(fileKeys, []byte(key))
+	}
+	var buf bytes.Buffer
+	err := kvfile.Write(&buf, fileKeys, func(wr io.Writer, key []byte) (uint64, error) {
+		nw, err := wr.Write(vals[string(key)])
+		return uint64(nw), err
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rd, err := kvfile.BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// use a small chunk size to stream the large values
+	c := NewClient(startTestServer(t, SingleFile(rd), 256), "")
+
+	for key, expected := range vals {
+		val, found, err := c.Get(ctx, []byte(key))
+		if err != nil || !found || !bytes.Equal(val, expected) {
+			t.Fatalf("unexpected value for %s: %v %v", key, found, err)
+		}
+		size, err := c.GetValueSize(ctx, []byte(key))
+		if err != nil || size != int64(len(expected)) {
+			t.Fatalf("unexpected size for %s: %v %v", key, size, err)
+		}
+		if exists, err := c.Exists(ctx, []byte(key)); err != nil || !exists {
+			t.Fatalf("expected %s to exist: %v", key, err)
+		}
+	}
+	if val, found, err := c.Get(ctx, []byte("missing")); err != nil || found || val != nil {
+		t.Fatalf("expected missing key: %v %v", found, err)
+	}
+	if size, err := c.GetValueSize(ctx, []byte("missing")); err != nil || size != -1 {
+		t.Fatalf("expected missing size: %v %v", size, err)
+	}
+	if exists, err := c.Exists(ctx, []byte("missing")); err != nil || exists {
+		t.Fatalf("expected missing key to not exist: %v", err)
+	}
+
+	var keys []string
+	err = c.ScanPrefix(ctx, []byte("a/"), 0, func(key, value []byte) error {
+		if !bytes.Equal(value, vals[string(key)]) {
+			t.Fatalf("unexpected value for %s", key)
+		}
+		keys = append(keys, string(key))
+		return nil
+	})
+	if err != nil || !slices.Equal(keys, []string{"a/1", "a/2", "a/3"}) {
+		t.Fatalf("unexpected scan: %v %v", keys, err)
+	}
+	keys = nil
+	err = c.ScanPrefixKeys(ctx, nil, 2, func(key []byte) error {
+		keys = append(keys, string(key))
+		return nil
+	})
+	if err != nil || !slices.Equal(keys, []string{"a/1", "a/2"}) {
+		t.Fatalf("unexpected limited scan: %v %v", keys, err)
+	}
+
+	stats, err := c.Stat(ctx)
+	if err != nil || stats.EntryCount != uint64(len(vals)) || stats.MaxValueSize != uint64(len(large)) {
+		t.Fatalf("unexpected stats: %+v %v", stats, err)
+	}
+}
+
+func TestClientFiles(t *testing.T) {
+	ctx := context.Background()
+	files := make(map[string]*kvfile.Reader)
+	for fileID, val := range map[string]string{"one": "1", "two": "2"} {
+		var buf bytes.Buffer
+		err := kvfile.Write(&buf, [][]byte{[]byte("key")}, func(wr io.Writer, key []byte) (uint64, error) {
+			nw, err := io.WriteString(wr, val)
+			return uint64(nw), err
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		rd, err := kvfile.BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		files[fileID] = rd
+	}
+	client := startTestServer(t, FileMap(files), 0)
+	for _, fileID := range []string{"one", "two"} {
+		val, found, err := NewClient(client, fileID).Get(ctx, []byte("key"))
+		if err != nil || !found || string(val) != map[string]string{"one": "1", "two": "2"}[fileID] {
+			t.Fatalf("unexpected value in %s: %q %v %v", fileID, val, found, err)
+		}
+	}
+
+	missing := NewClient(client, "missing")
+	if _, _, err := missing.Get(ctx, []byte("key")); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("expected ErrFileNotFound from Get: %v", err)
+	}
+	if _, err := missing.Exists(ctx, []byte("key")); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("expected ErrFileNotFound from Exists: %v", err)
+	}
+	if err := missing.ScanPrefixKeys(ctx, nil, 0, func(key []byte) error { return nil }); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("expected ErrFileNotFound from ScanPrefixKeys: %v", err)
+	}
+	if _, err := missing.Stat(ctx); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("expected ErrFileNotFound from Stat: %v", err)
+	}
+}
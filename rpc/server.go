@@ -0,0 +1,229 @@
+package kvfile_rpc
+
+import (
+	"context"
+	"io"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	srpc "github.com/aperturerobotics/starpc/srpc"
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxChunkSize is the default maximum size of value chunks.
+const DefaultMaxChunkSize = 64 * 1024
+
+// FileLookupFunc returns the Reader for a file id.
+//
+// Returns nil, nil if the file was not found.
+type FileLookupFunc func(ctx context.Context, fileID string) (*kvfile.Reader, error)
+
+// SingleFile returns a FileLookupFunc serving the Reader for the empty file id.
+func SingleFile(rd *kvfile.Reader) FileLookupFunc {
+	return func(ctx context.Context, fileID string) (*kvfile.Reader, error) {
+		if fileID != "" {
+			return nil, nil
+		}
+		return rd, nil
+	}
+}
+
+// FileMap returns a FileLookupFunc serving the Readers keyed by file id.
+//
+// The map must not be modified while the server is running.
+func FileMap(files map[string]*kvfile.Reader) FileLookupFunc {
+	return func(ctx context.Context, fileID string) (*kvfile.Reader, error) {
+		return files[fileID], nil
+	}
+}
+
+// Server implements the KvFile service.
+type Server struct {
+	lookup       FileLookupFunc
+	maxChunkSize int
+}
+
+// NewServer constructs a new Server.
+//
+// If maxChunkSize is zero or less uses DefaultMaxChunkSize.
+func NewServer(lookup FileLookupFunc, maxChunkSize int) *Server {
+	if maxChunkSize <= 0 {
+		maxChunkSize = DefaultMaxChunkSize
+	}
+	return &Server{lookup: lookup, maxChunkSize: maxChunkSize}
+}
+
+// Register registers the Server with the Mux.
+func (s *Server) Register(mux srpc.Mux) error {
+	return SRPCRegisterKvFile(mux, s)
+}
+
+// Get streams the value for a key in chunks.
+func (s *Server) Get(req *GetRequest, strm SRPCKvFile_GetStream) error {
+	rd, err := s.lookup(strm.Context(), req.GetFileId())
+	if err != nil {
+		return err
+	}
+	if rd == nil {
+		return strm.Send(&GetResponse{Status: Status_STATUS_FILE_NOT_FOUND})
+	}
+	_, valueLen, indexEntry, indexEntryIdx, err := rd.GetValuePosition(req.GetKey())
+	if err != nil {
+		return err
+	}
+	if valueLen < 0 {
+		return strm.Send(&GetResponse{Status: Status_STATUS_NOT_FOUND})
+	}
+	valueRdr, err := rd.GetValueReaderWithEntry(indexEntry, indexEntryIdx)
+	if err != nil {
+		return err
+	}
+	first := &GetResponse{Size: uint64(valueLen)}
+	return s.sendChunks(strm.Context(), valueRdr, valueLen, req.GetChunkSize(), func(data []byte) error {
+		if first != nil {
+			first.Data = data
+			resp := first
+			first = nil
+			return strm.Send(resp)
+		}
+		return strm.Send(&GetResponse{Data: data})
+	})
+}
+
+// Exists checks if a key exists.
+func (s *Server) Exists(ctx context.Context, req *KeyRequest) (*ExistsResponse, error) {
+	rd, err := s.lookup(ctx, req.GetFileId())
+	if err != nil {
+		return nil, err
+	}
+	if rd == nil {
+		return &ExistsResponse{Status: Status_STATUS_FILE_NOT_FOUND}, nil
+	}
+	found, err := rd.Exists(req.GetKey())
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return &ExistsResponse{Status: Status_STATUS_NOT_FOUND}, nil
+	}
+	return &ExistsResponse{}, nil
+}
+
+// GetValueSize returns the size of the value for a key.
+func (s *Server) GetValueSize(ctx context.Context, req *KeyRequest) (*GetValueSizeResponse, error) {
+	rd, err := s.lookup(ctx, req.GetFileId())
+	if err != nil {
+		return nil, err
+	}
+	if rd == nil {
+		return &GetValueSizeResponse{Status: Status_STATUS_FILE_NOT_FOUND}, nil
+	}
+	size, err := rd.GetValueSize(req.GetKey())
+	if err != nil {
+		return nil, err
+	}
+	if size < 0 {
+		return &GetValueSizeResponse{Status: Status_STATUS_NOT_FOUND}, nil
+	}
+	return &GetValueSizeResponse{Size: uint64(size)}, nil
+}
+
+// errScanLimit stops a scan when the limit is reached.
+var errScanLimit = errors.New("scan limit reached")
+
+// ScanPrefix streams the key/value pairs with a prefix.
+func (s *Server) ScanPrefix(req *ScanPrefixRequest, strm SRPCKvFile_ScanPrefixStream) error {
+	ctx := strm.Context()
+	rd, err := s.lookup(ctx, req.GetFileId())
+	if err != nil {
+		return err
+	}
+	if rd == nil {
+		return strm.Send(&ScanPrefixResponse{Status: Status_STATUS_FILE_NOT_FOUND})
+	}
+	limit := req.GetLimit()
+	var count uint64
+	err = rd.ScanPrefixEntries(req.GetPrefix(), func(indexEntry *kvfile.IndexEntry, indexEntryIdx int) error {
+		if limit != 0 && count >= limit {
+			return errScanLimit
+		}
+		count++
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		key := indexEntry.GetKey()
+		if req.GetKeysOnly() {
+			return strm.Send(&ScanPrefixResponse{Key: key})
+		}
+		valueRdr, err := rd.GetValueReaderWithEntry(indexEntry, indexEntryIdx)
+		if err != nil {
+			return err
+		}
+		first := &ScanPrefixResponse{Key: key, Size: uint64(valueRdr.Size())}
+		return s.sendChunks(ctx, valueRdr, valueRdr.Size(), req.GetChunkSize(), func(data []byte) error {
+			if first != nil {
+				first.Data = data
+				resp := first
+				first = nil
+				return strm.Send(resp)
+			}
+			return strm.Send(&ScanPrefixResponse{Data: data})
+		})
+	})
+	if err == errScanLimit {
+		err = nil
+	}
+	return err
+}
+
+// Stat returns statistics about a file.
+func (s *Server) Stat(ctx context.Context, req *StatRequest) (*StatResponse, error) {
+	rd, err := s.lookup(ctx, req.GetFileId())
+	if err != nil {
+		return nil, err
+	}
+	if rd == nil {
+		return &StatResponse{Status: Status_STATUS_FILE_NOT_FOUND}, nil
+	}
+	stats, err := rd.Stats()
+	if err != nil {
+		return nil, err
+	}
+	return &StatResponse{
+		EntryCount:     stats.EntryCount,
+		TotalValueSize: stats.TotalValueSize,
+		MaxKeySize:     stats.MaxKeySize,
+		MaxValueSize:   stats.MaxValueSize,
+	}, nil
+}
+
+// sendChunks reads the value in chunks and calls send with each chunk.
+//
+// send is called once with an empty chunk if the value is empty. The chunk
+// buffer is reused after send returns.
+func (s *Server) sendChunks(ctx context.Context, rd io.Reader, size int64, chunkSize uint32, send func(data []byte) error) error {
+	maxChunk := int64(s.maxChunkSize)
+	if chunkSize != 0 && int64(chunkSize) < maxChunk {
+		maxChunk = int64(chunkSize)
+	}
+	buf := make([]byte, min(size, maxChunk))
+	for sent := int64(0); ; {
+		data := buf[:min(size-sent, maxChunk)]
+		n := int64(len(data))
+		if _, err := io.ReadFull(rd, data); err != nil {
+			return err
+		}
+		if err := send(data); err != nil {
+			return err
+		}
+		sent += n
+		if sent >= size {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// _ is a type assertion
+var _ SRPCKvFileServer = ((*Server)(nil))
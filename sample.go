@@ -0,0 +1,58 @@
+package kvfile
+
+import (
+	"math/rand/v2"
+	"slices"
+)
+
+// SampleEntries calls the callback with up to n index entries with the key
+// prefix chosen uniformly at random by rng, in key order.
+//
+// Reads only the sampled index entries. If fewer than n entries have the
+// prefix, calls the callback with all of them. Entries hidden by the reader
+// options are sampled and skipped, which can return fewer than n entries.
+func (r *Reader) SampleEntries(prefix []byte, n int, rng *rand.Rand, cb func(indexEntry *IndexEntry, indexEntryIdx int) error) error {
+	start, end, err := r.SearchPrefixRange(prefix)
+	if err != nil || n <= 0 {
+		return err
+	}
+	for _, idx := range sampleIndexes(start, end, uint64(n), rng) {
+		indexEntry, err := r.ReadIndexEntry(idx)
+		if err != nil {
+			return err
+		}
+		if r.isEntryHidden(indexEntry) {
+			continue
+		}
+		if err := cb(indexEntry, int(idx)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sampleIndexes returns n distinct indexes in [start, end) in sorted order.
+//
+// Uses Floyd's algorithm to choose the indexes in O(n).
+func sampleIndexes(start, end, n uint64, rng *rand.Rand) []uint64 {
+	count := end - start
+	if n >= count {
+		idxs := make([]uint64, 0, count)
+		for i := start; i < end; i++ {
+			idxs = append(idxs, i)
+		}
+		return idxs
+	}
+	chosen := make(map[uint64]struct{}, n)
+	idxs := make([]uint64, 0, n)
+	for j := count - n; j < count; j++ {
+		idx := rng.Uint64N(j + 1)
+		if _, ok := chosen[idx]; ok {
+			idx = j
+		}
+		chosen[idx] = struct{}{}
+		idxs = append(idxs, start+idx)
+	}
+	slices.Sort(idxs)
+	return idxs
+}
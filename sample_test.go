@@ -0,0 +1,61 @@
+package kvfile
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestSampleEntries(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	for i := 0; i < 100; i++ {
+		key := "a/" + strconv.Itoa(i)
+		if err := wr.WriteValue([]byte(key), bytes.NewReader([]byte(key))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.WriteValue([]byte("b/0"), bytes.NewReader(nil)); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	sample := func(prefix string, n int, seed uint64) []string {
+		var keys []string
+		err := rdr.SampleEntries([]byte(prefix), n, rand.New(rand.NewPCG(seed, 0)), func(indexEntry *IndexEntry, indexEntryIdx int) error {
+			keys = append(keys, string(indexEntry.GetKey()))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return keys
+	}
+
+	keys := sample("a/", 10, 1)
+	if len(keys) != 10 {
+		t.Fatalf("expected 10 keys: %v", keys)
+	}
+	for i, key := range keys {
+		if !bytes.HasPrefix([]byte(key), []byte("a/")) || (i != 0 && keys[i-1] >= key) {
+			t.Fatalf("expected distinct sorted keys with the prefix: %v", keys)
+		}
+	}
+	if again := sample("a/", 10, 1); !slices.Equal(keys, again) {
+		t.Fatalf("expected the same sample with the same seed: %v != %v", keys, again)
+	}
+	if keys := sample("b/", 10, 1); len(keys) != 1 || keys[0] != "b/0" {
+		t.Fatalf("expected all keys with the prefix: %v", keys)
+	}
+	if keys := sample("c/", 10, 1); len(keys) != 0 {
+		t.Fatalf("expected no keys: %v", keys)
+	}
+}
@@ -0,0 +1,91 @@
+package kvfile
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+
+	"github.com/pkg/errors"
+)
+
+// A signature is the Ed25519 signature of:
+//
+//	["kvfile-signature-v1" 0x00][content digest]
+//
+// where the content digest is the ContentDigest of the file with SHA-256. The
+// signature depends only on the keys and values, so rewriting the file with a
+// different layout or compression does not invalidate it. Tombstones are
+// signed, the entry expiry, metadata, and footer blocks are not.
+//
+// The signature footer block contains the signature, see
+// WriterOptions.SignatureKey.
+
+// signatureContext is prepended to the content digest when signing.
+var signatureContext = []byte("kvfile-signature-v1\x00")
+
+// ErrInvalidSignature is returned if a signature does not match the file.
+var ErrInvalidSignature = errors.New("invalid kvfile signature")
+
+// ErrNoSignature is returned by VerifyEmbeddedSignature if the file has no
+// signature footer block.
+var ErrNoSignature = errors.New("kvfile has no embedded signature")
+
+// Sign returns a detached signature of the contents of the file.
+//
+// The values are streamed to compute the content digest.
+func Sign(r *Reader, priv ed25519.PrivateKey) ([]byte, error) {
+	if len(priv) != ed25519.PrivateKeySize {
+		return nil, errors.Errorf("invalid ed25519 private key size: %v", len(priv))
+	}
+	digest, err := ContentDigest(r, sha256.New)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.Sign(priv, signatureMessage(digest)), nil
+}
+
+// VerifySignature checks a detached signature of the contents of the file.
+//
+// The values are streamed to compute the content digest. Returns
+// ErrInvalidSignature if the signature does not match.
+func VerifySignature(r *Reader, pub ed25519.PublicKey, sig []byte) error {
+	if len(pub) != ed25519.PublicKeySize {
+		return errors.Errorf("invalid ed25519 public key size: %v", len(pub))
+	}
+	digest, err := ContentDigest(r, sha256.New)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, signatureMessage(digest), sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// VerifyEmbeddedSignature checks the signature in the signature footer block.
+//
+// Returns ErrNoSignature if the file has no signature footer block and
+// ErrInvalidSignature if the signature does not match.
+func (r *Reader) VerifyEmbeddedSignature(pub ed25519.PublicKey) error {
+	sig, found, err := r.FooterBlock(FooterBlockSignature)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNoSignature
+	}
+	return VerifySignature(r, pub, sig)
+}
+
+// signatureMessage returns the message to sign for the content digest.
+func signatureMessage(digest []byte) []byte {
+	msg := make([]byte, 0, len(signatureContext)+len(digest))
+	msg = append(msg, signatureContext...)
+	return append(msg, digest...)
+}
+
+// buildSignatureBlock signs the content digest and builds the signature footer
+// block.
+func buildSignatureBlock(digest []byte, priv ed25519.PrivateKey) *footerBlockData {
+	sig := ed25519.Sign(priv, signatureMessage(digest))
+	return &footerBlockData{typ: FooterBlockSignature, data: sig}
+}
@@ -0,0 +1,112 @@
+package kvfile
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+)
+
+// writeSignTestFile writes a file with the test values and builds a Reader.
+func writeSignTestFile(t *testing.T, opts WriterOptions, tamper bool) *Reader {
+	var buf bytes.Buffer
+	wr, err := NewWriterWithOptions(&buf, opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if err := wr.WriteValue([]byte(key), bytes.NewReader([]byte("value-"+key))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	data := buf.Bytes()
+	if tamper {
+		data[bytes.Index(data, []byte("value-b"))+6] = 'x'
+	}
+	rdr, err := BuildReader(bytes.NewReader(data), uint64(len(data)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return rdr
+}
+
+func TestSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	rdr := writeSignTestFile(t, WriterOptions{}, false)
+	sig, err := Sign(rdr, priv)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := VerifySignature(rdr, pub, sig); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// a different layout has the same contents
+	relayout := writeSignTestFile(t, WriterOptions{ValueHeaders: true, HashIndex: true}, false)
+	if err := VerifySignature(relayout, pub, sig); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tampered := writeSignTestFile(t, WriterOptions{}, true)
+	if err := VerifySignature(tampered, pub, sig); err != ErrInvalidSignature {
+		t.Fatalf("expected invalid signature for tampered value: %v", err)
+	}
+	if err := VerifySignature(rdr, otherPub, sig); err != ErrInvalidSignature {
+		t.Fatalf("expected invalid signature for wrong key: %v", err)
+	}
+	if err := VerifySignature(rdr, pub, sig[:10]); err != ErrInvalidSignature {
+		t.Fatalf("expected invalid signature for truncated signature: %v", err)
+	}
+	if _, err := Sign(rdr, priv[:10]); err == nil {
+		t.Fatal("expected an error for an invalid private key")
+	}
+}
+
+func TestEmbeddedSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	rdr := writeSignTestFile(t, WriterOptions{SignatureKey: priv}, false)
+	if err := rdr.VerifyEmbeddedSignature(pub); err != nil {
+		t.Fatal(err.Error())
+	}
+	// the embedded signature matches a detached signature
+	sig, _, err := rdr.FooterBlock(FooterBlockSignature)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := VerifySignature(writeSignTestFile(t, WriterOptions{}, false), pub, sig); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tampered := writeSignTestFile(t, WriterOptions{SignatureKey: priv}, true)
+	if err := tampered.VerifyEmbeddedSignature(pub); err != ErrInvalidSignature {
+		t.Fatalf("expected invalid signature for tampered value: %v", err)
+	}
+	if err := rdr.VerifyEmbeddedSignature(otherPub); err != ErrInvalidSignature {
+		t.Fatalf("expected invalid signature for wrong key: %v", err)
+	}
+	if err := writeSignTestFile(t, WriterOptions{}, false).VerifyEmbeddedSignature(pub); err != ErrNoSignature {
+		t.Fatalf("expected no signature: %v", err)
+	}
+	if _, err := NewWriterWithOptions(&bytes.Buffer{}, WriterOptions{SignatureKey: priv[:10]}); err == nil {
+		t.Fatal("expected an error for an invalid private key")
+	}
+}
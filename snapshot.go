@@ -0,0 +1,78 @@
+package kvfile
+
+import (
+	"bytes"
+	"io"
+	"slices"
+
+	protobuf_go_lite "github.com/aperturerobotics/protobuf-go-lite"
+	"github.com/pkg/errors"
+)
+
+// ErrSnapshotTooLarge is returned by ToSnapshot if the encoded snapshot would
+// exceed the maximum size.
+var ErrSnapshotTooLarge = errors.New("snapshot too large")
+
+// ToSnapshot reads the key/value pairs of the file to a Snapshot.
+//
+// maxBytes limits the encoded size of the snapshot (see Snapshot.SizeVT), zero
+// for no limit. The limit is checked with the value sizes in the index before
+// reading each value: returns an error wrapping ErrSnapshotTooLarge without
+// reading the rest of the values if exceeded. Entries hidden by the reader
+// options are skipped.
+func ToSnapshot(r *Reader, maxBytes uint64) (*Snapshot, error) {
+	snap := &Snapshot{}
+	var snapSize uint64
+	err := r.ScanPrefixEntries(nil, func(indexEntry *IndexEntry, indexEntryIdx int) error {
+		entrySize := snapshotEntrySize(uint64(len(indexEntry.GetKey()))) + snapshotEntrySize(indexEntry.GetSize())
+		snapSize += snapshotEntrySize(entrySize)
+		if maxBytes != 0 && snapSize > maxBytes {
+			return errors.Wrapf(ErrSnapshotTooLarge, "%v > %v bytes", snapSize, maxBytes)
+		}
+		value, err := r.GetWithEntry(indexEntry, indexEntryIdx)
+		if err != nil {
+			return err
+		}
+		snap.Entries = append(snap.Entries, &SnapshotEntry{Key: indexEntry.GetKey(), Value: value})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// snapshotEntrySize returns the encoded size of a length-delimited field.
+//
+// Empty fields are omitted from the encoding.
+func snapshotEntrySize(size uint64) uint64 {
+	if size == 0 {
+		return 0
+	}
+	return 1 + uint64(protobuf_go_lite.SizeOfVarint(size)) + size
+}
+
+// FromSnapshot writes the key/value pairs in the Snapshot to a kvfile.
+//
+// The entries do not need to be sorted. Returns an error if a key is empty or
+// repeated.
+func FromSnapshot(w io.Writer, s *Snapshot) error {
+	entries := slices.Clone(s.GetEntries())
+	slices.SortStableFunc(entries, func(a, b *SnapshotEntry) int {
+		return bytes.Compare(a.GetKey(), b.GetKey())
+	})
+	wr := NewWriter(w)
+	for i, entry := range entries {
+		key, value := entry.GetKey(), entry.GetValue()
+		if len(key) == 0 {
+			return errors.New("snapshot key cannot be empty")
+		}
+		if i != 0 && bytes.Equal(key, entries[i-1].GetKey()) {
+			return errors.Errorf("duplicate key in snapshot: %q", key)
+		}
+		if err := wr.writeEntry(&IndexEntry{Key: key}, bytes.NewReader(value), int64(len(value))); err != nil {
+			return err
+		}
+	}
+	return wr.Close()
+}
@@ -0,0 +1,73 @@
+package kvfile
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestSnapshot(t *testing.T) {
+	vals := map[string]string{
+		"a":       "value-a",
+		"b":       "",
+		"c":       strings.Repeat("c", 300),
+		"nul\x00": "binary\x00value",
+	}
+	rdr := buildDeltaTestFile(t, vals, nil)
+
+	snap, err := ToSnapshot(rdr, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(snap.GetEntries()) != len(vals) {
+		t.Fatalf("expected %d entries: %d", len(vals), len(snap.GetEntries()))
+	}
+	data, err := snap.MarshalVT()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the size guard matches the encoded size exactly
+	if _, err := ToSnapshot(rdr, uint64(len(data))); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := ToSnapshot(rdr, uint64(len(data))-1); !errors.Is(err, ErrSnapshotTooLarge) {
+		t.Fatalf("expected snapshot too large: %v", err)
+	}
+
+	// round trip through the encoding with the entries in reverse order
+	decoded := &Snapshot{}
+	if err := decoded.UnmarshalVT(data); err != nil {
+		t.Fatal(err.Error())
+	}
+	for i, j := 0, len(decoded.Entries)-1; i < j; i, j = i+1, j-1 {
+		decoded.Entries[i], decoded.Entries[j] = decoded.Entries[j], decoded.Entries[i]
+	}
+	var buf bytes.Buffer
+	if err := FromSnapshot(&buf, decoded); err != nil {
+		t.Fatal(err.Error())
+	}
+	result, err := NewReaderFromBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if equal, err := Equal(result, rdr); err != nil || !equal {
+		t.Fatalf("expected the round trip to be equal: %v %v", equal, err)
+	}
+
+	// duplicate and empty keys
+	dup := &Snapshot{Entries: []*SnapshotEntry{
+		{Key: []byte("a"), Value: []byte("1")},
+		{Key: []byte("b"), Value: []byte("2")},
+		{Key: []byte("a"), Value: []byte("3")},
+	}}
+	if err := FromSnapshot(&bytes.Buffer{}, dup); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Fatalf("expected duplicate key error: %v", err)
+	}
+	empty := &Snapshot{Entries: []*SnapshotEntry{{Value: []byte("1")}}}
+	if err := FromSnapshot(&bytes.Buffer{}, empty); err == nil {
+		t.Fatal("expected empty key error")
+	}
+}
@@ -0,0 +1,194 @@
+package kvfile
+
+import (
+	"bytes"
+	"io"
+	"iter"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// SortedIterator iterates over key/value pairs in strictly increasing key order.
+//
+// Next advances to the next pair and returns false at the end or on error.
+// Key may return a buffer reused by the next call to Next. Value returns a
+// reader for the value and the size of the value, or -1 if unknown.
+type SortedIterator interface {
+	// Next advances to the next pair, returning false if there are no more.
+	Next() bool
+	// Key returns the key of the current pair.
+	Key() []byte
+	// Value returns a reader for the value of the current pair and the size.
+	Value() (io.Reader, int64, error)
+	// Err returns the error which stopped the iterator, if any.
+	Err() error
+}
+
+// KeyOrderError is returned by WriteFromSortedIterator, or by the Writer when
+// computing the content digest, if a key is not after the previous key.
+type KeyOrderError struct {
+	// Prev is the previous key.
+	Prev []byte
+	// Key is the key which is not after Prev.
+	Key []byte
+}
+
+// Error returns the error string.
+func (e *KeyOrderError) Error() string {
+	return "key " + strconv.Quote(string(e.Key)) + " is not after " + strconv.Quote(string(e.Prev))
+}
+
+// WriteFromSortedIterator writes the key/value pairs from the iterator to a
+// kvfile.
+//
+// The values are streamed from the iterator. Returns a *KeyOrderError if the
+// keys are not in strictly increasing order. If the value reader implements
+// io.Closer it is closed after the value is written.
+func WriteFromSortedIterator(w io.Writer, it SortedIterator) error {
+	wr := NewWriter(w)
+	var prev []byte
+	for it.Next() {
+		// the iterator may reuse the key buffer
+		key := bytes.Clone(it.Key())
+		if prev != nil && bytes.Compare(key, prev) <= 0 {
+			return &KeyOrderError{Prev: prev, Key: key}
+		}
+		prev = key
+
+		valueRdr, valueSize, err := it.Value()
+		if err != nil {
+			return errors.Wrapf(err, "read value for %q", key)
+		}
+		if valueSize >= 0 {
+			err = wr.writeEntry(&IndexEntry{Key: key}, &sizedReader{r: valueRdr, remaining: valueSize}, valueSize)
+		} else {
+			err = wr.WriteValue(key, valueRdr)
+		}
+		if closer, ok := valueRdr.(io.Closer); ok {
+			if cerr := closer.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			return errors.Wrapf(err, "write value for %q", key)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	return wr.Close()
+}
+
+// sizedReader returns an error if the reader does not return exactly the
+// remaining number of bytes.
+type sizedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+// Read reads from the reader.
+func (s *sizedReader) Read(p []byte) (int, error) {
+	if s.remaining == 0 {
+		// check that the value has ended
+		var extra [1]byte
+		n, err := s.r.Read(extra[:])
+		if n != 0 {
+			return 0, errors.New("value is longer than the size")
+		}
+		if err == nil {
+			err = io.EOF
+		}
+		return 0, err
+	}
+	if int64(len(p)) > s.remaining {
+		p = p[:s.remaining]
+	}
+	n, err := s.r.Read(p)
+	s.remaining -= int64(n)
+	if err == io.EOF && s.remaining != 0 {
+		err = errors.Wrap(io.ErrUnexpectedEOF, "value is shorter than the size")
+	}
+	return n, err
+}
+
+// KV is a key/value pair.
+type KV struct {
+	// Key is the key.
+	Key []byte
+	// Value is the value.
+	Value []byte
+}
+
+// sliceIterator is a SortedIterator over a slice of pairs.
+type sliceIterator struct {
+	kvs []KV
+	idx int
+}
+
+// NewSliceIterator builds a SortedIterator over a slice of pairs sorted by key.
+func NewSliceIterator(kvs []KV) SortedIterator {
+	return &sliceIterator{kvs: kvs, idx: -1}
+}
+
+// Next advances to the next pair.
+func (s *sliceIterator) Next() bool {
+	if s.idx+1 >= len(s.kvs) {
+		s.idx = len(s.kvs)
+		return false
+	}
+	s.idx++
+	return true
+}
+
+// Key returns the key of the current pair.
+func (s *sliceIterator) Key() []byte {
+	return s.kvs[s.idx].Key
+}
+
+// Value returns a reader for the value of the current pair.
+func (s *sliceIterator) Value() (io.Reader, int64, error) {
+	value := s.kvs[s.idx].Value
+	return bytes.NewReader(value), int64(len(value)), nil
+}
+
+// Err returns nil.
+func (s *sliceIterator) Err() error {
+	return nil
+}
+
+// seqIterator is a SortedIterator over an iter.Seq2.
+type seqIterator struct {
+	next       func() ([]byte, []byte, bool)
+	key, value []byte
+}
+
+// NewSeqIterator builds a SortedIterator over a sequence of pairs sorted by key.
+//
+// The returned function releases the sequence and must be called when done.
+func NewSeqIterator(seq iter.Seq2[[]byte, []byte]) (SortedIterator, func()) {
+	next, stop := iter.Pull2(seq)
+	return &seqIterator{next: next}, stop
+}
+
+// Next advances to the next pair.
+func (s *seqIterator) Next() bool {
+	var ok bool
+	s.key, s.value, ok = s.next()
+	return ok
+}
+
+// Key returns the key of the current pair.
+func (s *seqIterator) Key() []byte {
+	return s.key
+}
+
+// Value returns a reader for the value of the current pair.
+func (s *seqIterator) Value() (io.Reader, int64, error) {
+	return bytes.NewReader(s.value), int64(len(s.value)), nil
+}
+
+// Err returns nil.
+func (s *seqIterator) Err() error {
+	return nil
+}
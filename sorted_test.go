@@ -0,0 +1,139 @@
+package kvfile
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// testSortedIterator is a SortedIterator returning readers from a function.
+type testSortedIterator struct {
+	keys  []string
+	value func(key string) (io.Reader, int64, error)
+	idx   int
+	err   error
+}
+
+func (s *testSortedIterator) Next() bool {
+	if s.idx >= len(s.keys) {
+		return false
+	}
+	s.idx++
+	return true
+}
+
+func (s *testSortedIterator) Key() []byte {
+	return []byte(s.keys[s.idx-1])
+}
+
+func (s *testSortedIterator) Value() (io.Reader, int64, error) {
+	return s.value(s.keys[s.idx-1])
+}
+
+func (s *testSortedIterator) Err() error {
+	return s.err
+}
+
+// errAfterReader returns err after reading the data.
+type errAfterReader struct {
+	data []byte
+	err  error
+}
+
+func (r *errAfterReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestWriteFromSortedIterator(t *testing.T) {
+	kvs := []KV{
+		{Key: []byte("a"), Value: []byte("val-a")},
+		{Key: []byte("b"), Value: nil},
+		{Key: []byte("c"), Value: []byte("val-c")},
+	}
+	var buf bytes.Buffer
+	if err := WriteFromSortedIterator(&buf, NewSliceIterator(kvs)); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	seq := func(yield func([]byte, []byte) bool) {
+		for _, kv := range kvs {
+			if !yield(kv.Key, kv.Value) {
+				return
+			}
+		}
+	}
+	seqIt, stop := NewSeqIterator(seq)
+	defer stop()
+	var seqBuf bytes.Buffer
+	if err := WriteFromSortedIterator(&seqBuf, seqIt); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(buf.Bytes(), seqBuf.Bytes()) {
+		t.Fatal("expected the slice and seq iterators to write the same file")
+	}
+
+	rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if rdr.Size() != uint64(len(kvs)) {
+		t.Fatalf("expected %d entries: %d", len(kvs), rdr.Size())
+	}
+	for _, kv := range kvs {
+		val, found, err := rdr.Get(kv.Key)
+		if err != nil || !found || !bytes.Equal(val, kv.Value) {
+			t.Fatalf("unexpected value for %s: %q %v %v", kv.Key, val, found, err)
+		}
+	}
+}
+
+func TestWriteFromSortedIteratorErrors(t *testing.T) {
+	valueFn := func(key string) (io.Reader, int64, error) {
+		return strings.NewReader("val-" + key), -1, nil
+	}
+
+	// out of order and duplicate keys
+	for _, keys := range [][]string{{"a", "c", "b"}, {"a", "b", "b"}} {
+		err := WriteFromSortedIterator(io.Discard, &testSortedIterator{keys: keys, value: valueFn})
+		var orderErr *KeyOrderError
+		if !errors.As(err, &orderErr) || string(orderErr.Prev) != keys[1] || string(orderErr.Key) != keys[2] {
+			t.Fatalf("expected key order error for %v: %v", keys, err)
+		}
+	}
+
+	// value reader fails mid-copy
+	errRead := errors.New("read failed")
+	it := &testSortedIterator{keys: []string{"a", "b"}, value: func(key string) (io.Reader, int64, error) {
+		if key == "b" {
+			return &errAfterReader{data: bytes.Repeat([]byte("x"), 64<<10), err: errRead}, -1, nil
+		}
+		return valueFn(key)
+	}}
+	if err := WriteFromSortedIterator(io.Discard, it); !errors.Is(err, errRead) || !strings.Contains(err.Error(), `"b"`) {
+		t.Fatalf("expected read error for b: %v", err)
+	}
+
+	// value size does not match the reader
+	for _, size := range []int64{4, 6} {
+		it := &testSortedIterator{keys: []string{"a"}, value: func(key string) (io.Reader, int64, error) {
+			return strings.NewReader("val-a"), size, nil
+		}}
+		if err := WriteFromSortedIterator(io.Discard, it); err == nil {
+			t.Fatalf("expected size mismatch error for size %d", size)
+		}
+	}
+
+	// iterator error
+	errIter := errors.New("iterator failed")
+	if err := WriteFromSortedIterator(io.Discard, &testSortedIterator{value: valueFn, err: errIter}); err != errIter {
+		t.Fatalf("expected iterator error: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package kvfile
+
+import (
+	protobuf_go_lite "github.com/aperturerobotics/protobuf-go-lite"
+	"github.com/pkg/errors"
+)
+
+// Stats contains statistics about the entries in a file.
+type Stats struct {
+	// EntryCount is the number of entries.
+	EntryCount uint64
+	// TotalValueSize is the sum of the value sizes in bytes.
+	TotalValueSize uint64
+	// MaxKeySize is the length of the longest key in bytes.
+	MaxKeySize uint64
+	// MaxValueSize is the size of the largest value in bytes.
+	MaxValueSize uint64
+	// FromFooter indicates the stats were read from the footer.
+	// If false, the stats were computed by scanning the index.
+	FromFooter bool
+}
+
+// Stats returns statistics about the entries in the file.
+//
+// If the file has the stats footer block (see WriterOptions.WriteStats)
+// returns the stats without reading the index. Otherwise scans the index,
+// which is O(n). Entries hidden by the reader options are not excluded.
+func (r *Reader) Stats() (*Stats, error) {
+	if block, found := r.findFooterBlock(FooterBlockStats); found {
+		data, err := r.readFooterBlockData(block)
+		if err != nil {
+			return nil, err
+		}
+		stats, err := parseStatsBlock(data)
+		if err != nil {
+			return nil, err
+		}
+		stats.EntryCount = r.Size()
+		return stats, nil
+	}
+
+	stats := &Stats{EntryCount: r.Size()}
+	for i := uint64(0); i < stats.EntryCount; i++ {
+		indexEntry, err := r.ReadIndexEntry(i)
+		if err != nil {
+			return nil, err
+		}
+		addEntryStats(stats, indexEntry)
+	}
+	return stats, nil
+}
+
+// addEntryStats adds the index entry to the stats.
+func addEntryStats(stats *Stats, indexEntry *IndexEntry) {
+	stats.TotalValueSize += indexEntry.GetSize()
+	stats.MaxKeySize = max(stats.MaxKeySize, uint64(len(indexEntry.GetKey())))
+	stats.MaxValueSize = max(stats.MaxValueSize, indexEntry.GetSize())
+}
+
+// buildStatsBlock builds the stats footer block for the index.
+//
+// The block contains varints: total value size, max key size, max value size.
+func buildStatsBlock(index []*IndexEntry) *footerBlockData {
+	var stats Stats
+	for _, indexEntry := range index {
+		addEntryStats(&stats, indexEntry)
+	}
+	data := protobuf_go_lite.AppendVarint(nil, stats.TotalValueSize)
+	data = protobuf_go_lite.AppendVarint(data, stats.MaxKeySize)
+	data = protobuf_go_lite.AppendVarint(data, stats.MaxValueSize)
+	return &footerBlockData{typ: FooterBlockStats, data: data}
+}
+
+// parseStatsBlock parses the stats footer block.
+func parseStatsBlock(data []byte) (*Stats, error) {
+	var fields [3]uint64
+	for i := range fields {
+		field, n := protobuf_go_lite.ConsumeVarint(data)
+		if n < 0 {
+			return nil, errors.New("invalid stats footer block")
+		}
+		fields[i] = field
+		data = data[n:]
+	}
+	return &Stats{
+		TotalValueSize: fields[0],
+		MaxKeySize:     fields[1],
+		MaxValueSize:   fields[2],
+		FromFooter:     true,
+	}, nil
+}
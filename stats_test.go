@@ -0,0 +1,50 @@
+package kvfile
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	keys := []string{"test-2", "test-long-key", "test-1", "test-empty"}
+	vals := []string{"val-2", strings.Repeat("v", 1000), "val-1", ""}
+
+	stats := make([]*Stats, 2)
+	for i, writeStats := range []bool{true, false} {
+		var buf bytes.Buffer
+		wr, err := NewWriterWithOptions(&buf, WriterOptions{WriteStats: writeStats})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		for j, key := range keys {
+			if err := wr.WriteValue([]byte(key), strings.NewReader(vals[j])); err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+		if err := wr.Close(); err != nil {
+			t.Fatal(err.Error())
+		}
+		rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		stats[i], err = rdr.Stats()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if stats[i].FromFooter != writeStats {
+			t.Fatalf("expected from footer = %v", writeStats)
+		}
+	}
+
+	footerStats, scanStats := *stats[0], *stats[1]
+	footerStats.FromFooter = false
+	if footerStats != scanStats {
+		t.Fatalf("footer stats do not match scan stats: %v != %v", footerStats, scanStats)
+	}
+	expected := Stats{EntryCount: 4, TotalValueSize: 1010, MaxKeySize: 13, MaxValueSize: 1000}
+	if scanStats != expected {
+		t.Fatalf("unexpected stats: %v", scanStats)
+	}
+}
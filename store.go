@@ -0,0 +1,73 @@
+package kvfile
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+)
+
+// Store is a read-only key/value store.
+//
+// Implemented by Reader, LayeredReader, OverlayStore, and MapStore.
+type Store interface {
+	// Get looks up the value for the given key.
+	// Returns nil, false, nil if not found.
+	Get(key []byte) ([]byte, bool, error)
+	// Exists checks if the given key exists in the store.
+	Exists(key []byte) (bool, error)
+	// ScanPrefix iterates over key/value pairs with a prefix in key order.
+	// Returns the error returned by the callback, if any.
+	ScanPrefix(prefix []byte, cb func(key, value []byte) error) error
+	// Size returns the number of entries in the store.
+	// May include entries hidden from Get and ScanPrefix.
+	Size() uint64
+}
+
+// MapStore is a Store backed by a map.
+//
+// The keys are scanned in sorted order. Useful for tests.
+type MapStore map[string][]byte
+
+// Get looks up the value for the given key.
+func (m MapStore) Get(key []byte) ([]byte, bool, error) {
+	value, found := m[string(key)]
+	if !found {
+		return nil, false, nil
+	}
+	return bytes.Clone(value), true, nil
+}
+
+// Exists checks if the given key exists in the store.
+func (m MapStore) Exists(key []byte) (bool, error) {
+	_, found := m[string(key)]
+	return found, nil
+}
+
+// ScanPrefix iterates over key/value pairs with a prefix in key order.
+func (m MapStore) ScanPrefix(prefix []byte, cb func(key, value []byte) error) error {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		if strings.HasPrefix(key, string(prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if err := cb([]byte(key), bytes.Clone(m[key])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Size returns the number of entries in the store.
+func (m MapStore) Size() uint64 {
+	return uint64(len(m))
+}
+
+// _ is a type assertion
+var (
+	_ Store = ((*Reader)(nil))
+	_ Store = ((*LayeredReader)(nil))
+	_ Store = MapStore(nil)
+)
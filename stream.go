@@ -0,0 +1,231 @@
+package kvfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	protobuf_go_lite "github.com/aperturerobotics/protobuf-go-lite"
+	"github.com/pkg/errors"
+)
+
+// Files written with WriterOptions.ValueHeaders start with valueHeadersMagic
+// followed by one record per value:
+//
+//	[key length + 1 (varint)][key][value length (varint)][flags (varint)]
+//	[expiry (varint), if flagged][meta length (varint)][meta, if flagged]
+//	[value]
+//
+// The flags mark tombstones and which of the optional entry attributes follow
+// so the index entries can be rebuilt from the headers (see RecoverIndex).
+// The records are terminated with a zero byte (key length + 1 = 0), followed
+// by the index as usual. The index entry offsets point to the value bytes, so
+// random access is unaffected by the headers.
+
+// valueHeadersMagic is written at the start of files with value headers.
+var valueHeadersMagic = []byte{'K', 'V', 'F', 'S', 'T', 'R', 'M', 0x81}
+
+const (
+	// valueHeaderFlagTombstone marks the record as a tombstone.
+	valueHeaderFlagTombstone uint64 = 1 << 0
+	// valueHeaderFlagExpires indicates the expiry follows the flags.
+	valueHeaderFlagExpires uint64 = 1 << 1
+	// valueHeaderFlagMeta indicates the metadata follows the flags.
+	valueHeaderFlagMeta uint64 = 1 << 2
+)
+
+// ErrNoValueHeaders is returned if the stream was not written with value headers.
+var ErrNoValueHeaders = errors.New("kvfile was not written with value headers")
+
+// appendValueHeader appends the header for the entry value to the buffer.
+func appendValueHeader(buf []byte, entry *IndexEntry, valueSize uint64) []byte {
+	key := entry.GetKey()
+	buf = protobuf_go_lite.AppendVarint(buf, uint64(len(key))+1)
+	buf = append(buf, key...)
+	buf = protobuf_go_lite.AppendVarint(buf, valueSize)
+
+	var flags uint64
+	if entry.GetTombstone() {
+		flags |= valueHeaderFlagTombstone
+	}
+	if entry.GetExpiresUnixMs() != 0 {
+		flags |= valueHeaderFlagExpires
+	}
+	if len(entry.GetMeta()) != 0 {
+		flags |= valueHeaderFlagMeta
+	}
+	buf = protobuf_go_lite.AppendVarint(buf, flags)
+	if flags&valueHeaderFlagExpires != 0 {
+		buf = protobuf_go_lite.AppendVarint(buf, entry.GetExpiresUnixMs())
+	}
+	if flags&valueHeaderFlagMeta != 0 {
+		buf = protobuf_go_lite.AppendVarint(buf, uint64(len(entry.GetMeta())))
+		buf = append(buf, entry.GetMeta()...)
+	}
+	return buf
+}
+
+// checkValueHeadersMagic checks the magic at the start of a file with value
+// headers.
+func checkValueHeadersMagic(magic []byte) error {
+	if !bytes.Equal(magic, valueHeadersMagic) {
+		return ErrNoValueHeaders
+	}
+	return nil
+}
+
+// sizeValueReader determines the size of the value in the reader.
+//
+// If the size cannot be determined reads the value into memory.
+func sizeValueReader(valueRdr io.Reader) (io.Reader, int64, error) {
+	switch rdr := valueRdr.(type) {
+	case interface{ Len() int }:
+		return valueRdr, int64(rdr.Len()), nil
+	case io.Seeker:
+		cur, err := rdr.Seek(0, io.SeekCurrent)
+		if err == nil {
+			var end int64
+			end, err = rdr.Seek(0, io.SeekEnd)
+			if err == nil {
+				_, err = rdr.Seek(cur, io.SeekStart)
+				if err != nil {
+					return nil, 0, err
+				}
+				return valueRdr, end - cur, nil
+			}
+		}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(valueRdr, int64(maxValueSize)+1))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(data) > int(maxValueSize) {
+		return nil, 0, errors.Errorf("value size > max size %v", maxValueSize)
+	}
+	return bytes.NewReader(data), int64(len(data)), nil
+}
+
+// writeStreamHeaderLocked writes the value headers magic if not yet written.
+func (w *Writer) writeStreamHeaderLocked() error {
+	if w.hdr {
+		return nil
+	}
+	w.hdr = true
+	nw, err := writeFull(w.vout, valueHeadersMagic)
+	w.pos += uint64(nw)
+	if err != nil {
+		w.fin = true
+	}
+	return err
+}
+
+// writeStreamEndLocked writes the end of values marker.
+func (w *Writer) writeStreamEndLocked() error {
+	if err := w.writeStreamHeaderLocked(); err != nil {
+		return err
+	}
+	nw, err := writeFull(w.vout, []byte{0})
+	w.pos += uint64(nw)
+	return err
+}
+
+// DecodeStream decodes the key/value pairs in a file written with value
+// headers (see WriterOptions.ValueHeaders) front-to-back without seeking.
+//
+// The pairs are passed to the callback in the order they were written. The
+// buffers are not reused and can be retained by the callback. Stops reading
+// after the last value without reading the index. Tombstones are passed as
+// empty values.
+//
+// Returns ErrNoValueHeaders if the file was not written with value headers.
+func DecodeStream(rd io.Reader, cb func(key, value []byte) error) error {
+	br, ok := rd.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(rd)
+	}
+
+	magic := make([]byte, len(valueHeadersMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrNoValueHeaders
+		}
+		return err
+	}
+	if err := checkValueHeadersMagic(magic); err != nil {
+		return err
+	}
+
+	for {
+		keyLen, err := binary.ReadUvarint(br)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if keyLen == 0 {
+			// end of values
+			return nil
+		}
+		keyLen--
+		if keyLen > uint64(maxIndexEntrySize) {
+			return errors.Errorf("invalid key length in value header: %v", keyLen)
+		}
+		key := make([]byte, keyLen)
+		if _, err := io.ReadFull(br, key); err != nil {
+			return unexpectedEOF(err)
+		}
+
+		valueLen, err := binary.ReadUvarint(br)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if valueLen > uint64(maxValueSize) {
+			return errors.Errorf("value size %v > max size %v", valueLen, maxValueSize)
+		}
+		if err := skipValueHeaderAttrs(br); err != nil {
+			return err
+		}
+		value := make([]byte, valueLen)
+		if _, err := io.ReadFull(br, value); err != nil {
+			return unexpectedEOF(err)
+		}
+
+		if err := cb(key, value); err != nil {
+			return err
+		}
+	}
+}
+
+// skipValueHeaderAttrs skips the flags and entry attributes of a record.
+func skipValueHeaderAttrs(br *bufio.Reader) error {
+	flags, err := binary.ReadUvarint(br)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	if flags&valueHeaderFlagExpires != 0 {
+		if _, err := binary.ReadUvarint(br); err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+	if flags&valueHeaderFlagMeta != 0 {
+		metaLen, err := binary.ReadUvarint(br)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if metaLen > uint64(maxMetaSize) {
+			return errors.Errorf("invalid meta length in value header: %v", metaLen)
+		}
+		if _, err := br.Discard(int(metaLen)); err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+	return nil
+}
+
+// unexpectedEOF converts io.EOF to io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package kvfile
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecodeStream(t *testing.T) {
+	keys := []string{"test-2", "test-3", "test-1", "test-empty"}
+	vals := []string{"val-2", strings.Repeat("val-3", 10000), "val-1", ""}
+
+	var buf bytes.Buffer
+	wr, err := NewWriterWithOptions(&buf, WriterOptions{ValueHeaders: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for i, key := range keys {
+		var valueRdr io.Reader = strings.NewReader(vals[i])
+		if i == 1 {
+			// hide the Len method to force buffering
+			valueRdr = iotest.HalfReader(valueRdr)
+		}
+		if err := wr.WriteValue([]byte(key), valueRdr); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// sequential access over a reader without ReaderAt
+	var i int
+	err = DecodeStream(iotest.OneByteReader(bytes.NewReader(buf.Bytes())), func(key, value []byte) error {
+		if string(key) != keys[i] || string(value) != vals[i] {
+			t.Fatalf("unexpected pair %d: %s %d", i, string(key), len(value))
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if i != len(keys) {
+		t.Fatalf("expected %d pairs: %d", len(keys), i)
+	}
+
+	// random access to the same file
+	rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for i, key := range keys {
+		data, found, err := rdr.Get([]byte(key))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !found || string(data) != vals[i] {
+			t.Fatalf("unexpected value for %s: %v %d", key, found, len(data))
+		}
+	}
+}
+
+func TestDecodeStreamEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	wr, err := NewWriterWithOptions(&buf, WriterOptions{ValueHeaders: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	err = DecodeStream(bytes.NewReader(buf.Bytes()), func(key, value []byte) error {
+		t.Fatal("expected no pairs")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if rdr.Size() != 0 {
+		t.Fatalf("unexpected size: %v", rdr.Size())
+	}
+}
+
+func TestDecodeStreamNoHeaders(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	if err := wr.WriteValue([]byte("test-1"), strings.NewReader("val-1")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	err := DecodeStream(bytes.NewReader(buf.Bytes()), func(key, value []byte) error {
+		return nil
+	})
+	if err != ErrNoValueHeaders {
+		t.Fatalf("expected ErrNoValueHeaders: %v", err)
+	}
+}
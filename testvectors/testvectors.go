@@ -0,0 +1,439 @@
+package kvfile_testvectors
+
+//go:generate go run ../cmd/kvfiletestvectors -o testdata
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	kvfile_compress "github.com/aperturerobotics/go-kvfile/compress"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// ManifestVersion is the version of the manifest format.
+const ManifestVersion = 1
+
+// ManifestName is the file name of the manifest written by WriteDir.
+const ManifestName = "manifest.json"
+
+// maxIndexEntrySize is the default ReaderOptions.MaxIndexEntrySize.
+const maxIndexEntrySize = 2048
+
+// Entry is an entry in a test vector.
+type Entry struct {
+	// Key is the key.
+	Key []byte
+	// Value is the value.
+	Value []byte
+	// Meta is the entry metadata.
+	Meta []byte
+	// Expires is the expiry time, zero if the entry does not expire.
+	Expires time.Time
+	// Tombstone indicates the entry is a tombstone.
+	Tombstone bool
+}
+
+// Vector is a generated test vector.
+type Vector struct {
+	// Name is the name of the vector, used as the file name.
+	Name string
+	// Description describes what the vector covers.
+	Description string
+	// Compressed indicates the file is a seekable zstd compressed kvfile.
+	Compressed bool
+	// Entries are the entries in key order.
+	Entries []Entry
+	// Data is the file contents.
+	Data []byte
+}
+
+// FileName returns the file name of the vector.
+func (v *Vector) FileName() string {
+	if v.Compressed {
+		return v.Name + ".kvf.zst"
+	}
+	return v.Name + ".kvf"
+}
+
+// Manifest describes the test vectors and the expected contents.
+type Manifest struct {
+	// Version is the manifest format version.
+	Version int `json:"version"`
+	// Vectors describes each test vector.
+	Vectors []*ManifestVector `json:"vectors"`
+}
+
+// ManifestVector describes a test vector file.
+type ManifestVector struct {
+	// Name is the name of the vector.
+	Name string `json:"name"`
+	// File is the file name relative to the manifest.
+	File string `json:"file"`
+	// Description describes what the vector covers.
+	Description string `json:"description"`
+	// Compressed indicates the file is a seekable zstd compressed kvfile.
+	Compressed bool `json:"compressed"`
+	// Size is the size of the file in bytes.
+	Size int `json:"size"`
+	// SHA256 is the hex SHA-256 of the file.
+	SHA256 string `json:"sha256"`
+	// ContentDigest is the hex kvfile.ContentDigest with SHA-256.
+	ContentDigest string `json:"content_digest"`
+	// Entries are the expected entries in key order.
+	Entries []*ManifestEntry `json:"entries"`
+}
+
+// ManifestEntry describes an entry in a test vector.
+type ManifestEntry struct {
+	// Key is the hex key.
+	Key string `json:"key"`
+	// Offset is the offset of the value in the index entry.
+	//
+	// For compressed files the offset is in the decompressed file.
+	Offset uint64 `json:"offset"`
+	// ValueSize is the size of the value in bytes.
+	ValueSize uint64 `json:"value_size"`
+	// ValueSHA256 is the hex SHA-256 of the value.
+	ValueSHA256 string `json:"value_sha256"`
+	// Meta is the hex entry metadata if any.
+	Meta string `json:"meta,omitempty"`
+	// ExpiresUnixMs is the expiry time in unix milliseconds if any.
+	ExpiresUnixMs uint64 `json:"expires_unix_ms,omitempty"`
+	// Tombstone indicates the entry is a tombstone.
+	Tombstone bool `json:"tombstone,omitempty"`
+}
+
+// vectorSpec describes how to generate a vector.
+type vectorSpec struct {
+	name        string
+	description string
+	entries     []Entry
+	opts        kvfile.WriterOptions
+	// reverse writes the entries in reverse key order
+	reverse bool
+	// compress writes a compressed copy with the frame size if non-zero
+	compress int
+}
+
+// Generate generates the test vectors.
+//
+// The output is deterministic: generating again returns identical files.
+func Generate() ([]*Vector, error) {
+	var vectors []*Vector
+	for _, spec := range vectorSpecs() {
+		data, err := writeVector(spec)
+		if err != nil {
+			return nil, errors.Wrap(err, spec.name)
+		}
+		vec := &Vector{Name: spec.name, Description: spec.description, Entries: spec.entries, Data: data}
+		if spec.compress != 0 {
+			vec.Compressed = true
+			vec.Data, err = compressVector(data, spec.compress)
+			if err != nil {
+				return nil, errors.Wrap(err, spec.name)
+			}
+		}
+		vectors = append(vectors, vec)
+	}
+	return vectors, nil
+}
+
+// vectorSpecs returns the specs of the vectors.
+func vectorSpecs() []vectorSpec {
+	single := []Entry{{Key: []byte("key"), Value: []byte("value")}}
+	many := manyEntries(200)
+	return []vectorSpec{{
+		name:        "empty",
+		description: "A file with no entries.",
+	}, {
+		name:        "single",
+		description: "A file with one entry.",
+		entries:     single,
+	}, {
+		name:        "empty-value",
+		description: "Zero-length values at the start, middle, and end of the values.",
+		entries: []Entry{
+			{Key: []byte("a"), Value: nil},
+			{Key: []byte("b"), Value: []byte("value-b")},
+			{Key: []byte("c"), Value: nil},
+			{Key: []byte("d"), Value: []byte("value-d")},
+			{Key: []byte("e"), Value: nil},
+		},
+	}, {
+		name:        "max-key",
+		description: "A key with the maximum length: the index entry is exactly 2048 bytes, the default maximum index entry size.",
+		entries:     []Entry{{Key: maxKey(), Value: []byte("value")}},
+	}, {
+		name:        "binary-keys",
+		description: "Binary keys including NUL and 0xff bytes and keys which are prefixes of other keys.",
+		entries: []Entry{
+			{Key: []byte{0x00}, Value: []byte("nul")},
+			{Key: []byte{0x00, 0x00}, Value: []byte("nul-nul")},
+			{Key: []byte("a"), Value: []byte("a")},
+			{Key: []byte("a\x00"), Value: []byte("a-nul")},
+			{Key: []byte("ab"), Value: []byte("ab")},
+			{Key: []byte{0xff}, Value: []byte("ff")},
+			{Key: []byte{0xff, 0xff, 0xff}, Value: []byte("ff-ff-ff")},
+		},
+	}, {
+		name:        "many-entries",
+		description: "200 entries: the entry count, sizes, and offsets use multi-byte varints.",
+		entries:     many,
+	}, {
+		name:        "boundary-offsets",
+		description: "Values at offsets and with sizes on varint boundaries: 127, 128, 16383, and 16384.",
+		entries:     boundaryEntries(),
+	}, {
+		name:        "reverse-order",
+		description: "Entries written in reverse key order: the values are in write order and the index is sorted.",
+		entries:     manyEntries(20),
+		reverse:     true,
+	}, {
+		name:        "metadata",
+		description: "Entries with metadata, expiry times, and tombstones.",
+		entries: []Entry{
+			{Key: []byte("expired"), Value: []byte("expired-value"), Expires: time.UnixMilli(1)},
+			{Key: []byte("expires"), Value: []byte("expires-value"), Expires: time.UnixMilli(4102444800000)},
+			{Key: []byte("meta"), Value: []byte("meta-value"), Meta: []byte("text/plain")},
+			{Key: []byte("plain"), Value: []byte("plain-value")},
+			{Key: []byte("tombstone"), Tombstone: true},
+		},
+	}, {
+		name:        "footer-blocks",
+		description: "The footer extension area with the bounds, stats, and hash index blocks.",
+		entries:     manyEntries(50),
+		opts:        kvfile.WriterOptions{WriteBounds: true, WriteStats: true, HashIndex: true},
+	}, {
+		name:        "value-headers",
+		description: "Values with stream headers: the file can be decoded front-to-back.",
+		entries:     manyEntries(10),
+		opts:        kvfile.WriterOptions{ValueHeaders: true},
+	}, {
+		name:        "fixed-key-width",
+		description: "The fixed-width key index layout with 8-byte keys.",
+		entries:     fixedWidthEntries(100),
+		opts:        kvfile.WriterOptions{FixedKeyWidth: 8},
+	}, {
+		name:        "single-compressed",
+		description: "The single vector as a seekable zstd compressed kvfile.",
+		entries:     single,
+		compress:    kvfile_compress.MinFrameSize,
+	}, {
+		name:        "many-entries-compressed",
+		description: "The many-entries vector as a seekable zstd compressed kvfile with 4KiB frames.",
+		entries:     many,
+		compress:    4096,
+	}}
+}
+
+// manyEntries returns count entries with values of varying sizes.
+func manyEntries(count int) []Entry {
+	entries := make([]Entry, count)
+	for i := range entries {
+		key := []byte("key-" + strconv.Itoa(100000+i))
+		entries[i] = Entry{Key: key, Value: bytes.Repeat([]byte{byte(i)}, i*37%251)}
+	}
+	return entries
+}
+
+// fixedWidthEntries returns count entries with 8-byte keys.
+func fixedWidthEntries(count int) []Entry {
+	entries := make([]Entry, count)
+	for i := range entries {
+		key := []byte("k" + strconv.Itoa(1000000+i))
+		entries[i] = Entry{Key: key, Value: []byte("value-" + strconv.Itoa(i))}
+	}
+	return entries
+}
+
+// boundaryEntries returns entries with values at varint boundary offsets.
+func boundaryEntries() []Entry {
+	sizes := []int{127, 1, 16383 - 128, 1, 128, 0, 16384, 5}
+	entries := make([]Entry, len(sizes))
+	for i, size := range sizes {
+		entries[i] = Entry{
+			Key:   []byte("value-" + strconv.Itoa(i)),
+			Value: bytes.Repeat([]byte{'a' + byte(i)}, size),
+		}
+	}
+	return entries
+}
+
+// maxKey returns the longest key for which the index entry of a 5 byte value
+// at offset 0 fits in the maximum index entry size.
+func maxKey() []byte {
+	key := bytes.Repeat([]byte{'k'}, maxIndexEntrySize)
+	for (&kvfile.IndexEntry{Key: key, Size: 5}).SizeVT() > maxIndexEntrySize {
+		key = key[:len(key)-1]
+	}
+	return key
+}
+
+// writeVector writes the entries of the spec to a kvfile.
+func writeVector(spec vectorSpec) ([]byte, error) {
+	var buf bytes.Buffer
+	wr, err := kvfile.NewWriterWithOptions(&buf, spec.opts)
+	if err != nil {
+		return nil, err
+	}
+	entries := spec.entries
+	if spec.reverse {
+		entries = slices.Clone(entries)
+		slices.Reverse(entries)
+	}
+	for _, entry := range entries {
+		switch {
+		case entry.Tombstone:
+			err = wr.WriteTombstone(entry.Key)
+		case len(entry.Meta) != 0:
+			err = wr.WriteValueBytesMeta(entry.Key, entry.Value, entry.Meta)
+		default:
+			err = wr.WriteValueWithExpiry(entry.Key, bytes.NewReader(entry.Value), entry.Expires)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := wr.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// compressVector writes a compressed copy of the kvfile.
+//
+// Compresses frames sequentially for deterministic output.
+func compressVector(data []byte, frameSize int) ([]byte, error) {
+	rd, err := kvfile.NewReaderFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = kvfile_compress.CompressReader(&buf, rd, &kvfile_compress.CompressOptions{
+		Level:          zstd.SpeedDefault,
+		EncoderOptions: []zstd.EOption{zstd.WithEncoderConcurrency(1)},
+		FrameSize:      frameSize,
+		Concurrency:    1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// BuildManifest builds the manifest for the vectors.
+//
+// Reads the expected entries from the files with the Reader.
+func BuildManifest(vectors []*Vector) (*Manifest, error) {
+	manifest := &Manifest{Version: ManifestVersion}
+	for _, vec := range vectors {
+		mv, err := buildManifestVector(vec)
+		if err != nil {
+			return nil, errors.Wrap(err, vec.Name)
+		}
+		manifest.Vectors = append(manifest.Vectors, mv)
+	}
+	return manifest, nil
+}
+
+// buildManifestVector builds the manifest entry for the vector.
+func buildManifestVector(vec *Vector) (*ManifestVector, error) {
+	rd, rel, err := OpenVector(vec.Data, vec.Compressed)
+	if err != nil {
+		return nil, err
+	}
+	defer rel()
+	digest, err := kvfile.ContentDigest(rd, sha256.New)
+	if err != nil {
+		return nil, err
+	}
+	fileSum := sha256.Sum256(vec.Data)
+	mv := &ManifestVector{
+		Name:          vec.Name,
+		File:          vec.FileName(),
+		Description:   vec.Description,
+		Compressed:    vec.Compressed,
+		Size:          len(vec.Data),
+		SHA256:        hex.EncodeToString(fileSum[:]),
+		ContentDigest: hex.EncodeToString(digest),
+		Entries:       []*ManifestEntry{},
+	}
+	for i := uint64(0); i < rd.Size(); i++ {
+		indexEntry, err := rd.ReadIndexEntry(i)
+		if err != nil {
+			return nil, err
+		}
+		valueSum := sha256.New()
+		if _, err := rd.ReadToWithEntry(indexEntry, int(i), valueSum); err != nil {
+			return nil, err
+		}
+		mv.Entries = append(mv.Entries, &ManifestEntry{
+			Key:           hex.EncodeToString(indexEntry.GetKey()),
+			Offset:        indexEntry.GetOffset(),
+			ValueSize:     indexEntry.GetSize(),
+			ValueSHA256:   hex.EncodeToString(valueSum.Sum(nil)),
+			Meta:          hex.EncodeToString(indexEntry.GetMeta()),
+			ExpiresUnixMs: indexEntry.GetExpiresUnixMs(),
+			Tombstone:     indexEntry.GetTombstone(),
+		})
+	}
+	return mv, nil
+}
+
+// OpenVector opens the vector file contents with a Reader.
+//
+// The returned function releases the reader.
+func OpenVector(data []byte, compressed bool) (*kvfile.Reader, func(), error) {
+	if compressed {
+		return kvfile_compress.BuildCompressReaderFromBytes(data)
+	}
+	rd, err := kvfile.NewReaderFromBytes(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rd, func() {}, nil
+}
+
+// MarshalManifest encodes the manifest as indented JSON.
+func MarshalManifest(manifest *Manifest) ([]byte, error) {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// WriteDir generates the vectors and writes the files and the manifest to the
+// directory, creating it if needed.
+func WriteDir(dir string) error {
+	vectors, err := Generate()
+	if err != nil {
+		return err
+	}
+	manifest, err := BuildManifest(vectors)
+	if err != nil {
+		return err
+	}
+	manifestData, err := MarshalManifest(manifest)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, vec := range vectors {
+		if err := os.WriteFile(filepath.Join(dir, vec.FileName()), vec.Data, 0o644); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(filepath.Join(dir, ManifestName), manifestData, 0o644)
+}
@@ -0,0 +1,168 @@
+package kvfile_testvectors
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+)
+
+// TestGenerateDeterministic checks the generated vectors match testdata.
+func TestGenerateDeterministic(t *testing.T) {
+	vectors, err := Generate()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	again, err := Generate()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for i, vec := range vectors {
+		if !bytes.Equal(vec.Data, again[i].Data) {
+			t.Fatalf("%s: generated different output", vec.Name)
+		}
+		data, err := os.ReadFile(filepath.Join("testdata", vec.FileName()))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !bytes.Equal(vec.Data, data) {
+			t.Fatalf("%s: does not match testdata: run go generate", vec.Name)
+		}
+	}
+
+	manifest, err := BuildManifest(vectors)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	manifestData, err := MarshalManifest(manifest)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	data, err := os.ReadFile(filepath.Join("testdata", ManifestName))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(manifestData, data) {
+		t.Fatal("manifest does not match testdata: run go generate")
+	}
+}
+
+// TestVectors validates the vectors in testdata against the manifest.
+func TestVectors(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", ManifestName))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err.Error())
+	}
+	if manifest.Version != ManifestVersion || len(manifest.Vectors) == 0 {
+		t.Fatalf("unexpected manifest: version %v with %v vectors", manifest.Version, len(manifest.Vectors))
+	}
+
+	vectors, err := Generate()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for i, mv := range manifest.Vectors {
+		t.Run(mv.Name, func(t *testing.T) {
+			fileData, err := os.ReadFile(filepath.Join("testdata", mv.File))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			fileSum := sha256.Sum256(fileData)
+			if len(fileData) != mv.Size || hex.EncodeToString(fileSum[:]) != mv.SHA256 {
+				t.Fatal("file size or hash does not match the manifest")
+			}
+			validateVector(t, mv, vectors[i], fileData)
+		})
+	}
+}
+
+// validateVector checks the file against the manifest and the entries written
+// by the generator.
+func validateVector(t *testing.T, mv *ManifestVector, vec *Vector, fileData []byte) {
+	rd, rel, err := OpenVector(fileData, mv.Compressed)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rel()
+
+	expected := slices.Clone(vec.Entries)
+	slices.SortFunc(expected, func(a, b Entry) int {
+		return bytes.Compare(a.Key, b.Key)
+	})
+	if rd.Size() != uint64(len(expected)) || len(mv.Entries) != len(expected) {
+		t.Fatalf("expected %v entries: reader has %v and manifest has %v", len(expected), rd.Size(), len(mv.Entries))
+	}
+	for i, entry := range expected {
+		me := mv.Entries[i]
+		indexEntry, err := rd.ReadIndexEntry(uint64(i))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !bytes.Equal(indexEntry.GetKey(), entry.Key) || hex.EncodeToString(entry.Key) != me.Key {
+			t.Fatalf("entry %v: unexpected key %q", i, indexEntry.GetKey())
+		}
+		if indexEntry.GetOffset() != me.Offset || indexEntry.GetSize() != me.ValueSize {
+			t.Fatalf("entry %v: offset or size does not match the manifest", i)
+		}
+		if !bytes.Equal(indexEntry.GetMeta(), entry.Meta) || hex.EncodeToString(entry.Meta) != me.Meta {
+			t.Fatalf("entry %v: unexpected meta", i)
+		}
+		if indexEntry.GetTombstone() != entry.Tombstone || me.Tombstone != entry.Tombstone {
+			t.Fatalf("entry %v: unexpected tombstone", i)
+		}
+		if expires := uint64(entry.Expires.UnixMilli()); !entry.Expires.IsZero() && (indexEntry.GetExpiresUnixMs() != expires || me.ExpiresUnixMs != expires) {
+			t.Fatalf("entry %v: unexpected expiry", i)
+		}
+
+		value, err := rd.GetWithEntry(indexEntry, i)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		valueSum := sha256.Sum256(entry.Value)
+		if !bytes.Equal(value, entry.Value) || uint64(len(value)) != me.ValueSize || hex.EncodeToString(valueSum[:]) != me.ValueSHA256 {
+			t.Fatalf("entry %v: unexpected value", i)
+		}
+		if !entry.Tombstone {
+			got, found, err := rd.Get(entry.Key)
+			if err != nil || !found || !bytes.Equal(got, entry.Value) {
+				t.Fatalf("entry %v: lookup failed: %v", i, err)
+			}
+		}
+	}
+
+	digest, err := kvfile.ContentDigest(rd, sha256.New)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if hex.EncodeToString(digest) != mv.ContentDigest {
+		t.Fatal("content digest does not match the manifest")
+	}
+}
+
+// TestMaxKey checks the max-key vector index entry has the maximum size.
+func TestMaxKey(t *testing.T) {
+	key := maxKey()
+	if size := (&kvfile.IndexEntry{Key: key, Size: 5}).SizeVT(); size != maxIndexEntrySize {
+		t.Fatalf("expected index entry size %v but got %v", maxIndexEntrySize, size)
+	}
+	// one more byte is rejected
+	wr := kvfile.NewWriter(io.Discard)
+	err := wr.WriteValue(append(key, 'k'), bytes.NewReader([]byte("value")))
+	if err == nil {
+		err = wr.Close()
+	}
+	if err == nil {
+		t.Fatal("expected an error writing an oversized index entry")
+	}
+}
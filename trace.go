@@ -0,0 +1,60 @@
+package kvfile
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// Trace operations passed to TraceFunc.
+const (
+	// TraceOpIndex is a read of the index or footer.
+	TraceOpIndex = "index"
+	// TraceOpValue is a read of a value.
+	TraceOpValue = "value"
+)
+
+// TraceFunc is called after each read from the underlying ReaderAt.
+//
+// op is TraceOpIndex or TraceOpValue, off and length are the requested range,
+// and err is the error returned by the read. Must be safe for concurrent use.
+type TraceFunc func(op string, off int64, length int, dur time.Duration, err error)
+
+// traceReaderAt calls the trace func after each read.
+type traceReaderAt struct {
+	rd    io.ReaderAt
+	op    string
+	trace TraceFunc
+}
+
+// ReadAt reads from the underlying ReaderAt.
+func (t *traceReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	start := time.Now()
+	n, err := t.rd.ReadAt(p, off)
+	t.trace(t.op, off, len(p), time.Since(start), err)
+	return n, err
+}
+
+// NewSlogTraceFunc returns a TraceFunc logging reads at debug level.
+//
+// The file name is attached to each record.
+func NewSlogTraceFunc(logger *slog.Logger, file string) TraceFunc {
+	ctx := context.Background()
+	return func(op string, off int64, length int, dur time.Duration, err error) {
+		if !logger.Enabled(ctx, slog.LevelDebug) {
+			return
+		}
+		attrs := []slog.Attr{
+			slog.String("file", file),
+			slog.String("op", op),
+			slog.Int64("off", off),
+			slog.Int("len", length),
+			slog.Duration("dur", dur),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("err", err.Error()))
+		}
+		logger.LogAttrs(ctx, slog.LevelDebug, "kvfile read", attrs...)
+	}
+}
@@ -0,0 +1,95 @@
+package kvfile
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// traceRecord is a recorded call to a TraceFunc.
+type traceRecord struct {
+	op     string
+	off    int64
+	length int
+}
+
+func TestTrace(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	for _, key := range []string{"a", "b", "c"} {
+		if err := wr.WriteValue([]byte(key), bytes.NewReader([]byte("val-"+key))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var mtx sync.Mutex
+	var records []traceRecord
+	trace := func(op string, off int64, length int, dur time.Duration, err error) {
+		if err != nil {
+			t.Errorf("unexpected read error: %v", err)
+		}
+		mtx.Lock()
+		records = append(records, traceRecord{op: op, off: off, length: length})
+		mtx.Unlock()
+	}
+	rdr, err := BuildReaderWithOptions(bytes.NewReader(buf.Bytes()), uint64(buf.Len()), ReaderOptions{Trace: trace})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(records) == 0 {
+		t.Fatal("expected the footer reads to be traced")
+	}
+	for _, rec := range records {
+		if rec.op != TraceOpIndex {
+			t.Fatalf("expected only index reads when opening: %v", records)
+		}
+	}
+
+	valueIdx, valueLen, _, _, err := rdr.GetValuePosition([]byte("b"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	records = nil
+	if val, found, err := rdr.Get([]byte("b")); err != nil || !found || string(val) != "val-b" {
+		t.Fatalf("unexpected value: %q %v %v", val, found, err)
+	}
+
+	// the index lookup is followed by a single value read
+	if len(records) < 2 {
+		t.Fatalf("expected index and value reads: %v", records)
+	}
+	for _, rec := range records[:len(records)-1] {
+		if rec.op != TraceOpIndex || rec.length == 0 {
+			t.Fatalf("expected index reads before the value: %v", records)
+		}
+	}
+	if last := records[len(records)-1]; last != (traceRecord{op: TraceOpValue, off: valueIdx, length: int(valueLen)}) {
+		t.Fatalf("unexpected value read: %v", last)
+	}
+}
+
+func TestSlogTraceFunc(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	trace := NewSlogTraceFunc(logger, "test.kvf")
+	trace(TraceOpValue, 12, 34, time.Millisecond, nil)
+	line := out.String()
+	for _, attr := range []string{"level=DEBUG", "file=test.kvf", "op=value", "off=12", "len=34", "dur=1ms"} {
+		if !strings.Contains(line, attr) {
+			t.Fatalf("expected %s in log: %s", attr, line)
+		}
+	}
+
+	out.Reset()
+	infoLogger := slog.New(slog.NewTextHandler(&out, nil))
+	NewSlogTraceFunc(infoLogger, "test.kvf")(TraceOpIndex, 0, 8, time.Millisecond, nil)
+	if out.Len() != 0 {
+		t.Fatalf("expected no debug output at info level: %s", out.String())
+	}
+}
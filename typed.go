@@ -0,0 +1,166 @@
+package kvfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	protobuf_go_lite "github.com/aperturerobotics/protobuf-go-lite"
+)
+
+// Codec encodes and decodes values of type T.
+type Codec[T any] interface {
+	// Marshal encodes the value.
+	Marshal(v T) ([]byte, error)
+	// Unmarshal decodes the value.
+	Unmarshal(data []byte) (T, error)
+}
+
+// DecodeError is returned by TypedReader if a value cannot be decoded.
+type DecodeError struct {
+	// Key is the key of the value.
+	Key []byte
+	// Err is the error returned by the codec.
+	Err error
+}
+
+// Error returns the error string.
+func (e *DecodeError) Error() string {
+	return "decode value for " + strconv.Quote(string(e.Key)) + ": " + e.Err.Error()
+}
+
+// Unwrap returns the error returned by the codec.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// TypedReader reads values of type T from a Reader with a Codec.
+type TypedReader[T any] struct {
+	r     *Reader
+	codec Codec[T]
+}
+
+// NewTypedReader constructs a new TypedReader.
+func NewTypedReader[T any](r *Reader, codec Codec[T]) *TypedReader[T] {
+	return &TypedReader[T]{r: r, codec: codec}
+}
+
+// GetReader returns the underlying Reader.
+func (t *TypedReader[T]) GetReader() *Reader {
+	return t.r
+}
+
+// Get looks up and decodes the value for the given key.
+//
+// Returns a *DecodeError if the value cannot be decoded.
+func (t *TypedReader[T]) Get(key []byte) (T, bool, error) {
+	var empty T
+	data, found, err := t.r.Get(key)
+	if err != nil || !found {
+		return empty, found, err
+	}
+	v, err := t.decode(key, data)
+	if err != nil {
+		return empty, true, err
+	}
+	return v, true, nil
+}
+
+// ScanPrefix iterates over decoded key/value pairs with a prefix.
+//
+// Returns a *DecodeError if a value cannot be decoded.
+func (t *TypedReader[T]) ScanPrefix(prefix []byte, cb func(key []byte, v T) error) error {
+	return t.r.ScanPrefix(prefix, func(key, data []byte) error {
+		v, err := t.decode(key, data)
+		if err != nil {
+			return err
+		}
+		return cb(key, v)
+	})
+}
+
+// decode decodes the value for the key.
+func (t *TypedReader[T]) decode(key, data []byte) (T, error) {
+	v, err := t.codec.Unmarshal(data)
+	if err != nil {
+		return v, &DecodeError{Key: bytes.Clone(key), Err: err}
+	}
+	return v, nil
+}
+
+// TypedWriter writes values of type T to a Writer with a Codec.
+type TypedWriter[T any] struct {
+	w     *Writer
+	codec Codec[T]
+}
+
+// NewTypedWriter constructs a new TypedWriter.
+//
+// The caller must Close the Writer when done.
+func NewTypedWriter[T any](w *Writer, codec Codec[T]) *TypedWriter[T] {
+	return &TypedWriter[T]{w: w, codec: codec}
+}
+
+// GetWriter returns the underlying Writer.
+func (t *TypedWriter[T]) GetWriter() *Writer {
+	return t.w
+}
+
+// WriteValue encodes and writes the value for the key.
+func (t *TypedWriter[T]) WriteValue(key []byte, v T) error {
+	data, err := t.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return t.w.WriteValue(key, bytes.NewReader(data))
+}
+
+// jsonCodec is a Codec using encoding/json.
+type jsonCodec[T any] struct{}
+
+// NewJSONCodec returns a Codec encoding values with encoding/json.
+func NewJSONCodec[T any]() Codec[T] {
+	return jsonCodec[T]{}
+}
+
+// Marshal encodes the value.
+func (jsonCodec[T]) Marshal(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes the value.
+func (jsonCodec[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// VTMessage is a pointer to a protobuf-go-lite message of type T.
+type VTMessage[T any] interface {
+	*T
+	protobuf_go_lite.Message
+}
+
+// vtCodec is a Codec for protobuf-go-lite messages.
+type vtCodec[T any, PT VTMessage[T]] struct{}
+
+// NewVTCodec returns a Codec encoding protobuf-go-lite messages.
+//
+// For example: NewVTCodec[IndexEntry]() returns a Codec[*IndexEntry].
+func NewVTCodec[T any, PT VTMessage[T]]() Codec[PT] {
+	return vtCodec[T, PT]{}
+}
+
+// Marshal encodes the message.
+func (vtCodec[T, PT]) Marshal(v PT) ([]byte, error) {
+	return v.MarshalVT()
+}
+
+// Unmarshal decodes the message.
+func (vtCodec[T, PT]) Unmarshal(data []byte) (PT, error) {
+	v := PT(new(T))
+	if err := v.UnmarshalVT(data); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
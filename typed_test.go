@@ -0,0 +1,107 @@
+package kvfile
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// typedTestValue is a struct encoded with the JSON codec.
+type typedTestValue struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+// writeTypedTestFile writes the values with a TypedWriter and builds a TypedReader.
+func writeTypedTestFile[T any](t *testing.T, codec Codec[T], keys []string, vals []T) *TypedReader[T] {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	twr := NewTypedWriter(wr, codec)
+	for i, key := range keys {
+		if err := twr.WriteValue([]byte(key), vals[i]); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return NewTypedReader(rdr, codec)
+}
+
+func TestTypedJSON(t *testing.T) {
+	keys := []string{"item/a", "item/b", "other"}
+	vals := []typedTestValue{
+		{Name: "a", Count: 1, Tags: []string{"x"}},
+		{Name: "b", Count: 2},
+		{Name: "other"},
+	}
+	trd := writeTypedTestFile(t, NewJSONCodec[typedTestValue](), keys, vals)
+
+	v, found, err := trd.Get([]byte("item/a"))
+	if err != nil || !found || v.Name != "a" || v.Count != 1 || !slices.Equal(v.Tags, []string{"x"}) {
+		t.Fatalf("unexpected value: %v %v %v", v, found, err)
+	}
+	if _, found, err := trd.Get([]byte("missing")); err != nil || found {
+		t.Fatalf("expected missing key: %v %v", found, err)
+	}
+
+	var scanned []string
+	err = trd.ScanPrefix([]byte("item/"), func(key []byte, v typedTestValue) error {
+		scanned = append(scanned, string(key)+"="+v.Name)
+		return nil
+	})
+	if err != nil || !slices.Equal(scanned, []string{"item/a=a", "item/b=b"}) {
+		t.Fatalf("unexpected scan: %v %v", scanned, err)
+	}
+
+	// decode errors carry the key
+	brokenRdr := NewTypedReader(trd.GetReader(), NewJSONCodec[int]())
+	var decodeErr *DecodeError
+	if _, _, err := brokenRdr.Get([]byte("other")); !errors.As(err, &decodeErr) || string(decodeErr.Key) != "other" {
+		t.Fatalf("expected decode error for other: %v", err)
+	}
+	err = brokenRdr.ScanPrefix(nil, func(key []byte, v int) error { return nil })
+	if !errors.As(err, &decodeErr) || string(decodeErr.Key) != "item/a" {
+		t.Fatalf("expected decode error for item/a: %v", err)
+	}
+}
+
+func TestTypedVT(t *testing.T) {
+	keys := []string{"a", "b"}
+	vals := []*IndexEntry{
+		{Key: []byte("key-a"), Offset: 1, Size: 2},
+		{Key: []byte("key-b"), ExpiresUnixMs: 3},
+	}
+	trd := writeTypedTestFile(t, NewVTCodec[IndexEntry](), keys, vals)
+	for i, key := range keys {
+		v, found, err := trd.Get([]byte(key))
+		if err != nil || !found || !v.EqualVT(vals[i]) {
+			t.Fatalf("unexpected value for %s: %v %v %v", key, v, found, err)
+		}
+	}
+
+	// not a valid message
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	if err := wr.WriteValue([]byte("bad"), bytes.NewReader([]byte{0xff})); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var decodeErr *DecodeError
+	if _, found, err := NewTypedReader(rdr, NewVTCodec[IndexEntry]()).Get([]byte("bad")); !found || !errors.As(err, &decodeErr) || string(decodeErr.Key) != "bad" {
+		t.Fatalf("expected decode error: %v %v", found, err)
+	}
+}
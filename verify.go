@@ -0,0 +1,80 @@
+package kvfile
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// VerifyError describes the first problem found by Verify.
+type VerifyError struct {
+	// Index is the index of the entry with the problem.
+	// -1 if the problem is not in an entry.
+	Index int64
+	// Offset is the position in the file of the index entry, value, or footer
+	// block with the problem.
+	Offset uint64
+	// Err is the problem found.
+	Err error
+}
+
+// Error returns the error string.
+func (e *VerifyError) Error() string {
+	if e.Index < 0 {
+		return "verify at offset " + strconv.FormatUint(e.Offset, 10) + ": " + e.Err.Error()
+	}
+	return "verify entry " + strconv.FormatInt(e.Index, 10) + " at offset " + strconv.FormatUint(e.Offset, 10) + ": " + e.Err.Error()
+}
+
+// Unwrap returns the problem found.
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// Verify checks the structure of the file.
+//
+// Checks that the footer blocks and each index entry can be read, the keys are
+// sorted without duplicates, and the values are within the values region. If
+// readValues is set, also reads each value, for example to verify the
+// checksums of compressed values.
+//
+// Returns a *VerifyError for the first problem found.
+func (r *Reader) Verify(readValues bool) error {
+	for _, block := range r.footerBlocks {
+		if _, err := r.readFooterBlockData(block); err != nil {
+			return &VerifyError{Index: -1, Offset: block.pos, Err: err}
+		}
+	}
+
+	var prevKey []byte
+	for i := uint64(0); i < r.indexEntryCount; i++ {
+		indexEntry, err := r.ReadIndexEntry(i)
+		if err != nil {
+			return &VerifyError{Index: int64(i), Offset: r.IndexEntryOffset(i), Err: err}
+		}
+		key := indexEntry.GetKey()
+		if len(key) == 0 {
+			return &VerifyError{Index: int64(i), Offset: r.IndexEntryOffset(i), Err: errors.New("empty key")}
+		}
+		if i != 0 {
+			if cmp := bytes.Compare(prevKey, key); cmp == 0 {
+				return &VerifyError{Index: int64(i), Offset: r.IndexEntryOffset(i), Err: errors.Errorf("duplicate key %q", key)}
+			} else if cmp > 0 {
+				return &VerifyError{Index: int64(i), Offset: r.IndexEntryOffset(i), Err: errors.Errorf("key %q is out of order", key)}
+			}
+		}
+		prevKey = key
+
+		if _, _, err := r.GetValuePositionWithEntry(indexEntry, int(i)); err != nil {
+			return &VerifyError{Index: int64(i), Offset: r.IndexEntryOffset(i), Err: err}
+		}
+		if readValues {
+			if _, err := r.ReadToWithEntry(indexEntry, int(i), io.Discard); err != nil {
+				return &VerifyError{Index: int64(i), Offset: indexEntry.GetOffset(), Err: err}
+			}
+		}
+	}
+	return nil
+}
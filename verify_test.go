@@ -0,0 +1,55 @@
+package kvfile
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestVerify(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	for i := 0; i < 10; i++ {
+		key := []byte("test-" + strconv.Itoa(i))
+		if err := wr.WriteValue(key, strings.NewReader(strings.Repeat("v", 100+i))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	fixture := buf.Bytes()
+
+	rdr, err := BuildReader(bytes.NewReader(fixture), uint64(len(fixture)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := rdr.Verify(true); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// flip test-3 to test-8 in the index
+	corrupt := bytes.Clone(fixture)
+	keyPos := bytes.Index(corrupt, []byte("test-3"))
+	corrupt[keyPos+5] = '8'
+	rdr, err = BuildReader(bytes.NewReader(corrupt), uint64(len(corrupt)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = rdr.Verify(false)
+	var verr *VerifyError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected verify error: %v", err)
+	}
+	// the offset is the start of the index entry containing test-4
+	entryKeyPos := uint64(bytes.Index(corrupt, []byte("test-4")))
+	if verr.Index != 4 || verr.Offset > entryKeyPos || entryKeyPos-verr.Offset > 16 {
+		t.Fatalf("unexpected verify error: %v", err)
+	}
+	if !strings.Contains(err.Error(), `"test-4" is out of order`) {
+		t.Fatalf("unexpected verify error: %v", err)
+	}
+}
@@ -2,10 +2,14 @@ package kvfile
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
 	"encoding/binary"
+	"hash"
 	"io"
 	"slices"
 	"sync"
+	"time"
 
 	protobuf_go_lite "github.com/aperturerobotics/protobuf-go-lite"
 	"github.com/pkg/errors"
@@ -16,33 +20,330 @@ import (
 // Note: keys must not contain duplicates or an error will be returned.
 // Concurrency safe.
 type Writer struct {
-	out io.Writer
-	mtx sync.Mutex
-	buf []byte
-	idx []*IndexEntry
-	pos uint64
-	fin bool
+	out  io.Writer
+	vout io.Writer
+	opts WriterOptions
+	mtx  sync.Mutex
+	buf  []byte
+	idx  []*IndexEntry
+	pos  uint64
+	hdr  bool
+	fin  bool
+
+	// digestHash computes the content digest if enabled
+	digestHash hash.Hash
+	// signHash computes the SHA-256 content digest if signing
+	signHash hash.Hash
+	// digestKey is the last key written to the content digests
+	digestKey []byte
+	// leafHashes contains the Merkle leaf hashes if writing the Merkle tree
+	leafHashes map[*IndexEntry][]byte
+	// digest is the content digest computed when closing
+	digest []byte
+	// footer contains the footer blocks added with AddFooterBlock
+	footer []*footerBlockData
+	// vcloser closes the value writer if using ValueWriter
+	vcloser io.Closer
+	// cout counts the bytes written to out if using ValueWriter
+	cout *countingWriter
+	// valueBytes is the total size of the values written
+	valueBytes uint64
+}
+
+// WriterOptions are optional settings for a Writer.
+type WriterOptions struct {
+	// FixedKeyWidth enables the fixed-width key index layout if set.
+	//
+	// All keys must be exactly FixedKeyWidth bytes long. The index is written
+	// as a list of fixed-width records (key, offset, size) which can be located
+	// arithmetically without the positions list or protobuf decoding.
+	//
+	// Entries cannot have an expiry, metadata, or tombstone in this layout.
+	// Files written with this layout cannot be read by older readers.
+	FixedKeyWidth int
+	// WriteBounds writes the min and max keys to the footer when closing.
+	//
+	// Reader.Bounds returns the keys without reading the index if set.
+	WriteBounds bool
+	// WriteStats writes the total value size, max key size, and max value size
+	// to the footer when closing.
+	//
+	// Reader.Stats returns the stats without scanning the index if set.
+	WriteStats bool
+	// HashIndex writes a hash table mapping key hashes to entry indexes to the
+	// footer when closing.
+	//
+	// Get and Exists use the hash table when present for O(1) point lookups
+	// instead of a binary search. Costs 16 bytes per entry in the file.
+	HashIndex bool
+	// MerkleTree writes a Merkle tree over the entries to the footer when
+	// closing.
+	//
+	// Reader.RootHash and Reader.Prove read the tree without reading the
+	// values if set. The values are hashed while writing. Costs about 64
+	// bytes per entry in the file.
+	MerkleTree bool
+	// SignatureKey signs the contents with the Ed25519 key and writes the
+	// signature to the footer when closing.
+	//
+	// The values are hashed while writing, so the keys must be written in
+	// ascending order: a *KeyOrderError is returned otherwise. Values without
+	// a known size (Len or Seek) are buffered in memory. Check the signature
+	// with Reader.VerifyEmbeddedSignature. See Sign for what is signed.
+	SignatureKey ed25519.PrivateKey
+	// ValueHeaders writes a header before each value with the key, value
+	// length, and entry attributes so the file can be decoded front-to-back
+	// with DecodeStream and the index can be rebuilt with RecoverIndex.
+	//
+	// The index is still written so random access works as usual. If the size
+	// of a value cannot be determined from the reader (Len or Seek) the value
+	// is buffered in memory before writing.
+	ValueHeaders bool
+	// ContentDigest computes the content digest with the hash function while
+	// writing. See Writer.ContentDigest and the ContentDigest function.
+	//
+	// The keys must be written in ascending order: a *KeyOrderError is
+	// returned otherwise. Values without a known size (Len or Seek) are
+	// buffered in memory.
+	ContentDigest func() hash.Hash
+	// EncryptedValues marks the values in the file as encrypted.
+	//
+	// The Writer does not encrypt the values: this flag is set by wrappers such
+	// as the encrypt package. BuildReader refuses to open the file unless
+	// ReaderOptions.AllowEncryptedValues is set.
+	EncryptedValues bool
+	// ValueWriter wraps the output for writing the values, for example to
+	// compress the values while keeping the index uncompressed.
+	//
+	// The value offsets in the index are positions in the value stream. The
+	// value writer is closed before writing the index to the output. Files
+	// written with ValueWriter must be read with ReaderOptions.OpenValues.
+	ValueWriter func(out io.Writer) (io.WriteCloser, error)
+	// Progress is called after writing each value with the number of entries
+	// and the total size of the values written so far.
+	//
+	// Called with the writer locked: it must not call methods of the Writer.
+	Progress func(entries, valueBytes uint64)
+}
+
+// ValueAligner is implemented by writers which can start a new block before a
+// value, for example a compressed writer aligning values to frame boundaries.
+//
+// Writer calls AlignValue on the value writer before writing each value.
+type ValueAligner interface {
+	// AlignValue is called before writing a value of the given size.
+	// The size is -1 if unknown, in which case the value should not be
+	// aligned: streamed values would otherwise each start a new block.
+	AlignValue(size int64) error
 }
 
 // NewWriter builds a new writer.
 func NewWriter(out io.Writer) *Writer {
-	return &Writer{out: out}
+	return &Writer{out: out, vout: out}
+}
+
+// NewWriterWithOptions builds a new writer with the given options.
+func NewWriterWithOptions(out io.Writer, opts WriterOptions) (*Writer, error) {
+	if opts.FixedKeyWidth < 0 || opts.FixedKeyWidth > maxIndexEntrySize {
+		return nil, errors.Errorf("invalid fixed key width: %v", opts.FixedKeyWidth)
+	}
+	if opts.SignatureKey != nil && len(opts.SignatureKey) != ed25519.PrivateKeySize {
+		return nil, errors.Errorf("invalid ed25519 private key size: %v", len(opts.SignatureKey))
+	}
+	w := &Writer{out: out, vout: out, opts: opts}
+	if opts.ContentDigest != nil {
+		w.digestHash = opts.ContentDigest()
+	}
+	if opts.SignatureKey != nil {
+		w.signHash = sha256.New()
+	}
+	if opts.ValueWriter != nil {
+		w.cout = &countingWriter{w: out}
+		vout, err := opts.ValueWriter(w.cout)
+		if err != nil {
+			return nil, err
+		}
+		w.out, w.vout, w.vcloser = w.cout, vout, vout
+	}
+	return w, nil
 }
 
 // WriteValue writes a key/value pair to the kvfile writer.
 //
 // The writer is closed if an error is returned.
 func (w *Writer) WriteValue(key []byte, valueRdr io.Reader) error {
+	return w.writeEntry(&IndexEntry{Key: key}, valueRdr, -1)
+}
+
+// WriteValueFrom writes a key/value pair with the value written by writeValue.
+//
+// The value is written directly to the file without an intermediate reader.
+// writeValue must return the number of bytes it wrote: a mismatch returns an
+// error. If the value size is needed before the value is written (value
+// headers or content digests) the value is buffered in memory.
+// The writer is closed if an error is returned while writing the value.
+func (w *Writer) WriteValueFrom(key []byte, writeValue WriteValueFunc) error {
+	w.mtx.Lock()
+	needSize := w.opts.ValueHeaders || w.digestsEnabledLocked()
+	w.mtx.Unlock()
+	if needSize {
+		var buf bytes.Buffer
+		nw, err := writeValue(&buf, key)
+		if err != nil {
+			return err
+		}
+		if nw != uint64(buf.Len()) {
+			return errors.Errorf("write value returned %v but wrote %v bytes", nw, buf.Len())
+		}
+		return w.writeEntry(&IndexEntry{Key: key}, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	}
+	return w.writeEntry(&IndexEntry{Key: key}, &valueFuncReader{key: key, writeValue: writeValue}, -1)
+}
+
+// WriteValueBytesMeta writes a key/value pair with metadata to the kvfile writer.
+//
+// The metadata is stored in the index entry and is returned with the entries
+// from searches and scans. It must not be larger than 256 bytes and the
+// resulting index entry must not exceed the maximum index entry size.
+//
+// The writer is closed if an error is returned while writing the value.
+func (w *Writer) WriteValueBytesMeta(key, value, meta []byte) error {
+	if len(meta) > maxMetaSize {
+		return errors.Errorf("entry metadata too large: %v > %v", len(meta), maxMetaSize)
+	}
+	return w.writeEntry(&IndexEntry{Key: key, Meta: meta}, bytes.NewReader(value), int64(len(value)))
+}
+
+// WriteValueWithExpiry writes a key/value pair which expires at the given time.
+//
+// Readers with FilterExpired set hide the entry once the expiry has passed.
+// If expires is the zero time the entry never expires.
+// The writer is closed if an error is returned.
+func (w *Writer) WriteValueWithExpiry(key []byte, valueRdr io.Reader, expires time.Time) error {
+	entry := &IndexEntry{Key: key}
+	if !expires.IsZero() {
+		// clamp times at or before the unix epoch to 1ms (already expired)
+		entry.ExpiresUnixMs = 1
+		if expiresMs := expires.UnixMilli(); expiresMs > 1 {
+			entry.ExpiresUnixMs = uint64(expiresMs)
+		}
+	}
+	return w.writeEntry(entry, valueRdr, -1)
+}
+
+// WriteTombstone writes a tombstone for the key to the kvfile writer.
+//
+// The tombstone has no value and marks the key as deleted: readers hide it by
+// default and a LayeredReader hides the key in lower layers.
+// DecodeStream passes tombstones as empty values.
+// The writer is closed if an error is returned.
+func (w *Writer) WriteTombstone(key []byte) error {
+	return w.writeEntry(&IndexEntry{Key: key, Tombstone: true}, bytes.NewReader(nil), 0)
+}
+
+// AddFooterBlock adds a block to the footer written when closing.
+//
+// The type must be at least MinUserFooterBlockType and unique within the file.
+// Readers which do not know the type skip the block. The data is copied.
+func (w *Writer) AddFooterBlock(typ uint32, data []byte) error {
 	w.mtx.Lock()
 	defer w.mtx.Unlock()
 
 	if w.fin {
 		return errors.New("writer is already closed")
 	}
+	if typ < MinUserFooterBlockType {
+		return errors.Errorf("footer block type %v is reserved: must be at least %v", typ, MinUserFooterBlockType)
+	}
+	if uint64(len(data)) > maxFooterBlockSize {
+		return errors.Errorf("footer block too large: %v > %v", len(data), maxFooterBlockSize)
+	}
+	for _, block := range w.footer {
+		if block.typ == typ {
+			return errors.Errorf("duplicate footer block type: %v", typ)
+		}
+	}
+	w.footer = append(w.footer, &footerBlockData{typ: typ, data: bytes.Clone(data)})
+	return nil
+}
 
-	offset := w.pos
-	buf := w.getBufLocked()
-	nw, err := io.CopyBuffer(w.out, valueRdr, buf)
+// writeEntry writes the value and appends the entry to the index.
+// The Offset and Size fields of the entry are set by writeEntry.
+// If valueSize is not negative, the index entry size is checked before writing.
+func (w *Writer) writeEntry(entry *IndexEntry, valueRdr io.Reader, valueSize int64) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.fin {
+		return errors.New("writer is already closed")
+	}
+
+	if err := w.checkEntryLocked(entry); err != nil {
+		return err
+	}
+
+	// the value headers and content digests include the value size
+	needSize := w.opts.ValueHeaders || w.digestsEnabledLocked()
+	if needSize && valueSize < 0 {
+		var err error
+		valueRdr, valueSize, err = sizeValueReader(valueRdr)
+		if err != nil {
+			return err
+		}
+	}
+
+	var header []byte
+	if w.opts.ValueHeaders {
+		header = appendValueHeader(nil, entry, uint64(valueSize))
+		if err := w.writeStreamHeaderLocked(); err != nil {
+			return err
+		}
+	}
+
+	// sizeHint is the size of the value if known or -1
+	sizeHint := valueSize
+	if lenRdr, ok := valueRdr.(interface{ Len() int }); ok && sizeHint < 0 {
+		sizeHint = int64(lenRdr.Len())
+	}
+	if aligner, ok := w.vout.(ValueAligner); ok {
+		if err := aligner.AlignValue(sizeHint); err != nil {
+			w.fin = true
+			return err
+		}
+	}
+
+	offset := w.pos + uint64(len(header))
+	if valueSize >= 0 {
+		entry.Offset, entry.Size = offset, uint64(valueSize)
+		if err := checkIndexEntrySize(entry); err != nil {
+			return err
+		}
+	}
+
+	if w.opts.ValueHeaders {
+		nw, err := writeFull(w.vout, header)
+		w.pos += uint64(nw)
+		if err != nil {
+			w.fin = true
+			return err
+		}
+	}
+
+	// the hashes are written with the value to keep the WriterTo of the reader
+	valueOut := []io.Writer{w.vout}
+	for _, digest := range []hash.Hash{w.digestHash, w.signHash} {
+		if digest != nil {
+			writeEntryDigestHeader(digest, entry, uint64(valueSize))
+			valueOut = append(valueOut, digest)
+		}
+	}
+	var leafHash hash.Hash
+	if w.opts.MerkleTree {
+		leafHash = newMerkleLeafHash(entry.GetKey())
+		valueOut = append(valueOut, leafHash)
+	}
+
+	nw, err := w.copyValueLocked(valueOut, valueRdr, sizeHint)
 	w.pos += uint64(nw)
 	if err != nil {
 		if err == io.EOF {
@@ -51,17 +352,34 @@ func (w *Writer) WriteValue(key []byte, valueRdr io.Reader) error {
 			w.fin = true
 		}
 	}
+	if err == nil && needSize && nw != valueSize {
+		w.fin = true
+		err = errors.Errorf("value size changed while writing: %v != %v", nw, valueSize)
+	}
 
-	w.idx = append(w.idx, &IndexEntry{
-		Key:    key,
-		Offset: offset,
-		Size:   uint64(nw),
-	})
+	entry.Offset = offset
+	entry.Size = uint64(nw)
+	w.idx = append(w.idx, entry)
+	w.valueBytes += uint64(nw)
+	if w.digestsEnabledLocked() {
+		w.digestKey = entry.GetKey()
+	}
+	if leafHash != nil {
+		if w.leafHashes == nil {
+			w.leafHashes = make(map[*IndexEntry][]byte)
+		}
+		w.leafHashes[entry] = leafHash.Sum(nil)
+	}
+	if err == nil && w.opts.Progress != nil {
+		w.opts.Progress(uint64(len(w.idx)), w.valueBytes)
+	}
 
 	return err
 }
 
 // GetPos returns the current write position (written size).
+//
+// If using ValueWriter, returns the position in the value stream until closed.
 func (w *Writer) GetPos() uint64 {
 	return w.pos
 }
@@ -77,20 +395,155 @@ func (w *Writer) Close() error {
 
 	idx := w.idx
 	w.fin, w.idx = true, nil
-	nw, err := WriteIndex(w.out, idx, w.pos)
+	if w.opts.ValueHeaders {
+		if err := w.writeStreamEndLocked(); err != nil {
+			return err
+		}
+	}
+	var footer []*footerBlockData
+	if w.opts.WriteBounds && len(idx) != 0 {
+		footer = append(footer, buildBoundsBlock(idx))
+	}
+	if w.opts.WriteStats {
+		footer = append(footer, buildStatsBlock(idx))
+	}
+	if w.opts.MerkleTree && len(idx) != 0 {
+		// the tree is built over the sorted entries
+		sortIndexEntries(idx)
+		block, err := buildMerkleTreeBlock(idx, w.leafHashes)
+		if err != nil {
+			return err
+		}
+		footer = append(footer, block)
+		w.leafHashes = nil
+	}
+	if w.signHash != nil {
+		footer = append(footer, buildSignatureBlock(w.signHash.Sum(nil), w.opts.SignatureKey))
+		w.signHash = nil
+	}
+	footer = append(footer, w.footer...)
+	if w.vcloser != nil {
+		if err := w.vcloser.Close(); err != nil {
+			return err
+		}
+		w.pos = w.cout.n
+	}
+	nw, err := writeIndex(w.out, idx, w.pos, &w.opts, footer)
 	w.pos += nw
+	if err == nil && w.digestHash != nil {
+		w.digest, w.digestHash = w.digestHash.Sum(nil), nil
+	}
 	return err
 }
 
-// getBufLocked gets or allocates the scratch buffer for copies
-func (w *Writer) getBufLocked() []byte {
-	if len(w.buf) == 0 {
-		// size from io.Copy
-		w.buf = make([]byte, 32*1024)
+// checkEntryLocked checks if the entry can be written with the writer options.
+func (w *Writer) checkEntryLocked(entry *IndexEntry) error {
+	if w.digestsEnabledLocked() && len(w.idx) != 0 && bytes.Compare(entry.GetKey(), w.digestKey) <= 0 {
+		return &KeyOrderError{Prev: w.digestKey, Key: entry.GetKey()}
+	}
+	if keyWidth := w.opts.FixedKeyWidth; keyWidth != 0 {
+		if len(entry.GetKey()) != keyWidth {
+			return errors.Errorf("key length %v does not match fixed key width %v", len(entry.GetKey()), keyWidth)
+		}
+		if entry.GetExpiresUnixMs() != 0 || len(entry.GetMeta()) != 0 || entry.GetTombstone() {
+			return errors.New("fixed key width layout does not support entry expiry, metadata, or tombstones")
+		}
+	}
+	return nil
+}
+
+// copyValueLocked copies the value to the outputs.
+//
+// Uses io.Copy if the value implements io.WriterTo or the output implements
+// io.ReaderFrom, otherwise copies with the scratch buffer. sizeHint is the size
+// of the value if known or -1. Returns the number of bytes written to all of
+// the outputs.
+func (w *Writer) copyValueLocked(outs []io.Writer, valueRdr io.Reader, sizeHint int64) (int64, error) {
+	out := outs[0]
+	if len(outs) != 1 {
+		out = io.MultiWriter(outs...)
+	}
+	_, srcWriterTo := valueRdr.(io.WriterTo)
+	_, dstReaderFrom := out.(io.ReaderFrom)
+	if srcWriterTo || dstReaderFrom {
+		return io.Copy(out, valueRdr)
+	}
+	return io.CopyBuffer(out, valueRdr, w.getBufLocked(sizeHint))
+}
+
+// minCopyBufSize is the minimum size of the scratch buffer for copies.
+const minCopyBufSize = 512
+
+// maxCopyBufSize is the maximum size of the scratch buffer for copies.
+const maxCopyBufSize = 32 * 1024
+
+// getBufLocked gets or allocates the scratch buffer for copies.
+//
+// sizeHint is the size of the value to copy if known or -1. The buffer is
+// grown up to the max size as larger values are copied.
+func (w *Writer) getBufLocked(sizeHint int64) []byte {
+	size := maxCopyBufSize
+	if sizeHint >= 0 && sizeHint < maxCopyBufSize {
+		size = max(int(sizeHint), minCopyBufSize)
+	}
+	if len(w.buf) < size {
+		w.buf = make([]byte, size)
 	}
 	return w.buf
 }
 
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n uint64
+}
+
+// Write writes to the underlying writer and counts the bytes.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += uint64(n)
+	return n, err
+}
+
+// valueFuncReader writes the value with a WriteValueFunc.
+//
+// Implements io.WriterTo so copyValueLocked passes the value outputs to the
+// function. Read is not supported.
+type valueFuncReader struct {
+	key        []byte
+	writeValue WriteValueFunc
+}
+
+// Read returns an error: the value can only be written with WriteTo.
+func (v *valueFuncReader) Read(p []byte) (int, error) {
+	return 0, errors.New("value func does not support read")
+}
+
+// WriteTo writes the value to the writer and checks the returned count.
+func (v *valueFuncReader) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	nw, err := v.writeValue(cw, v.key)
+	if err == nil && nw != cw.n {
+		err = errors.Errorf("write value returned %v but wrote %v bytes", nw, cw.n)
+	}
+	return int64(cw.n), err
+}
+
+// sortIndexEntries sorts the index entries by key.
+func sortIndexEntries(index []*IndexEntry) {
+	slices.SortStableFunc(index, func(a, b *IndexEntry) int {
+		return bytes.Compare(a.Key, b.Key)
+	})
+}
+
+// checkIndexEntrySize checks that the encoded index entry is not too large.
+func checkIndexEntrySize(indexEntry *IndexEntry) error {
+	if size := indexEntry.SizeVT(); size > maxIndexEntrySize {
+		return errors.Errorf("index entry too large: %v > %v", size, maxIndexEntrySize)
+	}
+	return nil
+}
+
 // WriteIteratorFunc is a function that returns key/value pairs to write.
 // The callback should return one key at a time in the order they should be written to the file.
 // Return nil, nil or nil, io.EOF if no keys remain.
@@ -121,69 +574,128 @@ func Write(writer io.Writer, keys [][]byte, writeValue WriteValueFunc) error {
 // pos is the position the writer is located at in the file.
 // returns the number of bytes written (end pos - pos).
 func WriteIndex(writer io.Writer, index []*IndexEntry, pos uint64) (uint64, error) {
+	return writeIndex(writer, index, pos, nil, nil)
+}
+
+// writeIndex sorts and checks the index entries and writes them to a file
+// followed by the footer blocks (if any).
+//
+// opts controls the index layout and which footer blocks are built, can be nil.
+// returns the number of bytes written (end pos - pos).
+func writeIndex(writer io.Writer, index []*IndexEntry, pos uint64, opts *WriterOptions, footer []*footerBlockData) (uint64, error) {
 	startPos := pos
+	if opts == nil {
+		opts = &WriterOptions{}
+	}
 
-	// sort the index entries
-	slices.SortStableFunc(index, func(a, b *IndexEntry) int {
-		return bytes.Compare(a.Key, b.Key)
-	})
+	sortIndexEntries(index)
 
-	// write the index entries
-	indexEntryPos := make([]uint64, len(index)+1)
-	var buf []byte
-	var prevKey []byte
-	for i, indexEntry := range index {
-		if i != 0 && bytes.Equal(indexEntry.Key, prevKey) {
-			return pos - startPos, errors.New("duplicate key while writing")
-		}
-		prevKey = indexEntry.Key
-
-		indexEntrySize := indexEntry.SizeVT()
-		if cap(buf) < indexEntrySize {
-			buf = make([]byte, indexEntrySize, indexEntrySize*2)
-		} else {
-			buf = buf[:indexEntrySize]
+	// build the hash index from the sorted entries
+	if opts.HashIndex && len(index) != 0 {
+		block, err := buildHashIndexBlock(index)
+		if err != nil {
+			return 0, err
 		}
+		footer = append(footer, block)
+	}
 
-		_, err := indexEntry.MarshalToSizedBufferVT(buf)
+	// write the index entries
+	var indexEntryPos []uint64
+	var formatFlags uint64
+	var formatFields []uint64
+	if opts.ValueHeaders {
+		formatFlags |= formatFlagValueHeaders
+	}
+	if opts.EncryptedValues {
+		formatFlags |= formatFlagEncryptedValues
+	}
+	if opts.ValueWriter != nil {
+		formatFlags |= formatFlagExternalValues
+	}
+	if fixedKeyWidth := opts.FixedKeyWidth; fixedKeyWidth != 0 {
+		offsetWidth, sizeWidth := fixedRecordWidths(index)
+		nw, err := writeFixedIndexEntries(writer, index, fixedKeyWidth, offsetWidth, sizeWidth)
+		pos += nw
 		if err != nil {
 			return pos - startPos, err
 		}
+		formatFlags |= formatFlagFixedKeyWidth
+		formatFields = append(formatFields, uint64(fixedKeyWidth), uint64(offsetWidth), uint64(sizeWidth))
+	} else {
+		indexEntryPos = make([]uint64, len(index))
+		var buf []byte
+		var prevKey []byte
+		for i, indexEntry := range index {
+			if i != 0 && bytes.Equal(indexEntry.Key, prevKey) {
+				return pos - startPos, errors.Errorf("duplicate key while writing: %q", prevKey)
+			}
+			prevKey = indexEntry.Key
 
-		// write all of buf to writer
-		var nw int
-		for nw < len(buf) {
-			n, err := writer.Write(buf[nw:])
-			if err != nil {
+			if err := checkIndexEntrySize(indexEntry); err != nil {
 				return pos - startPos, err
 			}
-			nw += n
-			pos += uint64(n)
-		}
-
-		// pos = the position just after the index entry
-		// this is the position of the entry size varint
-		indexEntryPos[i] = pos
+			indexEntrySize := indexEntry.SizeVT()
+			if cap(buf) < indexEntrySize {
+				buf = make([]byte, indexEntrySize, indexEntrySize*2)
+			} else {
+				buf = buf[:indexEntrySize]
+			}
 
-		// write the varint size of the entry
-		buf = buf[:0]
-		buf = protobuf_go_lite.AppendVarint(buf, uint64(nw))
-		nw = 0
-		for nw < len(buf) {
-			n, err := writer.Write(buf[nw:])
+			_, err := indexEntry.MarshalToSizedBufferVT(buf)
 			if err != nil {
 				return pos - startPos, err
 			}
-			nw += n
-			pos += uint64(nw)
+
+			// write all of buf to writer
+			var nw int
+			for nw < len(buf) {
+				n, err := writer.Write(buf[nw:])
+				if err != nil {
+					return pos - startPos, err
+				}
+				nw += n
+				pos += uint64(n)
+			}
+
+			// pos = the position just after the index entry
+			// this is the position of the entry size varint
+			indexEntryPos[i] = pos
+
+			// write the varint size of the entry
+			buf = buf[:0]
+			buf = protobuf_go_lite.AppendVarint(buf, uint64(nw))
+			nw = 0
+			for nw < len(buf) {
+				n, err := writer.Write(buf[nw:])
+				if err != nil {
+					return pos - startPos, err
+				}
+				nw += n
+				pos += uint64(nw)
+			}
+
+			// pos = the position just after the size varint
 		}
+	}
 
-		// pos = the position just after the size varint
+	// the format block is written last so it is read along with the directory.
+	if formatFlags != 0 {
+		footer = append(footer, buildFormatBlock(formatFlags, formatFields...))
+	}
+
+	// write the footer extension area
+	if len(footer) != 0 {
+		nw, err := writeFooter(writer, footer)
+		pos += nw
+		if err != nil {
+			return pos - startPos, err
+		}
 	}
 
 	// write the index entry positions (fixed size uint64)
 	// the last entry position is the number of entries
-	indexEntryPos[len(indexEntryPos)-1] = uint64(len(index))
+	indexEntryPos = append(indexEntryPos, uint64(len(index)))
+	buf := make([]byte, 0, 8)
 	for _, entryPos := range indexEntryPos {
 		buf = binary.LittleEndian.AppendUint64(buf[:0], entryPos)
 		var nw int
@@ -0,0 +1,150 @@
+package kvfile_zip
+
+import (
+	"archive/zip"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+	"github.com/pkg/errors"
+)
+
+// SkipFunc is called when an entry is skipped with the name and the reason.
+type SkipFunc func(name, reason string)
+
+// Option configures FromZip and ToZip.
+type Option func(o *options)
+
+// options are the options for FromZip and ToZip.
+type options struct {
+	method  uint16
+	modTime time.Time
+	onSkip  SkipFunc
+}
+
+// WithMethod sets the compression method used by ToZip.
+//
+// Defaults to zip.Deflate. Use zip.Store to write uncompressed entries.
+func WithMethod(method uint16) Option {
+	return func(o *options) {
+		o.method = method
+	}
+}
+
+// WithModTime sets the modification time of the entries written by ToZip.
+//
+// If zero, the entries have no modification time.
+func WithModTime(modTime time.Time) Option {
+	return func(o *options) {
+		o.modTime = modTime
+	}
+}
+
+// WithOnSkip sets the function called when FromZip skips an entry.
+func WithOnSkip(onSkip SkipFunc) Option {
+	return func(o *options) {
+		o.onSkip = onSkip
+	}
+}
+
+// buildOptions applies the options to the defaults.
+func buildOptions(opts []Option) *options {
+	o := &options{method: zip.Deflate}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// NormalizeName normalizes a zip entry name to a key.
+//
+// Backslashes are converted to slashes, the path is cleaned, and leading
+// slashes are removed. Returns false if the name refers to the root or
+// escapes it with "..".
+func NormalizeName(name string) (string, bool) {
+	name = path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.TrimLeft(name, "/")
+	if name == "" || name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	return name, true
+}
+
+// FromZip writes the regular files in the zip to a kvfile.
+//
+// The entry names are normalized with NormalizeName and used as keys, and the
+// decompressed contents are streamed as the values. Directories are skipped.
+// Symlinks, other non-regular entries, and names which cannot be normalized
+// are skipped and reported to the WithOnSkip function. Returns an error if two
+// entries have the same normalized name.
+func FromZip(dst io.Writer, zr *zip.Reader, opts ...Option) error {
+	o := buildOptions(opts)
+	wr := kvfile.NewWriter(dst)
+	// seen maps each key to the name of the entry
+	seen := make(map[string]string, len(zr.File))
+	for _, f := range zr.File {
+		mode := f.Mode()
+		if mode.IsDir() || strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		if !mode.IsRegular() {
+			if o.onSkip != nil {
+				o.onSkip(f.Name, "not a regular file")
+			}
+			continue
+		}
+		key, ok := NormalizeName(f.Name)
+		if !ok {
+			if o.onSkip != nil {
+				o.onSkip(f.Name, "invalid name")
+			}
+			continue
+		}
+		if prev, ok := seen[key]; ok {
+			return errors.Errorf("duplicate name %q in zip: entries %q and %q", key, prev, f.Name)
+		}
+		seen[key] = f.Name
+
+		rc, err := f.Open()
+		if err != nil {
+			return errors.Wrapf(err, "open %s", f.Name)
+		}
+		err = wr.WriteValue([]byte(key), rc)
+		_ = rc.Close()
+		if err != nil {
+			return errors.Wrapf(err, "write %s", f.Name)
+		}
+	}
+	return wr.Close()
+}
+
+// ToZip writes the entries of the kvfile to the zip as regular files.
+//
+// The keys are used as the names and the values are streamed as the contents.
+// Entries hidden by the reader are skipped. Returns an error if a key is empty
+// or ends with a slash. The caller must Close the zip.Writer.
+func ToZip(zw *zip.Writer, src *kvfile.Reader, opts ...Option) error {
+	o := buildOptions(opts)
+	return src.ScanPrefixEntries(nil, func(indexEntry *kvfile.IndexEntry, indexEntryIdx int) error {
+		name := string(indexEntry.GetKey())
+		if name == "" || strings.HasSuffix(name, "/") {
+			return errors.Errorf("key %q is not a valid zip file name", name)
+		}
+		hdr := &zip.FileHeader{
+			Name:     name,
+			Method:   o.method,
+			Modified: o.modTime,
+		}
+		hdr.SetMode(0o644)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return errors.Wrapf(err, "create %s", name)
+		}
+		if _, err := src.ReadToWithEntry(indexEntry, indexEntryIdx, w); err != nil {
+			return errors.Wrapf(err, "write %s", name)
+		}
+		return nil
+	})
+}
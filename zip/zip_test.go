@@ -0,0 +1,187 @@
+package kvfile_zip
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+
+	kvfile "github.com/aperturerobotics/go-kvfile"
+)
+
+// testZipEntry is an entry in a test zip.
+type testZipEntry struct {
+	name string
+	mode fs.FileMode
+	data []byte
+}
+
+// buildTestZip writes the entries to a zip and returns a reader.
+func buildTestZip(t *testing.T, entries []testZipEntry) *zip.Reader {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		hdr := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		mode := entry.mode
+		if mode == 0 {
+			mode = 0o644
+		}
+		hdr.SetMode(mode)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if _, err := w.Write(entry.data); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return zr
+}
+
+// convertFromZip converts the zip to a kvfile and returns the key/value pairs.
+func convertFromZip(t *testing.T, zr *zip.Reader, opts ...Option) (*kvfile.Reader, map[string][]byte) {
+	var buf bytes.Buffer
+	if err := FromZip(&buf, zr, opts...); err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := kvfile.BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	vals := make(map[string][]byte)
+	err = rdr.ScanPrefix(nil, func(key, value []byte) error {
+		vals[string(key)] = value
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return rdr, vals
+}
+
+func TestZip(t *testing.T) {
+	blob := make([]byte, 100<<10)
+	rnd := rand.New(rand.NewPCG(1, 2))
+	for i := range blob {
+		blob[i] = byte(rnd.IntN(16))
+	}
+	zr := buildTestZip(t, []testZipEntry{
+		{name: "b.txt", data: []byte("hello")},
+		{name: "dir/", mode: fs.ModeDir | 0o755},
+		{name: "dir/blob.bin", data: blob},
+		{name: "./c", data: []byte("dot")},
+		{name: "win\\path.txt", data: []byte("backslash")},
+		{name: "empty", data: nil},
+		{name: "link", mode: fs.ModeSymlink | 0o777, data: []byte("b.txt")},
+		{name: "../evil", data: []byte("escape")},
+	})
+
+	var skipped []string
+	rdr, vals := convertFromZip(t, zr, WithOnSkip(func(name, reason string) {
+		skipped = append(skipped, name+": "+reason)
+	}))
+	expected := map[string][]byte{
+		"b.txt":        []byte("hello"),
+		"c":            []byte("dot"),
+		"dir/blob.bin": blob,
+		"empty":        nil,
+		"win/path.txt": []byte("backslash"),
+	}
+	if len(vals) != len(expected) {
+		t.Fatalf("unexpected keys: %v", vals)
+	}
+	for key, val := range expected {
+		if !bytes.Equal(vals[key], val) {
+			t.Fatalf("unexpected value for %s", key)
+		}
+	}
+	if !slices.Equal(skipped, []string{"link: not a regular file", "../evil: invalid name"}) {
+		t.Fatalf("unexpected skipped entries: %v", skipped)
+	}
+
+	// round trip with each method
+	for _, method := range []uint16{zip.Store, zip.Deflate} {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		if err := ToZip(zw, rdr, WithMethod(method)); err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err.Error())
+		}
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		for _, f := range zr.File {
+			if f.Method != method {
+				t.Fatalf("expected method %d for %s: %d", method, f.Name, f.Method)
+			}
+		}
+		_, roundTrip := convertFromZip(t, zr)
+		if len(roundTrip) != len(expected) {
+			t.Fatalf("unexpected keys after round trip: %v", roundTrip)
+		}
+		for key, val := range expected {
+			if !bytes.Equal(roundTrip[key], val) {
+				t.Fatalf("unexpected value for %s after round trip", key)
+			}
+		}
+	}
+}
+
+func TestZipEmpty(t *testing.T) {
+	rdr, vals := convertFromZip(t, buildTestZip(t, nil))
+	if rdr.Size() != 0 || len(vals) != 0 {
+		t.Fatalf("expected an empty kvfile: %v", vals)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if err := ToZip(zw, rdr); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil || len(zr.File) != 0 {
+		t.Fatalf("expected an empty zip: %v", err)
+	}
+}
+
+func TestZipErrors(t *testing.T) {
+	zr := buildTestZip(t, []testZipEntry{
+		{name: "x", data: []byte("1")},
+		{name: "/x", data: []byte("2")},
+	})
+	var buf bytes.Buffer
+	if err := FromZip(&buf, zr); err == nil || !strings.Contains(err.Error(), `duplicate name "x"`) {
+		t.Fatalf("expected duplicate name error: %v", err)
+	}
+
+	buf.Reset()
+	wr := kvfile.NewWriter(&buf)
+	if err := wr.WriteValue([]byte("dir/"), bytes.NewReader([]byte("x"))); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	rdr, err := kvfile.BuildReader(bytes.NewReader(buf.Bytes()), uint64(buf.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := ToZip(zip.NewWriter(&bytes.Buffer{}), rdr); err == nil {
+		t.Fatal("expected an error for a key ending with a slash")
+	}
+}